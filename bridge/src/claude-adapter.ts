@@ -1,5 +1,10 @@
 import { query } from "@anthropic-ai/claude-agent-sdk";
-import { ChatRequest, ChatResponse } from "./types.js";
+import {
+  BridgeEvent,
+  ChatRequest,
+  ChatResponse,
+  CompactRequest,
+} from "./types.js";
 import { SessionManager } from "./session-manager.js";
 
 const ALLOWED_TOOLS = [
@@ -13,6 +18,59 @@ const ALLOWED_TOOLS = [
   "WebSearch",
 ];
 
+// toolEvents extracts the tool activity from an SDK message. Text is only
+// kept from assistant messages that also call tools: the text of the final
+// assistant message is the result.
+function toolEvents(message: any): BridgeEvent[] {
+  const content = message.message?.content;
+  if (!Array.isArray(content)) {
+    return [];
+  }
+  const events: BridgeEvent[] = [];
+  if (message.type === "assistant") {
+    if (!content.some((block: any) => block.type === "tool_use")) {
+      return [];
+    }
+    for (const block of content) {
+      if (block.type === "text" && block.text) {
+        events.push({ type: "text", text: block.text });
+      } else if (block.type === "tool_use") {
+        events.push({
+          type: "tool_use",
+          id: block.id,
+          name: block.name,
+          input: block.input ?? {},
+        });
+      }
+    }
+  } else if (message.type === "user") {
+    for (const block of content) {
+      if (block.type === "tool_result") {
+        events.push({
+          type: "tool_result",
+          tool_use_id: block.tool_use_id,
+          output: toolResultText(block.content),
+          is_error: !!block.is_error,
+        });
+      }
+    }
+  }
+  return events;
+}
+
+function toolResultText(content: unknown): string {
+  if (typeof content === "string") {
+    return content;
+  }
+  if (Array.isArray(content)) {
+    return content
+      .filter((part: any) => part.type === "text")
+      .map((part: any) => part.text)
+      .join("\n");
+  }
+  return "";
+}
+
 export class ClaudeAdapter {
   private sessionManager: SessionManager;
 
@@ -21,7 +79,9 @@ export class ClaudeAdapter {
   }
 
   async chat(req: ChatRequest): Promise<ChatResponse> {
-    const existingSessionId = this.sessionManager.get(req.conversation_id);
+    const existingSessionId =
+      req.session_id ||
+      this.sessionManager.get(req.conversation_id, req.working_dir);
 
     let resultText = "";
     let sessionId = "";
@@ -29,6 +89,7 @@ export class ClaudeAdapter {
     let inputTokens = 0;
     let outputTokens = 0;
     let compacted = false;
+    const events: BridgeEvent[] = [];
 
     try {
       const queryOptions: Record<string, unknown> = {
@@ -69,6 +130,9 @@ export class ClaudeAdapter {
           compacted = true;
         }
 
+        // Relay the tool calls Claude Code makes
+        events.push(...toolEvents(message));
+
         // Capture result text
         if ("result" in message && typeof (message as any).result === "string") {
           resultText = (message as any).result;
@@ -89,7 +153,7 @@ export class ClaudeAdapter {
 
       // Store session mapping for future turns
       if (sessionId) {
-        this.sessionManager.set(req.conversation_id, sessionId);
+        this.sessionManager.set(req.conversation_id, sessionId, req.working_dir);
       }
     } catch (err: unknown) {
       isError = true;
@@ -110,6 +174,71 @@ export class ClaudeAdapter {
       },
       is_error: isError,
       ...(compacted && { compacted: true }),
+      ...(events.length > 0 && { events }),
+    };
+  }
+
+  // compact runs /compact in the conversation's session. The summary
+  // continues in a new session, which is remembered for the next turn.
+  async compact(
+    conversationId: string,
+    req: CompactRequest
+  ): Promise<ChatResponse> {
+    const existingSessionId =
+      req.session_id ||
+      this.sessionManager.get(conversationId, req.working_dir);
+    if (!existingSessionId) {
+      return {
+        result: "No session to compact",
+        session_id: "",
+        usage: { input_tokens: 0, output_tokens: 0 },
+        is_error: true,
+      };
+    }
+
+    let sessionId = "";
+    let isError = false;
+    let resultText = "";
+    try {
+      for await (const message of query({
+        prompt: "/compact",
+        options: {
+          allowedTools: ALLOWED_TOOLS,
+          permissionMode: "bypassPermissions",
+          cwd: req.working_dir,
+          resume: existingSessionId,
+        },
+      })) {
+        if (
+          message.type === "system" &&
+          (message as any).subtype === "init" &&
+          (message as any).session_id
+        ) {
+          sessionId = (message as any).session_id;
+        }
+        if ("result" in message && typeof (message as any).result === "string") {
+          resultText = (message as any).result;
+        }
+      }
+      if (sessionId) {
+        this.sessionManager.set(conversationId, sessionId, req.working_dir);
+      }
+    } catch (err: unknown) {
+      isError = true;
+      resultText =
+        err instanceof Error ? err.message : "Unknown error from Claude Code";
+      console.error(
+        `[claude-adapter] compact error for conversation ${conversationId}:`,
+        err
+      );
+    }
+
+    return {
+      result: resultText,
+      session_id: sessionId || existingSessionId,
+      usage: { input_tokens: 0, output_tokens: 0 },
+      is_error: isError,
+      compacted: !isError,
     };
   }
 
@@ -1,7 +1,61 @@
-import { Codex } from "@openai/codex-sdk";
-import { ChatRequest, ChatResponse } from "./types.js";
+import { Codex, ThreadItem, ThreadOptions } from "@openai/codex-sdk";
+import { BridgeEvent, ChatRequest, ChatResponse } from "./types.js";
 import { SessionManager } from "./session-manager.js";
 
+// itemEvents converts a completed Codex item to bridge events, so that
+// Shelley shows Codex's commands and edits like the tool calls of other
+// models. Reasoning and the final message are not relayed.
+function itemEvents(item: ThreadItem): BridgeEvent[] {
+  switch (item.type) {
+    case "agent_message":
+      return [{ type: "text", text: item.text }];
+    case "command_execution":
+      return toolCall(
+        item.id,
+        "Bash",
+        { command: item.command },
+        item.aggregated_output,
+        item.status === "failed" ||
+          (item.exit_code !== undefined && item.exit_code !== 0)
+      );
+    case "file_change":
+      return toolCall(
+        item.id,
+        "FileChange",
+        { changes: item.changes },
+        item.changes.map((c) => `${c.kind} ${c.path}`).join("\n"),
+        item.status === "failed"
+      );
+    case "mcp_tool_call":
+      return toolCall(
+        item.id,
+        `${item.server}.${item.tool}`,
+        {},
+        item.status,
+        item.status === "failed"
+      );
+    case "web_search":
+      return toolCall(item.id, "WebSearch", { query: item.query }, "", false);
+    case "error":
+      return [{ type: "text", text: item.message }];
+    default:
+      return [];
+  }
+}
+
+function toolCall(
+  id: string,
+  name: string,
+  input: unknown,
+  output: string,
+  isError: boolean
+): BridgeEvent[] {
+  return [
+    { type: "tool_use", id, name, input },
+    { type: "tool_result", tool_use_id: id, output, is_error: isError },
+  ];
+}
+
 export class CodexAdapter {
   private sessionManager: SessionManager;
   private codex: Codex;
@@ -12,26 +66,48 @@ export class CodexAdapter {
   }
 
   async chat(req: ChatRequest): Promise<ChatResponse> {
-    const existingThreadId = this.sessionManager.get(req.conversation_id);
+    const existingThreadId =
+      req.session_id ||
+      this.sessionManager.get(req.conversation_id, req.working_dir);
 
     let resultText = "";
     let threadId = "";
     let isError = false;
     let inputTokens = 0;
     let outputTokens = 0;
+    const events: BridgeEvent[] = [];
 
     try {
+      const threadOptions: ThreadOptions = {
+        workingDirectory: req.working_dir || process.cwd(),
+        skipGitRepoCheck: true,
+      };
+      if (req.sandbox) {
+        threadOptions.sandboxMode = req.sandbox;
+      }
+      if (req.reasoning_effort) {
+        threadOptions.modelReasoningEffort = req.reasoning_effort;
+      }
       const thread = existingThreadId
-        ? this.codex.resumeThread(existingThreadId)
-        : this.codex.startThread({
-            workingDirectory: req.working_dir || process.cwd(),
-          });
+        ? this.codex.resumeThread(existingThreadId, threadOptions)
+        : this.codex.startThread(threadOptions);
 
       const turn = await thread.run(req.message);
 
       threadId = thread.id || "";
       resultText = turn.finalResponse || "";
 
+      // The last agent message is the final response
+      let last = turn.items.length - 1;
+      while (last >= 0 && turn.items[last].type !== "agent_message") {
+        last--;
+      }
+      turn.items.forEach((item, i) => {
+        if (i !== last) {
+          events.push(...itemEvents(item));
+        }
+      });
+
       if (turn.usage) {
         inputTokens = turn.usage.input_tokens || 0;
         outputTokens = turn.usage.output_tokens || 0;
@@ -39,7 +115,7 @@ export class CodexAdapter {
 
       // Store thread mapping for future turns
       if (threadId) {
-        this.sessionManager.set(req.conversation_id, threadId);
+        this.sessionManager.set(req.conversation_id, threadId, req.working_dir);
       }
     } catch (err: unknown) {
       isError = true;
@@ -59,6 +135,7 @@ export class CodexAdapter {
         output_tokens: outputTokens,
       },
       is_error: isError,
+      ...(events.length > 0 && { events }),
     };
   }
 
@@ -0,0 +1,150 @@
+import { spawn } from "node:child_process";
+import { createInterface } from "node:readline";
+import { BridgeEvent, ChatRequest, ChatResponse } from "./types.js";
+import { SessionManager } from "./session-manager.js";
+
+// GEMINI_CLI_PATH overrides the gemini executable
+const GEMINI_CLI = process.env.GEMINI_CLI_PATH || "gemini";
+
+// GeminiAdapter runs the Gemini CLI in headless mode, one process per turn,
+// and reads its stream-json output.
+export class GeminiAdapter {
+  private sessionManager: SessionManager;
+
+  constructor(sessionManager: SessionManager) {
+    this.sessionManager = sessionManager;
+  }
+
+  async chat(req: ChatRequest): Promise<ChatResponse> {
+    const existingSessionId =
+      req.session_id ||
+      this.sessionManager.get(req.conversation_id, req.working_dir);
+
+    let resultText = "";
+    let sessionId = "";
+    let isError = false;
+    let inputTokens = 0;
+    let outputTokens = 0;
+    let cachedTokens = 0;
+    const events: BridgeEvent[] = [];
+
+    try {
+      const args = ["--output-format", "stream-json", "--yolo"];
+      if (existingSessionId) {
+        args.push("--resume", existingSessionId);
+      }
+      args.push("--prompt", req.message);
+
+      const child = spawn(GEMINI_CLI, args, {
+        cwd: req.working_dir || process.cwd(),
+        stdio: ["ignore", "pipe", "pipe"],
+      });
+      let stderr = "";
+      child.stderr.on("data", (chunk) => {
+        stderr += chunk;
+      });
+      const exited = new Promise<number>((resolve, reject) => {
+        child.on("error", reject);
+        child.on("close", (code) => resolve(code ?? 1));
+      });
+
+      // Assistant text is streamed in deltas; text before a tool call is
+      // activity, the text after the last one is the result
+      let text = "";
+      for await (const line of createInterface({ input: child.stdout })) {
+        let event: any;
+        try {
+          event = JSON.parse(line);
+        } catch {
+          continue;
+        }
+        switch (event.type) {
+          case "init":
+            sessionId = event.session_id || "";
+            break;
+          case "message":
+            if (event.role === "assistant") {
+              text += event.content || "";
+            }
+            break;
+          case "tool_use":
+            if (text) {
+              events.push({ type: "text", text });
+              text = "";
+            }
+            events.push({
+              type: "tool_use",
+              id: event.tool_id,
+              name: event.tool_name,
+              input: event.parameters ?? {},
+            });
+            break;
+          case "tool_result":
+            events.push({
+              type: "tool_result",
+              tool_use_id: event.tool_id,
+              output:
+                typeof event.output === "string"
+                  ? event.output
+                  : event.error?.message || "",
+              is_error: event.status === "error",
+            });
+            break;
+          case "error":
+            if (event.severity === "error") {
+              isError = true;
+              text = event.message || "Unknown error from Gemini CLI";
+            }
+            break;
+          case "result":
+            if (event.status === "error") {
+              isError = true;
+              text = event.error?.message || text;
+            }
+            inputTokens = event.stats?.input_tokens || 0;
+            outputTokens = event.stats?.output_tokens || 0;
+            cachedTokens = event.stats?.cached || 0;
+            break;
+        }
+      }
+      resultText = text;
+
+      const code = await exited;
+      if (code !== 0 && !isError) {
+        isError = true;
+        resultText = stderr.trim() || `gemini exited with status ${code}`;
+      }
+
+      // Store session mapping for future turns
+      if (sessionId && !isError) {
+        this.sessionManager.set(req.conversation_id, sessionId, req.working_dir);
+      }
+    } catch (err: unknown) {
+      isError = true;
+      resultText =
+        err instanceof Error ? err.message : "Unknown error from Gemini CLI";
+      console.error(
+        `[gemini-adapter] error for conversation ${req.conversation_id}:`,
+        err
+      );
+    }
+
+    // Gemini counts cached tokens as input; report them separately like the
+    // other backends
+    return {
+      result: resultText,
+      session_id: sessionId || existingSessionId || "",
+      usage: {
+        input_tokens: Math.max(inputTokens - cachedTokens, 0),
+        output_tokens: outputTokens,
+        ...(cachedTokens > 0 && { cache_read_input_tokens: cachedTokens }),
+      },
+      is_error: isError,
+      ...(events.length > 0 && { events }),
+    };
+  }
+
+  deleteSession(conversationId: string): boolean {
+    return this.sessionManager.delete(conversationId);
+  }
+}
@@ -2,7 +2,13 @@ import express from "express";
 import { SessionManager } from "./session-manager.js";
 import { ClaudeAdapter } from "./claude-adapter.js";
 import { CodexAdapter } from "./codex-adapter.js";
-import { ChatRequest, ChatResponse, HealthResponse } from "./types.js";
+import { GeminiAdapter } from "./gemini-adapter.js";
+import {
+  ChatRequest,
+  ChatResponse,
+  CompactRequest,
+  HealthResponse,
+} from "./types.js";
 
 const PORT = parseInt(process.env.BRIDGE_PORT || "9100", 10);
 
@@ -11,23 +17,42 @@ app.use(express.json());
 
 const claudeSessionManager = new SessionManager();
 const codexSessionManager = new SessionManager();
+const geminiSessionManager = new SessionManager();
 const claudeAdapter = new ClaudeAdapter(claudeSessionManager);
 const codexAdapter = new CodexAdapter(codexSessionManager);
+const geminiAdapter = new GeminiAdapter(geminiSessionManager);
 
 // Start cleanup intervals
 const cleanupTimer1 = claudeSessionManager.startCleanupInterval();
 const cleanupTimer2 = codexSessionManager.startCleanupInterval();
+const cleanupTimer3 = geminiSessionManager.startCleanupInterval();
 
 function isCodexModel(model?: string): boolean {
   return model === "codex" || (!!model && model.startsWith("codex-"));
 }
 
+function isGeminiModel(model?: string): boolean {
+  return model === "gemini-cli" || (!!model && model.startsWith("gemini-cli-"));
+}
+
+function backendFor(model?: string): string {
+  if (isCodexModel(model)) {
+    return "codex";
+  }
+  if (isGeminiModel(model)) {
+    return "gemini-cli";
+  }
+  return "claude-code";
+}
+
 // Health check
 app.get("/health", (_req, res) => {
   const response: HealthResponse = {
     status: "ok",
     active_sessions:
-      claudeSessionManager.activeCount + codexSessionManager.activeCount,
+      claudeSessionManager.activeCount +
+      codexSessionManager.activeCount +
+      geminiSessionManager.activeCount,
   };
   res.json(response);
 });
@@ -50,13 +75,18 @@ app.post("/chat", async (req, res) => {
     body.working_dir = process.cwd();
   }
 
-  const backend = isCodexModel(body.model) ? "codex" : "claude-code";
+  const backend = backendFor(body.model);
   console.log(
     `[bridge] POST /chat backend=${backend} conversation_id=${body.conversation_id} message_length=${body.message.length}`
   );
 
   try {
-    const adapter = isCodexModel(body.model) ? codexAdapter : claudeAdapter;
+    const adapter =
+      backend === "codex"
+        ? codexAdapter
+        : backend === "gemini-cli"
+          ? geminiAdapter
+          : claudeAdapter;
     const response = await adapter.chat(body);
     res.json(response);
   } catch (err) {
@@ -75,12 +105,47 @@ app.delete("/sessions/:conversationId", (req, res) => {
   const { conversationId } = req.params;
   const deletedClaude = claudeAdapter.deleteSession(conversationId);
   const deletedCodex = codexAdapter.deleteSession(conversationId);
-  res.json({ deleted: deletedClaude || deletedCodex });
+  const deletedGemini = geminiAdapter.deleteSession(conversationId);
+  res.json({ deleted: deletedClaude || deletedCodex || deletedGemini });
+});
+
+// Compact a session (Claude Code only)
+app.post("/sessions/:conversationId/compact", async (req, res) => {
+  const { conversationId } = req.params;
+  const body = req.body as CompactRequest;
+  if (!body.working_dir) {
+    body.working_dir = process.cwd();
+  }
+
+  if (backendFor(body.model) !== "claude-code") {
+    res.status(400).json({
+      result: "Only Claude Code sessions can be compacted",
+      session_id: "",
+      usage: { input_tokens: 0, output_tokens: 0 },
+      is_error: true,
+    } satisfies ChatResponse);
+    return;
+  }
+
+  console.log(
+    `[bridge] POST /sessions/${conversationId}/compact working_dir=${body.working_dir}`
+  );
+  try {
+    res.json(await claudeAdapter.compact(conversationId, body));
+  } catch (err) {
+    console.error("[bridge] unexpected error:", err);
+    res.status(500).json({
+      result: "Internal bridge error",
+      session_id: "",
+      usage: { input_tokens: 0, output_tokens: 0 },
+      is_error: true,
+    } satisfies ChatResponse);
+  }
 });
 
 const server = app.listen(PORT, () => {
   console.log(`[bridge] Bridge server listening on port ${PORT}`);
-  console.log(`[bridge] Backends: claude-code, codex`);
+  console.log(`[bridge] Backends: claude-code, codex, gemini-cli`);
   console.log(`[bridge] Health check: http://localhost:${PORT}/health`);
 });
 
@@ -89,6 +154,7 @@ process.on("SIGINT", () => {
   console.log("[bridge] shutting down...");
   clearInterval(cleanupTimer1);
   clearInterval(cleanupTimer2);
+  clearInterval(cleanupTimer3);
   server.close(() => process.exit(0));
 });
 
@@ -96,5 +162,6 @@ process.on("SIGTERM", () => {
   console.log("[bridge] shutting down...");
   clearInterval(cleanupTimer1);
   clearInterval(cleanupTimer2);
+  clearInterval(cleanupTimer3);
   server.close(() => process.exit(0));
 });
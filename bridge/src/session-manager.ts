@@ -6,18 +6,28 @@ export class SessionManager {
   // Maps Shelley conversation_id → Claude Code session_id
   private sessions: Map<string, SessionInfo> = new Map();
 
-  get(conversationId: string): string | undefined {
+  // get returns the session of a conversation. Sessions belong to the
+  // directory they started in, so a session in another directory is dropped.
+  get(conversationId: string, workingDir: string): string | undefined {
     const info = this.sessions.get(conversationId);
-    if (info) {
-      info.lastActivity = Date.now();
-      return info.sessionId;
+    if (!info) {
+      return undefined;
     }
-    return undefined;
+    if (info.workingDir !== workingDir) {
+      console.log(
+        `[session-manager] working directory of ${conversationId} changed from ${info.workingDir} to ${workingDir}; starting a new session`
+      );
+      this.sessions.delete(conversationId);
+      return undefined;
+    }
+    info.lastActivity = Date.now();
+    return info.sessionId;
   }
 
-  set(conversationId: string, sessionId: string): void {
+  set(conversationId: string, sessionId: string, workingDir: string): void {
     this.sessions.set(conversationId, {
       sessionId,
+      workingDir,
       lastActivity: Date.now(),
     });
   }
@@ -5,6 +5,21 @@ export interface ChatRequest {
   message: string;
   working_dir: string;
   model?: string;
+  // Session to resume, when Shelley keeps sessions itself
+  session_id?: string;
+  // Codex only
+  sandbox?: SandboxMode;
+  reasoning_effort?: ReasoningEffort;
+}
+
+export type SandboxMode = "read-only" | "workspace-write" | "danger-full-access";
+
+export type ReasoningEffort = "minimal" | "low" | "medium" | "high";
+
+export interface CompactRequest {
+  working_dir: string;
+  model?: string;
+  session_id?: string;
 }
 
 export interface ChatResponse {
@@ -13,11 +28,25 @@ export interface ChatResponse {
   usage: {
     input_tokens: number;
     output_tokens: number;
+    // Input tokens read from the backend's prompt cache, not in input_tokens
+    cache_read_input_tokens?: number;
   };
   is_error: boolean;
   compacted?: boolean;
+  // Text and tool activity before the result, in order
+  events?: BridgeEvent[];
 }
 
+export type BridgeEvent =
+  | { type: "text"; text: string }
+  | { type: "tool_use"; id: string; name: string; input: unknown }
+  | {
+      type: "tool_result";
+      tool_use_id: string;
+      output: string;
+      is_error: boolean;
+    };
+
 export interface HealthResponse {
   status: "ok";
   active_sessions: number;
@@ -25,5 +54,6 @@ export interface HealthResponse {
 
 export interface SessionInfo {
   sessionId: string;
+  workingDir: string;
   lastActivity: number;
 }
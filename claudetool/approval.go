@@ -0,0 +1,73 @@
+package claudetool
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+// explainTimeout bounds asking a model to explain a command awaiting approval.
+const explainTimeout = 30 * time.Second
+
+// CommandApproval is a request for the user to approve a risky command before
+// the bash tool runs it.
+type CommandApproval struct {
+	Command string
+	// Class is the command's risk class, one of the bashkit Risk constants.
+	Class      string
+	WorkingDir string
+	// Explanation says what the command will do, as explained by a cheap
+	// model, or is empty if no model could explain it.
+	Explanation string
+}
+
+// ApprovalCallback waits until the user approves or denies a risky command.
+// It returns an error, which is sent to the agent, if the command may not run.
+type ApprovalCallback func(ctx context.Context, req CommandApproval) error
+
+// explainCommand asks a fast, cheap model what command will do when run in
+// wd, so that the user can make an informed decision about it. It returns ""
+// if no model is available or the model fails.
+func (b *BashTool) explainCommand(ctx context.Context, command, wd string) string {
+	if b.LLMProvider == nil {
+		return ""
+	}
+	llmService, err := b.selectBestLLM()
+	if err != nil {
+		slog.DebugContext(ctx, "no LLM service to explain command", "error", err)
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, explainTimeout)
+	defer cancel()
+
+	query := fmt.Sprintf(`Explain what this shell command will do when run in %s. Say in two or three plain sentences what it will change, delete or affect, and whether that can be undone.
+
+Command:
+%s`, wd, command)
+
+	resp, err := llmService.Do(ctx, &llm.Request{
+		Messages: []llm.Message{{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{llm.StringContent(query)},
+		}},
+		System: []llm.SystemContent{{
+			Type: "text",
+			Text: "You are an expert in shell commands, explaining them to a user deciding whether to let them run. Do not suggest alternatives.",
+		}},
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to explain command", "error", err)
+		return ""
+	}
+	var explanation []string
+	for _, c := range resp.Content {
+		if c.Type == llm.ContentTypeText {
+			explanation = append(explanation, c.Text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(explanation, "\n"))
+}
@@ -0,0 +1,65 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool/bashkit"
+)
+
+func TestBashApproveCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "build"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var requests []CommandApproval
+	approve := false
+	bashTool := (&BashTool{
+		WorkingDir:  NewMutableWorkingDir(dir),
+		LLMProvider: &mockLLMProvider{},
+		ApproveCommand: func(ctx context.Context, req CommandApproval) error {
+			requests = append(requests, req)
+			if !approve {
+				return errors.New("the user denied the command")
+			}
+			return nil
+		},
+	}).Tool()
+	run := func(command string) error {
+		input, _ := json.Marshal(bashInput{Command: command})
+		return bashTool.Run(context.Background(), input).Error
+	}
+
+	// Commands that aren't risky run without asking
+	if err := run("ls"); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 0 {
+		t.Fatalf("expected no approval requests, got %+v", requests)
+	}
+
+	// Risky ones wait for approval, with an explanation
+	if err := run("rm -rf build"); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Fatalf("expected the command to be denied, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "build")); err != nil {
+		t.Fatalf("expected the denied command not to run: %v", err)
+	}
+	want := CommandApproval{Command: "rm -rf build", Class: bashkit.RiskDestructive, WorkingDir: dir, Explanation: "test response"}
+	if len(requests) != 1 || requests[0] != want {
+		t.Fatalf("approval requests = %+v, want %+v", requests, want)
+	}
+
+	approve = true
+	if err := run("rm -rf build"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "build")); !os.IsNotExist(err) {
+		t.Fatalf("expected the approved command to run, got %v", err)
+	}
+}
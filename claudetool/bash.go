@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -14,11 +15,12 @@ import (
 	"slices"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"shelley.exe.dev/claudetool/bashkit"
+	"shelley.exe.dev/devcontainer"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/termtext"
 )
 
 // PermissionCallback is a function type for checking if a command is allowed to run
@@ -28,10 +30,15 @@ type PermissionCallback func(command string) error
 type BashTool struct {
 	// CheckPermission is called before running any command, if set
 	CheckPermission PermissionCallback
+	// ApproveCommand, if set, is called before running a command in one of
+	// bashkit's risk classes, with an explanation of what it will do.
+	ApproveCommand ApprovalCallback
 	// EnableJITInstall enables just-in-time tool installation for missing commands
 	EnableJITInstall bool
 	// Timeouts holds the configurable timeout values (uses defaults if nil)
 	Timeouts *Timeouts
+	// Limits bounds the resources of commands; nil for no limits
+	Limits *ResourceLimits
 	// WorkingDir is the shared mutable working directory.
 	WorkingDir *MutableWorkingDir
 	// LLMProvider provides access to LLM services for tool validation
@@ -39,6 +46,14 @@ type BashTool struct {
 	// ConversationID is the ID of the conversation this tool belongs to.
 	// It is exposed to invoked commands via SHELLEY_CONVERSATION_ID.
 	ConversationID string
+	// Env holds extra KEY=VALUE environment entries for invoked commands.
+	Env []string
+	// Devcontainers runs commands in the development container of the
+	// working directory's workspace, if it has one.
+	Devcontainers *devcontainer.Manager
+	// Shell runs the commands; nil for DetectShell's. Commands in
+	// development containers always run with bash.
+	Shell *Shell
 }
 
 const (
@@ -85,12 +100,31 @@ func (t *Timeouts) background() time.Duration {
 func (b *BashTool) Tool() *llm.Tool {
 	return &llm.Tool{
 		Name:        bashName,
-		Description: strings.TrimSpace(bashDescription),
+		Description: strings.TrimSpace(bashDescription + shellDescriptions[b.shell().Name]),
 		InputSchema: llm.MustSchema(bashInputSchema),
 		Run:         b.Run,
+		ReadOnly:    bashReadOnly,
 	}
 }
 
+// bashReadOnly reports whether a bash call runs in the foreground and only
+// runs commands known to be read-only.
+func bashReadOnly(input json.RawMessage) bool {
+	var req bashInput
+	if err := json.Unmarshal(input, &req); err != nil {
+		return false
+	}
+	return !req.Background && bashkit.CheckReadOnly(req.Command) == nil
+}
+
+// shell returns the shell commands run with outside development containers.
+func (b *BashTool) shell() Shell {
+	if b.Shell != nil {
+		return *b.Shell
+	}
+	return defaultShell()
+}
+
 // getWorkingDir returns the current working directory.
 func (b *BashTool) getWorkingDir() string {
 	return b.WorkingDir.Get()
@@ -148,6 +182,10 @@ type bashInput struct {
 // BashDisplayData is the display data sent to the UI for bash tool results.
 type BashDisplayData struct {
 	WorkingDir string `json:"workingDir"`
+	// OutputHTML is the colored output of the command, if it had colors.
+	OutputHTML string `json:"outputHtml,omitempty"`
+	// LimitExceeded is the resource limit the command hit, if any.
+	LimitExceeded *LimitExceeded `json:"limitExceeded,omitempty"`
 }
 
 type BackgroundResult struct {
@@ -156,8 +194,8 @@ type BackgroundResult struct {
 }
 
 func (r *BackgroundResult) XMLish() string {
-	return fmt.Sprintf("<pid>%d</pid>\n<output_file>%s</output_file>\n<reminder>To stop the process: `kill -9 -%d`</reminder>\n",
-		r.PID, r.OutFile, r.PID)
+	return fmt.Sprintf("<pid>%d</pid>\n<output_file>%s</output_file>\n<reminder>To stop the process: `%s`</reminder>\n",
+		r.PID, r.OutFile, killCommand(r.PID))
 }
 
 func (i *bashInput) timeout(t *Timeouts) time.Duration {
@@ -199,8 +237,22 @@ func (b *BashTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 		}
 	}
 
+	if b.ApproveCommand != nil {
+		if class := bashkit.RiskClass(req.Command); class != "" {
+			approval := CommandApproval{
+				Command:     req.Command,
+				Class:       class,
+				WorkingDir:  wd,
+				Explanation: b.explainCommand(ctx, req.Command, wd),
+			}
+			if err := b.ApproveCommand(ctx, approval); err != nil {
+				return llm.ErrorToolOut(err)
+			}
+		}
+	}
+
 	// Check for missing tools and try to install them if needed, best effort only
-	if b.EnableJITInstall {
+	if b.EnableJITInstall && b.shell().Name == ShellBash {
 		err := b.checkAndInstallMissingTools(ctx, req.Command)
 		if err != nil {
 			slog.DebugContext(ctx, "failed to auto-install missing tools", "error", err)
@@ -224,9 +276,11 @@ func (b *BashTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 	}
 
 	// For foreground commands, use executeBash
-	out, execErr := b.executeBash(ctx, req, timeout)
+	out, html, execErr := b.executeBash(ctx, req, timeout)
+	display.OutputHTML = html
+	errors.As(execErr, &display.LimitExceeded)
 	if execErr != nil {
-		return llm.ErrorToolOut(execErr)
+		return llm.ToolOut{Error: execErr, Display: display}
 	}
 	return llm.ToolOut{LLMContent: llm.TextContent(out), Display: display}
 }
@@ -238,14 +292,75 @@ const (
 	maxLineLength        = 200 // truncate displayed lines to this length
 )
 
-func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.Writer) *exec.Cmd {
-	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+// makeBashCommand returns the command running command in the working
+// directory, with extraEnv added to its environment. In a workspace with a
+// development container, the command runs in the container.
+func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.Writer, extraEnv ...string) (*bashCommand, error) {
 	// Use shared WorkingDir if available, then context, then Pwd fallback
-	cmd.Dir = b.getWorkingDir()
+	dir := b.getWorkingDir()
+
+	// The environment set for the command, on top of Shelley's own
+	cmdEnv := []string{
+		"SKETCH=1",          // signal that this has been run by Sketch, sometimes useful for scripts
+		"EDITOR=/bin/false", // interactive editors won't work
+	}
+	if b.ConversationID != "" {
+		cmdEnv = append(cmdEnv, "SHELLEY_CONVERSATION_ID="+b.ConversationID)
+	}
+	cmdEnv = append(cmdEnv, b.Env...)
+	cmdEnv = append(cmdEnv, extraEnv...)
+
+	var container *devcontainer.Container
+	if b.Devcontainers != nil {
+		var err error
+		container, err = b.Devcontainers.Container(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	shell := b.shell()
+	if container != nil {
+		shell = Shell{Name: ShellBash, Path: "bash"}
+	}
+	bc := &bashCommand{limits: b.Limits}
+	if b.Limits != nil {
+		if b.Limits.Output > 0 {
+			bc.output = &limitWriter{w: out, limit: b.Limits.Output, onExceed: bc.kill}
+			out = bc.output
+		}
+		var cgroup string
+		if b.Limits.Cgroup != "" && container == nil {
+			cg, err := b.Limits.newCgroup()
+			if err != nil {
+				slog.WarnContext(ctx, "failed to create cgroup for command, limiting it with rlimits only", "error", err)
+			} else {
+				bc.cgroup = cg
+				cgroup = cg.dir
+			}
+		}
+		if shell.Name == ShellBash {
+			command = b.Limits.shellPrefix(cgroup) + command
+		}
+	}
+
+	var cmd *exec.Cmd
+	if container != nil {
+		// Only the command's own environment is forwarded, not Shelley's
+		names := make([]string, 0, len(cmdEnv))
+		for _, kv := range cmdEnv {
+			name, _, _ := strings.Cut(kv, "=")
+			names = append(names, name)
+		}
+		cmd = container.Command(ctx, dir, command, names)
+	} else {
+		cmd = exec.CommandContext(ctx, shell.Path, shell.args(command)...)
+		cmd.Dir = dir
+	}
 	cmd.Stdin = nil
 	cmd.Stdout = out
 	cmd.Stderr = out
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // set up for killing the process group
+	cmd.SysProcAttr = shellSysProcAttr(shell, command) // set up for killing the process group
 	cmd.Cancel = func() error {
 		if cmd.Process == nil {
 			// Process hasn't started yet.
@@ -253,7 +368,7 @@ func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.W
 			// but it is possible in theory, and it doesn't hurt to handle it gracefully.
 			return nil
 		}
-		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) // kill entire process group
+		return killProcessGroup(cmd.Process.Pid) // kill entire process group
 	}
 	cmd.WaitDelay = 15 * time.Second // prevent indefinite hangs when child processes keep pipes open
 	// Remove SKETCH_MODEL_URL, SKETCH_PUB_KEY, SKETCH_MODEL_API_KEY,
@@ -262,16 +377,54 @@ func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.W
 	env := slices.DeleteFunc(os.Environ(), func(s string) bool {
 		return strings.HasPrefix(s, "SKETCH_") && s != "SKETCH_PROXY_ID"
 	})
-	env = append(env, "SKETCH=1")          // signal that this has been run by Sketch, sometimes useful for scripts
-	env = append(env, "EDITOR=/bin/false") // interactive editors won't work
-	if b.ConversationID != "" {
-		env = append(env, "SHELLEY_CONVERSATION_ID="+b.ConversationID)
+	cmd.Env = append(env, cmdEnv...)
+	bc.Cmd = cmd
+	return bc, nil
+}
+
+// bashCommand is a bash command with the resource limits it runs under.
+type bashCommand struct {
+	*exec.Cmd
+	limits *ResourceLimits // nil if the command is not limited
+	cgroup *commandCgroup  // nil if the command doesn't run in a cgroup of its own
+	output *limitWriter    // nil if the output is not limited
+}
+
+// Start starts the command, removing its cgroup if it fails to start.
+func (c *bashCommand) Start() error {
+	err := c.Cmd.Start()
+	if err != nil && c.cgroup != nil {
+		c.cgroup.remove()
 	}
-	cmd.Env = env
-	return cmd
+	return err
 }
 
-func cmdWait(cmd *exec.Cmd) error {
+// Wait waits for the command to exit and removes its cgroup. If the command
+// hit a resource limit, the error is a *LimitExceeded.
+func (c *bashCommand) Wait() error {
+	err := c.Cmd.Wait()
+	if c.limits == nil {
+		return err
+	}
+	if c.output != nil && c.output.hitLimit() {
+		err = &LimitExceeded{Limit: LimitOutput, Value: c.limits.Output, err: err}
+	} else if exceeded := c.limits.exceeded(err, c.cgroup); exceeded != nil {
+		err = exceeded
+	}
+	if c.cgroup != nil {
+		c.cgroup.remove()
+	}
+	return err
+}
+
+// kill kills the command's process group.
+func (c *bashCommand) kill() {
+	if c.Process != nil {
+		killProcessGroup(c.Process.Pid)
+	}
+}
+
+func cmdWait(cmd *bashCommand) error {
 	err := cmd.Wait()
 	// We used to kill the process group here, but it's not clear that
 	// this is correct in the case of self-daemonizing processes,
@@ -280,35 +433,52 @@ func cmdWait(cmd *exec.Cmd) error {
 	return err
 }
 
-func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.Duration) (string, error) {
+// executeBash runs a foreground command and returns its output for the
+// agent, with escape sequences stripped and progress bars collapsed. If the
+// output was colored, it also returns the output as HTML for display.
+func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.Duration) (out, html string, err error) {
 	execCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	output := new(bytes.Buffer)
-	cmd := b.makeBashCommand(execCtx, req.Command, output)
 	// TODO: maybe detect simple interactive git rebase commands and auto-background them?
 	// Would need to hint to the agent what is happening.
 	// We might also be able to do this for other simple interactive commands that use EDITOR.
-	cmd.Env = append(cmd.Env, `GIT_SEQUENCE_EDITOR=echo "To do an interactive rebase, run it as a background task and check the output file." && exit 1`)
+	cmd, err := b.makeBashCommand(execCtx, req.Command, output, `GIT_SEQUENCE_EDITOR=echo "To do an interactive rebase, run it as a background task and check the output file." && exit 1`)
+	if err != nil {
+		return "", "", err
+	}
 	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("command failed: %w", err)
+		return "", "", fmt.Errorf("command failed: %w", err)
 	}
 
-	err := cmdWait(cmd)
+	err = cmdWait(cmd)
 
-	out, formatErr := formatForegroundBashOutput(output.String())
+	raw := output.String()
+	plain := termtext.Strip(raw)
+	out, formatErr := formatForegroundBashOutput(plain)
 	if formatErr != nil {
-		return "", formatErr
+		return "", "", formatErr
+	}
+	// Output too large to show is summarized without colors
+	colored := out == plain && termtext.HasEscapes(raw)
+	toHTML := func(header string) string {
+		if !colored {
+			return ""
+		}
+		return termtext.HTML(header + raw)
 	}
 
 	if execCtx.Err() == context.DeadlineExceeded {
-		return "", fmt.Errorf("[command timed out after %s, showing output until timeout]\n%s", timeout, out)
+		header := fmt.Sprintf("[command timed out after %s, showing output until timeout]\n", timeout)
+		return "", toHTML(header), errors.New(header + out)
 	}
 	if err != nil {
-		return "", fmt.Errorf("[command failed: %w]\n%s", err, out)
+		header := fmt.Sprintf("[command failed: %s]\n", err)
+		return "", toHTML(header), fmt.Errorf("[command failed: %w]\n%s", err, out)
 	}
 
-	return out, nil
+	return out, toHTML(""), nil
 }
 
 // formatForegroundBashOutput formats the output of a foreground bash command for display to the agent.
@@ -399,10 +569,11 @@ func (b *BashTool) executeBackgroundBash(ctx context.Context, req bashInput, tim
 	}
 
 	execCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout) // detach from tool use context
-	cmd := b.makeBashCommand(execCtx, req.Command, out)
-	cmd.Env = append(cmd.Env, `GIT_SEQUENCE_EDITOR=python3 -c "import os, sys, signal, threading; print(f\"Send USR1 to pid {os.getpid()} after editing {sys.argv[1]}\", flush=True); signal.signal(signal.SIGUSR1, lambda *_: sys.exit(0)); threading.Event().wait()"`)
-
-	if err := cmd.Start(); err != nil {
+	cmd, err := b.makeBashCommand(execCtx, req.Command, out, `GIT_SEQUENCE_EDITOR=python3 -c "import os, sys, signal, threading; print(f\"Send USR1 to pid {os.getpid()} after editing {sys.argv[1]}\", flush=True); signal.signal(signal.SIGUSR1, lambda *_: sys.exit(0)); threading.Event().wait()"`)
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
 		cancel()
 		out.Close()
 		os.RemoveAll(tmpDir) // clean up temp dir -- didn't start means we don't need the output
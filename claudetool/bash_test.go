@@ -195,7 +195,7 @@ func TestExecuteBash(t *testing.T) {
 			Command: "echo 'Success'",
 		}
 
-		output, err := bashTool.executeBash(ctx, req, 5*time.Second)
+		output, _, err := bashTool.executeBash(ctx, req, 5*time.Second)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -212,7 +212,7 @@ func TestExecuteBash(t *testing.T) {
 			Command: "echo $SKETCH",
 		}
 
-		output, err := bashTool.executeBash(ctx, req, 5*time.Second)
+		output, _, err := bashTool.executeBash(ctx, req, 5*time.Second)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -233,7 +233,7 @@ func TestExecuteBash(t *testing.T) {
 			Command: "echo $SHELLEY_CONVERSATION_ID",
 		}
 
-		output, err := bashWithConvID.executeBash(ctx, req, 5*time.Second)
+		output, _, err := bashWithConvID.executeBash(ctx, req, 5*time.Second)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -250,7 +250,7 @@ func TestExecuteBash(t *testing.T) {
 			Command: "echo \"conv_id:$SHELLEY_CONVERSATION_ID:\"",
 		}
 
-		output, err := bashTool.executeBash(ctx, req, 5*time.Second)
+		output, _, err := bashTool.executeBash(ctx, req, 5*time.Second)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -268,7 +268,7 @@ func TestExecuteBash(t *testing.T) {
 			Command: "echo 'Error message' >&2 && echo 'Success'",
 		}
 
-		output, err := bashTool.executeBash(ctx, req, 5*time.Second)
+		output, _, err := bashTool.executeBash(ctx, req, 5*time.Second)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -285,7 +285,7 @@ func TestExecuteBash(t *testing.T) {
 			Command: "echo 'Error message' >&2 && exit 1",
 		}
 
-		_, err := bashTool.executeBash(ctx, req, 5*time.Second)
+		_, _, err := bashTool.executeBash(ctx, req, 5*time.Second)
 		if err == nil {
 			t.Errorf("Expected error for failed command, got none")
 		} else if !strings.Contains(err.Error(), "Error message") {
@@ -300,7 +300,7 @@ func TestExecuteBash(t *testing.T) {
 		}
 
 		start := time.Now()
-		_, err := bashTool.executeBash(ctx, req, 100*time.Millisecond)
+		_, _, err := bashTool.executeBash(ctx, req, 100*time.Millisecond)
 		elapsed := time.Since(start)
 
 		// Command should time out after ~100ms, not wait for full 1 second
@@ -314,6 +314,22 @@ func TestExecuteBash(t *testing.T) {
 			t.Errorf("Expected timeout error, got: %v", err)
 		}
 	})
+
+	// Test colored output and progress bars
+	t.Run("Terminal Escapes", func(t *testing.T) {
+		req := bashInput{
+			Command: `printf '10%%\r50%%\r100%%\n\033[31mFAIL\033[0m <x>\n'; exit 1`,
+		}
+
+		_, html, err := bashTool.executeBash(ctx, req, 5*time.Second)
+		if err == nil || !strings.HasSuffix(err.Error(), "]\n100%\nFAIL <x>\n") {
+			t.Errorf("Expected stripped output in error, got: %v", err)
+		}
+		want := "[command failed: exit status 1]\n100%\n<span class=\"ansi-fg-red\">FAIL</span> &lt;x&gt;\n"
+		if html != want {
+			t.Errorf("Expected HTML %q, got %q", want, html)
+		}
+	})
 }
 
 func TestBackgroundBash(t *testing.T) {
@@ -721,3 +737,19 @@ func waitForProcessDeath(t *testing.T, pid int) {
 		}
 	}
 }
+
+func TestBashReadOnly(t *testing.T) {
+	tests := map[string]bool{
+		`{"command":"ls -la && grep -rn foo ."}`: true,
+		`{"command":"git status"}`:               true,
+		`{"command":"rm -rf build"}`:             false,
+		`{"command":"echo hi > out.txt"}`:        false,
+		`{"command":"ls","background":true}`:     false,
+		`not json`:                               false,
+	}
+	for input, want := range tests {
+		if got := bashReadOnly(json.RawMessage(input)); got != want {
+			t.Errorf("bashReadOnly(%s) = %v, want %v", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,597 @@
+package bashkit
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// readOnlyCommands are commands that only read files, mapped to a check of
+// their arguments, which is nil when any arguments are fine.
+var readOnlyCommands = map[string]func(args []string) error{
+	"ls": nil, "cat": nil, "head": nil, "tail": nil, "wc": nil, "nl": nil,
+	"grep": nil, "egrep": nil, "fgrep": nil, "ag": nil,
+	"file": nil, "stat": nil, "du": nil, "df": nil,
+	"pwd": nil, "echo": nil, "printf": nil, "which": nil, "type": nil,
+	"cut": nil, "tr": nil, "jq": nil, "column": nil,
+	"diff": nil, "cmp": nil, "comm": nil, "basename": nil, "dirname": nil,
+	"realpath": nil, "readlink": nil, "true": nil, "false": nil,
+	"test": nil, "[": nil, "cd": nil, "id": nil, "whoami": nil, "uname": nil,
+	"hexdump": nil, "od": nil, "strings": nil,
+	"md5sum": nil, "sha1sum": nil, "sha256sum": nil,
+	"find": denyArgs("-delete", "-exec", "-execdir", "-ok", "-okdir", "-fprint", "-fprint0", "-fprintf", "-fls"),
+	"sed":  checkSed,
+	"awk":  checkAwk,
+	"sort": denyFlags("o", "--output", "--compress-program"),
+	"tree": denyFlags("o", "--output"),
+	"rg":   denyFlags("", "--pre"),
+	"fd":   denyFlags("xX", "--exec", "--exec-batch"),
+	"date": denyArgPrefixes("-s", "--set"),
+	// Naming a host, directly or from a file, sets it
+	"hostname": checkHostname,
+	// A second file argument is written to
+	"uniq": maxOperands(1),
+	"xxd":  maxOperands(1),
+	"git": allowSubcommands(map[string]func(args []string) error{
+		"status": nil, "log": checkGitDiff, "diff": checkGitDiff, "show": checkGitDiff, "grep": denyFlags("O", "--open-files-in-pager"),
+		"ls-files": nil, "ls-tree": nil, "blame": nil, "rev-parse": nil,
+		"describe": nil, "shortlog": nil, "cat-file": nil, "rev-list": nil,
+		"merge-base": nil, "reflog": allowOnly("show"),
+		"branch": onlyFlags, "tag": onlyFlags, "remote": onlyFlags,
+		"config": allowFlags("--get", "--get-all", "--get-regexp", "--list", "-l"),
+		"stash":  allowOnly("list", "show"),
+	}),
+	"go": allowSubcommands(map[string]func(args []string) error{
+		"list": denyArgPrefixes("-toolexec", "--toolexec"), "doc": nil, "version": nil,
+		"env": denyArgPrefixes("-w", "--w", "-u", "--u"), "vet": denyArgPrefixes("-vettool", "--vettool", "-toolexec", "--toolexec"),
+	}),
+}
+
+// CheckReadOnly returns an error if bashScript runs a command that is not
+// known to be read-only, or redirects output to a file.
+// Like Check, it DOES NOT PROVIDE SECURITY: it keeps a cooperative model
+// from modifying files while it is meant to only explore.
+func CheckReadOnly(bashScript string) error {
+	file, err := syntax.NewParser().Parse(strings.NewReader(bashScript), "")
+	if err != nil {
+		return fmt.Errorf("could not parse command: %w", err)
+	}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *syntax.Redirect:
+			err = checkReadOnlyRedirect(n)
+		case *syntax.CallExpr:
+			err = checkReadOnlyCall(n)
+		}
+		return err == nil
+	})
+	return err
+}
+
+func checkReadOnlyRedirect(r *syntax.Redirect) error {
+	switch r.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrAll, syntax.AppAll, syntax.ClbOut, syntax.RdrInOut:
+		if r.Word != nil && r.Word.Lit() == "/dev/null" {
+			return nil
+		}
+		return fmt.Errorf("redirecting output to a file is not allowed in read-only mode")
+	}
+	return nil
+}
+
+func checkReadOnlyCall(call *syntax.CallExpr) error {
+	if len(call.Args) == 0 {
+		// Variable assignments only
+		return nil
+	}
+	name, ok := staticWord(call.Args[0])
+	if !ok || name == "" {
+		return fmt.Errorf("commands with computed names are not allowed in read-only mode")
+	}
+	name = path.Base(name)
+	check, ok := readOnlyCommands[name]
+	if !ok {
+		return fmt.Errorf("%s is not allowed in read-only mode; only commands that read files can run", name)
+	}
+	if check == nil {
+		// No argument can make the command write, so expansions are fine
+		return nil
+	}
+	// The arguments must be known to be checked, so "$FLAG" can't hide -i
+	args := make([]string, len(call.Args)-1)
+	for i, arg := range call.Args[1:] {
+		if args[i], ok = staticWord(arg); !ok {
+			return fmt.Errorf("%s: arguments with expansions are not allowed in read-only mode", name)
+		}
+	}
+	if err := check(args); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// staticWord returns the value of a word without expansions, with its
+// quotes and escapes removed, and whether it has none.
+func staticWord(word *syntax.Word) (string, bool) {
+	var b strings.Builder
+	for _, part := range word.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			b.WriteString(unescape(p.Value, func(byte) bool { return true }))
+		case *syntax.SglQuoted:
+			if p.Dollar {
+				// $'...' has escapes of its own
+				return "", false
+			}
+			b.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			if p.Dollar {
+				return "", false
+			}
+			for _, qp := range p.Parts {
+				lit, ok := qp.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				b.WriteString(unescape(lit.Value, func(c byte) bool { return strings.IndexByte("$`\"\\\n", c) >= 0 }))
+			}
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// unescape removes the backslashes before the characters they escape.
+func unescape(s string, escapes func(byte) bool) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && escapes(s[i+1]) {
+			i++
+			if s[i] == '\n' {
+				// A line continuation
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// denyArgs rejects any of the given arguments.
+func denyArgs(denied ...string) func([]string) error {
+	return func(args []string) error {
+		for _, arg := range args {
+			if slices.Contains(denied, arg) {
+				return fmt.Errorf("%s is not allowed in read-only mode", arg)
+			}
+		}
+		return nil
+	}
+}
+
+// denyArgPrefixes rejects arguments starting with any of the given prefixes,
+// which catches attached values such as sed -i.bak.
+func denyArgPrefixes(denied ...string) func([]string) error {
+	return func(args []string) error {
+		for _, arg := range args {
+			for _, d := range denied {
+				if strings.HasPrefix(arg, d) {
+					return fmt.Errorf("%s is not allowed in read-only mode", arg)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// denyFlags rejects the given long options, alone or with a value after =,
+// and short options whose letters are in short, alone or combined with
+// others as in sort -uo.
+func denyFlags(short string, long ...string) func([]string) error {
+	return func(args []string) error {
+		for _, arg := range args {
+			if arg == "--" {
+				return nil
+			}
+			if strings.HasPrefix(arg, "--") {
+				name, _, _ := strings.Cut(arg, "=")
+				if slices.Contains(long, name) {
+					return fmt.Errorf("%s is not allowed in read-only mode", name)
+				}
+				continue
+			}
+			if strings.HasPrefix(arg, "-") {
+				if i := strings.IndexAny(arg[1:], short); short != "" && i >= 0 {
+					return fmt.Errorf("-%c is not allowed in read-only mode", arg[1+i])
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// checkGitDiff refuses writing the diff to a file and running the external
+// diff and text conversion commands configured for the repository.
+var checkGitDiff = denyFlags("", "--output", "--ext-diff", "--textconv")
+
+// checkHostname allows printing the host name but not setting it.
+func checkHostname(args []string) error {
+	if err := denyFlags("Fb", "--file", "--boot")(args); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("setting the host name is not allowed in read-only mode")
+		}
+	}
+	return nil
+}
+
+// maxOperands allows at most n arguments that aren't options.
+func maxOperands(n int) func([]string) error {
+	return func(args []string) error {
+		operands := 0
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-") || arg == "-" {
+				operands++
+			}
+		}
+		if operands > n {
+			return fmt.Errorf("writing to an output file is not allowed in read-only mode")
+		}
+		return nil
+	}
+}
+
+// allowSubcommands allows the given subcommands, skipping leading flags such
+// as git -C dir. Setting configuration with git -c is refused, since it can
+// make git run commands.
+func allowSubcommands(subcommands map[string]func([]string) error) func([]string) error {
+	return func(args []string) error {
+		for i := 0; i < len(args); i++ {
+			arg := args[i]
+			if strings.HasPrefix(arg, "-") {
+				if arg == "-c" || strings.HasPrefix(arg, "--config-env") || strings.HasPrefix(arg, "--exec-path") {
+					return fmt.Errorf("%s is not allowed in read-only mode", arg)
+				}
+				// Flags taking a separate value
+				if arg == "-C" {
+					i++
+				}
+				continue
+			}
+			check, ok := subcommands[arg]
+			if !ok {
+				return fmt.Errorf("%s is not allowed in read-only mode", arg)
+			}
+			if check == nil {
+				return nil
+			}
+			return check(args[i+1:])
+		}
+		return nil
+	}
+}
+
+// allowOnly allows no arguments or a first argument from the given list.
+func allowOnly(allowed ...string) func([]string) error {
+	return func(args []string) error {
+		if len(args) == 0 || slices.Contains(allowed, args[0]) {
+			return nil
+		}
+		return fmt.Errorf("%s is not allowed in read-only mode", args[0])
+	}
+}
+
+// onlyFlags allows only flags, so that e.g. git branch lists branches but does
+// not create one.
+func onlyFlags(args []string) error {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("only listing is allowed in read-only mode")
+		}
+		if slices.Contains([]string{"-d", "-D", "-m", "-M", "-c", "-C", "-f", "--delete", "--move", "--copy", "--force", "--set-upstream-to", "-u"}, arg) {
+			return fmt.Errorf("%s is not allowed in read-only mode", arg)
+		}
+	}
+	return nil
+}
+
+// allowFlags requires one of the given flags.
+func allowFlags(allowed ...string) func([]string) error {
+	return func(args []string) error {
+		for _, arg := range args {
+			if slices.Contains(allowed, arg) {
+				return nil
+			}
+		}
+		return fmt.Errorf("only reading configuration is allowed in read-only mode")
+	}
+}
+
+// checkSed allows sed scripts without the commands that write files or run
+// them: w, W, e and the w and e flags of s. Script files and editing in
+// place are refused.
+func checkSed(args []string) error {
+	var scripts []string
+	explicit := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if !explicit && len(scripts) == 0 && i+1 < len(args) {
+				scripts = append(scripts, args[i+1])
+			}
+			i = len(args)
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg, "=")
+			switch name {
+			case "--in-place", "--file":
+				return fmt.Errorf("%s is not allowed in read-only mode", name)
+			case "--expression":
+				if !hasValue && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				scripts = append(scripts, value)
+				explicit = true
+			case "--line-length":
+				if !hasValue {
+					i++
+				}
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+		flags:
+			for j := 1; j < len(arg); j++ {
+				switch arg[j] {
+				case 'i', 'f':
+					return fmt.Errorf("-%c is not allowed in read-only mode", arg[j])
+				case 'e', 'l':
+					// The rest of the argument or the next one is the value
+					value := arg[j+1:]
+					if value == "" && i+1 < len(args) {
+						i++
+						value = args[i]
+					}
+					if arg[j] == 'e' {
+						scripts = append(scripts, value)
+						explicit = true
+					}
+					break flags
+				}
+			}
+		case !explicit && len(scripts) == 0:
+			scripts = append(scripts, arg)
+		}
+	}
+	for _, script := range scripts {
+		if err := checkSedScript(script); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSedScript rejects a sed script with commands that write files or run
+// commands, or that it can't make sense of.
+func checkSedScript(script string) error {
+	p := &sedParser{s: script}
+	for {
+		p.skip(" \t\n;")
+		if p.done() {
+			return nil
+		}
+		switch p.peek() {
+		case '#':
+			p.until("\n")
+			continue
+		case '}':
+			p.i++
+			continue
+		}
+		if p.address() {
+			p.skip(" \t")
+			if p.peek() == ',' {
+				p.i++
+				p.skip(" \t")
+				if p.peek() == '+' || p.peek() == '~' {
+					p.i++
+				}
+				if !p.address() {
+					return errSedScript
+				}
+			}
+		}
+		p.skip(" \t")
+		if p.peek() == '!' {
+			p.i++
+			p.skip(" \t")
+		}
+		if p.done() {
+			return errSedScript
+		}
+		cmd := p.s[p.i]
+		p.i++
+		switch cmd {
+		case '{', '=', 'd', 'D', 'g', 'G', 'h', 'H', 'n', 'N', 'p', 'P', 'x', 'z', 'F':
+		case 'q', 'Q', 'l', 'L':
+			p.skip(" \t")
+			p.skipDigits()
+		case 'b', 't', 'T', ':', 'r', 'R', 'v':
+			// A label or file name to the end of the command; r reads
+			p.until(";\n")
+		case 'a', 'i', 'c':
+			// Text to the end of the line, continued by a trailing backslash
+			for !p.done() && p.peek() != '\n' {
+				if p.peek() == '\\' {
+					p.i++
+				}
+				p.i++
+			}
+		case 's':
+			if !p.delimited(2) {
+				return errSedScript
+			}
+			for !p.done() && strings.IndexByte(" \t\n;}", p.peek()) < 0 {
+				if flag := p.peek(); flag == 'w' || flag == 'e' {
+					return fmt.Errorf("the %c flag of s is not allowed in read-only mode", flag)
+				}
+				p.i++
+			}
+		case 'y':
+			if !p.delimited(2) {
+				return errSedScript
+			}
+		case 'w', 'W', 'e':
+			return fmt.Errorf("the %c command of sed is not allowed in read-only mode", cmd)
+		default:
+			return errSedScript
+		}
+	}
+}
+
+var errSedScript = errors.New("only sed scripts that can be checked are allowed in read-only mode")
+
+// sedParser walks through a sed script.
+type sedParser struct {
+	s string
+	i int
+}
+
+func (p *sedParser) done() bool { return p.i >= len(p.s) }
+
+func (p *sedParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+// skip skips the characters in chars.
+func (p *sedParser) skip(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// until skips to the first of the characters in chars.
+func (p *sedParser) until(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.s[p.i]) < 0 {
+		p.i++
+	}
+}
+
+func (p *sedParser) skipDigits() {
+	for !p.done() && '0' <= p.peek() && p.peek() <= '9' {
+		p.i++
+	}
+}
+
+// address skips an address, if there is one: a line number, $, or a
+// regular expression with its flags.
+func (p *sedParser) address() bool {
+	switch c := p.peek(); {
+	case '0' <= c && c <= '9':
+		p.skipDigits()
+		if p.peek() == '~' {
+			p.i++
+			p.skipDigits()
+		}
+		return true
+	case c == '$':
+		p.i++
+		return true
+	case c == '/':
+		if !p.delimited(1) {
+			return false
+		}
+	case c == '\\':
+		p.i++
+		if !p.delimited(1) {
+			return false
+		}
+	default:
+		return false
+	}
+	p.skip("IM")
+	return true
+}
+
+// delimited skips n parts ended by the delimiter at the current position,
+// as in s/a/b/, and reports whether they were all there.
+func (p *sedParser) delimited(n int) bool {
+	if p.done() || p.peek() == '\n' || p.peek() == '\\' {
+		return false
+	}
+	delim := p.s[p.i]
+	p.i++
+	for ; n > 0; n-- {
+		for !p.done() && p.s[p.i] != delim {
+			if p.s[p.i] == '\\' {
+				p.i++
+			}
+			p.i++
+		}
+		if p.done() {
+			return false
+		}
+		p.i++
+	}
+	return true
+}
+
+// awkUnsafe matches awk programs that run commands or write files: system(),
+// pipes to and from commands, which || is not, and print redirected to a
+// file.
+var awkUnsafe = regexp.MustCompile(`system|(^|[^|])\|([^|]|$)|printf?[^;{}\n]*>`)
+
+// checkAwk allows awk programs that don't run commands or write files.
+// Program files, extensions and gawk's in-place editing are refused.
+func checkAwk(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if i+1 < len(args) {
+				return checkAwkProgram(args[i+1])
+			}
+			return nil
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return checkAwkProgram(arg)
+		}
+		name, _, hasValue := strings.Cut(arg, "=")
+		switch {
+		case slices.Contains([]string{"--file", "--include", "--exec", "--load"}, name):
+			return fmt.Errorf("%s is not allowed in read-only mode", name)
+		case strings.HasPrefix(arg, "--"):
+			if !hasValue && (name == "--assign" || name == "--field-separator") {
+				i++
+			}
+		case strings.ContainsAny(arg[1:2], "fiEl"):
+			return fmt.Errorf("-%s is not allowed in read-only mode", arg[1:2])
+		case arg == "-F" || arg == "-v":
+			// Options taking a separate value
+			i++
+		}
+	}
+	return nil
+}
+
+func checkAwkProgram(program string) error {
+	if awkUnsafe.MatchString(program) {
+		return fmt.Errorf("awk programs that run commands or write files are not allowed in read-only mode")
+	}
+	return nil
+}
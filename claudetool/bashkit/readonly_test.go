@@ -0,0 +1,145 @@
+package bashkit
+
+import "testing"
+
+func TestCheckReadOnly(t *testing.T) {
+	tests := []struct {
+		script  string
+		wantErr bool
+	}{
+		{"ls -la", false},
+		{"cat main.go | grep -n func | head -20", false},
+		{"cd sub && rg TODO 2>&1", false},
+		{"find . -name '*.go' -not -path './vendor/*'", false},
+		{"grep -r foo . > /dev/null && echo found", false},
+		{"git -C repo log --oneline -5", false},
+		{"git status; git diff HEAD~1", false},
+		{"git branch -a", false},
+		{"git config --get user.name", false},
+		{"/usr/bin/ls", false},
+		{"sed -n 1,20p file.go", false},
+		{"go list ./...", false},
+		{"X=1", false},
+		{"for f in *.go; do wc -l $f; done", false},
+		{"echo $(cat VERSION)", false},
+
+		{"rm -rf build", true},
+		{"echo hi > out.txt", true},
+		{"cat a >> b", true},
+		{"ls &> log", true},
+		{"touch new.go", true},
+		{"sed -i s/a/b/ file.go", true},
+		{"sed -i.bak s/a/b/ file.go", true},
+		{"sort -o out.txt in.txt", true},
+		{"find . -name '*.tmp' -delete", true},
+		{"find . -exec rm {} \\;", true},
+		{"git commit -m wip", true},
+		{"git checkout main", true},
+		{"git branch new-feature", true},
+		{"git branch -D old", true},
+		{"git stash", false},
+		{"git stash pop", true},
+		{"git config user.name me", true},
+		{"go build ./...", true},
+		{"go env -w GOFLAGS=-mod=mod", true},
+		{"ls && python3 script.py", true},
+		{"echo $(make)", true},
+		{"$CMD", true},
+		{"echo 'unterminated", true},
+	}
+	for _, tt := range tests {
+		err := CheckReadOnly(tt.script)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckReadOnly(%q) error = %v, wantErr %v", tt.script, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckReadOnlyBypasses(t *testing.T) {
+	tests := []struct {
+		script  string
+		wantErr bool
+	}{
+		// Combined short flags
+		{"sed -Ei s/a/b/ file.go", true},
+		{"sed -ni.bak p file.go", true},
+		{"sort -uo out.txt in.txt", true},
+		{"tree -ao out.txt", true},
+		{"sort -un in.txt", false},
+		{"sed -nE '/^func/p' file.go", false},
+
+		// Quoted, escaped and computed arguments
+		{`sed "-i" s/a/b/ file.go`, true},
+		{`sed '-i' s/a/b/ file.go`, true},
+		{`sed \-i s/a/b/ file.go`, true},
+		{`find . "-delete"`, true},
+		{`find . -name "*.go" '-delete'`, true},
+		{"sed $FLAGS s/a/b/ file.go", true},
+		{"find . $(echo -delete)", true},
+		{`sed $'-i' s/a/b/ file.go`, true},
+		{`find . -name "*.go"`, false},
+		{`grep -n "$PATTERN" main.go`, false},
+
+		// awk running commands or writing files
+		{`awk 'BEGIN{system("rm -rf x")}'`, true},
+		{`awk '{print > "out"}' file`, true},
+		{`awk '{printf "%s", $1 >> "out"}' file`, true},
+		{`awk '{print | "sh"}' file`, true},
+		{`awk 'BEGIN{"date" | getline d}'`, true},
+		{"awk -f prog.awk file", true},
+		{"awk -i inplace '{print}' file", true},
+		{`awk -F: '{print $1}' /etc/passwd`, false},
+		{`awk '$3 > 100 {print $1}' data`, false},
+		{`awk '$1 == "a" || $2 == "b"' data`, false},
+		{`awk -v n=2 '{print $n}' system.log`, false},
+
+		// sed commands writing files or running commands
+		{`sed 'w out' file`, true},
+		{`sed -n '/x/w out' file`, true},
+		{`sed 's/a/b/w out' file`, true},
+		{`sed 's/a/b/ge' file`, true},
+		{`sed '1e date' file`, true},
+		{`sed -e p -e 'W out' file`, true},
+		{`sed --expression='$w out' file`, true},
+		{"sed -f script.sed file", true},
+		{`sed 's/w/e/g; /e/d' file`, false},
+		{`sed -n '/begin/,/end/{/x/p}' file`, false},
+		{`sed '1i\header' file`, false},
+
+		// git configuration and output files
+		{"git -c core.fsmonitor=./run status", true},
+		{"git --config-env=core.pager=X log", true},
+		{"git diff --output=x", true},
+		{"git log --output x", true},
+		{"git grep -O vim TODO", true},
+		{"git diff --ext-diff", true},
+		{"git log -p --textconv", true},
+		{"git show --textconv=true HEAD", true},
+		{"git diff --no-ext-diff --no-textconv", false},
+		{"git diff --stat", false},
+
+		// Other commands running commands or writing files
+		{"rg --pre ./run TODO", true},
+		{"rg --pre=./run TODO", true},
+		{"fd -x rm", true},
+		{"uniq in.txt out.txt", true},
+		{"xxd in.bin out.hex", true},
+		{"go vet -vettool=./tool ./...", true},
+		{"go env -w=true GOPROXY=x", true},
+		{"go env --u=true GOPROXY", true},
+		{"sort --compress-program=./run -S 1 in.txt", true},
+		{"hostname evil", true},
+		{"hostname -F name.txt", true},
+		{"hostname --file=name.txt", true},
+		{"go env GOPATH", false},
+		{"hostname -f", false},
+		{"rg --pre-glob '*.gz' TODO", false},
+		{"uniq -c in.txt", false},
+	}
+	for _, tt := range tests {
+		err := CheckReadOnly(tt.script)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckReadOnly(%q) error = %v, wantErr %v", tt.script, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package bashkit
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Risk classes of commands that conversations may require the user to
+// approve before they run.
+const (
+	// RiskDestructive commands delete or overwrite data, such as rm -r,
+	// dd and mkfs.
+	RiskDestructive = "destructive"
+	// RiskHistoryRewrite commands discard or rewrite git history, such as
+	// git push --force and git reset --hard.
+	RiskHistoryRewrite = "history_rewrite"
+	// RiskInfrastructure commands change deployed systems, such as
+	// kubectl apply and terraform destroy.
+	RiskInfrastructure = "infrastructure"
+	// RiskSystem commands change the machine itself, such as sudo and
+	// systemctl stop.
+	RiskSystem = "system"
+	// RiskPublish commands publish packages, such as npm publish.
+	RiskPublish = "publish"
+)
+
+// riskyCommands map commands to a check of their arguments returning their
+// risk class, or "" if they aren't risky.
+var riskyCommands = map[string]func(args []string) string{
+	"rm":       withFlag(RiskDestructive, "-r", "-R", "--recursive"),
+	"find":     withArg(RiskDestructive, "-delete"),
+	"shred":    always(RiskDestructive),
+	"dd":       always(RiskDestructive),
+	"truncate": always(RiskDestructive),
+	"wipefs":   always(RiskDestructive),
+	"fdisk":    always(RiskDestructive),
+	"parted":   always(RiskDestructive),
+	"git": withSubcommands(map[string]func([]string) string{
+		"push":          gitPushRisk,
+		"reset":         withArg(RiskHistoryRewrite, "--hard"),
+		"clean":         withFlag(RiskHistoryRewrite, "-f", "--force"),
+		"branch":        withArg(RiskHistoryRewrite, "-D"),
+		"filter-branch": always(RiskHistoryRewrite),
+		"filter-repo":   always(RiskHistoryRewrite),
+	}),
+	"kubectl": withSubcommands(riskySubcommands(RiskInfrastructure,
+		"apply", "create", "delete", "drain", "edit", "patch", "replace", "rollout", "scale")),
+	"helm": withSubcommands(riskySubcommands(RiskInfrastructure,
+		"install", "upgrade", "uninstall", "delete", "rollback")),
+	"terraform": withSubcommands(riskySubcommands(RiskInfrastructure, "apply", "destroy", "import", "state")),
+	"tofu":      withSubcommands(riskySubcommands(RiskInfrastructure, "apply", "destroy", "import", "state")),
+	"docker": withSubcommands(map[string]func([]string) string{
+		"rm": always(RiskInfrastructure), "rmi": always(RiskInfrastructure),
+		"kill": always(RiskInfrastructure), "stop": always(RiskInfrastructure),
+		"system": withArg(RiskInfrastructure, "prune"), "volume": withArg(RiskInfrastructure, "rm", "prune"),
+		"image": withArg(RiskInfrastructure, "rm", "prune"), "container": withArg(RiskInfrastructure, "rm", "prune"),
+	}),
+	"sudo":      always(RiskSystem),
+	"shutdown":  always(RiskSystem),
+	"reboot":    always(RiskSystem),
+	"halt":      always(RiskSystem),
+	"poweroff":  always(RiskSystem),
+	"systemctl": withSubcommands(riskySubcommands(RiskSystem, "stop", "restart", "disable", "mask", "kill", "reboot", "poweroff")),
+	"chmod":     withFlag(RiskSystem, "-R", "--recursive"),
+	"chown":     withFlag(RiskSystem, "-R", "--recursive"),
+	"npm":       withSubcommands(riskySubcommands(RiskPublish, "publish", "unpublish", "deprecate")),
+	"cargo":     withSubcommands(riskySubcommands(RiskPublish, "publish", "yank")),
+	"twine":     withSubcommands(riskySubcommands(RiskPublish, "upload")),
+}
+
+// RiskClass returns the risk class of the first risky command bashScript
+// runs, or "" if it runs none. Like Check, it DOES NOT PROVIDE SECURITY: it
+// picks out commands a user would want to hear about before they run.
+func RiskClass(bashScript string) string {
+	file, err := syntax.NewParser().Parse(strings.NewReader(bashScript), "")
+	if err != nil {
+		// bash will refuse to run it as well
+		return ""
+	}
+	var class string
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if class != "" {
+			return false
+		}
+		if call, ok := node.(*syntax.CallExpr); ok {
+			class = callRiskClass(call)
+		}
+		return class == ""
+	})
+	return class
+}
+
+func callRiskClass(call *syntax.CallExpr) string {
+	if len(call.Args) == 0 {
+		return ""
+	}
+	args := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		args[i] = arg.Lit()
+	}
+	name := path.Base(args[0])
+	if strings.HasPrefix(name, "mkfs") {
+		return RiskDestructive
+	}
+	check, ok := riskyCommands[name]
+	if !ok {
+		return ""
+	}
+	return check(args[1:])
+}
+
+// gitPushRisk classes force pushes and deleting remote branches.
+func gitPushRisk(args []string) string {
+	for _, arg := range args {
+		if arg == "-f" || arg == "-d" || arg == "--delete" || arg == "--mirror" ||
+			strings.HasPrefix(arg, "--force") || strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, ":") {
+			return RiskHistoryRewrite
+		}
+	}
+	return ""
+}
+
+// always classes any use of a command.
+func always(class string) func([]string) string {
+	return func([]string) string { return class }
+}
+
+// withArg classes uses of a command with any of the given arguments.
+func withArg(class string, risky ...string) func([]string) string {
+	return func(args []string) string {
+		for _, arg := range args {
+			if slices.Contains(risky, arg) {
+				return class
+			}
+		}
+		return ""
+	}
+}
+
+// withFlag is like withArg, but also finds single-letter flags combined with
+// others, such as the r of rm -rf.
+func withFlag(class string, flags ...string) func([]string) string {
+	return func(args []string) string {
+		for _, arg := range args {
+			if slices.Contains(flags, arg) {
+				return class
+			}
+			if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") {
+				continue
+			}
+			for _, flag := range flags {
+				if len(flag) == 2 && strings.Contains(arg[1:], flag[1:]) {
+					return class
+				}
+			}
+		}
+		return ""
+	}
+}
+
+// riskySubcommands classes all of the given subcommands.
+func riskySubcommands(class string, subcommands ...string) map[string]func([]string) string {
+	m := make(map[string]func([]string) string, len(subcommands))
+	for _, sub := range subcommands {
+		m[sub] = always(class)
+	}
+	return m
+}
+
+// withSubcommands classes uses of the given subcommands, skipping leading
+// flags as allowSubcommands does, and kubectl's -n and --context.
+func withSubcommands(subcommands map[string]func([]string) string) func([]string) string {
+	return func(args []string) string {
+		for i := 0; i < len(args); i++ {
+			arg := args[i]
+			if strings.HasPrefix(arg, "-") {
+				switch arg {
+				case "-C", "-c", "-n", "--namespace", "--context":
+					i++
+				}
+				continue
+			}
+			if check, ok := subcommands[arg]; ok {
+				return check(args[i+1:])
+			}
+			return ""
+		}
+		return ""
+	}
+}
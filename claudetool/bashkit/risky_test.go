@@ -0,0 +1,47 @@
+package bashkit
+
+import "testing"
+
+func TestRiskClass(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"ls -la", ""},
+		{"rm build/out.o", ""},
+		{"git push origin main", ""},
+		{"git reset HEAD~1", ""},
+		{"kubectl get pods", ""},
+		{"docker ps && docker logs app", ""},
+		{"chmod +x run.sh", ""},
+		{"npm install", ""},
+		{"rm -x", ""},
+		{"echo 'rm -rf /'", ""},
+
+		{"rm -rf build", RiskDestructive},
+		{"cd /tmp && rm -r old", RiskDestructive},
+		{"find . -name '*.log' -delete", RiskDestructive},
+		{"dd if=/dev/zero of=/dev/sda", RiskDestructive},
+		{"/sbin/mkfs.ext4 /dev/sdb1", RiskDestructive},
+		{"git push --force origin main", RiskHistoryRewrite},
+		{"git push --force-with-lease", RiskHistoryRewrite},
+		{"git push origin +main", RiskHistoryRewrite},
+		{"git push origin --delete old", RiskHistoryRewrite},
+		{"git -C repo reset --hard origin/main", RiskHistoryRewrite},
+		{"git clean -fdx", RiskHistoryRewrite},
+		{"kubectl -n prod delete pod web-1", RiskInfrastructure},
+		{"terraform apply -auto-approve", RiskInfrastructure},
+		{"docker system prune -a", RiskInfrastructure},
+		{"sudo apt-get remove nginx", RiskSystem},
+		{"systemctl restart nginx", RiskSystem},
+		{"chown -R app:app /srv", RiskSystem},
+		{"npm publish", RiskPublish},
+		{"for d in a b; do rm -rf $d; done", RiskDestructive},
+		{"echo $(git push -f)", RiskHistoryRewrite},
+	}
+	for _, tt := range tests {
+		if got := RiskClass(tt.script); got != tt.want {
+			t.Errorf("RiskClass(%q) = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
@@ -76,15 +76,8 @@ func (c *ChangeDirTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut
 		return llm.ErrorfToolOut("path is required")
 	}
 
-	// Get current working directory
-	currentWD := c.WorkingDir.Get()
-
 	// Resolve the path
-	targetPath := req.Path
-	if !filepath.IsAbs(targetPath) {
-		targetPath = filepath.Join(currentWD, targetPath)
-	}
-	targetPath = filepath.Clean(targetPath)
+	targetPath := filepath.Clean(c.WorkingDir.Resolve(req.Path))
 
 	// Validate the directory exists
 	info, err := os.Stat(targetPath)
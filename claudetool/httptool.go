@@ -0,0 +1,85 @@
+package claudetool
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+const (
+	// httpToolTimeout bounds a call of an HTTP tool.
+	httpToolTimeout = 60 * time.Second
+	// httpToolMaxResponse is the most of a response sent to the model.
+	httpToolMaxResponse = 64 * 1024
+)
+
+// HTTPTool is a user-defined tool that POSTs its input, as JSON, to an HTTP
+// endpoint and gives the response body to the model.
+type HTTPTool struct {
+	Name        string
+	Description string
+	InputSchema json.RawMessage
+	URL         string
+	// Headers are added to each call, e.g. for authentication.
+	Headers map[string]string
+	// ConversationID is sent in the X-Shelley-Conversation-Id header.
+	ConversationID string
+	// HTTPC is the client for calls (default: http.DefaultClient).
+	HTTPC *http.Client
+}
+
+// Tool returns an llm.Tool that calls the endpoint.
+func (t *HTTPTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        t.Name,
+		Description: t.Description,
+		InputSchema: t.InputSchema,
+		Run:         t.Run,
+	}
+}
+
+// Run calls the endpoint with the tool input.
+func (t *HTTPTool) Run(ctx context.Context, input json.RawMessage) llm.ToolOut {
+	ctx, cancel := context.WithTimeout(ctx, httpToolTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(input))
+	if err != nil {
+		return llm.ErrorfToolOut("%s: %w", t.Name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range t.Headers {
+		req.Header.Set(name, value)
+	}
+	if t.ConversationID != "" {
+		req.Header.Set("X-Shelley-Conversation-Id", t.ConversationID)
+	}
+
+	resp, err := cmp.Or(t.HTTPC, http.DefaultClient).Do(req)
+	if err != nil {
+		return llm.ErrorfToolOut("%s: %w", t.Name, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httpToolMaxResponse+1))
+	if err != nil {
+		return llm.ErrorfToolOut("%s: failed to read response: %w", t.Name, err)
+	}
+	text := string(body)
+	if len(body) > httpToolMaxResponse {
+		text = string(body[:httpToolMaxResponse]) + "\n[response truncated]"
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return llm.ErrorfToolOut("%s: endpoint returned %s: %s", t.Name, resp.Status, strings.TrimSpace(text))
+	}
+	if strings.TrimSpace(text) == "" {
+		text = "(empty response)"
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(text)}
+}
@@ -0,0 +1,67 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestHTTPTool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			http.Error(w, "no such order", http.StatusNotFound)
+		case "/big":
+			w.Write([]byte(strings.Repeat("x", httpToolMaxResponse+10)))
+		default:
+			w.Write([]byte(r.Header.Get("X-Token")))
+		}
+	}))
+	defer server.Close()
+
+	tool := &HTTPTool{Name: "lookup", URL: server.URL, Headers: map[string]string{"X-Token": "t0k"}}
+	out := tool.Run(context.Background(), json.RawMessage(`{}`))
+	if out.Error != nil || out.LLMContent[0].Text != "t0k" {
+		t.Errorf("expected the configured header to be sent, got %+v", out)
+	}
+
+	tool.URL = server.URL + "/fail"
+	if out := tool.Run(context.Background(), json.RawMessage(`{}`)); out.Error == nil || !strings.Contains(out.Error.Error(), "404 Not Found: no such order") {
+		t.Errorf("expected the endpoint's error, got %v", out.Error)
+	}
+
+	tool.URL = server.URL + "/big"
+	if out := tool.Run(context.Background(), json.RawMessage(`{}`)); !strings.HasSuffix(out.LLMContent[0].Text, "[response truncated]") {
+		t.Error("expected a long response to be truncated")
+	}
+}
+
+func TestToolSetUserTools(t *testing.T) {
+	httpTools := []*HTTPTool{
+		{Name: "lookup", InputSchema: llm.EmptySchema()},
+		{Name: "bash", InputSchema: llm.EmptySchema()},
+		{Name: "jira", InputSchema: llm.EmptySchema()},
+	}
+	pluginTools := []*llm.Tool{{Name: "jira", Description: "plugin", InputSchema: llm.EmptySchema()}}
+	names := func(ts *ToolSet) map[string]int {
+		count := map[string]int{}
+		for _, tool := range ts.Tools() {
+			count[tool.Name]++
+		}
+		return count
+	}
+
+	got := names(NewToolSet(context.Background(), ToolSetConfig{HTTPTools: httpTools, PluginTools: pluginTools}))
+	if got["lookup"] != 1 || got["bash"] != 1 || got["jira"] != 1 {
+		t.Errorf("expected lookup once, the built-in bash only and the plugin's jira only, got %v", got)
+	}
+	got = names(NewToolSet(context.Background(), ToolSetConfig{HTTPTools: httpTools, PluginTools: pluginTools, PlanMode: true}))
+	if got["lookup"] != 0 || got["jira"] != 0 {
+		t.Errorf("expected no HTTP or plugin tools in plan mode, got %v", got)
+	}
+}
@@ -41,6 +41,7 @@ func (k *KeywordTool) Tool() *llm.Tool {
 		Description: keywordDescription,
 		InputSchema: llm.MustSchema(keywordInputSchema),
 		Run:         k.keywordRun,
+		ReadOnly:    alwaysReadOnly,
 	}
 }
 
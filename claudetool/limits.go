@@ -0,0 +1,272 @@
+package claudetool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ResourceLimits bound the resources of each bash command and the processes
+// it starts, so that a runaway command fails with an error the agent can act
+// on instead of exhausting the machine. Zero fields are not limited.
+type ResourceLimits struct {
+	// CPUTime bounds the CPU time of each process (RLIMIT_CPU).
+	CPUTime time.Duration
+	// Memory bounds the memory of the command, in bytes: of all its
+	// processes together in a cgroup, or of the address space of each
+	// process (RLIMIT_AS) without one.
+	Memory int64
+	// Processes bounds how many processes the command runs at once. It is
+	// only enforced in a cgroup, since RLIMIT_NPROC counts all processes of
+	// the user.
+	Processes int64
+	// Output bounds the output kept of a command, in bytes. The command is
+	// killed when it writes more.
+	Output int64
+	// Cgroup is a cgroup v2 directory delegated to Shelley, such as one of
+	// a systemd service with Delegate=yes. If set, each command runs in a
+	// cgroup of its own under it, limited by Memory and Processes.
+	// Commands in development containers are not.
+	Cgroup string
+}
+
+// DefaultResourceLimits keep the output of a command from filling the
+// server's memory.
+var DefaultResourceLimits = ResourceLimits{Output: 64 << 20}
+
+// Limits of ResourceLimits, as reported in LimitExceeded.
+const (
+	LimitCPUTime   = "cpu_time"
+	LimitMemory    = "memory"
+	LimitProcesses = "processes"
+	LimitOutput    = "output"
+)
+
+// LimitExceeded is the error of a command that hit one of its resource
+// limits. It is also included in the display data of the bash tool.
+type LimitExceeded struct {
+	// Limit is one of the Limit constants.
+	Limit string `json:"limit"`
+	// Value is the limit: seconds of CPU time, bytes of memory or output,
+	// or a number of processes.
+	Value int64 `json:"value"`
+	err   error
+}
+
+func (e *LimitExceeded) Error() string {
+	switch e.Limit {
+	case LimitCPUTime:
+		return fmt.Sprintf("a process of the command used more than its limit of %ds of CPU time and was killed", e.Value)
+	case LimitMemory:
+		return fmt.Sprintf("the command used more than its memory limit of %s and was killed", formatLimitBytes(e.Value))
+	case LimitProcesses:
+		return fmt.Sprintf("the command tried to run more than its limit of %d processes at once, so starting some failed", e.Value)
+	case LimitOutput:
+		return fmt.Sprintf("the command wrote more than its output limit of %s and was killed; redirect large output to a file", formatLimitBytes(e.Value))
+	}
+	return "the command exceeded a resource limit"
+}
+
+// Unwrap returns the error the command failed with.
+func (e *LimitExceeded) Unwrap() error {
+	return e.err
+}
+
+// formatLimitBytes formats a limit in bytes.
+func formatLimitBytes(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%dGB", n>>30)
+	case n >= 1<<20:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n >= 1<<10:
+		return fmt.Sprintf("%dkB", n>>10)
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// shellPrefix returns shell commands that set the rlimits of the command,
+// and move it into cgroup, if it isn't empty. They go on the same line as
+// the command, so that the line numbers of its errors don't change.
+func (l *ResourceLimits) shellPrefix(cgroup string) string {
+	var prefix strings.Builder
+	if cgroup != "" {
+		procs := filepath.Join(cgroup, "cgroup.procs")
+		fmt.Fprintf(&prefix, `echo $$ > %s || { echo "[could not move the command into cgroup %s]" >&2; exit 1; }; `, shellQuote(procs), cgroup)
+	}
+	if l.CPUTime > 0 {
+		// Processes get SIGXCPU at the soft limit, which tells the limit
+		// apart from other kills, and SIGKILL at the hard one
+		seconds := l.cpuSeconds()
+		fmt.Fprintf(&prefix, "ulimit -H -t %d 2>/dev/null; ulimit -S -t %d 2>/dev/null; ", seconds+1, seconds)
+	}
+	if l.Memory > 0 && cgroup == "" {
+		fmt.Fprintf(&prefix, "ulimit -v %d 2>/dev/null; ", max(l.Memory/1024, 1))
+	}
+	return prefix.String()
+}
+
+// cpuSeconds returns the CPU time limit in whole seconds, as rlimits take it.
+func (l *ResourceLimits) cpuSeconds() int64 {
+	return int64(max(l.CPUTime, time.Second) / time.Second)
+}
+
+// shellQuote quotes s for bash.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// exceeded returns the limit the command hit, judging by its error and
+// cgroup, or nil.
+func (l *ResourceLimits) exceeded(err error, cgroup *commandCgroup) *LimitExceeded {
+	if cgroup != nil {
+		if l.Memory > 0 && cgroup.events("memory.events", "oom_kill") > 0 {
+			return &LimitExceeded{Limit: LimitMemory, Value: l.Memory, err: err}
+		}
+		if l.Processes > 0 && cgroup.events("pids.events", "max") > 0 {
+			return &LimitExceeded{Limit: LimitProcesses, Value: l.Processes, err: err}
+		}
+	}
+	var exitErr *exec.ExitError
+	if l.CPUTime > 0 && errors.As(err, &exitErr) && killedByCPULimit(exitErr) {
+		return &LimitExceeded{Limit: LimitCPUTime, Value: l.cpuSeconds(), err: err}
+	}
+	return nil
+}
+
+// commandCgroup is the cgroup of one command.
+type commandCgroup struct {
+	dir string
+}
+
+// newCgroup creates a cgroup for a command under l.Cgroup, with its memory
+// and process limits.
+func (l *ResourceLimits) newCgroup() (*commandCgroup, error) {
+	// Controllers must be enabled in the parent for its children to use them
+	var controllers []string
+	if l.Memory > 0 {
+		controllers = append(controllers, "+memory")
+	}
+	if l.Processes > 0 {
+		controllers = append(controllers, "+pids")
+	}
+	if len(controllers) > 0 {
+		if err := os.WriteFile(filepath.Join(l.Cgroup, "cgroup.subtree_control"), []byte(strings.Join(controllers, " ")), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to enable cgroup controllers: %w", err)
+		}
+	}
+	dir, err := os.MkdirTemp(l.Cgroup, "bash-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	c := &commandCgroup{dir: dir}
+	settings := map[string]int64{}
+	if l.Memory > 0 {
+		settings["memory.max"] = l.Memory
+	}
+	if l.Processes > 0 {
+		settings["pids.max"] = l.Processes
+	}
+	for name, value := range settings {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strconv.FormatInt(value, 10)), 0o644); err != nil {
+			c.remove()
+			return nil, fmt.Errorf("failed to set %s of cgroup: %w", name, err)
+		}
+	}
+	if l.Memory > 0 {
+		// Without swap, the memory limit is reached instead of swapping; not
+		// every kernel accounts swap
+		os.WriteFile(filepath.Join(dir, "memory.swap.max"), []byte("0"), 0o644)
+	}
+	return c, nil
+}
+
+// events returns the count of the named event in the cgroup's events file.
+func (c *commandCgroup) events(file, name string) int64 {
+	f, err := os.Open(filepath.Join(c.dir, file))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		if key == name {
+			n, _ := strconv.ParseInt(value, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// cgroupRemoveInterval is how often the cgroup of a command whose processes
+// outlive it is checked for being empty, to remove it.
+const cgroupRemoveInterval = 10 * time.Second
+
+// remove removes the cgroup once its processes have exited. Processes
+// daemonized by the command are left running.
+func (c *commandCgroup) remove() {
+	if os.Remove(c.dir) == nil {
+		return
+	}
+	go func() {
+		for range time.Tick(cgroupRemoveInterval) {
+			err := os.Remove(c.dir)
+			if err == nil || os.IsNotExist(err) {
+				return
+			}
+			if !errors.Is(err, syscall.EBUSY) {
+				slog.Warn("failed to remove cgroup of command", "cgroup", c.dir, "error", err)
+				return
+			}
+		}
+	}()
+}
+
+// limitWriter passes output on to w until limit bytes were written, and
+// discards the rest, calling onExceed once.
+type limitWriter struct {
+	w        io.Writer
+	limit    int64
+	onExceed func()
+
+	mu       sync.Mutex
+	n        int64
+	exceeded bool
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if lw.exceeded {
+		return len(p), nil
+	}
+	if remaining := lw.limit - lw.n; int64(len(p)) > remaining {
+		lw.exceeded = true
+		if _, err := lw.w.Write(p[:remaining]); err != nil {
+			return 0, err
+		}
+		lw.n = lw.limit
+		lw.onExceed()
+		return len(p), nil
+	}
+	n, err := lw.w.Write(p)
+	lw.n += int64(n)
+	return n, err
+}
+
+func (lw *limitWriter) hitLimit() bool {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.exceeded
+}
@@ -0,0 +1,114 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBashResourceLimits(t *testing.T) {
+	run := func(limits ResourceLimits, command string) (BashDisplayData, string, error) {
+		t.Helper()
+		bashTool := &BashTool{WorkingDir: NewMutableWorkingDir(t.TempDir()), Limits: &limits}
+		input, _ := json.Marshal(bashInput{Command: command})
+		out := bashTool.Run(context.Background(), input)
+		display, _ := out.Display.(BashDisplayData)
+		var text string
+		if len(out.LLMContent) > 0 {
+			text = out.LLMContent[0].Text
+		}
+		return display, text, out.Error
+	}
+
+	t.Run("Output", func(t *testing.T) {
+		start := time.Now()
+		display, _, err := run(ResourceLimits{Output: 4096}, "yes")
+		var limitErr *LimitExceeded
+		if !errors.As(err, &limitErr) || limitErr.Limit != LimitOutput || limitErr.Value != 4096 {
+			t.Fatalf("expected the output limit to be exceeded, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "output limit of 4kB") {
+			t.Errorf("error = %q", err)
+		}
+		if display.LimitExceeded == nil || display.LimitExceeded.Limit != LimitOutput {
+			t.Errorf("expected the limit in the display data, got %+v", display)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("expected the command to be killed at once, took %s", elapsed)
+		}
+
+		if _, text, err := run(ResourceLimits{Output: 4096}, "echo small"); err != nil || text != "small\n" {
+			t.Errorf("expected small output to pass, got %q, %v", text, err)
+		}
+	})
+
+	t.Run("CPUTime", func(t *testing.T) {
+		_, _, err := run(ResourceLimits{CPUTime: time.Second}, "while :; do :; done")
+		var limitErr *LimitExceeded
+		if !errors.As(err, &limitErr) || limitErr.Limit != LimitCPUTime || limitErr.Value != 1 {
+			t.Fatalf("expected the CPU time limit to be exceeded, got %v", err)
+		}
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		// Without a cgroup, the address space of each process is limited
+		_, text, err := run(ResourceLimits{Memory: 512 << 20}, "ulimit -v")
+		if err != nil || strings.TrimSpace(text) != "524288" {
+			t.Errorf("expected a 512MB address space limit, got %q, %v", text, err)
+		}
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		_, text, err := run(ResourceLimits{}, "ulimit -t")
+		if err != nil || !strings.Contains(text, "unlimited") {
+			t.Errorf("expected no CPU time limit, got %q, %v", text, err)
+		}
+	})
+}
+
+func TestCgroupLimits(t *testing.T) {
+	// A directory stands in for the delegated cgroup
+	parent := t.TempDir()
+	limits := &ResourceLimits{Memory: 256 << 20, Processes: 64, Cgroup: parent}
+	cgroup, err := limits.newCgroup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(cgroup.dir) != parent {
+		t.Fatalf("expected the cgroup under %s, got %s", parent, cgroup.dir)
+	}
+	for file, want := range map[string]string{
+		filepath.Join(parent, "cgroup.subtree_control"): "+memory +pids",
+		filepath.Join(cgroup.dir, "memory.max"):         "268435456",
+		filepath.Join(cgroup.dir, "pids.max"):           "64",
+		filepath.Join(cgroup.dir, "memory.swap.max"):    "0",
+	} {
+		if data, err := os.ReadFile(file); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", file, data, err, want)
+		}
+	}
+
+	// The command moves itself into the cgroup, which limits its memory
+	// instead of rlimits
+	prefix := limits.shellPrefix(cgroup.dir)
+	if !strings.Contains(prefix, filepath.Join(cgroup.dir, "cgroup.procs")) || strings.Contains(prefix, "ulimit -v") {
+		t.Errorf("unexpected prefix %q", prefix)
+	}
+
+	if exceeded := limits.exceeded(errors.New("exit status 1"), cgroup); exceeded != nil {
+		t.Errorf("expected no limit to be exceeded, got %v", exceeded)
+	}
+	os.WriteFile(filepath.Join(cgroup.dir, "pids.events"), []byte("max 3\n"), 0o644)
+	if exceeded := limits.exceeded(errors.New("exit status 1"), cgroup); exceeded == nil || exceeded.Limit != LimitProcesses {
+		t.Errorf("expected the process limit to be exceeded, got %v", exceeded)
+	}
+	os.WriteFile(filepath.Join(cgroup.dir, "memory.events"), []byte("low 0\nhigh 0\nmax 12\noom 1\noom_kill 1\n"), 0o644)
+	if exceeded := limits.exceeded(errors.New("signal: killed"), cgroup); exceeded == nil || exceeded.Limit != LimitMemory {
+		t.Errorf("expected the memory limit to be exceeded, got %v", exceeded)
+	}
+}
@@ -0,0 +1,228 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/lsp"
+)
+
+// LSPTools query language servers for diagnostics, definitions and references.
+type LSPTools struct {
+	Manager    *lsp.Manager
+	WorkingDir *MutableWorkingDir
+}
+
+const (
+	lspDiagnosticsName        = "lsp_diagnostics"
+	lspDiagnosticsDescription = `Report compiler errors and warnings for a file from its language server.
+
+Use this after editing a file to check it compiles, instead of rebuilding the whole project.
+Diagnostics reflect the file as it is on disk, in the context of its workspace.
+`
+	lspDiagnosticsInputSchema = `{
+  "type": "object",
+  "required": ["path"],
+  "properties": {
+    "path": {
+      "type": "string",
+      "description": "The file to check (absolute or relative to the working directory)"
+    }
+  }
+}`
+
+	lspDefinitionName        = "lsp_definition"
+	lspDefinitionDescription = `Find where a symbol is defined, using the file's language server.
+
+Identify the symbol by the line it appears on and its name.
+`
+	lspReferencesName        = "lsp_references"
+	lspReferencesDescription = `Find all references to a symbol, including its declaration, using the file's language server.
+
+Identify the symbol by the line it appears on and its name.
+`
+	lspPositionInputSchema = `{
+  "type": "object",
+  "required": ["path", "line", "symbol"],
+  "properties": {
+    "path": {
+      "type": "string",
+      "description": "The file containing the symbol (absolute or relative to the working directory)"
+    },
+    "line": {
+      "type": "integer",
+      "description": "The 1-based line number where the symbol appears"
+    },
+    "symbol": {
+      "type": "string",
+      "description": "The symbol's name as it appears on that line; the first occurrence is used"
+    }
+  }
+}`
+)
+
+// lspDiagnosticsWait bounds how long to wait for a server to publish diagnostics.
+const lspDiagnosticsWait = 10 * time.Second
+
+// lspMaxLocations bounds the number of locations reported.
+const lspMaxLocations = 100
+
+type lspInput struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line,omitempty"`
+	Symbol string `json:"symbol,omitempty"`
+}
+
+// Tools returns the LSP tools.
+func (t *LSPTools) Tools() []*llm.Tool {
+	return []*llm.Tool{
+		{
+			Name:        lspDiagnosticsName,
+			Description: lspDiagnosticsDescription,
+			InputSchema: llm.MustSchema(lspDiagnosticsInputSchema),
+			Run:         t.runDiagnostics,
+			ReadOnly:    alwaysReadOnly,
+		},
+		{
+			Name:        lspDefinitionName,
+			Description: lspDefinitionDescription,
+			InputSchema: llm.MustSchema(lspPositionInputSchema),
+			Run:         t.runDefinition,
+			ReadOnly:    alwaysReadOnly,
+		},
+		{
+			Name:        lspReferencesName,
+			Description: lspReferencesDescription,
+			InputSchema: llm.MustSchema(lspPositionInputSchema),
+			Run:         t.runReferences,
+			ReadOnly:    alwaysReadOnly,
+		},
+	}
+}
+
+func (t *LSPTools) parse(m json.RawMessage) (lspInput, string, error) {
+	var in lspInput
+	if err := json.Unmarshal(m, &in); err != nil {
+		return in, "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	if in.Path == "" {
+		return in, "", fmt.Errorf("path is required")
+	}
+	return in, filepath.Clean(t.WorkingDir.Resolve(in.Path)), nil
+}
+
+func (t *LSPTools) runDiagnostics(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	_, path, err := t.parse(m)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	client, err := t.Manager.Client(ctx, path)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	diags, err := client.Diagnostics(ctx, path, lspDiagnosticsWait)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to get diagnostics: %w", err)
+	}
+	if len(diags) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent("No diagnostics for " + t.display(path))}
+	}
+	var b strings.Builder
+	for _, d := range diags {
+		source := ""
+		if d.Source != "" {
+			source = " (" + d.Source + ")"
+		}
+		fmt.Fprintf(&b, "%s:%d:%d: %s: %s%s\n", t.display(path), d.Range.Start.Line+1, d.Range.Start.Character+1, d.SeverityName(), d.Message, source)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(b.String())}
+}
+
+func (t *LSPTools) runDefinition(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	return t.runLocations(ctx, m, (*lsp.Client).Definition, "No definition found")
+}
+
+func (t *LSPTools) runReferences(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	return t.runLocations(ctx, m, (*lsp.Client).References, "No references found")
+}
+
+func (t *LSPTools) runLocations(ctx context.Context, m json.RawMessage, query func(*lsp.Client, context.Context, string, lsp.Position) ([]lsp.Location, error), none string) llm.ToolOut {
+	in, path, err := t.parse(m)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	pos, err := symbolPosition(path, in.Line, in.Symbol)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	client, err := t.Manager.Client(ctx, path)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	locs, err := query(client, ctx, path, pos)
+	if err != nil {
+		return llm.ErrorfToolOut("language server request failed: %w", err)
+	}
+	if len(locs) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent(none)}
+	}
+
+	var b strings.Builder
+	lines := make(map[string][]string)
+	for i, loc := range locs {
+		if i == lspMaxLocations {
+			fmt.Fprintf(&b, "... and %d more\n", len(locs)-i)
+			break
+		}
+		file := lsp.URIPath(loc.URI)
+		if _, ok := lines[file]; !ok {
+			data, _ := os.ReadFile(file)
+			lines[file] = strings.Split(string(data), "\n")
+		}
+		text := ""
+		if l := loc.Range.Start.Line; l < len(lines[file]) {
+			text = strings.TrimSpace(lines[file][l])
+		}
+		fmt.Fprintf(&b, "%s:%d:%d: %s\n", t.display(file), loc.Range.Start.Line+1, loc.Range.Start.Character+1, text)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(b.String())}
+}
+
+// display returns path relative to the working directory when it is inside it.
+func (t *LSPTools) display(path string) string {
+	if rel, err := filepath.Rel(t.WorkingDir.Get(), path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// symbolPosition returns the LSP position of the first occurrence of symbol on
+// the 1-based line of the file at path.
+func symbolPosition(path string, line int, symbol string) (lsp.Position, error) {
+	if line < 1 {
+		return lsp.Position{}, fmt.Errorf("line must be at least 1")
+	}
+	if symbol == "" {
+		return lsp.Position{}, fmt.Errorf("symbol is required")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lsp.Position{}, err
+	}
+	lines := strings.Split(string(data), "\n")
+	if line > len(lines) {
+		return lsp.Position{}, fmt.Errorf("line %d is past the end of %s (%d lines)", line, path, len(lines))
+	}
+	text := lines[line-1]
+	col := strings.Index(text, symbol)
+	if col < 0 {
+		return lsp.Position{}, fmt.Errorf("%q does not appear on line %d: %s", symbol, line, strings.TrimSpace(text))
+	}
+	return lsp.Position{Line: line - 1, Character: lsp.UTF16Offset(text, col)}, nil
+}
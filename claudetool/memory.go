@@ -0,0 +1,140 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"shelley.exe.dev/gitstate"
+	"shelley.exe.dev/llm"
+)
+
+// MemoryStore is the storage interface for the memory tool.
+// This is implemented by the db package.
+type MemoryStore interface {
+	// Remember stores a memory for directory, or for every directory when
+	// directory is empty, and returns its ID.
+	Remember(ctx context.Context, directory, content string) (int64, error)
+	// Forget deletes a memory. It reports false if there is no such memory.
+	Forget(ctx context.Context, memoryID int64) (bool, error)
+}
+
+// MemoryTool lets the agent remember facts across conversations.
+type MemoryTool struct {
+	Store      MemoryStore
+	WorkingDir *MutableWorkingDir
+}
+
+const (
+	memoryName        = "memory"
+	memoryDescription = `Remember or forget a fact across conversations.
+
+Remembered facts are included in the system prompt of future conversations:
+project memories in conversations started in the same project, global memories everywhere.
+
+Use "remember" when the user asks you to remember something, or when you learn a
+durable project convention that future conversations will need. Keep each memory
+to one short, self-contained fact.
+
+Use "forget" with a memory's ID (shown in the system prompt) when the user asks you
+to forget it or it is no longer true.
+`
+	memoryInputSchema = `{
+  "type": "object",
+  "required": ["action"],
+  "properties": {
+    "action": {
+      "type": "string",
+      "enum": ["remember", "forget"]
+    },
+    "content": {
+      "type": "string",
+      "description": "The fact to remember (remember only)"
+    },
+    "scope": {
+      "type": "string",
+      "enum": ["project", "global"],
+      "description": "project (default) applies to the current repository or directory; global applies everywhere (remember only)"
+    },
+    "id": {
+      "type": "integer",
+      "description": "The ID of the memory to forget (forget only)"
+    }
+  }
+}`
+)
+
+type memoryInput struct {
+	Action  string `json:"action"`
+	Content string `json:"content,omitempty"`
+	Scope   string `json:"scope,omitempty"`
+	ID      int64  `json:"id,omitempty"`
+}
+
+// Tool returns an llm.Tool for remembering and forgetting memories.
+func (m *MemoryTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        memoryName,
+		Description: memoryDescription,
+		InputSchema: llm.MustSchema(memoryInputSchema),
+		Run:         m.Run,
+	}
+}
+
+// Run executes the memory tool.
+func (m *MemoryTool) Run(ctx context.Context, raw json.RawMessage) llm.ToolOut {
+	var req memoryInput
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse memory input: %w", err)
+	}
+
+	switch req.Action {
+	case "remember":
+		content := strings.TrimSpace(req.Content)
+		if content == "" {
+			return llm.ErrorfToolOut("content is required")
+		}
+		var dir string
+		switch req.Scope {
+		case "", "project":
+			dir = m.projectDir()
+		case "global":
+		default:
+			return llm.ErrorfToolOut("invalid scope: %q", req.Scope)
+		}
+		id, err := m.Store.Remember(ctx, dir, content)
+		if err != nil {
+			return llm.ErrorfToolOut("failed to remember: %w", err)
+		}
+		where := "all projects"
+		if dir != "" {
+			where = dir
+		}
+		return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("Remembered as memory %d for %s.", id, where))}
+	case "forget":
+		if req.ID == 0 {
+			return llm.ErrorfToolOut("id is required")
+		}
+		found, err := m.Store.Forget(ctx, req.ID)
+		if err != nil {
+			return llm.ErrorfToolOut("failed to forget: %w", err)
+		}
+		if !found {
+			return llm.ErrorfToolOut("no memory with id %d", req.ID)
+		}
+		return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("Forgot memory %d.", req.ID))}
+	default:
+		return llm.ErrorfToolOut("invalid action: %q", req.Action)
+	}
+}
+
+// projectDir returns the directory project memories are stored for: the
+// repository root, or the working directory outside a repository.
+func (m *MemoryTool) projectDir() string {
+	wd := m.WorkingDir.Get()
+	if state := gitstate.GetGitState(wd); state.IsRepo && state.Worktree != "" {
+		return state.Worktree
+	}
+	return wd
+}
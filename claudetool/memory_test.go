@@ -0,0 +1,65 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+type fakeMemoryStore struct {
+	memories map[int64]string
+	dirs     map[int64]string
+	nextID   int64
+}
+
+func (f *fakeMemoryStore) Remember(ctx context.Context, directory, content string) (int64, error) {
+	f.nextID++
+	f.memories[f.nextID] = content
+	f.dirs[f.nextID] = directory
+	return f.nextID, nil
+}
+
+func (f *fakeMemoryStore) Forget(ctx context.Context, memoryID int64) (bool, error) {
+	_, ok := f.memories[memoryID]
+	delete(f.memories, memoryID)
+	return ok, nil
+}
+
+func TestMemoryTool(t *testing.T) {
+	dir := t.TempDir()
+	store := &fakeMemoryStore{memories: map[int64]string{}, dirs: map[int64]string{}}
+	tool := &MemoryTool{Store: store, WorkingDir: NewMutableWorkingDir(dir)}
+
+	run := func(in memoryInput) error {
+		t.Helper()
+		input, _ := json.Marshal(in)
+		return tool.Run(context.Background(), input).Error
+	}
+
+	if err := run(memoryInput{Action: "remember", Content: "use tabs"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(memoryInput{Action: "remember", Content: "be brief", Scope: "global"}); err != nil {
+		t.Fatal(err)
+	}
+	if store.dirs[1] != dir || store.dirs[2] != "" {
+		t.Errorf("unexpected memory directories: %q", store.dirs)
+	}
+
+	if err := run(memoryInput{Action: "remember"}); err == nil {
+		t.Error("expected error for empty content")
+	}
+	if err := run(memoryInput{Action: "remember", Content: "x", Scope: "team"}); err == nil {
+		t.Error("expected error for invalid scope")
+	}
+
+	if err := run(memoryInput{Action: "forget", ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.memories[1]; ok {
+		t.Error("expected memory to be forgotten")
+	}
+	if err := run(memoryInput{Action: "forget", ID: 1}); err == nil {
+		t.Error("expected error for unknown memory")
+	}
+}
@@ -0,0 +1,97 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+// NowTool tells the agent the current time, in the user's time zone unless
+// it asks for another.
+type NowTool struct {
+	// Location is the user's time zone; nil for the server's.
+	Location *time.Location
+	// now returns the current time; nil for time.Now.
+	now func() time.Time
+}
+
+const (
+	nowName        = "now"
+	nowDescription = `Get the current date and time in the user's time zone, or in another IANA time zone.
+
+Use this for anything that depends on the date or time, such as dating changelog entries,
+writing cron expressions or computing deadlines, rather than guessing or relying on the
+time at the start of the turn.
+`
+	nowInputSchema = `{
+  "type": "object",
+  "properties": {
+    "timezone": {
+      "type": "string",
+      "description": "An IANA time zone such as Europe/Berlin or UTC; defaults to the user's"
+    }
+  }
+}`
+)
+
+type nowInput struct {
+	Timezone string `json:"timezone"`
+}
+
+// Tool returns an llm.Tool for getting the current time.
+func (t *NowTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        nowName,
+		Description: nowDescription,
+		InputSchema: llm.MustSchema(nowInputSchema),
+		Run:         t.Run,
+		ReadOnly:    alwaysReadOnly,
+	}
+}
+
+// Run executes the now tool.
+func (t *NowTool) Run(ctx context.Context, raw json.RawMessage) llm.ToolOut {
+	var req nowInput
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return llm.ErrorfToolOut("failed to parse now input: %w", err)
+		}
+	}
+	loc := t.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	if tz := strings.TrimSpace(req.Timezone); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return llm.ErrorfToolOut("unknown time zone %q: %w", tz, err)
+		}
+	}
+	now := time.Now
+	if t.now != nil {
+		now = t.now
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(FormatLocalTime(now().In(loc)))}
+}
+
+// FormatLocalTime describes a time for the agent: in RFC 3339, with the
+// weekday, the time zone and its offset from UTC, e.g.
+// "2026-03-29T14:05:00+02:00 (Sunday, Europe/Berlin, CEST, UTC+02:00)".
+func FormatLocalTime(t time.Time) string {
+	abbrev, offset := t.Zone()
+	zone := t.Location().String()
+	if zone == "Local" {
+		zone = abbrev
+	} else if abbrev != zone && !strings.HasPrefix(abbrev, "+") && !strings.HasPrefix(abbrev, "-") {
+		zone += ", " + abbrev
+	}
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%s (%s, %s, UTC%c%02d:%02d)", t.Format(time.RFC3339), t.Weekday(), zone, sign, offset/3600, offset%3600/60)
+}
@@ -0,0 +1,43 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNowTool(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	instant := time.Date(2026, 3, 29, 12, 5, 0, 0, time.UTC)
+	tool := &NowTool{Location: berlin, now: func() time.Time { return instant }}
+
+	run := func(input string) string {
+		t.Helper()
+		out := tool.Run(context.Background(), json.RawMessage(input))
+		if out.Error != nil {
+			t.Fatalf("now(%s) failed: %v", input, out.Error)
+		}
+		return out.LLMContent[0].Text
+	}
+
+	// Berlin switched to summer time that morning
+	if got, want := run(`{}`), "2026-03-29T14:05:00+02:00 (Sunday, Europe/Berlin, CEST, UTC+02:00)"; got != want {
+		t.Errorf("now = %q, want %q", got, want)
+	}
+	if got := run(`{"timezone":"UTC"}`); !strings.HasPrefix(got, "2026-03-29T12:05:00Z (Sunday, UTC, UTC+00:00)") {
+		t.Errorf("now in UTC = %q", got)
+	}
+	if got := run(`{"timezone":"America/St_Johns"}`); !strings.Contains(got, "UTC-02:30") {
+		t.Errorf("expected a half hour offset, got %q", got)
+	}
+
+	out := tool.Run(context.Background(), json.RawMessage(`{"timezone":"Mars/Olympus_Mons"}`))
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "unknown time zone") {
+		t.Errorf("expected an unknown time zone error, got %v", out.Error)
+	}
+}
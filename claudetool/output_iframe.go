@@ -216,10 +216,7 @@ func (t *OutputIframeTool) Run(ctx context.Context, m json.RawMessage) llm.ToolO
 	}
 
 	// Resolve the path relative to working directory
-	path := input.Path
-	if !filepath.IsAbs(path) {
-		path = filepath.Join(t.WorkingDir.Get(), path)
-	}
+	path := t.WorkingDir.Resolve(input.Path)
 
 	// Read the main HTML file
 	data, err := os.ReadFile(path)
@@ -231,9 +228,7 @@ func (t *OutputIframeTool) Run(ctx context.Context, m json.RawMessage) llm.ToolO
 	var embeddedFiles []EmbeddedFile
 	for name, filePath := range input.Files {
 		// Resolve relative paths
-		if !filepath.IsAbs(filePath) {
-			filePath = filepath.Join(t.WorkingDir.Get(), filePath)
-		}
+		filePath = t.WorkingDir.Resolve(filePath)
 		content, err := os.ReadFile(filePath)
 		if err != nil {
 			return llm.ErrorfToolOut("failed to read file %q: %v", name, err)
@@ -353,13 +353,7 @@ func (p *PatchTool) patchParse(m json.RawMessage) (PatchInput, error) {
 // patchRun implements the guts of the patch tool.
 // It populates input from m.
 func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut {
-	path := input.Path
-	if !filepath.IsAbs(input.Path) {
-		// Use shared WorkingDir if available, then context, then Pwd fallback
-		pwd := p.getWorkingDir()
-		path = filepath.Join(pwd, input.Path)
-	}
-	input.Path = path
+	input.Path = p.WorkingDir.Resolve(input.Path)
 	if len(input.Patches) == 0 {
 		return llm.ErrorToolOut(fmt.Errorf("no patches provided"))
 	}
@@ -0,0 +1,99 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"shelley.exe.dev/claudetool/bashkit"
+	"shelley.exe.dev/llm"
+)
+
+// PlanStore is the storage interface for the submit_plan tool.
+// This is implemented by the db package.
+type PlanStore interface {
+	// SavePlan stores the plan for a conversation, replacing any earlier one.
+	SavePlan(ctx context.Context, conversationID, plan string) error
+}
+
+// SubmitPlanTool lets the agent submit its plan for approval in plan mode.
+type SubmitPlanTool struct {
+	Store          PlanStore
+	ConversationID string
+}
+
+// PlanDisplay is the display data for a submitted plan.
+type PlanDisplay struct {
+	Type           string `json:"type"`
+	Plan           string `json:"plan"`
+	ConversationID string `json:"conversation_id"`
+}
+
+const (
+	submitPlanName        = "submit_plan"
+	submitPlanDescription = `Submit your implementation plan for the user to review and approve.
+
+The conversation is in plan mode: you can read and search but not modify anything.
+Once you understand the task, submit a plan in markdown covering the files to change,
+the changes to make, and how to verify them. Then end your turn.
+
+When the user approves the plan, the conversation switches to execution mode and the
+write-capable tools become available. Submitting again replaces the previous plan.
+`
+	submitPlanInputSchema = `{
+  "type": "object",
+  "required": ["plan"],
+  "properties": {
+    "plan": {
+      "type": "string",
+      "description": "The plan, in markdown"
+    }
+  }
+}`
+)
+
+type submitPlanInput struct {
+	Plan string `json:"plan"`
+}
+
+// Tool returns an llm.Tool for submitting a plan.
+func (p *SubmitPlanTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        submitPlanName,
+		Description: submitPlanDescription,
+		InputSchema: llm.MustSchema(submitPlanInputSchema),
+		Run:         p.Run,
+	}
+}
+
+// Run executes the submit_plan tool.
+func (p *SubmitPlanTool) Run(ctx context.Context, raw json.RawMessage) llm.ToolOut {
+	var req submitPlanInput
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse submit_plan input: %w", err)
+	}
+	plan := strings.TrimSpace(req.Plan)
+	if plan == "" {
+		return llm.ErrorfToolOut("plan is required")
+	}
+	if err := p.Store.SavePlan(ctx, p.ConversationID, plan); err != nil {
+		return llm.ErrorfToolOut("failed to save plan: %w", err)
+	}
+	return llm.ToolOut{
+		LLMContent: llm.TextContent("Plan submitted. End your turn and wait for the user to approve it."),
+		Display: PlanDisplay{
+			Type:           "plan",
+			Plan:           plan,
+			ConversationID: p.ConversationID,
+		},
+	}
+}
+
+// checkPlanModeCommand rejects bash commands that may modify anything.
+func checkPlanModeCommand(command string) error {
+	if err := bashkit.CheckReadOnly(command); err != nil {
+		return fmt.Errorf("%w (the conversation is in plan mode: explore read-only and use submit_plan)", err)
+	}
+	return nil
+}
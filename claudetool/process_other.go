@@ -0,0 +1,33 @@
+//go:build !windows
+
+package claudetool
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// shellSysProcAttr starts the shell in a process group of its own, so that
+// killing the group also kills the processes it starts.
+func shellSysProcAttr(Shell, string) *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the process group started by shellSysProcAttr.
+func killProcessGroup(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
+
+// killCommand tells the agent how to kill a background command.
+func killCommand(pid int) string {
+	return fmt.Sprintf("kill -9 -%d", pid)
+}
+
+// killedByCPULimit reports whether the command was killed for exceeding its
+// CPU time limit. A process bash waits for is reported in its exit status as
+// 128 plus the signal, one it execs as killed by the signal.
+func killedByCPULimit(exitErr *exec.ExitError) bool {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return exitErr.ExitCode() == 128+int(syscall.SIGXCPU) || ok && status.Signaled() && status.Signal() == syscall.SIGXCPU
+}
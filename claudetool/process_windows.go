@@ -0,0 +1,37 @@
+package claudetool
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// shellSysProcAttr starts the shell in a process group of its own. cmd.exe
+// gets its command line as is, since it doesn't unquote arguments the way
+// exec quotes them.
+func shellSysProcAttr(shell Shell, command string) *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	if shell.Name == ShellCmd {
+		attr.CmdLine = fmt.Sprintf(`%s /d /s /c "%s"`, syscall.EscapeArg(shell.Path), command)
+	}
+	return attr
+}
+
+// killProcessGroup kills the process and the processes it started, which
+// Windows only keeps track of as a tree.
+func killProcessGroup(pid int) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run()
+}
+
+// killCommand tells the agent how to kill a background command.
+func killCommand(pid int) string {
+	return fmt.Sprintf("taskkill /T /F /PID %d", pid)
+}
+
+// killedByCPULimit reports whether the command was killed for exceeding its
+// CPU time limit, which is never the case on Windows, where there are no
+// rlimits.
+func killedByCPULimit(*exec.ExitError) bool {
+	return false
+}
@@ -0,0 +1,36 @@
+package claudetool
+
+import (
+	"fmt"
+
+	"shelley.exe.dev/claudetool/bashkit"
+	"shelley.exe.dev/llm"
+)
+
+// writeTools are the tools left out in plan mode and read-only conversations
+// because they modify files or run arbitrary commands.
+var writeTools = map[string]bool{
+	PatchName:    true,
+	runTestsName: true,
+	subagentName: true,
+}
+
+// withoutWriteTools returns tools without the write-capable ones.
+func withoutWriteTools(tools []*llm.Tool) []*llm.Tool {
+	var kept []*llm.Tool
+	for _, tool := range tools {
+		if !writeTools[tool.Name] {
+			kept = append(kept, tool)
+		}
+	}
+	return kept
+}
+
+// checkReadOnlyCommand rejects bash commands that may modify anything,
+// including over the network.
+func checkReadOnlyCommand(command string) error {
+	if err := bashkit.CheckReadOnly(command); err != nil {
+		return fmt.Errorf("%w (the conversation is read-only: it may only read files and inspect state)", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestReadOnlyToolSet(t *testing.T) {
+	ts := NewToolSet(context.Background(), ToolSetConfig{
+		WorkingDir: t.TempDir(),
+		ReadOnly:   true,
+		HTTPTools:  []*HTTPTool{{Name: "lookup", InputSchema: llm.EmptySchema()}},
+	})
+	var bash *llm.Tool
+	for _, tool := range ts.Tools() {
+		if writeTools[tool.Name] || tool.Name == "lookup" || tool.Name == submitPlanName {
+			t.Errorf("unexpected tool %s in a read-only conversation", tool.Name)
+		}
+		if tool.Name == "bash" {
+			bash = tool
+		}
+	}
+	if bash == nil {
+		t.Fatal("expected the bash tool")
+	}
+
+	for _, command := range []string{"touch notes.txt", "echo x > notes.txt", "curl -X POST https://example.com", "git push"} {
+		input, _ := json.Marshal(map[string]string{"command": command})
+		out := bash.Run(context.Background(), input)
+		if out.Error == nil || !strings.Contains(out.Error.Error(), "read-only") {
+			t.Errorf("expected %q to be rejected as read-only, got %+v", command, out)
+		}
+	}
+	input, _ := json.Marshal(map[string]string{"command": "ls"})
+	if out := bash.Run(context.Background(), input); out.Error != nil {
+		t.Errorf("expected ls to run, got %v", out.Error)
+	}
+}
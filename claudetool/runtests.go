@@ -0,0 +1,492 @@
+package claudetool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/termtext"
+)
+
+// RunTestsTool runs a project's tests and reports structured results.
+// Commands run with the same environment as the bash tool.
+type RunTestsTool struct {
+	Bash *BashTool
+}
+
+const (
+	runTestsName        = "run_tests"
+	runTestsDescription = `Run the project's tests and report which passed, failed or errored.
+
+Prefer this over running test commands with bash: results are summarized per test,
+only failing output is shown, and the user sees a test panel instead of raw output.
+
+Supported runners are go (go test), pytest and npm (npm test). By default the runner
+is detected from the working directory (go.mod, package.json, or Python test config).
+Use args to narrow the run, e.g. "./server/ -run TestChat" for go or "tests/test_api.py -k login" for pytest.
+`
+	runTestsInputSchema = `{
+  "type": "object",
+  "properties": {
+    "runner": {
+      "type": "string",
+      "enum": ["auto", "go", "pytest", "npm"],
+      "description": "The test runner to use (default: auto)"
+    },
+    "args": {
+      "type": "string",
+      "description": "Arguments passed to the runner as shell words, e.g. packages, paths or filters"
+    },
+    "timeout": {
+      "type": "string",
+      "description": "Timeout as a Go duration string (default: 10m)"
+    }
+  }
+}`
+)
+
+const (
+	runTestsDefaultTimeout = 10 * time.Minute
+	// runTestsMaxOutput bounds the output kept for each failing test.
+	runTestsMaxOutput = 4 * 1024
+	// runTestsMaxRawOutput bounds the tail of the raw output kept for display.
+	runTestsMaxRawOutput = 16 * 1024
+)
+
+// Test result statuses.
+const (
+	TestPass  = "pass"
+	TestFail  = "fail"
+	TestError = "error"
+	TestSkip  = "skip"
+)
+
+// TestResult is the outcome of a single test.
+type TestResult struct {
+	Name     string  `json:"name"`
+	Package  string  `json:"package,omitempty"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration,omitempty"` // seconds
+	Output   string  `json:"output,omitempty"`
+}
+
+// TestRunDisplay is the data passed to the UI for rendering a test run.
+type TestRunDisplay struct {
+	Type     string       `json:"type"`
+	Runner   string       `json:"runner"`
+	Command  string       `json:"command"`
+	ExitCode int          `json:"exit_code"`
+	Passed   int          `json:"passed"`
+	Failed   int          `json:"failed"`
+	Errors   int          `json:"errors"`
+	Skipped  int          `json:"skipped"`
+	Duration float64      `json:"duration"` // seconds
+	Tests    []TestResult `json:"tests"`
+	Output   string       `json:"output,omitempty"`
+}
+
+type runTestsInput struct {
+	Runner  string `json:"runner"`
+	Args    string `json:"args"`
+	Timeout string `json:"timeout"`
+}
+
+func (t *RunTestsTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        runTestsName,
+		Description: runTestsDescription,
+		InputSchema: llm.MustSchema(runTestsInputSchema),
+		Run:         t.Run,
+	}
+}
+
+func (t *RunTestsTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var input runTestsInput
+	if err := json.Unmarshal(m, &input); err != nil {
+		return llm.ErrorfToolOut("failed to parse input: %w", err)
+	}
+	timeout := runTestsDefaultTimeout
+	if input.Timeout != "" {
+		d, err := time.ParseDuration(input.Timeout)
+		if err != nil {
+			return llm.ErrorfToolOut("invalid timeout %q: %w", input.Timeout, err)
+		}
+		timeout = d
+	}
+
+	dir := t.Bash.getWorkingDir()
+	runner := input.Runner
+	if runner == "" || runner == "auto" {
+		runner = detectTestRunner(dir)
+		if runner == "" {
+			return llm.ErrorfToolOut("could not detect a test runner in %s; set runner to go, pytest or npm", dir)
+		}
+	}
+	command, err := testCommand(runner, input.Args)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	output := new(bytes.Buffer)
+	cmd, err := t.Bash.makeBashCommand(execCtx, command, output)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return llm.ErrorfToolOut("command failed: %w", err)
+	}
+	err = cmdWait(cmd)
+	elapsed := time.Since(start)
+	text := termtext.Strip(output.String())
+	if execCtx.Err() == context.DeadlineExceeded {
+		return llm.ErrorfToolOut("%s timed out after %s\n%s", command, timeout, tailOutput(text, runTestsMaxOutput))
+	}
+	var limitErr *LimitExceeded
+	if errors.As(err, &limitErr) {
+		return llm.ErrorfToolOut("%s: %w\n%s", command, limitErr, tailOutput(text, runTestsMaxOutput))
+	}
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return llm.ErrorfToolOut("command failed: %w", err)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	display := parseTestOutput(runner, text)
+	display.Command = command
+	display.ExitCode = exitCode
+	display.Duration = elapsed.Seconds()
+	if exitCode != 0 && display.Failed == 0 && display.Errors == 0 {
+		// The run failed without a failing test, e.g. a syntax error or missing runner
+		display.Tests = append(display.Tests, TestResult{
+			Name:   command,
+			Status: TestError,
+			Output: tailOutput(text, runTestsMaxOutput),
+		})
+		display.Errors++
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(display.summary()), Display: display}
+}
+
+// detectTestRunner guesses the test runner for the project in dir.
+func detectTestRunner(dir string) string {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	if exists("go.mod") {
+		return "go"
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		var pkg struct {
+			Scripts map[string]string `json:"scripts"`
+		}
+		if json.Unmarshal(data, &pkg) == nil && pkg.Scripts["test"] != "" {
+			return "npm"
+		}
+	}
+	for _, name := range []string{"pytest.ini", "conftest.py", "pyproject.toml", "setup.cfg", "tox.ini"} {
+		if exists(name) {
+			return "pytest"
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "test_*.py")); len(matches) > 0 {
+		return "pytest"
+	}
+	if exists("tests") || exists("test") {
+		if matches, _ := filepath.Glob(filepath.Join(dir, "test*", "test_*.py")); len(matches) > 0 {
+			return "pytest"
+		}
+	}
+	return ""
+}
+
+// testCommand returns the shell command that runs runner with args.
+func testCommand(runner, args string) (string, error) {
+	args = strings.TrimSpace(args)
+	switch runner {
+	case "go":
+		if args == "" {
+			args = "./..."
+		}
+		return "go test -json " + args, nil
+	case "pytest":
+		return strings.TrimSpace("python3 -m pytest -rA --color=no " + args), nil
+	case "npm":
+		if args == "" {
+			return "npm test", nil
+		}
+		return "npm test -- " + args, nil
+	default:
+		return "", fmt.Errorf("unknown runner %q; use go, pytest or npm", runner)
+	}
+}
+
+// parseTestOutput extracts structured results from a runner's output.
+func parseTestOutput(runner, output string) *TestRunDisplay {
+	var tests []TestResult
+	switch runner {
+	case "go":
+		tests = parseGoTestJSON(output)
+	case "pytest":
+		tests = parsePytest(output)
+	default:
+		tests = parseGenericTestOutput(output)
+	}
+	d := &TestRunDisplay{Type: runTestsName, Runner: runner, Tests: tests}
+	if d.Tests == nil {
+		d.Tests = []TestResult{}
+	}
+	for i, tr := range d.Tests {
+		switch tr.Status {
+		case TestPass:
+			d.Passed++
+			// Passing output is noise
+			d.Tests[i].Output = ""
+		case TestFail:
+			d.Failed++
+		case TestError:
+			d.Errors++
+		case TestSkip:
+			d.Skipped++
+		}
+		d.Tests[i].Output = tailOutput(d.Tests[i].Output, runTestsMaxOutput)
+	}
+	if runner != "go" {
+		d.Output = tailOutput(output, runTestsMaxRawOutput)
+	}
+	return d
+}
+
+// summary formats the run for the LLM: counts, then the output of each failure.
+func (d *TestRunDisplay) summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d passed, %d failed, %d errors, %d skipped (exit code %d, %.1fs)\n",
+		d.Command, d.Passed, d.Failed, d.Errors, d.Skipped, d.ExitCode, d.Duration)
+	for _, tr := range d.Tests {
+		if tr.Status != TestFail && tr.Status != TestError {
+			continue
+		}
+		name := tr.Name
+		if tr.Package != "" {
+			name = tr.Package + " " + tr.Name
+		}
+		fmt.Fprintf(&b, "\n%s %s\n", strings.ToUpper(tr.Status), strings.TrimSpace(name))
+		if out := strings.TrimSpace(tr.Output); out != "" {
+			b.WriteString(out)
+			b.WriteString("\n")
+		}
+	}
+	if len(d.Tests) == 0 && d.Output != "" {
+		b.WriteString("\nNo individual test results were recognized. Output:\n")
+		b.WriteString(tailOutput(d.Output, runTestsMaxOutput))
+	}
+	return b.String()
+}
+
+// tailOutput returns at most the last n bytes of s, starting at a line boundary.
+func tailOutput(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[len(s)-n:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return "[output truncated]\n" + s
+}
+
+// goTestEvent is an event from go test -json.
+type goTestEvent struct {
+	Action     string
+	Package    string
+	ImportPath string
+	Test       string
+	Elapsed    float64
+	Output     string
+}
+
+// parseGoTestJSON parses go test -json output. Packages that fail without a
+// failing test, e.g. because they do not build, are reported as errors.
+func parseGoTestJSON(output string) []TestResult {
+	var tests []TestResult
+	testOutput := make(map[string]*strings.Builder)
+	pkgOutput := make(map[string]*strings.Builder)
+	failedTests := make(map[string]bool)
+	var stray strings.Builder
+	appendTo := func(m map[string]*strings.Builder, key, s string) {
+		if m[key] == nil {
+			m[key] = new(strings.Builder)
+		}
+		m[key].WriteString(s)
+	}
+	text := func(m map[string]*strings.Builder, key string) string {
+		if m[key] == nil {
+			return ""
+		}
+		return m[key].String()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var ev goTestEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &ev) != nil {
+			// Build errors from older toolchains are printed as plain text
+			stray.WriteString(line + "\n")
+			continue
+		}
+		key := ev.Package + "\x00" + ev.Test
+		switch ev.Action {
+		case "build-output":
+			pkg, _, _ := strings.Cut(ev.ImportPath, " ")
+			appendTo(pkgOutput, pkg, ev.Output)
+		case "output":
+			if ev.Test != "" {
+				appendTo(testOutput, key, ev.Output)
+			} else {
+				appendTo(pkgOutput, ev.Package, ev.Output)
+			}
+		case "pass", "fail", "skip":
+			if ev.Test == "" {
+				if ev.Action == "fail" && !failedTests[ev.Package] {
+					out := text(pkgOutput, ev.Package)
+					if strings.TrimSpace(out) == "" {
+						out = stray.String()
+					}
+					tests = append(tests, TestResult{Package: ev.Package, Status: TestError, Duration: ev.Elapsed, Output: out})
+				}
+				continue
+			}
+			status := map[string]string{"pass": TestPass, "fail": TestFail, "skip": TestSkip}[ev.Action]
+			if status == TestFail {
+				failedTests[ev.Package] = true
+			}
+			tests = append(tests, TestResult{Name: ev.Test, Package: ev.Package, Status: status, Duration: ev.Elapsed, Output: text(testOutput, key)})
+		}
+	}
+	return tests
+}
+
+var (
+	pytestSummaryRegexp = regexp.MustCompile(`^(PASSED|FAILED|ERROR|SKIPPED|XFAIL|XPASS) (\S+)(?: - (.*))?`)
+	pytestSectionRegexp = regexp.MustCompile(`^=+ (.+?) =+$`)
+	pytestBlockRegexp   = regexp.MustCompile(`^_{3,} (.+?) _{3,}$`)
+)
+
+// parsePytest parses the short test summary printed by pytest -rA, attaching
+// the tracebacks from the FAILURES and ERRORS sections to their tests.
+func parsePytest(output string) []TestResult {
+	var tests []TestResult
+	blocks := make(map[string]*strings.Builder)
+	section, block := "", ""
+	for _, line := range strings.Split(output, "\n") {
+		if m := pytestSectionRegexp.FindStringSubmatch(line); m != nil {
+			section, block = m[1], ""
+			continue
+		}
+		switch section {
+		case "FAILURES", "ERRORS":
+			if m := pytestBlockRegexp.FindStringSubmatch(line); m != nil {
+				block = strings.TrimPrefix(m[1], "ERROR at setup of ")
+				blocks[block] = new(strings.Builder)
+				continue
+			}
+			if block != "" {
+				blocks[block].WriteString(line + "\n")
+			}
+		case "short test summary info":
+			m := pytestSummaryRegexp.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			tr := TestResult{Name: m[2], Output: m[3]}
+			switch m[1] {
+			case "PASSED", "XPASS":
+				tr.Status = TestPass
+			case "FAILED":
+				tr.Status = TestFail
+			case "ERROR":
+				tr.Status = TestError
+			default:
+				tr.Status = TestSkip
+			}
+			if file, _, ok := strings.Cut(tr.Name, "::"); ok {
+				tr.Package = file
+			}
+			if strings.HasPrefix(tr.Name, "[") {
+				// SKIPPED [1] path:line: reason
+				tr.Name, tr.Output = strings.TrimSpace(line[len(m[1]):]), ""
+			}
+			tests = append(tests, tr)
+		}
+	}
+	for i, tr := range tests {
+		if tr.Status != TestFail && tr.Status != TestError {
+			continue
+		}
+		// Block headers name the test without its file, with classes joined by dots
+		_, name, _ := strings.Cut(tr.Name, "::")
+		if b := blocks[strings.ReplaceAll(name, "::", ".")]; b != nil {
+			tests[i].Output = b.String()
+		} else if b := blocks[tr.Name]; b != nil {
+			tests[i].Output = b.String()
+		}
+	}
+	return tests
+}
+
+var (
+	tapRegexp  = regexp.MustCompile(`^\s*(not ok|ok)\s+\d+\s*(?:-\s*)?(.*)$`)
+	markRegexp = regexp.MustCompile(`^\s*([✓✔√✕✖✗×○])\s+(.+?)(?:\s+\(\d+(?:\.\d+)?\s*m?s\))?$`)
+)
+
+// parseGenericTestOutput recognizes TAP lines and the check marks printed by
+// Jest, Mocha, Vitest and node --test. Other output is left unparsed.
+func parseGenericTestOutput(output string) []TestResult {
+	var tests []TestResult
+	for _, line := range strings.Split(output, "\n") {
+		if m := tapRegexp.FindStringSubmatch(line); m != nil {
+			name := m[2]
+			status := TestPass
+			switch {
+			case strings.Contains(strings.ToUpper(name), "# SKIP"), strings.Contains(strings.ToUpper(name), "# TODO"):
+				status = TestSkip
+			case m[1] == "not ok":
+				status = TestFail
+			}
+			if i := strings.Index(name, " # "); i >= 0 {
+				name = name[:i]
+			}
+			tests = append(tests, TestResult{Name: strings.TrimSpace(name), Status: status})
+			continue
+		}
+		if m := markRegexp.FindStringSubmatch(line); m != nil {
+			status := TestPass
+			switch m[1] {
+			case "✕", "✖", "✗", "×":
+				status = TestFail
+			case "○":
+				status = TestSkip
+			}
+			tests = append(tests, TestResult{Name: m[2], Status: status})
+		}
+	}
+	return tests
+}
@@ -0,0 +1,153 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGoTestJSON(t *testing.T) {
+	output := strings.Join([]string{
+		`{"Action":"run","Package":"example.com/a","Test":"TestOK"}`,
+		`{"Action":"output","Package":"example.com/a","Test":"TestOK","Output":"=== RUN   TestOK\n"}`,
+		`{"Action":"pass","Package":"example.com/a","Test":"TestOK","Elapsed":0.01}`,
+		`{"Action":"run","Package":"example.com/a","Test":"TestBad"}`,
+		`{"Action":"output","Package":"example.com/a","Test":"TestBad","Output":"    a_test.go:9: got 1, want 2\n"}`,
+		`{"Action":"fail","Package":"example.com/a","Test":"TestBad","Elapsed":0.02}`,
+		`{"Action":"skip","Package":"example.com/a","Test":"TestLater"}`,
+		`{"Action":"fail","Package":"example.com/a","Elapsed":0.1}`,
+		`{"ImportPath":"example.com/b [example.com/b.test]","Action":"build-output","Output":"b/b.go:3:1: syntax error\n"}`,
+		`{"Action":"fail","Package":"example.com/b","Elapsed":0}`,
+	}, "\n")
+
+	d := parseTestOutput("go", output)
+	if d.Passed != 1 || d.Failed != 1 || d.Skipped != 1 || d.Errors != 1 {
+		t.Fatalf("unexpected counts: %+v", d)
+	}
+	for _, tr := range d.Tests {
+		switch {
+		case tr.Name == "TestOK" && tr.Output != "":
+			t.Errorf("passing test output should be dropped, got %q", tr.Output)
+		case tr.Name == "TestBad" && !strings.Contains(tr.Output, "got 1, want 2"):
+			t.Errorf("failing test output missing: %q", tr.Output)
+		case tr.Status == TestError && (tr.Package != "example.com/b" || !strings.Contains(tr.Output, "syntax error")):
+			t.Errorf("unexpected build error result: %+v", tr)
+		}
+	}
+	summary := d.summary()
+	if !strings.Contains(summary, "FAIL example.com/a TestBad") || strings.Contains(summary, "TestOK") {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+}
+
+func TestParsePytest(t *testing.T) {
+	output := `============================= test session starts ==============================
+collected 4 items
+
+tests/test_math.py .F.s                                                  [100%]
+
+=================================== FAILURES ===================================
+_____________________________ TestMath.test_divide _____________________________
+
+    def test_divide(self):
+>       assert 1 / 1 == 2
+E       assert 1.0 == 2
+
+tests/test_math.py:9: AssertionError
+==================================== PASSES ====================================
+=========================== short test summary info ============================
+PASSED tests/test_math.py::test_add
+PASSED tests/test_math.py::test_sub
+FAILED tests/test_math.py::TestMath::test_divide - assert 1.0 == 2
+SKIPPED [1] tests/test_math.py:12: not ready
+==================== 1 failed, 2 passed, 1 skipped in 0.05s ====================
+`
+	d := parseTestOutput("pytest", output)
+	if d.Passed != 2 || d.Failed != 1 || d.Skipped != 1 || d.Errors != 0 {
+		t.Fatalf("unexpected counts: %+v", d)
+	}
+	for _, tr := range d.Tests {
+		if tr.Status == TestFail {
+			if tr.Package != "tests/test_math.py" || !strings.Contains(tr.Output, "assert 1.0 == 2") || !strings.Contains(tr.Output, "AssertionError") {
+				t.Errorf("unexpected failure: %+v", tr)
+			}
+		}
+	}
+}
+
+func TestParseGenericTestOutput(t *testing.T) {
+	output := `
+  math
+    ✓ adds (2 ms)
+    ✕ divides (5 ms)
+    ○ skipped multiplies
+ok 1 - parses input
+not ok 2 - rejects bad input
+ok 3 - network # SKIP offline
+`
+	d := parseTestOutput("npm", output)
+	if d.Passed != 2 || d.Failed != 2 || d.Skipped != 2 {
+		t.Fatalf("unexpected counts: %+v", d.Tests)
+	}
+	if d.Tests[1].Name != "divides" || d.Tests[5].Name != "network" {
+		t.Errorf("unexpected names: %+v", d.Tests)
+	}
+}
+
+func TestDetectTestRunner(t *testing.T) {
+	for _, tt := range []struct {
+		file, content, want string
+	}{
+		{"go.mod", "module x\n", "go"},
+		{"package.json", `{"scripts":{"test":"jest"}}`, "npm"},
+		{"package.json", `{"scripts":{}}`, ""},
+		{"pyproject.toml", "", "pytest"},
+		{"test_things.py", "", "pytest"},
+		{"README", "", ""},
+	} {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.content), 0o644)
+		if got := detectTestRunner(dir); got != tt.want {
+			t.Errorf("detectTestRunner with %s %q = %q, want %q", tt.file, tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestRunTestsToolGo(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/tests\n\ngo 1.21\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "x_test.go"), []byte(`package tests
+
+import "testing"
+
+func TestPass(t *testing.T) {}
+
+func TestFail(t *testing.T) { t.Fatal("boom") }
+`), 0o644)
+
+	tool := &RunTestsTool{Bash: &BashTool{WorkingDir: NewMutableWorkingDir(dir)}}
+	// The tool runs in its own module, not the one under test
+	t.Setenv("GOFLAGS", "")
+	input, _ := json.Marshal(runTestsInput{Runner: "auto"})
+	out := tool.Run(context.Background(), input)
+	if out.Error != nil {
+		t.Fatalf("unexpected error: %v", out.Error)
+	}
+	d, ok := out.Display.(*TestRunDisplay)
+	if !ok {
+		t.Fatalf("unexpected display type %T", out.Display)
+	}
+	if d.Runner != "go" || d.Passed != 1 || d.Failed != 1 || d.ExitCode == 0 {
+		t.Fatalf("unexpected results: %+v", d)
+	}
+	if text := out.LLMContent[0].Text; !strings.Contains(text, "boom") || !strings.Contains(text, "1 passed, 1 failed") {
+		t.Errorf("unexpected summary:\n%s", text)
+	}
+}
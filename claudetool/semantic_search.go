@@ -0,0 +1,100 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"shelley.exe.dev/codeindex"
+	"shelley.exe.dev/llm"
+)
+
+// SemanticSearchTool searches the repository with a semantic code index.
+type SemanticSearchTool struct {
+	Index      *codeindex.Index
+	WorkingDir *MutableWorkingDir
+}
+
+const (
+	semanticSearchName        = "semantic_search"
+	semanticSearchDescription = `Find code related to a natural-language description using a semantic index of the repository.
+
+Use this to locate where a concept or behavior is implemented when you don't know the
+names involved, especially in large repositories where grepping is slow or noisy.
+Results are chunks of files, most relevant first. The index covers the repository
+containing the working directory and is updated automatically before each search.
+
+If you know exact identifiers, error messages or filenames, use rg instead.
+`
+	semanticSearchInputSchema = `{
+  "type": "object",
+  "required": ["query"],
+  "properties": {
+    "query": {
+      "type": "string",
+      "description": "A description of the code you are looking for"
+    },
+    "limit": {
+      "type": "integer",
+      "description": "Maximum number of results (default 8, at most 30)"
+    }
+  }
+}`
+)
+
+const (
+	semanticSearchDefaultLimit = 8
+	semanticSearchMaxLimit     = 30
+)
+
+type semanticSearchInput struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// Tool returns an llm.Tool for semantic code search.
+func (s *SemanticSearchTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        semanticSearchName,
+		Description: semanticSearchDescription,
+		InputSchema: llm.MustSchema(semanticSearchInputSchema),
+		Run:         s.Run,
+		ReadOnly:    alwaysReadOnly,
+	}
+}
+
+// Run executes the semantic_search tool.
+func (s *SemanticSearchTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req semanticSearchInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse semantic_search input: %w", err)
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return llm.ErrorfToolOut("query is required")
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = semanticSearchDefaultLimit
+	}
+	limit = min(limit, semanticSearchMaxLimit)
+
+	root := s.WorkingDir.Get()
+	if repoRoot, err := FindRepoRoot(root); err == nil {
+		root = repoRoot
+	}
+	results, err := s.Index.Search(ctx, root, req.Query, limit)
+	if err != nil {
+		return llm.ErrorfToolOut("semantic search failed: %w", err)
+	}
+	if len(results) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent("No indexed files in " + root)}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Results in %s:\n", root)
+	for _, r := range results {
+		fmt.Fprintf(&b, "\n%s:%d-%d (score %.2f)\n%s\n", r.Path, r.StartLine, r.EndLine, r.Score, r.Content)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(b.String())}
+}
@@ -0,0 +1,44 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/codeindex"
+	"shelley.exe.dev/db"
+)
+
+func TestSemanticSearchTool(t *testing.T) {
+	database, err := db.New(db.Config{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "retry.go"), []byte("func retryWithBackoff(attempts int) {}\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "color.go"), []byte("func parseColor(hex string) {}\n"), 0o644)
+
+	tool := &SemanticSearchTool{Index: codeindex.New(database, codeindex.NewHashEmbedder()), WorkingDir: NewMutableWorkingDir(dir)}
+	input, _ := json.Marshal(semanticSearchInput{Query: "retry backoff", Limit: 1})
+	out := tool.Run(context.Background(), input)
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	text := out.LLMContent[0].Text
+	if !strings.Contains(text, "retry.go:1-1") || strings.Contains(text, "color.go") {
+		t.Errorf("unexpected result: %q", text)
+	}
+
+	input, _ = json.Marshal(semanticSearchInput{})
+	if out := tool.Run(context.Background(), input); out.Error == nil {
+		t.Error("expected error for empty query")
+	}
+}
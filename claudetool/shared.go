@@ -7,8 +7,14 @@ package claudetool
 
 import (
 	"context"
+	"encoding/json"
 )
 
+// alwaysReadOnly is the llm.Tool ReadOnly of tools that never write.
+func alwaysReadOnly(json.RawMessage) bool {
+	return true
+}
+
 type workingDirCtxKeyType string
 
 const workingDirCtxKey workingDirCtxKeyType = "workingDir"
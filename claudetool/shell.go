@@ -0,0 +1,78 @@
+package claudetool
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Shells the bash tool runs commands with.
+const (
+	ShellBash       = "bash"
+	ShellPowerShell = "powershell"
+	ShellCmd        = "cmd"
+)
+
+// Shell is the shell the bash tool runs commands with.
+type Shell struct {
+	// Name is one of the Shell constants.
+	Name string
+	// Path is the shell's executable.
+	Path string
+}
+
+// args returns the arguments that make the shell run command.
+func (s Shell) args(command string) []string {
+	switch s.Name {
+	case ShellPowerShell:
+		return []string{"-NoLogo", "-NoProfile", "-NonInteractive", "-Command", command}
+	case ShellCmd:
+		return []string{"/d", "/s", "/c", command}
+	}
+	return []string{"-c", command}
+}
+
+// DetectShell returns the shell commands run with: bash, except on Windows
+// without bash (such as Git for Windows') on the PATH, where it is
+// PowerShell, or cmd.exe if PowerShell isn't installed either.
+func DetectShell() Shell {
+	if runtime.GOOS != "windows" {
+		return Shell{Name: ShellBash, Path: "bash"}
+	}
+	// System32's bash.exe starts a shell in WSL, where Windows paths don't work
+	system32 := filepath.Join(os.Getenv("SystemRoot"), "System32")
+	if path, err := exec.LookPath("bash"); err == nil && !strings.EqualFold(filepath.Dir(path), system32) {
+		return Shell{Name: ShellBash, Path: path}
+	}
+	for _, name := range []string{"pwsh", "powershell"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return Shell{Name: ShellPowerShell, Path: path}
+		}
+	}
+	comspec := os.Getenv("ComSpec")
+	if comspec == "" {
+		comspec = "cmd.exe"
+	}
+	return Shell{Name: ShellCmd, Path: comspec}
+}
+
+// defaultShell is the shell of bash tools that don't set one.
+var defaultShell = sync.OnceValue(DetectShell)
+
+// shellDescriptions tell the agent how commands run in shells other than
+// bash, appended to the bash tool's description.
+var shellDescriptions = map[string]string{
+	ShellPowerShell: `
+This machine runs Windows and has no bash: commands run in PowerShell (-Command), not bash.
+Use PowerShell syntax and cmdlets, e.g. Get-ChildItem, Select-String, $env:NAME, and ; to
+separate commands. Paths use backslashes, e.g. C:\Users\me, though / works in most commands.
+`,
+	ShellCmd: `
+This machine runs Windows and has neither bash nor PowerShell: commands run in cmd.exe (/c).
+Use cmd syntax, e.g. dir, type, findstr, set NAME=value, %NAME% and && to chain commands.
+Paths use backslashes, e.g. C:\Users\me.
+`,
+}
@@ -0,0 +1,60 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDetectShell(t *testing.T) {
+	shell := DetectShell()
+	if runtime.GOOS != "windows" && shell.Name != ShellBash {
+		t.Errorf("expected bash outside Windows, got %+v", shell)
+	}
+	if _, err := exec.LookPath(shell.Path); err != nil {
+		t.Errorf("shell %+v not found: %v", shell, err)
+	}
+
+	// echo works the same in all of the shells
+	bash := &BashTool{WorkingDir: NewMutableWorkingDir(t.TempDir())}
+	out := bash.Run(context.Background(), json.RawMessage(`{"command":"echo hello"}`))
+	if out.Error != nil {
+		t.Fatalf("echo failed with %s: %v", shell.Name, out.Error)
+	}
+	if got := strings.TrimSpace(out.LLMContent[0].Text); got != "hello" {
+		t.Errorf("echo hello with %s = %q", shell.Name, got)
+	}
+}
+
+func TestShellDescription(t *testing.T) {
+	for name, want := range map[string]string{
+		ShellBash:       "",
+		ShellPowerShell: "commands run in PowerShell",
+		ShellCmd:        "commands run in cmd.exe",
+	} {
+		bash := &BashTool{WorkingDir: NewMutableWorkingDir("/"), Shell: &Shell{Name: name}}
+		description := bash.Tool().Description
+		if want == "" {
+			if strings.Contains(description, "Windows") {
+				t.Errorf("%s: expected no Windows guidance, got %q", name, description)
+			}
+		} else if !strings.Contains(description, want) {
+			t.Errorf("%s: expected description to contain %q, got %q", name, want, description)
+		}
+	}
+}
+
+func TestShellArgs(t *testing.T) {
+	for name, want := range map[string][]string{
+		ShellBash:       {"-c", "echo hi"},
+		ShellPowerShell: {"-NoLogo", "-NoProfile", "-NonInteractive", "-Command", "echo hi"},
+		ShellCmd:        {"/d", "/s", "/c", "echo hi"},
+	} {
+		if got := (Shell{Name: name}).args("echo hi"); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: args = %q, want %q", name, got, want)
+		}
+	}
+}
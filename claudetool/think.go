@@ -13,6 +13,7 @@ var Think = &llm.Tool{
 	Description: thinkDescription,
 	InputSchema: llm.MustSchema(thinkInputSchema),
 	Run:         thinkRun,
+	ReadOnly:    alwaysReadOnly,
 }
 
 const (
@@ -0,0 +1,150 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// Todo statuses
+const (
+	TodoPending    = "pending"
+	TodoInProgress = "in_progress"
+	TodoCompleted  = "completed"
+)
+
+// Todo is an item of the agent's task list.
+type Todo struct {
+	Content string `json:"content"`
+	Status  string `json:"status"`
+}
+
+// TodoStore is the storage interface for the todo tool.
+// This is implemented by the db package.
+type TodoStore interface {
+	// SaveTodos replaces the todo list of a conversation with todos, a JSON array of Todo.
+	SaveTodos(ctx context.Context, conversationID, todos string) error
+}
+
+// TodoTool lets the agent keep a task list during long work.
+type TodoTool struct {
+	Store          TodoStore
+	ConversationID string
+}
+
+// TodoDisplay is the display data for a todo list update.
+type TodoDisplay struct {
+	Type  string `json:"type"`
+	Todos []Todo `json:"todos"`
+}
+
+const (
+	todoName        = "todo"
+	todoDescription = `Record and update your task list.
+
+Use this for work with several steps: write the list when you start, then update it
+as you go, marking one item in_progress while you work on it and completed as soon
+as it is done. The user sees the list as a live progress view.
+
+Each call replaces the whole list, so always send every item.
+Skip this for simple tasks that take one or two steps.
+`
+	todoInputSchema = `{
+  "type": "object",
+  "required": ["todos"],
+  "properties": {
+    "todos": {
+      "type": "array",
+      "description": "The complete task list",
+      "items": {
+        "type": "object",
+        "required": ["content", "status"],
+        "properties": {
+          "content": {
+            "type": "string",
+            "description": "What to do"
+          },
+          "status": {
+            "type": "string",
+            "enum": ["pending", "in_progress", "completed"]
+          }
+        }
+      }
+    }
+  }
+}`
+)
+
+type todoInput struct {
+	Todos []Todo `json:"todos"`
+}
+
+// Tool returns an llm.Tool for updating the task list.
+func (t *TodoTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        todoName,
+		Description: todoDescription,
+		InputSchema: llm.MustSchema(todoInputSchema),
+		Run:         t.Run,
+	}
+}
+
+// Run executes the todo tool.
+func (t *TodoTool) Run(ctx context.Context, raw json.RawMessage) llm.ToolOut {
+	var req todoInput
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse todo input: %w", err)
+	}
+	todos := make([]Todo, 0, len(req.Todos))
+	for i, todo := range req.Todos {
+		todo.Content = strings.TrimSpace(todo.Content)
+		if todo.Content == "" {
+			return llm.ErrorfToolOut("todo %d has no content", i+1)
+		}
+		switch todo.Status {
+		case TodoPending, TodoInProgress, TodoCompleted:
+		case "":
+			todo.Status = TodoPending
+		default:
+			return llm.ErrorfToolOut("todo %d has invalid status %q", i+1, todo.Status)
+		}
+		todos = append(todos, todo)
+	}
+
+	data, err := json.Marshal(todos)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	if err := t.Store.SaveTodos(ctx, t.ConversationID, string(data)); err != nil {
+		return llm.ErrorfToolOut("failed to save todos: %w", err)
+	}
+	return llm.ToolOut{
+		LLMContent: llm.TextContent(todoSummary(todos)),
+		Display:    TodoDisplay{Type: "todo", Todos: todos},
+	}
+}
+
+// todoSummary describes the progress through todos.
+func todoSummary(todos []Todo) string {
+	if len(todos) == 0 {
+		return "Todo list cleared."
+	}
+	done := 0
+	var current []string
+	for _, todo := range todos {
+		switch todo.Status {
+		case TodoCompleted:
+			done++
+		case TodoInProgress:
+			current = append(current, todo.Content)
+		}
+	}
+	summary := fmt.Sprintf("Todo list updated: %d of %d completed.", done, len(todos))
+	if len(current) > 0 {
+		summary += " In progress: " + strings.Join(current, "; ")
+	}
+	return summary
+}
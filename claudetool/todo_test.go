@@ -0,0 +1,52 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type fakeTodoStore struct {
+	todos map[string]string
+}
+
+func (f *fakeTodoStore) SaveTodos(ctx context.Context, conversationID, todos string) error {
+	f.todos[conversationID] = todos
+	return nil
+}
+
+func TestTodoTool(t *testing.T) {
+	store := &fakeTodoStore{todos: map[string]string{}}
+	tool := &TodoTool{Store: store, ConversationID: "c1"}
+
+	input, _ := json.Marshal(todoInput{Todos: []Todo{
+		{Content: "write the parser", Status: TodoCompleted},
+		{Content: " add tests ", Status: TodoInProgress},
+		{Content: "update docs"},
+	}})
+	out := tool.Run(context.Background(), input)
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if text := out.LLMContent[0].Text; !strings.Contains(text, "1 of 3 completed") || !strings.Contains(text, "In progress: add tests") {
+		t.Errorf("unexpected summary: %q", text)
+	}
+	var saved []Todo
+	if err := json.Unmarshal([]byte(store.todos["c1"]), &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 3 || saved[1].Content != "add tests" || saved[2].Status != TodoPending {
+		t.Errorf("unexpected saved todos: %+v", saved)
+	}
+	if d, ok := out.Display.(TodoDisplay); !ok || len(d.Todos) != 3 {
+		t.Errorf("unexpected display: %+v", out.Display)
+	}
+
+	for _, bad := range []Todo{{Content: ""}, {Content: "x", Status: "blocked"}} {
+		input, _ := json.Marshal(todoInput{Todos: []Todo{bad}})
+		if out := tool.Run(context.Background(), input); out.Error == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
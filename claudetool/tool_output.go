@@ -0,0 +1,112 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// ToolOutputStore is the storage interface for the tool_output tool.
+// This is implemented by the db package.
+type ToolOutputStore interface {
+	// GetToolOutput returns the full output of a truncated tool result of the
+	// conversation. It reports false if there is none.
+	GetToolOutput(ctx context.Context, conversationID, toolUseID string) (string, bool, error)
+}
+
+// ToolOutputTool lets the agent read the full output of tool results that
+// were truncated.
+type ToolOutputTool struct {
+	Store          ToolOutputStore
+	ConversationID string
+}
+
+// ToolOutputName is the name of the tool_output tool, which truncated tool
+// results refer to.
+const ToolOutputName = "tool_output"
+
+const (
+	toolOutputDefaultLines = 200
+	toolOutputDescription  = `Read the full output of a tool result that was truncated.
+
+Truncated results say so and give the id to pass here. The output is returned
+with line numbers, a range of lines at a time; use offset and limit to page through it.
+`
+	toolOutputInputSchema = `{
+  "type": "object",
+  "required": ["id"],
+  "properties": {
+    "id": {
+      "type": "string",
+      "description": "The id given in the truncated result"
+    },
+    "offset": {
+      "type": "integer",
+      "description": "The first line to return, counting from 1 (default 1)"
+    },
+    "limit": {
+      "type": "integer",
+      "description": "How many lines to return (default 200)"
+    }
+  }
+}`
+)
+
+type toolOutputInput struct {
+	ID     string `json:"id"`
+	Offset int    `json:"offset,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// Tool returns an llm.Tool for reading truncated tool output.
+func (t *ToolOutputTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        ToolOutputName,
+		Description: toolOutputDescription,
+		InputSchema: llm.MustSchema(toolOutputInputSchema),
+		Run:         t.Run,
+		ReadOnly:    alwaysReadOnly,
+	}
+}
+
+// Run executes the tool_output tool.
+func (t *ToolOutputTool) Run(ctx context.Context, raw json.RawMessage) llm.ToolOut {
+	var req toolOutputInput
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse tool_output input: %w", err)
+	}
+	if req.ID == "" {
+		return llm.ErrorfToolOut("id is required")
+	}
+	output, ok, err := t.Store.GetToolOutput(ctx, t.ConversationID, req.ID)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to get tool output: %w", err)
+	}
+	if !ok {
+		return llm.ErrorfToolOut("no truncated tool output with id %q", req.ID)
+	}
+
+	offset := max(req.Offset, 1)
+	limit := req.Limit
+	if limit <= 0 {
+		limit = toolOutputDefaultLines
+	}
+	lines := strings.Split(output, "\n")
+	if offset > len(lines) {
+		return llm.ErrorfToolOut("offset %d is past the end of the output (%d lines)", offset, len(lines))
+	}
+	end := min(offset-1+limit, len(lines))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[lines %d-%d of %d]\n", offset, end, len(lines))
+	for i := offset - 1; i < end; i++ {
+		fmt.Fprintf(&b, "%6d: %s\n", i+1, lines[i])
+	}
+	if end < len(lines) {
+		fmt.Fprintf(&b, "[%d more lines; continue with offset %d]\n", len(lines)-end, end+1)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(b.String())}
+}
@@ -0,0 +1,58 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type fakeToolOutputStore map[string]string
+
+func (f fakeToolOutputStore) GetToolOutput(ctx context.Context, conversationID, toolUseID string) (string, bool, error) {
+	output, ok := f[conversationID+"/"+toolUseID]
+	return output, ok, nil
+}
+
+func TestToolOutputTool(t *testing.T) {
+	var lines []string
+	for i := range 250 {
+		lines = append(lines, strings.Repeat("x", i%7))
+	}
+	tool := &ToolOutputTool{Store: fakeToolOutputStore{"c1/t1": strings.Join(lines, "\n")}, ConversationID: "c1"}
+	run := func(in toolOutputInput) (string, error) {
+		t.Helper()
+		input, _ := json.Marshal(in)
+		out := tool.Run(context.Background(), input)
+		if out.Error != nil {
+			return "", out.Error
+		}
+		return out.LLMContent[0].Text, nil
+	}
+
+	out, err := run(toolOutputInput{ID: "t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "[lines 1-200 of 250]\n") || !strings.Contains(out, "continue with offset 201") {
+		t.Errorf("first page:\n%s", out)
+	}
+	out, err = run(toolOutputInput{ID: "t1", Offset: 241, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "[lines 241-250 of 250]\n") || !strings.Contains(out, "   250: xxxx\n") || strings.Contains(out, "more lines") {
+		t.Errorf("last page:\n%s", out)
+	}
+
+	for _, bad := range []toolOutputInput{{}, {ID: "t2"}, {ID: "t1", Offset: 251}} {
+		if _, err := run(bad); err == nil {
+			t.Errorf("run(%+v) succeeded, want an error", bad)
+		}
+	}
+	// Outputs of other conversations aren't visible
+	tool.ConversationID = "c2"
+	if _, err := run(toolOutputInput{ID: "t1"}); err == nil {
+		t.Error("read another conversation's output")
+	}
+}
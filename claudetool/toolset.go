@@ -2,17 +2,25 @@ package claudetool
 
 import (
 	"context"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"shelley.exe.dev/claudetool/browse"
+	"shelley.exe.dev/codeindex"
+	"shelley.exe.dev/devcontainer"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/lsp"
 )
 
 // WorkingDir is a thread-safe mutable working directory.
 type MutableWorkingDir struct {
 	mu  sync.RWMutex
 	dir string
+	// translatePath converts paths given to tools to paths Shelley can
+	// open; nil to use them as they are.
+	translatePath func(string) string
 }
 
 // NewMutableWorkingDir creates a new MutableWorkingDir with the given initial directory.
@@ -34,6 +42,19 @@ func (w *MutableWorkingDir) Set(dir string) {
 	w.dir = dir
 }
 
+// Resolve returns the path a tool was given as an absolute path, relative
+// to the working directory unless it is absolute already, after translating
+// it with the tool set's TranslatePath.
+func (w *MutableWorkingDir) Resolve(path string) string {
+	if w.translatePath != nil {
+		path = w.translatePath(path)
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(w.Get(), path)
+}
+
 // ToolSetConfig contains configuration for creating a ToolSet.
 type ToolSetConfig struct {
 	// WorkingDir is the initial working directory for tools.
@@ -60,6 +81,56 @@ type ToolSetConfig struct {
 	// ConversationID is the ID of the conversation these tools belong to.
 	// This is exposed to bash commands via the SHELLEY_CONVERSATION_ID environment variable.
 	ConversationID string
+	// Env holds extra KEY=VALUE environment entries for bash commands.
+	Env []string
+	// MemoryStore stores memories across conversations.
+	// If set, the memory tool will be available.
+	MemoryStore MemoryStore
+	// CodeIndex is the semantic code index.
+	// If set, the semantic_search tool will be available.
+	CodeIndex *codeindex.Index
+	// LSP runs language servers.
+	// If set, the lsp_diagnostics, lsp_definition and lsp_references tools will be available.
+	LSP *lsp.Manager
+	// Devcontainers runs bash commands in the development container of the
+	// working directory's workspace, if it has one.
+	Devcontainers *devcontainer.Manager
+	// PlanMode restricts the tools to read-only exploration and adds the
+	// submit_plan tool, which saves the plan to PlanStore.
+	PlanMode bool
+	// PlanStore stores plans submitted in plan mode.
+	PlanStore PlanStore
+	// ReadOnly restricts the tools to those that don't modify files or
+	// change anything over the network, for conversations meant only for
+	// analysis. Unlike PlanMode, it adds no tools, and it also leaves out
+	// the browser.
+	ReadOnly bool
+	// ResourceLimits bound the resources of bash commands; nil for no limits.
+	ResourceLimits *ResourceLimits
+	// ApproveCommand, if set, is called before bash runs a risky command and
+	// keeps it from running unless the user approves it.
+	ApproveCommand ApprovalCallback
+	// TranslatePath, if set, converts the paths given to file tools and
+	// change_dir to paths Shelley can open, such as the Windows paths of
+	// the host when running in WSL.
+	TranslatePath func(string) string
+	// Location is the user's time zone, which the now tool tells the time
+	// in; nil for the server's.
+	Location *time.Location
+	// TodoStore stores the agent's task list.
+	// If set, the todo tool will be available.
+	TodoStore TodoStore
+	// ToolOutputStore stores the full output of truncated tool results.
+	// If set, the tool_output tool will be available.
+	ToolOutputStore ToolOutputStore
+	// HTTPTools are user-defined tools that call HTTP endpoints. They are left
+	// out in plan mode and read-only conversations, since they may change
+	// anything, and when a built-in tool has the same name.
+	HTTPTools []*HTTPTool
+	// PluginTools are tools loaded from WASM plugins. Like HTTPTools, they are
+	// left out in plan mode, in read-only conversations and when an earlier
+	// tool has the same name.
+	PluginTools []*llm.Tool
 }
 
 // ToolSet holds a set of tools for a single conversation.
@@ -100,12 +171,25 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		workingDir = "/"
 	}
 	wd := NewMutableWorkingDir(workingDir)
+	wd.translatePath = cfg.TranslatePath
 
 	bashTool := &BashTool{
 		WorkingDir:       wd,
 		LLMProvider:      cfg.LLMProvider,
 		EnableJITInstall: cfg.EnableJITInstall,
 		ConversationID:   cfg.ConversationID,
+		Env:              cfg.Env,
+		Devcontainers:    cfg.Devcontainers,
+		ApproveCommand:   cfg.ApproveCommand,
+		Limits:           cfg.ResourceLimits,
+	}
+	switch {
+	case cfg.ReadOnly:
+		bashTool.CheckPermission = checkReadOnlyCommand
+		bashTool.EnableJITInstall = false
+	case cfg.PlanMode:
+		bashTool.CheckPermission = checkPlanModeCommand
+		bashTool.EnableJITInstall = false
 	}
 
 	// Use simplified patch schema for weaker models, full schema for sonnet/opus
@@ -125,13 +209,19 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 
 	outputIframeTool := &OutputIframeTool{WorkingDir: wd}
 
+	runTestsTool := &RunTestsTool{Bash: bashTool}
+
+	nowTool := &NowTool{Location: cfg.Location}
+
 	tools := []*llm.Tool{
 		Think,
+		nowTool.Tool(),
 		bashTool.Tool(),
 		patchTool.Tool(),
 		keywordTool.Tool(),
 		changeDirTool.Tool(),
 		outputIframeTool.Tool(),
+		runTestsTool.Tool(),
 	}
 
 	// Add subagent tool if configured
@@ -145,8 +235,63 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		tools = append(tools, subagentTool.Tool())
 	}
 
+	if cfg.MemoryStore != nil {
+		memoryTool := &MemoryTool{Store: cfg.MemoryStore, WorkingDir: wd}
+		tools = append(tools, memoryTool.Tool())
+	}
+
+	if cfg.TodoStore != nil {
+		todoTool := &TodoTool{Store: cfg.TodoStore, ConversationID: cfg.ConversationID}
+		tools = append(tools, todoTool.Tool())
+	}
+
+	if cfg.ToolOutputStore != nil {
+		toolOutputTool := &ToolOutputTool{Store: cfg.ToolOutputStore, ConversationID: cfg.ConversationID}
+		tools = append(tools, toolOutputTool.Tool())
+	}
+
+	if cfg.CodeIndex != nil {
+		semanticSearchTool := &SemanticSearchTool{Index: cfg.CodeIndex, WorkingDir: wd}
+		tools = append(tools, semanticSearchTool.Tool())
+	}
+
+	if cfg.LSP != nil {
+		lspTools := &LSPTools{Manager: cfg.LSP, WorkingDir: wd}
+		tools = append(tools, lspTools.Tools()...)
+	}
+
+	if cfg.PlanMode || cfg.ReadOnly {
+		tools = withoutWriteTools(tools)
+	}
+	if cfg.PlanMode && cfg.PlanStore != nil {
+		submitPlanTool := &SubmitPlanTool{Store: cfg.PlanStore, ConversationID: cfg.ConversationID}
+		tools = append(tools, submitPlanTool.Tool())
+	}
+
+	if !cfg.PlanMode && !cfg.ReadOnly {
+		taken := make(map[string]bool, len(tools))
+		for _, tool := range tools {
+			taken[tool.Name] = true
+		}
+		for _, pluginTool := range cfg.PluginTools {
+			if taken[pluginTool.Name] {
+				continue
+			}
+			taken[pluginTool.Name] = true
+			tools = append(tools, pluginTool)
+		}
+		for _, httpTool := range cfg.HTTPTools {
+			if taken[httpTool.Name] {
+				continue
+			}
+			t := *httpTool
+			t.ConversationID = cfg.ConversationID
+			tools = append(tools, t.Tool())
+		}
+	}
+
 	var cleanup func()
-	if cfg.EnableBrowser {
+	if cfg.EnableBrowser && !cfg.ReadOnly {
 		// Get max image dimension from the LLM service
 		maxImageDimension := 0
 		if cfg.LLMProvider != nil && cfg.ModelID != "" {
@@ -2,6 +2,7 @@ package claudetool
 
 import (
 	"context"
+	"strings"
 	"testing"
 )
 
@@ -97,6 +98,31 @@ func TestToolSet_WorkingDir(t *testing.T) {
 	}
 }
 
+func TestToolSet_TranslatePath(t *testing.T) {
+	cfg := ToolSetConfig{
+		LLMProvider: &mockLLMProvider{},
+		ModelID:     "test-model",
+		WorkingDir:  "/test",
+		TranslatePath: func(p string) string {
+			if rest, ok := strings.CutPrefix(p, `C:\`); ok {
+				return "/mnt/c/" + strings.ReplaceAll(rest, `\`, "/")
+			}
+			return p
+		},
+	}
+	wd := NewToolSet(context.Background(), cfg).WorkingDir()
+
+	for in, want := range map[string]string{
+		`C:\src\main.go`: "/mnt/c/src/main.go",
+		"/etc/hosts":     "/etc/hosts",
+		"main.go":        "/test/main.go",
+	} {
+		if got := wd.Resolve(in); got != want {
+			t.Errorf("Resolve(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
 func TestToolSet_Cleanup(t *testing.T) {
 	provider := &mockLLMProvider{}
 
@@ -92,19 +92,31 @@ type conversationStateForTS struct {
 	ConversationID string `json:"conversation_id"`
 	Working        bool   `json:"working"`
 	Model          string `json:"model,omitempty"`
+	QueuePosition  int    `json:"queue_position,omitempty"`
 }
 
 type conversationWithStateForTS struct {
-	ConversationID       string  `json:"conversation_id"`
-	Slug                 *string `json:"slug"`
-	UserInitiated        bool    `json:"user_initiated"`
-	CreatedAt            string  `json:"created_at"`
-	UpdatedAt            string  `json:"updated_at"`
-	Cwd                  *string `json:"cwd"`
-	Archived             bool    `json:"archived"`
-	ParentConversationID *string `json:"parent_conversation_id"`
-	Model                *string `json:"model"`
-	Working              bool    `json:"working"`
+	ConversationID       string                    `json:"conversation_id"`
+	Slug                 *string                   `json:"slug"`
+	UserInitiated        bool                      `json:"user_initiated"`
+	CreatedAt            string                    `json:"created_at"`
+	UpdatedAt            string                    `json:"updated_at"`
+	Cwd                  *string                   `json:"cwd"`
+	Archived             bool                      `json:"archived"`
+	ParentConversationID *string                   `json:"parent_conversation_id"`
+	Model                *string                   `json:"model"`
+	Working              bool                      `json:"working"`
+	ProjectRoot          string                    `json:"project_root,omitempty"`
+	Summary              *conversationSummaryForTS `json:"summary,omitempty"`
+	UnreadCount          int                       `json:"unread_count,omitempty"`
+	ChangedFiles         []string                  `json:"changed_files,omitempty"`
+	Tags                 []string                  `json:"tags,omitempty"`
+}
+
+type conversationSummaryForTS struct {
+	Summary   string   `json:"summary"`
+	Files     []string `json:"files"`
+	UpdatedAt string   `json:"updated_at"`
 }
 
 type streamResponseForTS struct {
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFlags are the sections of the config file setting command-line
+// flags and environment variables. Flags given on the command line and
+// variables already set take precedence.
+type configFlags struct {
+	// Flags are global flags by name, e.g. {"db": "/var/lib/shelley/shelley.db"}
+	Flags map[string]any `json:"flags"`
+	// Serve are flags of the serve command by name, e.g. {"port": "8000", "idle-timeout": "1h"}
+	Serve map[string]any `json:"serve"`
+	// Env are environment variables such as ANTHROPIC_API_KEY
+	Env map[string]string `json:"env"`
+}
+
+// defaultConfigPath returns the config file used without -config:
+// shelley/config.json, config.yaml or config.yml in the user's config
+// directory, whichever exists first.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{"config.json", "config.yaml", "config.yml"} {
+		path := filepath.Join(dir, "shelley", name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// readConfigFile reads the config file as JSON. Files named *.yaml or *.yml
+// are YAML, with the same sections and keys as JSON, and are converted.
+func readConfigFile(configPath string) ([]byte, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if ext := filepath.Ext(configPath); ext != ".yaml" && ext != ".yml" {
+		return data, nil
+	}
+	var cfg map[string]any
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid YAML in %s: %w", configPath, err)
+	}
+	if cfg == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(cfg)
+}
+
+// readConfigFlags reads the flag and environment sections of the config file.
+// A missing file has none.
+func readConfigFlags(configPath string) (configFlags, error) {
+	var cfg configFlags
+	if configPath == "" {
+		return cfg, nil
+	}
+	data, err := readConfigFile(configPath)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config file %s: %w", configPath, err)
+	}
+	return cfg, nil
+}
+
+// commandLineFlags returns the names of the flags set on the command line.
+func commandLineFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+// applyConfigFlags sets the flags of fs that were not given on the command
+// line to their values in the config file, or back to their defaults if the
+// file no longer has them. Values are strings, numbers, booleans or lists,
+// which become comma-separated.
+func applyConfigFlags(fs *flag.FlagSet, values map[string]any, commandLine map[string]bool) error {
+	for name := range values {
+		if fs.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("unknown flag %q in config file", name)
+		}
+	}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || commandLine[f.Name] || f.Name == "config" {
+			return
+		}
+		value := f.DefValue
+		if v, ok := values[f.Name]; ok {
+			value = configFlagValue(v)
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value for flag %q in config file: %w", f.Name, setErr)
+		}
+	})
+	return err
+}
+
+func configFlagValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = configFlagValue(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// applyConfigEnv sets the environment variables of the config file that are
+// not set already.
+func applyConfigEnv(env map[string]string) {
+	for name, value := range env {
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+// reloadOnHangup calls reload whenever the process receives SIGHUP.
+func reloadOnHangup(logger *slog.Logger, reload func() error) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for range hangup {
+		if err := reload(); err != nil {
+			logger.Warn("Failed to reload config", "error", err)
+			continue
+		}
+		logger.Info("Reloaded config")
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,22 +1,46 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	// Conversations' time zones must load on machines without a zoneinfo
+	// database, such as Windows
+	_ "time/tzdata"
 
 	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/codeindex"
 	"shelley.exe.dev/db"
+	"shelley.exe.dev/devcontainer"
+	"shelley.exe.dev/email"
+	"shelley.exe.dev/eval"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/claudecode"
+	"shelley.exe.dev/llm/llmhttp"
+	"shelley.exe.dev/loop"
+	"shelley.exe.dev/lsp"
+	"shelley.exe.dev/mcp"
 	"shelley.exe.dev/models"
+	"shelley.exe.dev/plugins"
 	"shelley.exe.dev/server"
 	"shelley.exe.dev/templates"
+	"shelley.exe.dev/transcribe"
 	"shelley.exe.dev/version"
+	"shelley.exe.dev/webhook"
+	"shelley.exe.dev/webpush"
 )
 
 type GlobalConfig struct {
@@ -37,7 +61,7 @@ func main() {
 	flag.BoolVar(&global.Debug, "debug", false, "Enable debug logging")
 	flag.StringVar(&global.Model, "model", defaultModelID, "LLM model to use (use 'predictable' for testing)")
 	flag.BoolVar(&global.PredictableOnly, "predictable-only", false, "Use only the predictable service, ignoring all other models")
-	flag.StringVar(&global.ConfigPath, "config", "", "Path to shelley.json configuration file (optional)")
+	flag.StringVar(&global.ConfigPath, "config", "", "Path to the JSON or YAML configuration file (default: shelley/config.json, config.yaml or config.yml in the user config directory, if it exists)")
 	flag.StringVar(&global.DefaultModel, "default-model", defaultModelID, "Default model for web UI")
 
 	// Custom usage function
@@ -47,8 +71,13 @@ func main() {
 		flag.PrintDefaults()
 		fmt.Fprintf(flag.CommandLine.Output(), "\nCommands:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  serve [flags]                 Start the web server\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  mcp [flags]                   Serve Shelley's tools to an MCP client over stdin/stdout\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  doctor [flags]                Check the database, providers, git, disk space and UI, and suggest fixes\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  eval [flags] <task>...        Run evaluation tasks (YAML files or directories of them) and report pass/fail, tokens and cost per model\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  unpack-template <name> <dir>  Unpack a project template to a directory\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  upgrade [flags]               Replace this binary with the latest release\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  version                       Print version information as JSON\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  vapid-keys [subject]          Generate VAPID keys for the \"web_push\" config section\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "\nUse '%s <command> -h' for command-specific help\n", os.Args[0])
 	}
 
@@ -56,6 +85,22 @@ func main() {
 	flag.Parse()
 	args := flag.Args()
 
+	// The config file can set flags not given on the command line and
+	// environment variables
+	if global.ConfigPath == "" {
+		global.ConfigPath = defaultConfigPath()
+	}
+	configFile, err := readConfigFlags(global.ConfigPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := applyConfigFlags(flag.CommandLine, configFile.Flags, commandLineFlags(flag.CommandLine)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	applyConfigEnv(configFile.Env)
+
 	if len(args) == 0 {
 		flag.Usage()
 		os.Exit(1)
@@ -65,10 +110,20 @@ func main() {
 	switch command {
 	case "serve":
 		runServe(global, args[1:])
+	case "mcp":
+		runMCP(global, args[1:])
+	case "doctor":
+		runDoctor(global, args[1:])
+	case "eval":
+		runEval(global, args[1:])
 	case "unpack-template":
 		runUnpackTemplate(args[1:])
+	case "upgrade":
+		runUpgrade(args[1:])
 	case "version":
 		runVersion()
+	case "vapid-keys":
+		runVAPIDKeys(args[1:])
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		flag.Usage()
@@ -81,18 +136,73 @@ func runServe(global GlobalConfig, args []string) {
 	port := fs.String("port", "9000", "Port to listen on")
 	systemdActivation := fs.Bool("systemd-activation", false, "Use systemd socket activation (listen on fd from systemd)")
 	requireHeader := fs.String("require-header", "", "Require this header on all API requests (e.g., X-Exedev-Userid)")
+	admins := fs.String("admins", "", "Comma-separated -require-header values of the users allowed to use the admin API, such as the audit log")
+	checkMigrations := fs.Bool("check-migrations", false, "Report pending database migrations and exit without applying them (exit status 1 if the schema is not up to date)")
+	idleTimeout := fs.Duration("idle-timeout", server.DefaultIdleTimeout, "Release the resources of conversations idle for this long (0 to keep them); they are revived on the next message")
+	maxToolIterations := fs.Int("max-tool-iterations", server.DefaultLoopLimits.MaxToolIterations, "Pause a turn as stuck after this many rounds of tool calls for one message (0 for no limit); it can be resumed with guidance")
+	maxRepeatedToolCalls := fs.Int("max-repeated-tool-calls", server.DefaultLoopLimits.MaxRepeatedToolCalls, "Pause a turn as stuck after the agent makes the same tool calls with the same results this many times in a row (0 to disable)")
+	turnTimeout := fs.Duration("turn-timeout", 0, "End a turn that runs longer than this, keeping its partial results (0 for no limit); conversations can set their own")
+	toolTimeout := fs.Duration("tool-timeout", 0, "Stop a tool execution that runs longer than this, keeping its partial output (0 for no limit); conversations can set their own")
+	concurrentReadOnlyTools := fs.Bool("concurrent-read-only-tools", false, "Run the read-only tool calls of one LLM response, such as reads and searches, at once instead of one after another")
+	maxConcurrentTurns := fs.Int("max-concurrent-turns", 0, "Run turns of at most this many conversations at once (0 for no limit); the others wait in a queue where turns started by users go before batch tasks")
+	basePath := fs.String("base-path", "", "Serve the UI and API under this path prefix (e.g. /shelley/), for reverse proxies")
+	tlsCert := fs.String("tls-cert", "", "Serve HTTPS with this certificate file (requires -tls-key)")
+	tlsKey := fs.String("tls-key", "", "Private key file for -tls-cert")
+	autocertDomains := fs.String("autocert", "", "Serve HTTPS with certificates from Let's Encrypt for these comma-separated domains; they must reach this server on port 443")
+	autocertCache := fs.String("autocert-cache", "", "Directory for -autocert certificates (default: autocert/ next to the database)")
+	autocertEmail := fs.String("autocert-email", "", "Contact email for -autocert certificate problems (optional)")
+	responseCache := fs.Bool("response-cache", false, "Answer deterministic (temperature 0) LLM requests from a cache of earlier responses in the database, e.g. for repeated test or batch runs")
+	secretsKeychain := fs.Bool("secrets-keychain", false, "Keep the key encrypting stored secrets in the OS keychain; without it the key is derived from $SHELLEY_SECRETS_PASSPHRASE, or kept in the database if that is unset")
+	pluginsDir := fs.String("plugins-dir", "", "Load tools from the WebAssembly plugins (*.wasm) in this directory (default: plugins/ next to the database)")
+	containerExecutor := fs.String("container-executor", "", "Run bash commands in the workspace's development container (.devcontainer/devcontainer.json) with this container CLI, e.g. docker or podman")
+	workspacesDir := fs.String("workspaces-dir", "", "Clone repositories for new conversations into this directory (default: workspaces/ next to the database)")
+	toolCPUTime := fs.Duration("tool-cpu-time", 0, "Kill a process started by a bash command when it uses this much CPU time (0 for no limit)")
+	toolMemoryMB := fs.Int64("tool-memory-mb", 0, "Limit the memory of each bash command to this many MB: of all its processes with -tool-cgroup, of each process's address space otherwise (0 for no limit)")
+	toolMaxProcesses := fs.Int64("tool-max-processes", 0, "Limit each bash command to this many processes at once; requires -tool-cgroup (0 for no limit)")
+	toolOutputMB := fs.Int64("tool-output-mb", claudetool.DefaultResourceLimits.Output>>20, "Kill a bash command when it writes more than this many MB of output (0 for no limit)")
+	toolCgroup := fs.String("tool-cgroup", "", "Run each bash command in a cgroup of its own under this cgroup v2 directory delegated to Shelley (e.g. with systemd's Delegate=yes), enforcing -tool-memory-mb and -tool-max-processes")
+	instanceID := fs.String("instance-id", "", "Name of this instance when several share the database, e.g. the hostname; each conversation's agent runs on one instance at a time, which holds a lease on it (empty disables leasing)")
 	fs.Parse(args)
 
-	logger := setupLogging(global.Debug)
+	logger := setupLogging(os.Stdout, global.Debug)
+
+	commandLine := commandLineFlags(fs)
+	configFile, err := readConfigFlags(global.ConfigPath)
+	if err == nil {
+		err = applyConfigFlags(fs, configFile.Serve, commandLine)
+	}
+	if err != nil {
+		logger.Error("Invalid config file", "error", err)
+		os.Exit(1)
+	}
+
+	if *checkMigrations {
+		runCheckMigrations(global.DBPath, logger)
+		return
+	}
 
 	database := setupDatabase(global.DBPath, logger)
 	defer database.Close()
 
+	secretsConfig := db.SecretsConfig{Passphrase: os.Getenv("SHELLEY_SECRETS_PASSPHRASE"), Keychain: *secretsKeychain}
+	if err := database.InitSecrets(context.Background(), secretsConfig); err != nil {
+		logger.Error("Failed to initialize secrets store", "error", err)
+		os.Exit(1)
+	}
+
+	go offloadLargeMessages(database, logger)
+
 	// Set the database path for system prompt generation
 	server.DBPath = global.DBPath
 
 	// Build LLM configuration
 	llmConfig := buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, global.DefaultModel, database)
+	if *responseCache {
+		llmConfig.ResponseCache = true
+	}
+	if bridge := setupBridge(logger, global.ConfigPath, llmConfig); bridge != nil {
+		defer bridge.Close()
+	}
 
 	// Initialize LLM service manager (includes custom model support via database)
 	llmManager := server.NewLLMServiceManager(llmConfig)
@@ -102,11 +212,99 @@ func runServe(global GlobalConfig, args []string) {
 	logger.Info("Available models", "models", strings.Join(availableModels, ", "))
 
 	toolSetConfig := setupToolSetConfig(llmManager)
+	toolSetConfig.CodeIndex = setupCodeIndex(logger, global.ConfigPath, llmConfig, database)
+	toolSetConfig.LSP = setupLSP(logger, global.ConfigPath)
+	if toolSetConfig.LSP != nil {
+		defer toolSetConfig.LSP.Close()
+	}
+	toolSetConfig.ResourceLimits = &claudetool.ResourceLimits{
+		CPUTime:   *toolCPUTime,
+		Memory:    *toolMemoryMB << 20,
+		Processes: *toolMaxProcesses,
+		Output:    *toolOutputMB << 20,
+		Cgroup:    *toolCgroup,
+	}
+	if *containerExecutor != "" {
+		devcontainers := devcontainer.NewManager(*containerExecutor)
+		defer devcontainers.Close()
+		toolSetConfig.Devcontainers = devcontainers
+		logger.Info("Running commands in development containers", "runtime", *containerExecutor)
+	}
+	if *pluginsDir == "" {
+		*pluginsDir = filepath.Join(filepath.Dir(global.DBPath), "plugins")
+	}
+	if pluginHost := setupPlugins(logger, *pluginsDir); pluginHost != nil {
+		defer pluginHost.Close(context.Background())
+		toolSetConfig.PluginTools = pluginHost.Tools()
+	}
 
 	// Create server
 	svr := server.NewServer(database, llmManager, toolSetConfig, logger, global.PredictableOnly, llmConfig.TerminalURL, llmConfig.DefaultModel, *requireHeader, llmConfig.Links)
+	// applySettings applies the settings that reloading the config file can change
+	applySettings := func(llmCfg *server.LLMConfig) {
+		svr.ApplySettings(server.Settings{
+			DefaultModel: llmCfg.DefaultModel,
+			TerminalURL:  llmCfg.TerminalURL,
+			Links:        llmCfg.Links,
+			IdleTimeout:  *idleTimeout,
+			LoopLimits: loop.Limits{
+				MaxToolIterations:    *maxToolIterations,
+				MaxRepeatedToolCalls: *maxRepeatedToolCalls,
+				TurnTimeout:          *turnTimeout,
+				ToolTimeout:          *toolTimeout,
+			},
+			ConcurrentReadOnlyTools: *concurrentReadOnlyTools,
+			Webhooks:                setupNotificationWebhooks(logger, global.ConfigPath),
+			Admins:                  splitList(*admins),
+			PromptOverrides:         llmCfg.PromptOverrides,
+		})
+		svr.SetMaxConcurrentTurns(*maxConcurrentTurns)
+	}
+	applySettings(llmConfig)
+	var reloadMu sync.Mutex
+	reload := func() error {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+		configFile, err := readConfigFlags(global.ConfigPath)
+		if err != nil {
+			return err
+		}
+		if err := applyConfigFlags(fs, configFile.Serve, commandLine); err != nil {
+			return err
+		}
+		applySettings(buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, global.DefaultModel, database))
+		return nil
+	}
+	svr.SetConfigReloader(reload)
+	go reloadOnHangup(logger, reload)
+	svr.SetBasePath(*basePath)
+	if *workspacesDir == "" {
+		*workspacesDir = filepath.Join(filepath.Dir(global.DBPath), "workspaces")
+	}
+	svr.SetWorkspacesDir(*workspacesDir)
+	svr.SetInstanceID(*instanceID)
+	if transcriber := setupTranscriber(logger, global.ConfigPath, llmConfig); transcriber != nil {
+		svr.SetTranscriber(transcriber)
+	}
+	if pushSender := setupPushSender(logger, global.ConfigPath); pushSender != nil {
+		svr.SetPushSender(pushSender)
+	}
+	if emailNotifier := setupEmailNotifier(logger, global.ConfigPath); emailNotifier != nil {
+		svr.SetEmailNotifier(emailNotifier)
+	}
+	tlsConfig := server.TLSConfig{CertFile: *tlsCert, KeyFile: *tlsKey, AutocertEmail: *autocertEmail}
+	if *autocertDomains != "" {
+		tlsConfig.AutocertDomains = splitList(*autocertDomains)
+		tlsConfig.AutocertCacheDir = *autocertCache
+		if tlsConfig.AutocertCacheDir == "" {
+			tlsConfig.AutocertCacheDir = filepath.Join(filepath.Dir(global.DBPath), "autocert")
+		}
+	}
+	if err := svr.SetTLS(tlsConfig); err != nil {
+		logger.Error("Invalid TLS configuration", "error", err)
+		os.Exit(1)
+	}
 
-	var err error
 	if *systemdActivation {
 		listener, listenerErr := systemdListener()
 		if listenerErr != nil {
@@ -125,12 +323,12 @@ func runServe(global GlobalConfig, args []string) {
 	}
 }
 
-func setupLogging(debug bool) *slog.Logger {
+func setupLogging(w io.Writer, debug bool) *slog.Logger {
 	logLevel := slog.LevelInfo
 	if debug {
 		logLevel = slog.LevelDebug
 	}
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	logger := slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
 		Level: logLevel,
 	}))
 	slog.SetDefault(logger)
@@ -153,6 +351,283 @@ func setupDatabase(dbPath string, logger *slog.Logger) *db.DB {
 	return database
 }
 
+// offloadLargeMessages moves the large payloads of messages stored by older
+// releases into blob storage, in batches so that other writes can go between.
+func offloadLargeMessages(database *db.DB, logger *slog.Logger) {
+	total := 0
+	for {
+		n, err := database.OffloadLargeMessages(context.Background(), 100)
+		if err != nil {
+			logger.Warn("Failed to move large message payloads to blob storage", "error", err)
+			return
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+	}
+	if total > 0 {
+		logger.Info("Moved large message payloads to blob storage", "messages", total)
+	}
+}
+
+// runDoctor runs the self-checks and prints their results with fixes for the
+// problems found. It exits with status 1 if a check failed.
+func runDoctor(global GlobalConfig, args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Print the report as JSON")
+	secretsKeychain := fs.Bool("secrets-keychain", false, "The key encrypting stored secrets is in the OS keychain (see serve -secrets-keychain)")
+	fs.Parse(args)
+
+	logger := setupLogging(os.Stderr, global.Debug)
+	database, err := db.New(db.Config{DSN: global.DBPath})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open the database %s: %v\n", global.DBPath, err)
+		fmt.Fprintf(os.Stderr, "  fix: check the -db path and the permissions of its directory\n")
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	cfg := server.DoctorConfig{DB: database, DBPath: global.DBPath}
+	// Credentials and custom models saved at runtime are only read from an
+	// up-to-date database, and credentials only with the key encrypting them
+	llmDB := database
+	if status, err := database.CheckMigrations(ctx); err != nil || !status.UpToDate() {
+		llmDB = nil
+	} else {
+		secretsConfig := db.SecretsConfig{Passphrase: os.Getenv("SHELLEY_SECRETS_PASSPHRASE"), Keychain: *secretsKeychain}
+		if err := database.InitSecrets(ctx, secretsConfig); err != nil {
+			logger.Warn("Cannot decrypt stored provider credentials", "error", err)
+		}
+	}
+	llmManager := server.NewLLMServiceManager(buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, global.DefaultModel, llmDB))
+	if checker, ok := llmManager.(server.ProviderHealthChecker); ok {
+		cfg.Providers = checker
+	}
+	cfg.Models = llmManager.GetAvailableModels()
+
+	report := server.RunDoctor(ctx, cfg)
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		for _, c := range report.Checks {
+			line := fmt.Sprintf("%-6s %s", "["+c.Status+"]", c.Name)
+			if c.Detail != "" {
+				line += ": " + c.Detail
+			}
+			fmt.Println(line)
+			if c.Fix != "" {
+				fmt.Printf("       fix: %s\n", c.Fix)
+			}
+		}
+	}
+	if report.Status == server.CheckFail {
+		os.Exit(1)
+	}
+}
+
+// runCheckMigrations prints the migration status of the database and exits
+// with status 1 if it is not up to date
+func runCheckMigrations(dbPath string, logger *slog.Logger) {
+	database, err := db.New(db.Config{DSN: dbPath})
+	if err != nil {
+		logger.Error("Failed to initialize database", "error", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	status, err := database.CheckMigrations(context.Background())
+	if err != nil {
+		logger.Error("Failed to check database migrations", "error", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Schema version: %d (latest: %d)\n", status.Version, status.Latest)
+	if len(status.Pending) == 0 {
+		fmt.Println("Pending migrations: none")
+	} else {
+		fmt.Println("Pending migrations:")
+		for _, name := range status.Pending {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+	if len(status.Unknown) > 0 {
+		fmt.Printf("Migrations from a newer release: %v\n", status.Unknown)
+	}
+	if !status.UpToDate() {
+		database.Close()
+		os.Exit(1)
+	}
+}
+
+// runMCP serves Shelley's tools to an MCP client over stdin and stdout. The
+// tools run in the working directory with the same checks as in
+// conversations, e.g. bash refuses blind git adds.
+func runMCP(global GlobalConfig, args []string) {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	dir := fs.String("dir", "", "Working directory for the tools (default: the current directory)")
+	toolNames := fs.String("tools", strings.Join(mcp.DefaultTools, ","), "Comma-separated names of the tools to serve")
+	secretsKeychain := fs.Bool("secrets-keychain", false, "Keep the key encrypting stored secrets in the OS keychain (see serve -secrets-keychain)")
+	// Policies, as in a conversation's settings
+	readOnly := fs.Bool("read-only", false, "Serve only tools and commands that don't modify files or change anything over the network")
+	approveRisky := fs.Bool("approve-risky-commands", false, "Hold shell commands in a risk class, such as force pushes and recursive deletes, for approval; there is no one to approve them over MCP, so they are refused")
+	sandbox := fs.String("sandbox", "", "Sandbox of models that run tools themselves, such as Codex, when tools call them: "+strings.Join(llm.SandboxModes, ", "))
+	fs.Parse(args)
+
+	// stdout carries the protocol
+	logger := setupLogging(os.Stderr, global.Debug)
+
+	if *sandbox != "" && !slices.Contains(llm.SandboxModes, *sandbox) {
+		logger.Error("Unknown sandbox mode", "sandbox", *sandbox, "modes", llm.SandboxModes)
+		os.Exit(2)
+	}
+	if *readOnly {
+		if *sandbox != "" && *sandbox != llm.SandboxReadOnly {
+			logger.Error("The sandbox of a read-only server must be "+llm.SandboxReadOnly, "sandbox", *sandbox)
+			os.Exit(2)
+		}
+		*sandbox = llm.SandboxReadOnly
+	}
+	ctx := context.Background()
+	if *sandbox != "" {
+		ctx = llmhttp.WithSandbox(ctx, *sandbox)
+	}
+
+	database := setupDatabase(global.DBPath, logger)
+	defer database.Close()
+	secretsConfig := db.SecretsConfig{Passphrase: os.Getenv("SHELLEY_SECRETS_PASSPHRASE"), Keychain: *secretsKeychain}
+	if err := database.InitSecrets(context.Background(), secretsConfig); err != nil {
+		logger.Error("Failed to initialize secrets store", "error", err)
+		os.Exit(1)
+	}
+
+	llmConfig := buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, global.DefaultModel, database)
+	llmManager := server.NewLLMServiceManager(llmConfig)
+
+	toolSetConfig := setupToolSetConfig(llmManager)
+	toolSetConfig.EnableBrowser = false
+	if *dir != "" {
+		toolSetConfig.WorkingDir = *dir
+	}
+	toolSetConfig.ModelID = global.Model
+	toolSetConfig.ReadOnly = *readOnly
+	if *approveRisky {
+		toolSetConfig.ApproveCommand = refuseRiskyCommand
+	}
+	toolSetConfig.CodeIndex = setupCodeIndex(logger, global.ConfigPath, llmConfig, database)
+	toolSetConfig.LSP = setupLSP(logger, global.ConfigPath)
+	if toolSetConfig.LSP != nil {
+		defer toolSetConfig.LSP.Close()
+	}
+	toolSet := claudetool.NewToolSet(ctx, toolSetConfig)
+	defer toolSet.Cleanup()
+
+	var names []string
+	for _, name := range strings.Split(*toolNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	tools := mcp.FilterTools(toolSet.Tools(), names)
+	if len(tools) == 0 {
+		logger.Error("None of the tools are available", "tools", *toolNames)
+		os.Exit(1)
+	}
+
+	srv := mcp.NewServer(tools)
+	srv.Version = version.GetInfo().Version
+	srv.Logger = logger
+	if err := srv.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+		logger.Error("MCP server failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// refuseRiskyCommand is the approval callback of the mcp command, which has
+// no user to ask.
+func refuseRiskyCommand(ctx context.Context, req claudetool.CommandApproval) error {
+	return fmt.Errorf("this %s command needs approval, which is not possible over MCP; run it yourself if it is intended", req.Class)
+}
+
+// runEval runs evaluation tasks with each model and prints a report. It
+// exits with status 1 if a task did not pass.
+func runEval(global GlobalConfig, args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	modelList := fs.String("models", global.Model, "Comma-separated models to run the tasks with")
+	jsonOutput := fs.Bool("json", false, "Print the report as JSON")
+	keep := fs.Bool("keep", false, "Keep the working directories of the tasks for inspection")
+	secretsKeychain := fs.Bool("secrets-keychain", false, "Keep the key encrypting stored secrets in the OS keychain (see serve -secrets-keychain)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s eval [flags] <task.yaml|dir>...\n\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "A task file has a prompt, a check command that exits with status 0 if the\n")
+		fmt.Fprintf(fs.Output(), "task was done, and optionally a fixture directory copied to the agent's\n")
+		fmt.Fprintf(fs.Output(), "working directory, a name and a timeout.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// The report may go to stdout
+	logger := setupLogging(os.Stderr, global.Debug)
+
+	tasks, err := eval.LoadTasks(fs.Args())
+	if err != nil {
+		logger.Error("Failed to load eval tasks", "error", err)
+		os.Exit(1)
+	}
+	models := splitList(*modelList)
+	if len(models) == 0 {
+		logger.Error("No models to run the tasks with")
+		os.Exit(1)
+	}
+
+	database := setupDatabase(global.DBPath, logger)
+	defer database.Close()
+	secretsConfig := db.SecretsConfig{Passphrase: os.Getenv("SHELLEY_SECRETS_PASSPHRASE"), Keychain: *secretsKeychain}
+	if err := database.InitSecrets(context.Background(), secretsConfig); err != nil {
+		logger.Error("Failed to initialize secrets store", "error", err)
+		os.Exit(1)
+	}
+	llmManager := server.NewLLMServiceManager(buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, global.DefaultModel, database))
+	toolSetConfig := setupToolSetConfig(llmManager)
+	toolSetConfig.EnableBrowser = false
+
+	cfg := eval.Config{
+		Service: llmManager.GetService,
+		Tools: func(ctx context.Context, model, dir string) ([]*llm.Tool, func()) {
+			tsConfig := toolSetConfig
+			tsConfig.WorkingDir = dir
+			tsConfig.ModelID = model
+			toolSet := claudetool.NewToolSet(ctx, tsConfig)
+			return toolSet.Tools(), toolSet.Cleanup
+		},
+		SystemPrompt: server.GenerateSystemPrompt,
+		Limits:       server.DefaultLoopLimits,
+		KeepWorkDirs: *keep,
+		Logger:       logger,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report := eval.Run(ctx, cfg, tasks, models)
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+	if !report.Passed() {
+		database.Close()
+		os.Exit(1)
+	}
+}
+
 // runUnpackTemplate unpacks a project template to a directory
 func runUnpackTemplate(args []string) {
 	fs := flag.NewFlagSet("unpack-template", flag.ExitOnError)
@@ -226,18 +701,288 @@ func runVersion() {
 	}
 }
 
+// runUpgrade checks GitHub for a newer release and replaces the running
+// binary with it after verifying its checksum and signature
+func runUpgrade(args []string) {
+	fs := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	checkOnly := fs.Bool("check", false, "Only report whether an update is available; exit with status 1 if so")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	checker := server.NewVersionChecker()
+	info, err := checker.Check(ctx, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error checking for updates: %v\n", err)
+		os.Exit(1)
+	}
+	if info.Error != "" {
+		fmt.Fprintf(os.Stderr, "Error checking for updates: %s\n", info.Error)
+		os.Exit(1)
+	}
+	current := cmp.Or(info.CurrentTag, info.CurrentVersion)
+	if !info.HasUpdate {
+		fmt.Printf("Shelley %s is up to date\n", current)
+		return
+	}
+	fmt.Printf("Shelley %s is available (running %s)\n", info.LatestTag, current)
+	if *checkOnly {
+		os.Exit(1)
+	}
+
+	if err := checker.DoUpgrade(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Upgrade failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Upgraded to %s. Restart Shelley to apply.\n", info.LatestTag)
+}
+
+// runVAPIDKeys prints new VAPID keys for Web Push as the "web_push" section
+// of the config file.
+func runVAPIDKeys(args []string) {
+	subject := ""
+	if len(args) > 0 {
+		subject = args[0]
+	}
+	cfg, err := webpush.GenerateConfig(subject)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating VAPID keys: %v\n", err)
+		os.Exit(1)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(map[string]webpush.Config{"web_push": cfg})
+}
+
 func setupToolSetConfig(llmProvider claudetool.LLMServiceProvider) claudetool.ToolSetConfig {
 	wd, err := os.Getwd()
 	if err != nil {
 		// Fallback to "/" if we can't get working directory
 		wd = "/"
 	}
+	limits := claudetool.DefaultResourceLimits
 	return claudetool.ToolSetConfig{
 		WorkingDir:       wd,
 		LLMProvider:      llmProvider,
 		EnableJITInstall: claudetool.EnableBashToolJITInstall,
 		EnableBrowser:    true,
+		ResourceLimits:   &limits,
+	}
+}
+
+// setupCodeIndex creates the semantic code index, using the embedding provider
+// from the "embedding" section of the config file. It returns nil if the
+// provider cannot be configured.
+func setupCodeIndex(logger *slog.Logger, configPath string, llmCfg *server.LLMConfig, database *db.DB) *codeindex.Index {
+	var embeddingCfg codeindex.Config
+	if configPath != "" {
+		if data, err := readConfigFile(configPath); err == nil {
+			var cfg struct {
+				Embedding codeindex.Config `json:"embedding"`
+			}
+			if err := json.Unmarshal(data, &cfg); err == nil {
+				embeddingCfg = cfg.Embedding
+			}
+		}
+	}
+	embeddingCfg.APIKey = llmCfg.OpenAIAPIKey
+	if embeddingCfg.BaseURL == "" && llmCfg.Gateway != "" {
+		embeddingCfg.BaseURL = llmCfg.Gateway + "/_/gateway/openai/v1"
+	}
+
+	embedder, err := codeindex.NewEmbedder(embeddingCfg)
+	if err != nil {
+		logger.Warn("Semantic code search disabled", "error", err)
+		return nil
+	}
+	logger.Info("Using embedder for semantic code search", "embedder", embedder.Name())
+	return codeindex.New(database, embedder)
+}
+
+// setupTranscriber creates the speech-to-text provider for dictation from the
+// "transcription" section of the config file. It returns nil if there is none.
+func setupTranscriber(logger *slog.Logger, configPath string, llmCfg *server.LLMConfig) transcribe.Transcriber {
+	var transcribeCfg transcribe.Config
+	if configPath != "" {
+		if data, err := readConfigFile(configPath); err == nil {
+			var cfg struct {
+				Transcription transcribe.Config `json:"transcription"`
+			}
+			if err := json.Unmarshal(data, &cfg); err == nil {
+				transcribeCfg = cfg.Transcription
+			}
+		}
+	}
+	transcribeCfg.APIKey = llmCfg.OpenAIAPIKey
+	if transcribeCfg.BaseURL == "" && llmCfg.Gateway != "" {
+		transcribeCfg.BaseURL = llmCfg.Gateway + "/_/gateway/openai/v1"
+	}
+
+	transcriber, err := transcribe.New(transcribeCfg)
+	if err != nil {
+		logger.Info("Voice transcription disabled", "reason", err)
+		return nil
+	}
+	logger.Info("Using transcriber for voice input", "transcriber", transcriber.Name())
+	return transcriber
+}
+
+// setupPushSender creates the Web Push sender from the VAPID keys in the
+// "web_push" section of the config file. It returns nil if there are none.
+func setupPushSender(logger *slog.Logger, configPath string) *webpush.Sender {
+	if configPath == "" {
+		return nil
+	}
+	data, err := readConfigFile(configPath)
+	if err != nil {
+		return nil
+	}
+	var cfg struct {
+		WebPush *webpush.Config `json:"web_push"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil || cfg.WebPush == nil {
+		return nil
+	}
+	sender, err := webpush.NewSender(*cfg.WebPush)
+	if err != nil {
+		logger.Warn("Push notifications disabled", "error", err)
+		return nil
+	}
+	logger.Info("Push notifications enabled")
+	return sender
+}
+
+// setupEmailNotifier creates the email notifier from the "email" section of
+// the config file. The SMTP password may instead be set in SHELLEY_SMTP_PASSWORD.
+func setupEmailNotifier(logger *slog.Logger, configPath string) *email.Notifier {
+	if configPath == "" {
+		return nil
+	}
+	data, err := readConfigFile(configPath)
+	if err != nil {
+		return nil
+	}
+	var cfg struct {
+		Email *email.Config `json:"email"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil || cfg.Email == nil {
+		return nil
+	}
+	if cfg.Email.Password == "" {
+		cfg.Email.Password = os.Getenv("SHELLEY_SMTP_PASSWORD")
+	}
+	notifier, err := email.NewNotifier(*cfg.Email)
+	if err != nil {
+		logger.Warn("Email notifications disabled", "error", err)
+		return nil
+	}
+	logger.Info("Email notifications enabled", "to", cfg.Email.To)
+	return notifier
+}
+
+// setupNotificationWebhooks creates the webhook and Slack notification
+// channels from the "notifications" section of the config file.
+func setupNotificationWebhooks(logger *slog.Logger, configPath string) server.NotificationWebhooks {
+	var hooks server.NotificationWebhooks
+	if configPath == "" {
+		return hooks
+	}
+	data, err := readConfigFile(configPath)
+	if err != nil {
+		return hooks
+	}
+	var cfg struct {
+		Notifications *struct {
+			BaseURL string          `json:"base_url"`
+			Webhook *webhook.Config `json:"webhook"`
+			Slack   *webhook.Config `json:"slack"`
+		} `json:"notifications"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil || cfg.Notifications == nil {
+		return hooks
+	}
+	hooks.BaseURL = cfg.Notifications.BaseURL
+	if cfg.Notifications.Webhook != nil {
+		if hooks.Webhook, err = webhook.NewSender(*cfg.Notifications.Webhook); err != nil {
+			logger.Warn("Notification webhook disabled", "error", err)
+		}
+	}
+	if cfg.Notifications.Slack != nil {
+		if hooks.Slack, err = webhook.NewSender(*cfg.Notifications.Slack); err != nil {
+			logger.Warn("Slack notifications disabled", "error", err)
+		}
+	}
+	return hooks
+}
+
+// setupLSP creates the language server manager, using the "lsp_servers" section
+// of the config file or the default servers. It returns nil if none is installed.
+func setupLSP(logger *slog.Logger, configPath string) *lsp.Manager {
+	servers := lsp.DefaultServers
+	if configPath != "" {
+		if data, err := readConfigFile(configPath); err == nil {
+			var cfg struct {
+				LSPServers []lsp.ServerConfig `json:"lsp_servers"`
+			}
+			if err := json.Unmarshal(data, &cfg); err == nil && len(cfg.LSPServers) > 0 {
+				servers = cfg.LSPServers
+			}
+		}
 	}
+	manager := lsp.NewManager(servers)
+	installed := manager.Installed()
+	if len(installed) == 0 {
+		logger.Info("No language servers installed, LSP tools disabled")
+		return nil
+	}
+	logger.Info("Language servers available", "servers", strings.Join(installed, ", "))
+	return manager
+}
+
+// setupPlugins loads the WebAssembly plugins in dir. It returns nil if there
+// are none.
+func setupPlugins(logger *slog.Logger, dir string) *plugins.Host {
+	host, err := plugins.Load(context.Background(), dir, logger)
+	if err != nil {
+		logger.Warn("Failed to load plugins", "dir", dir, "error", err)
+		return nil
+	}
+	if len(host.Plugins()) == 0 {
+		return nil
+	}
+	return host
+}
+
+// setupBridge starts the Claude Code bridge if the "claude_code_bridge" section
+// of the config file has a command and $CLAUDE_CODE_BRIDGE_URL doesn't point
+// to a bridge already, and points llmCfg at it. It returns nil if no bridge
+// was started.
+func setupBridge(logger *slog.Logger, configPath string, llmCfg *server.LLMConfig) *claudecode.Supervisor {
+	if configPath == "" || llmCfg.ClaudeCodeBridgeURL != "" {
+		return nil
+	}
+	data, err := readConfigFile(configPath)
+	if err != nil {
+		return nil
+	}
+	var cfg struct {
+		Bridge claudecode.SupervisorConfig `json:"claude_code_bridge"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil || len(cfg.Bridge.Command) == 0 {
+		return nil
+	}
+
+	supervisor, err := claudecode.StartSupervisor(cfg.Bridge, logger)
+	if err != nil {
+		logger.Warn("Failed to start Claude Code bridge", "error", err)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := supervisor.WaitReady(ctx); err != nil {
+		logger.Warn("Claude Code bridge is not ready yet", "url", supervisor.URL(), "error", err)
+	}
+	llmCfg.ClaudeCodeBridgeURL = supervisor.URL()
+	return supervisor
 }
 
 // buildLLMConfig constructs LLMConfig from environment variables and optional config file
@@ -255,7 +1000,7 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 	}
 
 	if configPath != "" {
-		data, err := os.ReadFile(configPath)
+		data, err := readConfigFile(configPath)
 		if err != nil {
 			if !os.IsNotExist(err) {
 				logger.Warn("Failed to read config file", "path", configPath, "error", err)
@@ -264,10 +1009,13 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 		}
 
 		var cfg struct {
-			LLMGateway   string        `json:"llm_gateway"`
-			TerminalURL  string        `json:"terminal_url"`
-			DefaultModel string        `json:"default_model"`
-			Links        []server.Link `json:"links"`
+			LLMGateway      string                           `json:"llm_gateway"`
+			TerminalURL     string                           `json:"terminal_url"`
+			DefaultModel    string                           `json:"default_model"`
+			Links           []server.Link                    `json:"links"`
+			ThinkingBudgets map[string]int                   `json:"thinking_budgets"`
+			PromptOverrides map[string]server.PromptOverride `json:"prompt_overrides"`
+			ResponseCache   bool                             `json:"response_cache"`
 		}
 		if err := json.Unmarshal(data, &cfg); err != nil {
 			logger.Warn("Failed to parse config file", "path", configPath, "error", err)
@@ -311,6 +1059,23 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 			llmCfg.Links = cfg.Links
 			logger.Info("Loaded links from config", "count", len(cfg.Links))
 		}
+
+		// Load per-model extended thinking budgets from config file if present
+		if len(cfg.ThinkingBudgets) > 0 {
+			llmCfg.ThinkingBudgets = cfg.ThinkingBudgets
+			logger.Info("Loaded thinking budgets from config", "count", len(cfg.ThinkingBudgets))
+		}
+
+		// Load per-model changes of the system prompt from config file if present
+		if len(cfg.PromptOverrides) > 0 {
+			llmCfg.PromptOverrides = cfg.PromptOverrides
+			logger.Info("Loaded system prompt overrides from config", "count", len(cfg.PromptOverrides))
+		}
+
+		if cfg.ResponseCache {
+			llmCfg.ResponseCache = true
+			logger.Info("Caching responses to deterministic LLM requests")
+		}
 	}
 
 	return llmCfg
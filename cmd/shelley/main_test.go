@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"net"
@@ -232,3 +233,70 @@ func TestSystemdListenerIntegration(t *testing.T) {
 		t.Errorf("Unexpected status code %d, body: %s", resp.StatusCode, body)
 	}
 }
+
+func TestApplyConfigFlags(t *testing.T) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	port := fs.String("port", "9000", "")
+	idle := fs.Duration("idle-timeout", time.Minute, "")
+	admins := fs.String("admins", "", "")
+	turns := fs.Int("max-concurrent-turns", 0, "")
+	if err := fs.Parse([]string{"-port", "8000"}); err != nil {
+		t.Fatal(err)
+	}
+	commandLine := commandLineFlags(fs)
+
+	values := map[string]any{
+		"port":                 "7000",
+		"idle-timeout":         "1h",
+		"admins":               []any{"alice", "bob"},
+		"max-concurrent-turns": float64(1000000),
+	}
+	if err := applyConfigFlags(fs, values, commandLine); err != nil {
+		t.Fatal(err)
+	}
+	if *port != "8000" || *idle != time.Hour || *admins != "alice,bob" || *turns != 1000000 {
+		t.Errorf("flags = %s %s %s %d", *port, *idle, *admins, *turns)
+	}
+
+	// Flags removed from the config file go back to their defaults
+	if err := applyConfigFlags(fs, map[string]any{"admins": "carol"}, commandLine); err != nil {
+		t.Fatal(err)
+	}
+	if *idle != time.Minute || *admins != "carol" {
+		t.Errorf("after reload: idle-timeout = %s, admins = %s", *idle, *admins)
+	}
+
+	if err := applyConfigFlags(fs, map[string]any{"no-such-flag": true}, commandLine); err == nil {
+		t.Error("expected an error for an unknown flag")
+	}
+	if err := applyConfigFlags(fs, map[string]any{"idle-timeout": "soon"}, commandLine); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
+
+func TestReadConfigFlagsYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `flags:
+  db: /var/lib/shelley/shelley.db
+serve:
+  port: 8000
+  admins: [alice, bob]
+env:
+  ANTHROPIC_API_KEY: sk-test
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := readConfigFlags(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Flags["db"] != "/var/lib/shelley/shelley.db" || configFlagValue(cfg.Serve["port"]) != "8000" || configFlagValue(cfg.Serve["admins"]) != "alice,bob" || cfg.Env["ANTHROPIC_API_KEY"] != "sk-test" {
+		t.Errorf("config = %+v", cfg)
+	}
+
+	os.WriteFile(path, []byte("serve: [port"), 0o600)
+	if _, err := readConfigFlags(path); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+}
@@ -0,0 +1,285 @@
+// Package codeindex maintains a semantic index of repository source code.
+//
+// Files are split into overlapping chunks of lines, each chunk is embedded
+// with an Embedder, and the vectors are stored in the database. The index is
+// updated incrementally: only files whose content changed are re-embedded.
+package codeindex
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+)
+
+const (
+	// chunkLines is the number of lines in a chunk.
+	chunkLines = 60
+	// chunkOverlap is the number of lines shared by consecutive chunks.
+	chunkOverlap = 10
+	// maxFileSize skips larger files, which are usually generated or data.
+	maxFileSize = 256 * 1024
+	// maxFiles bounds the number of files indexed per repository.
+	maxFiles = 10000
+	// embedBatchSize is the number of chunks sent to the embedder at once.
+	embedBatchSize = 64
+)
+
+// Chunk is a range of lines in a file.
+type Chunk struct {
+	Path      string // relative to the repository root
+	StartLine int    // 1-based, inclusive
+	EndLine   int    // inclusive
+	Content   string
+}
+
+// Result is a chunk matching a search.
+type Result struct {
+	Chunk
+	Score float64
+}
+
+// Index is a semantic code index backed by the database.
+type Index struct {
+	db       *db.DB
+	embedder Embedder
+
+	mu    sync.Mutex
+	roots map[string]*sync.Mutex // serializes updates per repository
+}
+
+// New creates an Index.
+func New(database *db.DB, embedder Embedder) *Index {
+	return &Index{db: database, embedder: embedder, roots: make(map[string]*sync.Mutex)}
+}
+
+// Embedder returns the index's embedder.
+func (ix *Index) Embedder() Embedder {
+	return ix.embedder
+}
+
+func (ix *Index) rootLock(root string) *sync.Mutex {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	l, ok := ix.roots[root]
+	if !ok {
+		l = &sync.Mutex{}
+		ix.roots[root] = l
+	}
+	return l
+}
+
+// Update brings the index for the repository at root up to date and returns
+// the number of files that were (re)indexed.
+func (ix *Index) Update(ctx context.Context, root string) (int, error) {
+	lock := ix.rootLock(root)
+	lock.Lock()
+	defer lock.Unlock()
+
+	name := ix.embedder.Name()
+	if err := ix.db.DeleteCodeChunksForOtherEmbedders(ctx, root, name); err != nil {
+		return 0, err
+	}
+	indexed, err := ix.db.ListCodeIndexFiles(ctx, root, name)
+	if err != nil {
+		return 0, err
+	}
+
+	paths, err := listFiles(root)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		// Check the size before reading, so that large files are neither
+		// read nor kept in the index once they grow
+		full := filepath.Join(root, path)
+		if info, err := os.Stat(full); err != nil || info.Size() > maxFileSize {
+			continue
+		}
+		content, err := os.ReadFile(full)
+		if err != nil || len(content) > maxFileSize || bytes.IndexByte(content, 0) >= 0 {
+			continue
+		}
+		seen[path] = true
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+		if indexed[path] == hash {
+			continue
+		}
+		if err := ix.indexFile(ctx, root, path, hash, string(content)); err != nil {
+			return updated, fmt.Errorf("failed to index %s: %w", path, err)
+		}
+		updated++
+	}
+
+	// Drop files that no longer exist or are no longer indexable
+	for path := range indexed {
+		if !seen[path] {
+			if err := ix.db.ReplaceCodeChunks(ctx, root, path, nil); err != nil {
+				return updated, err
+			}
+		}
+	}
+	return updated, nil
+}
+
+func (ix *Index) indexFile(ctx context.Context, root, path, hash, content string) error {
+	chunks := ChunkFile(path, content)
+	params := make([]generated.InsertCodeChunkParams, 0, len(chunks))
+	for start := 0; start < len(chunks); start += embedBatchSize {
+		batch := chunks[start:min(start+embedBatchSize, len(chunks))]
+		texts := make([]string, len(batch))
+		for i, c := range batch {
+			texts[i] = c.Path + "\n" + c.Content
+		}
+		vectors, err := ix.embedder.Embed(ctx, texts)
+		if err != nil {
+			return err
+		}
+		for i, c := range batch {
+			params = append(params, generated.InsertCodeChunkParams{
+				Root:      root,
+				Path:      c.Path,
+				FileHash:  hash,
+				StartLine: int64(c.StartLine),
+				EndLine:   int64(c.EndLine),
+				Content:   c.Content,
+				Embedder:  ix.embedder.Name(),
+				Embedding: encodeVector(vectors[i]),
+			})
+		}
+	}
+	return ix.db.ReplaceCodeChunks(ctx, root, path, params)
+}
+
+// Search updates the index for root and returns the limit chunks most
+// similar to query, best first.
+func (ix *Index) Search(ctx context.Context, root, query string, limit int) ([]Result, error) {
+	if _, err := ix.Update(ctx, root); err != nil {
+		return nil, err
+	}
+	vectors, err := ix.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	q := vectors[0]
+
+	chunks, err := ix.db.ListCodeChunks(ctx, root, ix.embedder.Name())
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(chunks))
+	for _, c := range chunks {
+		results = append(results, Result{
+			Chunk: Chunk{Path: c.Path, StartLine: int(c.StartLine), EndLine: int(c.EndLine), Content: c.Content},
+			Score: dot(q, decodeVector(c.Embedding)),
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// ChunkFile splits a file into overlapping chunks of lines.
+func ChunkFile(path, content string) []Chunk {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if len(lines) == 1 && strings.TrimSpace(lines[0]) == "" {
+		return nil
+	}
+	var chunks []Chunk
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := min(start+chunkLines, len(lines))
+		text := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, Chunk{Path: path, StartLine: start + 1, EndLine: end, Content: text})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+// listFiles returns the files to index, relative to root: the files git
+// tracks or would track, or outside a repository every file not in a hidden
+// directory.
+func listFiles(root string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	if out, err := cmd.Output(); err == nil {
+		var paths []string
+		for _, p := range strings.Split(string(out), "\x00") {
+			if p != "" && len(paths) < maxFiles {
+				paths = append(paths, p)
+			}
+		}
+		return paths, nil
+	}
+
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(paths) >= maxFiles {
+			return filepath.SkipAll
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(root, path)
+			if err == nil {
+				paths = append(paths, rel)
+			}
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+	return b
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+// dot returns the dot product of two vectors, which is their cosine
+// similarity since embeddings are normalized.
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range min(len(a), len(b)) {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,143 @@
+package codeindex
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+)
+
+func setupTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.New(db.Config{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestChunkFile(t *testing.T) {
+	var lines []string
+	for i := 0; i < 125; i++ {
+		lines = append(lines, "line")
+	}
+	chunks := ChunkFile("a.go", strings.Join(lines, "\n")+"\n")
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	if chunks[0].StartLine != 1 || chunks[0].EndLine != 60 || chunks[1].StartLine != 51 || chunks[2].EndLine != 125 {
+		t.Errorf("unexpected chunk ranges: %+v", chunks)
+	}
+	if len(ChunkFile("empty", "\n")) != 0 {
+		t.Error("expected no chunks for an empty file")
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := strings.Join(terms("parseHTTPRequest(user_id, x)"), " ")
+	if got != "parse httprequest user id" {
+		t.Errorf("unexpected terms: %q", got)
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("auth.go", "func checkPassword(user, password string) bool {\n\treturn hashPassword(password) == user.passwordHash\n}\n")
+	write("render.go", "func renderTemplate(w io.Writer, page string) error {\n\treturn templates.Execute(w, page)\n}\n")
+	write("binary.dat", "\x00\x01\x02")
+
+	ix := New(setupTestDB(t), NewHashEmbedder())
+	if n, err := ix.Update(ctx, root); err != nil || n != 2 {
+		t.Fatalf("expected 2 files indexed, got %d, %v", n, err)
+	}
+	if n, err := ix.Update(ctx, root); err != nil || n != 0 {
+		t.Errorf("expected unchanged files to be skipped, got %d, %v", n, err)
+	}
+
+	results, err := ix.Search(ctx, root, "where is the password checked", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "auth.go" {
+		t.Fatalf("expected auth.go first, got %+v", results)
+	}
+
+	// Changed and removed files are reindexed
+	write("render.go", "func renderPassword() {}\n")
+	if err := os.Remove(filepath.Join(root, "auth.go")); err != nil {
+		t.Fatal(err)
+	}
+	results, err = ix.Search(ctx, root, "password", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "render.go" || !strings.Contains(results[0].Content, "renderPassword") {
+		t.Errorf("unexpected results after change: %+v", results)
+	}
+
+	// Files grown past the size limit are dropped
+	write("render.go", "func renderPassword() {}\n"+strings.Repeat("// padding\n", maxFileSize/10))
+	results, err = ix.Search(ctx, root, "password", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected the large file to be dropped, got %+v", results)
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		// Return the embeddings out of order to check they are matched by index
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,2]},{"index":0,"embedding":[3,4]}]}`))
+	}))
+	defer srv.Close()
+
+	e, err := NewEmbedder(Config{BaseURL: srv.URL + "/v1", APIKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Name() != "openai-text-embedding-3-small" {
+		t.Errorf("unexpected name: %q", e.Name())
+	}
+	vectors, err := e.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vectors[0][0] != 0.6 || vectors[0][1] != 0.8 || vectors[1][1] != 1 {
+		t.Errorf("expected normalized vectors in input order, got %v", vectors)
+	}
+
+	if _, err := NewEmbedder(Config{Provider: "openai"}); err == nil {
+		t.Error("expected error without an API key")
+	}
+	if e, err := NewEmbedder(Config{}); err != nil || e.Name() != "local-hash-512" {
+		t.Errorf("expected local embedder by default, got %v, %v", e, err)
+	}
+}
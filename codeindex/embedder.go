@@ -0,0 +1,216 @@
+package codeindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Embedder turns texts into vectors. Vectors from the same embedder can be
+// compared with cosine similarity.
+type Embedder interface {
+	// Name identifies the embedder and its model. Chunks indexed by a
+	// different embedder are reindexed.
+	Name() string
+	// Embed returns one vector per text.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Config selects and configures an embedding provider.
+type Config struct {
+	// Provider is "openai" for an OpenAI-compatible embeddings API, or "local"
+	// for the built-in hashing embedder. Empty selects openai when an API key
+	// is available and local otherwise.
+	Provider string `json:"provider"`
+	// Model is the embedding model (openai only).
+	Model string `json:"model"`
+	// BaseURL is the API base URL (openai only).
+	BaseURL string `json:"base_url"`
+	// APIKey authenticates with the API (openai only).
+	APIKey string `json:"-"`
+}
+
+// NewEmbedder creates the embedder described by cfg.
+func NewEmbedder(cfg Config) (Embedder, error) {
+	provider := cfg.Provider
+	if provider == "" {
+		provider = "local"
+		if cfg.APIKey != "" {
+			provider = "openai"
+		}
+	}
+	switch provider {
+	case "local":
+		return NewHashEmbedder(), nil
+	case "openai":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("openai embedding provider requires an API key")
+		}
+		e := &OpenAIEmbedder{BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: cfg.Model}
+		if e.BaseURL == "" {
+			e.BaseURL = "https://api.openai.com/v1"
+		}
+		if e.Model == "" {
+			e.Model = "text-embedding-3-small"
+		}
+		return e, nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider: %q", provider)
+	}
+}
+
+// hashDimensions is the vector size of the hashing embedder.
+const hashDimensions = 512
+
+// HashEmbedder is a local embedder that hashes identifier-like terms into a
+// fixed-size vector. It needs no network access and is much weaker than a
+// learned embedding model, but still ranks chunks sharing vocabulary with
+// the query first.
+type HashEmbedder struct{}
+
+// NewHashEmbedder returns a HashEmbedder.
+func NewHashEmbedder() *HashEmbedder {
+	return &HashEmbedder{}
+}
+
+// Name implements Embedder.
+func (e *HashEmbedder) Name() string {
+	return fmt.Sprintf("local-hash-%d", hashDimensions)
+}
+
+// Embed implements Embedder.
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, hashDimensions)
+		for _, term := range terms(text) {
+			h := fnv.New32a()
+			h.Write([]byte(term))
+			sum := h.Sum32()
+			sign := float32(1)
+			if sum&1 == 1 {
+				sign = -1
+			}
+			v[(sum>>1)%hashDimensions] += sign
+		}
+		normalize(v)
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+// terms splits text into lower-case terms, breaking identifiers at
+// underscores and camelCase boundaries.
+func terms(text string) []string {
+	var out []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 1 {
+			out = append(out, strings.ToLower(string(cur)))
+		}
+		cur = cur[:0]
+	}
+	var prev rune
+	for _, r := range text {
+		switch {
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush()
+			cur = append(cur, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			cur = append(cur, r)
+		default:
+			flush()
+		}
+		prev = r
+	}
+	flush()
+	return out
+}
+
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// OpenAIEmbedder uses an OpenAI-compatible /embeddings endpoint.
+type OpenAIEmbedder struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	HTTPClient *http.Client
+}
+
+// Name implements Embedder.
+func (e *OpenAIEmbedder) Name() string {
+	return "openai-" + e.Model
+}
+
+// Embed implements Embedder.
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": e.Model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(e.BaseURL, "/")+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.APIKey)
+
+	client := e.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding request failed: %s: %s", resp.Status, data)
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse embedding response: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Data))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index out of range: %d", d.Index)
+		}
+		normalize(d.Embedding)
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
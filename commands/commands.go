@@ -0,0 +1,173 @@
+// Package commands implements user-defined slash commands.
+//
+// A command is a markdown file in a .shelley/commands directory whose name
+// (without the .md extension) is the command name. Sending "/name args" as a
+// user message replaces the message with the file's body, with $ARGUMENTS
+// replaced by the whole argument string and $1 to $9 by individual arguments.
+//
+// The file may start with frontmatter:
+//
+//	---
+//	description: Review the current diff
+//	argument-hint: [focus area]
+//	---
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Command is a reusable prompt.
+type Command struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	ArgumentHint string `json:"argument_hint,omitempty"`
+	Path         string `json:"path"`
+	Body         string `json:"-"`
+}
+
+var (
+	nameRegexp     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+	placeholderReg = regexp.MustCompile(`\$(ARGUMENTS|[1-9])`)
+)
+
+// Parse reads a command file.
+func Parse(path string) (Command, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Command{}, err
+	}
+	cmd := Command{
+		Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Path: path,
+	}
+
+	body := string(content)
+	if rest, ok := strings.CutPrefix(body, "---\n"); ok {
+		if front, after, ok := strings.Cut(rest, "\n---"); ok {
+			for _, line := range strings.Split(front, "\n") {
+				key, value, ok := strings.Cut(line, ":")
+				if !ok {
+					continue
+				}
+				value = unquote(strings.TrimSpace(value))
+				switch strings.TrimSpace(key) {
+				case "description":
+					cmd.Description = value
+				case "argument-hint":
+					cmd.ArgumentHint = value
+				}
+			}
+			body = strings.TrimPrefix(strings.TrimPrefix(after, "\r"), "\n")
+		}
+	}
+	cmd.Body = strings.TrimSpace(body)
+
+	// Without a description, use the first line of the body
+	if cmd.Description == "" {
+		first, _, _ := strings.Cut(cmd.Body, "\n")
+		cmd.Description = strings.TrimSpace(strings.TrimLeft(first, "# "))
+	}
+	return cmd, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Dirs returns the command directories for a working directory, lowest
+// precedence first: ~/.config/shelley/commands, then each .shelley/commands
+// from gitRoot (or workingDir when gitRoot is empty) down to workingDir.
+func Dirs(workingDir, gitRoot string) []string {
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config", "shelley", "commands"))
+	}
+
+	var project []string
+	current := workingDir
+	for current != "" {
+		project = append(project, filepath.Join(current, ".shelley", "commands"))
+		if gitRoot == "" || current == gitRoot {
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+	for i := len(project) - 1; i >= 0; i-- {
+		dirs = append(dirs, project[i])
+	}
+	return dirs
+}
+
+// Discover loads the commands in dirs. A command in a later directory replaces
+// one with the same name in an earlier directory. The result is sorted by name.
+func Discover(dirs []string) []Command {
+	byName := make(map[string]Command)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+				continue
+			}
+			cmd, err := Parse(filepath.Join(dir, entry.Name()))
+			if err != nil || !nameRegexp.MatchString(cmd.Name) {
+				continue
+			}
+			byName[cmd.Name] = cmd
+		}
+	}
+
+	cmds := make([]Command, 0, len(byName))
+	for _, cmd := range byName {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// ParseInvocation splits a message of the form "/name args" into the command
+// name and its argument string.
+func ParseInvocation(message string) (name, args string, ok bool) {
+	rest, ok := strings.CutPrefix(message, "/")
+	if !ok {
+		return "", "", false
+	}
+	name = rest
+	if i := strings.IndexFunc(rest, unicode.IsSpace); i >= 0 {
+		name, args = rest[:i], rest[i:]
+	}
+	if !nameRegexp.MatchString(name) {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(args), true
+}
+
+// Expand returns the command body with its placeholders replaced by args.
+func (c Command) Expand(args string) string {
+	fields := strings.Fields(args)
+	return placeholderReg.ReplaceAllStringFunc(c.Body, func(m string) string {
+		if m == "$ARGUMENTS" {
+			return args
+		}
+		i := int(m[1] - '1')
+		if i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	})
+}
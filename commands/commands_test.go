@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "review.md")
+	writeFile(t, path, "---\ndescription: \"Review a file\"\nargument-hint: <file>\n---\nReview $1 carefully.\n")
+
+	cmd, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "review" || cmd.Description != "Review a file" || cmd.ArgumentHint != "<file>" {
+		t.Errorf("unexpected command: %+v", cmd)
+	}
+	if cmd.Body != "Review $1 carefully." {
+		t.Errorf("unexpected body: %q", cmd.Body)
+	}
+
+	plain := filepath.Join(dir, "plain.md")
+	writeFile(t, plain, "# Explain the build\n\nExplain how this project is built.")
+	cmd, err = Parse(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Description != "Explain the build" {
+		t.Errorf("expected description from first line, got %q", cmd.Description)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	cmd := Command{Body: "Fix issue $1 in $2. Full request: $ARGUMENTS. Missing: [$3]"}
+	got := cmd.Expand("123 parser.go")
+	want := "Fix issue 123 in parser.go. Full request: 123 parser.go. Missing: []"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseInvocation(t *testing.T) {
+	tests := []struct {
+		message, name, args string
+		ok                  bool
+	}{
+		{"/review main.go", "review", "main.go", true},
+		{"/review", "review", "", true},
+		{"/fix-bug\nmore details", "fix-bug", "more details", true},
+		{"/etc/passwd is odd", "", "", false},
+		{"hello /review", "", "", false},
+		{"/", "", "", false},
+	}
+	for _, tt := range tests {
+		name, args, ok := ParseInvocation(tt.message)
+		if name != tt.name || args != tt.args || ok != tt.ok {
+			t.Errorf("ParseInvocation(%q) = %q, %q, %v; want %q, %q, %v", tt.message, name, args, ok, tt.name, tt.args, tt.ok)
+		}
+	}
+}
+
+func TestDiscoverPrecedence(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	wd := filepath.Join(root, "sub")
+	writeFile(t, filepath.Join(root, ".shelley", "commands", "test.md"), "root test")
+	writeFile(t, filepath.Join(root, ".shelley", "commands", "lint.md"), "root lint")
+	writeFile(t, filepath.Join(wd, ".shelley", "commands", "test.md"), "sub test")
+	writeFile(t, filepath.Join(wd, ".shelley", "commands", "notes.txt"), "ignored")
+
+	cmds := Discover(Dirs(wd, root))
+	if len(cmds) != 2 {
+		t.Fatalf("expected 2 commands, got %+v", cmds)
+	}
+	if cmds[0].Name != "lint" || cmds[1].Name != "test" || cmds[1].Body != "sub test" {
+		t.Errorf("expected nested command to take precedence, got %+v", cmds)
+	}
+
+	// Without a git root only the working directory is searched
+	if cmds := Discover(Dirs(wd, "")); len(cmds) != 1 || cmds[0].Body != "sub test" {
+		t.Errorf("unexpected commands without git root: %+v", cmds)
+	}
+}
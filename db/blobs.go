@@ -0,0 +1,136 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// BlobSizeLimit is the size above which a message's llm_data or display_data
+// is compressed into message_blobs, leaving a reference in the message.
+const BlobSizeLimit = 64 << 10
+
+// blobRefPrefix starts a reference to a blob; DeleteUnreferencedMessageBlobs
+// depends on its length.
+const blobRefPrefix = `{"$blob":"`
+
+func blobRef(hash string) string {
+	return blobRefPrefix + hash + `"}`
+}
+
+// blobHash returns the hash a payload refers to, if it is a reference.
+func blobHash(data *string) (string, bool) {
+	if data == nil || !strings.HasPrefix(*data, blobRefPrefix) || !strings.HasSuffix(*data, `"}`) {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(*data, blobRefPrefix), `"}`), true
+}
+
+// storePayload moves a payload over BlobSizeLimit into message_blobs and
+// returns what the message column should hold instead.
+func storePayload(ctx context.Context, q *generated.Queries, data *string) (*string, error) {
+	if data == nil || len(*data) <= BlobSizeLimit {
+		return data, nil
+	}
+	sum := sha256.Sum256([]byte(*data))
+	hash := hex.EncodeToString(sum[:])
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, *data); err != nil {
+		return nil, fmt.Errorf("failed to compress message payload: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress message payload: %w", err)
+	}
+	if err := q.CreateMessageBlob(ctx, generated.CreateMessageBlobParams{
+		Hash: hash,
+		Data: buf.Bytes(),
+		Size: int64(len(*data)),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store message payload: %w", err)
+	}
+	ref := blobRef(hash)
+	return &ref, nil
+}
+
+// loadPayload replaces a reference to a blob with the payload.
+func loadPayload(ctx context.Context, q *generated.Queries, data **string) error {
+	hash, ok := blobHash(*data)
+	if !ok {
+		return nil
+	}
+	blob, err := q.GetMessageBlob(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("failed to load message payload %s: %w", hash, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(blob.Data))
+	if err != nil {
+		return fmt.Errorf("failed to decompress message payload %s: %w", hash, err)
+	}
+	payload, err := io.ReadAll(zr)
+	if err != nil {
+		return fmt.Errorf("failed to decompress message payload %s: %w", hash, err)
+	}
+	str := string(payload)
+	*data = &str
+	return nil
+}
+
+// LoadMessageBlobs fills in the payloads of messages that were moved to
+// message_blobs. The DB methods returning messages do this already; callers
+// listing messages with generated queries must call it themselves.
+func LoadMessageBlobs(ctx context.Context, q *generated.Queries, messages []generated.Message) error {
+	for i := range messages {
+		if err := loadMessageBlobs(ctx, q, &messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadMessageBlobs(ctx context.Context, q *generated.Queries, message *generated.Message) error {
+	if err := loadPayload(ctx, q, &message.LlmData); err != nil {
+		return err
+	}
+	return loadPayload(ctx, q, &message.DisplayData)
+}
+
+// OffloadLargeMessages moves the large payloads of up to limit messages
+// stored before message_blobs existed, returning how many it moved.
+func (db *DB) OffloadLargeMessages(ctx context.Context, limit int64) (int, error) {
+	var n int
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		rows, err := q.ListLargeMessages(ctx, generated.ListLargeMessagesParams{Size: BlobSizeLimit, Limit: limit})
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			llmData, err := storePayload(ctx, q, row.LlmData)
+			if err != nil {
+				return err
+			}
+			displayData, err := storePayload(ctx, q, row.DisplayData)
+			if err != nil {
+				return err
+			}
+			if err := q.UpdateMessagePayloads(ctx, generated.UpdateMessagePayloadsParams{
+				LlmData:     llmData,
+				DisplayData: displayData,
+				MessageID:   row.MessageID,
+			}); err != nil {
+				return err
+			}
+		}
+		n = len(rows)
+		return nil
+	})
+	return n, err
+}
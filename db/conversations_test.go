@@ -604,3 +604,35 @@ func TestConversationService_UpdateConversationCwd(t *testing.T) {
 		t.Errorf("Expected cwd %s, got %s", newCwd, *updatedConv.Cwd)
 	}
 }
+
+func TestToolOutputsPerConversation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	a, err := db.CreateConversation(ctx, stringPtr("tool-outputs-a"), true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := db.CreateConversation(ctx, stringPtr("tool-outputs-b"), true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both conversations have a tool result with the same ID
+	if err := db.SaveToolOutput(ctx, a.ConversationID, "toolu_1", "output of a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveToolOutput(ctx, b.ConversationID, "toolu_1", "output of b"); err != nil {
+		t.Fatal(err)
+	}
+	for conversationID, want := range map[string]string{a.ConversationID: "output of a", b.ConversationID: "output of b"} {
+		output, err := db.GetToolOutput(ctx, conversationID, "toolu_1")
+		if err != nil || output == nil || output.Output != want {
+			t.Errorf("GetToolOutput(%s) = %+v, %v; want %q", conversationID, output, err, want)
+		}
+	}
+	if output, err := db.GetToolOutput(ctx, a.ConversationID, "toolu_2"); err != nil || output != nil {
+		t.Errorf("expected no output for an unknown tool use, got %+v, %v", output, err)
+	}
+}
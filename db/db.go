@@ -18,9 +18,12 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/secrets"
 
 	_ "modernc.org/sqlite"
 )
@@ -41,6 +44,9 @@ func generateConversationID() (string, error) {
 // DB wraps the database connection pool and provides high-level operations
 type DB struct {
 	pool *Pool
+
+	secretsMu sync.Mutex
+	secrets   *secrets.Cipher // set by InitSecrets, or on first use
 }
 
 // Config holds database configuration
@@ -91,17 +97,25 @@ func (db *DB) Close() error {
 	return db.pool.Close()
 }
 
-// Migrate runs the database migrations
-func (db *DB) Migrate(ctx context.Context) error {
+// migrationPattern matches migration filenames, e.g. "001-base.sql"
+var migrationPattern = regexp.MustCompile(`^(\d{3})-.*\.sql$`)
+
+// migrationFile is a migration embedded in the binary
+type migrationFile struct {
+	number int
+	name   string
+}
+
+// schemaMigrations returns the embedded migrations in order
+func schemaMigrations() ([]migrationFile, error) {
 	// Read all migration files
 	entries, err := schemaFS.ReadDir("schema")
 	if err != nil {
-		return fmt.Errorf("failed to read schema directory: %w", err)
+		return nil, fmt.Errorf("failed to read schema directory: %w", err)
 	}
 
 	// Filter and validate migration files
-	var migrations []string
-	migrationPattern := regexp.MustCompile(`^(\d{3})-.*\.sql$`)
+	var names []string
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
@@ -109,30 +123,40 @@ func (db *DB) Migrate(ctx context.Context) error {
 		if !migrationPattern.MatchString(entry.Name()) {
 			continue
 		}
-		migrations = append(migrations, entry.Name())
+		names = append(names, entry.Name())
 	}
 
 	// Sort migrations by number
-	sort.Strings(migrations)
-
-	// Check for duplicate migration numbers
-	seenNumbers := make(map[string]string) // number -> filename
-	for _, migration := range migrations {
-		matches := migrationPattern.FindStringSubmatch(migration)
-		if len(matches) < 2 {
-			continue
+	sort.Strings(names)
+
+	// Check for duplicate migration numbers and extract them from the
+	// filenames (e.g., "001-base.sql" -> 001)
+	seenNumbers := make(map[int]string) // number -> filename
+	migrations := make([]migrationFile, 0, len(names))
+	for _, name := range names {
+		matches := migrationPattern.FindStringSubmatch(name)
+		if len(matches) != 2 {
+			return nil, fmt.Errorf("invalid migration filename format: %s", name)
+		}
+		num, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse migration number from %s: %w", name, err)
 		}
-		num := matches[1]
 		if existing, ok := seenNumbers[num]; ok {
-			return fmt.Errorf("duplicate migration number %s: %s and %s", num, existing, migration)
+			return nil, fmt.Errorf("duplicate migration number %s: %s and %s", matches[1], existing, name)
 		}
-		seenNumbers[num] = migration
+		seenNumbers[num] = name
+		migrations = append(migrations, migrationFile{number: num, name: name})
 	}
+	return migrations, nil
+}
 
-	// Get executed migrations
+// appliedMigrations returns the numbers of the migrations recorded in the
+// migrations table, which is empty for a new database
+func (db *DB) appliedMigrations(ctx context.Context) (map[int]bool, error) {
 	executedMigrations := make(map[int]bool)
 	var tableName string
-	err = db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		row := rx.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name='migrations'")
 		return row.Scan(&tableName)
 	})
@@ -156,34 +180,122 @@ func (db *DB) Migrate(ctx context.Context) error {
 			return rows.Err()
 		})
 		if err != nil {
-			return fmt.Errorf("failed to load executed migrations: %w", err)
+			return nil, fmt.Errorf("failed to load executed migrations: %w", err)
 		}
 	} else if !errors.Is(err, sql.ErrNoRows) {
 		// Migrations table doesn't exist - executedMigrations remains empty
 		slog.Info("migrations table not found, running all migrations")
 	}
+	return executedMigrations, nil
+}
 
-	// Run any migrations that haven't been executed
+// MigrationStatus describes the schema of a database relative to the
+// migrations embedded in this binary
+type MigrationStatus struct {
+	// Version is the number of the latest applied migration, 0 for a new database
+	Version int
+	// Latest is the number of the latest migration in this binary
+	Latest int
+	// Pending are the filenames of the migrations not applied yet, in order
+	Pending []string
+	// Unknown are applied migration numbers missing from this binary,
+	// meaning the database was used by a newer release
+	Unknown []int
+}
+
+// UpToDate reports whether the database has exactly the migrations of this binary
+func (s *MigrationStatus) UpToDate() bool {
+	return len(s.Pending) == 0 && len(s.Unknown) == 0
+}
+
+// CheckMigrations compares the applied migrations with the embedded ones without changing the database
+func (db *DB) CheckMigrations(ctx context.Context) (*MigrationStatus, error) {
+	migrations, err := schemaMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{}
+	known := make(map[int]bool, len(migrations))
 	for _, migration := range migrations {
-		// Extract migration number from filename (e.g., "001-base.sql" -> 001)
-		matches := migrationPattern.FindStringSubmatch(migration)
-		if len(matches) != 2 {
-			return fmt.Errorf("invalid migration filename format: %s", migration)
+		known[migration.number] = true
+		status.Latest = max(status.Latest, migration.number)
+		if !applied[migration.number] {
+			status.Pending = append(status.Pending, migration.name)
+		}
+	}
+	for number := range applied {
+		status.Version = max(status.Version, number)
+		if !known[number] {
+			status.Unknown = append(status.Unknown, number)
 		}
+	}
+	sort.Ints(status.Unknown)
+	return status, nil
+}
 
-		migrationNumber, err := strconv.Atoi(matches[1])
+// QuickCheck runs SQLite's quick_check and returns the problems it found,
+// none if the database is intact
+func (db *DB) QuickCheck(ctx context.Context) ([]string, error) {
+	var problems []string
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		rows, err := rx.Query("PRAGMA quick_check;")
 		if err != nil {
-			return fmt.Errorf("failed to parse migration number from %s: %w", migration, err)
+			return err
 		}
+		defer rows.Close()
+		for rows.Next() {
+			var problem string
+			if err := rows.Scan(&problem); err != nil {
+				return err
+			}
+			if problem != "ok" {
+				problems = append(problems, problem)
+			}
+		}
+		return rows.Err()
+	})
+	return problems, err
+}
+
+// Migrate runs the database migrations that haven't been applied yet, in order
+func (db *DB) Migrate(ctx context.Context) error {
+	migrations, err := schemaMigrations()
+	if err != nil {
+		return err
+	}
+	executedMigrations, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
 
-		if !executedMigrations[migrationNumber] {
-			slog.Info("running migration", "file", migration, "number", migrationNumber)
-			if err := db.runMigration(ctx, migration, migrationNumber); err != nil {
+	// Run any migrations that haven't been executed
+	known := make(map[int]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.number] = true
+		if !executedMigrations[migration.number] {
+			slog.Info("running migration", "file", migration.name, "number", migration.number)
+			if err := db.runMigration(ctx, migration.name, migration.number); err != nil {
 				return err
 			}
 		}
 	}
 
+	var unknown []int
+	for number := range executedMigrations {
+		if !known[number] {
+			unknown = append(unknown, number)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Ints(unknown)
+		slog.Warn("database has migrations unknown to this binary; it was used by a newer release", "migrations", unknown)
+	}
+
 	return nil
 }
 
@@ -300,6 +412,19 @@ func (db *DB) ListConversations(ctx context.Context, limit, offset int64) ([]gen
 	return conversations, err
 }
 
+// ListQuickSearchConversations returns the most recently updated top-level
+// conversations with how many messages the user sent in each
+func (db *DB) ListQuickSearchConversations(ctx context.Context, limit int64) ([]generated.ListQuickSearchConversationsRow, error) {
+	var conversations []generated.ListQuickSearchConversationsRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		conversations, err = q.ListQuickSearchConversations(ctx, limit)
+		return err
+	})
+	return conversations, err
+}
+
 // SearchConversations searches for conversations containing the given query in their slug
 func (db *DB) SearchConversations(ctx context.Context, query string, limit, offset int64) ([]generated.Conversation, error) {
 	queryPtr := &query
@@ -453,17 +578,28 @@ func (db *DB) CreateMessage(ctx context.Context, params CreateMessageParams) (*g
 			return fmt.Errorf("failed to get next sequence ID: %w", err)
 		}
 
+		// Large payloads are stored in message_blobs
+		llmData, err := storePayload(ctx, q, llmDataJSON)
+		if err != nil {
+			return err
+		}
+		displayData, err := storePayload(ctx, q, displayDataJSON)
+		if err != nil {
+			return err
+		}
+
 		message, err = q.CreateMessage(ctx, generated.CreateMessageParams{
 			MessageID:           messageID,
 			ConversationID:      params.ConversationID,
 			SequenceID:          sequenceID,
 			Type:                string(params.Type),
-			LlmData:             llmDataJSON,
+			LlmData:             llmData,
 			UserData:            userDataJSON,
 			UsageData:           usageDataJSON,
-			DisplayData:         displayDataJSON,
+			DisplayData:         displayData,
 			ExcludedFromContext: params.ExcludedFromContext,
 		})
+		message.LlmData, message.DisplayData = llmDataJSON, displayDataJSON
 		return err
 	})
 	return &message, err
@@ -476,7 +612,10 @@ func (db *DB) GetMessageByID(ctx context.Context, messageID string) (*generated.
 		q := generated.New(rx.Conn())
 		var err error
 		message, err = q.GetMessage(ctx, messageID)
-		return err
+		if err != nil {
+			return err
+		}
+		return loadMessageBlobs(ctx, q, &message)
 	})
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("message not found: %s", messageID)
@@ -495,7 +634,10 @@ func (db *DB) ListMessagesByConversationPaginated(ctx context.Context, conversat
 			Limit:          limit,
 			Offset:         offset,
 		})
-		return err
+		if err != nil {
+			return err
+		}
+		return LoadMessageBlobs(ctx, q, messages)
 	})
 	return messages, err
 }
@@ -507,7 +649,10 @@ func (db *DB) ListMessages(ctx context.Context, conversationID string) ([]genera
 		q := generated.New(rx.Conn())
 		var err error
 		messages, err = q.ListMessages(ctx, conversationID)
-		return err
+		if err != nil {
+			return err
+		}
+		return LoadMessageBlobs(ctx, q, messages)
 	})
 	return messages, err
 }
@@ -519,7 +664,10 @@ func (db *DB) ListMessagesForContext(ctx context.Context, conversationID string)
 		q := generated.New(rx.Conn())
 		var err error
 		messages, err = q.ListMessagesForContext(ctx, conversationID)
-		return err
+		if err != nil {
+			return err
+		}
+		return LoadMessageBlobs(ctx, q, messages)
 	})
 	return messages, err
 }
@@ -534,11 +682,34 @@ func (db *DB) ListMessagesByType(ctx context.Context, conversationID string, mes
 			ConversationID: conversationID,
 			Type:           string(messageType),
 		})
-		return err
+		if err != nil {
+			return err
+		}
+		return LoadMessageBlobs(ctx, q, messages)
 	})
 	return messages, err
 }
 
+// ListMessagesForAnalytics returns the user and agent messages of all conversations created since a time
+func (db *DB) ListMessagesForAnalytics(ctx context.Context, since time.Time) ([]generated.ListMessagesForAnalyticsRow, error) {
+	var rows []generated.ListMessagesForAnalyticsRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		rows, err = q.ListMessagesForAnalytics(ctx, since.UTC())
+		if err != nil {
+			return err
+		}
+		for i := range rows {
+			if err := loadPayload(ctx, q, &rows[i].LlmData); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return rows, err
+}
+
 // GetLatestMessage retrieves the latest message in a conversation
 func (db *DB) GetLatestMessage(ctx context.Context, conversationID string) (*generated.Message, error) {
 	var message generated.Message
@@ -546,7 +717,10 @@ func (db *DB) GetLatestMessage(ctx context.Context, conversationID string) (*gen
 		q := generated.New(rx.Conn())
 		var err error
 		message, err = q.GetLatestMessage(ctx, conversationID)
-		return err
+		if err != nil {
+			return err
+		}
+		return loadMessageBlobs(ctx, q, &message)
 	})
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("no messages found in conversation: %s", conversationID)
@@ -569,143 +743,1094 @@ func (db *DB) CountMessagesByType(ctx context.Context, conversationID string, me
 	return count, err
 }
 
-// Queries provides read-only access to generated queries within a read transaction
-func (db *DB) Queries(ctx context.Context, fn func(*generated.Queries) error) error {
-	return db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+// ListConversationDirectories returns the additional directories registered for a conversation
+func (db *DB) ListConversationDirectories(ctx context.Context, conversationID string) ([]string, error) {
+	var dirs []string
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
-		return fn(q)
+		var err error
+		dirs, err = q.ListConversationDirectories(ctx, conversationID)
+		return err
 	})
+	return dirs, err
 }
 
-// QueriesTx provides read-write access to generated queries within a write transaction
-func (db *DB) QueriesTx(ctx context.Context, fn func(*generated.Queries) error) error {
+// AddConversationDirectory registers an additional directory for a conversation
+func (db *DB) AddConversationDirectory(ctx context.Context, conversationID, path string) error {
 	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
-		return fn(q)
+		return q.AddConversationDirectory(ctx, generated.AddConversationDirectoryParams{
+			ConversationID: conversationID,
+			Path:           path,
+		})
 	})
 }
 
-// ListArchivedConversations retrieves archived conversations with pagination
-func (db *DB) ListArchivedConversations(ctx context.Context, limit, offset int64) ([]generated.Conversation, error) {
-	var conversations []generated.Conversation
-	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
-		q := generated.New(rx.Conn())
-		var err error
-		conversations, err = q.ListArchivedConversations(ctx, generated.ListArchivedConversationsParams{
-			Limit:  limit,
-			Offset: offset,
+// RemoveConversationDirectory unregisters an additional directory from a conversation
+func (db *DB) RemoveConversationDirectory(ctx context.Context, conversationID, path string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.RemoveConversationDirectory(ctx, generated.RemoveConversationDirectoryParams{
+			ConversationID: conversationID,
+			Path:           path,
 		})
-		return err
 	})
-	return conversations, err
 }
 
-// SearchArchivedConversations searches for archived conversations containing the given query in their slug
-func (db *DB) SearchArchivedConversations(ctx context.Context, query string, limit, offset int64) ([]generated.Conversation, error) {
-	queryPtr := &query
-	var conversations []generated.Conversation
+// ListConversationEnv returns the environment variables set for a conversation,
+// with secret values decrypted
+func (db *DB) ListConversationEnv(ctx context.Context, conversationID string) ([]generated.ConversationEnv, error) {
+	var env []generated.ConversationEnv
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
-		conversations, err = q.SearchArchivedConversations(ctx, generated.SearchArchivedConversationsParams{
-			Column1: queryPtr,
-			Limit:   limit,
-			Offset:  offset,
-		})
+		env, err = q.ListConversationEnv(ctx, conversationID)
 		return err
 	})
-	return conversations, err
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range env {
+		if !v.Encrypted {
+			continue
+		}
+		c, err := db.secretsCipher(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if env[i].Value, err = c.Open(v.Value); err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", v.Name, err)
+		}
+		env[i].Encrypted = false
+	}
+	return env, nil
 }
 
-// ArchiveConversation archives a conversation
-func (db *DB) ArchiveConversation(ctx context.Context, conversationID string) (*generated.Conversation, error) {
-	var conversation generated.Conversation
-	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+// SetConversationEnv sets or replaces an environment variable for a conversation.
+// Secret values are stored encrypted.
+func (db *DB) SetConversationEnv(ctx context.Context, conversationID, name, value string, secret bool) error {
+	if secret {
+		c, err := db.secretsCipher(ctx)
+		if err != nil {
+			return err
+		}
+		if value, err = c.Seal(value); err != nil {
+			return err
+		}
+	}
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetConversationEnv(ctx, generated.SetConversationEnvParams{
+			ConversationID: conversationID,
+			Name:           name,
+			Value:          value,
+			Secret:         secret,
+			Encrypted:      secret,
+		})
+	})
+}
+
+// UnsetConversationEnv removes an environment variable from a conversation
+func (db *DB) UnsetConversationEnv(ctx context.Context, conversationID, name string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
+		return q.UnsetConversationEnv(ctx, generated.UnsetConversationEnvParams{
+			ConversationID: conversationID,
+			Name:           name,
+		})
+	})
+}
+
+// ListConversationHooks returns the hooks configured for a conversation in creation order
+func (db *DB) ListConversationHooks(ctx context.Context, conversationID string) ([]generated.ConversationHook, error) {
+	var hooks []generated.ConversationHook
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
 		var err error
-		conversation, err = q.ArchiveConversation(ctx, conversationID)
+		hooks, err = q.ListConversationHooks(ctx, conversationID)
 		return err
 	})
-	return &conversation, err
+	return hooks, err
 }
 
-// UnarchiveConversation unarchives a conversation
-func (db *DB) UnarchiveConversation(ctx context.Context, conversationID string) (*generated.Conversation, error) {
-	var conversation generated.Conversation
+// AddConversationHook adds a hook to a conversation
+func (db *DB) AddConversationHook(ctx context.Context, params generated.AddConversationHookParams) (*generated.ConversationHook, error) {
+	var hook generated.ConversationHook
 	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
 		var err error
-		conversation, err = q.UnarchiveConversation(ctx, conversationID)
+		hook, err = q.AddConversationHook(ctx, params)
 		return err
 	})
-	return &conversation, err
+	if err != nil {
+		return nil, err
+	}
+	return &hook, nil
 }
 
-// DeleteConversation deletes a conversation and all its messages
-func (db *DB) DeleteConversation(ctx context.Context, conversationID string) error {
+// RemoveConversationHook removes a hook from a conversation
+func (db *DB) RemoveConversationHook(ctx context.Context, conversationID string, hookID int64) error {
 	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
-		// Delete messages first (foreign key constraint)
-		if err := q.DeleteConversationMessages(ctx, conversationID); err != nil {
-			return fmt.Errorf("failed to delete messages: %w", err)
-		}
-		return q.DeleteConversation(ctx, conversationID)
+		return q.RemoveConversationHook(ctx, generated.RemoveConversationHookParams{
+			ConversationID: conversationID,
+			HookID:         hookID,
+		})
 	})
 }
 
-// CreateSubagentConversation creates a new subagent conversation with a parent
-func (db *DB) CreateSubagentConversation(ctx context.Context, slug, parentID string, cwd *string) (*generated.Conversation, error) {
-	conversationID, err := generateConversationID()
+// Conversation plan modes
+const (
+	PlanModePlan    = "plan"
+	PlanModeExecute = "execute"
+)
+
+// GetConversationPlan returns a conversation's plan state, or nil if plan mode was never used
+func (db *DB) GetConversationPlan(ctx context.Context, conversationID string) (*generated.ConversationPlan, error) {
+	var plan generated.ConversationPlan
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		plan, err = q.GetConversationPlan(ctx, conversationID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate conversation ID: %w", err)
+		return nil, err
 	}
-	var conversation generated.Conversation
-	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+	return &plan, nil
+}
+
+// SetConversationPlanMode switches a conversation between plan and execute mode
+func (db *DB) SetConversationPlanMode(ctx context.Context, conversationID, mode string) (*generated.ConversationPlan, error) {
+	var plan generated.ConversationPlan
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
-		conversation, err = q.CreateSubagentConversation(ctx, generated.CreateSubagentConversationParams{
-			ConversationID:       conversationID,
-			Slug:                 &slug,
-			Cwd:                  cwd,
-			ParentConversationID: &parentID,
+		var err error
+		plan, err = q.SetConversationPlanMode(ctx, generated.SetConversationPlanModeParams{
+			ConversationID: conversationID,
+			Mode:           mode,
 		})
 		return err
 	})
-	return &conversation, err
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
 }
 
-// GetSubagents retrieves all subagent conversations for a parent conversation
-func (db *DB) GetSubagents(ctx context.Context, parentID string) ([]generated.Conversation, error) {
-	var conversations []generated.Conversation
-	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
-		q := generated.New(rx.Conn())
+// SaveConversationPlan stores the plan submitted by the agent, replacing any
+// earlier plan and its approval
+func (db *DB) SaveConversationPlan(ctx context.Context, conversationID, plan string) (*generated.ConversationPlan, error) {
+	var saved generated.ConversationPlan
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
 		var err error
-		conversations, err = q.GetSubagents(ctx, &parentID)
+		saved, err = q.SaveConversationPlan(ctx, generated.SaveConversationPlanParams{
+			ConversationID: conversationID,
+			Plan:           plan,
+		})
 		return err
 	})
-	return conversations, err
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
 }
 
-// GetConversationBySlugAndParent retrieves a subagent conversation by slug and parent ID
-func (db *DB) GetConversationBySlugAndParent(ctx context.Context, slug, parentID string) (*generated.Conversation, error) {
-	var conversation generated.Conversation
+// ApproveConversationPlan approves the submitted plan and switches the
+// conversation to execute mode. It returns nil if no plan has been submitted.
+func (db *DB) ApproveConversationPlan(ctx context.Context, conversationID string) (*generated.ConversationPlan, error) {
+	var plan generated.ConversationPlan
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		plan, err = q.ApproveConversationPlan(ctx, conversationID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// GetConversationTodos returns a conversation's todo list, or nil if the agent has not kept one
+func (db *DB) GetConversationTodos(ctx context.Context, conversationID string) (*generated.ConversationTodo, error) {
+	var todos generated.ConversationTodo
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
-		conversation, err = q.GetConversationBySlugAndParent(ctx, generated.GetConversationBySlugAndParentParams{
-			Slug:                 &slug,
-			ParentConversationID: &parentID,
-		})
+		todos, err = q.GetConversationTodos(ctx, conversationID)
 		return err
 	})
-	if err == sql.ErrNoRows {
-		return nil, nil // Not found, return nil without error
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
 	}
-	return &conversation, err
+	if err != nil {
+		return nil, err
+	}
+	return &todos, nil
 }
 
-// SubagentDBAdapter adapts *DB to the claudetool.SubagentDB interface.
-type SubagentDBAdapter struct {
-	DB *DB
+// ListMessageAnnotations returns the annotations of a conversation's messages
+func (db *DB) ListMessageAnnotations(ctx context.Context, conversationID string) ([]generated.MessageAnnotation, error) {
+	var annotations []generated.MessageAnnotation
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		annotations, err = q.ListMessageAnnotations(ctx, conversationID)
+		return err
+	})
+	return annotations, err
+}
+
+// SaveMessageAnnotation creates or replaces the annotation of a message
+func (db *DB) SaveMessageAnnotation(ctx context.Context, params generated.UpsertMessageAnnotationParams) (*generated.MessageAnnotation, error) {
+	var saved generated.MessageAnnotation
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		saved, err = q.UpsertMessageAnnotation(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DeleteMessageAnnotation removes the annotation of a message
+func (db *DB) DeleteMessageAnnotation(ctx context.Context, messageID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteMessageAnnotation(ctx, messageID)
+	})
+}
+
+// ListMessageContextFlags returns the context flags of a conversation's messages
+func (db *DB) ListMessageContextFlags(ctx context.Context, conversationID string) ([]generated.MessageContextFlag, error) {
+	var flags []generated.MessageContextFlag
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		flags, err = q.ListMessageContextFlags(ctx, conversationID)
+		return err
+	})
+	return flags, err
+}
+
+// SaveMessageContextFlag pins a message or marks it evictable
+func (db *DB) SaveMessageContextFlag(ctx context.Context, params generated.UpsertMessageContextFlagParams) (*generated.MessageContextFlag, error) {
+	var saved generated.MessageContextFlag
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		saved, err = q.UpsertMessageContextFlag(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DeleteMessageContextFlag removes the context flag of a message
+func (db *DB) DeleteMessageContextFlag(ctx context.Context, messageID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteMessageContextFlag(ctx, messageID)
+	})
+}
+
+// ListAnnotatedMessages returns all annotated messages with their content, most recently annotated first
+func (db *DB) ListAnnotatedMessages(ctx context.Context) ([]generated.ListAnnotatedMessagesRow, error) {
+	var rows []generated.ListAnnotatedMessagesRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		rows, err = q.ListAnnotatedMessages(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range rows {
+			if err := loadPayload(ctx, q, &rows[i].LlmData); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return rows, err
+}
+
+// ListReviewComments returns the review comments of a conversation, oldest first
+func (db *DB) ListReviewComments(ctx context.Context, conversationID string) ([]generated.ReviewComment, error) {
+	var comments []generated.ReviewComment
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		comments, err = q.ListReviewComments(ctx, conversationID)
+		return err
+	})
+	return comments, err
+}
+
+// GetReviewComment returns a review comment, or nil if it doesn't exist
+func (db *DB) GetReviewComment(ctx context.Context, commentID int64) (*generated.ReviewComment, error) {
+	var comment generated.ReviewComment
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		comment, err = q.GetReviewComment(ctx, commentID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// CreateReviewComment adds a review comment
+func (db *DB) CreateReviewComment(ctx context.Context, params generated.CreateReviewCommentParams) (*generated.ReviewComment, error) {
+	var comment generated.ReviewComment
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		comment, err = q.CreateReviewComment(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// UpdateReviewComment changes the body of a review comment and whether it is resolved
+func (db *DB) UpdateReviewComment(ctx context.Context, params generated.UpdateReviewCommentParams) (*generated.ReviewComment, error) {
+	var comment generated.ReviewComment
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		comment, err = q.UpdateReviewComment(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// DeleteReviewComment removes a review comment and its replies
+func (db *DB) DeleteReviewComment(ctx context.Context, commentID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteReviewComment(ctx, commentID)
+	})
+}
+
+// SetInterruptedTurn records that a conversation's turn was interrupted and should be resumed
+func (db *DB) SetInterruptedTurn(ctx context.Context, conversationID, reason string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetInterruptedTurn(ctx, generated.SetInterruptedTurnParams{ConversationID: conversationID, Reason: reason})
+	})
+}
+
+// ListInterruptedTurns returns the interrupted turns, oldest first
+func (db *DB) ListInterruptedTurns(ctx context.Context) ([]generated.InterruptedTurn, error) {
+	var turns []generated.InterruptedTurn
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		turns, err = q.ListInterruptedTurns(ctx)
+		return err
+	})
+	return turns, err
+}
+
+// DeleteInterruptedTurn removes a conversation's interrupted turn record
+func (db *DB) DeleteInterruptedTurn(ctx context.Context, conversationID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteInterruptedTurn(ctx, conversationID)
+	})
+}
+
+// GetBridgeSession returns a conversation's bridge session, or nil if it has none
+func (db *DB) GetBridgeSession(ctx context.Context, conversationID string) (*generated.BridgeSession, error) {
+	var session generated.BridgeSession
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		session, err = q.GetBridgeSession(ctx, conversationID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// SetBridgeSession records a conversation's bridge session and the directory it started in
+func (db *DB) SetBridgeSession(ctx context.Context, conversationID, sessionID, workingDir string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetBridgeSession(ctx, generated.SetBridgeSessionParams{ConversationID: conversationID, SessionID: sessionID, WorkingDir: workingDir})
+	})
+}
+
+// DeleteBridgeSession forgets a conversation's bridge session
+func (db *DB) DeleteBridgeSession(ctx context.Context, conversationID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteBridgeSession(ctx, conversationID)
+	})
+}
+
+// GetCachedResponse returns the cached response for a request key and counts
+// the hit, or returns nil if there is none
+func (db *DB) GetCachedResponse(ctx context.Context, cacheKey string) (*generated.ResponseCache, error) {
+	var cached generated.ResponseCache
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		cached, err = q.GetCachedResponse(ctx, cacheKey)
+		if err != nil {
+			return err
+		}
+		return q.RecordCacheHit(ctx, cacheKey)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cached, nil
+}
+
+// SaveCachedResponse caches the response to the request with the given key
+func (db *DB) SaveCachedResponse(ctx context.Context, cacheKey, modelID, response string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SaveCachedResponse(ctx, generated.SaveCachedResponseParams{CacheKey: cacheKey, ModelID: modelID, Response: response})
+	})
+}
+
+// GetResponseCacheStats returns the number of cached responses and how often they were used
+func (db *DB) GetResponseCacheStats(ctx context.Context) (generated.GetResponseCacheStatsRow, error) {
+	var stats generated.GetResponseCacheStatsRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		stats, err = q.GetResponseCacheStats(ctx)
+		return err
+	})
+	return stats, err
+}
+
+// ClearResponseCache removes all cached responses
+func (db *DB) ClearResponseCache(ctx context.Context) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.ClearResponseCache(ctx)
+	})
+}
+
+// GetConversationSettings returns a conversation's sampling settings, or nil if it uses the model's defaults
+func (db *DB) GetConversationSettings(ctx context.Context, conversationID string) (*generated.ConversationSetting, error) {
+	var settings generated.ConversationSetting
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		settings, err = q.GetConversationSettings(ctx, conversationID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveConversationSettings replaces a conversation's sampling settings; nil values use the model's defaults
+func (db *DB) SaveConversationSettings(ctx context.Context, params generated.UpsertConversationSettingsParams) (*generated.ConversationSetting, error) {
+	var saved generated.ConversationSetting
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		saved, err = q.UpsertConversationSettings(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// SaveConversationTodos replaces a conversation's todo list with todos, a JSON array
+func (db *DB) SaveConversationTodos(ctx context.Context, conversationID, todos string) (*generated.ConversationTodo, error) {
+	var saved generated.ConversationTodo
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		saved, err = q.UpsertConversationTodos(ctx, generated.UpsertConversationTodosParams{
+			ConversationID: conversationID,
+			Todos:          todos,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// SaveToolOutput stores the full output of a tool result whose text was truncated for the model
+func (db *DB) SaveToolOutput(ctx context.Context, conversationID, toolUseID, output string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpsertToolOutput(ctx, generated.UpsertToolOutputParams{
+			ConversationID: conversationID,
+			ToolUseID:      toolUseID,
+			Output:         output,
+		})
+	})
+}
+
+// GetToolOutput returns the full output of a truncated tool result of a
+// conversation, or nil if it was not truncated
+func (db *DB) GetToolOutput(ctx context.Context, conversationID, toolUseID string) (*generated.ToolOutput, error) {
+	var output generated.ToolOutput
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		output, err = q.GetToolOutput(ctx, generated.GetToolOutputParams{ConversationID: conversationID, ToolUseID: toolUseID})
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// ErrNoTruncation is returned by RestoreTruncatedMessages when there is nothing to restore.
+var ErrNoTruncation = errors.New("no truncated messages to restore")
+
+// TruncateMessages moves all messages with sequence_id >= fromSequenceID into
+// truncated_messages so that the truncation can later be undone.
+// It returns the number of messages moved.
+func (db *DB) TruncateMessages(ctx context.Context, conversationID string, fromSequenceID int64) (int, error) {
+	var n int
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		n, err = truncateMessages(ctx, q, conversationID, fromSequenceID)
+		return err
+	})
+	return n, err
+}
+
+func truncateMessages(ctx context.Context, q *generated.Queries, conversationID string, fromSequenceID int64) (int, error) {
+	messages, err := q.ListMessagesSince(ctx, generated.ListMessagesSinceParams{
+		ConversationID: conversationID,
+		SequenceID:     fromSequenceID - 1,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list messages: %w", err)
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	truncationID, err := q.GetNextTruncationID(ctx, conversationID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get next truncation ID: %w", err)
+	}
+	for _, m := range messages {
+		if err := q.CreateTruncatedMessage(ctx, generated.CreateTruncatedMessageParams{
+			MessageID:           m.MessageID,
+			ConversationID:      m.ConversationID,
+			TruncationID:        truncationID,
+			SequenceID:          m.SequenceID,
+			Type:                m.Type,
+			LlmData:             m.LlmData,
+			UserData:            m.UserData,
+			UsageData:           m.UsageData,
+			CreatedAt:           m.CreatedAt,
+			DisplayData:         m.DisplayData,
+			ExcludedFromContext: m.ExcludedFromContext,
+		}); err != nil {
+			return 0, fmt.Errorf("failed to save truncated message: %w", err)
+		}
+	}
+	if err := q.DeleteMessagesFrom(ctx, generated.DeleteMessagesFromParams{
+		ConversationID: conversationID,
+		SequenceID:     fromSequenceID,
+	}); err != nil {
+		return 0, fmt.Errorf("failed to delete truncated messages: %w", err)
+	}
+	return len(messages), nil
+}
+
+// RestoreTruncatedMessages undoes the most recent truncation of a conversation.
+// Messages added since that truncation are truncated in turn, so calling it
+// again redoes the truncation. Restored messages get fresh sequence IDs.
+// It returns the number of messages restored, or ErrNoTruncation.
+func (db *DB) RestoreTruncatedMessages(ctx context.Context, conversationID string) (int, error) {
+	var n int
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		next, err := q.GetNextTruncationID(ctx, conversationID)
+		if err != nil {
+			return fmt.Errorf("failed to get next truncation ID: %w", err)
+		}
+		latest := next - 1
+		if latest == 0 {
+			return ErrNoTruncation
+		}
+		truncated, err := q.ListTruncatedMessages(ctx, generated.ListTruncatedMessagesParams{
+			ConversationID: conversationID,
+			TruncationID:   latest,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list truncated messages: %w", err)
+		}
+		if len(truncated) == 0 {
+			return ErrNoTruncation
+		}
+
+		if _, err := truncateMessages(ctx, q, conversationID, truncated[0].SequenceID); err != nil {
+			return err
+		}
+		if err := q.DeleteTruncatedMessages(ctx, generated.DeleteTruncatedMessagesParams{
+			ConversationID: conversationID,
+			TruncationID:   latest,
+		}); err != nil {
+			return fmt.Errorf("failed to delete truncated messages: %w", err)
+		}
+
+		sequenceID, err := q.GetNextSequenceID(ctx, conversationID)
+		if err != nil {
+			return fmt.Errorf("failed to get next sequence ID: %w", err)
+		}
+		for i, m := range truncated {
+			if err := q.RestoreMessage(ctx, generated.RestoreMessageParams{
+				MessageID:           m.MessageID,
+				ConversationID:      m.ConversationID,
+				SequenceID:          sequenceID + int64(i),
+				Type:                m.Type,
+				LlmData:             m.LlmData,
+				UserData:            m.UserData,
+				UsageData:           m.UsageData,
+				CreatedAt:           m.CreatedAt,
+				DisplayData:         m.DisplayData,
+				ExcludedFromContext: m.ExcludedFromContext,
+			}); err != nil {
+				return fmt.Errorf("failed to restore message: %w", err)
+			}
+		}
+		n = len(truncated)
+		return nil
+	})
+	return n, err
+}
+
+// GetDraft returns the saved draft for a conversation, or nil if there is none
+func (db *DB) GetDraft(ctx context.Context, conversationID string) (*generated.Draft, error) {
+	var draft generated.Draft
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		draft, err = q.GetDraft(ctx, conversationID)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// SaveDraft stores the draft for a conversation. An empty text deletes the draft.
+func (db *DB) SaveDraft(ctx context.Context, conversationID, text string) (*generated.Draft, error) {
+	var draft *generated.Draft
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		if text == "" {
+			return q.DeleteDraft(ctx, conversationID)
+		}
+		d, err := q.UpsertDraft(ctx, generated.UpsertDraftParams{
+			ConversationID: conversationID,
+			Text:           text,
+		})
+		draft = &d
+		return err
+	})
+	return draft, err
+}
+
+// GetOrCreateSetting returns the value of a setting, storing the value returned
+// by create first if the setting does not exist yet.
+func (db *DB) GetOrCreateSetting(ctx context.Context, key string, create func() (string, error)) (string, error) {
+	var value string
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		value, err = q.GetSetting(ctx, key)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if value, err = create(); err != nil {
+			return err
+		}
+		return q.InsertSettingIfAbsent(ctx, generated.InsertSettingIfAbsentParams{Key: key, Value: value})
+	})
+	return value, err
+}
+
+// GetSetting returns the value of a setting, or "" if it is not set.
+func (db *DB) GetSetting(ctx context.Context, key string) (string, error) {
+	var value string
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		value, err = q.GetSetting(ctx, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	})
+	return value, err
+}
+
+// SetSetting stores the value of a setting, replacing any previous value.
+func (db *DB) SetSetting(ctx context.Context, key, value string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetSetting(ctx, generated.SetSettingParams{Key: key, Value: value})
+	})
+}
+
+// DeleteSetting removes a setting.
+func (db *DB) DeleteSetting(ctx context.Context, key string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteSetting(ctx, key)
+	})
+}
+
+// Queries provides read-only access to generated queries within a read transaction
+func (db *DB) Queries(ctx context.Context, fn func(*generated.Queries) error) error {
+	return db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		return fn(q)
+	})
+}
+
+// QueriesTx provides read-write access to generated queries within a write transaction
+func (db *DB) QueriesTx(ctx context.Context, fn func(*generated.Queries) error) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return fn(q)
+	})
+}
+
+// ListArchivedConversations retrieves archived conversations with pagination
+func (db *DB) ListArchivedConversations(ctx context.Context, limit, offset int64) ([]generated.Conversation, error) {
+	var conversations []generated.Conversation
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		conversations, err = q.ListArchivedConversations(ctx, generated.ListArchivedConversationsParams{
+			Limit:  limit,
+			Offset: offset,
+		})
+		return err
+	})
+	return conversations, err
+}
+
+// SearchArchivedConversations searches for archived conversations containing the given query in their slug
+func (db *DB) SearchArchivedConversations(ctx context.Context, query string, limit, offset int64) ([]generated.Conversation, error) {
+	queryPtr := &query
+	var conversations []generated.Conversation
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		conversations, err = q.SearchArchivedConversations(ctx, generated.SearchArchivedConversationsParams{
+			Column1: queryPtr,
+			Limit:   limit,
+			Offset:  offset,
+		})
+		return err
+	})
+	return conversations, err
+}
+
+// ArchiveConversation archives a conversation
+func (db *DB) ArchiveConversation(ctx context.Context, conversationID string) (*generated.Conversation, error) {
+	var conversation generated.Conversation
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		conversation, err = q.ArchiveConversation(ctx, conversationID)
+		return err
+	})
+	return &conversation, err
+}
+
+// UnarchiveConversation unarchives a conversation
+func (db *DB) UnarchiveConversation(ctx context.Context, conversationID string) (*generated.Conversation, error) {
+	var conversation generated.Conversation
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		conversation, err = q.UnarchiveConversation(ctx, conversationID)
+		return err
+	})
+	return &conversation, err
+}
+
+// DeleteConversation deletes a conversation and all its messages
+func (db *DB) DeleteConversation(ctx context.Context, conversationID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		// Delete annotations and messages first (foreign key constraint)
+		if err := q.DeleteConversationAnnotations(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete annotations: %w", err)
+		}
+		if err := q.DeleteConversationMessages(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete messages: %w", err)
+		}
+		if err := q.DeleteConversationTruncatedMessages(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete truncated messages: %w", err)
+		}
+		if err := q.DeleteDraft(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete draft: %w", err)
+		}
+		if err := q.DeleteConversationDirectories(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete directories: %w", err)
+		}
+		if err := q.DeleteConversationEnv(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete environment: %w", err)
+		}
+		if err := q.DeleteConversationHooks(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete hooks: %w", err)
+		}
+		if err := q.DeleteConversationPlan(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete plan: %w", err)
+		}
+		if err := q.DeleteConversationTodos(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete todos: %w", err)
+		}
+		if err := q.DeleteConversationSettings(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete settings: %w", err)
+		}
+		if err := q.DeleteInterruptedTurn(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete interrupted turn: %w", err)
+		}
+		if err := q.DeleteConversationToolOutputs(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete tool outputs: %w", err)
+		}
+		if err := q.DeleteBridgeSession(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete bridge session: %w", err)
+		}
+		if err := q.DeleteConversation(ctx, conversationID); err != nil {
+			return err
+		}
+		return q.DeleteUnreferencedMessageBlobs(ctx)
+	})
+}
+
+// AddConversationTags tags a conversation; tags it already has are kept
+func (db *DB) AddConversationTags(ctx context.Context, conversationID string, tags []string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		for _, tag := range tags {
+			if err := q.AddConversationTag(ctx, generated.AddConversationTagParams{ConversationID: conversationID, Tag: tag}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveConversationTags removes tags from a conversation
+func (db *DB) RemoveConversationTags(ctx context.Context, conversationID string, tags []string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		for _, tag := range tags {
+			if err := q.DeleteConversationTag(ctx, generated.DeleteConversationTagParams{ConversationID: conversationID, Tag: tag}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListConversationTags returns the tags of all conversations
+func (db *DB) ListConversationTags(ctx context.Context) ([]generated.ConversationTag, error) {
+	var tags []generated.ConversationTag
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		tags, err = q.ListConversationTags(ctx)
+		return err
+	})
+	return tags, err
+}
+
+// CreateSubagentConversation creates a new subagent conversation with a parent
+func (db *DB) CreateSubagentConversation(ctx context.Context, slug, parentID string, cwd *string) (*generated.Conversation, error) {
+	conversationID, err := generateConversationID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate conversation ID: %w", err)
+	}
+	var conversation generated.Conversation
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		conversation, err = q.CreateSubagentConversation(ctx, generated.CreateSubagentConversationParams{
+			ConversationID:       conversationID,
+			Slug:                 &slug,
+			Cwd:                  cwd,
+			ParentConversationID: &parentID,
+		})
+		return err
+	})
+	return &conversation, err
+}
+
+// GetSubagents retrieves all subagent conversations for a parent conversation
+func (db *DB) GetSubagents(ctx context.Context, parentID string) ([]generated.Conversation, error) {
+	var conversations []generated.Conversation
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		conversations, err = q.GetSubagents(ctx, &parentID)
+		return err
+	})
+	return conversations, err
+}
+
+// GetConversationBySlugAndParent retrieves a subagent conversation by slug and parent ID
+func (db *DB) GetConversationBySlugAndParent(ctx context.Context, slug, parentID string) (*generated.Conversation, error) {
+	var conversation generated.Conversation
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		conversation, err = q.GetConversationBySlugAndParent(ctx, generated.GetConversationBySlugAndParentParams{
+			Slug:                 &slug,
+			ParentConversationID: &parentID,
+		})
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil // Not found, return nil without error
+	}
+	return &conversation, err
+}
+
+// MemoryDBAdapter adapts *DB to the claudetool.MemoryStore interface.
+type MemoryDBAdapter struct {
+	DB *DB
+}
+
+// Remember implements claudetool.MemoryStore.
+func (a *MemoryDBAdapter) Remember(ctx context.Context, directory, content string) (int64, error) {
+	memory, err := a.DB.CreateMemory(ctx, directory, content)
+	if err != nil {
+		return 0, err
+	}
+	return memory.MemoryID, nil
+}
+
+// Forget implements claudetool.MemoryStore.
+func (a *MemoryDBAdapter) Forget(ctx context.Context, memoryID int64) (bool, error) {
+	memory, err := a.DB.DeleteMemory(ctx, memoryID)
+	return memory != nil, err
+}
+
+// PlanDBAdapter adapts *DB to the claudetool.PlanStore interface.
+type PlanDBAdapter struct {
+	DB *DB
+}
+
+// SavePlan implements claudetool.PlanStore.
+func (a *PlanDBAdapter) SavePlan(ctx context.Context, conversationID, plan string) error {
+	_, err := a.DB.SaveConversationPlan(ctx, conversationID, plan)
+	return err
+}
+
+// TodoDBAdapter adapts *DB to the claudetool.TodoStore interface.
+type TodoDBAdapter struct {
+	DB *DB
+}
+
+// SaveTodos implements claudetool.TodoStore.
+func (a *TodoDBAdapter) SaveTodos(ctx context.Context, conversationID, todos string) error {
+	_, err := a.DB.SaveConversationTodos(ctx, conversationID, todos)
+	return err
+}
+
+// ToolOutputDBAdapter adapts *DB to the claudetool.ToolOutputStore interface.
+type ToolOutputDBAdapter struct {
+	DB *DB
+}
+
+// GetToolOutput implements claudetool.ToolOutputStore.
+func (a *ToolOutputDBAdapter) GetToolOutput(ctx context.Context, conversationID, toolUseID string) (string, bool, error) {
+	output, err := a.DB.GetToolOutput(ctx, conversationID, toolUseID)
+	if err != nil || output == nil {
+		return "", false, err
+	}
+	return output.Output, true, nil
+}
+
+// BridgeSessionDBAdapter adapts *DB to the claudecode.SessionStore interface.
+type BridgeSessionDBAdapter struct {
+	DB *DB
+}
+
+// GetSession implements claudecode.SessionStore.
+func (a *BridgeSessionDBAdapter) GetSession(ctx context.Context, conversationID string) (sessionID, workingDir string, err error) {
+	session, err := a.DB.GetBridgeSession(ctx, conversationID)
+	if err != nil || session == nil {
+		return "", "", err
+	}
+	return session.SessionID, session.WorkingDir, nil
+}
+
+// SaveSession implements claudecode.SessionStore.
+func (a *BridgeSessionDBAdapter) SaveSession(ctx context.Context, conversationID, sessionID, workingDir string) error {
+	return a.DB.SetBridgeSession(ctx, conversationID, sessionID, workingDir)
+}
+
+// DeleteSession implements claudecode.SessionStore.
+func (a *BridgeSessionDBAdapter) DeleteSession(ctx context.Context, conversationID string) error {
+	return a.DB.DeleteBridgeSession(ctx, conversationID)
+}
+
+// SubagentDBAdapter adapts *DB to the claudetool.SubagentDB interface.
+type SubagentDBAdapter struct {
+	DB *DB
 }
 
 // GetOrCreateSubagentConversation implements claudetool.SubagentDB.
@@ -714,259 +1839,1365 @@ func (a *SubagentDBAdapter) GetOrCreateSubagentConversation(ctx context.Context,
 	// Try to find existing with exact slug
 	existing, err := a.DB.GetConversationBySlugAndParent(ctx, slug, parentID)
 	if err != nil {
-		return "", "", err
+		return "", "", err
+	}
+	if existing != nil {
+		return existing.ConversationID, *existing.Slug, nil
+	}
+
+	// Try to create new, handling unique constraint violations by appending numbers
+	baseSlug := slug
+	actualSlug := slug
+	for attempt := 0; attempt < 100; attempt++ {
+		conv, err := a.DB.CreateSubagentConversation(ctx, actualSlug, parentID, &cwd)
+		if err == nil {
+			return conv.ConversationID, actualSlug, nil
+		}
+
+		// Check if this is a unique constraint violation
+		errLower := strings.ToLower(err.Error())
+		if strings.Contains(errLower, "unique constraint") ||
+			strings.Contains(errLower, "duplicate") {
+			// Try with a numeric suffix
+			actualSlug = fmt.Sprintf("%s-%d", baseSlug, attempt+1)
+			continue
+		}
+
+		// Some other error occurred
+		return "", "", err
+	}
+
+	return "", "", fmt.Errorf("failed to create unique subagent slug after 100 attempts")
+}
+
+// InsertLLMRequest inserts a new LLM request record
+func (db *DB) InsertLLMRequest(ctx context.Context, params generated.InsertLLMRequestParams) (*generated.LlmRequest, error) {
+	var request generated.LlmRequest
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+
+		// If we have a conversation ID and request body, try to find common prefix
+		if params.ConversationID != nil && params.RequestBody != nil {
+			// Get the last request for this conversation
+			lastReq, err := q.GetLastRequestForConversation(ctx, params.ConversationID)
+			if err == nil {
+				// Found a previous request - compute common prefix
+				prefixLen, fullPrevBody := computeSharedPrefixLength(lastReq, *params.RequestBody)
+				if prefixLen > 0 {
+					// Store only the suffix
+					suffix := (*params.RequestBody)[prefixLen:]
+					params.RequestBody = &suffix
+					params.PrefixRequestID = &lastReq.ID
+					prefixLen64 := int64(prefixLen)
+					params.PrefixLength = &prefixLen64
+					_ = fullPrevBody // silence unused warning, used for computing prefix
+				}
+			}
+			// If no previous request found or error, just store the full body
+		}
+
+		var err error
+		request, err = q.InsertLLMRequest(ctx, params)
+		return err
+	})
+	return &request, err
+}
+
+// computeSharedPrefixLength computes the length of the shared prefix between
+// the full previous request body (reconstructed by walking the chain) and the new request body.
+// It returns the prefix length and the fully reconstructed previous body.
+func computeSharedPrefixLength(prevReq generated.LlmRequest, newBody string) (int, string) {
+	// Get the stored body (which may be just a suffix if prevReq has a prefix reference)
+	prevBody := ""
+	if prevReq.RequestBody != nil {
+		prevBody = *prevReq.RequestBody
+	}
+
+	// If the previous request has a prefix reference, we need to account for that
+	// by prepending the prefix length worth of bytes from the new body.
+	// This works because in a conversation, request N+1 typically starts with
+	// all of request N plus new content at the end.
+	if prevReq.PrefixLength != nil && *prevReq.PrefixLength > 0 {
+		// The previous request's full body would be:
+		// [first prefix_length bytes that match its parent] + [stored suffix]
+		// If the new body is a continuation, its first prefix_length bytes
+		// should match those same bytes.
+		prefixLen := int(*prevReq.PrefixLength)
+		if prefixLen <= len(newBody) {
+			prevBody = newBody[:prefixLen] + prevBody
+		}
+	}
+
+	// Compute byte-by-byte shared prefix between reconstructed prevBody and newBody
+	minLen := len(prevBody)
+	if len(newBody) < minLen {
+		minLen = len(newBody)
+	}
+
+	prefixLen := 0
+	for i := 0; i < minLen; i++ {
+		if prevBody[i] != newBody[i] {
+			break
+		}
+		prefixLen++
+	}
+
+	// Only use prefix deduplication if we save meaningful space
+	// (at least 100 bytes saved)
+	if prefixLen < 100 {
+		return 0, prevBody
+	}
+
+	return prefixLen, prevBody
+}
+
+// ListRecentLLMRequests returns the most recent LLM requests
+func (db *DB) ListRecentLLMRequests(ctx context.Context, limit int64) ([]generated.ListRecentLLMRequestsRow, error) {
+	var requests []generated.ListRecentLLMRequestsRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		requests, err = q.ListRecentLLMRequests(ctx, limit)
+		return err
+	})
+	return requests, err
+}
+
+// GetLLMRequestBody returns the raw request body for a request
+func (db *DB) GetLLMRequestBody(ctx context.Context, id int64) (*string, error) {
+	var body *string
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		body, err = q.GetLLMRequestBody(ctx, id)
+		return err
+	})
+	return body, err
+}
+
+// GetLLMResponseBody returns the raw response body for a request
+func (db *DB) GetLLMResponseBody(ctx context.Context, id int64) (*string, error) {
+	var body *string
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		body, err = q.GetLLMResponseBody(ctx, id)
+		return err
+	})
+	return body, err
+}
+
+// GetFullLLMRequestBody reconstructs the full request body for a request,
+// following the prefix chain if necessary.
+func (db *DB) GetFullLLMRequestBody(ctx context.Context, requestID int64) (string, error) {
+	var result string
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		return reconstructRequestBody(ctx, q, requestID, &result)
+	})
+	return result, err
+}
+
+// reconstructRequestBody recursively reconstructs the full request body
+func reconstructRequestBody(ctx context.Context, q *generated.Queries, requestID int64, result *string) error {
+	req, err := q.GetLLMRequestByID(ctx, requestID)
+	if err != nil {
+		return err
+	}
+
+	suffix := ""
+	if req.RequestBody != nil {
+		suffix = *req.RequestBody
+	}
+
+	if req.PrefixRequestID == nil || req.PrefixLength == nil || *req.PrefixLength == 0 {
+		// No prefix reference - the stored body is the full body
+		*result = suffix
+		return nil
+	}
+
+	// Recursively get the parent's full body
+	var parentBody string
+	if err := reconstructRequestBody(ctx, q, *req.PrefixRequestID, &parentBody); err != nil {
+		return err
+	}
+
+	// The full body is the first prefix_length bytes from the parent + our suffix
+	prefixLen := int(*req.PrefixLength)
+	if prefixLen > len(parentBody) {
+		prefixLen = len(parentBody)
+	}
+	*result = parentBody[:prefixLen] + suffix
+	return nil
+}
+
+// GetModels returns all models from the database
+func (db *DB) GetModels(ctx context.Context) ([]generated.Model, error) {
+	var models []generated.Model
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		models, err = q.GetModels(ctx)
+		return err
+	})
+	return models, err
+}
+
+// GetModel returns a model by ID
+func (db *DB) GetModel(ctx context.Context, modelID string) (*generated.Model, error) {
+	var model generated.Model
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		model, err = q.GetModel(ctx, modelID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// CreateModel creates a new model
+func (db *DB) CreateModel(ctx context.Context, params generated.CreateModelParams) (*generated.Model, error) {
+	var model generated.Model
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		model, err = q.CreateModel(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// UpdateModel updates a model
+func (db *DB) UpdateModel(ctx context.Context, params generated.UpdateModelParams) (*generated.Model, error) {
+	var model generated.Model
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		model, err = q.UpdateModel(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// DeleteModel deletes a model
+func (db *DB) DeleteModel(ctx context.Context, modelID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteModel(ctx, modelID)
+	})
+}
+
+// ListProjects returns all project profiles ordered by directory
+func (db *DB) ListProjects(ctx context.Context) ([]generated.Project, error) {
+	var projects []generated.Project
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		projects, err = q.ListProjects(ctx)
+		return err
+	})
+	return projects, err
+}
+
+// UpsertProject creates or replaces the project profile for a directory
+func (db *DB) UpsertProject(ctx context.Context, params generated.UpsertProjectParams) (*generated.Project, error) {
+	var project generated.Project
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		project, err = q.UpsertProject(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// DeleteProject deletes the project profile for a directory
+func (db *DB) DeleteProject(ctx context.Context, directory string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteProject(ctx, directory)
+	})
+}
+
+// FindProjectForDirectory returns the project whose directory is dir or its
+// closest ancestor, or nil if no project covers dir.
+func (db *DB) FindProjectForDirectory(ctx context.Context, dir string) (*generated.Project, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	projects, err := db.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Clean(dir)
+	var best *generated.Project
+	for i := range projects {
+		p := &projects[i]
+		if !isWithinDir(dir, p.Directory) {
+			continue
+		}
+		if best == nil || len(p.Directory) > len(best.Directory) {
+			best = p
+		}
+	}
+	return best, nil
+}
+
+// ListSnippets returns an owner's prompt snippets ordered by name
+func (db *DB) ListSnippets(ctx context.Context, owner string) ([]generated.Snippet, error) {
+	var snippets []generated.Snippet
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		snippets, err = q.ListSnippets(ctx, owner)
+		return err
+	})
+	return snippets, err
+}
+
+// GetSnippet returns one of an owner's prompt snippets
+func (db *DB) GetSnippet(ctx context.Context, owner string, snippetID int64) (*generated.Snippet, error) {
+	var snippet generated.Snippet
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		snippet, err = q.GetSnippet(ctx, generated.GetSnippetParams{SnippetID: snippetID, Owner: owner})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snippet, nil
+}
+
+// CreateSnippet adds a prompt snippet
+func (db *DB) CreateSnippet(ctx context.Context, params generated.CreateSnippetParams) (*generated.Snippet, error) {
+	var snippet generated.Snippet
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		snippet, err = q.CreateSnippet(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snippet, nil
+}
+
+// UpdateSnippet replaces the name, description and body of a prompt snippet
+func (db *DB) UpdateSnippet(ctx context.Context, params generated.UpdateSnippetParams) (*generated.Snippet, error) {
+	var snippet generated.Snippet
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		snippet, err = q.UpdateSnippet(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snippet, nil
+}
+
+// DeleteSnippet deletes one of an owner's prompt snippets
+func (db *DB) DeleteSnippet(ctx context.Context, owner string, snippetID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteSnippet(ctx, generated.DeleteSnippetParams{SnippetID: snippetID, Owner: owner})
+	})
+}
+
+// MarkConversationRead records that a user has read a conversation up to its latest message
+func (db *DB) MarkConversationRead(ctx context.Context, owner, conversationID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.MarkConversationRead(ctx, generated.MarkConversationReadParams{Owner: owner, ConversationID: conversationID})
+	})
+}
+
+// ListUnreadMessages returns the user and agent messages a user has not read,
+// in the conversations the user has read before
+func (db *DB) ListUnreadMessages(ctx context.Context, owner string) ([]generated.Message, error) {
+	var messages []generated.Message
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		messages, err = q.ListUnreadMessages(ctx, owner)
+		if err != nil {
+			return err
+		}
+		return LoadMessageBlobs(ctx, q, messages)
+	})
+	return messages, err
+}
+
+// GetConversationSummary returns a conversation's summary, or nil if it has none
+func (db *DB) GetConversationSummary(ctx context.Context, conversationID string) (*generated.ConversationSummary, error) {
+	var summary generated.ConversationSummary
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		summary, err = q.GetConversationSummary(ctx, conversationID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// ListConversationSummaries returns the summaries of all conversations
+func (db *DB) ListConversationSummaries(ctx context.Context) ([]generated.ConversationSummary, error) {
+	var summaries []generated.ConversationSummary
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		summaries, err = q.ListConversationSummaries(ctx)
+		return err
+	})
+	return summaries, err
+}
+
+// SaveConversationSummary creates or replaces a conversation's summary
+func (db *DB) SaveConversationSummary(ctx context.Context, params generated.UpsertConversationSummaryParams) (*generated.ConversationSummary, error) {
+	var summary generated.ConversationSummary
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		summary, err = q.UpsertConversationSummary(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// ListConversationTemplates returns an owner's conversation templates ordered by name
+func (db *DB) ListConversationTemplates(ctx context.Context, owner string) ([]generated.ConversationTemplate, error) {
+	var templates []generated.ConversationTemplate
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		templates, err = q.ListConversationTemplates(ctx, owner)
+		return err
+	})
+	return templates, err
+}
+
+// GetConversationTemplate returns one of an owner's conversation templates
+func (db *DB) GetConversationTemplate(ctx context.Context, owner string, templateID int64) (*generated.ConversationTemplate, error) {
+	var template generated.ConversationTemplate
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		template, err = q.GetConversationTemplate(ctx, generated.GetConversationTemplateParams{TemplateID: templateID, Owner: owner})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetConversationTemplateByName returns an owner's conversation template by name
+func (db *DB) GetConversationTemplateByName(ctx context.Context, owner, name string) (*generated.ConversationTemplate, error) {
+	var template generated.ConversationTemplate
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		template, err = q.GetConversationTemplateByName(ctx, generated.GetConversationTemplateByNameParams{Name: name, Owner: owner})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// CreateConversationTemplate adds a conversation template
+func (db *DB) CreateConversationTemplate(ctx context.Context, params generated.CreateConversationTemplateParams) (*generated.ConversationTemplate, error) {
+	var template generated.ConversationTemplate
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		template, err = q.CreateConversationTemplate(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// UpdateConversationTemplate replaces a conversation template
+func (db *DB) UpdateConversationTemplate(ctx context.Context, params generated.UpdateConversationTemplateParams) (*generated.ConversationTemplate, error) {
+	var template generated.ConversationTemplate
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		template, err = q.UpdateConversationTemplate(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// DeleteConversationTemplate deletes one of an owner's conversation templates
+func (db *DB) DeleteConversationTemplate(ctx context.Context, owner string, templateID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteConversationTemplate(ctx, generated.DeleteConversationTemplateParams{TemplateID: templateID, Owner: owner})
+	})
+}
+
+// ListAPITokens returns an owner's API tokens, newest first
+func (db *DB) ListAPITokens(ctx context.Context, owner string) ([]generated.ApiToken, error) {
+	var tokens []generated.ApiToken
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		tokens, err = q.ListAPITokens(ctx, owner)
+		return err
+	})
+	return tokens, err
+}
+
+// GetAPITokenByHash returns the API token with the given hash
+func (db *DB) GetAPITokenByHash(ctx context.Context, tokenHash string) (*generated.ApiToken, error) {
+	var token generated.ApiToken
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		token, err = q.GetAPITokenByHash(ctx, tokenHash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CreateAPIToken adds an API token
+func (db *DB) CreateAPIToken(ctx context.Context, params generated.CreateAPITokenParams) (*generated.ApiToken, error) {
+	var token generated.ApiToken
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		token, err = q.CreateAPIToken(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	if existing != nil {
-		return existing.ConversationID, *existing.Slug, nil
+	return &token, nil
+}
+
+// TouchAPIToken records that an API token was just used
+func (db *DB) TouchAPIToken(ctx context.Context, tokenID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.TouchAPIToken(ctx, tokenID)
+	})
+}
+
+// DeleteAPIToken revokes one of an owner's API tokens, returning
+// sql.ErrNoRows when the owner has no token with that ID
+func (db *DB) DeleteAPIToken(ctx context.Context, owner string, tokenID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		n, err := q.DeleteAPIToken(ctx, generated.DeleteAPITokenParams{TokenID: tokenID, Owner: owner})
+		if err == nil && n == 0 {
+			err = sql.ErrNoRows
+		}
+		return err
+	})
+}
+
+// InsertAuditEvent appends an event to the audit log
+func (db *DB) InsertAuditEvent(ctx context.Context, params generated.InsertAuditEventParams) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		_, err := q.InsertAuditEvent(ctx, params)
+		return err
+	})
+}
+
+// ListAuditEvents returns audit log events matching the filters, newest first
+func (db *DB) ListAuditEvents(ctx context.Context, params generated.ListAuditEventsParams) ([]generated.AuditLog, error) {
+	var events []generated.AuditLog
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		events, err = q.ListAuditEvents(ctx, params)
+		return err
+	})
+	return events, err
+}
+
+// isWithinDir reports whether dir is base or one of its descendants.
+// Both paths must be clean.
+func isWithinDir(dir, base string) bool {
+	return dir == base || strings.HasPrefix(dir, strings.TrimSuffix(base, "/")+"/")
+}
+
+// CreateMemory stores a memory for a directory, or for every directory when directory is empty
+func (db *DB) CreateMemory(ctx context.Context, directory, content string) (*generated.Memory, error) {
+	var memory generated.Memory
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		memory, err = q.CreateMemory(ctx, generated.CreateMemoryParams{Directory: directory, Content: content})
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &memory, nil
+}
 
-	// Try to create new, handling unique constraint violations by appending numbers
-	baseSlug := slug
-	actualSlug := slug
-	for attempt := 0; attempt < 100; attempt++ {
-		conv, err := a.DB.CreateSubagentConversation(ctx, actualSlug, parentID, &cwd)
-		if err == nil {
-			return conv.ConversationID, actualSlug, nil
+// DeleteMemory deletes a memory and returns it, or nil if there is no such memory
+func (db *DB) DeleteMemory(ctx context.Context, memoryID int64) (*generated.Memory, error) {
+	var memory generated.Memory
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		memory, err = q.DeleteMemory(ctx, memoryID)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &memory, nil
+}
+
+// ListMemories returns all memories, oldest first
+func (db *DB) ListMemories(ctx context.Context) ([]generated.Memory, error) {
+	var memories []generated.Memory
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		memories, err = q.ListMemories(ctx)
+		return err
+	})
+	return memories, err
+}
+
+// ListMemoriesForDirectory returns the memories that apply to dir: those
+// for every directory and those for dir or one of its ancestors, oldest first.
+func (db *DB) ListMemoriesForDirectory(ctx context.Context, dir string) ([]generated.Memory, error) {
+	memories, err := db.ListMemories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		dir = filepath.Clean(dir)
+	}
+	result := []generated.Memory{}
+	for _, m := range memories {
+		if m.Directory == "" || (dir != "" && isWithinDir(dir, m.Directory)) {
+			result = append(result, m)
 		}
+	}
+	return result, nil
+}
 
-		// Check if this is a unique constraint violation
-		errLower := strings.ToLower(err.Error())
-		if strings.Contains(errLower, "unique constraint") ||
-			strings.Contains(errLower, "duplicate") {
-			// Try with a numeric suffix
-			actualSlug = fmt.Sprintf("%s-%d", baseSlug, attempt+1)
-			continue
+// ListCodeChunks returns the indexed chunks of the repository at root made by embedder
+func (db *DB) ListCodeChunks(ctx context.Context, root, embedder string) ([]generated.CodeChunk, error) {
+	var chunks []generated.CodeChunk
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		chunks, err = q.ListCodeChunks(ctx, generated.ListCodeChunksParams{Root: root, Embedder: embedder})
+		return err
+	})
+	return chunks, err
+}
+
+// ListCodeIndexFiles returns the hash of each file indexed by embedder in the repository at root
+func (db *DB) ListCodeIndexFiles(ctx context.Context, root, embedder string) (map[string]string, error) {
+	var rows []generated.ListCodeIndexFilesRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		rows, err = q.ListCodeIndexFiles(ctx, generated.ListCodeIndexFilesParams{Root: root, Embedder: embedder})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(rows))
+	for _, row := range rows {
+		files[row.Path] = row.FileHash
+	}
+	return files, nil
+}
+
+// ReplaceCodeChunks replaces the indexed chunks of one file. With no chunks,
+// the file is removed from the index.
+func (db *DB) ReplaceCodeChunks(ctx context.Context, root, path string, chunks []generated.InsertCodeChunkParams) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		if err := q.DeleteCodeChunksForFile(ctx, generated.DeleteCodeChunksForFileParams{Root: root, Path: path}); err != nil {
+			return err
+		}
+		for _, chunk := range chunks {
+			if err := q.InsertCodeChunk(ctx, chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteCodeChunksForOtherEmbedders drops the index entries for root made by
+// any embedder other than the given one.
+func (db *DB) DeleteCodeChunksForOtherEmbedders(ctx context.Context, root, embedder string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteCodeChunksForOtherEmbedders(ctx, generated.DeleteCodeChunksForOtherEmbeddersParams{Root: root, Embedder: embedder})
+	})
+}
+
+// ListHTTPTools returns the user-defined HTTP tools ordered by name
+func (db *DB) ListHTTPTools(ctx context.Context) ([]generated.HttpTool, error) {
+	var tools []generated.HttpTool
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		tools, err = q.ListHTTPTools(ctx)
+		return err
+	})
+	return tools, err
+}
+
+// GetHTTPTool returns a user-defined HTTP tool
+func (db *DB) GetHTTPTool(ctx context.Context, toolID int64) (*generated.HttpTool, error) {
+	var tool generated.HttpTool
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		tool, err = q.GetHTTPTool(ctx, toolID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tool, nil
+}
+
+// httpToolSecretName names the secret holding the value of a header of an
+// HTTP tool.
+func httpToolSecretName(toolID int64, header string) string {
+	return fmt.Sprintf("http_tool/%d/%s", toolID, header)
+}
+
+// HTTPToolHeaders returns the headers added to each call of an HTTP tool,
+// with their values read from the secrets store.
+func (db *DB) HTTPToolHeaders(ctx context.Context, tool generated.HttpTool) (map[string]string, error) {
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(tool.Headers), &headers); err != nil {
+		return nil, fmt.Errorf("invalid headers of HTTP tool %s: %w", tool.Name, err)
+	}
+	if !tool.HeadersEncrypted {
+		return headers, nil
+	}
+	for header, secret := range headers {
+		value, err := db.GetSecret(ctx, secret)
+		if err != nil {
+			return nil, err
+		}
+		headers[header] = value
+	}
+	return headers, nil
+}
+
+// setHTTPToolHeaders replaces the headers of an HTTP tool, whose current
+// headers column is old, storing their values as secrets. It returns the new
+// headers column.
+func setHTTPToolHeaders(ctx context.Context, q *generated.Queries, c *secrets.Cipher, toolID int64, old string, headers map[string]string) (string, error) {
+	var previous map[string]string
+	json.Unmarshal([]byte(old), &previous)
+	for header := range previous {
+		if err := q.DeleteSecret(ctx, httpToolSecretName(toolID, header)); err != nil {
+			return "", err
+		}
+	}
+	refs := make(map[string]string, len(headers))
+	for header, value := range headers {
+		sealed, err := c.Seal(value)
+		if err != nil {
+			return "", err
+		}
+		refs[header] = httpToolSecretName(toolID, header)
+		if err := q.SetSecret(ctx, generated.SetSecretParams{Name: refs[header], Value: sealed}); err != nil {
+			return "", err
+		}
+	}
+	data, err := json.Marshal(refs)
+	if err != nil {
+		return "", err
+	}
+	return string(data), q.SetHTTPToolHeaders(ctx, generated.SetHTTPToolHeadersParams{Headers: string(data), ToolID: toolID})
+}
+
+// CreateHTTPTool adds a user-defined HTTP tool. The values of its headers are
+// kept in the secrets store.
+func (db *DB) CreateHTTPTool(ctx context.Context, params generated.CreateHTTPToolParams, headers map[string]string) (*generated.HttpTool, error) {
+	c, err := db.secretsCipher(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var tool generated.HttpTool
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		if tool, err = q.CreateHTTPTool(ctx, params); err != nil {
+			return err
+		}
+		tool.Headers, err = setHTTPToolHeaders(ctx, q, c, tool.ToolID, tool.Headers, headers)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tool, nil
+}
+
+// UpdateHTTPTool replaces the definition of a user-defined HTTP tool, and its
+// headers unless they are nil.
+func (db *DB) UpdateHTTPTool(ctx context.Context, params generated.UpdateHTTPToolParams, headers map[string]string) (*generated.HttpTool, error) {
+	c, err := db.secretsCipher(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var tool generated.HttpTool
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		if tool, err = q.UpdateHTTPTool(ctx, params); err != nil || headers == nil {
+			return err
+		}
+		tool.Headers, err = setHTTPToolHeaders(ctx, q, c, tool.ToolID, tool.Headers, headers)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tool, nil
+}
+
+// DeleteHTTPTool deletes a user-defined HTTP tool and the secrets of its headers
+func (db *DB) DeleteHTTPTool(ctx context.Context, toolID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		tool, err := q.GetHTTPTool(ctx, toolID)
+		if err != nil {
+			return err
+		}
+		var headers map[string]string
+		json.Unmarshal([]byte(tool.Headers), &headers)
+		for header := range headers {
+			if err := q.DeleteSecret(ctx, httpToolSecretName(toolID, header)); err != nil {
+				return err
+			}
+		}
+		return q.DeleteHTTPTool(ctx, toolID)
+	})
+}
+
+// recentDirectoriesKept is how many recently used directories are kept per
+// user; older ones are forgotten unless they are favorites.
+const recentDirectoriesKept = 50
+
+// TouchUserDirectory records that a user used a working directory
+func (db *DB) TouchUserDirectory(ctx context.Context, owner, path string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		if err := q.TouchUserDirectory(ctx, generated.TouchUserDirectoryParams{Owner: owner, Path: path}); err != nil {
+			return err
 		}
+		return q.PruneUserDirectories(ctx, generated.PruneUserDirectoriesParams{Owner: owner, Owner_2: owner, Limit: recentDirectoriesKept})
+	})
+}
+
+// SetUserDirectoryFavorite pins or unpins a working directory for a user
+func (db *DB) SetUserDirectoryFavorite(ctx context.Context, owner, path string, favorite bool) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetUserDirectoryFavorite(ctx, generated.SetUserDirectoryFavoriteParams{Owner: owner, Path: path, Favorite: favorite})
+	})
+}
+
+// ListRecentUserDirectories returns a user's most recently used working directories, newest first
+func (db *DB) ListRecentUserDirectories(ctx context.Context, owner string, limit int64) ([]generated.UserDirectory, error) {
+	var dirs []generated.UserDirectory
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		dirs, err = q.ListRecentUserDirectories(ctx, generated.ListRecentUserDirectoriesParams{Owner: owner, Limit: limit})
+		return err
+	})
+	return dirs, err
+}
+
+// ListFavoriteUserDirectories returns a user's favorite working directories ordered by path
+func (db *DB) ListFavoriteUserDirectories(ctx context.Context, owner string) ([]generated.UserDirectory, error) {
+	var dirs []generated.UserDirectory
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		dirs, err = q.ListFavoriteUserDirectories(ctx, owner)
+		return err
+	})
+	return dirs, err
+}
+
+// SavePushSubscription stores a browser's Web Push subscription for a user,
+// replacing any earlier subscription with the same endpoint
+func (db *DB) SavePushSubscription(ctx context.Context, params generated.UpsertPushSubscriptionParams) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpsertPushSubscription(ctx, params)
+	})
+}
+
+// ListPushSubscriptions returns a user's Web Push subscriptions
+func (db *DB) ListPushSubscriptions(ctx context.Context, owner string) ([]generated.PushSubscription, error) {
+	var subs []generated.PushSubscription
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		subs, err = q.ListPushSubscriptions(ctx, owner)
+		return err
+	})
+	return subs, err
+}
+
+// DeletePushSubscription removes one of a user's Web Push subscriptions
+func (db *DB) DeletePushSubscription(ctx context.Context, owner, endpoint string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeletePushSubscription(ctx, generated.DeletePushSubscriptionParams{Endpoint: endpoint, Owner: owner})
+	})
+}
+
+// InsertConversationEvent appends an event to a conversation's timeline
+func (db *DB) InsertConversationEvent(ctx context.Context, params generated.InsertConversationEventParams) (*generated.ConversationEvent, error) {
+	var event generated.ConversationEvent
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		event, err = q.InsertConversationEvent(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// ListConversationEvents returns up to limit events of a conversation's
+// timeline after the event afterID, oldest first
+func (db *DB) ListConversationEvents(ctx context.Context, conversationID string, afterID, limit int64) ([]generated.ConversationEvent, error) {
+	var events []generated.ConversationEvent
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		events, err = q.ListConversationEvents(ctx, generated.ListConversationEventsParams{
+			ConversationID: conversationID,
+			AfterID:        afterID,
+			Limit:          limit,
+		})
+		return err
+	})
+	return events, err
+}
+
+// AcquireConversationLease takes or renews holder's lease on a conversation
+// for ttl. It reports false if another holder's lease has not expired.
+func (db *DB) AcquireConversationLease(ctx context.Context, conversationID, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		_, err := q.AcquireConversationLease(ctx, generated.AcquireConversationLeaseParams{
+			ConversationID: conversationID,
+			Holder:         holder,
+			ExpiresAt:      now.Add(ttl),
+			Now:            now,
+		})
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
 
-		// Some other error occurred
-		return "", "", err
+// GetConversationLease returns the lease on a conversation, or nil if no
+// instance holds one. The lease may have expired.
+func (db *DB) GetConversationLease(ctx context.Context, conversationID string) (*generated.ConversationLease, error) {
+	var lease generated.ConversationLease
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		lease, err = q.GetConversationLease(ctx, conversationID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
+	return &lease, nil
+}
 
-	return "", "", fmt.Errorf("failed to create unique subagent slug after 100 attempts")
+// ReleaseConversationLease gives up holder's lease on a conversation.
+func (db *DB) ReleaseConversationLease(ctx context.Context, conversationID, holder string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.ReleaseConversationLease(ctx, generated.ReleaseConversationLeaseParams{
+			ConversationID: conversationID,
+			Holder:         holder,
+		})
+	})
 }
 
-// InsertLLMRequest inserts a new LLM request record
-func (db *DB) InsertLLMRequest(ctx context.Context, params generated.InsertLLMRequestParams) (*generated.LlmRequest, error) {
-	var request generated.LlmRequest
-	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+// GetNotificationPreferences returns a user's notification preferences for a
+// conversation, or their defaults when conversationID is "". It returns nil
+// if none are saved.
+func (db *DB) GetNotificationPreferences(ctx context.Context, owner, conversationID string) (*generated.NotificationPreference, error) {
+	var prefs generated.NotificationPreference
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		prefs, err = q.GetNotificationPreferences(ctx, generated.GetNotificationPreferencesParams{Owner: owner, ConversationID: conversationID})
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// SaveNotificationPreferences stores a user's notification preferences
+func (db *DB) SaveNotificationPreferences(ctx context.Context, params generated.UpsertNotificationPreferencesParams) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
+		return q.UpsertNotificationPreferences(ctx, params)
+	})
+}
 
-		// If we have a conversation ID and request body, try to find common prefix
-		if params.ConversationID != nil && params.RequestBody != nil {
-			// Get the last request for this conversation
-			lastReq, err := q.GetLastRequestForConversation(ctx, params.ConversationID)
-			if err == nil {
-				// Found a previous request - compute common prefix
-				prefixLen, fullPrevBody := computeSharedPrefixLength(lastReq, *params.RequestBody)
-				if prefixLen > 0 {
-					// Store only the suffix
-					suffix := (*params.RequestBody)[prefixLen:]
-					params.RequestBody = &suffix
-					params.PrefixRequestID = &lastReq.ID
-					prefixLen64 := int64(prefixLen)
-					params.PrefixLength = &prefixLen64
-					_ = fullPrevBody // silence unused warning, used for computing prefix
-				}
-			}
-			// If no previous request found or error, just store the full body
-		}
+// DeleteNotificationPreferences removes a user's notification preferences,
+// so that the defaults apply
+func (db *DB) DeleteNotificationPreferences(ctx context.Context, owner, conversationID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteNotificationPreferences(ctx, generated.DeleteNotificationPreferencesParams{Owner: owner, ConversationID: conversationID})
+	})
+}
 
+// CreateWorkspace records a repository cloned into the workspaces directory
+func (db *DB) CreateWorkspace(ctx context.Context, params generated.CreateWorkspaceParams) (*generated.Workspace, error) {
+	var workspace generated.Workspace
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
 		var err error
-		request, err = q.InsertLLMRequest(ctx, params)
+		workspace, err = q.CreateWorkspace(ctx, params)
 		return err
 	})
-	return &request, err
+	if err != nil {
+		return nil, err
+	}
+	return &workspace, nil
 }
 
-// computeSharedPrefixLength computes the length of the shared prefix between
-// the full previous request body (reconstructed by walking the chain) and the new request body.
-// It returns the prefix length and the fully reconstructed previous body.
-func computeSharedPrefixLength(prevReq generated.LlmRequest, newBody string) (int, string) {
-	// Get the stored body (which may be just a suffix if prevReq has a prefix reference)
-	prevBody := ""
-	if prevReq.RequestBody != nil {
-		prevBody = *prevReq.RequestBody
+// FindWorkspaceForDirectory returns the cloned workspace containing dir, or
+// nil if dir isn't in one.
+func (db *DB) FindWorkspaceForDirectory(ctx context.Context, dir string) (*generated.Workspace, error) {
+	if dir == "" {
+		return nil, nil
 	}
-
-	// If the previous request has a prefix reference, we need to account for that
-	// by prepending the prefix length worth of bytes from the new body.
-	// This works because in a conversation, request N+1 typically starts with
-	// all of request N plus new content at the end.
-	if prevReq.PrefixLength != nil && *prevReq.PrefixLength > 0 {
-		// The previous request's full body would be:
-		// [first prefix_length bytes that match its parent] + [stored suffix]
-		// If the new body is a continuation, its first prefix_length bytes
-		// should match those same bytes.
-		prefixLen := int(*prevReq.PrefixLength)
-		if prefixLen <= len(newBody) {
-			prevBody = newBody[:prefixLen] + prevBody
+	var workspaces []generated.Workspace
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		workspaces, err = q.ListWorkspaces(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Clean(dir)
+	for i := range workspaces {
+		if isWithinDir(dir, workspaces[i].Path) {
+			return &workspaces[i], nil
 		}
 	}
+	return nil, nil
+}
 
-	// Compute byte-by-byte shared prefix between reconstructed prevBody and newBody
-	minLen := len(prevBody)
-	if len(newBody) < minLen {
-		minLen = len(newBody)
-	}
+// Batch modes
+const (
+	BatchModeSequential = "sequential"
+	BatchModeParallel   = "parallel"
+)
 
-	prefixLen := 0
-	for i := 0; i < minLen; i++ {
-		if prevBody[i] != newBody[i] {
-			break
-		}
-		prefixLen++
-	}
+// Batch task statuses
+const (
+	BatchTaskPending  = "pending"
+	BatchTaskRunning  = "running"
+	BatchTaskDone     = "done"
+	BatchTaskFailed   = "failed"
+	BatchTaskCanceled = "canceled"
+)
 
-	// Only use prefix deduplication if we save meaningful space
-	// (at least 100 bytes saved)
-	if prefixLen < 100 {
-		return 0, prevBody
+// CreateBatch queues a batch with a pending task for each prompt
+func (db *DB) CreateBatch(ctx context.Context, params generated.CreateBatchParams, prompts []string) (*generated.Batch, error) {
+	var batch generated.Batch
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		batch, err = q.CreateBatch(ctx, params)
+		if err != nil {
+			return err
+		}
+		for i, prompt := range prompts {
+			if _, err := q.CreateBatchTask(ctx, generated.CreateBatchTaskParams{BatchID: batch.BatchID, Position: int64(i), Prompt: prompt}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return prefixLen, prevBody
+	return &batch, nil
 }
 
-// ListRecentLLMRequests returns the most recent LLM requests
-func (db *DB) ListRecentLLMRequests(ctx context.Context, limit int64) ([]generated.ListRecentLLMRequestsRow, error) {
-	var requests []generated.ListRecentLLMRequestsRow
+// GetBatch returns a batch, or nil if it doesn't exist
+func (db *DB) GetBatch(ctx context.Context, batchID int64) (*generated.Batch, error) {
+	var batch generated.Batch
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
-		requests, err = q.ListRecentLLMRequests(ctx, limit)
+		batch, err = q.GetBatch(ctx, batchID)
 		return err
 	})
-	return requests, err
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
 }
 
-// GetLLMRequestBody returns the raw request body for a request
-func (db *DB) GetLLMRequestBody(ctx context.Context, id int64) (*string, error) {
-	var body *string
+// ListBatches returns a user's most recent batches, newest first
+func (db *DB) ListBatches(ctx context.Context, owner string, limit int64) ([]generated.Batch, error) {
+	var batches []generated.Batch
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
-		body, err = q.GetLLMRequestBody(ctx, id)
+		batches, err = q.ListBatches(ctx, generated.ListBatchesParams{Owner: owner, Limit: limit})
 		return err
 	})
-	return body, err
+	return batches, err
 }
 
-// GetLLMResponseBody returns the raw response body for a request
-func (db *DB) GetLLMResponseBody(ctx context.Context, id int64) (*string, error) {
-	var body *string
+// ListUnfinishedBatches returns the batches with tasks still pending or running
+func (db *DB) ListUnfinishedBatches(ctx context.Context) ([]generated.Batch, error) {
+	var batches []generated.Batch
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
-		body, err = q.GetLLMResponseBody(ctx, id)
+		batches, err = q.ListUnfinishedBatches(ctx)
 		return err
 	})
-	return body, err
+	return batches, err
 }
 
-// GetFullLLMRequestBody reconstructs the full request body for a request,
-// following the prefix chain if necessary.
-func (db *DB) GetFullLLMRequestBody(ctx context.Context, requestID int64) (string, error) {
-	var result string
+// ListBatchTasks returns the tasks of a batch in order
+func (db *DB) ListBatchTasks(ctx context.Context, batchID int64) ([]generated.BatchTask, error) {
+	var tasks []generated.BatchTask
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
-		return reconstructRequestBody(ctx, q, requestID, &result)
+		var err error
+		tasks, err = q.ListBatchTasks(ctx, batchID)
+		return err
 	})
-	return result, err
+	return tasks, err
 }
 
-// reconstructRequestBody recursively reconstructs the full request body
-func reconstructRequestBody(ctx context.Context, q *generated.Queries, requestID int64, result *string) error {
-	req, err := q.GetLLMRequestByID(ctx, requestID)
-	if err != nil {
+// StartBatchTask marks a pending task as running in a conversation. It
+// returns false if the task is no longer pending, e.g. because the batch was
+// canceled.
+func (db *DB) StartBatchTask(ctx context.Context, taskID int64, conversationID string) (bool, error) {
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		_, err := q.StartBatchTask(ctx, generated.StartBatchTaskParams{ConversationID: &conversationID, TaskID: taskID})
 		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
 	}
+	return err == nil, err
+}
 
-	suffix := ""
-	if req.RequestBody != nil {
-		suffix = *req.RequestBody
-	}
+// FinishBatchTask records the outcome of a pending or running task
+func (db *DB) FinishBatchTask(ctx context.Context, taskID int64, status, errText string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.FinishBatchTask(ctx, generated.FinishBatchTaskParams{Status: status, Error: errText, TaskID: taskID})
+	})
+}
 
-	if req.PrefixRequestID == nil || req.PrefixLength == nil || *req.PrefixLength == 0 {
-		// No prefix reference - the stored body is the full body
-		*result = suffix
-		return nil
-	}
+// CancelBatch cancels the pending tasks of a batch
+func (db *DB) CancelBatch(ctx context.Context, batchID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.CancelBatchTasks(ctx, batchID)
+	})
+}
 
-	// Recursively get the parent's full body
-	var parentBody string
-	if err := reconstructRequestBody(ctx, q, *req.PrefixRequestID, &parentBody); err != nil {
-		return err
-	}
+// UpdateBatchProviderStatus records the last polled status of a provider batch
+func (db *DB) UpdateBatchProviderStatus(ctx context.Context, batchID int64, status string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateBatchProviderStatus(ctx, generated.UpdateBatchProviderStatusParams{ProviderStatus: status, BatchID: batchID})
+	})
+}
 
-	// The full body is the first prefix_length bytes from the parent + our suffix
-	prefixLen := int(*req.PrefixLength)
-	if prefixLen > len(parentBody) {
-		prefixLen = len(parentBody)
+// CreateComparison records a comparison of two conversations
+func (db *DB) CreateComparison(ctx context.Context, params generated.CreateComparisonParams) (*generated.Comparison, error) {
+	var comparison generated.Comparison
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		comparison, err = q.CreateComparison(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	*result = parentBody[:prefixLen] + suffix
-	return nil
+	return &comparison, nil
 }
 
-// GetModels returns all models from the database
-func (db *DB) GetModels(ctx context.Context) ([]generated.Model, error) {
-	var models []generated.Model
+// GetComparison returns a comparison, or nil if it doesn't exist
+func (db *DB) GetComparison(ctx context.Context, comparisonID int64) (*generated.Comparison, error) {
+	var comparison generated.Comparison
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
-		models, err = q.GetModels(ctx)
+		comparison, err = q.GetComparison(ctx, comparisonID)
 		return err
 	})
-	return models, err
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comparison, nil
 }
 
-// GetModel returns a model by ID
-func (db *DB) GetModel(ctx context.Context, modelID string) (*generated.Model, error) {
-	var model generated.Model
+// ListComparisons returns a user's most recent comparisons, newest first
+func (db *DB) ListComparisons(ctx context.Context, owner string, limit int64) ([]generated.Comparison, error) {
+	var comparisons []generated.Comparison
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
-		model, err = q.GetModel(ctx, modelID)
+		comparisons, err = q.ListComparisons(ctx, generated.ListComparisonsParams{Owner: owner, Limit: limit})
+		return err
+	})
+	return comparisons, err
+}
+
+// ChooseComparison records the conversation the user picked to continue. It
+// returns nil if a conversation was picked already.
+func (db *DB) ChooseComparison(ctx context.Context, comparisonID int64, conversationID string) (*generated.Comparison, error) {
+	var comparison generated.Comparison
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		comparison, err = q.ChooseComparison(ctx, generated.ChooseComparisonParams{ChosenConversationID: &conversationID, ComparisonID: comparisonID})
 		return err
 	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &model, nil
+	return &comparison, nil
 }
 
-// CreateModel creates a new model
-func (db *DB) CreateModel(ctx context.Context, params generated.CreateModelParams) (*generated.Model, error) {
-	var model generated.Model
+// CreateReplay records a replay of a conversation
+func (db *DB) CreateReplay(ctx context.Context, params generated.CreateReplayParams) (*generated.Replay, error) {
+	var replay generated.Replay
 	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
 		var err error
-		model, err = q.CreateModel(ctx, params)
+		replay, err = q.CreateReplay(ctx, params)
 		return err
 	})
 	if err != nil {
 		return nil, err
 	}
-	return &model, nil
+	return &replay, nil
 }
 
-// UpdateModel updates a model
-func (db *DB) UpdateModel(ctx context.Context, params generated.UpdateModelParams) (*generated.Model, error) {
-	var model generated.Model
-	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
-		q := generated.New(tx.Conn())
+// GetReplay returns a replay, or nil if it doesn't exist
+func (db *DB) GetReplay(ctx context.Context, replayID int64) (*generated.Replay, error) {
+	var replay generated.Replay
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
 		var err error
-		model, err = q.UpdateModel(ctx, params)
+		replay, err = q.GetReplay(ctx, replayID)
 		return err
 	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &model, nil
+	return &replay, nil
 }
 
-// DeleteModel deletes a model
-func (db *DB) DeleteModel(ctx context.Context, modelID string) error {
-	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
-		q := generated.New(tx.Conn())
-		return q.DeleteModel(ctx, modelID)
+// ListConversationReplays returns the replays of a conversation, newest first
+func (db *DB) ListConversationReplays(ctx context.Context, conversationID string) ([]generated.Replay, error) {
+	var replays []generated.Replay
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		replays, err = q.ListConversationReplays(ctx, conversationID)
+		return err
 	})
+	return replays, err
 }
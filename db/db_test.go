@@ -631,3 +631,130 @@ func TestLLMRequestOpenAIStyle(t *testing.T) {
 		req2FullLen-req2StoredLen,
 		100.0*float64(req2FullLen-req2StoredLen)/float64(req2FullLen))
 }
+
+func TestDB_CheckMigrations(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := New(Config{DSN: tmpDir + "/test.db"})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	status, err := db.CheckMigrations(ctx)
+	if err != nil {
+		t.Fatalf("CheckMigrations() error = %v", err)
+	}
+	if status.Version != 0 || status.Latest == 0 || len(status.Pending) == 0 || status.UpToDate() {
+		t.Fatalf("unexpected status for a new database: %+v", status)
+	}
+	if status.Pending[0] != "001-conversations.sql" {
+		t.Errorf("expected pending migrations in order, got %v", status.Pending)
+	}
+	latest := status.Latest
+
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	status, err = db.CheckMigrations(ctx)
+	if err != nil {
+		t.Fatalf("CheckMigrations() error = %v", err)
+	}
+	if !status.UpToDate() || status.Version != latest {
+		t.Errorf("expected an up to date schema at version %d, got %+v", latest, status)
+	}
+
+	// A migration applied by a newer release
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		_, err := tx.Exec("INSERT INTO migrations (migration_number, migration_name) VALUES (?, ?)", latest+1, "future.sql")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = db.CheckMigrations(ctx)
+	if err != nil {
+		t.Fatalf("CheckMigrations() error = %v", err)
+	}
+	if status.UpToDate() || len(status.Unknown) != 1 || status.Unknown[0] != latest+1 || status.Version != latest+1 {
+		t.Errorf("expected the newer migration to be reported, got %+v", status)
+	}
+	if err := db.Migrate(ctx); err != nil {
+		t.Errorf("Migrate() should tolerate newer migrations, got %v", err)
+	}
+}
+
+func TestUserDirectoriesPruned(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := db.SetUserDirectoryFavorite(ctx, "alice", "/favorite", true); err != nil {
+		t.Fatal(err)
+	}
+	for i := range recentDirectoriesKept + 5 {
+		if err := db.TouchUserDirectory(ctx, "alice", fmt.Sprintf("/dir%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recent, err := db.ListRecentUserDirectories(ctx, "alice", 2*recentDirectoriesKept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != recentDirectoriesKept {
+		t.Errorf("kept %d recent directories, want %d", len(recent), recentDirectoriesKept)
+	}
+	favorites, err := db.ListFavoriteUserDirectories(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(favorites) != 1 || favorites[0].Path != "/favorite" || favorites[0].LastUsedAt != nil {
+		t.Errorf("favorites = %+v, want the unused /favorite", favorites)
+	}
+}
+
+func TestConversationLease(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	conv, err := db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquire := func(holder string, ttl time.Duration) bool {
+		t.Helper()
+		ok, err := db.AcquireConversationLease(ctx, conv.ConversationID, holder, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if !acquire("a", time.Minute) {
+		t.Fatal("a could not take a free lease")
+	}
+	if !acquire("a", time.Minute) {
+		t.Error("a could not renew its lease")
+	}
+	if acquire("b", time.Minute) {
+		t.Error("b took a's unexpired lease")
+	}
+
+	// An expired lease can be taken over, and only its holder releases it
+	if !acquire("a", -time.Second) || !acquire("b", time.Minute) {
+		t.Fatal("b could not take over a's expired lease")
+	}
+	if err := db.ReleaseConversationLease(ctx, conv.ConversationID, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if lease, err := db.GetConversationLease(ctx, conv.ConversationID); err != nil || lease == nil || lease.Holder != "b" {
+		t.Fatalf("lease = %+v, %v; want b's", lease, err)
+	}
+	if err := db.ReleaseConversationLease(ctx, conv.ConversationID, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if !acquire("a", time.Minute) {
+		t.Error("a could not take a released lease")
+	}
+}
@@ -7,6 +7,7 @@ package generated
 
 import (
 	"context"
+	"time"
 )
 
 const archiveConversation = `-- name: ArchiveConversation :one
@@ -337,6 +338,64 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 	return items, nil
 }
 
+const listQuickSearchConversations = `-- name: ListQuickSearchConversations :many
+SELECT c.conversation_id, c.slug, c.user_initiated, c.created_at, c.updated_at, c.cwd, c.archived, c.parent_conversation_id, c.model, (
+    SELECT COUNT(*) FROM messages m
+    WHERE m.conversation_id = c.conversation_id AND m.type = 'user'
+) AS user_message_count
+FROM conversations c
+WHERE c.archived = FALSE AND c.parent_conversation_id IS NULL
+ORDER BY c.updated_at DESC
+LIMIT ?
+`
+
+type ListQuickSearchConversationsRow struct {
+	ConversationID       string    `json:"conversation_id"`
+	Slug                 *string   `json:"slug"`
+	UserInitiated        bool      `json:"user_initiated"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+	Cwd                  *string   `json:"cwd"`
+	Archived             bool      `json:"archived"`
+	ParentConversationID *string   `json:"parent_conversation_id"`
+	Model                *string   `json:"model"`
+	UserMessageCount     int64     `json:"user_message_count"`
+}
+
+func (q *Queries) ListQuickSearchConversations(ctx context.Context, limit int64) ([]ListQuickSearchConversationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listQuickSearchConversations, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListQuickSearchConversationsRow{}
+	for rows.Next() {
+		var i ListQuickSearchConversationsRow
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.Slug,
+			&i.UserInitiated,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Cwd,
+			&i.Archived,
+			&i.ParentConversationID,
+			&i.Model,
+			&i.UserMessageCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const searchArchivedConversations = `-- name: SearchArchivedConversations :many
 SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
 WHERE slug LIKE '%' || ? || '%' AND archived = TRUE
@@ -7,6 +7,7 @@ package generated
 
 import (
 	"context"
+	"time"
 )
 
 const countMessagesByType = `-- name: CountMessagesByType :one
@@ -104,6 +105,21 @@ func (q *Queries) DeleteMessage(ctx context.Context, messageID string) error {
 	return err
 }
 
+const deleteMessagesFrom = `-- name: DeleteMessagesFrom :exec
+DELETE FROM messages
+WHERE conversation_id = ? AND sequence_id >= ?
+`
+
+type DeleteMessagesFromParams struct {
+	ConversationID string `json:"conversation_id"`
+	SequenceID     int64  `json:"sequence_id"`
+}
+
+func (q *Queries) DeleteMessagesFrom(ctx context.Context, arg DeleteMessagesFromParams) error {
+	_, err := q.db.ExecContext(ctx, deleteMessagesFrom, arg.ConversationID, arg.SequenceID)
+	return err
+}
+
 const getLatestMessage = `-- name: GetLatestMessage :one
 SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
 WHERE conversation_id = ?
@@ -153,11 +169,15 @@ func (q *Queries) GetMessage(ctx context.Context, messageID string) (Message, er
 }
 
 const getNextSequenceID = `-- name: GetNextSequenceID :one
-SELECT COALESCE(MAX(sequence_id), 0) + 1 
-FROM messages 
-WHERE conversation_id = ?
+SELECT COALESCE(MAX(sequence_id), 0) + 1
+FROM (
+    SELECT sequence_id FROM messages WHERE conversation_id = ?1
+    UNION ALL
+    SELECT sequence_id FROM truncated_messages WHERE conversation_id = ?1
+)
 `
 
+// Truncated messages are included so that sequence IDs are never reused.
 func (q *Queries) GetNextSequenceID(ctx context.Context, conversationID string) (int64, error) {
 	row := q.db.QueryRowContext(ctx, getNextSequenceID, conversationID)
 	var column_1 int64
@@ -250,6 +270,54 @@ func (q *Queries) ListMessagesByType(ctx context.Context, arg ListMessagesByType
 	return items, nil
 }
 
+const listMessagesForAnalytics = `-- name: ListMessagesForAnalytics :many
+SELECT m.conversation_id, m.type, m.llm_data, m.usage_data, m.created_at, c.model
+FROM messages m
+JOIN conversations c ON c.conversation_id = m.conversation_id
+WHERE m.created_at >= ? AND m.type IN ('user', 'agent')
+ORDER BY m.conversation_id, m.sequence_id
+`
+
+type ListMessagesForAnalyticsRow struct {
+	ConversationID string    `json:"conversation_id"`
+	Type           string    `json:"type"`
+	LlmData        *string   `json:"llm_data"`
+	UsageData      *string   `json:"usage_data"`
+	CreatedAt      time.Time `json:"created_at"`
+	Model          *string   `json:"model"`
+}
+
+// User and agent messages created since a time, with their conversation's model.
+func (q *Queries) ListMessagesForAnalytics(ctx context.Context, createdAt time.Time) ([]ListMessagesForAnalyticsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listMessagesForAnalytics, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessagesForAnalyticsRow{}
+	for rows.Next() {
+		var i ListMessagesForAnalyticsRow
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.Type,
+			&i.LlmData,
+			&i.UsageData,
+			&i.CreatedAt,
+			&i.Model,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listMessagesForContext = `-- name: ListMessagesForContext :many
 SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
 WHERE conversation_id = ? AND excluded_from_context = FALSE
@@ -381,3 +449,37 @@ func (q *Queries) ListMessagesSince(ctx context.Context, arg ListMessagesSincePa
 	}
 	return items, nil
 }
+
+const restoreMessage = `-- name: RestoreMessage :exec
+INSERT INTO messages (message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type RestoreMessageParams struct {
+	MessageID           string    `json:"message_id"`
+	ConversationID      string    `json:"conversation_id"`
+	SequenceID          int64     `json:"sequence_id"`
+	Type                string    `json:"type"`
+	LlmData             *string   `json:"llm_data"`
+	UserData            *string   `json:"user_data"`
+	UsageData           *string   `json:"usage_data"`
+	CreatedAt           time.Time `json:"created_at"`
+	DisplayData         *string   `json:"display_data"`
+	ExcludedFromContext bool      `json:"excluded_from_context"`
+}
+
+func (q *Queries) RestoreMessage(ctx context.Context, arg RestoreMessageParams) error {
+	_, err := q.db.ExecContext(ctx, restoreMessage,
+		arg.MessageID,
+		arg.ConversationID,
+		arg.SequenceID,
+		arg.Type,
+		arg.LlmData,
+		arg.UserData,
+		arg.UsageData,
+		arg.CreatedAt,
+		arg.DisplayData,
+		arg.ExcludedFromContext,
+	)
+	return err
+}
@@ -8,6 +8,79 @@ import (
 	"time"
 )
 
+type ApiToken struct {
+	TokenID    int64      `json:"token_id"`
+	Owner      string     `json:"owner"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"token_hash"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+type AuditLog struct {
+	AuditID        int64     `json:"audit_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Actor          string    `json:"actor"`
+	Action         string    `json:"action"`
+	ConversationID string    `json:"conversation_id"`
+	Details        string    `json:"details"`
+}
+
+type Batch struct {
+	BatchID         int64     `json:"batch_id"`
+	Owner           string    `json:"owner"`
+	Mode            string    `json:"mode"`
+	Model           string    `json:"model"`
+	Cwd             string    `json:"cwd"`
+	ConversationID  *string   `json:"conversation_id"`
+	CreatedAt       time.Time `json:"created_at"`
+	ProviderBatchID *string   `json:"provider_batch_id"`
+	ProviderStatus  string    `json:"provider_status"`
+}
+
+type BatchTask struct {
+	TaskID         int64      `json:"task_id"`
+	BatchID        int64      `json:"batch_id"`
+	Position       int64      `json:"position"`
+	Prompt         string     `json:"prompt"`
+	ConversationID *string    `json:"conversation_id"`
+	Status         string     `json:"status"`
+	Error          string     `json:"error"`
+	StartedAt      *time.Time `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at"`
+}
+
+type BridgeSession struct {
+	ConversationID string    `json:"conversation_id"`
+	SessionID      string    `json:"session_id"`
+	WorkingDir     string    `json:"working_dir"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type CodeChunk struct {
+	ChunkID   int64  `json:"chunk_id"`
+	Root      string `json:"root"`
+	Path      string `json:"path"`
+	FileHash  string `json:"file_hash"`
+	StartLine int64  `json:"start_line"`
+	EndLine   int64  `json:"end_line"`
+	Content   string `json:"content"`
+	Embedder  string `json:"embedder"`
+	Embedding []byte `json:"embedding"`
+}
+
+type Comparison struct {
+	ComparisonID         int64      `json:"comparison_id"`
+	Owner                string     `json:"owner"`
+	Prompt               string     `json:"prompt"`
+	ConversationA        string     `json:"conversation_a"`
+	ConversationB        string     `json:"conversation_b"`
+	ChosenConversationID *string    `json:"chosen_conversation_id"`
+	CreatedAt            time.Time  `json:"created_at"`
+	ChosenAt             *time.Time `json:"chosen_at"`
+}
+
 type Conversation struct {
 	ConversationID       string    `json:"conversation_id"`
 	Slug                 *string   `json:"slug"`
@@ -20,6 +93,136 @@ type Conversation struct {
 	Model                *string   `json:"model"`
 }
 
+type ConversationDirectory struct {
+	ConversationID string    `json:"conversation_id"`
+	Path           string    `json:"path"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ConversationEnv struct {
+	ConversationID string    `json:"conversation_id"`
+	Name           string    `json:"name"`
+	Value          string    `json:"value"`
+	Secret         bool      `json:"secret"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Encrypted      bool      `json:"encrypted"`
+}
+
+type ConversationEvent struct {
+	EventID        int64     `json:"event_id"`
+	ConversationID string    `json:"conversation_id"`
+	Type           string    `json:"type"`
+	RefID          string    `json:"ref_id"`
+	Name           string    `json:"name"`
+	DurationMs     *int64    `json:"duration_ms"`
+	Error          string    `json:"error"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ConversationHook struct {
+	HookID         int64     `json:"hook_id"`
+	ConversationID string    `json:"conversation_id"`
+	Event          string    `json:"event"`
+	Command        string    `json:"command"`
+	InjectOutput   bool      `json:"inject_output"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ConversationLease struct {
+	ConversationID string    `json:"conversation_id"`
+	Holder         string    `json:"holder"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+type ConversationPlan struct {
+	ConversationID string     `json:"conversation_id"`
+	Mode           string     `json:"mode"`
+	Plan           string     `json:"plan"`
+	ApprovedAt     *time.Time `json:"approved_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type ConversationRead struct {
+	Owner          string    `json:"owner"`
+	ConversationID string    `json:"conversation_id"`
+	SequenceID     int64     `json:"sequence_id"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ConversationSetting struct {
+	ConversationID       string    `json:"conversation_id"`
+	Temperature          *float64  `json:"temperature"`
+	TopP                 *float64  `json:"top_p"`
+	MaxTokens            *int64    `json:"max_tokens"`
+	UpdatedAt            time.Time `json:"updated_at"`
+	Sandbox              *string   `json:"sandbox"`
+	TurnTimeoutSeconds   *int64    `json:"turn_timeout_seconds"`
+	ToolTimeoutSeconds   *int64    `json:"tool_timeout_seconds"`
+	ReadOnly             bool      `json:"read_only"`
+	ApproveRiskyCommands bool      `json:"approve_risky_commands"`
+	WindowsPaths         bool      `json:"windows_paths"`
+	Timezone             *string   `json:"timezone"`
+	Locale               *string   `json:"locale"`
+	ResponseSchema       *string   `json:"response_schema"`
+}
+
+type ConversationSummary struct {
+	ConversationID string    `json:"conversation_id"`
+	Summary        string    `json:"summary"`
+	Files          string    `json:"files"`
+	SequenceID     int64     `json:"sequence_id"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ConversationTag struct {
+	ConversationID string    `json:"conversation_id"`
+	Tag            string    `json:"tag"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ConversationTemplate struct {
+	TemplateID  int64     `json:"template_id"`
+	Owner       string    `json:"owner"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Prompt      string    `json:"prompt"`
+	Config      string    `json:"config"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type ConversationTodo struct {
+	ConversationID string    `json:"conversation_id"`
+	Todos          string    `json:"todos"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type Draft struct {
+	ConversationID string    `json:"conversation_id"`
+	Text           string    `json:"text"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type HttpTool struct {
+	ToolID           int64     `json:"tool_id"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	InputSchema      string    `json:"input_schema"`
+	Url              string    `json:"url"`
+	Headers          string    `json:"headers"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	HeadersEncrypted bool      `json:"headers_encrypted"`
+}
+
+type InterruptedTurn struct {
+	ConversationID string    `json:"conversation_id"`
+	Reason         string    `json:"reason"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
 type LlmRequest struct {
 	ID              int64     `json:"id"`
 	ConversationID  *string   `json:"conversation_id"`
@@ -36,6 +239,13 @@ type LlmRequest struct {
 	PrefixLength    *int64    `json:"prefix_length"`
 }
 
+type Memory struct {
+	MemoryID  int64     `json:"memory_id"`
+	Directory string    `json:"directory"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Message struct {
 	MessageID           string    `json:"message_id"`
 	ConversationID      string    `json:"conversation_id"`
@@ -49,6 +259,30 @@ type Message struct {
 	ExcludedFromContext bool      `json:"excluded_from_context"`
 }
 
+type MessageAnnotation struct {
+	MessageID      string    `json:"message_id"`
+	ConversationID string    `json:"conversation_id"`
+	Rating         *string   `json:"rating"`
+	Flagged        bool      `json:"flagged"`
+	Note           string    `json:"note"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type MessageBlob struct {
+	Hash      string    `json:"hash"`
+	Data      []byte    `json:"data"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MessageContextFlag struct {
+	MessageID      string    `json:"message_id"`
+	ConversationID string    `json:"conversation_id"`
+	Flag           string    `json:"flag"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
 type Migration struct {
 	MigrationNumber int64      `json:"migration_number"`
 	MigrationName   string     `json:"migration_name"`
@@ -67,3 +301,126 @@ type Model struct {
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
+
+type NotificationPreference struct {
+	Owner          string    `json:"owner"`
+	ConversationID string    `json:"conversation_id"`
+	Preferences    string    `json:"preferences"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type Project struct {
+	Directory            string    `json:"directory"`
+	DefaultModel         *string   `json:"default_model"`
+	Env                  string    `json:"env"`
+	SetupCommands        string    `json:"setup_commands"`
+	Instructions         string    `json:"instructions"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+	SystemPromptTemplate string    `json:"system_prompt_template"`
+}
+
+type PushSubscription struct {
+	Endpoint  string    `json:"endpoint"`
+	Owner     string    `json:"owner"`
+	P256dh    string    `json:"p256dh"`
+	Auth      string    `json:"auth"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Replay struct {
+	ReplayID             int64     `json:"replay_id"`
+	Owner                string    `json:"owner"`
+	ConversationID       string    `json:"conversation_id"`
+	ReplayConversationID string    `json:"replay_conversation_id"`
+	BatchID              int64     `json:"batch_id"`
+	ReadOnly             bool      `json:"read_only"`
+	Sandbox              string    `json:"sandbox"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+type ResponseCache struct {
+	CacheKey  string     `json:"cache_key"`
+	ModelID   string     `json:"model_id"`
+	Response  string     `json:"response"`
+	Hits      int64      `json:"hits"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastHitAt *time.Time `json:"last_hit_at"`
+}
+
+type ReviewComment struct {
+	CommentID      int64     `json:"comment_id"`
+	ConversationID string    `json:"conversation_id"`
+	ParentID       *int64    `json:"parent_id"`
+	MessageID      *string   `json:"message_id"`
+	DiffID         string    `json:"diff_id"`
+	FilePath       string    `json:"file_path"`
+	StartLine      int64     `json:"start_line"`
+	EndLine        int64     `json:"end_line"`
+	Author         string    `json:"author"`
+	Body           string    `json:"body"`
+	Resolved       bool      `json:"resolved"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type Secret struct {
+	Name      string    `json:"name"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Setting struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Snippet struct {
+	SnippetID   int64     `json:"snippet_id"`
+	Owner       string    `json:"owner"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type ToolOutput struct {
+	ConversationID string    `json:"conversation_id"`
+	ToolUseID      string    `json:"tool_use_id"`
+	Output         string    `json:"output"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type TruncatedMessage struct {
+	MessageID           string    `json:"message_id"`
+	ConversationID      string    `json:"conversation_id"`
+	TruncationID        int64     `json:"truncation_id"`
+	SequenceID          int64     `json:"sequence_id"`
+	Type                string    `json:"type"`
+	LlmData             *string   `json:"llm_data"`
+	UserData            *string   `json:"user_data"`
+	UsageData           *string   `json:"usage_data"`
+	CreatedAt           time.Time `json:"created_at"`
+	DisplayData         *string   `json:"display_data"`
+	ExcludedFromContext bool      `json:"excluded_from_context"`
+	TruncatedAt         time.Time `json:"truncated_at"`
+}
+
+type UserDirectory struct {
+	Owner      string     `json:"owner"`
+	Path       string     `json:"path"`
+	Favorite   bool       `json:"favorite"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type Workspace struct {
+	Path      string    `json:"path"`
+	OriginUrl string    `json:"origin_url"`
+	Branch    string    `json:"branch"`
+	Owner     string    `json:"owner"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -518,3 +518,174 @@ func TestMessageService_ListMessagesByConversationPaginated(t *testing.T) {
 		messageIDs[msg.MessageID] = true
 	}
 }
+
+func TestMessageService_TruncateAndRestore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("truncate-test"), true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test conversation: %v", err)
+	}
+
+	create := func(text string) *generated.Message {
+		t.Helper()
+		msg, err := db.CreateMessage(ctx, CreateMessageParams{
+			ConversationID: conv.ConversationID,
+			Type:           MessageTypeUser,
+			LLMData:        map[string]string{"text": text},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		return msg
+	}
+	texts := func() []string {
+		t.Helper()
+		messages, err := db.ListMessages(ctx, conv.ConversationID)
+		if err != nil {
+			t.Fatalf("Failed to list messages: %v", err)
+		}
+		var out []string
+		for _, m := range messages {
+			var data map[string]string
+			if err := json.Unmarshal([]byte(*m.LlmData), &data); err != nil {
+				t.Fatalf("Failed to unmarshal message: %v", err)
+			}
+			out = append(out, data["text"])
+		}
+		return out
+	}
+
+	create("a")
+	b := create("b")
+	create("c")
+
+	n, err := db.TruncateMessages(ctx, conv.ConversationID, b.SequenceID)
+	if err != nil {
+		t.Fatalf("TruncateMessages() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 truncated messages, got %d", n)
+	}
+	if got := strings.Join(texts(), ","); got != "a" {
+		t.Errorf("Expected messages a after truncation, got %s", got)
+	}
+
+	// New messages never reuse truncated sequence IDs
+	d := create("d")
+	if d.SequenceID <= b.SequenceID+1 {
+		t.Errorf("Expected sequence ID after %d, got %d", b.SequenceID+1, d.SequenceID)
+	}
+
+	// Undo swaps the truncated tail back in
+	n, err = db.RestoreTruncatedMessages(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("RestoreTruncatedMessages() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 restored messages, got %d", n)
+	}
+	if got := strings.Join(texts(), ","); got != "a,b,c" {
+		t.Errorf("Expected messages a,b,c after restore, got %s", got)
+	}
+
+	// Undoing again redoes the truncation
+	if _, err := db.RestoreTruncatedMessages(ctx, conv.ConversationID); err != nil {
+		t.Fatalf("RestoreTruncatedMessages() error = %v", err)
+	}
+	if got := strings.Join(texts(), ","); got != "a,d" {
+		t.Errorf("Expected messages a,d after redo, got %s", got)
+	}
+
+	other, err := db.CreateConversation(ctx, stringPtr("no-truncation"), true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test conversation: %v", err)
+	}
+	if _, err := db.RestoreTruncatedMessages(ctx, other.ConversationID); err != ErrNoTruncation {
+		t.Errorf("Expected ErrNoTruncation, got %v", err)
+	}
+}
+
+func TestMessageService_LargePayloads(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("large-payloads"), true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test conversation: %v", err)
+	}
+	output := strings.Repeat("line of tool output\n", BlobSizeLimit/10)
+	created, err := db.CreateMessage(ctx, CreateMessageParams{
+		ConversationID: conv.ConversationID,
+		Type:           MessageTypeTool,
+		LLMData:        map[string]string{"output": output},
+		DisplayData:    map[string]string{"output": output},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	want, _ := json.Marshal(map[string]string{"output": output})
+	if *created.LlmData != string(want) {
+		t.Error("created message does not have its payload")
+	}
+
+	// The messages table holds a reference to the compressed payload
+	var stored string
+	var blobSize int
+	err = db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		if err := rx.QueryRow("SELECT llm_data FROM messages WHERE message_id = ?", created.MessageID).Scan(&stored); err != nil {
+			return err
+		}
+		return rx.QueryRow("SELECT length(data) FROM message_blobs").Scan(&blobSize)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored, blobRefPrefix) || blobSize >= len(want)/10 {
+		t.Errorf("stored llm_data %.40q, blob of %d bytes", stored, blobSize)
+	}
+
+	messages, err := db.ListMessages(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || *messages[0].LlmData != string(want) || *messages[0].DisplayData != string(want) {
+		t.Error("listed message does not have its payload")
+	}
+
+	// Messages stored inline by older releases are moved
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		_, err := tx.Exec("UPDATE messages SET llm_data = ? WHERE message_id = ?", string(want)+" ", created.MessageID)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.OffloadLargeMessages(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("OffloadLargeMessages = %d, %v", n, err)
+	}
+	if n, err := db.OffloadLargeMessages(ctx, 10); err != nil || n != 0 {
+		t.Fatalf("second OffloadLargeMessages = %d, %v", n, err)
+	}
+	message, err := db.GetMessageByID(ctx, created.MessageID)
+	if err != nil || *message.LlmData != string(want)+" " {
+		t.Fatalf("message after offloading: %v", err)
+	}
+
+	// Blobs go with the last message referring to them
+	if err := db.DeleteConversation(ctx, conv.ConversationID); err != nil {
+		t.Fatal(err)
+	}
+	var blobs int
+	err = db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		return rx.QueryRow("SELECT COUNT(*) FROM message_blobs").Scan(&blobs)
+	})
+	if err != nil || blobs != 0 {
+		t.Errorf("%d blobs left after deleting the conversation: %v", blobs, err)
+	}
+}
@@ -7,20 +7,106 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// busyRetries is how many more times BEGIN or COMMIT is tried after
+	// SQLITE_BUSY, on top of the wait of PRAGMA busy_timeout.
+	busyRetries = 5
+	// busyRetryDelay is the wait before the first retry; it doubles after each.
+	busyRetryDelay = 20 * time.Millisecond
+)
+
 // Pool is an SQLite connection pool.
 //
 // We deliberately minimize our use of database/sql machinery because
 // the semantics do not match SQLite well.
 //
 // Instead, we choose a single connection to use for writing (because
-// SQLite is single-writer) and use the rest as readers.
+// SQLite is single-writer) and use the rest as readers. Write transactions
+// queue for the writer, so within the process they never contend for the
+// database lock; SQLITE_BUSY comes from other processes using the file,
+// and is retried.
 type Pool struct {
 	db      *sql.DB
 	writer  chan *sql.Conn
 	readers chan *sql.Conn
+	stats   poolStats
+}
+
+// PoolStats are counters of a pool's transactions since it was opened.
+type PoolStats struct {
+	Writes int64 `json:"writes"`
+	Reads  int64 `json:"reads"`
+	// WriteWait is the total time write transactions queued for the writer
+	WriteWait    time.Duration `json:"write_wait_ns"`
+	MaxWriteWait time.Duration `json:"max_write_wait_ns"`
+	// Busy counts SQLITE_BUSY errors, including those that were retried
+	Busy    int64 `json:"busy"`
+	Retries int64 `json:"retries"`
+	// BusyFailures counts transactions that failed with SQLITE_BUSY
+	BusyFailures int64 `json:"busy_failures"`
+}
+
+type poolStats struct {
+	writes, reads           atomic.Int64
+	writeWait, maxWriteWait atomic.Int64
+	busy, retries           atomic.Int64
+	busyFailures            atomic.Int64
+}
+
+// Stats returns the pool's counters.
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Writes:       p.stats.writes.Load(),
+		Reads:        p.stats.reads.Load(),
+		WriteWait:    time.Duration(p.stats.writeWait.Load()),
+		MaxWriteWait: time.Duration(p.stats.maxWriteWait.Load()),
+		Busy:         p.stats.busy.Load(),
+		Retries:      p.stats.retries.Load(),
+		BusyFailures: p.stats.busyFailures.Load(),
+	}
+}
+
+func (p *Pool) recordWriteWait(wait time.Duration) {
+	p.stats.writes.Add(1)
+	p.stats.writeWait.Add(int64(wait))
+	for {
+		old := p.stats.maxWriteWait.Load()
+		if int64(wait) <= old || p.stats.maxWriteWait.CompareAndSwap(old, int64(wait)) {
+			return
+		}
+	}
+}
+
+// isBusy reports whether err is SQLite's SQLITE_BUSY.
+func isBusy(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "SQLITE_BUSY")
+}
+
+// retryBusy runs fn until it does not fail with SQLITE_BUSY, at most
+// busyRetries more times, backing off between tries.
+func (p *Pool) retryBusy(ctx context.Context, fn func() error) error {
+	delay := busyRetryDelay
+	for i := 0; ; i++ {
+		err := fn()
+		if !isBusy(err) {
+			return err
+		}
+		p.stats.busy.Add(1)
+		if i == busyRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		p.stats.retries.Add(1)
+		delay *= 2
+	}
 }
 
 func NewPool(dataSourceName string, readerCount int) (*Pool, error) {
@@ -75,7 +161,10 @@ func InitPoolDB(db *sql.DB, numConns int) error {
 
 	initQueries := []string{
 		"PRAGMA journal_mode=wal;",
-		"PRAGMA busy_timeout=1000;",
+		"PRAGMA busy_timeout=5000;",
+		// Durable across crashes in WAL mode; only a power loss can drop the
+		// latest transactions.
+		"PRAGMA synchronous=NORMAL;",
 		"PRAGMA foreign_keys=ON;",
 	}
 
@@ -150,17 +239,23 @@ func (p *Pool) Exec(ctx context.Context, query string, args ...interface{}) erro
 
 func (p *Pool) Tx(ctx context.Context, fn func(ctx context.Context, tx *Tx) error) error {
 	checkNoTx(ctx, "Tx")
+	queued := time.Now()
 	var conn *sql.Conn
 	select {
 	case <-ctx.Done():
 		return fmt.Errorf("Tx: %w", ctx.Err())
 	case conn = <-p.writer:
 	}
+	p.recordWriteWait(time.Since(queued))
 
 	// If the context is closed, we want BEGIN to succeed and then
 	// we roll it back later.
-	if _, err := conn.ExecContext(context.WithoutCancel(ctx), "BEGIN IMMEDIATE;"); err != nil {
-		if strings.Contains(err.Error(), "SQLITE_BUSY") {
+	if err := p.retryBusy(ctx, func() error {
+		_, err := conn.ExecContext(context.WithoutCancel(ctx), "BEGIN IMMEDIATE;")
+		return err
+	}); err != nil {
+		if isBusy(err) {
+			p.stats.busyFailures.Add(1)
 			p.writer <- conn
 			return fmt.Errorf("Tx begin: %w", err)
 		}
@@ -176,12 +271,20 @@ func (p *Pool) Tx(ctx context.Context, fn func(ctx context.Context, tx *Tx) erro
 	var err error
 	defer func() {
 		if err == nil {
-			_, err = tx.conn.ExecContext(tx.ctx, "COMMIT;")
+			// A COMMIT that fails with SQLITE_BUSY leaves the
+			// transaction open, so it can be tried again.
+			err = p.retryBusy(tx.ctx, func() error {
+				_, err := tx.conn.ExecContext(tx.ctx, "COMMIT;")
+				return err
+			})
 			if err != nil {
 				err = fmt.Errorf("Tx: commit: %w", err)
 			}
 		}
 		if err != nil {
+			if isBusy(err) {
+				p.stats.busyFailures.Add(1)
+			}
 			err = p.rollback(tx.ctx, "Tx", err, tx.conn)
 			// always return conn,
 			// either the entire database is closed or the conn is fine.
@@ -192,6 +295,9 @@ func (p *Pool) Tx(ctx context.Context, fn func(ctx context.Context, tx *Tx) erro
 		return ctxErr // fast path for canceled context
 	}
 	err = fn(tx.ctx, tx)
+	if isBusy(err) {
+		p.stats.busy.Add(1)
+	}
 
 	return err
 }
@@ -204,11 +310,14 @@ func (p *Pool) Rx(ctx context.Context, fn func(ctx context.Context, rx *Rx) erro
 		return ctx.Err()
 	case conn = <-p.readers:
 	}
+	p.stats.reads.Add(1)
 
 	// If the context is closed, we want BEGIN to succeed and then
 	// we roll it back later.
 	if _, err := conn.ExecContext(context.WithoutCancel(ctx), "BEGIN;"); err != nil {
-		if strings.Contains(err.Error(), "SQLITE_BUSY") {
+		if isBusy(err) {
+			p.stats.busy.Add(1)
+			p.stats.busyFailures.Add(1)
 			p.readers <- conn
 			return fmt.Errorf("Rx begin: %w", err)
 		}
@@ -220,6 +329,10 @@ func (p *Pool) Rx(ctx context.Context, fn func(ctx context.Context, rx *Rx) erro
 
 	var err error
 	defer func() {
+		if isBusy(err) {
+			p.stats.busy.Add(1)
+			p.stats.busyFailures.Add(1)
+		}
 		err = p.rollback(rx.ctx, "Rx", err, rx.conn)
 		// always return conn,
 		// either the entire database is closed or the conn is fine.
@@ -243,7 +356,7 @@ func (p *Pool) rollback(ctx context.Context, txType string, txErr error, conn *s
 		// In good operation, we should never see any of these.
 		//
 		// TODO: confirm this check works on all sqlite drivers.
-		if !strings.Contains(err.Error(), "SQLITE_BUSY") {
+		if !isBusy(err) {
 			conn.Close()
 			p.db.Close()
 		}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPoolStress runs concurrent reads and writes while another connection to
+// the same file, standing in for another process, keeps taking the write lock.
+func TestPoolStress(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "stress.db")
+	db, err := New(Config{DSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Fail fast on a held lock, so that the retries are exercised
+	if err := db.pool.Exec(ctx, "PRAGMA busy_timeout=0;"); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewPool(dsn, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	stop := make(chan struct{})
+	var holder sync.WaitGroup
+	holder.Go(func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			other.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+				time.Sleep(5 * time.Millisecond)
+				return nil
+			})
+			// Leave the lock free as long as it was held, so that retries
+			// succeed even on a loaded machine
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	const workers, perWorker = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker*2)
+	for range workers {
+		wg.Go(func() {
+			for range perWorker {
+				conversation, err := db.CreateConversation(ctx, nil, true, nil, nil)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if _, err := db.GetConversationByID(ctx, conversation.ConversationID); err != nil {
+					errs <- err
+				}
+			}
+		})
+	}
+	wg.Wait()
+	close(stop)
+	holder.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	stats := db.Pool().Stats()
+	if stats.Writes < workers*perWorker || stats.Reads < workers*perWorker {
+		t.Errorf("stats = %+v, want at least %d writes and reads", stats, workers*perWorker)
+	}
+	if stats.Retries == 0 || stats.BusyFailures != 0 {
+		t.Errorf("stats = %+v, want retries and no busy failures", stats)
+	}
+	t.Logf("pool stats: %+v", stats)
+}
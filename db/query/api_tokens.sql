@@ -0,0 +1,22 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (owner, name, token_hash, scope)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListAPITokens :many
+SELECT * FROM api_tokens
+WHERE owner = ?
+ORDER BY created_at DESC, token_id DESC;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens
+WHERE token_hash = ?;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = CURRENT_TIMESTAMP
+WHERE token_id = ?;
+
+-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens
+WHERE token_id = ? AND owner = ?;
@@ -0,0 +1,15 @@
+-- name: InsertAuditEvent :one
+INSERT INTO audit_log (actor, action, conversation_id, details)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListAuditEvents :many
+-- Events before before_id, newest first. Empty filters match everything.
+SELECT * FROM audit_log
+WHERE audit_id < @before_id
+  AND (@action = '' OR action = @action)
+  AND (@actor = '' OR actor = @actor)
+  AND (@conversation_id = '' OR conversation_id = @conversation_id)
+  AND created_at >= @since
+ORDER BY audit_id DESC
+LIMIT @limit;
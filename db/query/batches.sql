@@ -0,0 +1,52 @@
+-- name: CreateBatch :one
+INSERT INTO batches (owner, mode, model, cwd, conversation_id, provider_batch_id)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetBatch :one
+SELECT * FROM batches
+WHERE batch_id = ?;
+
+-- name: ListBatches :many
+SELECT * FROM batches
+WHERE owner = ?
+ORDER BY batch_id DESC
+LIMIT ?;
+
+-- name: ListUnfinishedBatches :many
+SELECT * FROM batches
+WHERE batch_id IN (
+    SELECT batch_id FROM batch_tasks WHERE status IN ('pending', 'running')
+)
+ORDER BY batch_id ASC;
+
+-- name: UpdateBatchProviderStatus :exec
+UPDATE batches
+SET provider_status = ?
+WHERE batch_id = ?;
+
+-- name: CreateBatchTask :one
+INSERT INTO batch_tasks (batch_id, position, prompt)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: ListBatchTasks :many
+SELECT * FROM batch_tasks
+WHERE batch_id = ?
+ORDER BY position ASC;
+
+-- name: StartBatchTask :one
+UPDATE batch_tasks
+SET status = 'running', conversation_id = ?, started_at = CURRENT_TIMESTAMP
+WHERE task_id = ? AND status = 'pending'
+RETURNING *;
+
+-- name: FinishBatchTask :exec
+UPDATE batch_tasks
+SET status = ?, error = ?, finished_at = CURRENT_TIMESTAMP
+WHERE task_id = ? AND status IN ('pending', 'running');
+
+-- name: CancelBatchTasks :exec
+UPDATE batch_tasks
+SET status = 'canceled', finished_at = CURRENT_TIMESTAMP
+WHERE batch_id = ? AND status = 'pending';
@@ -0,0 +1,15 @@
+-- name: GetBridgeSession :one
+SELECT * FROM bridge_sessions
+WHERE conversation_id = ?;
+
+-- name: SetBridgeSession :exec
+INSERT INTO bridge_sessions (conversation_id, session_id, working_dir)
+VALUES (?, ?, ?)
+ON CONFLICT (conversation_id) DO UPDATE SET
+    session_id = excluded.session_id,
+    working_dir = excluded.working_dir,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: DeleteBridgeSession :exec
+DELETE FROM bridge_sessions
+WHERE conversation_id = ?;
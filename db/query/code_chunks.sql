@@ -0,0 +1,20 @@
+-- name: InsertCodeChunk :exec
+INSERT INTO code_chunks (root, path, file_hash, start_line, end_line, content, embedder, embedding)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ListCodeChunks :many
+SELECT * FROM code_chunks
+WHERE root = ? AND embedder = ?
+ORDER BY path, start_line;
+
+-- name: ListCodeIndexFiles :many
+SELECT DISTINCT path, file_hash FROM code_chunks
+WHERE root = ? AND embedder = ?;
+
+-- name: DeleteCodeChunksForFile :exec
+DELETE FROM code_chunks
+WHERE root = ? AND path = ?;
+
+-- name: DeleteCodeChunksForOtherEmbedders :exec
+DELETE FROM code_chunks
+WHERE root = ? AND embedder != ?;
@@ -0,0 +1,20 @@
+-- name: CreateComparison :one
+INSERT INTO comparisons (owner, prompt, conversation_a, conversation_b)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetComparison :one
+SELECT * FROM comparisons
+WHERE comparison_id = ?;
+
+-- name: ListComparisons :many
+SELECT * FROM comparisons
+WHERE owner = ?
+ORDER BY comparison_id DESC
+LIMIT ?;
+
+-- name: ChooseComparison :one
+UPDATE comparisons
+SET chosen_conversation_id = ?, chosen_at = CURRENT_TIMESTAMP
+WHERE comparison_id = ? AND chosen_conversation_id IS NULL
+RETURNING *;
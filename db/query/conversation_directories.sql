@@ -0,0 +1,17 @@
+-- name: AddConversationDirectory :exec
+INSERT INTO conversation_directories (conversation_id, path)
+VALUES (?, ?)
+ON CONFLICT (conversation_id, path) DO NOTHING;
+
+-- name: RemoveConversationDirectory :exec
+DELETE FROM conversation_directories
+WHERE conversation_id = ? AND path = ?;
+
+-- name: ListConversationDirectories :many
+SELECT path FROM conversation_directories
+WHERE conversation_id = ?
+ORDER BY created_at ASC, path ASC;
+
+-- name: DeleteConversationDirectories :exec
+DELETE FROM conversation_directories
+WHERE conversation_id = ?;
@@ -0,0 +1,31 @@
+-- name: SetConversationEnv :exec
+INSERT INTO conversation_env (conversation_id, name, value, secret, encrypted)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (conversation_id, name) DO UPDATE SET
+    value = excluded.value,
+    secret = excluded.secret,
+    encrypted = excluded.encrypted,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: UnsetConversationEnv :exec
+DELETE FROM conversation_env
+WHERE conversation_id = ? AND name = ?;
+
+-- name: ListConversationEnv :many
+SELECT * FROM conversation_env
+WHERE conversation_id = ?
+ORDER BY name ASC;
+
+-- name: ListSecretConversationEnv :many
+SELECT * FROM conversation_env
+WHERE secret = TRUE
+ORDER BY conversation_id, name;
+
+-- name: UpdateConversationEnvValue :exec
+UPDATE conversation_env
+SET value = ?, encrypted = ?
+WHERE conversation_id = ? AND name = ?;
+
+-- name: DeleteConversationEnv :exec
+DELETE FROM conversation_env
+WHERE conversation_id = ?;
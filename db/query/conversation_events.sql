@@ -0,0 +1,11 @@
+-- name: InsertConversationEvent :one
+INSERT INTO conversation_events (conversation_id, type, ref_id, name, duration_ms, error, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListConversationEvents :many
+-- Events after after_id, oldest first.
+SELECT * FROM conversation_events
+WHERE conversation_id = @conversation_id AND event_id > @after_id
+ORDER BY event_id ASC
+LIMIT @limit;
@@ -0,0 +1,17 @@
+-- name: AddConversationHook :one
+INSERT INTO conversation_hooks (conversation_id, event, command, inject_output)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: RemoveConversationHook :exec
+DELETE FROM conversation_hooks
+WHERE conversation_id = ? AND hook_id = ?;
+
+-- name: ListConversationHooks :many
+SELECT * FROM conversation_hooks
+WHERE conversation_id = ?
+ORDER BY hook_id ASC;
+
+-- name: DeleteConversationHooks :exec
+DELETE FROM conversation_hooks
+WHERE conversation_id = ?;
@@ -0,0 +1,14 @@
+-- name: AcquireConversationLease :one
+-- Takes or renews holder's lease, unless another holder's has not expired,
+-- in which case no row is returned.
+INSERT INTO conversation_leases (conversation_id, holder, expires_at)
+VALUES (@conversation_id, @holder, @expires_at)
+ON CONFLICT (conversation_id) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+WHERE conversation_leases.holder = excluded.holder OR conversation_leases.expires_at < @now
+RETURNING *;
+
+-- name: GetConversationLease :one
+SELECT * FROM conversation_leases WHERE conversation_id = ?;
+
+-- name: ReleaseConversationLease :exec
+DELETE FROM conversation_leases WHERE conversation_id = ? AND holder = ?;
@@ -0,0 +1,30 @@
+-- name: GetConversationPlan :one
+SELECT * FROM conversation_plans
+WHERE conversation_id = ?;
+
+-- name: SetConversationPlanMode :one
+INSERT INTO conversation_plans (conversation_id, mode)
+VALUES (?, ?)
+ON CONFLICT (conversation_id) DO UPDATE SET
+    mode = excluded.mode,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: SaveConversationPlan :one
+INSERT INTO conversation_plans (conversation_id, plan)
+VALUES (?, ?)
+ON CONFLICT (conversation_id) DO UPDATE SET
+    plan = excluded.plan,
+    approved_at = NULL,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: ApproveConversationPlan :one
+UPDATE conversation_plans
+SET mode = 'execute', approved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE conversation_id = ? AND plan != ''
+RETURNING *;
+
+-- name: DeleteConversationPlan :exec
+DELETE FROM conversation_plans
+WHERE conversation_id = ?;
@@ -0,0 +1,14 @@
+-- name: MarkConversationRead :exec
+INSERT INTO conversation_reads (owner, conversation_id, sequence_id, updated_at)
+SELECT @owner, @conversation_id, COALESCE(MAX(sequence_id), 0), CURRENT_TIMESTAMP
+FROM messages
+WHERE conversation_id = @conversation_id
+ON CONFLICT (owner, conversation_id) DO UPDATE SET
+    sequence_id = MAX(conversation_reads.sequence_id, excluded.sequence_id),
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: ListUnreadMessages :many
+SELECT m.* FROM messages m
+JOIN conversation_reads r ON r.conversation_id = m.conversation_id
+WHERE r.owner = ? AND m.sequence_id > r.sequence_id AND m.type IN ('user', 'agent')
+ORDER BY m.conversation_id, m.sequence_id;
@@ -0,0 +1,26 @@
+-- name: GetConversationSettings :one
+SELECT * FROM conversation_settings
+WHERE conversation_id = ?;
+
+-- name: UpsertConversationSettings :one
+INSERT INTO conversation_settings (conversation_id, temperature, top_p, max_tokens, sandbox, turn_timeout_seconds, tool_timeout_seconds, read_only, approve_risky_commands, windows_paths, timezone, locale, response_schema, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (conversation_id) DO UPDATE SET
+    temperature = excluded.temperature,
+    top_p = excluded.top_p,
+    max_tokens = excluded.max_tokens,
+    sandbox = excluded.sandbox,
+    turn_timeout_seconds = excluded.turn_timeout_seconds,
+    tool_timeout_seconds = excluded.tool_timeout_seconds,
+    read_only = excluded.read_only,
+    approve_risky_commands = excluded.approve_risky_commands,
+    windows_paths = excluded.windows_paths,
+    timezone = excluded.timezone,
+    locale = excluded.locale,
+    response_schema = excluded.response_schema,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteConversationSettings :exec
+DELETE FROM conversation_settings
+WHERE conversation_id = ?;
@@ -0,0 +1,16 @@
+-- name: GetConversationSummary :one
+SELECT * FROM conversation_summaries
+WHERE conversation_id = ?;
+
+-- name: ListConversationSummaries :many
+SELECT * FROM conversation_summaries;
+
+-- name: UpsertConversationSummary :one
+INSERT INTO conversation_summaries (conversation_id, summary, files, sequence_id, updated_at)
+VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (conversation_id) DO UPDATE SET
+    summary = excluded.summary,
+    files = excluded.files,
+    sequence_id = excluded.sequence_id,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
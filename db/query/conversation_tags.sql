@@ -0,0 +1,12 @@
+-- name: AddConversationTag :exec
+INSERT INTO conversation_tags (conversation_id, tag)
+VALUES (?, ?)
+ON CONFLICT (conversation_id, tag) DO NOTHING;
+
+-- name: DeleteConversationTag :exec
+DELETE FROM conversation_tags
+WHERE conversation_id = ? AND tag = ?;
+
+-- name: ListConversationTags :many
+SELECT * FROM conversation_tags
+ORDER BY conversation_id, tag;
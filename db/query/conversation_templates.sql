@@ -0,0 +1,27 @@
+-- name: CreateConversationTemplate :one
+INSERT INTO conversation_templates (owner, name, description, prompt, config)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetConversationTemplate :one
+SELECT * FROM conversation_templates
+WHERE template_id = ? AND owner = ?;
+
+-- name: GetConversationTemplateByName :one
+SELECT * FROM conversation_templates
+WHERE name = ? AND owner = ?;
+
+-- name: ListConversationTemplates :many
+SELECT * FROM conversation_templates
+WHERE owner = ?
+ORDER BY name ASC;
+
+-- name: UpdateConversationTemplate :one
+UPDATE conversation_templates
+SET name = ?, description = ?, prompt = ?, config = ?, updated_at = CURRENT_TIMESTAMP
+WHERE template_id = ? AND owner = ?
+RETURNING *;
+
+-- name: DeleteConversationTemplate :exec
+DELETE FROM conversation_templates
+WHERE template_id = ? AND owner = ?;
@@ -0,0 +1,15 @@
+-- name: GetConversationTodos :one
+SELECT * FROM conversation_todos
+WHERE conversation_id = ?;
+
+-- name: UpsertConversationTodos :one
+INSERT INTO conversation_todos (conversation_id, todos, updated_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (conversation_id) DO UPDATE SET
+    todos = excluded.todos,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteConversationTodos :exec
+DELETE FROM conversation_todos
+WHERE conversation_id = ?;
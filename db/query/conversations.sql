@@ -17,6 +17,16 @@ WHERE archived = FALSE AND parent_conversation_id IS NULL
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?;
 
+-- name: ListQuickSearchConversations :many
+SELECT c.*, (
+    SELECT COUNT(*) FROM messages m
+    WHERE m.conversation_id = c.conversation_id AND m.type = 'user'
+) AS user_message_count
+FROM conversations c
+WHERE c.archived = FALSE AND c.parent_conversation_id IS NULL
+ORDER BY c.updated_at DESC
+LIMIT ?;
+
 -- name: ListArchivedConversations :many
 SELECT * FROM conversations
 WHERE archived = TRUE
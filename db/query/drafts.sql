@@ -0,0 +1,15 @@
+-- name: UpsertDraft :one
+INSERT INTO drafts (conversation_id, text, updated_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (conversation_id) DO UPDATE SET
+    text = excluded.text,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: GetDraft :one
+SELECT * FROM drafts
+WHERE conversation_id = ?;
+
+-- name: DeleteDraft :exec
+DELETE FROM drafts
+WHERE conversation_id = ?;
@@ -0,0 +1,27 @@
+-- name: CreateHTTPTool :one
+INSERT INTO http_tools (name, description, input_schema, url, headers_encrypted)
+VALUES (?, ?, ?, ?, TRUE)
+RETURNING *;
+
+-- name: GetHTTPTool :one
+SELECT * FROM http_tools
+WHERE tool_id = ?;
+
+-- name: ListHTTPTools :many
+SELECT * FROM http_tools
+ORDER BY name ASC;
+
+-- name: UpdateHTTPTool :one
+UPDATE http_tools
+SET name = ?, description = ?, input_schema = ?, url = ?, updated_at = CURRENT_TIMESTAMP
+WHERE tool_id = ?
+RETURNING *;
+
+-- name: SetHTTPToolHeaders :exec
+UPDATE http_tools
+SET headers = ?, headers_encrypted = TRUE
+WHERE tool_id = ?;
+
+-- name: DeleteHTTPTool :exec
+DELETE FROM http_tools
+WHERE tool_id = ?;
@@ -0,0 +1,14 @@
+-- name: SetInterruptedTurn :exec
+INSERT INTO interrupted_turns (conversation_id, reason)
+VALUES (?, ?)
+ON CONFLICT (conversation_id) DO UPDATE SET
+    reason = excluded.reason,
+    created_at = CURRENT_TIMESTAMP;
+
+-- name: ListInterruptedTurns :many
+SELECT * FROM interrupted_turns
+ORDER BY created_at ASC;
+
+-- name: DeleteInterruptedTurn :exec
+DELETE FROM interrupted_turns
+WHERE conversation_id = ?;
@@ -0,0 +1,13 @@
+-- name: CreateMemory :one
+INSERT INTO memories (directory, content)
+VALUES (?, ?)
+RETURNING *;
+
+-- name: ListMemories :many
+SELECT * FROM memories
+ORDER BY memory_id ASC;
+
+-- name: DeleteMemory :one
+DELETE FROM memories
+WHERE memory_id = ?
+RETURNING *;
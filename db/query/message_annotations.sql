@@ -0,0 +1,30 @@
+-- name: ListMessageAnnotations :many
+SELECT * FROM message_annotations
+WHERE conversation_id = ?
+ORDER BY created_at ASC;
+
+-- name: UpsertMessageAnnotation :one
+INSERT INTO message_annotations (message_id, conversation_id, rating, flagged, note, updated_at)
+VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (message_id) DO UPDATE SET
+    rating = excluded.rating,
+    flagged = excluded.flagged,
+    note = excluded.note,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteMessageAnnotation :exec
+DELETE FROM message_annotations
+WHERE message_id = ?;
+
+-- name: DeleteConversationAnnotations :exec
+DELETE FROM message_annotations
+WHERE conversation_id = ?;
+
+-- name: ListAnnotatedMessages :many
+SELECT a.message_id, a.conversation_id, a.rating, a.flagged, a.note, a.updated_at,
+       m.type, m.llm_data, c.model
+FROM message_annotations a
+JOIN messages m ON m.message_id = a.message_id
+JOIN conversations c ON c.conversation_id = a.conversation_id
+ORDER BY a.updated_at DESC;
@@ -0,0 +1,32 @@
+-- name: CreateMessageBlob :exec
+INSERT INTO message_blobs (hash, data, size)
+VALUES (?, ?, ?)
+ON CONFLICT (hash) DO NOTHING;
+
+-- name: GetMessageBlob :one
+SELECT * FROM message_blobs
+WHERE hash = ?;
+
+-- name: DeleteUnreferencedMessageBlobs :exec
+-- References are {"$blob":"<hash>"}: the hash starts at the 11th character.
+DELETE FROM message_blobs
+WHERE hash NOT IN (
+    SELECT substr(llm_data, 11, 64) FROM messages WHERE llm_data LIKE '{"$blob":%'
+    UNION ALL
+    SELECT substr(display_data, 11, 64) FROM messages WHERE display_data LIKE '{"$blob":%'
+    UNION ALL
+    SELECT substr(llm_data, 11, 64) FROM truncated_messages WHERE llm_data LIKE '{"$blob":%'
+    UNION ALL
+    SELECT substr(display_data, 11, 64) FROM truncated_messages WHERE display_data LIKE '{"$blob":%'
+);
+
+-- name: ListLargeMessages :many
+-- Messages stored before large payloads were moved to message_blobs.
+SELECT message_id, llm_data, display_data FROM messages
+WHERE length(llm_data) > @size OR length(display_data) > @size
+LIMIT @limit;
+
+-- name: UpdateMessagePayloads :exec
+UPDATE messages
+SET llm_data = ?, display_data = ?
+WHERE message_id = ?;
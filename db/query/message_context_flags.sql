@@ -0,0 +1,15 @@
+-- name: DeleteMessageContextFlag :exec
+DELETE FROM message_context_flags
+WHERE message_id = ?;
+
+-- name: ListMessageContextFlags :many
+SELECT * FROM message_context_flags
+WHERE conversation_id = ?
+ORDER BY created_at ASC;
+
+-- name: UpsertMessageContextFlag :one
+INSERT INTO message_context_flags (message_id, conversation_id, flag)
+VALUES (?, ?, ?)
+ON CONFLICT (message_id) DO UPDATE SET
+    flag = excluded.flag
+RETURNING *;
@@ -4,9 +4,13 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: GetNextSequenceID :one
-SELECT COALESCE(MAX(sequence_id), 0) + 1 
-FROM messages 
-WHERE conversation_id = ?;
+-- Truncated messages are included so that sequence IDs are never reused.
+SELECT COALESCE(MAX(sequence_id), 0) + 1
+FROM (
+    SELECT sequence_id FROM messages WHERE conversation_id = ?1
+    UNION ALL
+    SELECT sequence_id FROM truncated_messages WHERE conversation_id = ?1
+);
 
 -- name: GetMessage :one
 SELECT * FROM messages
@@ -17,6 +21,14 @@ SELECT * FROM messages
 WHERE conversation_id = ?
 ORDER BY sequence_id ASC;
 
+-- name: ListMessagesForAnalytics :many
+-- User and agent messages created since a time, with their conversation's model.
+SELECT m.conversation_id, m.type, m.llm_data, m.usage_data, m.created_at, c.model
+FROM messages m
+JOIN conversations c ON c.conversation_id = m.conversation_id
+WHERE m.created_at >= ? AND m.type IN ('user', 'agent')
+ORDER BY m.conversation_id, m.sequence_id;
+
 -- name: ListMessagesForContext :many
 SELECT * FROM messages
 WHERE conversation_id = ? AND excluded_from_context = FALSE
@@ -43,6 +55,14 @@ LIMIT 1;
 DELETE FROM messages
 WHERE message_id = ?;
 
+-- name: RestoreMessage :exec
+INSERT INTO messages (message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: DeleteMessagesFrom :exec
+DELETE FROM messages
+WHERE conversation_id = ? AND sequence_id >= ?;
+
 -- name: DeleteConversationMessages :exec
 DELETE FROM messages
 WHERE conversation_id = ?;
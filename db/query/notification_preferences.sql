@@ -0,0 +1,14 @@
+-- name: GetNotificationPreferences :one
+SELECT * FROM notification_preferences
+WHERE owner = ? AND conversation_id = ?;
+
+-- name: UpsertNotificationPreferences :exec
+INSERT INTO notification_preferences (owner, conversation_id, preferences, updated_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (owner, conversation_id) DO UPDATE SET
+    preferences = excluded.preferences,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: DeleteNotificationPreferences :exec
+DELETE FROM notification_preferences
+WHERE owner = ? AND conversation_id = ?;
@@ -0,0 +1,20 @@
+-- name: ListProjects :many
+SELECT * FROM projects ORDER BY directory ASC;
+
+-- name: GetProject :one
+SELECT * FROM projects WHERE directory = ?;
+
+-- name: UpsertProject :one
+INSERT INTO projects (directory, default_model, env, setup_commands, instructions, system_prompt_template)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (directory) DO UPDATE SET
+    default_model = excluded.default_model,
+    env = excluded.env,
+    setup_commands = excluded.setup_commands,
+    instructions = excluded.instructions,
+    system_prompt_template = excluded.system_prompt_template,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteProject :exec
+DELETE FROM projects WHERE directory = ?;
@@ -0,0 +1,16 @@
+-- name: UpsertPushSubscription :exec
+INSERT INTO push_subscriptions (endpoint, owner, p256dh, auth)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (endpoint) DO UPDATE SET
+    owner = excluded.owner,
+    p256dh = excluded.p256dh,
+    auth = excluded.auth;
+
+-- name: ListPushSubscriptions :many
+SELECT * FROM push_subscriptions
+WHERE owner = ?
+ORDER BY created_at ASC;
+
+-- name: DeletePushSubscription :exec
+DELETE FROM push_subscriptions
+WHERE endpoint = ? AND owner = ?;
@@ -0,0 +1,13 @@
+-- name: CreateReplay :one
+INSERT INTO replays (owner, conversation_id, replay_conversation_id, batch_id, read_only, sandbox)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetReplay :one
+SELECT * FROM replays
+WHERE replay_id = ?;
+
+-- name: ListConversationReplays :many
+SELECT * FROM replays
+WHERE conversation_id = ?
+ORDER BY replay_id DESC;
@@ -0,0 +1,21 @@
+-- name: GetCachedResponse :one
+SELECT * FROM response_cache
+WHERE cache_key = ?;
+
+-- name: SaveCachedResponse :exec
+INSERT INTO response_cache (cache_key, model_id, response)
+VALUES (?, ?, ?)
+ON CONFLICT (cache_key) DO UPDATE SET
+    response = excluded.response;
+
+-- name: RecordCacheHit :exec
+UPDATE response_cache
+SET hits = hits + 1, last_hit_at = CURRENT_TIMESTAMP
+WHERE cache_key = ?;
+
+-- name: GetResponseCacheStats :one
+SELECT COUNT(*) AS entries, CAST(COALESCE(SUM(hits), 0) AS INTEGER) AS hits
+FROM response_cache;
+
+-- name: ClearResponseCache :exec
+DELETE FROM response_cache;
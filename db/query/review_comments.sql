@@ -0,0 +1,23 @@
+-- name: CreateReviewComment :one
+INSERT INTO review_comments (conversation_id, parent_id, message_id, diff_id, file_path, start_line, end_line, author, body)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetReviewComment :one
+SELECT * FROM review_comments
+WHERE comment_id = ?;
+
+-- name: ListReviewComments :many
+SELECT * FROM review_comments
+WHERE conversation_id = ?
+ORDER BY comment_id ASC;
+
+-- name: UpdateReviewComment :one
+UPDATE review_comments
+SET body = ?, resolved = ?, updated_at = CURRENT_TIMESTAMP
+WHERE comment_id = ?
+RETURNING *;
+
+-- name: DeleteReviewComment :exec
+DELETE FROM review_comments
+WHERE comment_id = ?;
@@ -0,0 +1,18 @@
+-- name: GetSecret :one
+SELECT value FROM secrets
+WHERE name = ?;
+
+-- name: ListSecrets :many
+SELECT * FROM secrets
+ORDER BY name;
+
+-- name: SetSecret :exec
+INSERT INTO secrets (name, value, updated_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (name) DO UPDATE SET
+    value = excluded.value,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: DeleteSecret :exec
+DELETE FROM secrets
+WHERE name = ?;
@@ -0,0 +1,23 @@
+-- name: CreateSnippet :one
+INSERT INTO snippets (owner, name, description, body)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetSnippet :one
+SELECT * FROM snippets
+WHERE snippet_id = ? AND owner = ?;
+
+-- name: ListSnippets :many
+SELECT * FROM snippets
+WHERE owner = ?
+ORDER BY name ASC;
+
+-- name: UpdateSnippet :one
+UPDATE snippets
+SET name = ?, description = ?, body = ?, updated_at = CURRENT_TIMESTAMP
+WHERE snippet_id = ? AND owner = ?
+RETURNING *;
+
+-- name: DeleteSnippet :exec
+DELETE FROM snippets
+WHERE snippet_id = ? AND owner = ?;
@@ -0,0 +1,13 @@
+-- name: UpsertToolOutput :exec
+INSERT INTO tool_outputs (conversation_id, tool_use_id, output)
+VALUES (?, ?, ?)
+ON CONFLICT (conversation_id, tool_use_id) DO UPDATE SET
+    output = excluded.output;
+
+-- name: GetToolOutput :one
+SELECT * FROM tool_outputs
+WHERE conversation_id = ? AND tool_use_id = ?;
+
+-- name: DeleteConversationToolOutputs :exec
+DELETE FROM tool_outputs
+WHERE conversation_id = ?;
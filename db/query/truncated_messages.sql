@@ -0,0 +1,21 @@
+-- name: CreateTruncatedMessage :exec
+INSERT INTO truncated_messages (message_id, conversation_id, truncation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetNextTruncationID :one
+SELECT COALESCE(MAX(truncation_id), 0) + 1
+FROM truncated_messages
+WHERE conversation_id = ?;
+
+-- name: ListTruncatedMessages :many
+SELECT * FROM truncated_messages
+WHERE conversation_id = ? AND truncation_id = ?
+ORDER BY sequence_id ASC;
+
+-- name: DeleteTruncatedMessages :exec
+DELETE FROM truncated_messages
+WHERE conversation_id = ? AND truncation_id = ?;
+
+-- name: DeleteConversationTruncatedMessages :exec
+DELETE FROM truncated_messages
+WHERE conversation_id = ?;
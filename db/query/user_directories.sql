@@ -0,0 +1,29 @@
+-- name: TouchUserDirectory :exec
+INSERT INTO user_directories (owner, path, last_used_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (owner, path) DO UPDATE SET last_used_at = CURRENT_TIMESTAMP;
+
+-- name: PruneUserDirectories :exec
+DELETE FROM user_directories
+WHERE owner = ? AND NOT favorite AND path NOT IN (
+    SELECT recent.path FROM user_directories AS recent
+    WHERE recent.owner = ? AND recent.last_used_at IS NOT NULL
+    ORDER BY recent.last_used_at DESC
+    LIMIT ?
+);
+
+-- name: SetUserDirectoryFavorite :exec
+INSERT INTO user_directories (owner, path, favorite)
+VALUES (?, ?, ?)
+ON CONFLICT (owner, path) DO UPDATE SET favorite = excluded.favorite;
+
+-- name: ListRecentUserDirectories :many
+SELECT * FROM user_directories
+WHERE owner = ? AND last_used_at IS NOT NULL
+ORDER BY last_used_at DESC, path ASC
+LIMIT ?;
+
+-- name: ListFavoriteUserDirectories :many
+SELECT * FROM user_directories
+WHERE owner = ? AND favorite
+ORDER BY path ASC;
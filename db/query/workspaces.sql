@@ -0,0 +1,8 @@
+-- name: CreateWorkspace :one
+INSERT INTO workspaces (path, origin_url, branch, owner)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListWorkspaces :many
+SELECT * FROM workspaces
+ORDER BY path ASC;
@@ -0,0 +1,23 @@
+-- Truncated messages table.
+-- When a turn is retried or an earlier user message is edited, the messages
+-- from that point on are moved here instead of being deleted, so the
+-- truncation can be undone. Each truncation gets its own truncation_id,
+-- increasing per conversation.
+
+CREATE TABLE truncated_messages (
+    message_id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    truncation_id INTEGER NOT NULL,
+    sequence_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    llm_data TEXT,
+    user_data TEXT,
+    usage_data TEXT,
+    created_at DATETIME NOT NULL,
+    display_data TEXT,
+    excluded_from_context BOOLEAN NOT NULL DEFAULT FALSE,
+    truncated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_truncated_messages_conversation ON truncated_messages(conversation_id, truncation_id);
@@ -0,0 +1,10 @@
+-- Drafts table
+-- Holds the unsent text in a conversation's input box so it survives
+-- browser refreshes and device switches. At most one draft per conversation.
+
+CREATE TABLE drafts (
+    conversation_id TEXT PRIMARY KEY,
+    text TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
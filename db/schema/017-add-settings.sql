@@ -0,0 +1,8 @@
+-- Settings table
+-- Server-wide key/value settings, such as the key used to sign share links.
+
+CREATE TABLE settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
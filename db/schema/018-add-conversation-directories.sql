@@ -0,0 +1,10 @@
+-- Additional working directories for a conversation, beyond the primary cwd.
+-- They are listed in the system prompt so the agent can work across them.
+
+CREATE TABLE conversation_directories (
+    conversation_id TEXT NOT NULL,
+    path TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, path),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+-- Projects table
+-- Per-directory defaults applied to conversations whose cwd is the directory
+-- or one of its subdirectories. The most specific directory wins.
+
+CREATE TABLE projects (
+    directory TEXT PRIMARY KEY,
+    default_model TEXT,                        -- model for new conversations when none is requested
+    env TEXT NOT NULL DEFAULT '{}',            -- JSON object of environment variables for tool executions
+    setup_commands TEXT NOT NULL DEFAULT '[]', -- JSON array of shell commands run when a conversation is created
+    instructions TEXT NOT NULL DEFAULT '',     -- custom instructions added to the system prompt
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,15 @@
+-- Commands run at points in a conversation's lifecycle.
+-- event is one of conversation_start, before_tool or after_turn.
+-- When inject_output is set, the command output is added to the LLM context.
+
+CREATE TABLE conversation_hooks (
+    hook_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id TEXT NOT NULL,
+    event TEXT NOT NULL CHECK (event IN ('conversation_start', 'before_tool', 'after_turn')),
+    command TEXT NOT NULL,
+    inject_output BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_conversation_hooks_conversation_id ON conversation_hooks(conversation_id);
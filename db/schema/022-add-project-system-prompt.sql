@@ -0,0 +1,4 @@
+-- Custom system prompt template for a project.
+-- Empty means the global template (or the built-in one) is used.
+
+ALTER TABLE projects ADD COLUMN system_prompt_template TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+-- Reusable prompt snippets.
+-- owner is the value of the server's identity header (empty when not configured),
+-- so each user has their own library. body may contain {{variable}} placeholders.
+
+CREATE TABLE snippets (
+    snippet_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner, name)
+);
@@ -0,0 +1,12 @@
+-- Facts the agent has been asked to remember across conversations.
+-- directory is the project the memory applies to, or empty for memories that
+-- apply everywhere.
+
+CREATE TABLE memories (
+    memory_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    directory TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_memories_directory ON memories(directory);
@@ -0,0 +1,17 @@
+-- Semantic code index. Each row is a chunk of a file in the repository at root,
+-- with its embedding (little-endian float32 values) from the named embedder.
+-- file_hash detects files that changed since they were indexed.
+
+CREATE TABLE code_chunks (
+    chunk_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    root TEXT NOT NULL,
+    path TEXT NOT NULL,
+    file_hash TEXT NOT NULL,
+    start_line INTEGER NOT NULL,
+    end_line INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    embedder TEXT NOT NULL,
+    embedding BLOB NOT NULL
+);
+
+CREATE INDEX idx_code_chunks_root_path ON code_chunks(root, path);
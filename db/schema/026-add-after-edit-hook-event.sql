@@ -0,0 +1,21 @@
+-- Add 'after_edit' to the conversation hook event check constraint
+-- SQLite doesn't support ALTER TABLE to modify CHECK constraints
+
+CREATE TABLE conversation_hooks_new (
+    hook_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id TEXT NOT NULL,
+    event TEXT NOT NULL CHECK (event IN ('conversation_start', 'before_tool', 'after_edit', 'after_turn')),
+    command TEXT NOT NULL,
+    inject_output BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+INSERT INTO conversation_hooks_new (hook_id, conversation_id, event, command, inject_output, created_at)
+SELECT hook_id, conversation_id, event, command, inject_output, created_at FROM conversation_hooks;
+
+DROP TABLE conversation_hooks;
+
+ALTER TABLE conversation_hooks_new RENAME TO conversation_hooks;
+
+CREATE INDEX idx_conversation_hooks_conversation_id ON conversation_hooks(conversation_id);
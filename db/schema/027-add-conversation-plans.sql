@@ -0,0 +1,13 @@
+-- Plan mode state for conversations.
+-- In plan mode, write-capable tools are disabled and the agent submits a plan;
+-- approving the plan switches the conversation to execute mode.
+
+CREATE TABLE conversation_plans (
+    conversation_id TEXT PRIMARY KEY,
+    mode TEXT NOT NULL DEFAULT 'plan' CHECK (mode IN ('plan', 'execute')),
+    plan TEXT NOT NULL DEFAULT '',
+    approved_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+-- The task list the agent keeps with the todo tool during long work.
+-- The tool replaces the whole list on every update.
+
+CREATE TABLE conversation_todos (
+    conversation_id TEXT PRIMARY KEY,
+    todos TEXT NOT NULL DEFAULT '[]', -- JSON array of {content, status} items
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
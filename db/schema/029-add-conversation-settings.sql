@@ -0,0 +1,11 @@
+-- Sampling settings for conversations.
+-- NULL columns use the model's defaults.
+
+CREATE TABLE conversation_settings (
+    conversation_id TEXT PRIMARY KEY,
+    temperature REAL,
+    top_p REAL,
+    max_tokens INTEGER,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+-- Provider credentials configured at runtime
+-- Values are API keys, or the bridge URL for claude-code, encrypted by the server.
+-- They take precedence over the flags and environment the server was started with.
+
+CREATE TABLE provider_credentials (
+    provider TEXT PRIMARY KEY,
+    encrypted_value TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,22 @@
+-- Encrypted secrets store
+-- Values are sealed with the server's secrets key; see the secrets package.
+-- Provider credentials move here as provider/<provider>.
+
+CREATE TABLE secrets (
+    name TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO secrets (name, value, created_at, updated_at)
+SELECT 'provider/' || provider, encrypted_value, created_at, updated_at
+FROM provider_credentials;
+
+DROP TABLE provider_credentials;
+
+-- The key provider credentials were sealed with becomes the default secrets key
+UPDATE settings SET key = 'secrets_key' WHERE key = 'credential_key';
+
+-- Secret environment values are encrypted by the server when it starts
+ALTER TABLE conversation_env ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,16 @@
+-- User annotations on messages: a thumbs up/down rating, a wrong-answer flag and a note.
+-- They mark places in long conversations and form a feedback dataset for prompt tuning.
+
+CREATE TABLE message_annotations (
+    message_id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    rating TEXT CHECK (rating IN ('up', 'down')),
+    flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    note TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_message_annotations_conversation_id ON message_annotations(conversation_id);
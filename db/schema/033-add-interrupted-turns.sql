@@ -0,0 +1,9 @@
+-- Turns that were interrupted before they ended, such as by a server shutdown.
+-- The server resumes them when it starts again.
+
+CREATE TABLE interrupted_turns (
+    conversation_id TEXT PRIMARY KEY,
+    reason TEXT NOT NULL, -- what interrupted the turn, e.g. 'shutdown'
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
@@ -0,0 +1,16 @@
+-- API tokens for programmatic access.
+-- owner is the value of the server's identity header (empty when not configured).
+-- Only the SHA-256 hash of a token is stored; the token itself is shown once.
+-- scope is 'read' (GET requests only) or 'execute' (everything except token management).
+
+CREATE TABLE api_tokens (
+    token_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scope TEXT NOT NULL CHECK (scope IN ('read', 'execute')),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME
+);
+
+CREATE INDEX idx_api_tokens_owner ON api_tokens(owner);
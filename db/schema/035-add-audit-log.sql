@@ -0,0 +1,25 @@
+-- Append-only audit trail of tool executions, approvals, configuration
+-- changes and logins. actor is the identity header value ('' when not
+-- configured, 'agent' for tool executions); details is a JSON object.
+
+CREATE TABLE audit_log (
+    audit_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    conversation_id TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX idx_audit_log_action ON audit_log(action, audit_id);
+CREATE INDEX idx_audit_log_conversation ON audit_log(conversation_id, audit_id);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
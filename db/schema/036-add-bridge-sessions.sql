@@ -0,0 +1,11 @@
+-- The Claude Code bridge session of each conversation, so that the session
+-- survives restarts of Shelley and the bridge. A session belongs to the
+-- directory it started in.
+
+CREATE TABLE bridge_sessions (
+    conversation_id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    working_dir TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
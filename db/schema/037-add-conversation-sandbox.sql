@@ -0,0 +1,4 @@
+-- Sandbox mode of backends that run tools themselves, such as Codex.
+-- NULL uses the backend's default.
+
+ALTER TABLE conversation_settings ADD COLUMN sandbox TEXT;
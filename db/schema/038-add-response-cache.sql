@@ -0,0 +1,11 @@
+-- Cache of LLM responses to deterministic (temperature 0) requests,
+-- keyed on a hash of the model and the request.
+
+CREATE TABLE response_cache (
+    cache_key TEXT PRIMARY KEY,
+    model_id TEXT NOT NULL,
+    response TEXT NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_hit_at DATETIME
+);
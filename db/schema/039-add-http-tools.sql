@@ -0,0 +1,14 @@
+-- User-defined tools that call an HTTP endpoint.
+-- input_schema is the JSON schema of the tool's input, sent to the model;
+-- headers is a JSON object of headers added to each call.
+
+CREATE TABLE http_tools (
+    tool_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL,
+    input_schema TEXT NOT NULL,
+    url TEXT NOT NULL,
+    headers TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
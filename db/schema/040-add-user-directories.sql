@@ -0,0 +1,15 @@
+-- Working directories for the directory picker: those each user recently
+-- started conversations in, and those they pinned as favorites. owner is the
+-- value of the server's identity header (empty when not configured), as for
+-- snippets. last_used_at is NULL for favorites never used.
+
+CREATE TABLE user_directories (
+    owner TEXT NOT NULL DEFAULT '',
+    path TEXT NOT NULL,
+    favorite BOOLEAN NOT NULL DEFAULT FALSE,
+    last_used_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (owner, path)
+);
+
+CREATE INDEX idx_user_directories_last_used ON user_directories(owner, last_used_at DESC);
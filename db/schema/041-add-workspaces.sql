@@ -0,0 +1,11 @@
+-- Repositories Shelley cloned into its workspaces directory for new
+-- conversations, with the URL they were cloned from so that the agent
+-- knows where to push branches.
+
+CREATE TABLE workspaces (
+    path TEXT PRIMARY KEY,
+    origin_url TEXT NOT NULL,
+    branch TEXT NOT NULL DEFAULT '',
+    owner TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,31 @@
+-- Batches of prompts queued to run unattended: in order in one conversation
+-- (sequential), or each in a new conversation (parallel).
+-- conversation_id is the conversation of a sequential batch.
+
+CREATE TABLE batches (
+    batch_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner TEXT NOT NULL DEFAULT '',
+    mode TEXT NOT NULL CHECK (mode IN ('sequential', 'parallel')),
+    model TEXT NOT NULL,
+    cwd TEXT NOT NULL DEFAULT '',
+    conversation_id TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_batches_owner ON batches(owner, batch_id);
+
+-- The prompts of a batch, run in position order. conversation_id is the
+-- conversation the task ran in, once it started.
+CREATE TABLE batch_tasks (
+    task_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    batch_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    prompt TEXT NOT NULL,
+    conversation_id TEXT,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed', 'canceled')),
+    error TEXT NOT NULL DEFAULT '',
+    started_at DATETIME,
+    finished_at DATETIME,
+    UNIQUE (batch_id, position),
+    FOREIGN KEY (batch_id) REFERENCES batches(batch_id) ON DELETE CASCADE
+);
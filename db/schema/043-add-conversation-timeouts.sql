@@ -0,0 +1,5 @@
+-- Timeouts of conversation turns and of their tool executions, in seconds.
+-- NULL uses the server's defaults.
+
+ALTER TABLE conversation_settings ADD COLUMN turn_timeout_seconds INTEGER;
+ALTER TABLE conversation_settings ADD COLUMN tool_timeout_seconds INTEGER;
@@ -0,0 +1,12 @@
+-- Full output of tool results that were truncated for the model.
+-- The model sees the truncated text; the full text can be fetched by tool_use_id.
+
+CREATE TABLE tool_outputs (
+    tool_use_id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    output TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_tool_outputs_conversation ON tool_outputs(conversation_id);
@@ -0,0 +1,14 @@
+-- Web Push subscriptions of the browsers that asked to be notified when
+-- turns end. owner is the value of the server's identity header (empty when
+-- not configured), as for snippets. p256dh and auth are the browser's keys
+-- for encrypting messages, base64url encoded.
+
+CREATE TABLE push_subscriptions (
+    endpoint TEXT PRIMARY KEY,
+    owner TEXT NOT NULL DEFAULT '',
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_push_subscriptions_owner ON push_subscriptions(owner);
@@ -0,0 +1,11 @@
+-- Which channels notify a user of which events. conversation_id is '' for the
+-- user's defaults; preferences is a JSON object mapping events to lists of
+-- channels, and events it omits fall back to the user's defaults.
+
+CREATE TABLE notification_preferences (
+    owner TEXT NOT NULL DEFAULT '',
+    conversation_id TEXT NOT NULL DEFAULT '',
+    preferences TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (owner, conversation_id)
+);
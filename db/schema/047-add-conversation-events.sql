@@ -0,0 +1,17 @@
+-- Timeline of what happened in a conversation: working state changes, LLM
+-- requests and tool calls starting and ending. ref_id links the start and
+-- end of a request or tool call; name is the state, model or tool.
+
+CREATE TABLE conversation_events (
+    event_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    ref_id TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL DEFAULT '',
+    duration_ms INTEGER,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_conversation_events_conversation ON conversation_events(conversation_id, event_id);
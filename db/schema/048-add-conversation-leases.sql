@@ -0,0 +1,10 @@
+-- Leases of conversations to the Shelley instance driving their agent loop,
+-- when several instances share the database. A lease is renewed while the
+-- loop runs; one that has expired can be taken over by another instance.
+
+CREATE TABLE conversation_leases (
+    conversation_id TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
@@ -0,0 +1,24 @@
+-- Review comments left by teammates on what the agent did. A comment is
+-- attached to a message or to lines of a file in a diff, and replies point to
+-- the comment that starts their thread. Comments are never sent to the LLM.
+
+CREATE TABLE review_comments (
+    comment_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id TEXT NOT NULL,
+    parent_id INTEGER,
+    message_id TEXT,
+    diff_id TEXT NOT NULL DEFAULT '',
+    file_path TEXT NOT NULL DEFAULT '',
+    start_line INTEGER NOT NULL DEFAULT 0,
+    end_line INTEGER NOT NULL DEFAULT 0,
+    author TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    resolved BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES review_comments(comment_id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_review_comments_conversation_id ON review_comments(conversation_id);
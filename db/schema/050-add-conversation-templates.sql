@@ -0,0 +1,16 @@
+-- Conversation templates: an initial prompt and the settings of a conversation,
+-- saved under a name to start repeatable workflows. owner is the value of the
+-- server's identity header, as for snippets. config is the JSON of the model,
+-- working directories, hooks, plan mode and sampling and sandbox settings.
+
+CREATE TABLE conversation_templates (
+    template_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    prompt TEXT NOT NULL,
+    config TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner, name)
+);
@@ -0,0 +1,12 @@
+-- Short summaries of conversations, generated when they go idle so that old
+-- threads can be recognized from the conversation list. files is a JSON array
+-- of the files the agent edited; sequence_id is the last message summarized.
+
+CREATE TABLE conversation_summaries (
+    conversation_id TEXT PRIMARY KEY,
+    summary TEXT NOT NULL,
+    files TEXT NOT NULL DEFAULT '[]',
+    sequence_id INTEGER NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
@@ -0,0 +1,12 @@
+-- The last message of a conversation each user has read. owner is the value
+-- of the server's identity header, as for snippets. The conversation list
+-- reports what happened after it.
+
+CREATE TABLE conversation_reads (
+    owner TEXT NOT NULL DEFAULT '',
+    conversation_id TEXT NOT NULL,
+    sequence_id INTEGER NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (owner, conversation_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
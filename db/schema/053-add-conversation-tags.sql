@@ -0,0 +1,11 @@
+-- Free-form tags for organizing conversations.
+
+CREATE TABLE conversation_tags (
+    conversation_id TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, tag),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_conversation_tags_tag ON conversation_tags(tag);
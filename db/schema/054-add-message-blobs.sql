@@ -0,0 +1,13 @@
+-- Large message payloads, kept out of the messages table so that scanning it
+-- stays fast. A message whose llm_data or display_data is over the size limit
+-- stores {"$blob":"<hash>"} in the column instead, where hash is the SHA-256
+-- of the payload, and the gzip-compressed payload here. Blobs are shared by
+-- identical payloads and deleted once no message or truncated message refers
+-- to them. Searching message content does not look into blobs.
+
+CREATE TABLE message_blobs (
+    hash TEXT PRIMARY KEY,
+    data BLOB NOT NULL,
+    size INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,20 @@
+-- A/B comparisons: one prompt sent to two models, each in its own
+-- conversation. chosen_conversation_id is the conversation the user picked
+-- to continue; the other one is archived.
+
+CREATE TABLE comparisons (
+    comparison_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner TEXT NOT NULL DEFAULT '',
+    prompt TEXT NOT NULL,
+    conversation_a TEXT NOT NULL,
+    conversation_b TEXT NOT NULL,
+    chosen_conversation_id TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    chosen_at DATETIME,
+    FOREIGN KEY (conversation_a) REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    FOREIGN KEY (conversation_b) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_comparisons_owner ON comparisons(owner, comparison_id);
+CREATE INDEX idx_comparisons_conversation_a ON comparisons(conversation_a);
+CREATE INDEX idx_comparisons_conversation_b ON comparisons(conversation_b);
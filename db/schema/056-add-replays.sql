@@ -0,0 +1,22 @@
+-- Replays of a conversation's prompts with another model, for comparing the
+-- outcomes and cost. The prompts run as a sequential batch in
+-- replay_conversation_id, in sandbox (a copy of the original working
+-- directory) or, if read_only, in the original one in plan mode.
+
+CREATE TABLE replays (
+    replay_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner TEXT NOT NULL DEFAULT '',
+    conversation_id TEXT NOT NULL,
+    replay_conversation_id TEXT NOT NULL,
+    batch_id INTEGER NOT NULL,
+    read_only BOOLEAN NOT NULL DEFAULT FALSE,
+    sandbox TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    FOREIGN KEY (replay_conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    FOREIGN KEY (batch_id) REFERENCES batches(batch_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_replays_conversation ON replays(conversation_id, replay_id);
+CREATE INDEX idx_replays_replay_conversation ON replays(replay_conversation_id);
+CREATE INDEX idx_replays_batch ON replays(batch_id);
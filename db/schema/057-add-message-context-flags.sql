@@ -0,0 +1,14 @@
+-- Flags users set on messages for when a conversation's context is compacted,
+-- as when it is continued in a new conversation: pinned messages are kept in
+-- full and evictable ones are left out.
+
+CREATE TABLE message_context_flags (
+    message_id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    flag TEXT NOT NULL CHECK (flag IN ('pinned', 'evictable')),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_message_context_flags_conversation_id ON message_context_flags(conversation_id);
@@ -0,0 +1,7 @@
+-- Parallel batches can be submitted to the provider's batch API, which
+-- answers each prompt once, without tools, at half price. provider_batch_id
+-- is the provider's ID of such a batch and provider_status its last polled
+-- status.
+
+ALTER TABLE batches ADD COLUMN provider_batch_id TEXT;
+ALTER TABLE batches ADD COLUMN provider_status TEXT NOT NULL DEFAULT '';
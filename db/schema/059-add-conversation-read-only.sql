@@ -0,0 +1,4 @@
+-- Read-only conversations may only use tools that don't write files or
+-- change anything over the network.
+
+ALTER TABLE conversation_settings ADD COLUMN read_only BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+-- Conversations may require the user to approve risky shell commands, such as
+-- force pushes or recursive deletes, before they run.
+
+ALTER TABLE conversation_settings ADD COLUMN approve_risky_commands BOOLEAN NOT NULL DEFAULT FALSE;
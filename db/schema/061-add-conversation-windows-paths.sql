@@ -0,0 +1,4 @@
+-- Conversations on a server running in WSL may show paths to the user in the
+-- form of the Windows host.
+
+ALTER TABLE conversation_settings ADD COLUMN windows_paths BOOLEAN NOT NULL DEFAULT FALSE;
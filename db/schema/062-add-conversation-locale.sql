@@ -0,0 +1,5 @@
+-- Conversations may record the user's IANA time zone and BCP 47 locale, so
+-- that the agent knows their local time and how they write dates.
+
+ALTER TABLE conversation_settings ADD COLUMN timezone TEXT;
+ALTER TABLE conversation_settings ADD COLUMN locale TEXT;
@@ -0,0 +1,5 @@
+-- The values of HTTP tool headers, which often hold credentials, are kept in
+-- the secrets store; headers then maps each header name to the name of its
+-- secret. Values stored before are moved there by the server when it starts.
+
+ALTER TABLE http_tools ADD COLUMN headers_encrypted BOOLEAN NOT NULL DEFAULT FALSE;
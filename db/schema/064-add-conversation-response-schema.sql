@@ -0,0 +1,5 @@
+-- Conversations may ask for answers that are JSON matching a schema, for
+-- programs consuming them. response_schema is the JSON schema; NULL leaves
+-- answers free-form.
+
+ALTER TABLE conversation_settings ADD COLUMN response_schema TEXT;
@@ -0,0 +1,19 @@
+-- Key tool outputs by conversation and tool_use_id. Tool use IDs are chosen
+-- by the model's provider and aren't guaranteed to be unique across
+-- conversations, and one conversation must not overwrite or read another's
+-- outputs.
+
+CREATE TABLE tool_outputs_new (
+    conversation_id TEXT NOT NULL,
+    tool_use_id TEXT NOT NULL,
+    output TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, tool_use_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+INSERT INTO tool_outputs_new (conversation_id, tool_use_id, output, created_at)
+SELECT conversation_id, tool_use_id, output, created_at FROM tool_outputs;
+
+DROP TABLE tool_outputs;
+ALTER TABLE tool_outputs_new RENAME TO tool_outputs;
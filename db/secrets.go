@@ -0,0 +1,286 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/secrets"
+)
+
+// Settings used by the secrets store
+const (
+	// secretsKeySetting holds the hex-encoded default key, used when no passphrase or keychain is configured
+	secretsKeySetting = "secrets_key"
+	// secretsSaltSetting holds the hex-encoded salt for passphrase keys
+	secretsSaltSetting = "secrets_salt"
+	// secretsCheckSetting holds a value sealed with the current key, to detect a wrong key at startup
+	secretsCheckSetting = "secrets_check"
+)
+
+const secretsCheckValue = "shelley"
+
+// ErrSecretsKeyMismatch is returned by InitSecrets when the configured key is
+// not the one the stored secrets were encrypted with.
+var ErrSecretsKeyMismatch = errors.New("secrets key does not match the stored secrets")
+
+// SecretsConfig selects where the secrets key comes from. With neither set,
+// a random key is kept in the database.
+type SecretsConfig struct {
+	// Passphrase is a master passphrase the key is derived from
+	Passphrase string
+	// Keychain stores the key in the OS keychain; it takes precedence over Passphrase
+	Keychain bool
+}
+
+// InitSecrets sets up encryption of stored secrets. Secrets stored with the
+// default key are re-encrypted when a passphrase or keychain key is
+// configured, and the default key is then removed from the database. Secret
+// environment variables and HTTP tool headers stored before encryption was
+// added are encrypted.
+func (db *DB) InitSecrets(ctx context.Context, cfg SecretsConfig) error {
+	db.secretsMu.Lock()
+	defer db.secretsMu.Unlock()
+	c, err := db.initSecrets(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	db.secrets = c
+	return nil
+}
+
+// secretsCipher returns the cipher for stored secrets, initializing the
+// store with the default key if InitSecrets was not called.
+func (db *DB) secretsCipher(ctx context.Context) (*secrets.Cipher, error) {
+	db.secretsMu.Lock()
+	defer db.secretsMu.Unlock()
+	if db.secrets == nil {
+		c, err := db.initSecrets(ctx, SecretsConfig{})
+		if err != nil {
+			return nil, err
+		}
+		db.secrets = c
+	}
+	return db.secrets, nil
+}
+
+func (db *DB) initSecrets(ctx context.Context, cfg SecretsConfig) (*secrets.Cipher, error) {
+	defaultKey, err := db.GetSetting(ctx, secretsKeySetting)
+	if err != nil {
+		return nil, err
+	}
+	check, err := db.GetSetting(ctx, secretsCheckSetting)
+	if err != nil {
+		return nil, err
+	}
+
+	var key []byte
+	switch {
+	case cfg.Keychain:
+		if key, err = secrets.KeychainKey(ctx); err != nil {
+			return nil, err
+		}
+	case cfg.Passphrase != "":
+		saltHex, err := db.GetOrCreateSetting(ctx, secretsSaltSetting, func() (string, error) {
+			salt, err := secrets.GenerateSalt()
+			return hex.EncodeToString(salt), err
+		})
+		if err != nil {
+			return nil, err
+		}
+		salt, err := hex.DecodeString(saltHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secrets salt: %w", err)
+		}
+		if key, err = secrets.DeriveKey(cfg.Passphrase, salt); err != nil {
+			return nil, err
+		}
+	default:
+		if defaultKey == "" && check != "" {
+			return nil, fmt.Errorf("%w: they were stored with a passphrase or keychain key", ErrSecretsKeyMismatch)
+		}
+		keyHex, err := db.GetOrCreateSetting(ctx, secretsKeySetting, func() (string, error) {
+			key, err := secrets.GenerateKey()
+			return hex.EncodeToString(key), err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if key, err = hex.DecodeString(keyHex); err != nil {
+			return nil, fmt.Errorf("invalid secrets key: %w", err)
+		}
+		// This is the current key, not one to move away from
+		defaultKey = ""
+	}
+	c, err := secrets.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// previous is the default key when moving to a passphrase or keychain key
+	var previous *secrets.Cipher
+	if defaultKey != "" {
+		previousKey, err := hex.DecodeString(defaultKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secrets key: %w", err)
+		}
+		if previous, err = secrets.NewCipher(previousKey); err != nil {
+			return nil, err
+		}
+	} else if check != "" {
+		if _, err := c.Open(check); err != nil {
+			return nil, ErrSecretsKeyMismatch
+		}
+	}
+
+	sealedCheck, err := c.Seal(secretsCheckValue)
+	if err != nil {
+		return nil, err
+	}
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		if err := resealSecrets(ctx, q, previous, c); err != nil {
+			return err
+		}
+		if err := q.SetSetting(ctx, generated.SetSettingParams{Key: secretsCheckSetting, Value: sealedCheck}); err != nil {
+			return err
+		}
+		if previous != nil {
+			return q.DeleteSetting(ctx, secretsKeySetting)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// resealSecrets re-encrypts secrets sealed with previous, if not nil, with c,
+// and encrypts secret environment values and HTTP tool headers still stored
+// in plain text.
+func resealSecrets(ctx context.Context, q *generated.Queries, previous, c *secrets.Cipher) error {
+	reseal := func(sealed string) (string, error) {
+		value, err := previous.Open(sealed)
+		if err != nil {
+			return "", err
+		}
+		return c.Seal(value)
+	}
+
+	if previous != nil {
+		stored, err := q.ListSecrets(ctx)
+		if err != nil {
+			return err
+		}
+		for _, secret := range stored {
+			value, err := reseal(secret.Value)
+			if err != nil {
+				return fmt.Errorf("failed to re-encrypt secret %s: %w", secret.Name, err)
+			}
+			if err := q.SetSecret(ctx, generated.SetSecretParams{Name: secret.Name, Value: value}); err != nil {
+				return err
+			}
+		}
+	}
+
+	env, err := q.ListSecretConversationEnv(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range env {
+		var value string
+		switch {
+		case !v.Encrypted:
+			value, err = c.Seal(v.Value)
+		case previous != nil:
+			value, err = reseal(v.Value)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s of conversation %s: %w", v.Name, v.ConversationID, err)
+		}
+		err = q.UpdateConversationEnvValue(ctx, generated.UpdateConversationEnvValueParams{
+			Value:          value,
+			Encrypted:      true,
+			ConversationID: v.ConversationID,
+			Name:           v.Name,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	tools, err := q.ListHTTPTools(ctx)
+	if err != nil {
+		return err
+	}
+	for _, tool := range tools {
+		if tool.HeadersEncrypted {
+			continue
+		}
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(tool.Headers), &headers); err != nil {
+			return fmt.Errorf("invalid headers of HTTP tool %s: %w", tool.Name, err)
+		}
+		if _, err := setHTTPToolHeaders(ctx, q, c, tool.ToolID, "{}", headers); err != nil {
+			return fmt.Errorf("failed to encrypt the headers of HTTP tool %s: %w", tool.Name, err)
+		}
+	}
+	return nil
+}
+
+// GetSecret returns the decrypted value of a secret, or "" if it is not set.
+func (db *DB) GetSecret(ctx context.Context, name string) (string, error) {
+	var sealed string
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		sealed, err = q.GetSecret(ctx, name)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	c, err := db.secretsCipher(ctx)
+	if err != nil {
+		return "", err
+	}
+	value, err := c.Open(sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// SetSecret encrypts and stores a secret, replacing any previous value.
+func (db *DB) SetSecret(ctx context.Context, name, value string) error {
+	c, err := db.secretsCipher(ctx)
+	if err != nil {
+		return err
+	}
+	sealed, err := c.Seal(value)
+	if err != nil {
+		return err
+	}
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetSecret(ctx, generated.SetSecretParams{Name: name, Value: sealed})
+	})
+}
+
+// DeleteSecret removes a secret.
+func (db *DB) DeleteSecret(ctx context.Context, name string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteSecret(ctx, name)
+	})
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db/generated"
+)
+
+func TestSecrets(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	conv, err := db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateConversation failed: %v", err)
+	}
+	// A secret stored in plain text before encryption was added
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		return generated.New(tx.Conn()).SetConversationEnv(ctx, generated.SetConversationEnvParams{
+			ConversationID: conv.ConversationID,
+			Name:           "OLD_TOKEN",
+			Value:          "old-value",
+			Secret:         true,
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.SetSecret(ctx, "provider/anthropic", "sk-ant"); err != nil {
+		t.Fatalf("SetSecret failed: %v", err)
+	}
+	if err := db.SetConversationEnv(ctx, conv.ConversationID, "TOKEN", "new-value", true); err != nil {
+		t.Fatalf("SetConversationEnv failed: %v", err)
+	}
+	if err := db.SetConversationEnv(ctx, conv.ConversationID, "PLAIN", "visible", false); err != nil {
+		t.Fatalf("SetConversationEnv failed: %v", err)
+	}
+
+	// rawValues returns the stored values, as they are on disk
+	rawValues := func() map[string]string {
+		t.Helper()
+		values := map[string]string{}
+		err := db.Queries(ctx, func(q *generated.Queries) error {
+			stored, err := q.ListSecrets(ctx)
+			if err != nil {
+				return err
+			}
+			for _, s := range stored {
+				values[s.Name] = s.Value
+			}
+			env, err := q.ListConversationEnv(ctx, conv.ConversationID)
+			for _, v := range env {
+				values[v.Name] = v.Value
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+	raw := rawValues()
+	if raw["provider/anthropic"] == "sk-ant" || raw["TOKEN"] == "new-value" {
+		t.Errorf("secrets stored in plain text: %v", raw)
+	}
+	if raw["PLAIN"] != "visible" {
+		t.Errorf("expected non-secret values in plain text, got %q", raw["PLAIN"])
+	}
+	if raw["OLD_TOKEN"] == "old-value" {
+		t.Error("expected the old plain text secret to be encrypted on first use")
+	}
+
+	// checkValues verifies that all values read back decrypted
+	checkValues := func() {
+		t.Helper()
+		if value, err := db.GetSecret(ctx, "provider/anthropic"); err != nil || value != "sk-ant" {
+			t.Errorf("GetSecret = %q, %v", value, err)
+		}
+		env, err := db.ListConversationEnv(ctx, conv.ConversationID)
+		if err != nil {
+			t.Fatalf("ListConversationEnv failed: %v", err)
+		}
+		got := map[string]string{}
+		for _, v := range env {
+			got[v.Name] = v.Value
+		}
+		if got["TOKEN"] != "new-value" || got["OLD_TOKEN"] != "old-value" || got["PLAIN"] != "visible" {
+			t.Errorf("unexpected env: %v", got)
+		}
+	}
+	checkValues()
+	if value, err := db.GetSecret(ctx, "missing"); err != nil || value != "" {
+		t.Errorf("GetSecret(missing) = %q, %v", value, err)
+	}
+
+	// Moving to a passphrase re-encrypts everything and drops the stored key
+	before := rawValues()
+	if err := db.InitSecrets(ctx, SecretsConfig{Passphrase: "correct horse"}); err != nil {
+		t.Fatalf("InitSecrets failed: %v", err)
+	}
+	if after := rawValues(); after["provider/anthropic"] == before["provider/anthropic"] || after["TOKEN"] == before["TOKEN"] {
+		t.Error("expected secrets to be re-encrypted with the passphrase key")
+	}
+	if key, _ := db.GetSetting(ctx, secretsKeySetting); key != "" {
+		t.Error("expected the default key to be removed")
+	}
+	checkValues()
+
+	if err := db.InitSecrets(ctx, SecretsConfig{Passphrase: "wrong horse"}); !errors.Is(err, ErrSecretsKeyMismatch) {
+		t.Errorf("expected ErrSecretsKeyMismatch for a wrong passphrase, got %v", err)
+	}
+	if err := db.InitSecrets(ctx, SecretsConfig{}); !errors.Is(err, ErrSecretsKeyMismatch) {
+		t.Errorf("expected ErrSecretsKeyMismatch without the passphrase, got %v", err)
+	}
+	if err := db.InitSecrets(ctx, SecretsConfig{Passphrase: "correct horse"}); err != nil {
+		t.Fatalf("InitSecrets with the right passphrase failed: %v", err)
+	}
+	checkValues()
+
+	if err := db.DeleteSecret(ctx, "provider/anthropic"); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+	if value, _ := db.GetSecret(ctx, "provider/anthropic"); value != "" {
+		t.Errorf("expected the secret to be deleted, got %q", value)
+	}
+}
+
+func TestHTTPToolHeaders(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	// A tool whose headers were stored in plain text before they were moved
+	// to the secrets store
+	var old generated.HttpTool
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		var err error
+		old, err = generated.New(tx.Conn()).CreateHTTPTool(ctx, generated.CreateHTTPToolParams{Name: "old", Description: "d", InputSchema: "{}", Url: "https://example.com"})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE http_tools SET headers = ?, headers_encrypted = FALSE WHERE tool_id = ?", `{"Authorization":"Bearer old"}`, old.ToolID)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitSecrets(ctx, SecretsConfig{}); err != nil {
+		t.Fatalf("InitSecrets failed: %v", err)
+	}
+
+	tool, err := db.CreateHTTPTool(ctx, generated.CreateHTTPToolParams{Name: "new", Description: "d", InputSchema: "{}", Url: "https://example.com"}, map[string]string{"X-Api-Key": "key1"})
+	if err != nil {
+		t.Fatalf("CreateHTTPTool failed: %v", err)
+	}
+
+	headers := func(toolID int64) map[string]string {
+		t.Helper()
+		tool, err := db.GetHTTPTool(ctx, toolID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(tool.Headers, "Bearer") || strings.Contains(tool.Headers, "key") {
+			t.Errorf("header values stored with the tool: %s", tool.Headers)
+		}
+		headers, err := db.HTTPToolHeaders(ctx, *tool)
+		if err != nil {
+			t.Fatalf("HTTPToolHeaders failed: %v", err)
+		}
+		return headers
+	}
+	if got := headers(old.ToolID); got["Authorization"] != "Bearer old" {
+		t.Errorf("headers of the old tool = %v", got)
+	}
+	if got := headers(tool.ToolID); got["X-Api-Key"] != "key1" {
+		t.Errorf("headers = %v", got)
+	}
+
+	// Updating without headers keeps them; with headers replaces them
+	params := generated.UpdateHTTPToolParams{Name: "new", Description: "d2", InputSchema: "{}", Url: "https://example.com", ToolID: tool.ToolID}
+	if _, err := db.UpdateHTTPTool(ctx, params, nil); err != nil {
+		t.Fatalf("UpdateHTTPTool failed: %v", err)
+	}
+	if got := headers(tool.ToolID); got["X-Api-Key"] != "key1" {
+		t.Errorf("headers after an update without headers = %v", got)
+	}
+	if _, err := db.UpdateHTTPTool(ctx, params, map[string]string{"X-Other": "key2"}); err != nil {
+		t.Fatalf("UpdateHTTPTool failed: %v", err)
+	}
+	if got := headers(tool.ToolID); len(got) != 1 || got["X-Other"] != "key2" {
+		t.Errorf("headers after replacing them = %v", got)
+	}
+	if value, _ := db.GetSecret(ctx, httpToolSecretName(tool.ToolID, "X-Api-Key")); value != "" {
+		t.Errorf("replaced header left its secret: %q", value)
+	}
+
+	if err := db.DeleteHTTPTool(ctx, tool.ToolID); err != nil {
+		t.Fatalf("DeleteHTTPTool failed: %v", err)
+	}
+	if value, _ := db.GetSecret(ctx, httpToolSecretName(tool.ToolID, "X-Other")); value != "" {
+		t.Errorf("deleted tool left its secret: %q", value)
+	}
+}
@@ -0,0 +1,187 @@
+// Package devcontainer runs the agent's commands in a workspace's development
+// container, so that they get the same toolchain as the developers.
+//
+// A workspace (the git repository containing a directory, or the directory)
+// has a development container when it has .devcontainer/devcontainer.json or
+// .devcontainer.json. A Manager builds and starts the container the first
+// time a command runs in the workspace, with the workspace mounted at the
+// same path as on the host so that paths mean the same inside and out.
+//
+// Image and Dockerfile based configurations are supported; Docker Compose
+// based ones are not.
+package devcontainer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// configPaths are where a workspace's configuration is looked for, in order.
+var configPaths = []string{
+	filepath.Join(".devcontainer", "devcontainer.json"),
+	".devcontainer.json",
+}
+
+// Config is the part of devcontainer.json Shelley uses.
+type Config struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	Build *Build `json:"build"`
+	// DockerComposeFile is only read to reject Compose configurations.
+	DockerComposeFile any `json:"dockerComposeFile"`
+	// RunArgs are extra arguments for docker run.
+	RunArgs       []string          `json:"runArgs"`
+	ContainerEnv  map[string]string `json:"containerEnv"`
+	RemoteEnv     map[string]string `json:"remoteEnv"`
+	ContainerUser string            `json:"containerUser"`
+	RemoteUser    string            `json:"remoteUser"`
+	// PostCreateCommand is a shell command string or an argument list.
+	PostCreateCommand any `json:"postCreateCommand"`
+}
+
+// Build describes how to build the container's image.
+type Build struct {
+	// Dockerfile and Context are relative to devcontainer.json.
+	Dockerfile string            `json:"dockerfile"`
+	Context    string            `json:"context"`
+	Args       map[string]string `json:"args"`
+}
+
+// Find returns the devcontainer.json of the workspace root, or "" if it has
+// none.
+func Find(root string) (string, error) {
+	for _, p := range configPaths {
+		path := filepath.Join(root, p)
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// Load reads a devcontainer.json, which may have comments and trailing
+// commas.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(standardize(data), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if cfg.DockerComposeFile != nil {
+		return nil, fmt.Errorf("%s: Docker Compose configurations are not supported", path)
+	}
+	if cfg.Image == "" && (cfg.Build == nil || cfg.Build.Dockerfile == "") {
+		return nil, fmt.Errorf("%s: needs an image or a build.dockerfile", path)
+	}
+	return &cfg, nil
+}
+
+// postCreateCommand returns the post-create command as arguments, running a
+// string through the shell.
+func (c *Config) postCreateCommand() ([]string, error) {
+	switch cmd := c.PostCreateCommand.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if cmd == "" {
+			return nil, nil
+		}
+		return []string{"/bin/sh", "-c", cmd}, nil
+	case []any:
+		var args []string
+		for _, arg := range cmd {
+			s, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("postCreateCommand arguments must be strings")
+			}
+			args = append(args, s)
+		}
+		return args, nil
+	}
+	return nil, fmt.Errorf("postCreateCommand must be a string or a list of strings")
+}
+
+// standardize turns JSON with comments and trailing commas into JSON.
+func standardize(data []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out.WriteByte(c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out.WriteByte(data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			out.WriteByte('\n')
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(data)
+			} else {
+				i += end + 3
+			}
+			out.WriteByte(' ')
+		case c == ',':
+			// Drop the comma if only whitespace is left before a closing bracket
+			rest := bytes.TrimLeft(standardizeRest(data[i+1:]), " \t\r\n")
+			if len(rest) > 0 && (rest[0] == '}' || rest[0] == ']') {
+				continue
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
+
+// standardizeRest returns the start of data with comments removed, for
+// looking ahead past a comma.
+func standardizeRest(data []byte) []byte {
+	for {
+		trimmed := bytes.TrimLeft(data, " \t\r\n")
+		switch {
+		case bytes.HasPrefix(trimmed, []byte("//")):
+			if i := bytes.IndexByte(trimmed, '\n'); i >= 0 {
+				data = trimmed[i:]
+				continue
+			}
+			return nil
+		case bytes.HasPrefix(trimmed, []byte("/*")):
+			if i := bytes.Index(trimmed[2:], []byte("*/")); i >= 0 {
+				data = trimmed[i+4:]
+				continue
+			}
+			return nil
+		}
+		return trimmed
+	}
+}
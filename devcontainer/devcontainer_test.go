@@ -0,0 +1,157 @@
+package devcontainer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if path, err := Find(dir); err != nil || path != "" {
+		t.Fatalf("Find in a directory without configuration = %q, %v", path, err)
+	}
+
+	os.Mkdir(filepath.Join(dir, ".devcontainer"), 0o755)
+	configPath := filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	os.WriteFile(configPath, []byte(`{
+	// The toolchain
+	"name": "Go // not a comment",
+	"build": {
+		"dockerfile": "Dockerfile", /* next to this file */
+		"args": {"GO_VERSION": "1.24",},
+	},
+	"remoteUser": "vscode",
+	"postCreateCommand": ["go", "mod", "download"],
+}`), 0o644)
+	path, err := Find(dir)
+	if err != nil || path != configPath {
+		t.Fatalf("Find = %q, %v; want %q", path, err, configPath)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "Go // not a comment" || cfg.Build.Dockerfile != "Dockerfile" || cfg.Build.Args["GO_VERSION"] != "1.24" || cfg.RemoteUser != "vscode" {
+		t.Errorf("config = %+v", cfg)
+	}
+	if args, err := cfg.postCreateCommand(); err != nil || !slices.Equal(args, []string{"go", "mod", "download"}) {
+		t.Errorf("postCreateCommand = %v, %v", args, err)
+	}
+
+	for _, bad := range []string{
+		`{"dockerComposeFile": "compose.yml", "service": "app"}`,
+		`{"name": "no image"}`,
+		`{"image": `,
+	} {
+		os.WriteFile(configPath, []byte(bad), 0o644)
+		if _, err := Load(configPath); err == nil {
+			t.Errorf("Load(%s) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	cfg := &Config{
+		Build:        &Build{Dockerfile: "Dockerfile", Context: "..", Args: map[string]string{"B": "2", "A": "1"}},
+		ContainerEnv: map[string]string{"CI": "1"},
+		RunArgs:      []string{"--cap-add=SYS_PTRACE"},
+	}
+	build := strings.Join(buildArgs(cfg, "/src/app/.devcontainer/devcontainer.json", "img"), " ")
+	if want := "build -t img -f /src/app/.devcontainer/Dockerfile --build-arg A=1 --build-arg B=2 /src/app"; build != want {
+		t.Errorf("build args = %s, want %s", build, want)
+	}
+	run := strings.Join(runArgs(cfg, "/src/app", "img"), " ")
+	for _, want := range []string{"-v /src/app:/src/app", "-w /src/app", "-e CI=1", "--cap-add=SYS_PTRACE", "img -c"} {
+		if !strings.Contains(run, want) {
+			t.Errorf("run args %q don't contain %q", run, want)
+		}
+	}
+
+	c := &Container{ID: "abc", runtime: "docker", remoteUser: "vscode", remoteEnv: map[string]string{"PATH_EXTRA": "/x"}}
+	cmd := c.Command(context.Background(), "/src/app/sub", "go test ./...", []string{"SECRET"})
+	if got, want := strings.Join(cmd.Args, " "), "docker exec -i -w /src/app/sub -u vscode -e PATH_EXTRA=/x -e SECRET abc bash -c go test ./..."; got != want {
+		t.Errorf("exec args = %s, want %s", got, want)
+	}
+}
+
+func TestManager(t *testing.T) {
+	// A fake container CLI that logs its arguments
+	bin := t.TempDir()
+	log := filepath.Join(bin, "log")
+	runtime := filepath.Join(bin, "docker")
+	os.WriteFile(runtime, []byte(`#!/bin/sh
+echo "$@" >> "`+log+`"
+case "$1" in
+run) echo container1 ;;
+inspect) echo true ;;
+esac
+`), 0o755)
+
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, ".devcontainer.json"), []byte(`{"image": "golang:1.24", "postCreateCommand": "make setup"}`), 0o644)
+	m := NewManager(runtime)
+
+	c, err := m.Container(context.Background(), workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == nil || c.ID != "container1" || c.Root != workspace {
+		t.Fatalf("container = %+v", c)
+	}
+	// The running container is reused
+	if again, err := m.Container(context.Background(), workspace); err != nil || again != c {
+		t.Errorf("second Container = %+v, %v; want the same container", again, err)
+	}
+	m.Close()
+
+	data, _ := os.ReadFile(log)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "run ") || lines[1] != "exec -w "+workspace+" container1 /bin/sh -c make setup" ||
+		!strings.HasPrefix(lines[2], "inspect ") || lines[3] != "rm -f container1" {
+		t.Errorf("runtime calls:\n%s", data)
+	}
+
+	if c, err := m.Container(context.Background(), t.TempDir()); c != nil || err != nil {
+		t.Errorf("Container without configuration = %+v, %v; want nil", c, err)
+	}
+}
+
+func TestManagerSlowStart(t *testing.T) {
+	// A fake container CLI whose containers take a while to start
+	bin := t.TempDir()
+	log := filepath.Join(bin, "log")
+	runtime := filepath.Join(bin, "docker")
+	os.WriteFile(runtime, []byte(`#!/bin/sh
+echo "$1" >> "`+log+`"
+case "$1" in
+run) sleep 1; echo container1 ;;
+inspect) echo true ;;
+esac
+`), 0o755)
+
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, ".devcontainer.json"), []byte(`{"image": "golang:1.24"}`), 0o644)
+	m := NewManager(runtime)
+	defer m.Close()
+
+	// A command giving up doesn't stop the container from starting
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if c, err := m.Container(ctx, workspace); err == nil || !strings.Contains(err.Error(), "still starting") {
+		t.Fatalf("Container with a short timeout = %+v, %v; want still starting", c, err)
+	}
+	c, err := m.Container(context.Background(), workspace)
+	if err != nil || c == nil || c.ID != "container1" {
+		t.Fatalf("Container = %+v, %v", c, err)
+	}
+
+	data, _ := os.ReadFile(log)
+	if got := strings.Count(string(data), "run\n"); got != 1 {
+		t.Errorf("started %d containers, want 1:\n%s", got, data)
+	}
+}
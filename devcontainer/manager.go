@@ -0,0 +1,249 @@
+package devcontainer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/gitstate"
+	"tailscale.com/util/singleflight"
+)
+
+// startTimeout bounds building the image and starting a container. Starting
+// is detached from the command that asked for the container, whose timeout
+// is usually far shorter than a first image build.
+const startTimeout = 30 * time.Minute
+
+// Manager starts and caches development containers, one per workspace.
+type Manager struct {
+	// runtime is the container CLI, e.g. docker or podman.
+	runtime string
+
+	mu         sync.Mutex
+	containers map[string]*Container // keyed by workspace root
+
+	// starts starts at most one container per workspace root at a time.
+	starts singleflight.Group[string, *Container]
+}
+
+// NewManager creates a Manager running containers with the given CLI, such
+// as "docker" or "podman".
+func NewManager(runtime string) *Manager {
+	return &Manager{runtime: runtime, containers: make(map[string]*Container)}
+}
+
+// Container is a running development container.
+type Container struct {
+	// ID is the container's ID.
+	ID string
+	// Root is the workspace mounted into the container.
+	Root string
+
+	runtime    string
+	remoteUser string
+	remoteEnv  map[string]string
+}
+
+// Container returns the container for the workspace containing dir,
+// building and starting it if needed. It returns nil if the workspace has no
+// devcontainer.json.
+func (m *Manager) Container(ctx context.Context, dir string) (*Container, error) {
+	root := workspaceRoot(dir)
+	configPath, err := Find(root)
+	if err != nil || configPath == "" {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	c, ok := m.containers[root]
+	m.mu.Unlock()
+	if ok && c.running(ctx) {
+		return c, nil
+	}
+
+	result := m.starts.DoChan(root, func() (*Container, error) {
+		m.mu.Lock()
+		if cached := m.containers[root]; cached != nil && cached != c {
+			// Started by a call that finished since we looked
+			m.mu.Unlock()
+			return cached, nil
+		}
+		delete(m.containers, root)
+		m.mu.Unlock()
+
+		cfg, err := Load(configPath)
+		if err != nil {
+			return nil, err
+		}
+		startCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), startTimeout)
+		defer cancel()
+		c, err := m.start(startCtx, cfg, configPath, root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start the development container of %s: %w", root, err)
+		}
+		m.mu.Lock()
+		m.containers[root] = c
+		m.mu.Unlock()
+		return c, nil
+	})
+	select {
+	case r := <-result:
+		return r.Val, r.Err
+	case <-ctx.Done():
+		// The container keeps starting for the next command
+		return nil, fmt.Errorf("development container of %s is still starting: %w", root, context.Cause(ctx))
+	}
+}
+
+// Close removes all containers.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	containers := m.containers
+	m.containers = make(map[string]*Container)
+	m.mu.Unlock()
+	for _, c := range containers {
+		if out, err := exec.Command(c.runtime, "rm", "-f", c.ID).CombinedOutput(); err != nil {
+			slog.Warn("Failed to remove development container", "id", c.ID, "error", err, "output", string(out))
+		}
+	}
+}
+
+// start builds the image if the configuration has a Dockerfile, then starts a
+// container with the workspace mounted and runs the post-create command.
+func (m *Manager) start(ctx context.Context, cfg *Config, configPath, root string) (*Container, error) {
+	postCreate, err := cfg.postCreateCommand()
+	if err != nil {
+		return nil, err
+	}
+	image := cfg.Image
+	if cfg.Build != nil && cfg.Build.Dockerfile != "" {
+		image = imageName(configPath)
+		slog.Info("Building development container image", "config", configPath, "image", image)
+		if _, err := m.run(ctx, buildArgs(cfg, configPath, image)...); err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info("Starting development container", "config", configPath, "image", image)
+	out, err := m.run(ctx, runArgs(cfg, root, image)...)
+	if err != nil {
+		return nil, err
+	}
+	c := &Container{
+		ID:         strings.TrimSpace(out),
+		Root:       root,
+		runtime:    m.runtime,
+		remoteUser: cfg.RemoteUser,
+		remoteEnv:  cfg.RemoteEnv,
+	}
+	if len(postCreate) > 0 {
+		args := append([]string{"exec", "-w", root, c.ID}, postCreate...)
+		if _, err := m.run(ctx, args...); err != nil {
+			exec.Command(m.runtime, "rm", "-f", c.ID).Run()
+			return nil, fmt.Errorf("postCreateCommand failed: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// run runs the container CLI and returns its output.
+func (m *Manager) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, m.runtime, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s %s: %w\n%s", m.runtime, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Command returns a command running command with bash in dir inside the
+// container. envNames are the variables forwarded from the command's
+// environment, which the caller sets in cmd.Env; passing them by name keeps
+// their values, which may be secrets, out of the process list.
+func (c *Container) Command(ctx context.Context, dir, command string, envNames []string) *exec.Cmd {
+	args := []string{"exec", "-i", "-w", dir}
+	if c.remoteUser != "" {
+		args = append(args, "-u", c.remoteUser)
+	}
+	for _, k := range sortedKeys(c.remoteEnv) {
+		args = append(args, "-e", k+"="+c.remoteEnv[k])
+	}
+	for _, name := range envNames {
+		args = append(args, "-e", name)
+	}
+	args = append(args, c.ID, "bash", "-c", command)
+	return exec.CommandContext(ctx, c.runtime, args...)
+}
+
+// running reports whether the container is still running.
+func (c *Container) running(ctx context.Context) bool {
+	out, err := exec.CommandContext(ctx, c.runtime, "inspect", "-f", "{{.State.Running}}", c.ID).Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// buildArgs returns the arguments building the image of a Dockerfile based
+// configuration.
+func buildArgs(cfg *Config, configPath, image string) []string {
+	dir := filepath.Dir(configPath)
+	buildContext := "."
+	if cfg.Build.Context != "" {
+		buildContext = cfg.Build.Context
+	}
+	args := []string{"build", "-t", image, "-f", filepath.Join(dir, cfg.Build.Dockerfile)}
+	for _, k := range sortedKeys(cfg.Build.Args) {
+		args = append(args, "--build-arg", k+"="+cfg.Build.Args[k])
+	}
+	return append(args, filepath.Join(dir, buildContext))
+}
+
+// runArgs returns the arguments starting a container that stays up for
+// commands to be run in it.
+func runArgs(cfg *Config, root, image string) []string {
+	args := []string{
+		"run", "-d", "--rm", "--init",
+		"--label", "dev.shelley.workspace=" + root,
+		"-v", root + ":" + root,
+		"-w", root,
+	}
+	if cfg.ContainerUser != "" {
+		args = append(args, "-u", cfg.ContainerUser)
+	}
+	for _, k := range sortedKeys(cfg.ContainerEnv) {
+		args = append(args, "-e", k+"="+cfg.ContainerEnv[k])
+	}
+	args = append(args, cfg.RunArgs...)
+	return append(args, "--entrypoint", "/bin/sh", image, "-c", "while sleep 1000; do :; done")
+}
+
+// imageName names the image built for a configuration.
+func imageName(configPath string) string {
+	sum := sha256.Sum256([]byte(configPath))
+	return "shelley-devcontainer-" + hex.EncodeToString(sum[:6])
+}
+
+func workspaceRoot(dir string) string {
+	if state := gitstate.GetGitState(dir); state.IsRepo && state.Worktree != "" {
+		return state.Worktree
+	}
+	return dir
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
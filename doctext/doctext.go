@@ -0,0 +1,153 @@
+// Package doctext extracts the text of PDF and Word (.docx) documents so that
+// they can be given to a model. It needs no external tools; the text of
+// scanned or unusually encoded documents may be missing.
+package doctext
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Document is the text of a document.
+type Document struct {
+	// Text is the text of the document, with a "--- Page N ---" line before
+	// each page when the page breaks are known.
+	Text string
+	// Pages is the number of pages, or 0 if unknown.
+	Pages int
+}
+
+// MediaTypes maps the supported file extensions to their media types.
+var MediaTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+}
+
+// Supported reports whether the text of the file at path can be extracted,
+// judging by its extension.
+func Supported(path string) bool {
+	_, ok := MediaTypes[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Extract extracts the text of the document at path.
+func Extract(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".pdf":
+		return ExtractPDF(data)
+	case ".docx":
+		return ExtractDOCX(data)
+	default:
+		return nil, fmt.Errorf("unsupported document type %q", ext)
+	}
+}
+
+// newDocument joins the text of pages.
+func newDocument(pages []string) *Document {
+	if len(pages) == 1 {
+		return &Document{Text: pages[0], Pages: 1}
+	}
+	var b strings.Builder
+	for i, p := range pages {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "--- Page %d ---\n", i+1)
+		b.WriteString(p)
+	}
+	return &Document{Text: b.String(), Pages: len(pages)}
+}
+
+// ExtractDOCX extracts the text of a Word document. Pages are split at the
+// page breaks Word last rendered, so the page count is approximate.
+func ExtractDOCX(data []byte) (*Document, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a docx file: %w", err)
+	}
+	var body io.ReadCloser
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			if body, err = f.Open(); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if body == nil {
+		return nil, errors.New("not a docx file: no word/document.xml")
+	}
+	defer body.Close()
+
+	var pages []string
+	var page strings.Builder
+	breakPage := func() {
+		pages = append(pages, strings.TrimSpace(page.String()))
+		page.Reset()
+	}
+	dec := xml.NewDecoder(io.LimitReader(body, maxStreamSize))
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading docx: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				page.WriteByte('\t')
+			case "br", "cr":
+				if attr(t, "type") == "page" {
+					breakPage()
+				} else {
+					page.WriteByte('\n')
+				}
+			case "lastRenderedPageBreak":
+				if page.Len() > 0 {
+					breakPage()
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				page.WriteByte('\n')
+			case "tc":
+				page.WriteByte('\t')
+			}
+		case xml.CharData:
+			if inText {
+				page.Write(t)
+			}
+		}
+	}
+	breakPage()
+	return newDocument(pages), nil
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
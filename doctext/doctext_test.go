@@ -0,0 +1,145 @@
+package doctext
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildPDF builds a PDF with a page for each content stream. The streams are
+// compressed, and font F2 has a ToUnicode map.
+func buildPDF(contents ...string) []byte {
+	var objs []string
+	add := func(s string) int {
+		objs = append(objs, s)
+		return len(objs)
+	}
+	stream := func(dict string, data []byte) string {
+		return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+	}
+	deflate := func(s string) []byte {
+		var b bytes.Buffer
+		w := zlib.NewWriter(&b)
+		w.Write([]byte(s))
+		w.Close()
+		return b.Bytes()
+	}
+
+	catalog := add("")
+	pagesObj := add("")
+	cmap := add(stream("", []byte("1 begincodespacerange <0000> <FFFF> endcodespacerange\n"+
+		"2 beginbfchar <0001> <0048> <0002> <0069> endbfchar\n"+
+		"1 beginbfrange <0010> <0012> <0061> endbfrange")))
+	f1 := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	f2 := add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /ToUnicode %d 0 R >>", cmap))
+	var kids []string
+	for _, c := range contents {
+		content := add(stream("/Filter /FlateDecode", deflate(c)))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /Contents %d 0 R >>", pagesObj, content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objs[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj)
+	objs[pagesObj-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> >>",
+		strings.Join(kids, " "), len(kids), f1, f2)
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n")
+	for i, o := range objs {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	data := buildPDF(
+		"BT /F1 12 Tf 72 720 Td (Hello, \\(PDF\\) world) Tj 0 -14 Td [(Sec)20(ond)-300(line)] TJ ET",
+		"BT /F2 12 Tf <00010002> Tj 0 -14 Td <001000110012> Tj ET",
+	)
+	doc, err := ExtractPDF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- Page 1 ---\nHello, (PDF) world\nSecond line\n\n--- Page 2 ---\nHi\nabc"
+	if doc.Text != want || doc.Pages != 2 {
+		t.Errorf("ExtractPDF = %d pages:\n%s\nwant 2 pages:\n%s", doc.Pages, doc.Text, want)
+	}
+
+	if _, err := ExtractPDF([]byte("plain text")); err == nil {
+		t.Error("ExtractPDF of a non-PDF succeeded")
+	}
+	if _, err := ExtractPDF([]byte("%PDF-1.7\n1 0 obj << /Encrypt 2 0 R >> endobj")); err == nil {
+		t.Error("ExtractPDF of an encrypted PDF succeeded")
+	}
+}
+
+// objStmPDF is a PDF whose object stream has the given header and objects.
+func objStmPDF(header, objects string) []byte {
+	data := header + objects
+	return []byte(fmt.Sprintf("%%PDF-1.7\n1 0 obj\n<< /Type /ObjStm /N 1 /First %d /Length %d >>\nstream\n%s\nendstream\nendobj\n%%%%EOF\n",
+		len(header), len(data), data))
+}
+
+func TestExtractPDFMalformed(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"negative offset":      objStmPDF("2 -5 ", "<< >>"),
+		"negative first":       []byte("%PDF-1.7\n1 0 obj\n<< /Type /ObjStm /N 1 /First -9 /Length 6 >>\nstream\n2 0 <<\nendstream\nendobj\n"),
+		"offset past the end":  objStmPDF("2 99 ", "<< >>"),
+		"truncated dictionary": objStmPDF("2 0 ", "<< /Type"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Errors are fine; panics are not
+			ExtractPDF(data)
+		})
+	}
+
+	// A stream that inflates to more than the limit is refused
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(make([]byte, maxStreamSize+1))
+	w.Close()
+	if _, err := inflate(b.Bytes()); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("expected a size error inflating a large stream, got %v", err)
+	}
+}
+
+func FuzzExtractPDF(f *testing.F) {
+	f.Add(buildPDF("BT /F1 12 Tf (Hello) Tj ET", "BT /F2 12 Tf <0001> Tj ET"))
+	f.Add(objStmPDF("2 0 3 6 ", "<< >> [1 2]"))
+	f.Add(objStmPDF("2 -5 ", "<< >>"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ExtractPDF(data)
+	})
+}
+
+func TestExtractDOCX(t *testing.T) {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Title</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Hello </w:t></w:r><w:r><w:t>&amp; welcome</w:t><w:tab/><w:t>x</w:t></w:r></w:p>
+<w:p><w:r><w:br w:type="page"/><w:t>Page two</w:t></w:r></w:p>
+</w:body></w:document>`))
+	zw.Close()
+
+	path := filepath.Join(t.TempDir(), "spec.docx")
+	os.WriteFile(path, b.Bytes(), 0o644)
+	if !Supported(path) || Supported("notes.txt") {
+		t.Error("Supported is wrong")
+	}
+	doc, err := Extract(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- Page 1 ---\nTitle\nHello & welcome\tx\n\n--- Page 2 ---\nPage two"
+	if doc.Text != want || doc.Pages != 2 {
+		t.Errorf("Extract = %d pages:\n%q\nwant 2 pages:\n%q", doc.Pages, doc.Text, want)
+	}
+}
@@ -0,0 +1,782 @@
+package doctext
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// This is a small PDF reader, enough to get the text of most documents. It
+// finds objects by scanning for "N G obj" rather than reading the
+// cross-reference table, which also copes with slightly broken files.
+
+type (
+	pdfName    string
+	pdfKeyword string // an operator in a content stream, or an unknown token
+	pdfDict    map[pdfName]any
+	pdfRef     struct{ num, gen int }
+)
+
+type pdfStream struct {
+	dict pdfDict
+	data []byte
+}
+
+var pdfObjRegexp = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+type pdfDoc struct {
+	objs map[int]any
+}
+
+// ExtractPDF extracts the text of a PDF document.
+func ExtractPDF(data []byte) (*Document, error) {
+	doc, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return nil, errors.New("no pages found in PDF")
+	}
+	texts := make([]string, len(pages))
+	for i, p := range pages {
+		texts[i] = doc.pageText(p)
+	}
+	return newDocument(texts), nil
+}
+
+func parsePDF(data []byte) (*pdfDoc, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF")) {
+		return nil, errors.New("not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, errors.New("encrypted PDFs are not supported")
+	}
+
+	doc := &pdfDoc{objs: make(map[int]any)}
+	streamEnd := 0
+	for _, m := range pdfObjRegexp.FindAllSubmatchIndex(data, -1) {
+		if m[0] < streamEnd {
+			continue // a match inside stream data
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &pdfLexer{b: data, pos: m[1]}
+		v, err := l.value()
+		if err != nil {
+			continue
+		}
+		if d, ok := v.(pdfDict); ok {
+			l.skipSpace()
+			if bytes.HasPrefix(data[l.pos:], []byte("stream")) {
+				s := readStream(data, l.pos+len("stream"), d)
+				streamEnd = l.pos + len(s.data)
+				v = s
+			}
+		}
+		// Later definitions replace earlier ones, as in incremental updates
+		doc.objs[num] = v
+	}
+
+	var objStms []*pdfStream
+	for _, v := range doc.objs {
+		if s, ok := v.(*pdfStream); ok && s.dict["Type"] == pdfName("ObjStm") {
+			objStms = append(objStms, s)
+		}
+	}
+	for _, s := range objStms {
+		doc.expandObjStm(s)
+	}
+	return doc, nil
+}
+
+// readStream reads the data of a stream whose "stream" keyword ends at start.
+func readStream(data []byte, start int, d pdfDict) *pdfStream {
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+	if n, ok := d["Length"].(int); ok && n >= 0 && start+n <= len(data) &&
+		bytes.HasPrefix(bytes.TrimLeft(data[start+n:], " \t\r\n"), []byte("endstream")) {
+		return &pdfStream{dict: d, data: data[start : start+n]}
+	}
+	end := bytes.Index(data[start:], []byte("endstream"))
+	if end < 0 {
+		end = len(data) - start
+	}
+	return &pdfStream{dict: d, data: bytes.TrimRight(data[start:start+end], "\r\n")}
+}
+
+// expandObjStm adds the objects of an object stream.
+func (d *pdfDoc) expandObjStm(s *pdfStream) {
+	data, err := d.decode(s)
+	if err != nil {
+		return
+	}
+	n, _ := d.resolve(s.dict["N"]).(int)
+	first, _ := d.resolve(s.dict["First"]).(int)
+	header := &pdfLexer{b: data}
+	for range n {
+		num, err1 := header.value()
+		off, err2 := header.value()
+		if err1 != nil || err2 != nil {
+			return
+		}
+		objNum, ok1 := num.(int)
+		objOff, ok2 := off.(int)
+		if !ok1 || !ok2 || first < 0 || objOff < 0 || first+objOff >= len(data) {
+			return
+		}
+		if _, ok := d.objs[objNum]; ok {
+			continue
+		}
+		l := &pdfLexer{b: data, pos: first + objOff}
+		if v, err := l.value(); err == nil {
+			d.objs[objNum] = v
+		}
+	}
+}
+
+// resolve follows references.
+func (d *pdfDoc) resolve(v any) any {
+	for range 32 {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = d.objs[ref.num]
+	}
+	return nil
+}
+
+func (d *pdfDoc) dict(v any) pdfDict {
+	switch v := d.resolve(v).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// decode returns the decoded data of a stream.
+func (d *pdfDoc) decode(s *pdfStream) ([]byte, error) {
+	var filters []any
+	switch f := d.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{f}
+	case []any:
+		filters = f
+	}
+	data := s.data
+	for _, f := range filters {
+		var err error
+		switch name, _ := d.resolve(f).(pdfName); name {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+		case "ASCIIHexDecode", "AHx":
+			data, err = hex.DecodeString(strings.Map(func(r rune) rune {
+				if strings.ContainsRune(" \t\r\n\f>", r) {
+					return -1
+				}
+				return r
+			}, string(data)))
+		case "ASCII85Decode", "A85":
+			data = bytes.TrimSuffix(bytes.TrimSpace(data), []byte("~>"))
+			dst := make([]byte, 4*len(data))
+			var n int
+			n, _, err = ascii85.Decode(dst, data, true)
+			data = dst[:n]
+		default:
+			return nil, fmt.Errorf("unsupported PDF filter %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// maxStreamSize bounds the decompressed size of a stream, so that a small
+// file can't expand to fill the memory.
+const maxStreamSize = 64 << 20
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		// Some writers leave out the zlib header
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxStreamSize+1))
+	if len(out) > maxStreamSize {
+		return nil, fmt.Errorf("PDF stream is larger than %dMB decompressed", maxStreamSize>>20)
+	}
+	if len(out) > 0 && err != nil {
+		// Keep what could be read of truncated streams
+		return out, nil
+	}
+	return out, err
+}
+
+// pdfPage is a page and the resources it inherits.
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages returns the pages of the document in order.
+func (d *pdfDoc) pages() []pdfPage {
+	var catalog pdfDict
+	nums := make([]int, 0, len(d.objs))
+	for num := range d.objs {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if dict, ok := d.objs[num].(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+			catalog = dict
+		}
+	}
+
+	var pages []pdfPage
+	var walk func(node pdfDict, resources pdfDict, depth int)
+	walk = func(node pdfDict, resources pdfDict, depth int) {
+		// The depth limit guards against cycles in broken page trees
+		if node == nil || depth > 64 || len(pages) > 10000 {
+			return
+		}
+		if r := d.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		if node["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: node, resources: resources})
+			return
+		}
+		kids, _ := d.resolve(node["Kids"]).([]any)
+		for _, kid := range kids {
+			walk(d.dict(kid), resources, depth+1)
+		}
+	}
+	if catalog != nil {
+		walk(d.dict(catalog["Pages"]), nil, 0)
+	}
+	if len(pages) == 0 {
+		// Without a usable page tree, take the pages in object order
+		for _, num := range nums {
+			if dict, ok := d.objs[num].(pdfDict); ok && dict["Type"] == pdfName("Page") {
+				pages = append(pages, pdfPage{dict: dict, resources: d.dict(dict["Resources"])})
+			}
+		}
+	}
+	return pages
+}
+
+// pageText extracts the text of a page by interpreting its content stream.
+func (d *pdfDoc) pageText(p pdfPage) string {
+	var content []byte
+	contents := d.resolve(p.dict["Contents"])
+	if arr, ok := contents.([]any); ok {
+		for _, c := range arr {
+			if s, ok := d.resolve(c).(*pdfStream); ok {
+				if data, err := d.decode(s); err == nil {
+					content = append(append(content, data...), '\n')
+				}
+			}
+		}
+	} else if s, ok := contents.(*pdfStream); ok {
+		content, _ = d.decode(s)
+	}
+
+	fonts := make(map[pdfName]*pdfFont)
+	fontDicts := d.dict(p.resources["Font"])
+	var font *pdfFont
+	var out strings.Builder
+	write := func(sep byte) {
+		if out.Len() == 0 {
+			return
+		}
+		last := out.String()[out.Len()-1]
+		if last == '\n' || (last == ' ' && sep == ' ') {
+			return
+		}
+		if last == ' ' {
+			// Replace the space with the newline
+			s := out.String()[:out.Len()-1]
+			out.Reset()
+			out.WriteString(s)
+		}
+		out.WriteByte(sep)
+	}
+	show := func(v any) {
+		if s, ok := v.(string); ok {
+			out.WriteString(font.decode(s))
+		}
+	}
+
+	l := &pdfLexer{b: content}
+	var operands []any
+	for {
+		v, err := l.value()
+		if err != nil {
+			break
+		}
+		op, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				name, _ := operands[len(operands)-2].(pdfName)
+				if _, ok := fonts[name]; !ok {
+					fonts[name] = d.font(d.dict(fontDicts[name]))
+				}
+				font = fonts[name]
+			}
+		case "Tj":
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "'", "\"":
+			write('\n')
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				arr, _ := operands[len(operands)-1].([]any)
+				for _, e := range arr {
+					if n, ok := pdfNumber(e); ok && n < -200 {
+						write(' ')
+					}
+					show(e)
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				tx, _ := pdfNumber(operands[len(operands)-2])
+				ty, _ := pdfNumber(operands[len(operands)-1])
+				if ty != 0 {
+					write('\n')
+				} else if tx != 0 {
+					write(' ')
+				}
+			}
+		case "T*":
+			write('\n')
+		case "Tm":
+			write('\n')
+		case "ET":
+			write(' ')
+		case "ID":
+			l.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+	return strings.TrimSpace(out.String())
+}
+
+func pdfNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// pdfFont maps the character codes of a font to text.
+type pdfFont struct {
+	cmap *pdfCMap
+	// twoByte is set for composite fonts, whose codes can't be read without
+	// a ToUnicode map.
+	twoByte bool
+}
+
+func (d *pdfDoc) font(dict pdfDict) *pdfFont {
+	f := &pdfFont{twoByte: dict["Subtype"] == pdfName("Type0")}
+	if s, ok := d.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := d.decode(s); err == nil {
+			f.cmap = parseCMap(data)
+		}
+	}
+	return f
+}
+
+func (f *pdfFont) decode(s string) string {
+	if f != nil && f.cmap != nil && len(f.cmap.m) > 0 {
+		return f.cmap.decode(s)
+	}
+	if f != nil && f.twoByte {
+		return ""
+	}
+	// Simple fonts mostly use a Latin-1 compatible encoding
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= 0x20 || c == '\t' {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+// pdfCMap is a ToUnicode map from character codes to text.
+type pdfCMap struct {
+	width int // bytes per code
+	m     map[uint32]string
+}
+
+func parseCMap(data []byte) *pdfCMap {
+	c := &pdfCMap{m: make(map[uint32]string)}
+	l := &pdfLexer{b: data}
+	var operands []any
+	for {
+		v, err := l.value()
+		if err != nil {
+			break
+		}
+		op, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+		switch op {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].(string); ok {
+					c.width = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(string)
+				dst, ok2 := operands[i+1].(string)
+				if ok1 && ok2 {
+					c.setWidth(len(src))
+					c.m[codeOf(src)] = utf16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(string)
+				hi, ok2 := operands[i+1].(string)
+				if !ok1 || !ok2 || codeOf(hi) < codeOf(lo) || codeOf(hi)-codeOf(lo) > 0xffff {
+					continue
+				}
+				c.setWidth(len(lo))
+				switch dst := operands[i+2].(type) {
+				case string:
+					r := []rune(utf16BE(dst))
+					for code := codeOf(lo); code <= codeOf(hi) && len(r) > 0; code++ {
+						c.m[code] = string(r)
+						r[len(r)-1]++
+					}
+				case []any:
+					for j, e := range dst {
+						if s, ok := e.(string); ok {
+							c.m[codeOf(lo)+uint32(j)] = utf16BE(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return c
+}
+
+func (c *pdfCMap) setWidth(n int) {
+	if c.width == 0 {
+		c.width = n
+	}
+}
+
+func (c *pdfCMap) decode(s string) string {
+	w := max(c.width, 1)
+	var b strings.Builder
+	for i := 0; i+w <= len(s); i += w {
+		if t, ok := c.m[codeOf(s[i:i+w])]; ok {
+			b.WriteString(t)
+		}
+	}
+	return b.String()
+}
+
+func codeOf(s string) uint32 {
+	var code uint32
+	for i := 0; i < len(s) && i < 4; i++ {
+		code = code<<8 | uint32(s[i])
+	}
+	return code
+}
+
+func utf16BE(s string) string {
+	u := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(u))
+}
+
+// pdfLexer reads PDF values. Strings are returned as Go strings of their
+// bytes, names as pdfName and anything else unquoted as pdfKeyword.
+type pdfLexer struct {
+	b   []byte
+	pos int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		if c == '%' {
+			for l.pos < len(l.b) && l.b[l.pos] != '\n' && l.b[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+func (l *pdfLexer) token() string {
+	start := l.pos
+	for l.pos < len(l.b) && !isPDFSpace(l.b[l.pos]) && !isPDFDelim(l.b[l.pos]) {
+		l.pos++
+	}
+	return string(l.b[start:l.pos])
+}
+
+func (l *pdfLexer) value() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.b) {
+		return nil, io.EOF
+	}
+	switch c := l.b[l.pos]; {
+	case c == '/':
+		l.pos++
+		return pdfName(decodeName(l.token())), nil
+	case c == '(':
+		return l.literalString(), nil
+	case c == '<':
+		if l.pos+1 < len(l.b) && l.b[l.pos+1] == '<' {
+			l.pos += 2
+			return l.dict()
+		}
+		return l.hexString(), nil
+	case c == '[':
+		l.pos++
+		arr := []any{}
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.b) {
+				return arr, io.ErrUnexpectedEOF
+			}
+			if l.b[l.pos] == ']' {
+				l.pos++
+				return arr, nil
+			}
+			v, err := l.value()
+			if err != nil {
+				return arr, err
+			}
+			arr = append(arr, v)
+		}
+	case isPDFDelim(c):
+		// A stray delimiter, e.g. the end of an unbalanced dictionary
+		l.pos++
+		return pdfKeyword(c), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return l.number(), nil
+	default:
+		switch tok := l.token(); tok {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			return pdfKeyword(tok), nil
+		}
+	}
+}
+
+// number reads a number, or a reference such as "12 0 R".
+func (l *pdfLexer) number() any {
+	tok := l.token()
+	if strings.ContainsRune(tok, '.') {
+		f, _ := strconv.ParseFloat(tok, 64)
+		return f
+	}
+	n, err := strconv.Atoi(tok)
+	if err != nil {
+		f, _ := strconv.ParseFloat(tok, 64)
+		return f
+	}
+	save := l.pos
+	l.skipSpace()
+	if gen, err := strconv.Atoi(l.token()); err == nil {
+		l.skipSpace()
+		if l.pos < len(l.b) && l.b[l.pos] == 'R' && (l.pos+1 == len(l.b) || isPDFSpace(l.b[l.pos+1]) || isPDFDelim(l.b[l.pos+1])) {
+			l.pos++
+			return pdfRef{num: n, gen: gen}
+		}
+	}
+	l.pos = save
+	return n
+}
+
+func (l *pdfLexer) dict() (pdfDict, error) {
+	d := make(pdfDict)
+	for {
+		l.skipSpace()
+		if l.pos+1 < len(l.b) && l.b[l.pos] == '>' && l.b[l.pos+1] == '>' {
+			l.pos += 2
+			return d, nil
+		}
+		key, err := l.value()
+		if err != nil {
+			return d, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			continue
+		}
+		v, err := l.value()
+		if err != nil {
+			return d, err
+		}
+		d[name] = v
+	}
+}
+
+func (l *pdfLexer) literalString() string {
+	l.pos++ // (
+	var b []byte
+	depth := 1
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return string(b)
+			}
+		case '\\':
+			if l.pos >= len(l.b) {
+				return string(b)
+			}
+			e := l.b[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// Line continuation
+				if l.pos < len(l.b) && l.b[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.b) && l.b[l.pos] >= '0' && l.b[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.b[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+func (l *pdfLexer) hexString() string {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.b) && l.b[l.pos] != '>' {
+		if c := l.b[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b, _ := hex.DecodeString(string(digits))
+	return string(b)
+}
+
+// skipInlineImage skips the data of an inline image, after its ID operator.
+func (l *pdfLexer) skipInlineImage() {
+	for i := l.pos; i+1 < len(l.b); i++ {
+		if l.b[i] == 'E' && l.b[i+1] == 'I' && i > 0 && isPDFSpace(l.b[i-1]) &&
+			(i+2 == len(l.b) || isPDFSpace(l.b[i+2])) {
+			l.pos = i + 2
+			return
+		}
+	}
+	l.pos = len(l.b)
+}
+
+// decodeName decodes the #xx escapes of a name.
+func decodeName(s string) string {
+	if !strings.Contains(s, "#") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,303 @@
+// Package email sends notification emails over SMTP, rendered from
+// templates for each kind of event.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Event is a kind of notification.
+type Event string
+
+const (
+	// EventApprovalRequest is sent when a conversation waits for the user,
+	// such as to approve a plan.
+	EventApprovalRequest Event = "approval_request"
+	// EventTaskCompleted is sent when a task or turn finishes or fails.
+	EventTaskCompleted Event = "task_completed"
+	// EventBudgetAlert is sent when spending crosses a budget threshold.
+	EventBudgetAlert Event = "budget_alert"
+)
+
+// Template is the subject and body of the emails for an event, in
+// text/template syntax with Data as the dot.
+type Template struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// DefaultTemplates are the templates of events that have none configured.
+var DefaultTemplates = map[Event]Template{
+	EventApprovalRequest: {
+		Subject: "[Shelley] {{.Title}} needs your approval",
+		Body: `{{.Title}} is waiting for you: {{.Reason}}.
+{{if .Plan}}
+Plan:
+
+{{.Plan}}
+{{end}}{{if .Summary}}
+Last message:
+
+{{.Summary}}
+{{end}}{{if .URL}}
+Open the conversation: {{.URL}}
+{{end}}`,
+	},
+	EventTaskCompleted: {
+		Subject: "[Shelley] {{.Title}} {{if .Failed}}failed{{else}}finished{{end}}",
+		Body: `{{.Title}} {{if .Failed}}failed{{else}}finished{{end}}{{if .Duration}} after {{.Duration}}{{end}}.
+{{if .Summary}}
+{{.Summary}}
+{{end}}{{if .URL}}
+Open the conversation: {{.URL}}
+{{end}}`,
+	},
+	EventBudgetAlert: {
+		Subject: "[Shelley] Budget alert: {{.Reason}}",
+		Body: `{{.Reason}}.
+{{if .Title}}
+Conversation: {{.Title}}
+{{end}}{{if .URL}}
+Open the conversation: {{.URL}}
+{{end}}`,
+	},
+}
+
+// Data is what templates are rendered from.
+type Data struct {
+	ConversationID string
+	// Title is the conversation's slug, or "Shelley".
+	Title string
+	// URL links to the conversation, when Config.BaseURL is set.
+	URL   string
+	Model string
+	// Summary is the agent's last message.
+	Summary string
+	// Reason says why the event was sent, such as what the user must approve
+	// or which budget was exceeded.
+	Reason string
+	// Plan is the plan awaiting approval.
+	Plan     string
+	Failed   bool
+	Duration time.Duration
+}
+
+// Config configures the SMTP server and recipients of notifications.
+type Config struct {
+	Host string `json:"host"`
+	// Port defaults to 465 with TLS "tls", otherwise 587.
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// TLS is "starttls" (the default), "tls" for implicit TLS, or "none".
+	TLS  string `json:"tls"`
+	From string `json:"from"`
+	// To are the admins' addresses; only admins' conversations send email.
+	To []string `json:"to"`
+	// BaseURL is Shelley's external URL, used to link to conversations.
+	BaseURL string `json:"base_url"`
+	// Templates overrides the default templates of events.
+	Templates map[Event]Template `json:"templates"`
+}
+
+// Notifier sends notification emails.
+type Notifier struct {
+	cfg       Config
+	addr      string
+	from      string   // envelope sender
+	to        []string // envelope recipients
+	subjects  map[Event]*template.Template
+	bodies    map[Event]*template.Template
+	TLSConfig *tls.Config // optional
+}
+
+// NewNotifier creates a Notifier, checking the config and templates.
+func NewNotifier(cfg Config) (*Notifier, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("SMTP host is not configured")
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, errors.New("email from and to addresses are required")
+	}
+	switch cfg.TLS {
+	case "":
+		cfg.TLS = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("unknown SMTP TLS mode %q", cfg.TLS)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.TLS == "tls" {
+			cfg.Port = 465
+		}
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	n := &Notifier{
+		cfg:      cfg,
+		addr:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		subjects: make(map[Event]*template.Template),
+		bodies:   make(map[Event]*template.Template),
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	n.from = from.Address
+	for _, to := range cfg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to address: %w", err)
+		}
+		n.to = append(n.to, addr.Address)
+	}
+	for event, tmpl := range DefaultTemplates {
+		if custom, ok := cfg.Templates[event]; ok {
+			if custom.Subject != "" {
+				tmpl.Subject = custom.Subject
+			}
+			if custom.Body != "" {
+				tmpl.Body = custom.Body
+			}
+		}
+		if n.subjects[event], err = template.New(string(event)).Parse(tmpl.Subject); err != nil {
+			return nil, fmt.Errorf("invalid %s subject template: %w", event, err)
+		}
+		if n.bodies[event], err = template.New(string(event)).Parse(tmpl.Body); err != nil {
+			return nil, fmt.Errorf("invalid %s body template: %w", event, err)
+		}
+	}
+	return n, nil
+}
+
+// ConversationURL returns the link to a conversation, or "" without a base URL.
+func (n *Notifier) ConversationURL(slug string) string {
+	if n.cfg.BaseURL == "" || slug == "" {
+		return ""
+	}
+	return n.cfg.BaseURL + "/c/" + slug
+}
+
+// Render renders the subject and body of an event's email.
+func (n *Notifier) Render(event Event, data Data) (subject, body string, err error) {
+	subjectTmpl, ok := n.subjects[event]
+	if !ok {
+		return "", "", fmt.Errorf("unknown email event %q", event)
+	}
+	var b strings.Builder
+	if err := subjectTmpl.Execute(&b, data); err != nil {
+		return "", "", err
+	}
+	// Headers can't hold line breaks
+	subject = strings.Join(strings.Fields(b.String()), " ")
+	b.Reset()
+	if err := n.bodies[event].Execute(&b, data); err != nil {
+		return "", "", err
+	}
+	return subject, b.String(), nil
+}
+
+// Notify renders and sends the email for an event to the configured recipients.
+func (n *Notifier) Notify(ctx context.Context, event Event, data Data) error {
+	subject, body, err := n.Render(event, data)
+	if err != nil {
+		return err
+	}
+	return n.send(ctx, n.message(subject, body))
+}
+
+// message formats an email.
+func (n *Notifier) message(subject, body string) []byte {
+	var b bytes.Buffer
+	id := make([]byte, 12)
+	rand.Read(id)
+	fmt.Fprintf(&b, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), n.cfg.Host)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(&b)
+	w.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	w.Close()
+	return b.Bytes()
+}
+
+// send delivers a message over SMTP.
+func (n *Notifier) send(ctx context.Context, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	tlsConfig := n.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: n.cfg.Host}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return fmt.Errorf("connecting to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if n.cfg.TLS == "tls" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connecting to SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if n.cfg.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starting TLS: %w", err)
+		}
+	}
+	if n.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
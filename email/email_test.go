@@ -0,0 +1,143 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// smtpServer accepts one message over plain SMTP and returns its recipients
+// and data.
+func smtpServer(t *testing.T) (addr string, received chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received = make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP")
+		var got []string
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "MAIL", "RCPT":
+				got = append(got, line)
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 Go ahead")
+				data, _ := tp.ReadDotBytes()
+				got = append(got, string(data))
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				received <- got
+				return
+			default:
+				tp.PrintfLine("502 Unknown command")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestNotify(t *testing.T) {
+	addr, received := smtpServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+	n, err := NewNotifier(Config{
+		Host:    host,
+		Port:    portNum,
+		TLS:     "none",
+		From:    "Shelley <shelley@example.com>",
+		To:      []string{"ops@example.com"},
+		BaseURL: "https://shelley.example.com/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Notify(context.Background(), EventApprovalRequest, Data{
+		Title:  "fix-login",
+		URL:    n.ConversationURL("fix-login"),
+		Reason: "a plan is ready for your approval",
+		Plan:   "1. Reproduce\n2. Fix the session check",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := <-received
+	if len(got) != 3 || got[0] != "MAIL FROM:<shelley@example.com>" || got[1] != "RCPT TO:<ops@example.com>" {
+		t.Fatalf("SMTP session = %q", got)
+	}
+	header, body, _ := strings.Cut(strings.ReplaceAll(got[2], "\r\n", "\n"), "\n\n")
+	if !strings.Contains(header, "Subject: [Shelley] fix-login needs your approval\n") {
+		t.Errorf("header = %s", header)
+	}
+	text, _ := io.ReadAll(quotedprintable.NewReader(bufio.NewReader(strings.NewReader(body))))
+	for _, want := range []string{
+		"fix-login is waiting for you: a plan is ready for your approval.",
+		"2. Fix the session check",
+		"Open the conversation: https://shelley.example.com/c/fix-login",
+	} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("body missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	n, err := NewNotifier(Config{
+		Host: "smtp.example.com",
+		From: "shelley@example.com",
+		To:   []string{"ops@example.com"},
+		Templates: map[Event]Template{
+			EventBudgetAlert: {Subject: "Spent {{.Reason}}\non {{.Title}}"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, body, err := n.Render(EventBudgetAlert, Data{Title: "nightly", Reason: "$10 of $10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Spent $10 of $10 on nightly" {
+		t.Errorf("subject = %q", subject)
+	}
+	if !strings.Contains(body, "Conversation: nightly") {
+		t.Errorf("the default body was not kept: %q", body)
+	}
+	subject, _, _ = n.Render(EventTaskCompleted, Data{Title: "nightly", Failed: true})
+	if subject != "[Shelley] nightly failed" {
+		t.Errorf("subject = %q", subject)
+	}
+
+	if _, err := NewNotifier(Config{Host: "h", From: "a@b", To: []string{"c@d"}, TLS: "ssl3"}); err == nil {
+		t.Error("NewNotifier with an unknown TLS mode succeeded")
+	}
+	if _, err := NewNotifier(Config{Host: "h", From: "a@b", To: []string{"c@d"}, Templates: map[Event]Template{
+		EventApprovalRequest: {Body: "{{.Missing"},
+	}}); err == nil {
+		t.Error("NewNotifier with an invalid template succeeded")
+	}
+}
@@ -0,0 +1,297 @@
+// Package eval runs evaluation tasks against models, to detect regressions
+// when changing prompts, tools or providers. Each task sends a prompt to the
+// agent in a copy of a fixture directory and then runs a check command there.
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+// maxCheckOutput bounds the check output kept in a result.
+const maxCheckOutput = 4096
+
+// Config is what Run needs to run the agent.
+type Config struct {
+	// Service returns the LLM service of a model
+	Service func(model string) (llm.Service, error)
+	// Tools returns the tools of the agent working in dir, and a function
+	// releasing them
+	Tools func(ctx context.Context, model, dir string) ([]*llm.Tool, func())
+	// SystemPrompt returns the system prompt of the agent working in dir
+	SystemPrompt func(dir string) (string, error)
+	Limits       loop.Limits
+	// KeepWorkDirs keeps the working directories of the runs for inspection
+	KeepWorkDirs bool
+	Logger       *slog.Logger
+}
+
+// Result is the outcome of one task with one model.
+type Result struct {
+	Task   string `json:"task"`
+	Model  string `json:"model"`
+	Passed bool   `json:"passed"`
+	// Error is why the task could not be run or its turn failed
+	Error string `json:"error,omitempty"`
+	// CheckOutput is the end of the check command's output
+	CheckOutput string    `json:"check_output,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+	Usage       llm.Usage `json:"usage"`
+	// WorkDir is the working directory, if kept
+	WorkDir string `json:"work_dir,omitempty"`
+}
+
+// ModelSummary totals the results of a model.
+type ModelSummary struct {
+	Model  string    `json:"model"`
+	Passed int       `json:"passed"`
+	Total  int       `json:"total"`
+	Usage  llm.Usage `json:"usage"`
+}
+
+// Report is the outcome of running tasks with models.
+type Report struct {
+	Results []Result       `json:"results"`
+	Models  []ModelSummary `json:"models"`
+}
+
+// Passed reports whether every task passed with every model.
+func (r Report) Passed() bool {
+	for _, m := range r.Models {
+		if m.Passed != m.Total {
+			return false
+		}
+	}
+	return true
+}
+
+// Run runs each task with each model, one at a time.
+func Run(ctx context.Context, cfg Config, tasks []Task, models []string) Report {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	var report Report
+	for _, model := range models {
+		summary := ModelSummary{Model: model}
+		for _, task := range tasks {
+			if ctx.Err() != nil {
+				break
+			}
+			cfg.Logger.Info("Running eval task", "task", task.Name, "model", model)
+			result := runTask(ctx, cfg, task, model)
+			cfg.Logger.Info("Finished eval task", "task", task.Name, "model", model, "passed", result.Passed, "error", result.Error)
+			report.Results = append(report.Results, result)
+			summary.Total++
+			if result.Passed {
+				summary.Passed++
+			}
+			summary.Usage.Add(result.Usage)
+		}
+		report.Models = append(report.Models, summary)
+	}
+	return report
+}
+
+func runTask(ctx context.Context, cfg Config, task Task, model string) Result {
+	result := Result{Task: task.Name, Model: model}
+	start := time.Now()
+	defer func() { result.DurationMs = time.Since(start).Milliseconds() }()
+
+	dir, err := os.MkdirTemp("", "shelley-eval-")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if cfg.KeepWorkDirs {
+		result.WorkDir = dir
+	} else {
+		defer os.RemoveAll(dir)
+	}
+	if err := prepareWorkDir(ctx, task.Fixture, dir); err != nil {
+		result.Error = fmt.Sprintf("failed to prepare fixture: %v", err)
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, task.Timeout)
+	defer cancel()
+	usage, err := runAgent(ctx, cfg, task, model, dir)
+	result.Usage = usage
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	check := exec.CommandContext(ctx, "sh", "-c", task.Check)
+	check.Dir = dir
+	output, err := check.CombinedOutput()
+	if len(output) > maxCheckOutput {
+		output = output[len(output)-maxCheckOutput:]
+	}
+	result.CheckOutput = string(output)
+	if err != nil && ctx.Err() != nil {
+		result.Error = "timed out running the check"
+		return result
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		result.Error = fmt.Sprintf("failed to run the check: %v", err)
+		return result
+	}
+	result.Passed = err == nil
+	return result
+}
+
+// runAgent runs one turn of the agent on the task's prompt in dir.
+func runAgent(ctx context.Context, cfg Config, task Task, model, dir string) (llm.Usage, error) {
+	service, err := cfg.Service(model)
+	if err != nil {
+		return llm.Usage{}, err
+	}
+	var system []llm.SystemContent
+	if cfg.SystemPrompt != nil {
+		prompt, err := cfg.SystemPrompt(dir)
+		if err != nil {
+			return llm.Usage{}, fmt.Errorf("failed to generate system prompt: %w", err)
+		}
+		system = []llm.SystemContent{{Type: "text", Text: prompt}}
+	}
+	var tools []*llm.Tool
+	if cfg.Tools != nil {
+		var cleanup func()
+		tools, cleanup = cfg.Tools(ctx, model, dir)
+		defer cleanup()
+	}
+
+	// The turn's error messages are recorded rather than returned
+	var turnErr string
+	agent := loop.NewLoop(loop.Config{
+		LLM:        service,
+		Tools:      tools,
+		System:     system,
+		WorkingDir: dir,
+		Limits:     cfg.Limits,
+		Logger:     cfg.Logger,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			if message.ErrorType != llm.ErrorTypeNone {
+				turnErr = messageText(message)
+			}
+			return nil
+		},
+	})
+	agent.QueueUserMessage(llm.UserStringMessage(task.Prompt))
+	err = agent.ProcessOneTurn(ctx)
+	usage := agent.GetUsage()
+	switch {
+	case ctx.Err() != nil:
+		return usage, fmt.Errorf("timed out after %s", task.Timeout)
+	case err != nil:
+		return usage, err
+	case turnErr != "":
+		return usage, errors.New(turnErr)
+	}
+	return usage, nil
+}
+
+func messageText(message llm.Message) string {
+	var texts []string
+	for _, content := range message.Content {
+		if content.Type == llm.ContentTypeText && content.Text != "" {
+			texts = append(texts, content.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// prepareWorkDir copies the fixture to dir and commits it to a new git
+// repository there, unless it is one already, so that the agent and the
+// check can look at what changed.
+func prepareWorkDir(ctx context.Context, fixture, dir string) error {
+	if fixture != "" {
+		if err := os.CopyFS(dir, os.DirFS(fixture)); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return nil
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=Shelley Eval", "-c", "user.email=eval@shelley.invalid", "commit", "-q", "--allow-empty", "-m", "Fixture"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %v: %s", args[0], err, output)
+		}
+	}
+	return nil
+}
+
+// WriteText writes the report as a table of results followed by the totals
+// of each model.
+func (r Report) WriteText(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASK\tMODEL\tRESULT\tTIME\tTOKENS IN\tTOKENS OUT\tCOST")
+	for _, res := range r.Results {
+		status := "pass"
+		switch {
+		case res.Error != "":
+			status = "error"
+		case !res.Passed:
+			status = "fail"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t$%.4f\n", res.Task, res.Model, status,
+			(time.Duration(res.DurationMs) * time.Millisecond).Round(100*time.Millisecond),
+			inputTokens(res.Usage), res.Usage.OutputTokens, res.Usage.CostUSD)
+	}
+	tw.Flush()
+
+	for _, res := range r.Results {
+		if res.Passed {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s with %s:\n", res.Task, res.Model)
+		if res.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", res.Error)
+		}
+		if out := strings.TrimSpace(res.CheckOutput); out != "" {
+			fmt.Fprintf(w, "  check output:\n    %s\n", strings.ReplaceAll(out, "\n", "\n    "))
+		} else if res.Error == "" {
+			fmt.Fprintf(w, "  the check failed without output\n")
+		}
+		if res.WorkDir != "" {
+			fmt.Fprintf(w, "  working directory: %s\n", res.WorkDir)
+		}
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tPASSED\tTOKENS IN\tTOKENS OUT\tCOST")
+	for _, m := range r.Models {
+		fmt.Fprintf(tw, "%s\t%d/%d\t%d\t%d\t$%.4f\n", m.Model, m.Passed, m.Total,
+			inputTokens(m.Usage), m.Usage.OutputTokens, m.Usage.CostUSD)
+	}
+	tw.Flush()
+}
+
+// inputTokens counts the input tokens including those written to or read
+// from the prompt cache.
+func inputTokens(u llm.Usage) uint64 {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
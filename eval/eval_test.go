@@ -0,0 +1,133 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+func TestParseTask(t *testing.T) {
+	task, err := ParseTask(`# A task
+name: "fix it"
+fixture: fixtures/calc  # copied
+timeout: 2m
+prompt: |
+  Fix the bug.
+
+  Then run the tests.
+check: >-
+  go test
+  ./...
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Task{
+		Name:    "fix it",
+		Prompt:  "Fix the bug.\n\nThen run the tests.\n",
+		Fixture: "fixtures/calc",
+		Check:   "go test ./...",
+		Timeout: 2 * time.Minute,
+	}
+	if task != want {
+		t.Errorf("ParseTask = %+v, want %+v", task, want)
+	}
+
+	for _, bad := range []string{
+		"prompt: hi",
+		"check: true",
+		"prompt: hi\ncheck: true\nmodel: x",
+		"prompt: hi\ncheck: true\ntimeout: soon",
+		"prompt: hi\ncheck: true\ntimeout: 0s",
+		"prompt: hi\nprompt: again\ncheck: true",
+		"prompt: hi\n  check: true",
+	} {
+		if _, err := ParseTask(bad); err == nil {
+			t.Errorf("ParseTask(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestLoadTasks(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("prompt: b\ncheck: true\nfixture: repo\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "a.yml"), []byte("name: first\nprompt: a\ncheck: true\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a task"), 0o644)
+
+	tasks, err := LoadTasks([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].Name != "first" || tasks[1].Name != "b" {
+		t.Fatalf("tasks = %+v", tasks)
+	}
+	if tasks[1].Fixture != filepath.Join(dir, "repo") || tasks[0].Timeout != DefaultTimeout {
+		t.Errorf("task b = %+v", tasks[1])
+	}
+	if _, err := LoadTasks([]string{dir, filepath.Join(dir, "a.yml")}); err == nil {
+		t.Error("LoadTasks accepted a task twice")
+	}
+}
+
+func TestRun(t *testing.T) {
+	fixture := t.TempDir()
+	os.WriteFile(filepath.Join(fixture, "input.txt"), []byte("hello\n"), 0o644)
+
+	tasks := []Task{
+		{Name: "copy", Prompt: "bash: cp input.txt output.txt", Fixture: fixture, Check: "cmp input.txt output.txt", Timeout: time.Minute},
+		{Name: "nothing", Prompt: "echo: done", Fixture: fixture, Check: "echo missing output; test -f output.txt", Timeout: time.Minute},
+		{Name: "llm-error", Prompt: "error: overloaded", Check: "true", Timeout: time.Minute},
+	}
+	cfg := Config{
+		Service: func(model string) (llm.Service, error) {
+			if model != "predictable" {
+				return nil, fmt.Errorf("no such model")
+			}
+			return loop.NewPredictableService(), nil
+		},
+		Tools: func(ctx context.Context, model, dir string) ([]*llm.Tool, func()) {
+			ts := claudetool.NewToolSet(ctx, claudetool.ToolSetConfig{WorkingDir: dir, ModelID: model})
+			return ts.Tools(), ts.Cleanup
+		},
+	}
+	report := Run(context.Background(), cfg, tasks, []string{"predictable", "missing"})
+
+	if len(report.Results) != 6 || len(report.Models) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	results := report.Results
+	if !results[0].Passed || results[0].Error != "" || results[0].Usage.OutputTokens == 0 {
+		t.Errorf("copy = %+v", results[0])
+	}
+	if results[1].Passed || results[1].Error != "" || !strings.Contains(results[1].CheckOutput, "missing output") {
+		t.Errorf("nothing = %+v", results[1])
+	}
+	if results[2].Passed || results[2].Error == "" {
+		t.Errorf("llm-error = %+v", results[2])
+	}
+	if results[3].Passed || results[3].Error != "no such model" {
+		t.Errorf("missing model = %+v", results[3])
+	}
+	if m := report.Models[0]; m.Model != "predictable" || m.Passed != 1 || m.Total != 3 {
+		t.Errorf("summary = %+v", m)
+	}
+	if report.Passed() {
+		t.Error("report passed")
+	}
+
+	var out strings.Builder
+	report.WriteText(&out)
+	for _, want := range []string{"copy", "pass", "fail", "error", "1/3", "0/3"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("text report lacks %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultTimeout bounds a task's turn and check when the task sets no timeout.
+const DefaultTimeout = 10 * time.Minute
+
+// Task is an evaluation task, read from a YAML file such as:
+//
+//	name: fix-failing-test
+//	fixture: fixtures/calc
+//	timeout: 5m
+//	prompt: |
+//	  The tests in calc_test.go fail. Fix the bug in calc.go.
+//	check: go test ./...
+type Task struct {
+	Name string `yaml:"name"`
+	// Prompt is the user message sent to the agent
+	Prompt string `yaml:"prompt"`
+	// Fixture is the directory copied to the agent's working directory,
+	// relative to the task file; an empty directory if unset
+	Fixture string `yaml:"fixture"`
+	// Check is the shell command run in the working directory after the
+	// turn; the task passes if it exits with status 0
+	Check   string        `yaml:"check"`
+	Timeout time.Duration `yaml:"timeout"`
+	// Path is the file the task was read from
+	Path string `yaml:"-"`
+}
+
+// LoadTasks reads the tasks of the given files, and of the *.yaml and *.yml
+// files in the given directories, ordered by name.
+func LoadTasks(paths []string) ([]Task, error) {
+	var tasks []Task
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			files = nil
+			for _, pattern := range []string{"*.yaml", "*.yml"} {
+				matches, _ := filepath.Glob(filepath.Join(path, pattern))
+				files = append(files, matches...)
+			}
+			slices.Sort(files)
+		}
+		for _, file := range files {
+			task, err := LoadTask(file)
+			if err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, task)
+		}
+	}
+	for i, task := range tasks {
+		for _, other := range tasks[:i] {
+			if other.Name == task.Name {
+				return nil, fmt.Errorf("%s: task %q is also defined in %s", task.Path, task.Name, other.Path)
+			}
+		}
+	}
+	return tasks, nil
+}
+
+// LoadTask reads a task file. The fixture is resolved relative to it, and
+// the name defaults to the file name.
+func LoadTask(path string) (Task, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Task{}, err
+	}
+	task, err := ParseTask(string(data))
+	if err != nil {
+		return Task{}, fmt.Errorf("%s: %w", path, err)
+	}
+	task.Path = path
+	if task.Name == "" {
+		task.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if task.Fixture != "" && !filepath.IsAbs(task.Fixture) {
+		task.Fixture = filepath.Join(filepath.Dir(path), task.Fixture)
+	}
+	return task, nil
+}
+
+// ParseTask parses the YAML of a task.
+func ParseTask(content string) (Task, error) {
+	task := Task{Timeout: DefaultTimeout}
+	dec := yaml.NewDecoder(strings.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(&task); err != nil && !errors.Is(err, io.EOF) {
+		return Task{}, err
+	}
+	if task.Timeout <= 0 {
+		return Task{}, fmt.Errorf("timeout must be positive")
+	}
+	if strings.TrimSpace(task.Prompt) == "" {
+		return Task{}, fmt.Errorf("prompt is required")
+	}
+	if strings.TrimSpace(task.Check) == "" {
+		return Task{}, fmt.Errorf("check is required")
+	}
+	return task, nil
+}
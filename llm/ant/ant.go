@@ -79,6 +79,11 @@ func (s *Service) MaxImageDimension() int {
 	return 2000
 }
 
+// ValidateSampling reports whether the Anthropic API accepts the sampling parameters.
+func (s *Service) ValidateSampling(sampling llm.Sampling) error {
+	return llm.ValidateSamplingRange(sampling, 1)
+}
+
 // Service provides Claude completions.
 // Fields should not be altered concurrently with calling any method on Service.
 type Service struct {
@@ -89,7 +94,10 @@ type Service struct {
 	MaxTokens int          // defaults to DefaultMaxTokens if zero
 }
 
-var _ llm.Service = (*Service)(nil)
+var (
+	_ llm.Service           = (*Service)(nil)
+	_ llm.SamplingValidator = (*Service)(nil)
+)
 
 type content struct {
 	// https://docs.anthropic.com/en/api/messages
@@ -226,14 +234,34 @@ type request struct {
 	System        []systemContent `json:"system,omitempty"`
 	Tools         []*tool         `json:"tools,omitempty"`
 	ToolChoice    *toolChoice     `json:"tool_choice,omitempty"`
-	Temperature   float64         `json:"temperature,omitempty"`
+	Thinking      *thinking       `json:"thinking,omitempty"`
+	Temperature   *float64        `json:"temperature,omitempty"`
 	TopK          int             `json:"top_k,omitempty"`
-	TopP          float64         `json:"top_p,omitempty"`
+	TopP          *float64        `json:"top_p,omitempty"`
 	StopSequences []string        `json:"stop_sequences,omitempty"`
+	OutputFormat  *outputFormat   `json:"output_format,omitempty"`
 	// Messages comes last since it grows with each request in a conversation
 	Messages []message `json:"messages"`
 }
 
+// https://docs.claude.com/en/docs/build-with-claude/structured-outputs
+type outputFormat struct {
+	Type   string          `json:"type"` // "json_schema"
+	Schema json.RawMessage `json:"schema"`
+}
+
+// structuredOutputsBeta is the beta that enables output_format.
+const structuredOutputsBeta = "structured-outputs-2025-11-13"
+
+// https://docs.anthropic.com/en/docs/build-with-claude/extended-thinking
+type thinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// minThinkingBudget is the smallest budget_tokens the API accepts.
+const minThinkingBudget = 1024
+
 func mapped[Slice ~[]E, E, T any](s Slice, f func(E) T) []T {
 	out := make([]T, len(s))
 	for i, v := range s {
@@ -341,6 +369,21 @@ func fromLLMContent(c llm.Content) content {
 		d.ToolUseID = c.ToolUseID
 		d.ToolError = c.ToolError
 		d.ToolResult = toolResult
+	case llm.ContentTypeImage:
+		// Claude doesn't take images in its own turns
+		note := llm.ImageNote(c)
+		d.Type = "text"
+		d.Text = &note
+	case llm.ContentTypeDocument:
+		if c.MediaType == "application/pdf" && c.Data != "" {
+			// Claude reads PDFs natively, including their figures
+			d.Type = "document"
+			d.Source = json.RawMessage(fmt.Sprintf(`{"type":"base64","media_type":"%s","data":"%s"}`,
+				c.MediaType, c.Data))
+		} else {
+			d.Type = "text"
+			d.Text = &c.Text
+		}
 	}
 
 	return d
@@ -393,7 +436,7 @@ func fromLLMSystem(s llm.SystemContent) systemContent {
 }
 
 func (s *Service) fromLLMRequest(r *llm.Request) *request {
-	return &request{
+	req := &request{
 		Model:      cmp.Or(s.Model, DefaultModel),
 		Messages:   mapped(r.Messages, fromLLMMessage),
 		MaxTokens:  cmp.Or(s.MaxTokens, DefaultMaxTokens),
@@ -401,6 +444,29 @@ func (s *Service) fromLLMRequest(r *llm.Request) *request {
 		Tools:      mapped(r.Tools, fromLLMTool),
 		System:     mapped(r.System, fromLLMSystem),
 	}
+	if r.Sampling != nil {
+		if r.Sampling.MaxTokens > 0 {
+			req.MaxTokens = r.Sampling.MaxTokens
+		}
+		req.Temperature = r.Sampling.Temperature
+		req.TopP = r.Sampling.TopP
+	}
+	// Extended thinking cannot be combined with forced tool use or sampling changes
+	forcedTool := r.ToolChoice != nil && (r.ToolChoice.Type == llm.ToolChoiceTypeAny || r.ToolChoice.Type == llm.ToolChoiceTypeTool)
+	if r.Thinking != nil && r.Thinking.BudgetTokens > 0 && !forcedTool {
+		req.Temperature = nil
+		req.TopP = nil
+		budget := max(r.Thinking.BudgetTokens, minThinkingBudget)
+		req.Thinking = &thinking{Type: "enabled", BudgetTokens: budget}
+		// max_tokens includes the thinking budget, so leave room for the answer
+		if req.MaxTokens <= budget {
+			req.MaxTokens = budget + DefaultMaxTokens
+		}
+	}
+	if r.ResponseFormat != nil {
+		req.OutputFormat = &outputFormat{Type: "json_schema", Schema: r.ResponseFormat.Schema}
+	}
+	return req
 }
 
 func toLLMUsage(u usage) llm.Usage {
@@ -489,6 +555,9 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("X-API-Key", s.APIKey)
 		req.Header.Set("Anthropic-Version", "2023-06-01")
+		if request.OutputFormat != nil {
+			req.Header.Set("Anthropic-Beta", structuredOutputsBeta)
+		}
 
 		resp, err := httpc.Do(req)
 		if err != nil {
@@ -596,6 +596,88 @@ func TestFromLLMRequest(t *testing.T) {
 	}
 }
 
+func TestFromLLMRequestThinking(t *testing.T) {
+	s := &Service{Model: Claude45Sonnet, MaxTokens: 1000}
+	tests := []struct {
+		name          string
+		thinking      *llm.ThinkingConfig
+		toolChoice    *llm.ToolChoice
+		wantBudget    int
+		wantMaxTokens int
+	}{
+		{name: "disabled", wantMaxTokens: 1000},
+		{name: "budget", thinking: &llm.ThinkingConfig{BudgetTokens: 4000}, wantBudget: 4000, wantMaxTokens: 4000 + DefaultMaxTokens},
+		{name: "minimum budget", thinking: &llm.ThinkingConfig{BudgetTokens: 10}, wantBudget: minThinkingBudget, wantMaxTokens: minThinkingBudget + DefaultMaxTokens},
+		{name: "forced tool", thinking: &llm.ThinkingConfig{BudgetTokens: 4000}, toolChoice: &llm.ToolChoice{Type: llm.ToolChoiceTypeTool, Name: "bash"}, wantMaxTokens: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.fromLLMRequest(&llm.Request{Thinking: tt.thinking, ToolChoice: tt.toolChoice})
+			budget := 0
+			if got.Thinking != nil {
+				budget = got.Thinking.BudgetTokens
+				if got.Thinking.Type != "enabled" {
+					t.Errorf("Thinking.Type = %q, want enabled", got.Thinking.Type)
+				}
+			}
+			if budget != tt.wantBudget {
+				t.Errorf("Thinking.BudgetTokens = %d, want %d", budget, tt.wantBudget)
+			}
+			if got.MaxTokens != tt.wantMaxTokens {
+				t.Errorf("MaxTokens = %d, want %d", got.MaxTokens, tt.wantMaxTokens)
+			}
+		})
+	}
+}
+
+func TestFromLLMRequestSampling(t *testing.T) {
+	s := &Service{Model: Claude45Sonnet, MaxTokens: 1000}
+	temperature, topP := 0.0, 0.9
+	sampling := &llm.Sampling{Temperature: &temperature, TopP: &topP, MaxTokens: 2000}
+
+	got := s.fromLLMRequest(&llm.Request{Sampling: sampling})
+	if got.MaxTokens != 2000 {
+		t.Errorf("MaxTokens = %d, want 2000", got.MaxTokens)
+	}
+	if got.Temperature == nil || *got.Temperature != 0 || got.TopP == nil || *got.TopP != 0.9 {
+		t.Errorf("Temperature = %v, TopP = %v, want 0 and 0.9", got.Temperature, got.TopP)
+	}
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"temperature":0`) {
+		t.Errorf("expected a zero temperature to be sent, got %s", data)
+	}
+
+	// Extended thinking does not allow sampling changes
+	got = s.fromLLMRequest(&llm.Request{Sampling: sampling, Thinking: &llm.ThinkingConfig{BudgetTokens: 4000}})
+	if got.Temperature != nil || got.TopP != nil {
+		t.Errorf("expected sampling to be dropped with thinking, got %v and %v", got.Temperature, got.TopP)
+	}
+
+	hot := 1.5
+	if err := s.ValidateSampling(llm.Sampling{Temperature: &hot}); err == nil {
+		t.Error("expected temperature above 1 to be rejected")
+	}
+	if err := s.ValidateSampling(*sampling); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFromLLMRequestResponseFormat(t *testing.T) {
+	s := &Service{Model: Claude45Sonnet}
+	schema := llm.MustSchema(`{"type": "object", "properties": {"answer": {"type": "string"}}}`)
+
+	got := s.fromLLMRequest(&llm.Request{ResponseFormat: &llm.ResponseFormat{Name: "answer", Schema: schema}})
+	if got.OutputFormat == nil || got.OutputFormat.Type != "json_schema" || string(got.OutputFormat.Schema) != string(schema) {
+		t.Errorf("OutputFormat = %+v, want the json_schema format", got.OutputFormat)
+	}
+	if got := s.fromLLMRequest(&llm.Request{}); got.OutputFormat != nil {
+		t.Errorf("expected no output format, got %+v", got.OutputFormat)
+	}
+}
+
 func TestConfigDetails(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -830,6 +912,31 @@ func TestFromLLMContent(t *testing.T) {
 				Source: json.RawMessage(`{"type":"base64","media_type":"image/jpeg","data":"base64image"}`),
 			},
 		},
+		{
+			name: "pdf document",
+			c: llm.Content{
+				Type:      llm.ContentTypeDocument,
+				MediaType: "application/pdf",
+				Text:      "Document spec.pdf",
+				Data:      "base64pdf",
+			},
+			want: content{
+				Type:   "document",
+				Source: json.RawMessage(`{"type":"base64","media_type":"application/pdf","data":"base64pdf"}`),
+			},
+		},
+		{
+			name: "document as text",
+			c: llm.Content{
+				Type:      llm.ContentTypeDocument,
+				MediaType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+				Text:      "hello world",
+			},
+			want: content{
+				Type: "text",
+				Text: &text,
+			},
+		},
 		{
 			name: "tool result with nested content",
 			c: llm.Content{
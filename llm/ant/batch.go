@@ -0,0 +1,191 @@
+package ant
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+var _ llm.Batcher = (*Service)(nil)
+
+// https://docs.anthropic.com/en/api/creating-message-batches
+type batchRequest struct {
+	Requests []batchRequestItem `json:"requests"`
+}
+
+type batchRequestItem struct {
+	CustomID string   `json:"custom_id"`
+	Params   *request `json:"params"`
+}
+
+type messageBatch struct {
+	ID               string `json:"id"`
+	ProcessingStatus string `json:"processing_status"` // in_progress, canceling or ended
+	RequestCounts    struct {
+		Processing int `json:"processing"`
+		Succeeded  int `json:"succeeded"`
+		Errored    int `json:"errored"`
+		Canceled   int `json:"canceled"`
+		Expired    int `json:"expired"`
+	} `json:"request_counts"`
+	ResultsURL *string `json:"results_url"`
+}
+
+type batchResultLine struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string    `json:"type"` // succeeded, errored, canceled or expired
+		Message *response `json:"message"`
+		Error   *struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"error"`
+	} `json:"result"`
+}
+
+// batchesURL returns the URL of the Message Batches API next to the Messages API.
+func (s *Service) batchesURL() string {
+	return strings.TrimSuffix(cmp.Or(s.URL, DefaultURL), "/") + "/batches"
+}
+
+// batchDo sends a request to the Message Batches API and decodes its JSON
+// response into v.
+func (s *Service) batchDo(ctx context.Context, method, url string, body any, beta bool, v any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", s.APIKey)
+	req.Header.Set("Anthropic-Version", "2023-06-01")
+	if beta {
+		req.Header.Set("Anthropic-Beta", structuredOutputsBeta)
+	}
+	resp, err := cmp.Or(s.HTTPC, http.DefaultClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %v (url=%s): %s", resp.Status, url, buf)
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// SubmitBatch submits the items to the Message Batches API.
+func (s *Service) SubmitBatch(ctx context.Context, items []llm.BatchItem) (string, error) {
+	var body batchRequest
+	beta := false
+	for _, item := range items {
+		params := s.fromLLMRequest(item.Request)
+		beta = beta || params.OutputFormat != nil
+		body.Requests = append(body.Requests, batchRequestItem{CustomID: item.CustomID, Params: params})
+	}
+	var batch messageBatch
+	if err := s.batchDo(ctx, http.MethodPost, s.batchesURL(), body, beta, &batch); err != nil {
+		return "", fmt.Errorf("failed to submit batch: %w", err)
+	}
+	return batch.ID, nil
+}
+
+// PollBatch returns the status of a message batch, and its results once it has ended.
+func (s *Service) PollBatch(ctx context.Context, batchID string) (*llm.BatchStatus, []llm.BatchResult, error) {
+	var batch messageBatch
+	if err := s.batchDo(ctx, http.MethodGet, s.batchesURL()+"/"+batchID, nil, false, &batch); err != nil {
+		return nil, nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+	counts := batch.RequestCounts
+	status := &llm.BatchStatus{
+		Status:    batch.ProcessingStatus,
+		Ended:     batch.ProcessingStatus == "ended",
+		Total:     counts.Processing + counts.Succeeded + counts.Errored + counts.Canceled + counts.Expired,
+		Succeeded: counts.Succeeded,
+		Failed:    counts.Errored + counts.Canceled + counts.Expired,
+	}
+	if !status.Ended || batch.ResultsURL == nil {
+		return status, nil, nil
+	}
+	results, err := s.batchResults(ctx, *batch.ResultsURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return status, results, nil
+}
+
+// batchResults fetches and decodes the JSONL results of an ended batch.
+func (s *Service) batchResults(ctx context.Context, url string) ([]llm.BatchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-API-Key", s.APIKey)
+	req.Header.Set("Anthropic-Version", "2023-06-01")
+	resp, err := cmp.Or(s.HTTPC, http.DefaultClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch results: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		buf, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get batch results: status %v: %s", resp.Status, buf)
+	}
+
+	var results []llm.BatchResult
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line batchResultLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("failed to decode batch result: %w", err)
+		}
+		result := llm.BatchResult{CustomID: line.CustomID}
+		switch {
+		case line.Result.Type == "succeeded" && line.Result.Message != nil:
+			result.Response = toLLMResponse(line.Result.Message)
+		case line.Result.Error != nil:
+			result.Err = fmt.Errorf("%s: %s", line.Result.Error.Error.Type, line.Result.Error.Error.Message)
+		default:
+			result.Err = errors.New("request " + line.Result.Type)
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch results: %w", err)
+	}
+	return results, nil
+}
+
+// CancelBatch cancels a message batch.
+func (s *Service) CancelBatch(ctx context.Context, batchID string) error {
+	var batch messageBatch
+	if err := s.batchDo(ctx, http.MethodPost, s.batchesURL()+"/"+batchID+"/cancel", nil, false, &batch); err != nil {
+		return fmt.Errorf("failed to cancel batch: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package ant
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestBatch(t *testing.T) {
+	var submitted batchRequest
+	ended := false
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("POST /v1/messages/batches", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" {
+			http.Error(w, "no key", http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&submitted)
+		fmt.Fprint(w, `{"id":"msgbatch_1","processing_status":"in_progress","request_counts":{"processing":2}}`)
+	})
+	mux.HandleFunc("GET /v1/messages/batches/msgbatch_1", func(w http.ResponseWriter, r *http.Request) {
+		if !ended {
+			fmt.Fprint(w, `{"id":"msgbatch_1","processing_status":"in_progress","request_counts":{"processing":1,"succeeded":1}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"id":"msgbatch_1","processing_status":"ended","request_counts":{"succeeded":1,"errored":1},"results_url":%q}`, srv.URL+"/results")
+	})
+	mux.HandleFunc("GET /results", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"custom_id":"a","result":{"type":"succeeded","message":{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"hello"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":2}}}}`)
+		fmt.Fprintln(w, `{"custom_id":"b","result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"bad prompt"}}}}`)
+	})
+	mux.HandleFunc("POST /v1/messages/batches/msgbatch_1/cancel", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"msgbatch_1","processing_status":"canceling"}`)
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	svc := &Service{URL: srv.URL + "/v1/messages", APIKey: "key"}
+	prompt := func(text string) *llm.Request {
+		return &llm.Request{Messages: []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: text}}}}}
+	}
+	id, err := svc.SubmitBatch(t.Context(), []llm.BatchItem{{CustomID: "a", Request: prompt("hi")}, {CustomID: "b", Request: prompt("bad")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "msgbatch_1" || len(submitted.Requests) != 2 || submitted.Requests[0].CustomID != "a" || submitted.Requests[0].Params.Model != DefaultModel {
+		t.Fatalf("unexpected submission: id %q, %+v", id, submitted)
+	}
+
+	status, results, err := svc.PollBatch(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Ended || status.Total != 2 || status.Succeeded != 1 || results != nil {
+		t.Fatalf("unexpected in-progress status %+v, results %v", status, results)
+	}
+
+	ended = true
+	status, results, err = svc.PollBatch(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Ended || status.Failed != 1 || len(results) != 2 {
+		t.Fatalf("unexpected ended status %+v, results %v", status, results)
+	}
+	if results[0].CustomID != "a" || results[0].Err != nil || results[0].Response.Content[0].Text != "hello" || results[0].Response.Usage.InputTokens != 10 {
+		t.Errorf("unexpected first result %+v", results[0])
+	}
+	if results[1].CustomID != "b" || results[1].Err == nil || results[1].Err.Error() != "invalid_request_error: bad prompt" {
+		t.Errorf("unexpected second result %+v", results[1])
+	}
+
+	if err := svc.CancelBatch(t.Context(), id); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
 	"time"
 
 	"shelley.exe.dev/llm"
@@ -17,11 +20,29 @@ import (
 )
 
 // Service implements llm.Service by forwarding requests to a bridge server
-// that routes to Claude Code or Codex based on the Model field.
+// that routes to Claude Code, Codex or Gemini CLI based on the Model field.
 type Service struct {
 	HTTPC     *http.Client
 	BridgeURL string // e.g. "http://localhost:9100"
-	Model     string // e.g. "claude-code" or "codex" — passed to bridge for routing
+	Model     string // e.g. "claude-code", "codex" or "gemini-cli" — passed to bridge for routing
+
+	// ReasoningEffort is the reasoning effort of Codex's o-series models:
+	// "minimal", "low", "medium" or "high" (optional, Codex only).
+	ReasoningEffort string
+
+	// Sessions keeps the session of each conversation, so that it survives
+	// restarts of the bridge (optional). Without it the bridge keeps sessions
+	// in memory by conversation ID.
+	Sessions SessionStore
+}
+
+// SessionStore persists the bridge session of each conversation.
+type SessionStore interface {
+	// GetSession returns a conversation's session and the directory it
+	// started in, or an empty session ID if there is none.
+	GetSession(ctx context.Context, conversationID string) (sessionID, workingDir string, err error)
+	SaveSession(ctx context.Context, conversationID, sessionID, workingDir string) error
+	DeleteSession(ctx context.Context, conversationID string) error
 }
 
 // bridgeChatRequest is the JSON body sent to POST /chat on the bridge.
@@ -30,6 +51,18 @@ type bridgeChatRequest struct {
 	Message        string `json:"message"`
 	WorkingDir     string `json:"working_dir"`
 	Model          string `json:"model,omitempty"`
+	// SessionID resumes this session instead of the one the bridge remembers.
+	SessionID string `json:"session_id,omitempty"`
+	// Sandbox is the conversation's sandbox mode (Codex only).
+	Sandbox         string `json:"sandbox,omitempty"`
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+}
+
+// bridgeSessionRequest is the JSON body sent to POST /sessions/{id}/compact.
+type bridgeSessionRequest struct {
+	WorkingDir string `json:"working_dir"`
+	Model      string `json:"model,omitempty"`
+	SessionID  string `json:"session_id,omitempty"`
 }
 
 // bridgeChatResponse is the JSON body returned from POST /chat.
@@ -37,72 +70,72 @@ type bridgeChatResponse struct {
 	Result    string `json:"result"`
 	SessionID string `json:"session_id"`
 	Usage     struct {
-		InputTokens  uint64 `json:"input_tokens"`
-		OutputTokens uint64 `json:"output_tokens"`
+		InputTokens          uint64 `json:"input_tokens"`
+		OutputTokens         uint64 `json:"output_tokens"`
+		CacheReadInputTokens uint64 `json:"cache_read_input_tokens"`
 	} `json:"usage"`
 	IsError   bool `json:"is_error"`
 	Compacted bool `json:"compacted"`
+	// Events are the text and tool activity of the turn before the result, in order.
+	Events []bridgeEvent `json:"events,omitempty"`
+}
+
+// bridgeEvent is a step Claude Code took while working on a message.
+type bridgeEvent struct {
+	Type string `json:"type"` // "text", "tool_use" or "tool_result"
+	Text string `json:"text,omitempty"`
+
+	// for tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// for tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Output    string `json:"output,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
 }
 
 // Do sends the last user message to the bridge and returns the result.
 // It always returns StopReasonEndTurn so that Shelley's loop does not
 // attempt to execute any tool calls (Claude Code handles tools internally).
+// The tool calls Claude Code made are returned as the response's Activity.
 func (s *Service) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
 	// Extract the last user message text
 	userMessage := extractLastUserMessage(req.Messages)
 	if userMessage == "" {
 		return nil, fmt.Errorf("claudecode: no user message found in request")
 	}
+	// The CLIs have no structured output option
+	if req.ResponseFormat != nil {
+		userMessage += "\n\n" + req.ResponseFormat.Instruction()
+	}
 
 	// Get conversation ID from context (set by convo.go via llmhttp.WithConversationID)
 	conversationID := llmhttp.ConversationIDFromContext(ctx)
+	persist := s.Sessions != nil && conversationID != ""
 	if conversationID == "" {
 		conversationID = "default"
 	}
 
+	// The bridge falls back to its own working directory when this is empty
 	bridgeReq := bridgeChatRequest{
-		ConversationID: conversationID,
-		Message:        userMessage,
-		Model:          s.Model,
+		ConversationID:  conversationID,
+		Message:         userMessage,
+		WorkingDir:      llmhttp.WorkingDirFromContext(ctx),
+		Model:           s.Model,
+		Sandbox:         llmhttp.SandboxFromContext(ctx),
+		ReasoningEffort: s.ReasoningEffort,
 	}
-
-	body, err := json.Marshal(bridgeReq)
-	if err != nil {
-		return nil, fmt.Errorf("claudecode: failed to marshal request: %w", err)
+	if persist {
+		bridgeReq.SessionID = s.storedSession(ctx, conversationID, bridgeReq.WorkingDir)
 	}
 
-	url := s.BridgeURL + "/chat"
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("claudecode: failed to create HTTP request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
 	start := time.Now()
 
-	httpc := s.HTTPC
-	if httpc == nil {
-		httpc = http.DefaultClient
-	}
-
-	httpResp, err := httpc.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("claudecode: bridge request failed: %w", err)
-	}
-	defer httpResp.Body.Close()
-
-	respBody, err := io.ReadAll(httpResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("claudecode: failed to read bridge response: %w", err)
-	}
-
-	if httpResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("claudecode: bridge returned status %d: %s", httpResp.StatusCode, string(respBody))
-	}
-
 	var bridgeResp bridgeChatResponse
-	if err := json.Unmarshal(respBody, &bridgeResp); err != nil {
-		return nil, fmt.Errorf("claudecode: failed to unmarshal bridge response: %w", err)
+	if err := s.call(ctx, http.MethodPost, "/chat", bridgeReq, &bridgeResp); err != nil {
+		return nil, err
 	}
 
 	if bridgeResp.IsError {
@@ -111,13 +144,21 @@ func (s *Service) Do(ctx context.Context, req *llm.Request) (*llm.Response, erro
 
 	end := time.Now()
 
+	if persist && bridgeResp.SessionID != "" && bridgeResp.SessionID != bridgeReq.SessionID {
+		if err := s.Sessions.SaveSession(ctx, conversationID, bridgeResp.SessionID, bridgeReq.WorkingDir); err != nil {
+			slog.Warn("claudecode: failed to save session", "conversation_id", conversationID, "error", err)
+		}
+	}
+
 	inputTokens := bridgeResp.Usage.InputTokens
 	outputTokens := bridgeResp.Usage.OutputTokens
+	cacheReadTokens := bridgeResp.Usage.CacheReadInputTokens
 
 	if bridgeResp.Compacted {
 		// Context was compacted: set input tokens to max so the gauge shows full.
 		inputTokens = uint64(s.TokenContextWindow())
 		outputTokens = 0
+		cacheReadTokens = 0
 	}
 
 	return &llm.Response{
@@ -129,17 +170,124 @@ func (s *Service) Do(ctx context.Context, req *llm.Request) (*llm.Response, erro
 			},
 		},
 		StopReason: llm.StopReasonEndTurn,
+		Activity:   activityMessages(bridgeResp.Events),
 		Usage: llm.Usage{
-			InputTokens:  inputTokens,
-			OutputTokens: outputTokens,
-			StartTime:    &start,
-			EndTime:      &end,
+			InputTokens:          inputTokens,
+			CacheReadInputTokens: cacheReadTokens,
+			OutputTokens:         outputTokens,
+			StartTime:            &start,
+			EndTime:              &end,
 		},
 	}, nil
 }
 
-// TokenContextWindow returns the context window size for Claude Code.
+// storedSession returns the saved session of a conversation if it started in
+// workingDir. A session from another directory can't be resumed, so it is forgotten.
+func (s *Service) storedSession(ctx context.Context, conversationID, workingDir string) string {
+	sessionID, sessionDir, err := s.Sessions.GetSession(ctx, conversationID)
+	if err != nil {
+		slog.Warn("claudecode: failed to load session", "conversation_id", conversationID, "error", err)
+		return ""
+	}
+	if sessionID != "" && sessionDir != workingDir {
+		if err := s.Sessions.DeleteSession(ctx, conversationID); err != nil {
+			slog.Warn("claudecode: failed to delete session", "conversation_id", conversationID, "error", err)
+		}
+		return ""
+	}
+	return sessionID
+}
+
+// ResetSession ends the bridge session of a conversation, so that the next
+// message starts a new one. Claude Code keeps sessions per project directory,
+// so the session must be reset when the conversation's directory changes.
+func (s *Service) ResetSession(ctx context.Context, conversationID string) error {
+	if s.Sessions != nil {
+		if err := s.Sessions.DeleteSession(ctx, conversationID); err != nil {
+			return fmt.Errorf("claudecode: failed to delete session: %w", err)
+		}
+	}
+	return s.call(ctx, http.MethodDelete, "/sessions/"+url.PathEscape(conversationID), nil, nil)
+}
+
+// CompactSession asks Claude Code to summarize the conversation's session to
+// free up its context window. The summary continues in a new session.
+func (s *Service) CompactSession(ctx context.Context, conversationID string) error {
+	req := bridgeSessionRequest{
+		WorkingDir: llmhttp.WorkingDirFromContext(ctx),
+		Model:      s.Model,
+	}
+	if s.Sessions != nil {
+		req.SessionID = s.storedSession(ctx, conversationID, req.WorkingDir)
+	}
+	var resp bridgeChatResponse
+	if err := s.call(ctx, http.MethodPost, "/sessions/"+url.PathEscape(conversationID)+"/compact", req, &resp); err != nil {
+		return err
+	}
+	if resp.IsError {
+		return fmt.Errorf("claudecode: bridge error: %s", resp.Result)
+	}
+	if s.Sessions != nil && resp.SessionID != "" {
+		if err := s.Sessions.SaveSession(ctx, conversationID, resp.SessionID, req.WorkingDir); err != nil {
+			return fmt.Errorf("claudecode: failed to save session: %w", err)
+		}
+	}
+	return nil
+}
+
+// call sends a request with a JSON body, if any, to the bridge and decodes
+// the JSON response into out, if not nil.
+func (s *Service) call(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("claudecode: failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, s.BridgeURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("claudecode: failed to create HTTP request: %w", err)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	httpc := s.HTTPC
+	if httpc == nil {
+		httpc = http.DefaultClient
+	}
+
+	httpResp, err := httpc.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("claudecode: bridge request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("claudecode: failed to read bridge response: %w", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("claudecode: bridge returned status %d: %s", httpResp.StatusCode, string(respBody))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("claudecode: failed to unmarshal bridge response: %w", err)
+		}
+	}
+	return nil
+}
+
+// TokenContextWindow returns the context window size of the bridged agent.
 func (s *Service) TokenContextWindow() int {
+	if s.Model == "gemini-cli" {
+		return 1048576
+	}
 	return 200000
 }
 
@@ -163,3 +311,71 @@ func extractLastUserMessage(messages []llm.Message) string {
 	}
 	return ""
 }
+
+// activityMessages converts the bridge events into alternating assistant
+// messages, holding text and tool calls, and user messages, holding tool
+// results. A tool call the bridge reported no result for gets an error result
+// so that every call is finished. Text after the last tool result is dropped,
+// since it is part of the response.
+func activityMessages(events []bridgeEvent) []llm.Message {
+	var messages []llm.Message
+	var pending []string // tool calls without a result yet
+	add := func(role llm.MessageRole, c llm.Content) {
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, c)
+			return
+		}
+		messages = append(messages, llm.Message{Role: role, Content: []llm.Content{c}})
+	}
+	finishPending := func() {
+		for _, id := range pending {
+			add(llm.MessageRoleUser, llm.Content{
+				Type:       llm.ContentTypeToolResult,
+				ToolUseID:  id,
+				ToolError:  true,
+				ToolResult: []llm.Content{{Type: llm.ContentTypeText, Text: "Claude Code reported no result for this tool call"}},
+			})
+		}
+		pending = nil
+	}
+
+	for _, e := range events {
+		switch e.Type {
+		case "text":
+			if e.Text == "" {
+				continue
+			}
+			finishPending()
+			add(llm.MessageRoleAssistant, llm.Content{Type: llm.ContentTypeText, Text: e.Text})
+		case "tool_use":
+			// Tool calls made together are answered together
+			if n := len(messages); n > 0 && messages[n-1].Role == llm.MessageRoleUser {
+				finishPending()
+			}
+			input := e.Input
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			add(llm.MessageRoleAssistant, llm.Content{Type: llm.ContentTypeToolUse, ID: e.ID, ToolName: e.Name, ToolInput: input})
+			pending = append(pending, e.ID)
+		case "tool_result":
+			i := slices.Index(pending, e.ToolUseID)
+			if i < 0 {
+				continue
+			}
+			pending = slices.Delete(pending, i, i+1)
+			add(llm.MessageRoleUser, llm.Content{
+				Type:       llm.ContentTypeToolResult,
+				ToolUseID:  e.ToolUseID,
+				ToolError:  e.IsError,
+				ToolResult: []llm.Content{{Type: llm.ContentTypeText, Text: e.Output}},
+			})
+		}
+	}
+	finishPending()
+	// Text after the last tool call is the result itself
+	for n := len(messages); n > 0 && messages[n-1].Role == llm.MessageRoleAssistant; n-- {
+		messages = messages[:n-1]
+	}
+	return messages
+}
@@ -40,6 +40,7 @@ func TestDo_Success(t *testing.T) {
 		}
 		resp.Usage.InputTokens = 100
 		resp.Usage.OutputTokens = 50
+		resp.Usage.CacheReadInputTokens = 400
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
@@ -83,6 +84,9 @@ func TestDo_Success(t *testing.T) {
 	if resp.Usage.OutputTokens != 50 {
 		t.Errorf("expected 50 output tokens, got %d", resp.Usage.OutputTokens)
 	}
+	if resp.Usage.CacheReadInputTokens != 400 {
+		t.Errorf("expected 400 cache read tokens, got %d", resp.Usage.CacheReadInputTokens)
+	}
 }
 
 func TestDo_BridgeError(t *testing.T) {
@@ -168,6 +172,10 @@ func TestTokenContextWindow(t *testing.T) {
 	if got := svc.TokenContextWindow(); got != 200000 {
 		t.Errorf("expected 200000, got %d", got)
 	}
+	svc.Model = "gemini-cli"
+	if got := svc.TokenContextWindow(); got != 1048576 {
+		t.Errorf("expected 1048576 for gemini-cli, got %d", got)
+	}
 }
 
 func TestMaxImageDimension(t *testing.T) {
@@ -222,3 +230,200 @@ func TestExtractLastUserMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestDo_ToolActivity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := bridgeChatResponse{
+			Result: "Done.",
+			Events: []bridgeEvent{
+				{Type: "text", Text: "Let me look."},
+				{Type: "tool_use", ID: "t1", Name: "Bash", Input: json.RawMessage(`{"command":"ls"}`)},
+				{Type: "tool_use", ID: "t2", Name: "Read", Input: json.RawMessage(`{"file_path":"a.go"}`)},
+				{Type: "tool_result", ToolUseID: "t1", Output: "a.go"},
+				{Type: "tool_result", ToolUseID: "t2", Output: "no such file", IsError: true},
+				{Type: "tool_use", ID: "t3", Name: "Edit"},
+				{Type: "text", Text: "Done."},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	svc := &Service{HTTPC: server.Client(), BridgeURL: server.URL}
+	resp, err := svc.Do(context.Background(), &llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hello"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+
+	// assistant(text, t1, t2), user(t1, t2), assistant(t3), user(t3)
+	activity := resp.Activity
+	if len(activity) != 4 {
+		t.Fatalf("expected 4 activity messages, got %d: %+v", len(activity), activity)
+	}
+	if activity[0].Role != llm.MessageRoleAssistant || len(activity[0].Content) != 3 || activity[0].Content[1].ToolName != "Bash" {
+		t.Errorf("unexpected first message: %+v", activity[0])
+	}
+	results := activity[1].Content
+	if activity[1].Role != llm.MessageRoleUser || len(results) != 2 || results[0].ToolUseID != "t1" || results[0].ToolError || !results[1].ToolError {
+		t.Errorf("unexpected results: %+v", activity[1])
+	}
+	if string(activity[2].Content[0].ToolInput) != "{}" {
+		t.Errorf("expected an empty input, got %s", activity[2].Content[0].ToolInput)
+	}
+	if r := activity[3].Content[0]; r.ToolUseID != "t3" || !r.ToolError {
+		t.Errorf("expected an error result for the unanswered tool call, got %+v", r)
+	}
+	if resp.Content[0].Text != "Done." {
+		t.Errorf("unexpected response text: %q", resp.Content[0].Text)
+	}
+}
+
+func TestDo_WorkingDir(t *testing.T) {
+	var got bridgeChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(bridgeChatResponse{Result: "ok"})
+	}))
+	defer server.Close()
+
+	svc := &Service{HTTPC: server.Client(), BridgeURL: server.URL}
+	ctx := llmhttp.WithWorkingDir(context.Background(), "/src/project")
+	_, err := svc.Do(ctx, &llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hello"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	if got.WorkingDir != "/src/project" {
+		t.Errorf("expected working_dir '/src/project', got %q", got.WorkingDir)
+	}
+}
+
+func TestDo_CodexOptions(t *testing.T) {
+	var got bridgeChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(bridgeChatResponse{Result: "ok"})
+	}))
+	defer server.Close()
+
+	svc := &Service{HTTPC: server.Client(), BridgeURL: server.URL, Model: "codex", ReasoningEffort: "high"}
+	ctx := llmhttp.WithSandbox(context.Background(), llm.SandboxReadOnly)
+	_, err := svc.Do(ctx, &llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hello"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	if got.Model != "codex" || got.Sandbox != llm.SandboxReadOnly || got.ReasoningEffort != "high" {
+		t.Errorf("unexpected request: %+v", got)
+	}
+}
+
+func TestResetSession(t *testing.T) {
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		json.NewEncoder(w).Encode(map[string]bool{"deleted": true})
+	}))
+	defer server.Close()
+
+	svc := &Service{HTTPC: server.Client(), BridgeURL: server.URL}
+	if err := svc.ResetSession(context.Background(), "conv-1"); err != nil {
+		t.Fatalf("ResetSession() returned error: %v", err)
+	}
+	if method != http.MethodDelete || path != "/sessions/conv-1" {
+		t.Errorf("unexpected request: %s %s", method, path)
+	}
+}
+
+// memorySessions is a SessionStore in memory
+type memorySessions map[string][2]string
+
+func (m memorySessions) GetSession(ctx context.Context, conversationID string) (string, string, error) {
+	s := m[conversationID]
+	return s[0], s[1], nil
+}
+
+func (m memorySessions) SaveSession(ctx context.Context, conversationID, sessionID, workingDir string) error {
+	m[conversationID] = [2]string{sessionID, workingDir}
+	return nil
+}
+
+func (m memorySessions) DeleteSession(ctx context.Context, conversationID string) error {
+	delete(m, conversationID)
+	return nil
+}
+
+func TestDo_StoredSession(t *testing.T) {
+	var got bridgeChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(bridgeChatResponse{Result: "ok", SessionID: "session-2"})
+	}))
+	defer server.Close()
+
+	sessions := memorySessions{"conv-1": {"session-1", "/src/project"}}
+	svc := &Service{HTTPC: server.Client(), BridgeURL: server.URL, Sessions: sessions}
+	req := &llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hello"}}},
+		},
+	}
+	ctx := llmhttp.WithWorkingDir(llmhttp.WithConversationID(context.Background(), "conv-1"), "/src/project")
+	if _, err := svc.Do(ctx, req); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	if got.SessionID != "session-1" {
+		t.Errorf("expected the stored session to be resumed, got %q", got.SessionID)
+	}
+	if sessions["conv-1"] != [2]string{"session-2", "/src/project"} {
+		t.Errorf("expected the new session to be stored, got %v", sessions["conv-1"])
+	}
+
+	// A session from another directory is not resumed
+	got = bridgeChatRequest{}
+	ctx = llmhttp.WithWorkingDir(llmhttp.WithConversationID(context.Background(), "conv-1"), "/src/other")
+	if _, err := svc.Do(ctx, req); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	if got.SessionID != "" {
+		t.Errorf("expected no session to be resumed, got %q", got.SessionID)
+	}
+	if sessions["conv-1"] != [2]string{"session-2", "/src/other"} {
+		t.Errorf("expected the new session to be stored, got %v", sessions["conv-1"])
+	}
+}
+
+func TestCompactSession(t *testing.T) {
+	var path string
+	var got bridgeSessionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(bridgeChatResponse{SessionID: "session-2", Compacted: true})
+	}))
+	defer server.Close()
+
+	sessions := memorySessions{"conv-1": {"session-1", "/src/project"}}
+	svc := &Service{HTTPC: server.Client(), BridgeURL: server.URL, Model: "claude-code", Sessions: sessions}
+	ctx := llmhttp.WithWorkingDir(context.Background(), "/src/project")
+	if err := svc.CompactSession(ctx, "conv-1"); err != nil {
+		t.Fatalf("CompactSession() returned error: %v", err)
+	}
+	if path != "/sessions/conv-1/compact" || got.SessionID != "session-1" || got.WorkingDir != "/src/project" {
+		t.Errorf("unexpected request to %s: %+v", path, got)
+	}
+	if sessions["conv-1"][0] != "session-2" {
+		t.Errorf("expected the compacted session to be stored, got %v", sessions["conv-1"])
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package claudecode
+
+import "syscall"
+
+// processGroupAttr starts the bridge in a process group of its own.
+func processGroupAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the bridge's process group.
+func killProcessGroup(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
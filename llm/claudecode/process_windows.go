@@ -0,0 +1,17 @@
+package claudecode
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// processGroupAttr starts the bridge in a process group of its own.
+func processGroupAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// killProcessGroup kills the bridge and the processes it started.
+func killProcessGroup(pid int) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run()
+}
@@ -0,0 +1,232 @@
+package claudecode
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SupervisorConfig describes how to run the bridge server.
+type SupervisorConfig struct {
+	// Command is the bridge executable and its arguments, e.g.
+	// ["node", "bridge/dist/index.js"]. The bridge must listen on $BRIDGE_PORT.
+	Command []string `json:"command"`
+	// Dir is the working directory of the bridge (default: the current directory).
+	Dir string `json:"dir,omitempty"`
+	// Port is the port the bridge listens on; 0 picks a free port.
+	Port int `json:"port,omitempty"`
+}
+
+const (
+	// healthInterval is how often the supervisor checks the bridge's health.
+	healthInterval = 10 * time.Second
+	// maxHealthFailures is the number of failed health checks in a row after
+	// which the bridge is restarted.
+	maxHealthFailures = 3
+	// maxRestartDelay caps the backoff between restarts of a crashing bridge.
+	maxRestartDelay = 30 * time.Second
+)
+
+// Supervisor runs the bridge server as a child process, restarting it when it
+// exits or stops answering health checks.
+type Supervisor struct {
+	cfg    SupervisorConfig
+	url    string
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	healthy bool
+	ready   chan struct{} // closed the first time the bridge is healthy
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartSupervisor starts the bridge and supervises it until Close is called.
+func StartSupervisor(cfg SupervisorConfig, logger *slog.Logger) (*Supervisor, error) {
+	if len(cfg.Command) == 0 {
+		return nil, fmt.Errorf("claudecode: no bridge command configured")
+	}
+	if _, err := exec.LookPath(cfg.Command[0]); err != nil {
+		return nil, fmt.Errorf("claudecode: bridge command not found: %w", err)
+	}
+	if cfg.Port == 0 {
+		port, err := freePort()
+		if err != nil {
+			return nil, fmt.Errorf("claudecode: failed to pick a bridge port: %w", err)
+		}
+		cfg.Port = port
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s := &Supervisor{
+		cfg:    cfg,
+		url:    "http://127.0.0.1:" + strconv.Itoa(cfg.Port),
+		logger: logger,
+		ready:  make(chan struct{}),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	go s.checkHealth()
+	return s, nil
+}
+
+// URL returns the URL of the bridge.
+func (s *Supervisor) URL() string {
+	return s.url
+}
+
+// Healthy reports whether the bridge answered its last health check.
+func (s *Supervisor) Healthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.healthy
+}
+
+// WaitReady waits until the bridge first answers a health check.
+func (s *Supervisor) WaitReady(ctx context.Context) error {
+	select {
+	case <-s.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the bridge and waits for it to exit.
+func (s *Supervisor) Close() error {
+	close(s.stop)
+	s.kill()
+	<-s.done
+	return nil
+}
+
+// run starts the bridge and restarts it whenever it exits, backing off while
+// it keeps crashing.
+func (s *Supervisor) run() {
+	defer close(s.done)
+	delay := time.Second
+	for {
+		started := time.Now()
+		err := s.runOnce()
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		if time.Since(started) > time.Minute {
+			delay = time.Second
+		}
+		s.logger.Warn("Claude Code bridge exited, restarting", "error", err, "delay", delay)
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRestartDelay)
+	}
+}
+
+// runOnce runs the bridge until it exits.
+func (s *Supervisor) runOnce() error {
+	cmd := exec.Command(s.cfg.Command[0], s.cfg.Command[1:]...)
+	cmd.Dir = s.cfg.Dir
+	cmd.Env = append(os.Environ(), "BRIDGE_PORT="+strconv.Itoa(s.cfg.Port))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = processGroupAttr() // so that kill reaches the bridge's children
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	s.logger.Info("Started Claude Code bridge", "pid", cmd.Process.Pid, "url", s.url)
+
+	s.mu.Lock()
+	s.cmd = cmd
+	s.mu.Unlock()
+	select {
+	case <-s.stop: // closed while starting
+		s.kill()
+	default:
+	}
+	err := cmd.Wait()
+	s.mu.Lock()
+	s.cmd = nil
+	s.healthy = false
+	s.mu.Unlock()
+	return err
+}
+
+// kill kills the running bridge, if any, and its process group.
+func (s *Supervisor) kill() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd != nil && s.cmd.Process != nil {
+		killProcessGroup(s.cmd.Process.Pid)
+	}
+}
+
+// checkHealth polls the bridge's /health endpoint and kills the bridge after
+// maxHealthFailures failures in a row, so that run restarts it. Until the
+// bridge is first ready it is polled more often and never killed.
+func (s *Supervisor) checkHealth() {
+	client := &http.Client{Timeout: 5 * time.Second}
+	failures := 0
+	interval := 200 * time.Millisecond
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(interval):
+		}
+
+		ok := false
+		if resp, err := client.Get(s.url + "/health"); err == nil {
+			ok = resp.StatusCode == http.StatusOK
+			resp.Body.Close()
+		}
+
+		s.mu.Lock()
+		s.healthy = ok
+		s.mu.Unlock()
+		select {
+		case <-s.ready:
+		default:
+			if ok {
+				close(s.ready)
+				interval = healthInterval
+			}
+			continue
+		}
+
+		if ok {
+			failures = 0
+			continue
+		}
+		failures++
+		if failures >= maxHealthFailures {
+			s.logger.Warn("Claude Code bridge is not responding, killing it", "failures", failures)
+			s.kill()
+			failures = 0
+		}
+	}
+}
+
+// freePort returns a local TCP port that is not in use.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
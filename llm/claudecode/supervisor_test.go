@@ -0,0 +1,74 @@
+package claudecode
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestHelperBridge is run as the bridge by TestSupervisor.
+func TestHelperBridge(t *testing.T) {
+	if os.Getenv("CLAUDECODE_TEST_BRIDGE") != "1" {
+		t.Skip("helper process")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.Itoa(os.Getpid())))
+	})
+	mux.HandleFunc("/crash", func(w http.ResponseWriter, r *http.Request) {
+		os.Exit(1)
+	})
+	http.ListenAndServe("127.0.0.1:"+os.Getenv("BRIDGE_PORT"), mux)
+	os.Exit(0)
+}
+
+func TestSupervisor(t *testing.T) {
+	t.Setenv("CLAUDECODE_TEST_BRIDGE", "1")
+	s, err := StartSupervisor(SupervisorConfig{Command: []string{os.Args[0], "-test.run=^TestHelperBridge$"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	if err := s.WaitReady(ctx); err != nil {
+		t.Fatalf("bridge did not become ready: %v", err)
+	}
+	pid := func() string {
+		resp, err := http.Get(s.URL() + "/health")
+		if err != nil {
+			return ""
+		}
+		defer resp.Body.Close()
+		var buf [16]byte
+		n, _ := resp.Body.Read(buf[:])
+		return string(buf[:n])
+	}
+	first := pid()
+	if first == "" {
+		t.Fatal("expected the bridge to answer")
+	}
+
+	// A crashed bridge is restarted
+	http.Get(s.URL() + "/crash")
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if p := pid(); p != "" && p != first {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the bridge to be restarted")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestStartSupervisor_MissingCommand(t *testing.T) {
+	if _, err := StartSupervisor(SupervisorConfig{Command: []string{"no-such-bridge-command"}}, nil); err == nil {
+		t.Error("expected an error for a missing command")
+	}
+}
@@ -27,6 +27,9 @@ type Service struct {
 	URL    string       // Gemini API URL, uses the gemini package default if empty
 	APIKey string       // must be non-empty
 	Model  string       // defaults to DefaultModel if empty
+	// ImageOutput asks for images as well as text, for image models.
+	// Tools are not sent, since image models can't call them.
+	ImageOutput bool
 }
 
 var _ llm.Service = (*Service)(nil)
@@ -192,6 +195,20 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 				content.Parts = append(content.Parts, gemini.Part{
 					Text: c.Text,
 				})
+			case llm.ContentTypeImage:
+				part := gemini.Part{Text: llm.ImageNote(c)}
+				if c.Data != "" {
+					part = gemini.Part{InlineData: &gemini.Blob{MimeType: c.MediaType, Data: c.Data}}
+				}
+				part.ThoughtSignature = c.Signature
+				content.Parts = append(content.Parts, part)
+			case llm.ContentTypeDocument:
+				part := gemini.Part{Text: c.Text}
+				if c.MediaType == "application/pdf" && c.Data != "" {
+					// Gemini reads PDFs natively
+					part = gemini.Part{InlineData: &gemini.Blob{MimeType: c.MediaType, Data: c.Data}}
+				}
+				content.Parts = append(content.Parts, part)
 			case llm.ContentTypeToolUse:
 				// Tool use becomes a function call
 				var args map[string]any
@@ -285,8 +302,8 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 		gemReq.Contents = append(gemReq.Contents, content)
 	}
 
-	// Handle tools/functions
-	if len(req.Tools) > 0 {
+	// Handle tools/functions; image models don't support function calling
+	if len(req.Tools) > 0 && !s.ImageOutput {
 		// Convert tool schemas
 		decls, err := convertToolSchemas(req.Tools)
 		if err != nil {
@@ -297,6 +314,38 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 		}
 	}
 
+	if req.Sampling != nil {
+		gemReq.GenerationConfig = &gemini.GenerationConfig{
+			Temperature:     req.Sampling.Temperature,
+			TopP:            req.Sampling.TopP,
+			MaxOutputTokens: req.Sampling.MaxTokens,
+		}
+	}
+	if req.Thinking != nil && req.Thinking.BudgetTokens > 0 {
+		if gemReq.GenerationConfig == nil {
+			gemReq.GenerationConfig = &gemini.GenerationConfig{}
+		}
+		gemReq.GenerationConfig.ThinkingConfig = &gemini.ThinkingConfig{ThinkingBudget: req.Thinking.BudgetTokens}
+	}
+	if s.ImageOutput {
+		if gemReq.GenerationConfig == nil {
+			gemReq.GenerationConfig = &gemini.GenerationConfig{}
+		}
+		gemReq.GenerationConfig.ResponseModalities = []string{"TEXT", "IMAGE"}
+	}
+	if req.ResponseFormat != nil {
+		var schema map[string]any
+		if err := json.Unmarshal(req.ResponseFormat.Schema, &schema); err != nil {
+			return nil, fmt.Errorf("failed to parse response schema: %w", err)
+		}
+		if gemReq.GenerationConfig == nil {
+			gemReq.GenerationConfig = &gemini.GenerationConfig{}
+		}
+		responseSchema := convertJSONSchemaToGeminiSchema(schema)
+		gemReq.GenerationConfig.ResponseMimeType = "application/json"
+		gemReq.GenerationConfig.ResponseSchema = &responseSchema
+	}
+
 	return gemReq, nil
 }
 
@@ -318,7 +367,8 @@ func convertGeminiResponseToContent(res *gemini.Response) []llm.Content {
 			"index", i,
 			"has_text", part.Text != "",
 			"has_function_call", part.FunctionCall != nil,
-			"has_function_response", part.FunctionResponse != nil)
+			"has_function_response", part.FunctionResponse != nil,
+			"has_inline_data", part.InlineData != nil)
 
 		if part.Text != "" {
 			// Simple text response
@@ -327,6 +377,14 @@ func convertGeminiResponseToContent(res *gemini.Response) []llm.Content {
 				Text:      part.Text,
 				Signature: part.ThoughtSignature, // Capture thought signature for text parts too
 			})
+		} else if part.InlineData != nil {
+			// Media, such as an image from an image model
+			contents = append(contents, llm.Content{
+				Type:      llm.ContentTypeImage,
+				MediaType: part.InlineData.MimeType,
+				Data:      part.InlineData.Data,
+				Signature: part.ThoughtSignature,
+			})
 		} else if part.FunctionCall != nil {
 			// Function call (tool use)
 			args, err := json.Marshal(part.FunctionCall.Args)
@@ -475,6 +533,11 @@ func (s *Service) MaxImageDimension() int {
 	return 0 // No known limit
 }
 
+// ValidateSampling reports whether the Gemini API accepts the sampling parameters.
+func (s *Service) ValidateSampling(sampling llm.Sampling) error {
+	return llm.ValidateSamplingRange(sampling, 2)
+}
+
 // Do sends a request to Gemini.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
 	// Log the incoming request for debugging
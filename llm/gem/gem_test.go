@@ -221,6 +221,46 @@ func TestConvertResponseWithToolCall(t *testing.T) {
 	}
 }
 
+func TestImageOutput(t *testing.T) {
+	content := convertGeminiResponseToContent(&gemini.Response{
+		Candidates: []gemini.Candidate{{Content: gemini.Content{Parts: []gemini.Part{
+			{Text: "Here it is"},
+			{InlineData: &gemini.Blob{MimeType: "image/png", Data: "iVBORw0KGgo="}, ThoughtSignature: "sig"},
+		}}}},
+	})
+	if len(content) != 2 || content[1].Type != llm.ContentTypeImage || content[1].MediaType != "image/png" ||
+		content[1].Data != "iVBORw0KGgo=" || content[1].Signature != "sig" {
+		t.Fatalf("content = %+v, want text and an image", content)
+	}
+
+	// Saved images keep their data, which is sent again so the model can edit them
+	saved := content[1]
+	saved.Text = "/tmp/b.png"
+	svc := &Service{ImageOutput: true}
+	gemReq, err := svc.buildGeminiRequest(&llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.MessageRoleAssistant, Content: []llm.Content{saved, {Type: llm.ContentTypeImage, MediaType: "image/png", Text: "/tmp/a.png"}}},
+		},
+		Tools: []*llm.Tool{{Name: "bash", InputSchema: llm.MustSchema(`{"type": "object", "properties": {}}`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gemReq.GenerationConfig == nil || len(gemReq.GenerationConfig.ResponseModalities) != 2 {
+		t.Errorf("generation config = %+v, want text and image modalities", gemReq.GenerationConfig)
+	}
+	if len(gemReq.Tools) != 0 {
+		t.Errorf("tools were sent to an image model")
+	}
+	parts := gemReq.Contents[0].Parts
+	if parts[0].InlineData == nil || parts[0].InlineData.Data != "iVBORw0KGgo=" || parts[0].ThoughtSignature != "sig" {
+		t.Errorf("image with data sent as %+v", parts[0])
+	}
+	if parts[1].Text != "[generated image (image/png) saved to /tmp/a.png]" {
+		t.Errorf("saved image sent as %+v", parts[1])
+	}
+}
+
 func TestGeminiHeaderCapture(t *testing.T) {
 	// Create a mock HTTP client that returns a response with headers
 	mockClient := &http.Client{
@@ -53,10 +53,17 @@ type Part struct {
 	// ThoughtSignature is required for Gemini 3 models when using function calling.
 	// It must be passed back exactly as received when sending the conversation history.
 	ThoughtSignature string `json:"thoughtSignature,omitempty"`
-	// TODO inlineData
+	// InlineData is media such as an image, e.g. generated by an image model.
+	InlineData *Blob `json:"inlineData,omitempty"`
 	// TODO fileData
 }
 
+// https://ai.google.dev/api/caching#Blob
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64-encoded
+}
+
 type FunctionCall struct {
 	Name string         `json:"name"`
 	Args map[string]any `json:"args"`
@@ -95,8 +102,20 @@ const (
 
 // https://ai.google.dev/api/generate-content#v1beta.GenerationConfig
 type GenerationConfig struct {
-	ResponseMimeType string  `json:"responseMimeType,omitempty"` // text/plain, application/json, or text/x.enum
-	ResponseSchema   *Schema `json:"responseSchema,omitempty"`   // for JSON
+	ResponseMimeType string          `json:"responseMimeType,omitempty"` // text/plain, application/json, or text/x.enum
+	ResponseSchema   *Schema         `json:"responseSchema,omitempty"`   // for JSON
+	Temperature      *float64        `json:"temperature,omitempty"`
+	TopP             *float64        `json:"topP,omitempty"`
+	MaxOutputTokens  int             `json:"maxOutputTokens,omitempty"`
+	ThinkingConfig   *ThinkingConfig `json:"thinkingConfig,omitempty"`
+	// ResponseModalities are the kinds of output, e.g. TEXT and IMAGE for
+	// image models.
+	ResponseModalities []string `json:"responseModalities,omitempty"`
+}
+
+// https://ai.google.dev/api/generate-content#ThinkingConfig
+type ThinkingConfig struct {
+	ThinkingBudget int `json:"thinkingBudget,omitempty"`
 }
 
 // https://ai.google.dev/api/caching#Tool
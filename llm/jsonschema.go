@@ -0,0 +1,244 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"unicode/utf8"
+)
+
+// Validate checks that text is a JSON value matching the format's schema. It
+// understands the keywords providers' structured output features accept:
+// type, enum, const, properties, required, additionalProperties, items,
+// minItems, maxItems, minLength, maxLength, minimum, maximum, anyOf, oneOf
+// and allOf. Other keywords are ignored.
+func (f *ResponseFormat) Validate(text string) error {
+	schema, err := decodeJSON(f.Schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	value, err := decodeJSON([]byte(text))
+	if err != nil {
+		return fmt.Errorf("response is not JSON: %w", err)
+	}
+	return validateSchema(schema, value, "$")
+}
+
+// decodeJSON decodes a single JSON value, keeping numbers as json.Number so
+// that integers can be told apart.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("text after the JSON value")
+	}
+	return value, nil
+}
+
+// validateSchema checks value, found at path, against schema.
+func validateSchema(schema, value any, path string) error {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		if schema == false {
+			return fmt.Errorf("%s: not allowed", path)
+		}
+		return nil
+	}
+
+	if t, ok := s["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []any:
+			for _, tt := range t {
+				if tt, ok := tt.(string); ok {
+					types = append(types, tt)
+				}
+			}
+		}
+		if !slices.ContainsFunc(types, func(t string) bool { return hasType(value, t) }) {
+			return fmt.Errorf("%s: expected %s, got %s", path, joinTypes(types), typeOf(value))
+		}
+	}
+	if enum, ok := s["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return jsonEqual(e, value) }) {
+		return fmt.Errorf("%s: %s is not one of the allowed values", path, compact(value))
+	}
+	if c, ok := s["const"]; ok && !jsonEqual(c, value) {
+		return fmt.Errorf("%s: expected %s", path, compact(c))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if err := validateObject(s, v, path); err != nil {
+			return err
+		}
+	case []any:
+		if n, ok := number(s["minItems"]); ok && float64(len(v)) < n {
+			return fmt.Errorf("%s: expected at least %v items, got %d", path, n, len(v))
+		}
+		if n, ok := number(s["maxItems"]); ok && float64(len(v)) > n {
+			return fmt.Errorf("%s: expected at most %v items, got %d", path, n, len(v))
+		}
+		if items, ok := s["items"]; ok {
+			for i, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if n, ok := number(s["minLength"]); ok && length < n {
+			return fmt.Errorf("%s: expected at least %v characters", path, n)
+		}
+		if n, ok := number(s["maxLength"]); ok && length > n {
+			return fmt.Errorf("%s: expected at most %v characters", path, n)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if n, ok := number(s["minimum"]); ok && f < n {
+			return fmt.Errorf("%s: %v is less than the minimum %v", path, v, n)
+		}
+		if n, ok := number(s["maximum"]); ok && f > n {
+			return fmt.Errorf("%s: %v is more than the maximum %v", path, v, n)
+		}
+	}
+
+	if all, ok := s["allOf"].([]any); ok {
+		for _, sub := range all {
+			if err := validateSchema(sub, value, path); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok && !slices.ContainsFunc(anyOf, func(sub any) bool { return validateSchema(sub, value, path) == nil }) {
+		return fmt.Errorf("%s: matches none of the allowed schemas", path)
+	}
+	if oneOf, ok := s["oneOf"].([]any); ok {
+		matches := 0
+		for _, sub := range oneOf {
+			if validateSchema(sub, value, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d of the schemas, expected exactly one", path, matches)
+		}
+	}
+	return nil
+}
+
+// validateObject checks the properties of an object against schema s.
+func validateObject(s, v map[string]any, path string) error {
+	if required, ok := s["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, ok := v[name]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+	}
+	properties, _ := s["properties"].(map[string]any)
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		propPath := path + "." + name
+		if sub, ok := properties[name]; ok {
+			if err := validateSchema(sub, v[name], propPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if additional, ok := s["additionalProperties"]; ok {
+			if additional == false {
+				return fmt.Errorf("%s: unexpected property", propPath)
+			}
+			if err := validateSchema(additional, v[name], propPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasType reports whether value is of the JSON schema type t.
+func hasType(value any, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	default:
+		return typeOf(value) == t
+	}
+}
+
+// typeOf returns the JSON schema type of a decoded value.
+func typeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func joinTypes(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return fmt.Sprintf("one of %v", types)
+}
+
+// jsonEqual reports whether two decoded JSON values are equal, comparing
+// numbers by value.
+func jsonEqual(a, b any) bool {
+	if fa, ok := number(a); ok {
+		fb, ok := number(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// number returns the value of a decoded JSON number.
+func number(v any) (float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+func compact(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResponseFormatValidate(t *testing.T) {
+	format := &ResponseFormat{Schema: MustSchema(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"count": {"type": "integer", "minimum": 0},
+			"tags": {"type": "array", "items": {"enum": ["a", "b"]}, "maxItems": 2},
+			"note": {"type": ["string", "null"]}
+		},
+		"required": ["name", "count"],
+		"additionalProperties": false
+	}`)}
+
+	tests := []struct {
+		text    string
+		wantErr string
+	}{
+		{`{"name": "x", "count": 3}`, ""},
+		{`{"name": "x", "count": 3, "tags": ["a", "b"], "note": null}`, ""},
+		{`{"name": "x", "count": 3.0}`, ""},
+		{`{"name": "x"}`, `missing required property "count"`},
+		{`{"name": "", "count": 1}`, "$.name: expected at least 1 characters"},
+		{`{"name": "x", "count": 1.5}`, "$.count: expected integer, got number"},
+		{`{"name": "x", "count": -1}`, "less than the minimum"},
+		{`{"name": "x", "count": 1, "tags": ["c"]}`, `$.tags[0]: "c" is not one of the allowed values`},
+		{`{"name": "x", "count": 1, "tags": ["a", "a", "a"]}`, "at most 2 items"},
+		{`{"name": "x", "count": 1, "extra": true}`, "$.extra: unexpected property"},
+		{`{"name": "x", "count": 1, "note": 5}`, "expected one of [string null]"},
+		{"Sure! Here it is: {}", "response is not JSON"},
+		{`{"name": "x", "count": 1} and more`, "response is not JSON"},
+	}
+	for _, tt := range tests {
+		err := format.Validate(tt.text)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("Validate(%s) = %v, want nil", tt.text, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Validate(%s) = %v, want an error containing %q", tt.text, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResponseFormatValidateCombinators(t *testing.T) {
+	format := &ResponseFormat{Schema: json.RawMessage(`{"oneOf": [{"type": "string"}, {"type": "integer"}, {"type": "number", "maximum": 1}]}`)}
+	for text, valid := range map[string]bool{`"s"`: true, `5`: true, `0.5`: true, `0`: false, `true`: false} {
+		if err := format.Validate(text); (err == nil) != valid {
+			t.Errorf("Validate(%s) = %v, want valid=%v", text, err, valid)
+		}
+	}
+}
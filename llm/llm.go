@@ -4,11 +4,13 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -64,9 +66,13 @@ func EmptySchema() json.RawMessage {
 type ErrorType string
 
 const (
-	ErrorTypeNone       ErrorType = ""            // Not an error
-	ErrorTypeTruncation ErrorType = "truncation"  // Response truncated due to max tokens
-	ErrorTypeLLMRequest ErrorType = "llm_request" // LLM request failed
+	ErrorTypeNone        ErrorType = ""            // Not an error
+	ErrorTypeTruncation  ErrorType = "truncation"  // Response truncated due to max tokens
+	ErrorTypeLLMRequest  ErrorType = "llm_request" // LLM request failed
+	ErrorTypeHook        ErrorType = "hook"        // Setup command or hook failed
+	ErrorTypeInterrupted ErrorType = "interrupted" // Turn interrupted by a server crash or restart
+	ErrorTypeStuck       ErrorType = "stuck"       // Turn paused by the loop's step limit or loop detection
+	ErrorTypeTimeout     ErrorType = "timeout"     // Turn ended by the turn timeout
 )
 
 type Request struct {
@@ -74,6 +80,150 @@ type Request struct {
 	ToolChoice *ToolChoice
 	Tools      []*Tool
 	System     []SystemContent
+	// Thinking enables extended reasoning if non-nil; services without
+	// reasoning support ignore it.
+	Thinking *ThinkingConfig
+	// Sampling overrides the service's sampling defaults if non-nil.
+	Sampling *Sampling
+	// ResponseFormat constrains the text of the response to JSON matching a
+	// schema if non-nil.
+	ResponseFormat *ResponseFormat
+}
+
+// ResponseFormat asks for a response whose text is a JSON value matching
+// Schema. Services pass it to the provider's structured output feature, or
+// add it to the prompt if the provider has none.
+type ResponseFormat struct {
+	// Name identifies the schema to providers that want one.
+	Name   string
+	Schema json.RawMessage
+}
+
+// Instruction returns a prompt asking for a response matching the format, for
+// services whose provider can't enforce it.
+func (f *ResponseFormat) Instruction() string {
+	return "Respond with only a JSON value, without code fences or any other text, that matches this JSON schema:\n" + string(f.Schema)
+}
+
+// Sampling holds sampling parameters and the output length of a request.
+// Unset fields use the service's defaults.
+type Sampling struct {
+	Temperature *float64
+	TopP        *float64
+	MaxTokens   int
+}
+
+// Sandbox modes limit what a backend that runs tools itself, such as Codex,
+// may do on the machine. Services without a sandbox ignore them.
+const (
+	SandboxReadOnly         = "read-only"
+	SandboxWorkspaceWrite   = "workspace-write"
+	SandboxDangerFullAccess = "danger-full-access"
+)
+
+// SandboxModes are the valid sandbox modes.
+var SandboxModes = []string{SandboxReadOnly, SandboxWorkspaceWrite, SandboxDangerFullAccess}
+
+// ErrSessionsUnsupported is returned by wrappers of services that keep no
+// backend sessions when a session operation is requested.
+var ErrSessionsUnsupported = errors.New("model does not keep sessions")
+
+// SessionResetter is implemented by services that keep a session per
+// conversation on a backend, such as the Claude Code bridge.
+type SessionResetter interface {
+	// ResetSession ends the conversation's session; the next request starts a new one.
+	ResetSession(ctx context.Context, conversationID string) error
+}
+
+// SessionCompactor is implemented by services whose backend session can be
+// summarized to free up its context window.
+type SessionCompactor interface {
+	// CompactSession summarizes the conversation's session.
+	CompactSession(ctx context.Context, conversationID string) error
+}
+
+// ErrBatchesUnsupported is returned by wrappers of services whose provider
+// has no batch API when a batch operation is requested.
+var ErrBatchesUnsupported = errors.New("model does not support batches")
+
+// BatchItem is a request of a provider batch, identified by CustomID.
+type BatchItem struct {
+	CustomID string
+	Request  *Request
+}
+
+// BatchStatus is the progress of a provider batch.
+type BatchStatus struct {
+	// Status is the provider's status, e.g. in_progress or ended
+	Status string
+	// Ended is set once the provider has finished the batch; its results can
+	// then be fetched.
+	Ended     bool
+	Total     int
+	Succeeded int
+	Failed    int
+}
+
+// BatchResult is the outcome of a batch item: a response, or an error if the
+// item failed, was canceled or expired.
+type BatchResult struct {
+	CustomID string
+	Response *Response
+	Err      error
+}
+
+// Batcher is implemented by services whose provider runs batches of requests
+// asynchronously, within a day and at half the price of regular requests.
+type Batcher interface {
+	// SubmitBatch submits the items as a batch and returns its provider ID.
+	SubmitBatch(ctx context.Context, items []BatchItem) (string, error)
+	// PollBatch returns the status of a batch, and its results once it has ended.
+	PollBatch(ctx context.Context, batchID string) (*BatchStatus, []BatchResult, error)
+	// CancelBatch asks the provider to stop a batch; items not yet processed
+	// end up canceled.
+	CancelBatch(ctx context.Context, batchID string) error
+}
+
+// SamplingValidator is implemented by services that restrict sampling parameters.
+type SamplingValidator interface {
+	// ValidateSampling reports whether the service accepts s.
+	ValidateSampling(s Sampling) error
+}
+
+// ValidateSamplingRange checks that s has a temperature in [0, maxTemperature],
+// a top_p in (0, 1] and a non-negative max_tokens.
+// It is a helper for implementing SamplingValidator.
+func ValidateSamplingRange(s Sampling, maxTemperature float64) error {
+	if s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > maxTemperature) {
+		return fmt.Errorf("temperature must be between 0 and %g", maxTemperature)
+	}
+	if s.TopP != nil && (*s.TopP <= 0 || *s.TopP > 1) {
+		return fmt.Errorf("top_p must be greater than 0 and at most 1")
+	}
+	if s.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative")
+	}
+	return nil
+}
+
+// ThinkingConfig configures the provider's reasoning tokens.
+type ThinkingConfig struct {
+	// BudgetTokens is the number of tokens the model may spend reasoning.
+	// Providers with coarse effort levels map it to the nearest level.
+	BudgetTokens int
+}
+
+// ReasoningEffort maps a thinking budget to the low/medium/high effort
+// levels used by providers without token budgets.
+func (t *ThinkingConfig) ReasoningEffort() string {
+	switch {
+	case t.BudgetTokens < 4096:
+		return "low"
+	case t.BudgetTokens < 16384:
+		return "medium"
+	default:
+		return "high"
+	}
 }
 
 // Message represents a message in the conversation.
@@ -121,6 +271,10 @@ type Tool struct {
 	EndsTurn bool
 	// Cache indicates whether to use prompt caching for this tool
 	Cache bool
+	// ReadOnly, if set, reports whether a call with the given input only
+	// reads, so that it may run at the same time as the response's other
+	// read-only calls.
+	ReadOnly func(input json.RawMessage) bool `json:"-"`
 
 	// The Run function is automatically called when the tool is used.
 	// Run functions may be called concurrently with each other and themselves.
@@ -131,6 +285,16 @@ type Tool struct {
 	Run func(ctx context.Context, input json.RawMessage) ToolOut `json:"-"`
 }
 
+// toolNameRegexp matches the tool names all providers accept.
+var toolNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidToolName reports whether name is a tool name all providers accept: 1
+// to 64 letters, digits, underscores or hyphens. Tools defined by users or
+// plugins are checked with it.
+func ValidToolName(name string) bool {
+	return toolNameRegexp.MatchString(name)
+}
+
 // ToolOut represents the output of a tool run.
 type ToolOut struct {
 	// LLMContent is the output of the tool to be sent back to the LLM.
@@ -182,6 +346,16 @@ func StringContent(s string) Content {
 	return Content{Type: ContentTypeText, Text: s}
 }
 
+// ImageNote describes generated image content as text, for providers that
+// can't take the image itself in the conversation history. Images saved as
+// attachments have their path in Text.
+func ImageNote(c Content) string {
+	if c.Text != "" {
+		return fmt.Sprintf("[generated image (%s) saved to %s]", c.MediaType, c.Text)
+	}
+	return fmt.Sprintf("[generated image (%s)]", c.MediaType)
+}
+
 // ContentsAttr returns contents as a slog.Attr.
 // It is meant for logging.
 func ContentsAttr(contents []Content) slog.Attr {
@@ -199,6 +373,12 @@ func ContentsAttr(contents []Content) slog.Attr {
 			attrs = append(attrs, slog.Bool("tool_error", content.ToolError))
 		case ContentTypeThinking:
 			attrs = append(attrs, slog.String("thinking", content.Text))
+		case ContentTypeImage:
+			attrs = append(attrs, slog.String("media_type", content.MediaType))
+			attrs = append(attrs, slog.String("path", content.Text))
+		case ContentTypeDocument:
+			attrs = append(attrs, slog.String("media_type", content.MediaType))
+			attrs = append(attrs, slog.Int("text_length", len(content.Text)))
 		default:
 			attrs = append(attrs, slog.String("unknown_content_type", content.Type.String()))
 			attrs = append(attrs, slog.Any("text", content)) // just log it all raw, better to have too much than not enough
@@ -237,6 +417,14 @@ const (
 	StopReasonEndTurn
 	StopReasonToolUse
 	StopReasonRefusal
+
+	// ContentTypeImage is an image generated by the model. It comes last
+	// so that the values above, which are stored, don't change.
+	ContentTypeImage ContentType = iota
+	// ContentTypeDocument is a document attached by the user. Text has its
+	// extracted text; Data may have the document itself, base64 encoded,
+	// for providers that read documents natively.
+	ContentTypeDocument
 )
 
 type Response struct {
@@ -250,6 +438,12 @@ type Response struct {
 	Usage        Usage
 	StartTime    *time.Time
 	EndTime      *time.Time
+
+	// Activity holds the tool calls an agent backend made on its own before
+	// this response, such as Claude Code's file edits, as alternating
+	// assistant and user messages. They are recorded for display only; the
+	// tools must not be run again.
+	Activity []Message
 }
 
 func (m *Response) ToMessage() Message {
@@ -286,6 +480,9 @@ type Usage struct {
 	Model                    string     `json:"model,omitempty"`
 	StartTime                *time.Time `json:"start_time,omitempty"`
 	EndTime                  *time.Time `json:"end_time,omitempty"`
+	// ResponseCacheHits counts the responses served from Shelley's response
+	// cache rather than the model; their tokens cost nothing.
+	ResponseCacheHits uint64 `json:"response_cache_hits,omitempty"`
 }
 
 func (u *Usage) Add(other Usage) {
@@ -294,6 +491,7 @@ func (u *Usage) Add(other Usage) {
 	u.CacheReadInputTokens += other.CacheReadInputTokens
 	u.OutputTokens += other.OutputTokens
 	u.CostUSD += other.CostUSD
+	u.ResponseCacheHits += other.ResponseCacheHits
 }
 
 func (u *Usage) String() string {
@@ -32,18 +32,31 @@ func _() {
 	_ = x[ContentTypeRedactedThinking-4]
 	_ = x[ContentTypeToolUse-5]
 	_ = x[ContentTypeToolResult-6]
+	_ = x[ContentTypeImage-16]
+	_ = x[ContentTypeDocument-17]
 }
 
-const _ContentType_name = "ContentTypeTextContentTypeThinkingContentTypeRedactedThinkingContentTypeToolUseContentTypeToolResult"
+const (
+	_ContentType_name_0 = "ContentTypeTextContentTypeThinkingContentTypeRedactedThinkingContentTypeToolUseContentTypeToolResult"
+	_ContentType_name_1 = "ContentTypeImageContentTypeDocument"
+)
 
-var _ContentType_index = [...]uint8{0, 15, 34, 61, 79, 100}
+var (
+	_ContentType_index_0 = [...]uint8{0, 15, 34, 61, 79, 100}
+	_ContentType_index_1 = [...]uint8{0, 16, 35}
+)
 
 func (i ContentType) String() string {
-	idx := int(i) - 2
-	if i < 2 || idx >= len(_ContentType_index)-1 {
+	switch {
+	case 2 <= i && i <= 6:
+		i -= 2
+		return _ContentType_name_0[_ContentType_index_0[i]:_ContentType_index_0[i+1]]
+	case 16 <= i && i <= 17:
+		i -= 16
+		return _ContentType_name_1[_ContentType_index_1[i]:_ContentType_index_1[i+1]]
+	default:
 		return "ContentType(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _ContentType_name[_ContentType_index[idx]:_ContentType_index[idx+1]]
 }
 func _() {
 	// An "invalid array index" compiler error signifies that the constant values have changed.
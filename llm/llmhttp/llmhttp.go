@@ -19,6 +19,9 @@ const (
 	conversationIDKey contextKey = iota
 	modelIDKey
 	providerKey
+	workingDirKey
+	sandboxKey
+	priorityKey
 )
 
 // WithConversationID returns a context with the conversation ID attached.
@@ -60,6 +63,34 @@ func ProviderFromContext(ctx context.Context) string {
 	return ""
 }
 
+// WithWorkingDir returns a context with the conversation's working directory
+// attached, for backends that run tools themselves.
+func WithWorkingDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, workingDirKey, dir)
+}
+
+// WorkingDirFromContext returns the working directory from the context, if any.
+func WorkingDirFromContext(ctx context.Context) string {
+	if v := ctx.Value(workingDirKey); v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
+// WithSandbox returns a context with the conversation's sandbox mode attached,
+// for backends that run tools themselves (see llm.SandboxModes).
+func WithSandbox(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, sandboxKey, mode)
+}
+
+// SandboxFromContext returns the sandbox mode from the context, if any.
+func SandboxFromContext(ctx context.Context) string {
+	if v := ctx.Value(sandboxKey); v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
 // Recorder is called after each LLM HTTP request with the request/response details.
 type Recorder func(ctx context.Context, url string, requestBody, responseBody []byte, statusCode int, err error, duration time.Duration)
 
@@ -68,6 +99,8 @@ type Recorder func(ctx context.Context, url string, requestBody, responseBody []
 type Transport struct {
 	Base     http.RoundTripper
 	Recorder Recorder
+	// Gate, if set, holds back background requests in favor of interactive ones
+	Gate *Gate
 }
 
 // RoundTrip implements http.RoundTripper.
@@ -112,6 +145,15 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		base = http.DefaultTransport
 	}
 
+	// Background requests wait while interactive ones need the rate-limit budget
+	if t.Gate != nil {
+		done, err := t.Gate.acquire(req.Context(), req.URL.Host, PriorityFromContext(req.Context()))
+		if err != nil {
+			return nil, err
+		}
+		base = gatedRoundTripper{base, done}
+	}
+
 	resp, err := base.RoundTrip(req)
 
 	// Record the request if we have a recorder
@@ -133,7 +175,8 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return resp, err
 }
 
-// NewClient creates an http.Client with Shelley headers and optional recording.
+// NewClient creates an http.Client with Shelley headers, priority gating and
+// optional recording.
 func NewClient(base *http.Client, recorder Recorder) *http.Client {
 	if base == nil {
 		base = http.DefaultClient
@@ -148,6 +191,7 @@ func NewClient(base *http.Client, recorder Recorder) *http.Client {
 		Transport: &Transport{
 			Base:     transport,
 			Recorder: recorder,
+			Gate:     NewGate(),
 		},
 		Timeout: base.Timeout,
 	}
@@ -0,0 +1,167 @@
+package llmhttp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Priority tells interactive LLM requests, which a user waits for, from
+// background ones, such as those of batches and scheduled jobs.
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota
+	PriorityBackground
+)
+
+func (p Priority) String() string {
+	if p == PriorityBackground {
+		return "background"
+	}
+	return "interactive"
+}
+
+// WithPriority returns a context whose LLM requests take their priority from
+// priority when they are sent. It is a function so that a long-lived context,
+// such as a conversation loop's, can serve turns of different priorities.
+func WithPriority(ctx context.Context, priority func() Priority) context.Context {
+	return context.WithValue(ctx, priorityKey, priority)
+}
+
+// PriorityFromContext returns the priority of the LLM requests sent with ctx,
+// PriorityInteractive by default.
+func PriorityFromContext(ctx context.Context) Priority {
+	if f, ok := ctx.Value(priorityKey).(func() Priority); ok {
+		return f()
+	}
+	return PriorityInteractive
+}
+
+var (
+	// defaultRateLimitBackoff is how long a host is rate limited after a 429
+	// response without a Retry-After header.
+	defaultRateLimitBackoff = 30 * time.Second
+	// contentionWindow is how long after a rate limit ends the host's budget
+	// is still considered contended.
+	contentionWindow = time.Minute
+)
+
+// hostBudget is the rate-limit state of an LLM API host.
+type hostBudget struct {
+	limitedUntil time.Time // when the last rate limit ends
+	interactive  int       // interactive requests in flight
+}
+
+// contended reports whether the host rate limited a request recently.
+func (b *hostBudget) contended(now time.Time) bool {
+	return !b.limitedUntil.IsZero() && now.Before(b.limitedUntil.Add(contentionWindow))
+}
+
+// Gate schedules LLM requests by priority so that background requests never
+// take a rate-limit budget an interactive request needs. Interactive requests
+// are sent at once. Background requests wait while their host rate limits,
+// and, while its budget is contended, until no interactive request to it is
+// in flight. Without rate limits, all requests are sent at once.
+type Gate struct {
+	mu      sync.Mutex
+	hosts   map[string]*hostBudget
+	changed chan struct{} // closed and replaced when a host's state changes
+}
+
+// NewGate returns a Gate with no rate limits.
+func NewGate() *Gate {
+	return &Gate{hosts: make(map[string]*hostBudget), changed: make(chan struct{})}
+}
+
+func (g *Gate) hostLocked(host string) *hostBudget {
+	b, ok := g.hosts[host]
+	if !ok {
+		b = &hostBudget{}
+		g.hosts[host] = b
+	}
+	return b
+}
+
+func (g *Gate) notifyLocked() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// acquire waits until a request of the given priority may be sent to host.
+// The returned function must be called with the response's status and
+// headers, or zero and nil if it failed, once the request is done.
+func (g *Gate) acquire(ctx context.Context, host string, priority Priority) (func(status int, header http.Header), error) {
+	g.mu.Lock()
+	for priority == PriorityBackground {
+		b := g.hostLocked(host)
+		now := time.Now()
+		var wake <-chan time.Time
+		switch {
+		case now.Before(b.limitedUntil):
+			wake = time.After(b.limitedUntil.Sub(now))
+		case b.interactive > 0 && b.contended(now):
+		default:
+			g.mu.Unlock()
+			return g.doneFunc(host, priority), nil
+		}
+		changed := g.changed
+		g.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		case <-wake:
+		}
+		g.mu.Lock()
+	}
+	g.hostLocked(host).interactive++
+	g.mu.Unlock()
+	return g.doneFunc(host, priority), nil
+}
+
+func (g *Gate) doneFunc(host string, priority Priority) func(int, http.Header) {
+	return func(status int, header http.Header) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		b := g.hostLocked(host)
+		if priority == PriorityInteractive {
+			b.interactive--
+		}
+		if status == http.StatusTooManyRequests {
+			if until := time.Now().Add(retryAfter(header)); until.After(b.limitedUntil) {
+				b.limitedUntil = until
+			}
+		}
+		g.notifyLocked()
+	}
+}
+
+// gatedRoundTripper tells the gate how the request it let through went.
+type gatedRoundTripper struct {
+	base http.RoundTripper
+	done func(status int, header http.Header)
+}
+
+func (g gatedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := g.base.RoundTrip(req)
+	if err != nil {
+		g.done(0, nil)
+		return nil, err
+	}
+	g.done(resp.StatusCode, resp.Header)
+	return resp, nil
+}
+
+// retryAfter returns how long a 429 response asks to wait before retrying.
+func retryAfter(header http.Header) time.Duration {
+	if s, err := strconv.Atoi(header.Get("Retry-After")); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(header.Get("Retry-After")); err == nil && time.Until(t) > 0 {
+		return time.Until(t)
+	}
+	return defaultRateLimitBackoff
+}
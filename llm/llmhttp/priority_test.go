@@ -0,0 +1,132 @@
+package llmhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func setGateTimes(t *testing.T, backoff, window time.Duration) {
+	oldBackoff, oldWindow := defaultRateLimitBackoff, contentionWindow
+	defaultRateLimitBackoff, contentionWindow = backoff, window
+	t.Cleanup(func() { defaultRateLimitBackoff, contentionWindow = oldBackoff, oldWindow })
+}
+
+func TestPriorityFromContext(t *testing.T) {
+	if got := PriorityFromContext(context.Background()); got != PriorityInteractive {
+		t.Errorf("default priority = %s, want interactive", got)
+	}
+	p := PriorityBackground
+	ctx := WithPriority(context.Background(), func() Priority { return p })
+	if got := PriorityFromContext(ctx); got != PriorityBackground {
+		t.Errorf("priority = %s, want background", got)
+	}
+	p = PriorityInteractive
+	if got := PriorityFromContext(ctx); got != PriorityInteractive {
+		t.Errorf("priority after change = %s, want interactive", got)
+	}
+}
+
+func TestGate(t *testing.T) {
+	setGateTimes(t, 300*time.Millisecond, time.Minute)
+	release := make(chan struct{})
+	slowStarted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/limit":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/slow":
+			close(slowStarted)
+			<-release
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(nil, nil)
+	send := func(path string, priority Priority) time.Duration {
+		t.Helper()
+		start := time.Now()
+		ctx := WithPriority(context.Background(), func() Priority { return priority })
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+path, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("request %s: %v", path, err)
+			return 0
+		}
+		resp.Body.Close()
+		return time.Since(start)
+	}
+
+	// Without rate limits, background requests are sent at once
+	if d := send("/", PriorityBackground); d > 200*time.Millisecond {
+		t.Errorf("background request took %v before any rate limit", d)
+	}
+
+	// While the host rate limits, interactive requests are still sent at once,
+	// and background ones wait for the limit to end
+	send("/limit", PriorityInteractive)
+	if d := send("/", PriorityInteractive); d > 200*time.Millisecond {
+		t.Errorf("interactive request took %v while rate limited", d)
+	}
+	if d := send("/", PriorityBackground); d < 200*time.Millisecond {
+		t.Errorf("background request took %v while rate limited, want it to wait", d)
+	}
+
+	// While the budget is contended, background requests wait for the
+	// interactive ones in flight
+	go send("/slow", PriorityInteractive)
+	<-slowStarted
+	done := make(chan struct{})
+	go func() {
+		send("/", PriorityBackground)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("background request was sent while an interactive one was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("background request was not sent after the interactive one finished")
+	}
+}
+
+func TestGateBackgroundCanceled(t *testing.T) {
+	setGateTimes(t, time.Hour, time.Minute)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewClient(nil, nil)
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ctx = WithPriority(ctx, func() Priority { return PriorityBackground })
+	req, _ = http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	if _, err := client.Do(req); err == nil {
+		t.Error("background request was sent while rate limited for an hour")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := http.Header{}
+	if got := retryAfter(header); got != defaultRateLimitBackoff {
+		t.Errorf("retryAfter(no header) = %v", got)
+	}
+	header.Set("Retry-After", "7")
+	if got := retryAfter(header); got != 7*time.Second {
+		t.Errorf("retryAfter(7) = %v", got)
+	}
+}
@@ -0,0 +1,190 @@
+// Package llmrr records the requests and responses of an llm.Service to a
+// golden file and replays them, so that tests of code using a real model
+// run without API keys and give the same answers every time.
+//
+// A golden file holds one JSON entry per line. Requests are matched on their
+// messages only: system prompts and tool definitions contain details of the
+// machine, such as the working directory, that differ between runs.
+package llmrr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"shelley.exe.dev/llm"
+)
+
+// EnvVar selects the mode of tests that use golden files: "record" calls the
+// real model and rewrites the files; anything else replays them.
+const EnvVar = "SHELLEY_LLM_RR"
+
+// Mode is whether a Service records or replays.
+type Mode string
+
+const (
+	ModeRecord Mode = "record"
+	ModeReplay Mode = "replay"
+)
+
+// ModeFromEnv returns the mode selected by $SHELLEY_LLM_RR.
+func ModeFromEnv() Mode {
+	if os.Getenv(EnvVar) == string(ModeRecord) {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// entry is a line of a golden file.
+type entry struct {
+	Key      string        `json:"key"`
+	Messages []llm.Message `json:"messages"` // for reading the file; not used for matching
+	Response *llm.Response `json:"response,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Service is an llm.Service that records to or replays from a golden file.
+type Service struct {
+	path    string
+	mode    Mode
+	service llm.Service // the recorded service; nil when replaying
+
+	mu      sync.Mutex
+	entries []entry
+	used    []bool
+}
+
+// Open returns a Service for the golden file at path. When recording, svc
+// answers the requests and the file is truncated; when replaying, svc may be
+// nil and the file must exist.
+func Open(path string, mode Mode, svc llm.Service) (*Service, error) {
+	s := &Service{path: path, mode: mode, service: svc}
+	if mode == ModeRecord {
+		if svc == nil {
+			return nil, errors.New("llmrr: recording needs a service")
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			return nil, fmt.Errorf("llmrr: %w", err)
+		}
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("llmrr: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("llmrr: %s: %w", path, err)
+		}
+		s.entries = append(s.entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("llmrr: %s: %w", path, err)
+	}
+	s.used = make([]bool, len(s.entries))
+	return s, nil
+}
+
+// Do records the request and the response of the underlying service, or
+// returns the first recorded response to the same messages not returned yet.
+func (s *Service) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	key, err := requestKey(req)
+	if err != nil {
+		return nil, fmt.Errorf("llmrr: %w", err)
+	}
+	if s.mode == ModeRecord {
+		return s.record(ctx, key, req)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if s.used[i] || e.Key != key {
+			continue
+		}
+		s.used[i] = true
+		if e.Error != "" {
+			return nil, errors.New(e.Error)
+		}
+		response := *e.Response
+		return &response, nil
+	}
+	return nil, fmt.Errorf("llmrr: no recorded response to request %s in %s; record it with %s=record", key[:12], s.path, EnvVar)
+}
+
+// record calls the underlying service and appends the exchange to the file.
+func (s *Service) record(ctx context.Context, key string, req *llm.Request) (*llm.Response, error) {
+	response, err := s.service.Do(ctx, req)
+	e := entry{Key: key, Messages: req.Messages, Response: response}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	data, merr := json.Marshal(e)
+	if merr != nil {
+		return nil, fmt.Errorf("llmrr: %w", merr)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ferr := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if ferr != nil {
+		return nil, fmt.Errorf("llmrr: %w", ferr)
+	}
+	defer f.Close()
+	if _, werr := f.Write(append(data, '\n')); werr != nil {
+		return nil, fmt.Errorf("llmrr: %w", werr)
+	}
+	return response, err
+}
+
+// TokenContextWindow delegates to the recorded service, or returns a
+// common window when replaying.
+func (s *Service) TokenContextWindow() int {
+	if s.service != nil {
+		return s.service.TokenContextWindow()
+	}
+	return 200000
+}
+
+// MaxImageDimension delegates to the recorded service, or returns 0 when replaying.
+func (s *Service) MaxImageDimension() int {
+	if s.service != nil {
+		return s.service.MaxImageDimension()
+	}
+	return 0
+}
+
+// requestKey hashes the messages of a request, without the tool timings and
+// display data that vary between runs.
+func requestKey(req *llm.Request) (string, error) {
+	messages := make([]llm.Message, len(req.Messages))
+	for i, message := range req.Messages {
+		message.Content = append([]llm.Content(nil), message.Content...)
+		for j := range message.Content {
+			message.Content[j].ToolUseStartTime = nil
+			message.Content[j].ToolUseEndTime = nil
+			message.Content[j].Display = nil
+		}
+		messages[i] = message
+	}
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
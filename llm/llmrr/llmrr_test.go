@@ -0,0 +1,94 @@
+package llmrr
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+// countingService answers every request with the number of requests so far.
+type countingService struct {
+	calls int
+}
+
+func (s *countingService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	s.calls++
+	if req.Messages[len(req.Messages)-1].Content[0].Text == "fail" {
+		return nil, errors.New("provider failed")
+	}
+	return &llm.Response{
+		Role:    llm.MessageRoleAssistant,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: strings.Repeat("x", s.calls)}},
+	}, nil
+}
+
+func (s *countingService) TokenContextWindow() int { return 1000 }
+func (s *countingService) MaxImageDimension() int  { return 0 }
+
+func request(text string) *llm.Request {
+	now := time.Now()
+	return &llm.Request{
+		System: []llm.SystemContent{{Text: "working directory: " + text}},
+		Messages: []llm.Message{{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: text, ToolUseStartTime: &now}},
+		}},
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "golden.jsonl")
+
+	svc := &countingService{}
+	rec, err := Open(path, ModeRecord, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"a", "b", "a"} {
+		if _, err := rec.Do(ctx, request(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := rec.Do(ctx, request("fail")); err == nil {
+		t.Fatal("expected the provider's error while recording")
+	}
+
+	rep, err := Open(path, ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rep.TokenContextWindow(); got != 200000 {
+		t.Errorf("TokenContextWindow = %d, want 200000", got)
+	}
+	// Repeated requests get their responses in recorded order
+	for _, tt := range []struct{ text, want string }{{"a", "x"}, {"a", "xxx"}, {"b", "xx"}} {
+		resp, err := rep.Do(ctx, request(tt.text))
+		if err != nil {
+			t.Fatalf("replay %q: %v", tt.text, err)
+		}
+		if got := resp.Content[0].Text; got != tt.want {
+			t.Errorf("replay %q = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+	if _, err := rep.Do(ctx, request("fail")); err == nil || err.Error() != "provider failed" {
+		t.Errorf("replayed error = %v, want provider failed", err)
+	}
+	if _, err := rep.Do(ctx, request("a")); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("expected no recorded response, got %v", err)
+	}
+	if svc.calls != 4 {
+		t.Errorf("service called %d times, want 4", svc.calls)
+	}
+}
+
+func TestReplayMissingFile(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.jsonl"), ModeReplay, nil); err == nil {
+		t.Fatal("expected an error for a missing golden file")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"math/rand/v2"
 	"net/http"
 	"strings"
@@ -449,6 +450,8 @@ func fromLLMContent(c llm.Content) (string, []openai.ToolCall) {
 			resultText = strings.Join(texts, "\n")
 		}
 		return resultText, nil
+	case llm.ContentTypeImage:
+		return llm.ImageNote(c), nil
 	default:
 		// For thinking or other types, convert to text
 		return c.Text, nil
@@ -764,6 +767,15 @@ func (s *Service) MaxImageDimension() int {
 	return 0 // No known limit
 }
 
+// ValidateSampling reports whether the model accepts the sampling parameters.
+func (s *Service) ValidateSampling(sampling llm.Sampling) error {
+	model := cmp.Or(s.Model, DefaultModel)
+	if model.IsReasoningModel && (sampling.Temperature != nil || sampling.TopP != nil) {
+		return fmt.Errorf("%s does not support temperature or top_p", cmp.Or(model.UserName, model.ModelName))
+	}
+	return llm.ValidateSamplingRange(sampling, 2)
+}
+
 // Do sends a request to OpenAI using the go-openai package.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
 	// Configure the OpenAI client
@@ -810,6 +822,33 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		ToolChoice:          fromLLMToolChoice(ir.ToolChoice), // TODO: make fromLLMToolChoice return an error when a perfect translation is not possible
 		MaxCompletionTokens: cmp.Or(s.MaxTokens, DefaultMaxTokens),
 	}
+	if ir.Sampling != nil {
+		if ir.Sampling.MaxTokens > 0 {
+			req.MaxCompletionTokens = ir.Sampling.MaxTokens
+		}
+		// Reasoning models only accept the default sampling parameters
+		if !model.IsReasoningModel {
+			if ir.Sampling.Temperature != nil {
+				// go-openai omits a zero temperature; the smallest float32 requests it
+				req.Temperature = max(float32(*ir.Sampling.Temperature), math.SmallestNonzeroFloat32)
+			}
+			if ir.Sampling.TopP != nil {
+				req.TopP = float32(*ir.Sampling.TopP)
+			}
+		}
+	}
+	if ir.Thinking != nil && model.IsReasoningModel {
+		req.ReasoningEffort = ir.Thinking.ReasoningEffort()
+	}
+	if ir.ResponseFormat != nil {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   cmp.Or(ir.ResponseFormat.Name, "response"),
+				Schema: ir.ResponseFormat.Schema,
+			},
+		}
+	}
 	// Construct the full URL for logging and debugging
 	fullURL := baseURL + "/chat/completions"
 
@@ -0,0 +1,223 @@
+package oai
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"shelley.exe.dev/llm"
+)
+
+var _ llm.Batcher = (*ResponsesService)(nil)
+
+// https://platform.openai.com/docs/guides/batch
+type batchInputLine struct {
+	CustomID string           `json:"custom_id"`
+	Method   string           `json:"method"`
+	URL      string           `json:"url"`
+	Body     responsesRequest `json:"body"`
+}
+
+type openAIBatch struct {
+	ID            string  `json:"id"`
+	Status        string  `json:"status"` // validating, in_progress, finalizing, completed, failed, expired, cancelling or cancelled
+	OutputFileID  *string `json:"output_file_id"`
+	ErrorFileID   *string `json:"error_file_id"`
+	RequestCounts struct {
+		Total     int `json:"total"`
+		Completed int `json:"completed"`
+		Failed    int `json:"failed"`
+	} `json:"request_counts"`
+}
+
+type batchOutputLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+	Error *responsesError `json:"error"`
+}
+
+// batchEnded reports whether OpenAI is done with a batch in the given status.
+func batchEnded(status string) bool {
+	switch status {
+	case "completed", "failed", "expired", "cancelled":
+		return true
+	}
+	return false
+}
+
+func (s *ResponsesService) baseURL() string {
+	model := cmp.Or(s.Model, DefaultModel)
+	return cmp.Or(s.ModelURL, model.URL, OpenAIURL)
+}
+
+// batchDo sends a request to the OpenAI API and returns its body, or an
+// error if it did not succeed.
+func (s *ResponsesService) batchDo(ctx context.Context, method, path, contentType string, body io.Reader) ([]byte, error) {
+	url := s.baseURL() + path
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	if s.Org != "" {
+		req.Header.Set("OpenAI-Organization", s.Org)
+	}
+	resp, err := cmp.Or(s.HTTPC, http.DefaultClient).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d (url=%s): %s", resp.StatusCode, url, buf)
+	}
+	return buf, nil
+}
+
+// SubmitBatch uploads the items as a JSONL file and creates a batch of
+// Responses API requests from it.
+func (s *ResponsesService) SubmitBatch(ctx context.Context, items []llm.BatchItem) (string, error) {
+	var input bytes.Buffer
+	enc := json.NewEncoder(&input)
+	for _, item := range items {
+		line := batchInputLine{CustomID: item.CustomID, Method: "POST", URL: "/v1/responses", Body: s.fromLLMRequest(item.Request)}
+		if err := enc.Encode(line); err != nil {
+			return "", err
+		}
+	}
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	mw.WriteField("purpose", "batch")
+	fw, err := mw.CreateFormFile("file", "batch.jsonl")
+	if err != nil {
+		return "", err
+	}
+	fw.Write(input.Bytes())
+	mw.Close()
+	buf, err := s.batchDo(ctx, http.MethodPost, "/files", mw.FormDataContentType(), &form)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload batch input: %w", err)
+	}
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(buf, &file); err != nil {
+		return "", err
+	}
+
+	create, _ := json.Marshal(map[string]string{
+		"input_file_id":     file.ID,
+		"endpoint":          "/v1/responses",
+		"completion_window": "24h",
+	})
+	buf, err = s.batchDo(ctx, http.MethodPost, "/batches", "application/json", bytes.NewReader(create))
+	if err != nil {
+		return "", fmt.Errorf("failed to create batch: %w", err)
+	}
+	var batch openAIBatch
+	if err := json.Unmarshal(buf, &batch); err != nil {
+		return "", err
+	}
+	return batch.ID, nil
+}
+
+// PollBatch returns the status of a batch, and its results once it has ended.
+// Items that were not run, such as those of an expired batch, have no result.
+func (s *ResponsesService) PollBatch(ctx context.Context, batchID string) (*llm.BatchStatus, []llm.BatchResult, error) {
+	buf, err := s.batchDo(ctx, http.MethodGet, "/batches/"+batchID, "", nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+	var batch openAIBatch
+	if err := json.Unmarshal(buf, &batch); err != nil {
+		return nil, nil, err
+	}
+	status := &llm.BatchStatus{
+		Status:    batch.Status,
+		Ended:     batchEnded(batch.Status),
+		Total:     batch.RequestCounts.Total,
+		Succeeded: batch.RequestCounts.Completed,
+		Failed:    batch.RequestCounts.Failed,
+	}
+	if !status.Ended {
+		return status, nil, nil
+	}
+
+	var results []llm.BatchResult
+	for _, fileID := range []*string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == nil || *fileID == "" {
+			continue
+		}
+		fileResults, err := s.batchFileResults(ctx, *fileID)
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, fileResults...)
+	}
+	return status, results, nil
+}
+
+// batchFileResults fetches and decodes a batch output or error file.
+func (s *ResponsesService) batchFileResults(ctx context.Context, fileID string) ([]llm.BatchResult, error) {
+	buf, err := s.batchDo(ctx, http.MethodGet, "/files/"+fileID+"/content", "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch results: %w", err)
+	}
+	var results []llm.BatchResult
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	scanner.Buffer(nil, len(buf)+1)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line batchOutputLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("failed to decode batch result: %w", err)
+		}
+		result := llm.BatchResult{CustomID: line.CustomID}
+		switch {
+		case line.Error != nil:
+			result.Err = fmt.Errorf("%s: %s", line.Error.Code, line.Error.Message)
+		case line.Response == nil:
+			result.Err = errors.New("no response")
+		case line.Response.StatusCode != http.StatusOK:
+			result.Err = fmt.Errorf("status %d: %s", line.Response.StatusCode, line.Response.Body)
+		default:
+			var resp responsesResponse
+			if err := json.Unmarshal(line.Response.Body, &resp); err != nil {
+				result.Err = fmt.Errorf("failed to decode response: %w", err)
+			} else if resp.Error != nil {
+				result.Err = errors.New(resp.Error.Message)
+			} else {
+				result.Response = s.toLLMResponseFromResponses(&resp, nil)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
+
+// CancelBatch cancels a batch.
+func (s *ResponsesService) CancelBatch(ctx context.Context, batchID string) error {
+	if _, err := s.batchDo(ctx, http.MethodPost, "/batches/"+batchID+"/cancel", "", nil); err != nil {
+		return fmt.Errorf("failed to cancel batch: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package oai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestResponsesBatch(t *testing.T) {
+	var input string
+	status := "in_progress"
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /files", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("purpose") != "batch" {
+			http.Error(w, "bad purpose", http.StatusBadRequest)
+			return
+		}
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(f)
+		input = string(data)
+		fmt.Fprint(w, `{"id":"file-in"}`)
+	})
+	mux.HandleFunc("POST /batches", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["input_file_id"] != "file-in" || req["endpoint"] != "/v1/responses" {
+			http.Error(w, "bad batch", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"id":"batch_1","status":"validating"}`)
+	})
+	mux.HandleFunc("GET /batches/batch_1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"batch_1","status":%q,"output_file_id":"file-out","error_file_id":"file-err","request_counts":{"total":2,"completed":1,"failed":1}}`, status)
+	})
+	mux.HandleFunc("GET /files/file-out/content", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"custom_id":"a","response":{"status_code":200,"body":{"id":"resp_1","status":"completed","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"hello"}]}],"usage":{"input_tokens":10,"output_tokens":2}}}}`)
+	})
+	mux.HandleFunc("GET /files/file-err/content", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"custom_id":"b","response":{"status_code":400,"body":{"error":{"message":"bad prompt"}}}}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	svc := &ResponsesService{Model: GPT5, ModelURL: srv.URL, APIKey: "key"}
+	prompt := func(text string) *llm.Request {
+		return &llm.Request{Messages: []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: text}}}}}
+	}
+	id, err := svc.SubmitBatch(t.Context(), []llm.BatchItem{{CustomID: "a", Request: prompt("hi")}, {CustomID: "b", Request: prompt("bad")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(input), "\n")
+	if id != "batch_1" || len(lines) != 2 || !strings.Contains(lines[0], `"custom_id":"a"`) || !strings.Contains(lines[0], `"url":"/v1/responses"`) {
+		t.Fatalf("unexpected submission: id %q, input %s", id, input)
+	}
+
+	st, results, err := svc.PollBatch(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Ended || results != nil {
+		t.Fatalf("unexpected in-progress status %+v, results %v", st, results)
+	}
+
+	status = "completed"
+	st, results, err = svc.PollBatch(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Ended || st.Total != 2 || st.Succeeded != 1 || st.Failed != 1 || len(results) != 2 {
+		t.Fatalf("unexpected ended status %+v, results %v", st, results)
+	}
+	if results[0].CustomID != "a" || results[0].Err != nil || results[0].Response.Content[0].Text != "hello" || results[0].Response.Usage.OutputTokens != 2 {
+		t.Errorf("unexpected first result %+v", results[0])
+	}
+	if results[1].CustomID != "b" || results[1].Err == nil {
+		t.Errorf("unexpected second result %+v", results[1])
+	}
+}
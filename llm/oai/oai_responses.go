@@ -28,6 +28,9 @@ type ResponsesService struct {
 	MaxTokens int          // defaults to DefaultMaxTokens if zero
 	Org       string       // optional - organization ID
 	DumpLLM   bool         // whether to dump request/response text to files for debugging; defaults to false
+	// ImageGeneration gives the model the built-in image_generation tool,
+	// which returns images made by gpt-image.
+	ImageGeneration bool
 }
 
 var _ llm.Service = (*ResponsesService)(nil)
@@ -40,7 +43,20 @@ type responsesRequest struct {
 	Tools           []responsesTool      `json:"tools,omitempty"`
 	ToolChoice      any                  `json:"tool_choice,omitempty"`
 	MaxOutputTokens int                  `json:"max_output_tokens,omitempty"`
+	Temperature     *float64             `json:"temperature,omitempty"`
+	TopP            *float64             `json:"top_p,omitempty"`
 	Reasoning       *responsesReasoning  `json:"reasoning,omitempty"`
+	Text            *responsesText       `json:"text,omitempty"`
+}
+
+type responsesText struct {
+	Format responsesFormat `json:"format"`
+}
+
+type responsesFormat struct {
+	Type   string          `json:"type"` // "json_schema"
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
 }
 
 type responsesReasoning struct {
@@ -63,8 +79,8 @@ type responsesContent struct {
 }
 
 type responsesTool struct {
-	Type        string          `json:"type"` // "function"
-	Name        string          `json:"name"`
+	Type        string          `json:"type"` // "function", "image_generation"
+	Name        string          `json:"name,omitempty"`
 	Description string          `json:"description,omitempty"`
 	Parameters  json.RawMessage `json:"parameters,omitempty"`
 }
@@ -82,14 +98,16 @@ type responsesResponse struct {
 
 type responsesOutputItem struct {
 	ID        string             `json:"id"`
-	Type      string             `json:"type"`           // "message", "reasoning", "function_call"
+	Type      string             `json:"type"`           // "message", "reasoning", "function_call", "image_generation_call"
 	Role      string             `json:"role,omitempty"` // for messages: "assistant"
 	Status    string             `json:"status,omitempty"`
-	Content   []responsesContent `json:"content,omitempty"`   // for messages
-	CallID    string             `json:"call_id,omitempty"`   // for function_call
-	Name      string             `json:"name,omitempty"`      // for function_call
-	Arguments string             `json:"arguments,omitempty"` // for function_call
-	Summary   []string           `json:"summary,omitempty"`   // for reasoning
+	Content   []responsesContent `json:"content,omitempty"`       // for messages
+	CallID    string             `json:"call_id,omitempty"`       // for function_call
+	Name      string             `json:"name,omitempty"`          // for function_call
+	Arguments string             `json:"arguments,omitempty"`     // for function_call
+	Summary   []string           `json:"summary,omitempty"`       // for reasoning
+	Result    string             `json:"result,omitempty"`        // for image_generation_call: the base64-encoded image
+	Format    string             `json:"output_format,omitempty"` // for image_generation_call: png, jpeg or webp
 }
 
 type responsesUsage struct {
@@ -176,6 +194,16 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 						Text: c.Text,
 					})
 				}
+			case llm.ContentTypeImage:
+				messageContent = append(messageContent, responsesContent{
+					Type: "output_text",
+					Text: llm.ImageNote(c),
+				})
+			case llm.ContentTypeDocument:
+				messageContent = append(messageContent, responsesContent{
+					Type: "input_text",
+					Text: c.Text,
+				})
 			case llm.ContentTypeToolUse:
 				// Tool use becomes a function_call in the input
 				functionCalls = append(functionCalls, responsesInputItem{
@@ -295,6 +323,15 @@ func (s *ResponsesService) toLLMResponseFromResponses(resp *responsesResponse, h
 				ToolInput: json.RawMessage(item.Arguments),
 			})
 			stopReason = llm.StopReasonToolUse
+		case "image_generation_call":
+			if item.Result != "" {
+				contents = append(contents, llm.Content{
+					ID:        item.ID,
+					Type:      llm.ContentTypeImage,
+					MediaType: "image/" + cmp.Or(item.Format, "png"),
+					Data:      item.Result,
+				})
+			}
 		}
 	}
 
@@ -359,9 +396,14 @@ func (s *ResponsesService) MaxImageDimension() int {
 	return 0 // No known limit
 }
 
+// ValidateSampling reports whether the OpenAI API accepts the sampling parameters.
+func (s *ResponsesService) ValidateSampling(sampling llm.Sampling) error {
+	return llm.ValidateSamplingRange(sampling, 2)
+}
+
 // Do sends a request to OpenAI using the Responses API.
-func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
-	httpc := cmp.Or(s.HTTPC, http.DefaultClient)
+// fromLLMRequest converts a request to the Responses API format.
+func (s *ResponsesService) fromLLMRequest(ir *llm.Request) responsesRequest {
 	model := cmp.Or(s.Model, DefaultModel)
 
 	// Start with system messages if provided
@@ -382,6 +424,9 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 	for _, t := range ir.Tools {
 		tools = append(tools, fromLLMToolResponses(t))
 	}
+	if s.ImageGeneration {
+		tools = append(tools, responsesTool{Type: "image_generation"})
+	}
 
 	// Create the request
 	req := responsesRequest{
@@ -390,11 +435,35 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		Tools:           tools,
 		MaxOutputTokens: cmp.Or(s.MaxTokens, DefaultMaxTokens),
 	}
+	if ir.Sampling != nil {
+		if ir.Sampling.MaxTokens > 0 {
+			req.MaxOutputTokens = ir.Sampling.MaxTokens
+		}
+		req.Temperature = ir.Sampling.Temperature
+		req.TopP = ir.Sampling.TopP
+	}
+	if ir.Thinking != nil {
+		req.Reasoning = &responsesReasoning{Effort: ir.Thinking.ReasoningEffort()}
+	}
+	if ir.ResponseFormat != nil {
+		req.Text = &responsesText{Format: responsesFormat{
+			Type:   "json_schema",
+			Name:   cmp.Or(ir.ResponseFormat.Name, "response"),
+			Schema: ir.ResponseFormat.Schema,
+		}}
+	}
 
 	// Add tool choice if specified
 	if ir.ToolChoice != nil {
 		req.ToolChoice = fromLLMToolChoice(ir.ToolChoice)
 	}
+	return req
+}
+
+func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	httpc := cmp.Or(s.HTTPC, http.DefaultClient)
+	model := cmp.Or(s.Model, DefaultModel)
+	req := s.fromLLMRequest(ir)
 
 	// Construct the full URL
 	baseURL := cmp.Or(s.ModelURL, model.URL, OpenAIURL)
@@ -257,6 +257,25 @@ func TestToLLMResponseFromResponses(t *testing.T) {
 			expectedReason: llm.StopReasonStopSequence,
 			contentCount:   2, // reasoning + text
 		},
+		{
+			name: "response with generated image",
+			resp: &responsesResponse{
+				ID:    "resp_123",
+				Model: "gpt-5.1",
+				Output: []responsesOutputItem{
+					{Type: "image_generation_call", ID: "ig_123", Result: "iVBORw0KGgo="},
+					{
+						Type: "message",
+						Role: "assistant",
+						Content: []responsesContent{
+							{Type: "output_text", Text: "Here's your logo"},
+						},
+					},
+				},
+			},
+			expectedReason: llm.StopReasonStopSequence,
+			contentCount:   2, // image + text
+		},
 	}
 
 	for _, tt := range tests {
@@ -517,3 +536,37 @@ func TestResponsesServiceDo(t *testing.T) {
 		t.Errorf("resp.Usage.OutputTokens = %d, expected 20", resp.Usage.OutputTokens)
 	}
 }
+
+func TestResponsesImageGeneration(t *testing.T) {
+	var req responsesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(responsesResponse{
+			ID:     "resp_1",
+			Status: "completed",
+			Output: []responsesOutputItem{{Type: "image_generation_call", ID: "ig_1", Result: "UklGRg==", Format: "webp"}},
+		})
+	}))
+	defer server.Close()
+
+	svc := &ResponsesService{APIKey: "test", ModelURL: server.URL, ImageGeneration: true}
+	resp, err := svc.Do(context.Background(), &llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.MessageRoleUser, Content: []llm.Content{llm.StringContent("draw a logo")}},
+			{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeImage, MediaType: "image/png", Text: "/tmp/logo.png"}}},
+			{Role: llm.MessageRoleUser, Content: []llm.Content{llm.StringContent("another")}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Type != "image_generation" {
+		t.Errorf("request tools = %+v, want image_generation", req.Tools)
+	}
+	if text := req.Input[1].Content[0].Text; text != "[generated image (image/png) saved to /tmp/logo.png]" {
+		t.Errorf("earlier image sent as %q", text)
+	}
+	if c := resp.Content[0]; c.Type != llm.ContentTypeImage || c.MediaType != "image/webp" || c.Data != "UklGRg==" {
+		t.Errorf("response content = %+v, want the generated image", c)
+	}
+}
@@ -0,0 +1,112 @@
+// Package tokenizer estimates how many tokens a model makes of text, and
+// cuts text to a token budget. Scripts tokenize very differently: English
+// and code come to about four characters per token, while CJK characters
+// and emoji take a token or more each, so budgets counted in bytes or
+// characters are far off for them. Estimates are made per character class
+// with rates measured for each model family's tokenizer.
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer estimates the tokens of text for a model family. Rates are in
+// hundredths of a token per character.
+type Tokenizer struct {
+	Name  string
+	ASCII int
+	CJK   int
+	Emoji int
+	// Other is the rate of other characters, e.g. accented Latin or Cyrillic
+	Other int
+}
+
+var (
+	// Claude is the tokenizer of Anthropic's models, which splits CJK text and
+	// emoji the most finely.
+	Claude = &Tokenizer{Name: "claude", ASCII: 25, CJK: 120, Emoji: 300, Other: 50}
+	// OpenAI is the o200k tokenizer of OpenAI's models.
+	OpenAI = &Tokenizer{Name: "openai", ASCII: 25, CJK: 80, Emoji: 200, Other: 35}
+	// Gemini is the tokenizer of Google's models.
+	Gemini = &Tokenizer{Name: "gemini", ASCII: 25, CJK: 75, Emoji: 200, Other: 35}
+	// Qwen is the tokenizer of the Qwen and GLM models served by Fireworks.
+	Qwen = &Tokenizer{Name: "qwen", ASCII: 25, CJK: 70, Emoji: 200, Other: 40}
+
+	// Default is used for models of unknown families. It is Claude's, whose
+	// estimates are the highest, so that budgets are not exceeded.
+	Default = Claude
+)
+
+// ForModel returns the tokenizer of the model with the given ID.
+func ForModel(modelID string) *Tokenizer {
+	id := strings.ToLower(modelID)
+	switch {
+	case strings.Contains(id, "claude"):
+		return Claude
+	case strings.Contains(id, "gpt"), strings.Contains(id, "codex"):
+		return OpenAI
+	case strings.Contains(id, "gemini"):
+		return Gemini
+	case strings.Contains(id, "qwen"), strings.Contains(id, "glm"):
+		return Qwen
+	}
+	return Default
+}
+
+// rate returns the cost of r in hundredths of a token.
+func (t *Tokenizer) rate(r rune) int {
+	switch {
+	case r < utf8.RuneSelf:
+		return t.ASCII
+	case isEmoji(r):
+		return t.Emoji
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+		return t.CJK
+	}
+	return t.Other
+}
+
+// isEmoji reports whether r is an emoji or joins emoji into one glyph.
+func isEmoji(r rune) bool {
+	return (r >= 0x1F000 && r <= 0x1FAFF) || (r >= 0x2600 && r <= 0x27BF) ||
+		r == 0x200D || r == 0xFE0F
+}
+
+// Count returns the estimated number of tokens of s.
+func (t *Tokenizer) Count(s string) int {
+	cost := 0
+	for _, r := range s {
+		cost += t.rate(r)
+	}
+	return (cost + 99) / 100
+}
+
+// Head returns the longest prefix of s within budget tokens. It never
+// splits a character.
+func (t *Tokenizer) Head(s string, budget int) string {
+	cost := 0
+	for i, r := range s {
+		cost += t.rate(r)
+		if cost > budget*100 {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// Tail returns the longest suffix of s within budget tokens. It never
+// splits a character.
+func (t *Tokenizer) Tail(s string, budget int) string {
+	cost := 0
+	for i := len(s); i > 0; {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		cost += t.rate(r)
+		if cost > budget*100 {
+			return s[i:]
+		}
+		i -= size
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name string
+		tok  *Tokenizer
+		text string
+		want int
+	}{
+		{"empty", Claude, "", 0},
+		{"ascii", Claude, strings.Repeat("abcd", 10), 10},
+		{"ascii rounds up", Claude, "abcde", 2},
+		{"cjk claude", Claude, "日本語のテキスト", 10},
+		{"cjk openai", OpenAI, "日本語のテキスト", 7},
+		{"hangul", Gemini, "안녕하세요", 4},
+		{"emoji", Claude, "👍🎉", 6},
+		{"emoji sequence", OpenAI, "👩‍💻", 6},
+		{"cyrillic", Claude, "привет", 3},
+	}
+	for _, tt := range tests {
+		if got := tt.tok.Count(tt.text); got != tt.want {
+			t.Errorf("%s: Count(%q) = %d, want %d", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCJKCostsMoreThanBytesSuggest(t *testing.T) {
+	// 300 bytes of ASCII and of Japanese differ by far more than their byte counts
+	ascii := strings.Repeat("a", 300)
+	japanese := strings.Repeat("日", 100)
+	if len(ascii) != len(japanese) {
+		t.Fatalf("test strings differ in length: %d, %d", len(ascii), len(japanese))
+	}
+	if a, j := Claude.Count(ascii), Claude.Count(japanese); j <= a {
+		t.Errorf("Japanese counted %d tokens, ASCII %d; want Japanese to cost more", j, a)
+	}
+}
+
+func TestHeadAndTail(t *testing.T) {
+	for _, tok := range []*Tokenizer{Claude, OpenAI, Gemini, Qwen} {
+		for _, text := range []string{
+			strings.Repeat("0123456789", 50),
+			strings.Repeat("漢字とかな", 50),
+			strings.Repeat("🙂 ok ", 50),
+			strings.Repeat("mixed 日本 ✨ text ", 50),
+		} {
+			for _, budget := range []int{0, 1, 7, 40, 10000} {
+				head := tok.Head(text, budget)
+				tail := tok.Tail(text, budget)
+				for name, part := range map[string]string{"head": head, "tail": tail} {
+					if !utf8.ValidString(part) {
+						t.Errorf("%s: %s of %q split a character", tok.Name, name, text[:20])
+					}
+					if n := tok.Count(part); n > budget {
+						t.Errorf("%s: %s has %d tokens, budget %d", tok.Name, name, n, budget)
+					}
+				}
+				if !strings.HasPrefix(text, head) || !strings.HasSuffix(text, tail) {
+					t.Errorf("%s: head or tail is not part of the text", tok.Name)
+				}
+				if budget >= tok.Count(text) && (head != text || tail != text) {
+					t.Errorf("%s: text within budget %d was cut", tok.Name, budget)
+				}
+			}
+		}
+	}
+}
+
+func TestForModel(t *testing.T) {
+	tests := map[string]*Tokenizer{
+		"claude-opus-4.5":       Claude,
+		"claude-code":           Claude,
+		"gpt-5.1":               OpenAI,
+		"gpt-5.2-codex":         OpenAI,
+		"gemini-3-pro":          Gemini,
+		"qwen3-coder-fireworks": Qwen,
+		"glm-4p6-fireworks":     Qwen,
+		"predictable":           Default,
+		"":                      Default,
+	}
+	for id, want := range tests {
+		if got := ForModel(id); got != want {
+			t.Errorf("ForModel(%q) = %s, want %s", id, got.Name, want.Name)
+		}
+	}
+}
@@ -2,14 +2,21 @@ package loop
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/gitstate"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/llmhttp"
+	"shelley.exe.dev/llm/tokenizer"
 )
 
 // MessageRecordFunc is called to record new messages to persistent storage
@@ -19,6 +26,86 @@ type MessageRecordFunc func(ctx context.Context, message llm.Message, usage llm.
 // This is used to record user-visible notifications about git changes.
 type GitStateChangeFunc func(ctx context.Context, state *gitstate.GitState)
 
+// BeforeToolFunc is called before each tool execution. Content it returns is
+// added to the tool result sent to the LLM. A non-nil error blocks the tool call
+// and its text is returned to the LLM as the tool error instead.
+type BeforeToolFunc func(ctx context.Context, toolName string, input json.RawMessage) ([]llm.Content, error)
+
+// TurnEndFunc is called when a turn ends, after git state changes are reported.
+type TurnEndFunc func(ctx context.Context)
+
+// ToolOutputFunc is called to store the full output of a tool result whose
+// text was truncated for the LLM.
+type ToolOutputFunc func(ctx context.Context, toolUseID, output string) error
+
+// ImageFunc is called to save an image generated by the LLM as an
+// attachment. It returns the path of the saved image.
+type ImageFunc func(ctx context.Context, mediaType string, data []byte) (string, error)
+
+// TurnWaitFunc is called before a turn starts and blocks until it may run.
+// The returned function is called when the turn ends.
+type TurnWaitFunc func(ctx context.Context) (done func(), err error)
+
+// OnlineWaitFunc is called when an LLM request fails. If the error shows the
+// provider is unreachable, it blocks until the provider may be reachable
+// again and returns true, and the request is sent again instead of ending
+// the turn with the error.
+type OnlineWaitFunc func(ctx context.Context, err error) bool
+
+// ToolCheckFunc is called after each round of tool calls. Content it returns
+// is added to the round's last tool result, so that the LLM sees it with the
+// results before it goes on.
+type ToolCheckFunc func(ctx context.Context) []llm.Content
+
+// EventType is the type of an Event.
+type EventType string
+
+const (
+	EventLLMRequestStart EventType = "llm_request_start"
+	EventLLMRequestEnd   EventType = "llm_request_end"
+	EventToolStart       EventType = "tool_start"
+	EventToolEnd         EventType = "tool_end"
+)
+
+// Event is a step of a turn: an LLM request or tool call starting or ending.
+type Event struct {
+	Type EventType
+	// ID identifies the LLM request or tool call; its start and end share it.
+	ID string
+	// Name is the tool of tool calls and the model that answered LLM requests.
+	Name string
+	// Duration is set on end events.
+	Duration time.Duration
+	// Error is set on end events that failed.
+	Error string
+}
+
+// EventFunc is called with the steps of turns as they happen.
+type EventFunc func(ctx context.Context, event Event)
+
+// Limits keep a turn from running indefinitely. Zero values disable a limit.
+//
+// When a step limit is reached the turn is paused with an ErrorTypeStuck
+// message; it continues when resumed or when the user sends a message. When
+// a timeout expires the tool calls in flight are stopped and their partial
+// output is kept; a turn timeout then ends the turn with an ErrorTypeTimeout
+// message.
+type Limits struct {
+	// MaxToolIterations bounds the rounds of tool calls for one user message.
+	MaxToolIterations int
+	// MaxRepeatedToolCalls bounds how many rounds in a row can make the same
+	// tool calls and get the same results.
+	MaxRepeatedToolCalls int
+	// TurnTimeout bounds the time from a user message to the end of the turn.
+	TurnTimeout time.Duration
+	// ToolTimeout bounds each tool execution.
+	ToolTimeout time.Duration
+}
+
+// toolStopGrace is how long a tool whose context expired has to return its
+// partial output before the loop stops waiting for it.
+var toolStopGrace = 5 * time.Second
+
 // Config contains all configuration needed to create a Loop
 type Config struct {
 	LLM              llm.Service
@@ -33,6 +120,43 @@ type Config struct {
 	// If set, this is called at end of turn to check for git state changes.
 	// If nil, Config.WorkingDir is used as a static value.
 	GetWorkingDir func() string
+	BeforeTool    BeforeToolFunc
+	OnTurnEnd     TurnEndFunc
+	CheckTools    ToolCheckFunc
+	OnEvent       EventFunc
+	// WaitTurn, if set, limits when turns start, e.g. to cap how many
+	// conversations run turns at once.
+	WaitTurn TurnWaitFunc
+	// WaitOnline, if set, holds turns whose LLM requests fail because the
+	// provider is unreachable until it is reachable again.
+	WaitOnline OnlineWaitFunc
+	// Sampling overrides the service's sampling defaults if non-nil.
+	Sampling *llm.Sampling
+	// ResponseFormat, if set, asks for answers that are JSON matching a
+	// schema.
+	ResponseFormat *llm.ResponseFormat
+	Limits         Limits
+	// ToolOutputBudget bounds the text of a tool result sent to the LLM, in
+	// estimated tokens; longer text is truncated. Zero disables truncation.
+	ToolOutputBudget int
+	// Tokenizer estimates tokens for ToolOutputBudget; nil means the default.
+	Tokenizer *tokenizer.Tokenizer
+	// SaveToolOutput stores the full text of truncated tool results, so that
+	// it can be read later.
+	SaveToolOutput ToolOutputFunc
+	// DedupToolOutputs sends a note or a diff instead of the output of a tool
+	// call repeating an earlier one, e.g. reading the same file again, when
+	// it is unchanged or changed little.
+	DedupToolOutputs bool
+	// SaveImage saves images generated by the LLM. Saved images are
+	// recorded with their path as well as their data, which image models
+	// are sent again to edit them in later turns.
+	SaveImage ImageFunc
+	// ConcurrentReadOnlyTools runs consecutive read-only tool calls of a
+	// response at the same time (see llm.Tool.ReadOnly), so that the next
+	// LLM request waits only for the slowest of them. Results are sent in
+	// the order of the calls either way.
+	ConcurrentReadOnlyTools bool
 }
 
 // Loop manages a conversation turn with an LLM including tool execution and message recording.
@@ -43,6 +167,7 @@ type Loop struct {
 	recordMessage    MessageRecordFunc
 	history          []llm.Message
 	messageQueue     []llm.Message
+	resume           bool // whether to continue the turn in progress without a new message
 	totalUsage       llm.Usage
 	mu               sync.Mutex
 	logger           *slog.Logger
@@ -51,6 +176,28 @@ type Loop struct {
 	onGitStateChange GitStateChangeFunc
 	getWorkingDir    func() string
 	lastGitState     *gitstate.GitState
+	beforeTool       BeforeToolFunc
+	onTurnEnd        TurnEndFunc
+	checkTools       ToolCheckFunc
+	onEvent          EventFunc
+	waitTurn         TurnWaitFunc
+	waitOnline       OnlineWaitFunc
+	sampling         *llm.Sampling
+	responseFormat   *llm.ResponseFormat
+	limits           Limits
+	toolIterations   int    // rounds of tool calls since the last user message
+	lastToolRound    string // the tool calls and results of the last round
+	toolRepeats      int    // rounds in a row equal to lastToolRound
+	turnDeadline     time.Time
+	toolOutputBudget int
+	tokenizer        *tokenizer.Tokenizer
+	saveToolOutput   ToolOutputFunc
+	saveImage        ImageFunc
+	// concurrentReadOnlyTools runs consecutive read-only tool calls at once
+	concurrentReadOnlyTools bool
+	// toolOutputs are the last full outputs of tool calls by call key, if
+	// repeated outputs are deduplicated
+	toolOutputs map[string]toolOutputRef
 }
 
 // NewLoop creates a new Loop instance with the provided configuration
@@ -67,7 +214,7 @@ func NewLoop(config Config) *Loop {
 	}
 	initialGitState := gitstate.GetGitState(workingDir)
 
-	return &Loop{
+	l := &Loop{
 		llm:              config.LLM,
 		history:          config.History,
 		tools:            config.Tools,
@@ -79,7 +226,30 @@ func NewLoop(config Config) *Loop {
 		onGitStateChange: config.OnGitStateChange,
 		getWorkingDir:    config.GetWorkingDir,
 		lastGitState:     initialGitState,
+		beforeTool:       config.BeforeTool,
+		onTurnEnd:        config.OnTurnEnd,
+		checkTools:       config.CheckTools,
+		onEvent:          config.OnEvent,
+		waitTurn:         config.WaitTurn,
+		waitOnline:       config.WaitOnline,
+		sampling:         config.Sampling,
+		responseFormat:   config.ResponseFormat,
+		limits:           config.Limits,
+		toolOutputBudget: config.ToolOutputBudget,
+		tokenizer:        config.Tokenizer,
+		saveToolOutput:   config.SaveToolOutput,
+		saveImage:        config.SaveImage,
+
+		concurrentReadOnlyTools: config.ConcurrentReadOnlyTools,
+	}
+	if l.tokenizer == nil {
+		l.tokenizer = tokenizer.Default
+	}
+	if config.DedupToolOutputs {
+		l.toolOutputs = make(map[string]toolOutputRef)
+		l.seedToolOutputs(config.History)
 	}
+	return l
 }
 
 // QueueUserMessage adds a user message to the queue to be processed
@@ -90,6 +260,15 @@ func (l *Loop) QueueUserMessage(message llm.Message) {
 	l.logger.Debug("queued user message", "content_count", len(message.Content))
 }
 
+// ResumeTurn makes the loop continue an interrupted turn from its history,
+// sending it to the LLM without waiting for a new user message.
+func (l *Loop) ResumeTurn() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resume = true
+	l.logger.Debug("resuming interrupted turn")
+}
+
 // GetUsage returns the total usage accumulated by this loop
 func (l *Loop) GetUsage() llm.Usage {
 	l.mu.Lock()
@@ -132,10 +311,29 @@ func (l *Loop) Go(ctx context.Context) error {
 		default:
 		}
 
-		// Process any queued messages
+		// Wait until a pending turn may start
+		var turnDone func()
+		if l.waitTurn != nil {
+			l.mu.Lock()
+			pending := len(l.messageQueue) > 0 || l.resume
+			l.mu.Unlock()
+			if pending {
+				done, err := l.waitTurn(ctx)
+				if err != nil {
+					return err
+				}
+				turnDone = done
+			}
+		}
+
+		// Process any queued messages, or continue a resumed turn
 		l.mu.Lock()
-		hasQueuedMessages := len(l.messageQueue) > 0
+		hasQueuedMessages := len(l.messageQueue) > 0 || l.resume
+		l.resume = false
 		if hasQueuedMessages {
+			l.startTurn()
+		}
+		if len(l.messageQueue) > 0 {
 			// Add queued messages to history (they are already recorded to DB by ConversationManager)
 			for _, msg := range l.messageQueue {
 				l.history = append(l.history, msg)
@@ -147,13 +345,24 @@ func (l *Loop) Go(ctx context.Context) error {
 		if hasQueuedMessages {
 			// Send request to LLM
 			l.logger.Debug("processing queued messages", "count", 1)
-			if err := l.processLLMRequest(ctx); err != nil {
+			err := l.processLLMRequest(ctx)
+			if turnDone != nil {
+				turnDone()
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					l.logger.Info("conversation loop canceled during LLM request")
+					return ctx.Err()
+				}
 				l.logger.Error("failed to process LLM request", "error", err)
 				time.Sleep(time.Second) // Wait before retrying
 				continue
 			}
 			l.logger.Debug("finished processing queued messages")
 		} else {
+			if turnDone != nil {
+				turnDone()
+			}
 			// No queued messages, wait a bit
 			select {
 			case <-ctx.Done():
@@ -181,6 +390,7 @@ func (l *Loop) ProcessOneTurn(ctx context.Context) error {
 		}
 		l.messageQueue = nil
 	}
+	l.startTurn()
 	l.mu.Unlock()
 
 	// Process one LLM request and response
@@ -189,6 +399,11 @@ func (l *Loop) ProcessOneTurn(ctx context.Context) error {
 
 // processLLMRequest sends a request to the LLM and handles the response
 func (l *Loop) processLLMRequest(ctx context.Context) error {
+	if l.turnTimedOut() {
+		l.endTimedOutTurn(ctx)
+		return nil
+	}
+
 	l.mu.Lock()
 	messages := append([]llm.Message(nil), l.history...)
 	tools := l.tools
@@ -225,6 +440,9 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 		Messages: messages,
 		Tools:    tools,
 		System:   system,
+		Sampling: l.sampling,
+		// Tool calls are exempt; the format applies to the answer
+		ResponseFormat: l.responseFormat,
 	}
 
 	// Insert missing tool results if the previous message had tool_use blocks
@@ -238,11 +456,11 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	}
 	l.logger.Debug("sending LLM request", "message_count", len(messages), "tool_count", len(tools), "system_items", len(system), "system_length", systemLen)
 
-	// Add a timeout for the LLM request to prevent indefinite hangs
-	llmCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-	defer cancel()
-
-	resp, err := llmService.Do(llmCtx, req)
+	resp, err := l.sendLLMRequest(ctx, llmService, req)
+	if err != nil && ctx.Err() == nil && l.turnTimedOut() {
+		l.endTimedOutTurn(ctx)
+		return nil
+	}
 	if err != nil {
 		// Record the error as a message so it can be displayed in the UI
 		// EndOfTurn must be true so the agent working state is properly updated
@@ -277,8 +495,19 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 		return l.handleMaxTokensTruncation(ctx, resp)
 	}
 
+	// Record the tool calls the backend already made before its response
+	for _, message := range resp.Activity {
+		l.mu.Lock()
+		l.history = append(l.history, message)
+		l.mu.Unlock()
+		if err := l.recordMessage(ctx, message, llm.Usage{}); err != nil {
+			l.logger.Error("failed to record backend activity", "error", err)
+		}
+	}
+
 	// Convert response to message and add to history
 	assistantMessage := resp.ToMessage()
+	l.saveImages(ctx, assistantMessage.Content)
 	l.mu.Lock()
 	l.history = append(l.history, assistantMessage)
 	l.mu.Unlock()
@@ -298,12 +527,63 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 		return l.handleToolCalls(ctx, resp.Content)
 	}
 
-	// End of turn - check for git state changes
-	l.checkGitStateChange(ctx)
+	l.endTurn(ctx)
 
 	return nil
 }
 
+// sendLLMRequest sends req to the LLM. While the provider is unreachable, it
+// waits for it with WaitOnline and sends req again.
+func (l *Loop) sendLLMRequest(ctx context.Context, llmService llm.Service, req *llm.Request) (*llm.Response, error) {
+	for {
+		resp, err := l.doLLMRequest(ctx, llmService, req)
+		if err == nil || ctx.Err() != nil || l.turnTimedOut() || l.waitOnline == nil || !l.waitOnline(ctx, err) {
+			return resp, err
+		}
+		l.logger.Info("retrying LLM request after the provider was unreachable")
+	}
+}
+
+// doLLMRequest sends req to the LLM once, emitting the request's events.
+func (l *Loop) doLLMRequest(ctx context.Context, llmService llm.Service, req *llm.Request) (*llm.Response, error) {
+	// Add a timeout for the LLM request to prevent indefinite hangs
+	llmCtx, cancel := l.withTurnDeadline(ctx, 5*time.Minute)
+	defer cancel()
+	if workingDir := l.currentWorkingDir(); workingDir != "" {
+		llmCtx = llmhttp.WithWorkingDir(llmCtx, workingDir)
+	}
+
+	requestID := rand.Text()
+	l.emit(ctx, Event{Type: EventLLMRequestStart, ID: requestID})
+	requestStart := time.Now()
+	resp, err := llmService.Do(llmCtx, req)
+	requestEnd := Event{Type: EventLLMRequestEnd, ID: requestID, Duration: time.Since(requestStart)}
+	if err != nil {
+		requestEnd.Error = err.Error()
+	} else {
+		requestEnd.Name = resp.Model
+	}
+	l.emit(ctx, requestEnd)
+	return resp, err
+}
+
+// currentWorkingDir returns the tools' working directory, which a tool may
+// have changed since the loop started.
+func (l *Loop) currentWorkingDir() string {
+	if l.getWorkingDir != nil {
+		return l.getWorkingDir()
+	}
+	return l.workingDir
+}
+
+// endTurn reports git state changes and runs the end-of-turn callback.
+func (l *Loop) endTurn(ctx context.Context) {
+	l.checkGitStateChange(ctx)
+	if l.onTurnEnd != nil {
+		l.onTurnEnd(ctx)
+	}
+}
+
 // checkGitStateChange checks if the git state has changed and calls the callback if so.
 // This is called at the end of each turn.
 func (l *Loop) checkGitStateChange(ctx context.Context) {
@@ -312,10 +592,7 @@ func (l *Loop) checkGitStateChange(ctx context.Context) {
 	}
 
 	// Get current working directory
-	workingDir := l.workingDir
-	if l.getWorkingDir != nil {
-		workingDir = l.getWorkingDir()
-	}
+	workingDir := l.currentWorkingDir()
 
 	// Get current git state
 	currentState := gitstate.GetGitState(workingDir)
@@ -385,75 +662,34 @@ func (l *Loop) handleMaxTokensTruncation(ctx context.Context, resp *llm.Response
 	}
 
 	// End the turn - don't automatically continue
-	l.checkGitStateChange(ctx)
+	l.endTurn(ctx)
 	return nil
 }
 
 // handleToolCalls processes tool calls from the LLM response
 func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error {
-	var toolResults []llm.Content
-
+	var uses []llm.Content
 	for _, c := range content {
-		if c.Type != llm.ContentTypeToolUse {
-			continue
+		if c.Type == llm.ContentTypeToolUse {
+			uses = append(uses, c)
 		}
+	}
 
-		l.logger.Debug("executing tool", "name", c.ToolName, "id", c.ID)
+	var toolResults []llm.Content
+	for len(uses) > 0 {
+		n := l.concurrentToolCalls(uses)
+		toolResults = append(toolResults, l.runToolCalls(ctx, uses[:n])...)
+		uses = uses[n:]
+	}
 
-		// Find the tool
-		var tool *llm.Tool
-		for _, t := range l.tools {
-			if t.Name == c.ToolName {
-				tool = t
-				break
+	if len(toolResults) > 0 {
+		if l.checkTools != nil {
+			if feedback := l.checkTools(ctx); len(feedback) > 0 {
+				last := &toolResults[len(toolResults)-1]
+				last.ToolResult = append(append([]llm.Content(nil), last.ToolResult...), feedback...)
 			}
 		}
 
-		if tool == nil {
-			l.logger.Error("tool not found", "name", c.ToolName)
-			toolResults = append(toolResults, llm.Content{
-				Type:      llm.ContentTypeToolResult,
-				ToolUseID: c.ID,
-				ToolError: true,
-				ToolResult: []llm.Content{
-					{Type: llm.ContentTypeText, Text: fmt.Sprintf("Tool '%s' not found", c.ToolName)},
-				},
-			})
-			continue
-		}
-
-		// Execute the tool with working directory set in context
-		toolCtx := ctx
-		if l.workingDir != "" {
-			toolCtx = claudetool.WithWorkingDir(ctx, l.workingDir)
-		}
-		startTime := time.Now()
-		result := tool.Run(toolCtx, c.ToolInput)
-		endTime := time.Now()
-
-		var toolResultContent []llm.Content
-		if result.Error != nil {
-			l.logger.Error("tool execution failed", "name", c.ToolName, "error", result.Error)
-			toolResultContent = []llm.Content{
-				{Type: llm.ContentTypeText, Text: result.Error.Error()},
-			}
-		} else {
-			toolResultContent = result.LLMContent
-			l.logger.Debug("tool executed successfully", "name", c.ToolName, "duration", endTime.Sub(startTime))
-		}
-
-		toolResults = append(toolResults, llm.Content{
-			Type:             llm.ContentTypeToolResult,
-			ToolUseID:        c.ID,
-			ToolError:        result.Error != nil,
-			ToolResult:       toolResultContent,
-			ToolUseStartTime: &startTime,
-			ToolUseEndTime:   &endTime,
-			Display:          result.Display,
-		})
-	}
-
-	if len(toolResults) > 0 {
 		// Add tool results to history as a user message
 		toolMessage := llm.Message{
 			Role:    llm.MessageRoleUser,
@@ -469,8 +705,10 @@ func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error
 				l.history = append(l.history, msg)
 			}
 			l.messageQueue = l.messageQueue[:0]
+			l.resetToolRounds()
 			l.logger.Info("processing user interruption during tool execution")
 		}
+		stuck := l.countToolRound(content, toolResults)
 		l.mu.Unlock()
 
 		// Record tool result message
@@ -478,6 +716,11 @@ func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error
 			l.logger.Error("failed to record tool result message", "error", err)
 		}
 
+		if stuck != "" {
+			l.pauseStuck(ctx, stuck)
+			return nil
+		}
+
 		// Process another LLM request with the tool results
 		return l.processLLMRequest(ctx)
 	}
@@ -485,6 +728,411 @@ func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error
 	return nil
 }
 
+// toolCall is a tool call being handled.
+type toolCall struct {
+	use         llm.Content
+	tool        *llm.Tool
+	ctx         context.Context
+	hookContent []llm.Content
+	// result is set when the call is answered without running the tool
+	result     *llm.Content
+	out        llm.ToolOut
+	timedOut   bool
+	start, end time.Time
+}
+
+// concurrentToolCalls returns how many of the leading calls of uses may run
+// at once: the read-only calls up to the first other one, if concurrent
+// read-only calls are enabled, and otherwise one.
+func (l *Loop) concurrentToolCalls(uses []llm.Content) int {
+	if !l.concurrentReadOnlyTools {
+		return 1
+	}
+	n := 0
+	for _, c := range uses {
+		tool := l.findTool(c.ToolName)
+		if tool == nil || tool.ReadOnly == nil || !tool.ReadOnly(c.ToolInput) {
+			break
+		}
+		n++
+	}
+	return max(n, 1)
+}
+
+// findTool returns the tool with the given name, or nil.
+func (l *Loop) findTool(name string) *llm.Tool {
+	for _, t := range l.tools {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// runToolCalls runs tool calls at once and returns their results in order.
+// BeforeTool and the handling of results run for one call at a time, in
+// order, so that only the tools themselves overlap.
+func (l *Loop) runToolCalls(ctx context.Context, uses []llm.Content) []llm.Content {
+	calls := make([]*toolCall, len(uses))
+	for i, c := range uses {
+		calls[i] = l.prepareToolCall(ctx, c)
+	}
+
+	var wg sync.WaitGroup
+	for _, call := range calls {
+		if call.result != nil {
+			continue
+		}
+		if len(calls) == 1 {
+			l.executeToolCall(ctx, call)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.executeToolCall(ctx, call)
+		}()
+	}
+	wg.Wait()
+
+	results := make([]llm.Content, 0, len(calls))
+	for _, call := range calls {
+		if call.result == nil {
+			call.result = l.toolCallResult(ctx, call)
+		}
+		results = append(results, *call.result)
+	}
+	return results
+}
+
+// prepareToolCall finds a call's tool and runs BeforeTool, answering the call
+// with an error if the tool does not exist or the call is blocked.
+func (l *Loop) prepareToolCall(ctx context.Context, c llm.Content) *toolCall {
+	call := &toolCall{use: c}
+	errorResult := func(text string) *toolCall {
+		call.result = &llm.Content{
+			Type:      llm.ContentTypeToolResult,
+			ToolUseID: c.ID,
+			ToolError: true,
+			ToolResult: []llm.Content{
+				{Type: llm.ContentTypeText, Text: text},
+			},
+		}
+		return call
+	}
+
+	l.logger.Debug("executing tool", "name", c.ToolName, "id", c.ID)
+
+	call.tool = l.findTool(c.ToolName)
+	if call.tool == nil {
+		l.logger.Error("tool not found", "name", c.ToolName)
+		return errorResult(fmt.Sprintf("Tool '%s' not found", c.ToolName))
+	}
+
+	// Execute the tool with working directory set in context
+	call.ctx = ctx
+	if l.workingDir != "" {
+		call.ctx = claudetool.WithWorkingDir(ctx, l.workingDir)
+	}
+
+	if l.beforeTool != nil {
+		content, err := l.beforeTool(call.ctx, c.ToolName, c.ToolInput)
+		if err != nil {
+			l.logger.Info("tool call blocked", "name", c.ToolName, "error", err)
+			return errorResult(err.Error())
+		}
+		call.hookContent = content
+	}
+	return call
+}
+
+// executeToolCall runs a prepared call's tool.
+func (l *Loop) executeToolCall(ctx context.Context, call *toolCall) {
+	c := call.use
+	l.emit(ctx, Event{Type: EventToolStart, ID: c.ID, Name: c.ToolName})
+	call.start = time.Now()
+	call.out, call.timedOut = l.runTool(call.ctx, call.tool, c.ToolInput)
+	call.end = time.Now()
+	toolEnd := Event{Type: EventToolEnd, ID: c.ID, Name: c.ToolName, Duration: call.end.Sub(call.start)}
+	if call.out.Error != nil {
+		toolEnd.Error = call.out.Error.Error()
+	} else if call.timedOut {
+		toolEnd.Error = context.DeadlineExceeded.Error()
+	}
+	l.emit(ctx, toolEnd)
+}
+
+// toolCallResult returns the tool result of an executed call.
+func (l *Loop) toolCallResult(ctx context.Context, call *toolCall) *llm.Content {
+	c, result := call.use, call.out
+	startTime, endTime := call.start, call.end
+	var toolResultContent []llm.Content
+	if result.Error != nil {
+		l.logger.Error("tool execution failed", "name", c.ToolName, "error", result.Error)
+		toolResultContent = []llm.Content{
+			{Type: llm.ContentTypeText, Text: result.Error.Error()},
+		}
+	} else {
+		toolResultContent = result.LLMContent
+		l.logger.Debug("tool executed successfully", "name", c.ToolName, "duration", endTime.Sub(startTime))
+	}
+	if call.timedOut {
+		l.logger.Warn("tool execution timed out", "name", c.ToolName, "duration", endTime.Sub(startTime))
+		toolResultContent = append(append([]llm.Content(nil), toolResultContent...), llm.Content{
+			Type: llm.ContentTypeText,
+			Text: fmt.Sprintf("Tool execution timed out after %s; the output above is partial.", endTime.Sub(startTime).Round(time.Second)),
+		})
+		if result.Error == nil {
+			result.Error = context.DeadlineExceeded
+		}
+	}
+	if result.Error == nil {
+		toolResultContent = l.replaceRepeatedOutput(ctx, c, toolResultContent)
+	}
+	toolResultContent = l.truncateToolOutput(ctx, c.ID, toolResultContent)
+	if result.Error == nil {
+		l.mu.Lock()
+		index := len(l.history)
+		l.mu.Unlock()
+		l.rememberToolOutput(c, toolResultContent, index)
+	}
+	if len(call.hookContent) > 0 {
+		toolResultContent = append(append([]llm.Content(nil), toolResultContent...), call.hookContent...)
+	}
+
+	return &llm.Content{
+		Type:             llm.ContentTypeToolResult,
+		ToolUseID:        c.ID,
+		ToolError:        result.Error != nil,
+		ToolResult:       toolResultContent,
+		ToolUseStartTime: &startTime,
+		ToolUseEndTime:   &endTime,
+		Display:          result.Display,
+	}
+}
+
+// emit reports an event to the OnEvent callback, if any.
+func (l *Loop) emit(ctx context.Context, event Event) {
+	if l.onEvent != nil {
+		l.onEvent(ctx, event)
+	}
+}
+
+// startTurn starts the limits of a turn: the step counts and the timeout.
+// The caller must hold l.mu.
+func (l *Loop) startTurn() {
+	l.resetToolRounds()
+	l.turnDeadline = time.Time{}
+	if l.limits.TurnTimeout > 0 {
+		l.turnDeadline = time.Now().Add(l.limits.TurnTimeout)
+	}
+}
+
+// resetToolRounds starts counting tool rounds afresh, for a new user message.
+// The caller must hold l.mu.
+func (l *Loop) resetToolRounds() {
+	l.toolIterations = 0
+	l.lastToolRound = ""
+	l.toolRepeats = 0
+}
+
+// turnTimedOut reports whether the turn's timeout has expired.
+func (l *Loop) turnTimedOut() bool {
+	return !l.turnDeadline.IsZero() && !time.Now().Before(l.turnDeadline)
+}
+
+// withTurnDeadline returns ctx with the given timeout, or less if the turn
+// ends sooner. A zero timeout leaves only the turn's deadline.
+func (l *Loop) withTurnDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	deadline := l.turnDeadline
+	if timeout > 0 {
+		if d := time.Now().Add(timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// runTool runs a tool within the tool and turn timeouts and reports whether
+// it timed out. A tool that outlives its context by more than toolStopGrace
+// is abandoned, with an empty result.
+func (l *Loop) runTool(ctx context.Context, tool *llm.Tool, input json.RawMessage) (llm.ToolOut, bool) {
+	runCtx, cancel := l.withTurnDeadline(ctx, l.limits.ToolTimeout)
+	defer cancel()
+
+	done := make(chan llm.ToolOut, 1)
+	go func() { done <- tool.Run(runCtx, input) }()
+	select {
+	case result := <-done:
+		return result, runCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+	case <-runCtx.Done():
+	}
+	timedOut := ctx.Err() == nil
+	select {
+	case result := <-done:
+		return result, timedOut
+	case <-time.After(toolStopGrace):
+		l.logger.Warn("abandoning tool that ignored its context", "name", tool.Name)
+		return llm.ToolOut{Error: runCtx.Err()}, timedOut
+	}
+}
+
+// endTimedOutTurn ends a turn whose timeout expired with an ErrorTypeTimeout
+// message. Messages recorded before it, such as tool results, are kept.
+func (l *Loop) endTimedOutTurn(ctx context.Context) {
+	l.logger.Warn("turn timed out", "timeout", l.limits.TurnTimeout)
+	timeoutMessage := llm.Message{
+		Role: llm.MessageRoleAssistant,
+		Content: []llm.Content{{
+			Type: llm.ContentTypeText,
+			Text: fmt.Sprintf("The turn timed out after %s; what the agent did so far is kept. Send a message to continue.", l.limits.TurnTimeout),
+		}},
+		EndOfTurn: true,
+		ErrorType: llm.ErrorTypeTimeout,
+	}
+	if err := l.recordMessage(ctx, timeoutMessage, llm.Usage{}); err != nil {
+		l.logger.Error("failed to record timeout message", "error", err)
+	}
+	l.turnDeadline = time.Time{}
+	l.endTurn(ctx)
+}
+
+// countToolRound counts a round of tool calls against the limits and returns
+// why the turn should pause, or "" to continue. The caller must hold l.mu.
+func (l *Loop) countToolRound(calls, results []llm.Content) string {
+	l.toolIterations++
+	if limit := l.limits.MaxToolIterations; limit > 0 && l.toolIterations >= limit {
+		return fmt.Sprintf("The agent made %d rounds of tool calls for this message without finishing.", l.toolIterations)
+	}
+
+	round := toolRoundKey(calls, results)
+	if round == l.lastToolRound {
+		l.toolRepeats++
+	} else {
+		l.lastToolRound = round
+		l.toolRepeats = 1
+	}
+	if limit := l.limits.MaxRepeatedToolCalls; limit > 0 && l.toolRepeats >= limit {
+		return fmt.Sprintf("The agent made the same tool calls and got the same results %d times in a row.", l.toolRepeats)
+	}
+	return ""
+}
+
+// toolRoundKey identifies a round of tool calls by the calls' names and
+// inputs and their results, ignoring the IDs and timings that always differ.
+func toolRoundKey(calls, results []llm.Content) string {
+	type call struct {
+		Name  string
+		Input json.RawMessage
+	}
+	type result struct {
+		Error  bool
+		Result []llm.Content
+	}
+	var key struct {
+		Calls   []call
+		Results []result
+	}
+	for _, c := range calls {
+		if c.Type == llm.ContentTypeToolUse {
+			key.Calls = append(key.Calls, call{c.ToolName, c.ToolInput})
+		}
+	}
+	for _, r := range results {
+		key.Results = append(key.Results, result{r.ToolError, r.ToolResult})
+	}
+	data, _ := json.Marshal(key)
+	return string(data)
+}
+
+// pauseStuck ends the turn with an ErrorTypeStuck message instead of sending
+// the tool results to the LLM. Resuming the turn sends them.
+func (l *Loop) pauseStuck(ctx context.Context, reason string) {
+	l.logger.Warn("pausing stuck turn", "reason", reason)
+	stuckMessage := llm.Message{
+		Role: llm.MessageRoleAssistant,
+		Content: []llm.Content{{
+			Type: llm.ContentTypeText,
+			Text: reason + " The turn is paused; resume it, optionally with guidance, to continue.",
+		}},
+		EndOfTurn: true,
+		ErrorType: llm.ErrorTypeStuck,
+	}
+	if err := l.recordMessage(ctx, stuckMessage, llm.Usage{}); err != nil {
+		l.logger.Error("failed to record stuck message", "error", err)
+	}
+	l.endTurn(ctx)
+}
+
+// truncateToolOutput shortens the text of a tool result to the tool output
+// budget, as counted by the model's tokenizer, keeping its start and end. The
+// full text is saved under the tool call's ID, and the truncated text says how
+// to read it.
+func (l *Loop) truncateToolOutput(ctx context.Context, toolUseID string, content []llm.Content) []llm.Content {
+	if l.toolOutputBudget <= 0 {
+		return content
+	}
+	var texts []string
+	for _, c := range content {
+		if c.Type == llm.ContentTypeText {
+			texts = append(texts, c.Text)
+		}
+	}
+	full := strings.Join(texts, "\n")
+	if l.tokenizer.Count(full) <= l.toolOutputBudget {
+		return content
+	}
+
+	note := "The full output was not saved."
+	if l.saveToolOutput != nil {
+		if err := l.saveToolOutput(ctx, toolUseID, full); err != nil {
+			l.logger.Error("failed to save full tool output", "id", toolUseID, "error", err)
+		} else {
+			note = fmt.Sprintf("Read the full output with the %s tool and id %q.", claudetool.ToolOutputName, toolUseID)
+		}
+	}
+	head := l.tokenizer.Head(full, l.toolOutputBudget*3/5)
+	tail := l.tokenizer.Tail(full, l.toolOutputBudget*2/5)
+	chars := utf8.RuneCountInString(full)
+	omitted := chars - utf8.RuneCountInString(head) - utf8.RuneCountInString(tail)
+	truncated := fmt.Sprintf("%s\n\n[... %d of %d characters omitted. %s ...]\n\n%s", head, omitted, chars, note, tail)
+
+	result := []llm.Content{{Type: llm.ContentTypeText, Text: truncated}}
+	for _, c := range content {
+		if c.Type != llm.ContentTypeText {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// saveImages saves the images generated by the LLM as attachments, setting
+// their Text to the path of the saved file. Their data is kept for the LLM.
+func (l *Loop) saveImages(ctx context.Context, content []llm.Content) {
+	if l.saveImage == nil {
+		return
+	}
+	for i, c := range content {
+		if c.Type != llm.ContentTypeImage || c.Data == "" {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(c.Data)
+		if err != nil {
+			l.logger.Error("failed to decode generated image", "error", err)
+			continue
+		}
+		path, err := l.saveImage(ctx, c.MediaType, data)
+		if err != nil {
+			l.logger.Error("failed to save generated image", "error", err)
+			continue
+		}
+		content[i].Text = path
+	}
+}
+
 // insertMissingToolResults fixes tool_result issues in the conversation history:
 //  1. Adds error results for tool_uses that were requested but not included in the next message.
 //     This can happen when a request is cancelled or fails after the LLM responds with tool_use
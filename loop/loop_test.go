@@ -2,19 +2,25 @@ package loop
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/gitstate"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/llmhttp"
+	"shelley.exe.dev/llm/tokenizer"
 )
 
 func TestNewLoop(t *testing.T) {
@@ -101,6 +107,43 @@ func TestPredictableService(t *testing.T) {
 	}
 }
 
+func TestPredictableServiceScript(t *testing.T) {
+	service := NewPredictableService()
+	service.Script("go",
+		ScriptToolCall("bash", map[string]string{"command": "ls"}),
+		ScriptError("overloaded"),
+	)
+	ctx := context.Background()
+
+	history := []llm.Message{llm.UserStringMessage("go")}
+	resp, err := service.Do(ctx, &llm.Request{Messages: history})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StopReason != llm.StopReasonToolUse || resp.Content[0].ToolName != "bash" || string(resp.Content[0].ToolInput) != `{"command":"ls"}` {
+		t.Fatalf("expected the scripted bash call, got %+v", resp.Content)
+	}
+
+	history = append(history, resp.ToMessage(), llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeToolResult, ToolUseID: resp.Content[0].ID}},
+	})
+	if _, err := service.Do(ctx, &llm.Request{Messages: history}); err == nil || !strings.Contains(err.Error(), "overloaded") {
+		t.Fatalf("expected the scripted error, got %v", err)
+	}
+
+	history = append(history, llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "?"}}})
+	if _, err := service.Do(ctx, &llm.Request{Messages: history}); err == nil || !strings.Contains(err.Error(), "no step 3") {
+		t.Fatalf("expected the script to run out, got %v", err)
+	}
+
+	// Other messages still get the built-in patterns
+	resp, err = service.Do(ctx, &llm.Request{Messages: []llm.Message{llm.UserStringMessage("echo: hi")}})
+	if err != nil || resp.Content[0].Text != "hi" {
+		t.Fatalf("expected the echo pattern, got %v, %v", resp, err)
+	}
+}
+
 func TestPredictableServiceEcho(t *testing.T) {
 	service := NewPredictableService()
 
@@ -276,6 +319,36 @@ func TestLoopWithPredictableService(t *testing.T) {
 	}
 }
 
+func TestLoopResumeTurn(t *testing.T) {
+	var recordedMessages []llm.Message
+	recordFunc := func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+		recordedMessages = append(recordedMessages, message)
+		return nil
+	}
+
+	// The history ends with a user message that was never answered
+	loop := NewLoop(Config{
+		LLM: NewPredictableService(),
+		History: []llm.Message{{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: "hello"}},
+		}},
+		Tools:         []*llm.Tool{},
+		RecordMessage: recordFunc,
+	})
+	loop.ResumeTurn()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := loop.Go(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context deadline exceeded, got %v", err)
+	}
+
+	if len(recordedMessages) != 1 || recordedMessages[0].Content[0].Text != "Well, hi there!" {
+		t.Fatalf("expected the resumed turn to be answered once, got %+v", recordedMessages)
+	}
+}
+
 func TestLoopWithTools(t *testing.T) {
 	var toolCalls []string
 
@@ -1572,6 +1645,85 @@ func (e *errorLLMService) MaxImageDimension() int {
 	return 2000
 }
 
+func TestProcessLLMRequestActivity(t *testing.T) {
+	// The tool calls a backend made itself are recorded before its response
+	activity := []llm.Message{
+		{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeToolUse, ID: "t1", ToolName: "Bash", ToolInput: json.RawMessage(`{}`)}}},
+		{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeToolResult, ToolUseID: "t1"}}},
+	}
+	service := &activityLLMService{activity: activity}
+
+	var recordedMessages []llm.Message
+	loop := NewLoop(Config{
+		LLM: service,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recordedMessages = append(recordedMessages, message)
+			return nil
+		},
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "go"}}})
+
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// tool call, tool result, response
+	if len(recordedMessages) != 3 {
+		t.Fatalf("expected 3 recorded messages, got %d", len(recordedMessages))
+	}
+	if recordedMessages[0].Content[0].ID != "t1" || recordedMessages[1].Content[0].ToolUseID != "t1" {
+		t.Errorf("expected the activity before the response, got %+v", recordedMessages)
+	}
+	if !recordedMessages[2].EndOfTurn {
+		t.Error("expected the response to end the turn")
+	}
+	if history := loop.GetHistory(); len(history) != 4 {
+		t.Errorf("expected the activity in the history, got %d messages", len(history))
+	}
+}
+
+func TestProcessLLMRequestWorkingDir(t *testing.T) {
+	// Backends that run tools themselves get the tools' current directory
+	dir := "/start"
+	service := &activityLLMService{}
+	loop := NewLoop(Config{
+		LLM:           service,
+		GetWorkingDir: func() string { return dir },
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error { return nil },
+	})
+	dir = "/moved"
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "go"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if service.workingDir != "/moved" {
+		t.Errorf("expected the request context to carry the working directory, got %q", service.workingDir)
+	}
+}
+
+// activityLLMService is a test LLM service that reports tool calls it made itself
+type activityLLMService struct {
+	activity   []llm.Message
+	workingDir string
+}
+
+func (s *activityLLMService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	s.workingDir = llmhttp.WorkingDirFromContext(ctx)
+	return &llm.Response{
+		Role:       llm.MessageRoleAssistant,
+		Content:    []llm.Content{{Type: llm.ContentTypeText, Text: "done"}},
+		StopReason: llm.StopReasonEndTurn,
+		Activity:   s.activity,
+	}, nil
+}
+
+func (s *activityLLMService) TokenContextWindow() int {
+	return 200000
+}
+
+func (s *activityLLMService) MaxImageDimension() int {
+	return 2000
+}
+
 func TestCheckGitStateChange(t *testing.T) {
 	// Create a test repo
 	tmpDir := t.TempDir()
@@ -1981,3 +2133,503 @@ func TestMaxTokensTruncation(t *testing.T) {
 //		t.Error("expected to find tool2 result in message 3")
 //	}
 //}
+
+func TestCheckTools(t *testing.T) {
+	var recordedMessages []llm.Message
+	checks := 0
+	loop := NewLoop(Config{
+		LLM:     NewPredictableService(),
+		History: []llm.Message{},
+		Tools: []*llm.Tool{{
+			Name:        "bash",
+			InputSchema: llm.MustSchema(`{"type": "object", "properties": {"command": {"type": "string"}}}`),
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				return llm.ToolOut{LLMContent: llm.TextContent("done")}
+			},
+		}},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recordedMessages = append(recordedMessages, message)
+			return nil
+		},
+		CheckTools: func(ctx context.Context) []llm.Content {
+			checks++
+			return []llm.Content{{Type: llm.ContentTypeText, Text: "lint failed"}}
+		},
+	})
+
+	loop.QueueUserMessage(llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: "bash: echo hello"}},
+	})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if checks != 1 {
+		t.Errorf("expected one check after the tool round, got %d", checks)
+	}
+	// The feedback is part of the tool result, not a message of its own
+	var results []llm.Content
+	for _, m := range recordedMessages {
+		for _, c := range m.Content {
+			if c.Type == llm.ContentTypeToolResult {
+				results = append(results, c)
+			}
+			if c.Type == llm.ContentTypeText && c.Text == "lint failed" {
+				t.Errorf("expected no feedback message, got %+v", m)
+			}
+		}
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected one tool result, got %+v", results)
+	}
+	if out := results[0].ToolResult; len(out) != 2 || out[0].Text != "done" || out[1].Text != "lint failed" {
+		t.Errorf("expected the feedback after the tool output, got %+v", out)
+	}
+}
+
+func TestLoopEvents(t *testing.T) {
+	var events []Event
+	testTool := &llm.Tool{
+		Name:        "bash",
+		InputSchema: llm.MustSchema(`{"type": "object", "properties": {"command": {"type": "string"}}}`),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			return llm.ToolOut{LLMContent: []llm.Content{{Type: llm.ContentTypeText, Text: "hello"}}}
+		},
+	}
+	loop := NewLoop(Config{
+		LLM:     NewPredictableService(),
+		History: []llm.Message{},
+		Tools:   []*llm.Tool{testTool},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			return nil
+		},
+		OnEvent: func(ctx context.Context, event Event) { events = append(events, event) },
+	})
+	loop.QueueUserMessage(llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: "bash: echo hello"}},
+	})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var types []EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []EventType{EventLLMRequestStart, EventLLMRequestEnd, EventToolStart, EventToolEnd, EventLLMRequestStart, EventLLMRequestEnd}
+	if !slices.Equal(types, want) {
+		t.Fatalf("event types = %v, want %v", types, want)
+	}
+	if events[0].ID == "" || events[0].ID != events[1].ID || events[0].ID == events[4].ID {
+		t.Errorf("LLM request IDs = %q, %q, %q", events[0].ID, events[1].ID, events[4].ID)
+	}
+	if events[1].Name != "predictable-v1" {
+		t.Errorf("LLM request model = %q", events[1].Name)
+	}
+	if tool := events[3]; tool.Name != "bash" || tool.ID != events[2].ID || tool.ID == "" || tool.Error != "" {
+		t.Errorf("tool end event = %+v", tool)
+	}
+}
+
+func TestLoopLimits(t *testing.T) {
+	var toolCalls int
+	bash := &llm.Tool{
+		Name:        "bash",
+		InputSchema: llm.MustSchema(`{"type": "object", "properties": {"command": {"type": "string"}}}`),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			toolCalls++
+			return llm.ToolOut{LLMContent: []llm.Content{{Type: llm.ContentTypeText, Text: "no such file"}}}
+		},
+	}
+	ls := func(dir string) ScriptStep { return ScriptToolCall("bash", map[string]string{"command": "ls " + dir}) }
+
+	for _, tt := range []struct {
+		name      string
+		limits    Limits
+		steps     []ScriptStep
+		wantCalls int
+	}{
+		{"repeated", Limits{MaxRepeatedToolCalls: 3}, []ScriptStep{ls("a"), ls("b"), ls("b"), ls("b"), ScriptText("done")}, 4},
+		{"iterations", Limits{MaxToolIterations: 2}, []ScriptStep{ls("a"), ls("b"), ScriptText("done")}, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			toolCalls = 0
+			var recorded []llm.Message
+			service := NewPredictableService()
+			service.Script("go", tt.steps...)
+			loop := NewLoop(Config{
+				LLM:    service,
+				Tools:  []*llm.Tool{bash},
+				Limits: tt.limits,
+				RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+					recorded = append(recorded, message)
+					return nil
+				},
+			})
+
+			loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "go"}}})
+			if err := loop.ProcessOneTurn(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			last := recorded[len(recorded)-1]
+			if toolCalls != tt.wantCalls || last.ErrorType != llm.ErrorTypeStuck || !last.EndOfTurn {
+				t.Fatalf("after %d tool calls the last message is %+v; want a stuck message after %d", toolCalls, last, tt.wantCalls)
+			}
+
+			// Resuming sends the pending tool results
+			if err := loop.ProcessOneTurn(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if last := recorded[len(recorded)-1]; last.ErrorType != llm.ErrorTypeNone || last.Content[0].Text != "done" {
+				t.Errorf("resumed turn ended with %+v", last)
+			}
+		})
+	}
+}
+
+func TestLoopToolTimeout(t *testing.T) {
+	toolStopGrace = 50 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	tools := []*llm.Tool{
+		{
+			Name: "slow",
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				<-ctx.Done()
+				return llm.ToolOut{LLMContent: []llm.Content{{Type: llm.ContentTypeText, Text: "partial"}}}
+			},
+		},
+		{
+			Name: "stubborn",
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				<-release
+				return llm.ToolOut{}
+			},
+		},
+	}
+
+	var recorded []llm.Message
+	service := NewPredictableService()
+	service.Script("go", ScriptToolCall("slow", map[string]string{}), ScriptToolCall("stubborn", map[string]string{}), ScriptText("done"))
+	loop := NewLoop(Config{
+		LLM:    service,
+		Tools:  tools,
+		Limits: Limits{ToolTimeout: 20 * time.Millisecond},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "go"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []llm.Content
+	for _, msg := range recorded {
+		for _, c := range msg.Content {
+			if c.Type == llm.ContentTypeToolResult {
+				results = append(results, c)
+			}
+		}
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d tool results, want 2", len(results))
+	}
+	if r := results[0]; !r.ToolError || r.ToolResult[0].Text != "partial" || !strings.Contains(r.ToolResult[1].Text, "timed out") {
+		t.Errorf("slow tool result = %+v, want its partial output and a timeout note", r)
+	}
+	if r := results[1]; !r.ToolError || !strings.Contains(r.ToolResult[len(r.ToolResult)-1].Text, "timed out") {
+		t.Errorf("stubborn tool result = %+v, want a timeout", r)
+	}
+	if last := recorded[len(recorded)-1]; last.Content[0].Text != "done" {
+		t.Errorf("turn ended with %+v, want the LLM to continue after the timeouts", last)
+	}
+}
+
+func TestLoopTurnTimeout(t *testing.T) {
+	var recorded []llm.Message
+	service := NewPredictableService()
+	loop := NewLoop(Config{
+		LLM:    service,
+		Limits: Limits{TurnTimeout: 50 * time.Millisecond},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "delay: 5"}}})
+	start := time.Now()
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("turn took %s, want it stopped by the timeout", elapsed)
+	}
+	if len(recorded) != 1 || recorded[0].ErrorType != llm.ErrorTypeTimeout || !recorded[0].EndOfTurn {
+		t.Errorf("recorded %+v, want a timeout message", recorded)
+	}
+}
+
+func TestLoopToolOutputTruncation(t *testing.T) {
+	long := strings.Repeat("0123456789", 100)
+	tools := []*llm.Tool{{
+		Name: "long",
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			return llm.ToolOut{LLMContent: llm.TextContent(long)}
+		},
+	}}
+
+	saved := map[string]string{}
+	var recorded []llm.Message
+	service := NewPredictableService()
+	service.Script("go", ScriptToolCall("long", map[string]string{}), ScriptText("done"))
+	loop := NewLoop(Config{
+		LLM:              service,
+		Tools:            tools,
+		ToolOutputBudget: 50,
+		SaveToolOutput: func(ctx context.Context, toolUseID, output string) error {
+			saved[toolUseID] = output
+			return nil
+		},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "go"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var result llm.Content
+	for _, msg := range recorded {
+		for _, c := range msg.Content {
+			if c.Type == llm.ContentTypeToolResult {
+				result = c
+			}
+		}
+	}
+	if saved[result.ToolUseID] != long {
+		t.Fatalf("saved outputs = %v, want the full output under %q", saved, result.ToolUseID)
+	}
+	text := result.ToolResult[0].Text
+	if !strings.HasPrefix(text, long[:120]) || !strings.HasSuffix(text, long[len(long)-80:]) ||
+		!strings.Contains(text, "800 of 1000 characters omitted") || !strings.Contains(text, result.ToolUseID) {
+		t.Errorf("truncated result = %q", text)
+	}
+}
+
+func TestLoopToolOutputTruncationCJK(t *testing.T) {
+	// 300 characters of Japanese are 900 bytes but about 360 Claude tokens
+	long := strings.Repeat("日本語", 100)
+	tools := []*llm.Tool{{
+		Name: "long",
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			return llm.ToolOut{LLMContent: llm.TextContent(long)}
+		},
+	}}
+
+	var recorded []llm.Message
+	service := NewPredictableService()
+	service.Script("go", ScriptToolCall("long", map[string]string{}), ScriptText("done"))
+	loop := NewLoop(Config{
+		LLM:              service,
+		Tools:            tools,
+		ToolOutputBudget: 50,
+		Tokenizer:        tokenizer.Claude,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "go"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var text string
+	for _, msg := range recorded {
+		for _, c := range msg.Content {
+			if c.Type == llm.ContentTypeToolResult {
+				text = c.ToolResult[0].Text
+			}
+		}
+	}
+	if !utf8.ValidString(text) || !strings.HasPrefix(text, string([]rune(long)[:25])) ||
+		!strings.HasSuffix(text, string([]rune(long)[300-16:])) || !strings.Contains(text, "259 of 300 characters omitted") {
+		t.Errorf("truncated result = %q", text)
+	}
+}
+
+// imageLLMService is a test LLM service that responds with a generated image
+type imageLLMService struct{}
+
+func (s *imageLLMService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	return &llm.Response{
+		Role: llm.MessageRoleAssistant,
+		Content: []llm.Content{
+			{Type: llm.ContentTypeText, Text: "a logo"},
+			{Type: llm.ContentTypeImage, MediaType: "image/png", Data: base64.StdEncoding.EncodeToString([]byte("png"))},
+		},
+		StopReason: llm.StopReasonEndTurn,
+	}, nil
+}
+
+func (s *imageLLMService) TokenContextWindow() int {
+	return 200000
+}
+
+func (s *imageLLMService) MaxImageDimension() int {
+	return 2000
+}
+
+func TestLoopSaveImage(t *testing.T) {
+	var saved []byte
+	var recorded []llm.Message
+	loop := NewLoop(Config{
+		LLM: &imageLLMService{},
+		SaveImage: func(ctx context.Context, mediaType string, data []byte) (string, error) {
+			saved = data
+			return "/tmp/generated.png", nil
+		},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "draw"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(saved) != "png" {
+		t.Errorf("saved image = %q, want the decoded data", saved)
+	}
+	image := recorded[len(recorded)-1].Content[1]
+	// The data is kept so that the image can be sent again to edit it
+	if image.Type != llm.ContentTypeImage || image.Text != "/tmp/generated.png" || image.Data == "" {
+		t.Errorf("recorded image = %+v, want its path and data", image)
+	}
+}
+
+func TestLoopConcurrentReadOnlyTools(t *testing.T) {
+	for _, concurrent := range []bool{false, true} {
+		var mu sync.Mutex
+		running, maxRunning := 0, 0
+		var order []string
+		run := func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			running--
+			order = append(order, string(input))
+			mu.Unlock()
+			return llm.ToolOut{LLMContent: llm.TextContent("ran " + string(input))}
+		}
+		tools := []*llm.Tool{
+			{Name: "read", Run: run, ReadOnly: func(json.RawMessage) bool { return true }},
+			{Name: "write", Run: run},
+		}
+		call := func(id, name string) llm.Content {
+			return llm.Content{Type: llm.ContentTypeToolUse, ID: id, ToolName: name, ToolInput: json.RawMessage(`"` + id + `"`)}
+		}
+		var results []llm.Content
+		service := &customPredictableService{
+			responseFunc: func(req *llm.Request) (*llm.Response, error) {
+				last := req.Messages[len(req.Messages)-1]
+				if last.Content[0].Type == llm.ContentTypeToolResult {
+					results = last.Content
+					return &llm.Response{Role: llm.MessageRoleAssistant, StopReason: llm.StopReasonEndTurn, Content: llm.TextContent("done")}, nil
+				}
+				return &llm.Response{
+					Role:       llm.MessageRoleAssistant,
+					StopReason: llm.StopReasonToolUse,
+					Content:    []llm.Content{call("r1", "read"), call("r2", "read"), call("r3", "read"), call("w1", "write"), call("r4", "read")},
+				}, nil
+			},
+		}
+		loop := NewLoop(Config{
+			LLM:                     service,
+			Tools:                   tools,
+			RecordMessage:           func(ctx context.Context, message llm.Message, usage llm.Usage) error { return nil },
+			ConcurrentReadOnlyTools: concurrent,
+		})
+		loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: llm.TextContent("go")})
+		if err := loop.ProcessOneTurn(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the read-only calls before the write overlap
+		if want := map[bool]int{false: 1, true: 3}[concurrent]; maxRunning != want {
+			t.Errorf("concurrent=%v: %d tools ran at once, want %d", concurrent, maxRunning, want)
+		}
+		if len(order) != 5 || order[3] != `"w1"` || order[4] != `"r4"` {
+			t.Errorf("concurrent=%v: tools ran in order %v", concurrent, order)
+		}
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.ToolUseID)
+		}
+		if !slices.Equal(ids, []string{"r1", "r2", "r3", "w1", "r4"}) {
+			t.Errorf("concurrent=%v: results in order %v", concurrent, ids)
+		}
+	}
+}
+
+func TestLoopWaitOnline(t *testing.T) {
+	errUnreachable := errors.New("dial tcp: connection refused")
+	var failures []error // returned by the next requests
+	service := &customPredictableService{
+		responseFunc: func(req *llm.Request) (*llm.Response, error) {
+			if len(failures) > 0 {
+				err := failures[0]
+				failures = failures[1:]
+				return nil, err
+			}
+			return &llm.Response{Role: llm.MessageRoleAssistant, StopReason: llm.StopReasonEndTurn, Content: llm.TextContent("back")}, nil
+		},
+	}
+	var waits int
+	var recorded []llm.Message
+	loop := NewLoop(Config{
+		LLM: service,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+		WaitOnline: func(ctx context.Context, err error) bool {
+			waits++
+			return errors.Is(err, errUnreachable)
+		},
+	})
+
+	// The request is sent again until the provider is reachable
+	failures = []error{errUnreachable, errUnreachable}
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: llm.TextContent("hi")})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waits != 2 {
+		t.Errorf("waited %d times, want 2", waits)
+	}
+	if len(recorded) != 1 || recorded[0].ErrorType != llm.ErrorTypeNone || recorded[0].Content[0].Text != "back" {
+		t.Errorf("expected only the response to be recorded, got %+v", recorded)
+	}
+
+	// Other errors end the turn as before
+	waits, recorded = 0, nil
+	failures = []error{errors.New("status 400")}
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: llm.TextContent("again")})
+	if err := loop.ProcessOneTurn(context.Background()); err == nil {
+		t.Error("expected the error of a reachable provider")
+	}
+	if waits != 1 || len(recorded) != 1 || recorded[0].ErrorType != llm.ErrorTypeLLMRequest {
+		t.Errorf("expected an error message after one wait, got %d waits and %+v", waits, recorded)
+	}
+}
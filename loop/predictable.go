@@ -24,6 +24,8 @@ import (
 //   - "subagent: <slug> <prompt>" - triggers subagent tool
 //   - "delay: <seconds>" - delays response by specified seconds
 //   - See Do() method for complete list of supported patterns
+//
+// Tests that need a multi-turn behavior script it with Script instead.
 type PredictableService struct {
 	// TokenContextWindow size
 	tokenContextWindow int
@@ -31,6 +33,61 @@ type PredictableService struct {
 	// Recent requests for testing inspection
 	recentRequests []*llm.Request
 	responseDelay  time.Duration
+	// Scripts by the user message that starts them
+	scripts map[string][]ScriptStep
+	// Results of submitted batches by batch ID
+	batches map[string][]llm.BatchResult
+}
+
+// ScriptStep is one response of a script; see Script.
+type ScriptStep struct {
+	// Text is the text of the response
+	Text string
+	// ToolName and ToolInput, if set, make the response a call of that tool;
+	// ToolInput is marshalled to JSON
+	ToolName  string
+	ToolInput any
+	// Err, if set, makes the request fail with this error instead
+	Err string
+	// Delay is how long to wait before responding, or until the request is
+	// cancelled
+	Delay time.Duration
+}
+
+// ScriptText returns a step that responds with text and ends the turn.
+func ScriptText(text string) ScriptStep {
+	return ScriptStep{Text: text}
+}
+
+// ScriptToolCall returns a step that calls a tool with input.
+func ScriptToolCall(name string, input any) ScriptStep {
+	return ScriptStep{ToolName: name, ToolInput: input}
+}
+
+// ScriptError returns a step that fails the request with msg.
+func ScriptError(msg string) ScriptStep {
+	return ScriptStep{Err: msg}
+}
+
+// After returns the step delayed by d.
+func (step ScriptStep) After(d time.Duration) ScriptStep {
+	step.Delay = d
+	return step
+}
+
+// Script makes the user message trigger a scripted series of responses: the
+// first request after trigger gets the first step, the request after that
+// (with the result of a scripted tool call, say) gets the second, and so on.
+// Steps are counted from the assistant messages following trigger in the
+// request, so retries of a failed step get the same step again, and concurrent
+// conversations each run their own copy of the script.
+func (s *PredictableService) Script(trigger string, steps ...ScriptStep) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scripts == nil {
+		s.scripts = make(map[string][]ScriptStep)
+	}
+	s.scripts[trigger] = steps
 }
 
 // NewPredictableService creates a new predictable LLM service
@@ -58,6 +115,48 @@ func (s *PredictableService) MaxImageDimension() int {
 	return 2000
 }
 
+// SubmitBatch answers the items right away, as Do would; the batch is ended
+// when first polled.
+func (s *PredictableService) SubmitBatch(ctx context.Context, items []llm.BatchItem) (string, error) {
+	results := make([]llm.BatchResult, 0, len(items))
+	for _, item := range items {
+		resp, err := s.Do(ctx, item.Request)
+		results = append(results, llm.BatchResult{CustomID: item.CustomID, Response: resp, Err: err})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.batches == nil {
+		s.batches = make(map[string][]llm.BatchResult)
+	}
+	id := fmt.Sprintf("predictable-batch-%d", len(s.batches)+1)
+	s.batches[id] = results
+	return id, nil
+}
+
+// PollBatch returns the results of a submitted batch.
+func (s *PredictableService) PollBatch(ctx context.Context, batchID string) (*llm.BatchStatus, []llm.BatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results, ok := s.batches[batchID]
+	if !ok {
+		return nil, nil, fmt.Errorf("batch %s not found", batchID)
+	}
+	status := &llm.BatchStatus{Status: "ended", Ended: true, Total: len(results)}
+	for _, r := range results {
+		if r.Err != nil {
+			status.Failed++
+		} else {
+			status.Succeeded++
+		}
+	}
+	return status, results, nil
+}
+
+// CancelBatch does nothing, since batches end as soon as they are submitted.
+func (s *PredictableService) CancelBatch(ctx context.Context, batchID string) error {
+	return nil
+}
+
 // Do processes a request and returns a predictable response based on the input text
 func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
 	// Store request for testing inspection
@@ -81,6 +180,13 @@ func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Res
 	// Calculate input token count based on the request content
 	inputTokens := s.countRequestTokens(req)
 
+	if step, ok, err := s.scriptStep(req); ok || err != nil {
+		if err != nil {
+			return nil, err
+		}
+		return s.doScriptStep(ctx, step, inputTokens)
+	}
+
 	// Extract the text content from the last user message
 	var inputText string
 	if len(req.Messages) > 0 {
@@ -176,6 +282,16 @@ func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Res
 			return s.makeSubagentToolResponse(slug, prompt, inputTokens), nil
 		}
 
+		if strings.HasPrefix(inputText, "remember: ") {
+			fact := strings.TrimPrefix(inputText, "remember: ")
+			return s.makeMemoryToolResponse(fact, inputTokens), nil
+		}
+
+		if strings.HasPrefix(inputText, "plan: ") {
+			plan := strings.TrimPrefix(inputText, "plan: ")
+			return s.makeSubmitPlanToolResponse(plan, inputTokens), nil
+		}
+
 		if strings.HasPrefix(inputText, "delay: ") {
 			delayStr := strings.TrimPrefix(inputText, "delay: ")
 			delaySeconds, err := strconv.ParseFloat(delayStr, 64)
@@ -195,6 +311,73 @@ func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Res
 	}
 }
 
+// scriptStep returns the step of the script triggered by the latest user text
+// message of req, if any.
+func (s *PredictableService) scriptStep(req *llm.Request) (ScriptStep, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.scripts) == 0 {
+		return ScriptStep{}, false, nil
+	}
+
+	turn := 0
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		msg := req.Messages[i]
+		if msg.Role == llm.MessageRoleAssistant {
+			turn++
+			continue
+		}
+		for _, content := range msg.Content {
+			if content.Type != llm.ContentTypeText {
+				continue
+			}
+			trigger := strings.TrimSpace(content.Text)
+			steps, ok := s.scripts[trigger]
+			if !ok {
+				return ScriptStep{}, false, nil
+			}
+			if turn >= len(steps) {
+				return ScriptStep{}, false, fmt.Errorf("predictable: script %q has no step %d", trigger, turn+1)
+			}
+			return steps[turn], true, nil
+		}
+	}
+	return ScriptStep{}, false, nil
+}
+
+// doScriptStep responds as step says.
+func (s *PredictableService) doScriptStep(ctx context.Context, step ScriptStep, inputTokens uint64) (*llm.Response, error) {
+	if step.Delay > 0 {
+		select {
+		case <-time.After(step.Delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if step.Err != "" {
+		return nil, fmt.Errorf("predictable error: %s", step.Err)
+	}
+	resp := s.makeResponse(step.Text, inputTokens)
+	if step.ToolName == "" {
+		return resp, nil
+	}
+	toolInput, err := json.Marshal(step.ToolInput)
+	if err != nil {
+		return nil, fmt.Errorf("predictable: bad input for tool %s: %w", step.ToolName, err)
+	}
+	if step.Text == "" {
+		resp.Content = nil
+	}
+	resp.Content = append(resp.Content, llm.Content{
+		ID:        fmt.Sprintf("tool_%d", time.Now().UnixNano()),
+		Type:      llm.ContentTypeToolUse,
+		ToolName:  step.ToolName,
+		ToolInput: toolInput,
+	})
+	resp.StopReason = llm.StopReasonToolUse
+	return resp, nil
+}
+
 // makeMaxTokensResponse creates a response that simulates hitting max_tokens limit
 func (s *PredictableService) makeMaxTokensResponse(text string, inputTokens uint64) *llm.Response {
 	outputTokens := uint64(len(text) / 4)
@@ -567,6 +750,76 @@ func (s *PredictableService) makeSubagentToolResponse(slug, prompt string, input
 	}
 }
 
+// makeMemoryToolResponse creates a response that remembers a project fact
+func (s *PredictableService) makeMemoryToolResponse(fact string, inputTokens uint64) *llm.Response {
+	toolInputData := map[string]any{
+		"action":  "remember",
+		"content": fact,
+	}
+	toolInputBytes, _ := json.Marshal(toolInputData)
+	toolInput := json.RawMessage(toolInputBytes)
+	responseText := "I'll remember that."
+	outputTokens := uint64(len(responseText)/4 + len(toolInputBytes)/4)
+	if outputTokens == 0 {
+		outputTokens = 1
+	}
+	return &llm.Response{
+		ID:    fmt.Sprintf("pred-memory-%d", time.Now().UnixNano()),
+		Type:  "message",
+		Role:  llm.MessageRoleAssistant,
+		Model: "predictable-v1",
+		Content: []llm.Content{
+			{Type: llm.ContentTypeText, Text: responseText},
+			{
+				ID:        fmt.Sprintf("tool_%d", time.Now().UnixNano()%1000),
+				Type:      llm.ContentTypeToolUse,
+				ToolName:  "memory",
+				ToolInput: toolInput,
+			},
+		},
+		StopReason: llm.StopReasonToolUse,
+		Usage: llm.Usage{
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+			CostUSD:      0.0,
+		},
+	}
+}
+
+func (s *PredictableService) makeSubmitPlanToolResponse(plan string, inputTokens uint64) *llm.Response {
+	toolInputData := map[string]any{
+		"plan": plan,
+	}
+	toolInputBytes, _ := json.Marshal(toolInputData)
+	toolInput := json.RawMessage(toolInputBytes)
+	responseText := "Here is my plan."
+	outputTokens := uint64(len(responseText)/4 + len(toolInputBytes)/4)
+	if outputTokens == 0 {
+		outputTokens = 1
+	}
+	return &llm.Response{
+		ID:    fmt.Sprintf("pred-plan-%d", time.Now().UnixNano()),
+		Type:  "message",
+		Role:  llm.MessageRoleAssistant,
+		Model: "predictable-v1",
+		Content: []llm.Content{
+			{Type: llm.ContentTypeText, Text: responseText},
+			{
+				ID:        fmt.Sprintf("tool_%d", time.Now().UnixNano()%1000),
+				Type:      llm.ContentTypeToolUse,
+				ToolName:  "submit_plan",
+				ToolInput: toolInput,
+			},
+		},
+		StopReason: llm.StopReasonToolUse,
+		Usage: llm.Usage{
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+			CostUSD:      0.0,
+		},
+	}
+}
+
 // makeToolSmorgasbordResponse creates a response that uses all available tool types
 func (s *PredictableService) makeToolSmorgasbordResponse(inputTokens uint64) *llm.Response {
 	baseNano := time.Now().UnixNano()
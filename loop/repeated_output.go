@@ -0,0 +1,163 @@
+package loop
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/diff"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/llm"
+)
+
+// minRepeatedOutput is the length, in characters, below which the output of
+// a repeated tool call is sent again as is; short outputs cost little.
+const minRepeatedOutput = 1000
+
+// repeatedOutputMarker starts the text sent instead of a repeated output, so
+// that it is not taken for a full output when the history is reloaded.
+const repeatedOutputMarker = "[Repeated tool call:"
+
+// toolOutputRef is the last output of a tool call sent to the LLM in full.
+type toolOutputRef struct {
+	toolUseID string
+	hash      [sha256.Size]byte
+	text      string
+	// truncated is set if only part of the output was sent
+	truncated bool
+	// index is the position in the history of the message holding the output
+	index int
+}
+
+// truncatedOutput matches the note truncateToolOutput puts in the middle of
+// a shortened output.
+var truncatedOutput = regexp.MustCompile(`\n\n\[\.\.\. \d+ of \d+ characters omitted\. `)
+
+// toolCallKey identifies calls of a tool with the same input.
+func toolCallKey(name string, input json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, input); err != nil {
+		return name + "\x00" + string(input)
+	}
+	return name + "\x00" + buf.String()
+}
+
+// outputText returns the text of a tool output, or false if it has other
+// content such as images.
+func outputText(content []llm.Content) (string, bool) {
+	var texts []string
+	for _, c := range content {
+		if c.Type != llm.ContentTypeText {
+			return "", false
+		}
+		texts = append(texts, c.Text)
+	}
+	return strings.Join(texts, "\n"), true
+}
+
+// seedToolOutputs remembers the outputs of the tool calls in the history, so
+// that repeats of them are recognized after a reload.
+func (l *Loop) seedToolOutputs(history []llm.Message) {
+	calls := make(map[string]llm.Content) // tool uses by ID
+	for i, msg := range history {
+		if msg.ExcludedFromContext {
+			continue
+		}
+		for _, c := range msg.Content {
+			switch c.Type {
+			case llm.ContentTypeToolUse:
+				calls[c.ID] = c
+			case llm.ContentTypeToolResult:
+				if call, ok := calls[c.ToolUseID]; ok && !c.ToolError {
+					l.rememberToolOutput(call, c.ToolResult, i)
+				}
+			}
+		}
+	}
+}
+
+// rememberToolOutput remembers the output of a tool call as sent to the LLM,
+// after any truncation, in the message at index in the history. Notes sent
+// instead of repeated outputs are not remembered, so that the earlier output
+// stays the last one sent in full and diffs do not build on each other.
+func (l *Loop) rememberToolOutput(call llm.Content, content []llm.Content, index int) {
+	if l.toolOutputs == nil {
+		return
+	}
+	text, ok := outputText(content)
+	if !ok || len(text) < minRepeatedOutput || strings.HasPrefix(text, repeatedOutputMarker) {
+		return
+	}
+	l.toolOutputs[toolCallKey(call.ToolName, call.ToolInput)] = toolOutputRef{
+		toolUseID: call.ID,
+		hash:      sha256.Sum256([]byte(text)),
+		text:      text,
+		truncated: truncatedOutput.MatchString(text),
+		index:     index,
+	}
+}
+
+// inContext reports whether an earlier output is still in the history sent
+// to the LLM. Outputs of the tool calls being answered are at the index of
+// the next message.
+func (l *Loop) inContext(ref toolOutputRef) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ref.index == len(l.history) {
+		return true
+	}
+	if ref.index > len(l.history) || l.history[ref.index].ExcludedFromContext {
+		return false
+	}
+	for _, c := range l.history[ref.index].Content {
+		if c.Type == llm.ContentTypeToolResult && c.ToolUseID == ref.toolUseID {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceRepeatedOutput replaces the output of a tool call made before with
+// the same input, such as reading a file again, by a note that it is
+// unchanged or by a diff from the earlier output, which is still in the
+// history. The earlier output is the last one sent in full; if it was
+// truncated or is no longer in the history, the output is sent as is. The
+// full output is saved for the tool_output tool.
+func (l *Loop) replaceRepeatedOutput(ctx context.Context, call llm.Content, content []llm.Content) []llm.Content {
+	if l.toolOutputs == nil {
+		return content
+	}
+	text, ok := outputText(content)
+	if !ok || len(text) < minRepeatedOutput {
+		return content
+	}
+	prev, seen := l.toolOutputs[toolCallKey(call.ToolName, call.ToolInput)]
+	if !seen || prev.truncated || !l.inContext(prev) {
+		return content
+	}
+
+	var replaced string
+	if sha256.Sum256([]byte(text)) == prev.hash {
+		replaced = fmt.Sprintf("%s the output is unchanged from tool call %s above, so it is not repeated.]", repeatedOutputMarker, prev.toolUseID)
+	} else {
+		var buf strings.Builder
+		if err := diff.Text("earlier", "now", prev.text, text, &buf); err != nil || buf.Len() > len(text)/2 {
+			return content
+		}
+		replaced = fmt.Sprintf("%s the output changed from tool call %s above as follows.]\n\n%s", repeatedOutputMarker, prev.toolUseID, buf.String())
+	}
+	if l.saveToolOutput != nil {
+		if err := l.saveToolOutput(ctx, call.ID, text); err != nil {
+			l.logger.Error("failed to save full tool output", "id", call.ID, "error", err)
+		} else {
+			replaced += fmt.Sprintf("\n\n[Read the full output with the %s tool and id %q.]", claudetool.ToolOutputName, call.ID)
+		}
+	}
+	l.logger.Debug("replaced repeated tool output", "name", call.ToolName, "id", call.ID, "earlier", prev.toolUseID, "chars", len(text), "sent", len(replaced))
+	return []llm.Content{{Type: llm.ContentTypeText, Text: replaced}}
+}
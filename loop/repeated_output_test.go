@@ -0,0 +1,149 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestReplaceRepeatedOutput(t *testing.T) {
+	ctx := context.Background()
+	saved := make(map[string]string)
+	l := NewLoop(Config{
+		DedupToolOutputs: true,
+		SaveToolOutput: func(ctx context.Context, toolUseID, output string) error {
+			saved[toolUseID] = output
+			return nil
+		},
+	})
+	call := func(id, input string) llm.Content {
+		return llm.Content{Type: llm.ContentTypeToolUse, ID: id, ToolName: "bash", ToolInput: json.RawMessage(input)}
+	}
+	var lines []string
+	for i := range 100 {
+		lines = append(lines, fmt.Sprintf("line %d of the file", i))
+	}
+	file := strings.Join(lines, "\n")
+	output := func(text string) []llm.Content { return []llm.Content{{Type: llm.ContentTypeText, Text: text}} }
+	// send handles an output as toolCallResult does, without truncation
+	send := func(call llm.Content, content []llm.Content) []llm.Content {
+		content = l.replaceRepeatedOutput(ctx, call, content)
+		l.rememberToolOutput(call, content, len(l.history))
+		return content
+	}
+
+	// The first read is sent in full
+	if got := send(call("t1", `{"command": "cat f"}`), output(file)); got[0].Text != file {
+		t.Fatalf("first read replaced: %q", got[0].Text)
+	}
+
+	// Reading it again unchanged, with differently formatted input, sends a note
+	got := send(call("t2", `{"command":"cat f"}`), output(file))
+	if !strings.HasPrefix(got[0].Text, repeatedOutputMarker) || !strings.Contains(got[0].Text, "unchanged from tool call t1") {
+		t.Errorf("unchanged read = %q", got[0].Text)
+	}
+	if saved["t2"] != file || !strings.Contains(got[0].Text, `"t2"`) {
+		t.Errorf("full output not saved for t2: %q", got[0].Text)
+	}
+
+	// A small change is sent as a diff from the full output
+	changed := strings.Replace(file, "line 50 of", "LINE 50 OF", 1)
+	got = send(call("t3", `{"command": "cat f"}`), output(changed))
+	if !strings.Contains(got[0].Text, "changed from tool call t1") || !strings.Contains(got[0].Text, "+LINE 50 OF the file") || len(got[0].Text) > len(changed)/2 {
+		t.Errorf("changed read = %q", got[0].Text)
+	}
+
+	// A big change is sent in full, and later diffs are from it
+	rewritten := strings.ReplaceAll(file, "line", "row")
+	if got = send(call("t4", `{"command": "cat f"}`), output(rewritten)); got[0].Text != rewritten {
+		t.Errorf("rewritten read replaced: %q", got[0].Text)
+	}
+	if got = send(call("t5", `{"command": "cat f"}`), output(rewritten)); !strings.Contains(got[0].Text, "unchanged from tool call t4") {
+		t.Errorf("read after rewrite = %q", got[0].Text)
+	}
+
+	// Other calls, short outputs and images are sent as is
+	if got = send(call("t6", `{"command": "cat g"}`), output(file)); got[0].Text != file {
+		t.Errorf("other file replaced: %q", got[0].Text)
+	}
+	for range 2 {
+		if got = send(call("t7", `{"command": "pwd"}`), output("/tmp")); got[0].Text != "/tmp" {
+			t.Errorf("short output replaced: %q", got[0].Text)
+		}
+	}
+	image := append(output(file), llm.Content{Type: llm.ContentTypeImage, MediaType: "image/png", Data: "AAAA"})
+	if got = send(call("t8", `{"command": "cat f"}`), image); len(got) != 2 {
+		t.Errorf("output with image replaced: %+v", got)
+	}
+}
+
+func TestRepeatedOutputAfterReload(t *testing.T) {
+	var lines []string
+	for i := range 100 {
+		lines = append(lines, fmt.Sprintf("line %d of the file", i))
+	}
+	file := strings.Join(lines, "\n")
+	input := json.RawMessage(`{"command": "cat f"}`)
+	history := []llm.Message{
+		{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeToolUse, ID: "t1", ToolName: "bash", ToolInput: input}}},
+		{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeToolResult, ToolUseID: "t1", ToolResult: []llm.Content{{Type: llm.ContentTypeText, Text: file}}}}},
+		{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeToolUse, ID: "t2", ToolName: "bash", ToolInput: input}}},
+		{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeToolResult, ToolUseID: "t2", ToolResult: []llm.Content{{Type: llm.ContentTypeText, Text: repeatedOutputMarker + " the output is unchanged from tool call t1 above, so it is not repeated.]"}}}}},
+	}
+	l := NewLoop(Config{History: history, DedupToolOutputs: true})
+	call := llm.Content{Type: llm.ContentTypeToolUse, ID: "t3", ToolName: "bash", ToolInput: input}
+	got := l.replaceRepeatedOutput(context.Background(), call, []llm.Content{{Type: llm.ContentTypeText, Text: file}})
+	if !strings.Contains(got[0].Text, "unchanged from tool call t1") {
+		t.Errorf("read after reload = %q", got[0].Text)
+	}
+
+	// Without deduplication outputs are always sent in full
+	l = NewLoop(Config{History: history})
+	if got = l.replaceRepeatedOutput(context.Background(), call, []llm.Content{{Type: llm.ContentTypeText, Text: file}}); got[0].Text != file {
+		t.Errorf("read without deduplication = %q", got[0].Text)
+	}
+}
+
+func TestRepeatedOutputNotInContext(t *testing.T) {
+	var lines []string
+	for i := range 100 {
+		lines = append(lines, fmt.Sprintf("line %d of the file", i))
+	}
+	file := strings.Join(lines, "\n")
+	input := json.RawMessage(`{"command": "cat f"}`)
+	use := func(id string) llm.Message {
+		return llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeToolUse, ID: id, ToolName: "bash", ToolInput: input}}}
+	}
+	result := func(id, text string) llm.Message {
+		return llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeToolResult, ToolUseID: id, ToolResult: []llm.Content{{Type: llm.ContentTypeText, Text: text}}}}}
+	}
+	call := llm.Content{Type: llm.ContentTypeToolUse, ID: "t9", ToolName: "bash", ToolInput: input}
+	read := func(history []llm.Message) string {
+		l := NewLoop(Config{History: history, DedupToolOutputs: true})
+		return l.replaceRepeatedOutput(context.Background(), call, []llm.Content{{Type: llm.ContentTypeText, Text: file}})[0].Text
+	}
+
+	// The model saw only part of a truncated output
+	truncated := file[:500] + "\n\n[... 900 of 2000 characters omitted. The full output was not saved. ...]\n\n" + file[len(file)-500:]
+	if got := read([]llm.Message{use("t1"), result("t1", truncated)}); got != file {
+		t.Errorf("read after a truncated output = %q", got)
+	}
+
+	// Outputs excluded from the context are not referred to
+	excluded := result("t1", file)
+	excluded.ExcludedFromContext = true
+	if got := read([]llm.Message{use("t1"), excluded}); got != file {
+		t.Errorf("read after an excluded output = %q", got)
+	}
+
+	// nor are outputs that have left the history
+	l := NewLoop(Config{History: []llm.Message{use("t1"), result("t1", file)}, DedupToolOutputs: true})
+	l.history = []llm.Message{{Role: llm.MessageRoleUser, Content: llm.TextContent("summary")}, use("t2"), result("t2", "short")}
+	if got := l.replaceRepeatedOutput(context.Background(), call, []llm.Content{{Type: llm.ContentTypeText, Text: file}}); got[0].Text != file {
+		t.Errorf("read after the output left the history = %q", got[0].Text)
+	}
+}
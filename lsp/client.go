@@ -0,0 +1,421 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diagnosticsSettle is how long to wait for further diagnostics after a
+// server publishes some; servers often publish in several passes.
+const diagnosticsSettle = 300 * time.Millisecond
+
+// ErrClosed is returned for requests to a client whose server has exited.
+var ErrClosed = errors.New("language server closed")
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *responseError  `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("language server error %d: %s", e.Code, e.Message)
+}
+
+type document struct {
+	version int
+	text    string
+}
+
+// Client is a connection to a language server for one workspace.
+type Client struct {
+	conn       io.ReadWriteCloser
+	languageID func(path string) string
+
+	writeMu sync.Mutex
+
+	mu          sync.Mutex
+	nextID      int64
+	pending     map[int64]chan *message
+	documents   map[string]*document
+	diagnostics map[string][]Diagnostic
+	published   map[string]int // number of diagnostics notifications per URI
+	notify      chan struct{}  // closed and replaced on every diagnostics notification
+	closed      bool
+
+	done chan struct{}
+}
+
+// NewClient initializes a language server session over conn for the
+// workspace at root. languageID maps a file path to its LSP language ID.
+func NewClient(ctx context.Context, conn io.ReadWriteCloser, root string, languageID func(path string) string) (*Client, error) {
+	c := &Client{
+		conn:        conn,
+		languageID:  languageID,
+		pending:     make(map[int64]chan *message),
+		documents:   make(map[string]*document),
+		diagnostics: make(map[string][]Diagnostic),
+		published:   make(map[string]int),
+		notify:      make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go c.readLoop()
+
+	rootURI := FileURI(root)
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": root},
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"didSave": false},
+				"publishDiagnostics": map[string]any{"relatedInformation": false},
+				"definition":         map[string]any{"linkSupport": true},
+				"references":         map[string]any{},
+			},
+			"workspace": map[string]any{"workspaceFolders": true, "configuration": true},
+		},
+	}
+	if err := c.call(ctx, "initialize", params, nil); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to initialize language server: %w", err)
+	}
+	if err := c.notifyServer("initialized", map[string]any{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Done is closed when the connection to the server is lost.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close shuts down the server session.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if !closed {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		c.call(ctx, "shutdown", nil, nil)
+		cancel()
+		c.notifyServer("exit", nil)
+	}
+	return c.conn.Close()
+}
+
+func (c *Client) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = c.conn.Write(data)
+	return err
+}
+
+func (c *Client) notifyServer(method string, params any) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: raw})
+}
+
+// marshalParams encodes request parameters, which are omitted when nil.
+func marshalParams(params any) (json.RawMessage, error) {
+	if params == nil {
+		return nil, nil
+	}
+	return json.Marshal(params)
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(&message{ID: json.RawMessage(strconv.FormatInt(id, 10)), Method: method, Params: raw}); err != nil {
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp == nil {
+			return ErrClosed
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil && len(resp.Result) > 0 {
+			return json.Unmarshal(resp.Result, result)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) readLoop() {
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		for id, ch := range c.pending {
+			close(ch)
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+		close(c.done)
+	}()
+
+	r := bufio.NewReader(c.conn)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		switch {
+		case msg.Method != "" && len(msg.ID) > 0:
+			// Reply from another goroutine: the server may be blocked
+			// writing to us until we read its next message
+			go c.handleServerRequest(msg)
+		case msg.Method != "":
+			c.handleNotification(msg)
+		default:
+			id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+			if err != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch := c.pending[id]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		}
+	}
+}
+
+func readMessage(r *bufio.Reader) (*message, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// handleServerRequest answers requests from the server. Configuration
+// requests get empty settings; everything else gets a null result.
+func (c *Client) handleServerRequest(msg *message) {
+	result := json.RawMessage("null")
+	if msg.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		items := make([]any, len(params.Items))
+		data, _ := json.Marshal(items)
+		result = data
+	}
+	c.write(&message{ID: msg.ID, Result: result})
+}
+
+func (c *Client) handleNotification(msg *message) {
+	if msg.Method != "textDocument/publishDiagnostics" {
+		return
+	}
+	var params publishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return
+	}
+	c.mu.Lock()
+	c.diagnostics[params.URI] = params.Diagnostics
+	c.published[params.URI]++
+	close(c.notify)
+	c.notify = make(chan struct{})
+	c.mu.Unlock()
+}
+
+// sync sends the current content of path to the server. It reports whether
+// the server's copy changed.
+func (c *Client) sync(path string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	uri := FileURI(path)
+	text := string(content)
+
+	c.mu.Lock()
+	doc := c.documents[uri]
+	if doc != nil && doc.text == text {
+		c.mu.Unlock()
+		return false, nil
+	}
+	if doc == nil {
+		doc = &document{}
+		c.documents[uri] = doc
+	}
+	doc.version++
+	doc.text = text
+	version := doc.version
+	c.mu.Unlock()
+
+	if version == 1 {
+		return true, c.notifyServer("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{
+				"uri":        uri,
+				"languageId": c.languageID(path),
+				"version":    version,
+				"text":       text,
+			},
+		})
+	}
+	return true, c.notifyServer("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": version},
+		"contentChanges": []map[string]string{{"text": text}},
+	})
+}
+
+// Diagnostics returns the diagnostics for path after sending the server its
+// current content. It waits up to wait for the server to publish them.
+func (c *Client) Diagnostics(ctx context.Context, path string, wait time.Duration) ([]Diagnostic, error) {
+	uri := FileURI(path)
+	c.mu.Lock()
+	before := c.published[uri]
+	c.mu.Unlock()
+
+	changed, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	var settle <-chan time.Time
+	for {
+		c.mu.Lock()
+		count := c.published[uri]
+		diags := c.diagnostics[uri]
+		notify := c.notify
+		c.mu.Unlock()
+		if !changed && count > 0 {
+			return diags, nil
+		}
+		if count > before && settle == nil {
+			settle = time.After(diagnosticsSettle)
+		}
+
+		select {
+		case <-notify:
+			// Restart the settle period on every further notification
+			if settle != nil {
+				settle = time.After(diagnosticsSettle)
+			}
+		case <-settle:
+			return diags, nil
+		case <-deadline.C:
+			return diags, nil
+		case <-c.done:
+			return nil, ErrClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Definition returns the locations where the symbol at pos in path is defined.
+func (c *Client) Definition(ctx context.Context, path string, pos Position) ([]Location, error) {
+	if _, err := c.sync(path); err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	params := textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: FileURI(path)}, Position: pos}
+	if err := c.call(ctx, "textDocument/definition", params, &raw); err != nil {
+		return nil, err
+	}
+	return parseLocations(raw)
+}
+
+// References returns the locations that refer to the symbol at pos in path,
+// including its declaration.
+func (c *Client) References(ctx context.Context, path string, pos Position) ([]Location, error) {
+	if _, err := c.sync(path); err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	params := map[string]any{
+		"textDocument": textDocumentIdentifier{URI: FileURI(path)},
+		"position":     pos,
+		"context":      map[string]bool{"includeDeclaration": true},
+	}
+	if err := c.call(ctx, "textDocument/references", params, &raw); err != nil {
+		return nil, err
+	}
+	return parseLocations(raw)
+}
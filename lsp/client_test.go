@@ -0,0 +1,154 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer is a minimal language server. It reports an error diagnostic for
+// every line containing "bad", resolves definitions to the first line of the
+// file and references to every line mentioning "x".
+func fakeServer(t *testing.T, conn net.Conn) {
+	r := bufio.NewReader(conn)
+	send := func(msg map[string]any) {
+		msg["jsonrpc"] = "2.0"
+		data, _ := json.Marshal(msg)
+		fmt.Fprintf(conn, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	}
+	texts := map[string]string{}
+	publish := func(uri string) {
+		var diags []Diagnostic
+		for i, line := range strings.Split(texts[uri], "\n") {
+			if strings.Contains(line, "bad") {
+				diags = append(diags, Diagnostic{Range: Range{Start: Position{Line: i}}, Severity: SeverityError, Message: "bad line", Source: "fake"})
+			}
+		}
+		send(map[string]any{"method": "textDocument/publishDiagnostics", "params": publishDiagnosticsParams{URI: uri, Diagnostics: diags}})
+	}
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		uri := params.TextDocument.URI
+		switch msg.Method {
+		case "initialize":
+			// Ask for configuration before answering, as real servers do
+			send(map[string]any{"id": 99, "method": "workspace/configuration", "params": map[string]any{"items": []any{map[string]any{}}}})
+			send(map[string]any{"id": msg.ID, "result": map[string]any{"capabilities": map[string]any{}}})
+		case "textDocument/didOpen":
+			texts[uri] = params.TextDocument.Text
+			publish(uri)
+		case "textDocument/didChange":
+			texts[uri] = params.ContentChanges[0].Text
+			publish(uri)
+		case "textDocument/definition":
+			send(map[string]any{"id": msg.ID, "result": []locationLink{{TargetURI: uri, TargetSelectionRange: Range{Start: Position{Line: 0, Character: 5}}}}})
+		case "textDocument/references":
+			var locs []Location
+			for i, line := range strings.Split(texts[uri], "\n") {
+				if strings.Contains(line, "x") {
+					locs = append(locs, Location{URI: uri, Range: Range{Start: Position{Line: i}}})
+				}
+			}
+			send(map[string]any{"id": msg.ID, "result": locs})
+		case "shutdown":
+			send(map[string]any{"id": msg.ID, "result": nil})
+		case "exit":
+			conn.Close()
+			return
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	go fakeServer(t, serverConn)
+
+	ctx := context.Background()
+	root := t.TempDir()
+	c, err := NewClient(ctx, clientConn, root, func(string) string { return "fake" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	path := filepath.Join(root, "main.fake")
+	os.WriteFile(path, []byte("var x = 1\nbad\nuse(x)\n"), 0o644)
+
+	diags, err := c.Diagnostics(ctx, path, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].Range.Start.Line != 1 || diags[0].SeverityName() != "error" {
+		t.Fatalf("unexpected diagnostics: %+v", diags)
+	}
+
+	// Unchanged files return the cached diagnostics; edits are sent to the server
+	if diags, _ := c.Diagnostics(ctx, path, 5*time.Second); len(diags) != 1 {
+		t.Errorf("expected cached diagnostics, got %+v", diags)
+	}
+	os.WriteFile(path, []byte("var x = 1\nuse(x)\n"), 0o644)
+	if diags, _ := c.Diagnostics(ctx, path, 5*time.Second); len(diags) != 0 {
+		t.Errorf("expected diagnostics to clear after the fix, got %+v", diags)
+	}
+
+	defs, err := c.Definition(ctx, path, Position{Line: 1, Character: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 || URIPath(defs[0].URI) != path || defs[0].Range.Start.Character != 5 {
+		t.Errorf("unexpected definition: %+v", defs)
+	}
+	refs, err := c.References(ctx, path, Position{Line: 0, Character: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 {
+		t.Errorf("expected 2 references, got %+v", refs)
+	}
+}
+
+func TestParseLocations(t *testing.T) {
+	for _, tt := range []struct {
+		raw  string
+		want int
+	}{
+		{`null`, 0},
+		{`{"uri":"file:///a","range":{"start":{"line":1,"character":0},"end":{"line":1,"character":1}}}`, 1},
+		{`[{"uri":"file:///a","range":{}},{"uri":"file:///b","range":{}}]`, 2},
+	} {
+		locs, err := parseLocations(json.RawMessage(tt.raw))
+		if err != nil || len(locs) != tt.want {
+			t.Errorf("parseLocations(%s) = %v, %v; want %d locations", tt.raw, locs, err, tt.want)
+		}
+	}
+}
+
+func TestUTF16Offset(t *testing.T) {
+	line := "a😀b := x"
+	if got := UTF16Offset(line, strings.Index(line, "x")); got != 8 {
+		t.Errorf("expected UTF-16 offset 8, got %d", got)
+	}
+	if got := URIPath(FileURI("/tmp/a b.go")); got != "/tmp/a b.go" {
+		t.Errorf("URI round trip failed: %q", got)
+	}
+}
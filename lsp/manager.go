@@ -0,0 +1,180 @@
+// Package lsp runs language servers and queries them for diagnostics,
+// definitions and references.
+//
+// A Manager starts one server per workspace (the git repository containing a
+// file, or its directory) and language, the first time a file in it is used.
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"shelley.exe.dev/gitstate"
+)
+
+// ServerConfig describes how to run a language server.
+type ServerConfig struct {
+	// Name identifies the server.
+	Name string `json:"name"`
+	// Command is the server executable and its arguments. The server must
+	// speak LSP over stdin and stdout.
+	Command []string `json:"command"`
+	// Extensions are the file extensions the server handles, e.g. ".go".
+	Extensions []string `json:"extensions"`
+	// LanguageID overrides the LSP language ID, which otherwise comes from the extension.
+	LanguageID string `json:"language_id,omitempty"`
+}
+
+// DefaultServers are the language servers used when none are configured.
+// Servers whose executable is not installed are skipped.
+var DefaultServers = []ServerConfig{
+	{Name: "gopls", Command: []string{"gopls", "serve"}, Extensions: []string{".go"}},
+	{Name: "typescript-language-server", Command: []string{"typescript-language-server", "--stdio"}, Extensions: []string{".ts", ".tsx", ".js", ".jsx", ".mjs"}},
+	{Name: "pyright", Command: []string{"pyright-langserver", "--stdio"}, Extensions: []string{".py"}},
+	{Name: "rust-analyzer", Command: []string{"rust-analyzer"}, Extensions: []string{".rs"}},
+	{Name: "clangd", Command: []string{"clangd"}, Extensions: []string{".c", ".h", ".cc", ".cpp", ".hpp"}},
+}
+
+// languageIDs maps file extensions to LSP language IDs.
+var languageIDs = map[string]string{
+	".go": "go", ".ts": "typescript", ".tsx": "typescriptreact", ".js": "javascript",
+	".jsx": "javascriptreact", ".mjs": "javascript", ".py": "python", ".rs": "rust",
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".hpp": "cpp",
+}
+
+// Manager starts and caches language servers.
+type Manager struct {
+	servers []ServerConfig
+
+	mu      sync.Mutex
+	clients map[string]*Client // keyed by server name and workspace root
+}
+
+// NewManager creates a Manager for the given servers.
+func NewManager(servers []ServerConfig) *Manager {
+	return &Manager{servers: servers, clients: make(map[string]*Client)}
+}
+
+// Installed returns the names of the configured servers whose executables are installed.
+func (m *Manager) Installed() []string {
+	var names []string
+	for _, s := range m.servers {
+		if len(s.Command) == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(s.Command[0]); err == nil {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// ServerFor returns the configuration of the server handling path, or false
+// if no installed server handles it.
+func (m *Manager) ServerFor(path string) (ServerConfig, bool) {
+	ext := filepath.Ext(path)
+	for _, s := range m.servers {
+		for _, e := range s.Extensions {
+			if e == ext && len(s.Command) > 0 {
+				if _, err := exec.LookPath(s.Command[0]); err == nil {
+					return s, true
+				}
+			}
+		}
+	}
+	return ServerConfig{}, false
+}
+
+// Client returns the client for the server handling path in its workspace,
+// starting the server if needed.
+func (m *Manager) Client(ctx context.Context, path string) (*Client, error) {
+	cfg, ok := m.ServerFor(path)
+	if !ok {
+		return nil, fmt.Errorf("no language server configured for %s files", filepath.Ext(path))
+	}
+	root := workspaceRoot(filepath.Dir(path))
+	key := cfg.Name + "\x00" + root
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.clients[key]; ok {
+		select {
+		case <-c.Done():
+			delete(m.clients, key)
+		default:
+			return c, nil
+		}
+	}
+	c, err := start(ctx, cfg, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", cfg.Name, err)
+	}
+	m.clients[key] = c
+	return c, nil
+}
+
+// Close shuts down all servers.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = make(map[string]*Client)
+	m.mu.Unlock()
+	for _, c := range clients {
+		c.Close()
+	}
+}
+
+func workspaceRoot(dir string) string {
+	if state := gitstate.GetGitState(dir); state.IsRepo && state.Worktree != "" {
+		return state.Worktree
+	}
+	return dir
+}
+
+// processConn joins a server process's stdout and stdin.
+type processConn struct {
+	io.Reader
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (p *processConn) Close() error {
+	p.WriteCloser.Close()
+	if p.cmd.Process != nil {
+		p.cmd.Process.Kill()
+	}
+	return p.cmd.Wait()
+}
+
+func start(ctx context.Context, cfg ServerConfig, root string) (*Client, error) {
+	// The server outlives the request that started it, so ctx only bounds initialization
+	cmd := exec.Command(cfg.Command[0], cfg.Command[1:]...)
+	cmd.Dir = root
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	conn := &processConn{Reader: stdout, WriteCloser: stdin, cmd: cmd}
+	languageID := func(path string) string {
+		if cfg.LanguageID != "" {
+			return cfg.LanguageID
+		}
+		if id, ok := languageIDs[filepath.Ext(path)]; ok {
+			return id
+		}
+		return strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	return NewClient(ctx, conn, root, languageID)
+}
@@ -0,0 +1,288 @@
+// Package mcp serves Shelley's tools over the Model Context Protocol, so that
+// other agents and editors can run commands, edit files and search code in
+// Shelley's environment.
+//
+// The server speaks the MCP stdio transport: newline-delimited JSON-RPC 2.0
+// messages. It implements initialize, ping, tools/list, tools/call and the
+// notifications/cancelled notification.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"shelley.exe.dev/llm"
+)
+
+// ProtocolVersion is the latest MCP version the server implements.
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the MCP versions the server accepts from clients.
+var supportedVersions = []string{"2024-11-05", "2025-03-26", ProtocolVersion}
+
+// DefaultTools are the tools served by default: the ones that act on the
+// environment, not the agent's own conversation.
+var DefaultTools = []string{
+	"bash",
+	"patch",
+	"keyword_search",
+	"change_dir",
+	"run_tests",
+	"semantic_search",
+	"lsp_diagnostics",
+	"lsp_definition",
+	"lsp_references",
+}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// toolInfo is a tool in a tools/list result.
+type toolInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// content is an item of a tools/call result.
+type content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+type callResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError"`
+}
+
+// Server serves tools to one MCP client.
+type Server struct {
+	// Name and Version identify the server to clients.
+	Name    string
+	Version string
+	Logger  *slog.Logger
+
+	tools []*llm.Tool
+
+	writeMu sync.Mutex
+	w       io.Writer
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // cancels in-flight tools/call requests by ID
+}
+
+// NewServer returns a server for the given tools.
+func NewServer(tools []*llm.Tool) *Server {
+	return &Server{
+		Name:    "shelley",
+		Logger:  slog.Default(),
+		tools:   tools,
+		running: make(map[string]context.CancelFunc),
+	}
+}
+
+// Serve reads requests from r and writes responses to w until r ends. Tool
+// calls run concurrently, with a context canceled when ctx is; Serve waits
+// for them before it returns.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.w = w
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			s.reply(nil, nil, &rpcError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if msg.JSONRPC != "2.0" {
+			s.reply(msg.ID, nil, &rpcError{Code: codeInvalidRequest, Message: "jsonrpc must be 2.0"})
+			continue
+		}
+		if msg.Method == "" {
+			// A response; the server sends no requests, so there are none to match
+			continue
+		}
+		if msg.Method == "tools/call" && msg.ID != nil {
+			callCtx, callCancel := context.WithCancel(ctx)
+			s.mu.Lock()
+			s.running[string(msg.ID)] = callCancel
+			s.mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() {
+					s.mu.Lock()
+					delete(s.running, string(msg.ID))
+					s.mu.Unlock()
+					callCancel()
+				}()
+				result, err := s.callTool(callCtx, msg.Params)
+				if callCtx.Err() != nil && ctx.Err() == nil {
+					// Canceled by the client, which expects no response
+					return
+				}
+				s.reply(msg.ID, result, err)
+			}()
+			continue
+		}
+		result, err := s.handle(msg)
+		if msg.ID != nil {
+			s.reply(msg.ID, result, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// handle answers a request other than tools/call, or handles a notification.
+func (s *Server) handle(msg message) (any, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": s.Name, "version": s.Version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		tools := make([]toolInfo, 0, len(s.tools))
+		for _, tool := range s.tools {
+			tools = append(tools, toolInfo{Name: tool.Name, Description: tool.Description, InputSchema: tool.InputSchema})
+		}
+		return map[string]any{"tools": tools}, nil
+	case "notifications/cancelled":
+		var params struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.mu.Lock()
+			if cancel, ok := s.running[string(params.RequestID)]; ok {
+				cancel()
+			}
+			s.mu.Unlock()
+		}
+		return nil, nil
+	case "notifications/initialized":
+		return nil, nil
+	}
+	if msg.ID == nil {
+		// Unknown notifications are ignored
+		return nil, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+// callTool runs a tool for a tools/call request. Tool failures are results
+// with isError set, so the client's model sees them; protocol errors are
+// returned as JSON-RPC errors.
+func (s *Server) callTool(ctx context.Context, raw json.RawMessage) (any, *rpcError) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	i := slices.IndexFunc(s.tools, func(t *llm.Tool) bool { return t.Name == params.Name })
+	if i < 0 {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + params.Name}
+	}
+	input := params.Arguments
+	if len(input) == 0 || string(input) == "null" {
+		input = json.RawMessage("{}")
+	}
+
+	s.Logger.Info("MCP tool call", "tool", params.Name)
+	out := s.tools[i].Run(ctx, input)
+	if out.Error != nil {
+		return callResult{Content: []content{{Type: "text", Text: out.Error.Error()}}, IsError: true}, nil
+	}
+	result := callResult{Content: []content{}}
+	for _, c := range out.LLMContent {
+		if c.MediaType != "" && c.Data != "" {
+			result.Content = append(result.Content, content{Type: "image", Data: c.Data, MimeType: c.MediaType})
+			continue
+		}
+		result.Content = append(result.Content, content{Type: "text", Text: c.Text})
+	}
+	return result, nil
+}
+
+// reply writes a response to request id.
+func (s *Server) reply(id json.RawMessage, result any, rerr *rpcError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	msg := message{JSONRPC: "2.0", ID: id}
+	if rerr != nil {
+		msg.Error = rerr
+	} else {
+		msg.Result = result
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		s.Logger.Error("Failed to encode MCP response", "error", err)
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		s.Logger.Warn("Failed to write MCP response", "error", err)
+	}
+}
+
+// FilterTools returns the tools whose names are in names, in the order of
+// names.
+func FilterTools(tools []*llm.Tool, names []string) []*llm.Tool {
+	var filtered []*llm.Tool
+	for _, name := range names {
+		if i := slices.IndexFunc(tools, func(t *llm.Tool) bool { return t.Name == name }); i >= 0 {
+			filtered = append(filtered, tools[i])
+		}
+	}
+	return filtered
+}
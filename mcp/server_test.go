@@ -0,0 +1,175 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+var testTools = []*llm.Tool{
+	{
+		Name:        "echo",
+		Description: "Echoes its text",
+		InputSchema: llm.MustSchema(`{"type":"object","properties":{"text":{"type":"string"}}}`),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			var req struct{ Text string }
+			json.Unmarshal(input, &req)
+			return llm.ToolOut{LLMContent: llm.TextContent(req.Text)}
+		},
+	},
+	{
+		Name:        "fail",
+		InputSchema: llm.EmptySchema(),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			return llm.ErrorToolOut(errors.New("it failed"))
+		},
+	},
+	{
+		Name:        "wait",
+		InputSchema: llm.EmptySchema(),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			<-ctx.Done()
+			return llm.ErrorToolOut(ctx.Err())
+		},
+	},
+}
+
+// client drives a server over pipes.
+type client struct {
+	t   *testing.T
+	in  *io.PipeWriter
+	out *bufio.Scanner
+}
+
+func startServer(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(testTools).Serve(context.Background(), inR, outW)
+		outW.Close()
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		go io.Copy(io.Discard, outR)
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return &client{t: t, in: inW, out: bufio.NewScanner(outR)}
+}
+
+func (c *client) send(msg string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.in, msg+"\n"); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+func (c *client) receive() response {
+	c.t.Helper()
+	if !c.out.Scan() {
+		c.t.Fatalf("no response: %v", c.out.Err())
+	}
+	var resp response
+	if err := json.Unmarshal(c.out.Bytes(), &resp); err != nil {
+		c.t.Fatalf("invalid response %s: %v", c.out.Bytes(), err)
+	}
+	return resp
+}
+
+func TestInitializeAndList(t *testing.T) {
+	c := startServer(t)
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test"}}}`)
+	resp := c.receive()
+	var init struct {
+		ProtocolVersion string         `json:"protocolVersion"`
+		Capabilities    map[string]any `json:"capabilities"`
+		ServerInfo      struct{ Name string }
+	}
+	json.Unmarshal(resp.Result, &init)
+	if init.ProtocolVersion != "2025-03-26" || init.Capabilities["tools"] == nil || init.ServerInfo.Name != "shelley" {
+		t.Errorf("initialize result = %s", resp.Result)
+	}
+
+	c.send(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	resp = c.receive()
+	if string(resp.ID) != "2" {
+		t.Errorf("response id = %s, want 2", resp.ID)
+	}
+	var list struct {
+		Tools []toolInfo `json:"tools"`
+	}
+	json.Unmarshal(resp.Result, &list)
+	if len(list.Tools) != 3 || list.Tools[0].Name != "echo" || len(list.Tools[0].InputSchema) == 0 {
+		t.Errorf("tools/list result = %s", resp.Result)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"resources/list"}`)
+	if resp := c.receive(); resp.Error == nil || resp.Error.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got %+v", resp)
+	}
+	c.send(`not json`)
+	if resp := c.receive(); resp.Error == nil || resp.Error.Code != codeParseError {
+		t.Errorf("expected a parse error, got %+v", resp)
+	}
+}
+
+func TestCallTool(t *testing.T) {
+	c := startServer(t)
+	c.send(`{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"name":"echo","arguments":{"text":"hello"}}}`)
+	resp := c.receive()
+	var result callResult
+	json.Unmarshal(resp.Result, &result)
+	if result.IsError || len(result.Content) != 1 || result.Content[0].Text != "hello" {
+		t.Errorf("echo result = %s", resp.Result)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":"b","method":"tools/call","params":{"name":"fail"}}`)
+	resp = c.receive()
+	json.Unmarshal(resp.Result, &result)
+	if !result.IsError || result.Content[0].Text != "it failed" {
+		t.Errorf("fail result = %s", resp.Result)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":"c","method":"tools/call","params":{"name":"missing"}}`)
+	if resp := c.receive(); resp.Error == nil || resp.Error.Code != codeInvalidParams {
+		t.Errorf("expected invalid params for an unknown tool, got %+v", resp)
+	}
+}
+
+func TestCancelCall(t *testing.T) {
+	c := startServer(t)
+	c.send(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"wait"}}`)
+	// Other requests are answered while the call runs
+	c.send(`{"jsonrpc":"2.0","id":8,"method":"ping"}`)
+	if resp := c.receive(); string(resp.ID) != "8" {
+		t.Fatalf("expected the ping response first, got %+v", resp)
+	}
+
+	// The canceled call gets no response, but it must end for Serve to return
+	c.send(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`)
+	c.send(`{"jsonrpc":"2.0","id":9,"method":"ping"}`)
+	if resp := c.receive(); string(resp.ID) != "9" {
+		t.Errorf("expected only the ping response, got %+v", resp)
+	}
+}
+
+func TestFilterTools(t *testing.T) {
+	tools := FilterTools(testTools, []string{"wait", "missing", "echo"})
+	if len(tools) != 2 || tools[0].Name != "wait" || tools[1].Name != "echo" {
+		t.Errorf("FilterTools = %v", tools)
+	}
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/llm/oai"
+)
+
+// ProviderHealth is the result of checking that a provider answers with its
+// configured credential.
+type ProviderHealth struct {
+	Provider Provider
+	// Configured reports whether the provider has a credential; providers
+	// without one are not contacted
+	Configured bool
+	// Reachable reports whether the provider answered
+	Reachable bool
+	// AuthValid reports whether the provider accepted the credential, or is
+	// nil if the check can't tell, as for the bridge
+	AuthValid *bool
+	Latency   time.Duration
+	Error     string
+}
+
+// CheckProviderHealth checks all configurable providers concurrently. It lists
+// the models of API providers, which costs no tokens, and asks the bridge for
+// its health. Providers it can't list the models of behind a gateway are sent
+// a minimal request instead.
+func (m *Manager) CheckProviderHealth(ctx context.Context) []ProviderHealth {
+	m.mu.RLock()
+	cfg, err := m.configWith("", "")
+	m.mu.RUnlock()
+
+	results := make([]ProviderHealth, len(ConfigurableProviders))
+	var wg sync.WaitGroup
+	for i, provider := range ConfigurableProviders {
+		results[i].Provider = provider
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		field, _ := cfg.credential(provider)
+		if results[i].Configured = *field != ""; !results[i].Configured {
+			continue
+		}
+		wg.Go(func() {
+			start := time.Now()
+			m.checkHealth(ctx, cfg, &results[i])
+			results[i].Latency = time.Since(start)
+		})
+	}
+	wg.Wait()
+	return results
+}
+
+// checkHealth checks the provider of h and fills in the result.
+func (m *Manager) checkHealth(ctx context.Context, cfg *Config, h *ProviderHealth) {
+	req, err := cfg.healthRequest(ctx, h.Provider)
+	if err != nil {
+		h.Error = err.Error()
+		return
+	}
+	if req == nil {
+		m.pingProvider(ctx, cfg, h)
+		return
+	}
+
+	// Not the manager's client, which records requests as LLM requests
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.Error = err.Error()
+		return
+	}
+	resp.Body.Close()
+	h.Reachable = true
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		valid := false
+		h.AuthValid = &valid
+		h.Error = "credential rejected: " + resp.Status
+	case resp.StatusCode >= 300:
+		h.Error = "unexpected status: " + resp.Status
+	case h.Provider != ProviderClaudeCode:
+		valid := true
+		h.AuthValid = &valid
+	}
+}
+
+// pingProvider sends a minimal request to the first built-in model of the
+// provider of h. An error doesn't tell whether the credential was rejected or
+// the provider was unreachable, so both are left unknown.
+func (m *Manager) pingProvider(ctx context.Context, cfg *Config, h *ProviderHealth) {
+	for _, model := range All() {
+		if model.Provider != h.Provider {
+			continue
+		}
+		svc, err := model.Factory(cfg, m.httpc)
+		if err != nil {
+			h.Error = err.Error()
+			return
+		}
+		if _, err := svc.Do(ctx, pingRequest()); err != nil {
+			h.Error = err.Error()
+			return
+		}
+		valid := true
+		h.Reachable, h.AuthValid = true, &valid
+		return
+	}
+	h.Error = fmt.Sprintf("provider %s has no models", h.Provider)
+}
+
+// healthRequest returns a request that checks a provider without using any
+// tokens, or nil if there is none and a model must be pinged.
+func (c *Config) healthRequest(ctx context.Context, provider Provider) (*http.Request, error) {
+	var url string
+	header := http.Header{}
+	switch provider {
+	case ProviderAnthropic:
+		url = "https://api.anthropic.com/v1/models"
+		if messagesURL := c.getAnthropicURL(); messagesURL != "" {
+			url = strings.TrimSuffix(messagesURL, "/messages") + "/models"
+		}
+		header.Set("X-Api-Key", c.AnthropicAPIKey)
+		header.Set("Anthropic-Version", "2023-06-01")
+	case ProviderOpenAI:
+		url = oai.OpenAIURL + "/models"
+		if baseURL := c.getOpenAIURL(); baseURL != "" {
+			url = baseURL + "/models"
+		}
+		header.Set("Authorization", "Bearer "+c.OpenAIAPIKey)
+	case ProviderFireworks:
+		url = oai.FireworksURL + "/models"
+		if baseURL := c.getFireworksURL(); baseURL != "" {
+			url = baseURL + "/models"
+		}
+		header.Set("Authorization", "Bearer "+c.FireworksAPIKey)
+	case ProviderGemini:
+		if c.Gateway != "" {
+			return nil, nil
+		}
+		url = "https://generativelanguage.googleapis.com/v1beta/models"
+		header.Set("X-Goog-Api-Key", c.GeminiAPIKey)
+	case ProviderClaudeCode:
+		return http.NewRequestWithContext(ctx, http.MethodHead, strings.TrimSuffix(c.ClaudeCodeBridgeURL, "/")+"/health", nil)
+	default:
+		return nil, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	return req, nil
+}
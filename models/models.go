@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"shelley.exe.dev/db"
@@ -22,13 +23,14 @@ import (
 type Provider string
 
 const (
-	ProviderOpenAI    Provider = "openai"
-	ProviderAnthropic Provider = "anthropic"
-	ProviderFireworks Provider = "fireworks"
-	ProviderGemini    Provider = "gemini"
+	ProviderOpenAI     Provider = "openai"
+	ProviderAnthropic  Provider = "anthropic"
+	ProviderFireworks  Provider = "fireworks"
+	ProviderGemini     Provider = "gemini"
 	ProviderBuiltIn    Provider = "builtin"
 	ProviderClaudeCode Provider = "claude-code"
 	ProviderCodex      Provider = "codex"
+	ProviderGeminiCLI  Provider = "gemini-cli"
 )
 
 // Model represents a configured LLM model in Shelley
@@ -45,10 +47,25 @@ type Model struct {
 	// RequiredEnvVars are the environment variables required for this model
 	RequiredEnvVars []string
 
+	// SupportsVision reports whether the model accepts image input
+	SupportsVision bool
+
+	// SupportsTools reports whether the model supports tool calls
+	SupportsTools bool
+
+	// Pricing is the list price of the model, or nil if unknown or not billed per token
+	Pricing *Pricing
+
 	// Factory creates an llm.Service instance for this model
 	Factory func(config *Config, httpc *http.Client) (llm.Service, error)
 }
 
+// Pricing is the price of a model in USD per million tokens
+type Pricing struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+}
+
 // Config holds the configuration needed to create LLM services
 type Config struct {
 	// API keys for each provider
@@ -64,12 +81,28 @@ type Config struct {
 	// If set, model-specific suffixes will be appended
 	Gateway string
 
+	// ThinkingBudgets maps model IDs to their extended thinking budget in tokens (optional).
+	// Models without a budget run without extended thinking.
+	ThinkingBudgets map[string]int
+
+	// ResponseCache answers deterministic (temperature 0) requests from a
+	// cache of earlier responses in DB (optional).
+	ResponseCache bool
+
 	Logger *slog.Logger
 
 	// Database for recording LLM requests (optional)
 	DB *db.DB
 }
 
+// bridgeSessions returns the store for Claude Code bridge sessions, or nil without a database
+func (c *Config) bridgeSessions() claudecode.SessionStore {
+	if c.DB == nil {
+		return nil
+	}
+	return &db.BridgeSessionDBAdapter{DB: c.DB}
+}
+
 // getAnthropicURL returns the Anthropic API URL, with gateway suffix if gateway is set
 func (c *Config) getAnthropicURL() string {
 	if c.Gateway != "" {
@@ -110,6 +143,9 @@ func All() []Model {
 			Provider:        ProviderAnthropic,
 			Description:     "Claude Opus 4.5 (default)",
 			RequiredEnvVars: []string{"ANTHROPIC_API_KEY"},
+			SupportsVision:  true,
+			SupportsTools:   true,
+			Pricing:         &Pricing{InputPerMTok: 5, OutputPerMTok: 25},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.AnthropicAPIKey == "" {
 					return nil, fmt.Errorf("claude-opus-4.5 requires ANTHROPIC_API_KEY")
@@ -126,6 +162,8 @@ func All() []Model {
 			Provider:        ProviderFireworks,
 			Description:     "Qwen3 Coder 480B on Fireworks",
 			RequiredEnvVars: []string{"FIREWORKS_API_KEY"},
+			SupportsTools:   true,
+			Pricing:         &Pricing{InputPerMTok: 0.45, OutputPerMTok: 1.8},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.FireworksAPIKey == "" {
 					return nil, fmt.Errorf("qwen3-coder-fireworks requires FIREWORKS_API_KEY")
@@ -142,6 +180,8 @@ func All() []Model {
 			Provider:        ProviderFireworks,
 			Description:     "GLM-4P6 on Fireworks",
 			RequiredEnvVars: []string{"FIREWORKS_API_KEY"},
+			SupportsTools:   true,
+			Pricing:         &Pricing{InputPerMTok: 0.55, OutputPerMTok: 2.19},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.FireworksAPIKey == "" {
 					return nil, fmt.Errorf("glm-4p6-fireworks requires FIREWORKS_API_KEY")
@@ -158,6 +198,9 @@ func All() []Model {
 			Provider:        ProviderOpenAI,
 			Description:     "GPT-5.2 Codex",
 			RequiredEnvVars: []string{"OPENAI_API_KEY"},
+			SupportsVision:  true,
+			SupportsTools:   true,
+			Pricing:         &Pricing{InputPerMTok: 1.75, OutputPerMTok: 14},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.OpenAIAPIKey == "" {
 					return nil, fmt.Errorf("gpt-5.2-codex requires OPENAI_API_KEY")
@@ -169,11 +212,33 @@ func All() []Model {
 				return svc, nil
 			},
 		},
+		{
+			ID:              "gpt-5.1",
+			Provider:        ProviderOpenAI,
+			Description:     "GPT-5.1 with image generation",
+			RequiredEnvVars: []string{"OPENAI_API_KEY"},
+			SupportsVision:  true,
+			SupportsTools:   true,
+			Pricing:         &Pricing{InputPerMTok: 1.25, OutputPerMTok: 10},
+			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
+				if config.OpenAIAPIKey == "" {
+					return nil, fmt.Errorf("gpt-5.1 requires OPENAI_API_KEY")
+				}
+				svc := &oai.ResponsesService{Model: oai.GPT5, APIKey: config.OpenAIAPIKey, HTTPC: httpc, ImageGeneration: true}
+				if url := config.getOpenAIURL(); url != "" {
+					svc.ModelURL = url
+				}
+				return svc, nil
+			},
+		},
 		{
 			ID:              "claude-sonnet-4.5",
 			Provider:        ProviderAnthropic,
 			Description:     "Claude Sonnet 4.5",
 			RequiredEnvVars: []string{"ANTHROPIC_API_KEY"},
+			SupportsVision:  true,
+			SupportsTools:   true,
+			Pricing:         &Pricing{InputPerMTok: 3, OutputPerMTok: 15},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.AnthropicAPIKey == "" {
 					return nil, fmt.Errorf("claude-sonnet-4.5 requires ANTHROPIC_API_KEY")
@@ -190,6 +255,9 @@ func All() []Model {
 			Provider:        ProviderAnthropic,
 			Description:     "Claude Haiku 4.5",
 			RequiredEnvVars: []string{"ANTHROPIC_API_KEY"},
+			SupportsVision:  true,
+			SupportsTools:   true,
+			Pricing:         &Pricing{InputPerMTok: 1, OutputPerMTok: 5},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.AnthropicAPIKey == "" {
 					return nil, fmt.Errorf("claude-haiku-4.5 requires ANTHROPIC_API_KEY")
@@ -206,6 +274,9 @@ func All() []Model {
 			Provider:        ProviderGemini,
 			Description:     "Gemini 3 Pro",
 			RequiredEnvVars: []string{"GEMINI_API_KEY"},
+			SupportsVision:  true,
+			SupportsTools:   true,
+			Pricing:         &Pricing{InputPerMTok: 2, OutputPerMTok: 12},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.GeminiAPIKey == "" {
 					return nil, fmt.Errorf("gemini-3-pro requires GEMINI_API_KEY")
@@ -222,6 +293,9 @@ func All() []Model {
 			Provider:        ProviderGemini,
 			Description:     "Gemini 3 Flash",
 			RequiredEnvVars: []string{"GEMINI_API_KEY"},
+			SupportsVision:  true,
+			SupportsTools:   true,
+			Pricing:         &Pricing{InputPerMTok: 0.5, OutputPerMTok: 3},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.GeminiAPIKey == "" {
 					return nil, fmt.Errorf("gemini-3-flash requires GEMINI_API_KEY")
@@ -233,6 +307,24 @@ func All() []Model {
 				return svc, nil
 			},
 		},
+		{
+			ID:              "gemini-2.5-flash-image",
+			Provider:        ProviderGemini,
+			Description:     "Gemini 2.5 Flash Image, without tools",
+			RequiredEnvVars: []string{"GEMINI_API_KEY"},
+			SupportsVision:  true,
+			Pricing:         &Pricing{InputPerMTok: 0.3, OutputPerMTok: 30},
+			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
+				if config.GeminiAPIKey == "" {
+					return nil, fmt.Errorf("gemini-2.5-flash-image requires GEMINI_API_KEY")
+				}
+				svc := &gem.Service{APIKey: config.GeminiAPIKey, Model: "gemini-2.5-flash-image", HTTPC: httpc, ImageOutput: true}
+				if url := config.getGeminiURL(); url != "" {
+					svc.URL = url
+				}
+				return svc, nil
+			},
+		},
 		{
 			ID:              "claude-code",
 			Provider:        ProviderClaudeCode,
@@ -246,22 +338,28 @@ func All() []Model {
 					HTTPC:     httpc,
 					BridgeURL: config.ClaudeCodeBridgeURL,
 					Model:     "claude-code",
+					Sessions:  config.bridgeSessions(),
 				}, nil
 			},
 		},
+		codexModel("codex", "Codex CLI (OpenAI)", ""),
+		codexModel("codex-low", "Codex CLI (OpenAI), low reasoning effort", "low"),
+		codexModel("codex-medium", "Codex CLI (OpenAI), medium reasoning effort", "medium"),
+		codexModel("codex-high", "Codex CLI (OpenAI), high reasoning effort", "high"),
 		{
-			ID:              "codex",
-			Provider:        ProviderCodex,
-			Description:     "Codex CLI (OpenAI)",
+			ID:              "gemini-cli",
+			Provider:        ProviderGeminiCLI,
+			Description:     "Gemini CLI (Google)",
 			RequiredEnvVars: []string{"CLAUDE_CODE_BRIDGE_URL"},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.ClaudeCodeBridgeURL == "" {
-					return nil, fmt.Errorf("codex requires CLAUDE_CODE_BRIDGE_URL")
+					return nil, fmt.Errorf("gemini-cli requires CLAUDE_CODE_BRIDGE_URL")
 				}
 				return &claudecode.Service{
 					HTTPC:     httpc,
 					BridgeURL: config.ClaudeCodeBridgeURL,
-					Model:     "codex",
+					Model:     "gemini-cli",
+					Sessions:  config.bridgeSessions(),
 				}, nil
 			},
 		},
@@ -270,6 +368,8 @@ func All() []Model {
 			Provider:        ProviderBuiltIn,
 			Description:     "Deterministic test model (no API key)",
 			RequiredEnvVars: []string{},
+			SupportsTools:   true,
+			Pricing:         &Pricing{},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				return loop.NewPredictableService(), nil
 			},
@@ -277,6 +377,29 @@ func All() []Model {
 	}
 }
 
+// codexModel returns a Codex CLI model with the given reasoning effort; an
+// empty effort uses Codex's default.
+func codexModel(id, description, effort string) Model {
+	return Model{
+		ID:              id,
+		Provider:        ProviderCodex,
+		Description:     description,
+		RequiredEnvVars: []string{"CLAUDE_CODE_BRIDGE_URL"},
+		Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
+			if config.ClaudeCodeBridgeURL == "" {
+				return nil, fmt.Errorf("%s requires CLAUDE_CODE_BRIDGE_URL", id)
+			}
+			return &claudecode.Service{
+				HTTPC:           httpc,
+				BridgeURL:       config.ClaudeCodeBridgeURL,
+				Model:           "codex",
+				ReasoningEffort: effort,
+				Sessions:        config.bridgeSessions(),
+			}, nil
+		},
+	}
+}
+
 // ByID returns the model with the given ID, or nil if not found
 func ByID(id string) *Model {
 	for _, m := range All() {
@@ -304,10 +427,18 @@ func Default() Model {
 
 // Manager manages LLM services for all configured models
 type Manager struct {
-	services map[string]serviceEntry
-	logger   *slog.Logger
-	db       *db.DB       // for custom models and LLM request recording
-	httpc    *http.Client // HTTP client with recording middleware
+	mu              sync.RWMutex // guards services and credentials
+	services        map[string]serviceEntry
+	logger          *slog.Logger
+	db              *db.DB         // for custom models and LLM request recording
+	httpc           *http.Client   // HTTP client with recording middleware
+	thinkingBudgets map[string]int // extended thinking budget by model ID
+	responseCache   bool           // whether deterministic requests are cached
+
+	// base is the config the manager was created with; credentials override
+	// its provider credentials at runtime
+	base        Config
+	credentials map[Provider]string
 }
 
 type serviceEntry struct {
@@ -324,7 +455,7 @@ type ConfigInfo interface {
 
 // loggingService wraps an llm.Service to log request completion with usage information
 type loggingService struct {
-	service  llm.Service
+	wrapped
 	logger   *slog.Logger
 	modelID  string
 	provider Provider
@@ -340,7 +471,7 @@ func (l *loggingService) Do(ctx context.Context, request *llm.Request) (*llm.Res
 	ctx = llmhttp.WithProvider(ctx, string(l.provider))
 
 	// Call the underlying service
-	response, err := l.service.Do(ctx, request)
+	response, err := l.Service.Do(ctx, request)
 
 	duration := time.Since(start)
 	durationSeconds := duration.Seconds()
@@ -353,7 +484,7 @@ func (l *loggingService) Do(ctx context.Context, request *llm.Request) (*llm.Res
 		}
 
 		// Add configuration details if available
-		if configProvider, ok := l.service.(ConfigInfo); ok {
+		if configProvider, ok := l.Service.(ConfigInfo); ok {
 			for k, v := range configProvider.ConfigDetails() {
 				logAttrs = append(logAttrs, k, v)
 			}
@@ -389,30 +520,62 @@ func (l *loggingService) Do(ctx context.Context, request *llm.Request) (*llm.Res
 	return response, err
 }
 
-// TokenContextWindow delegates to the underlying service
-func (l *loggingService) TokenContextWindow() int {
-	return l.service.TokenContextWindow()
+// SubmitBatch adds the model ID and provider to the context for the HTTP
+// transport, like Do, and delegates to the underlying service
+func (l *loggingService) SubmitBatch(ctx context.Context, items []llm.BatchItem) (string, error) {
+	ctx = llmhttp.WithModelID(ctx, l.modelID)
+	ctx = llmhttp.WithProvider(ctx, string(l.provider))
+	return l.wrapped.SubmitBatch(ctx, items)
+}
+
+// thinkingService wraps an llm.Service to enable extended thinking on requests that don't configure it
+type thinkingService struct {
+	wrapped
+	budget int
 }
 
-// MaxImageDimension delegates to the underlying service
-func (l *loggingService) MaxImageDimension() int {
-	return l.service.MaxImageDimension()
+// Do sets the thinking budget on the request and calls the underlying service
+func (t *thinkingService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	if request.Thinking == nil {
+		req := *request
+		req.Thinking = &llm.ThinkingConfig{BudgetTokens: t.budget}
+		request = &req
+	}
+	return t.Service.Do(ctx, request)
+}
+
+// SubmitBatch sets the thinking budget on the items' requests and delegates to
+// the underlying service if its provider runs batches
+func (t *thinkingService) SubmitBatch(ctx context.Context, items []llm.BatchItem) (string, error) {
+	withThinking := make([]llm.BatchItem, len(items))
+	for i, item := range items {
+		withThinking[i] = item
+		if item.Request.Thinking == nil {
+			req := *item.Request
+			req.Thinking = &llm.ThinkingConfig{BudgetTokens: t.budget}
+			withThinking[i].Request = &req
+		}
+	}
+	return t.wrapped.SubmitBatch(ctx, withThinking)
 }
 
-// UseSimplifiedPatch delegates to the underlying service if it supports it
-func (l *loggingService) UseSimplifiedPatch() bool {
-	if sp, ok := l.service.(llm.SimplifiedPatcher); ok {
-		return sp.UseSimplifiedPatch()
+// withThinking wraps svc with the configured thinking budget of modelID, if any
+func (m *Manager) withThinking(modelID string, svc llm.Service) llm.Service {
+	if budget := m.thinkingBudgets[modelID]; budget > 0 {
+		return &thinkingService{wrapped: wrapped{svc}, budget: budget}
 	}
-	return false
+	return svc
 }
 
 // NewManager creates a new Manager with all models configured
 func NewManager(cfg *Config) (*Manager, error) {
 	manager := &Manager{
-		services: make(map[string]serviceEntry),
-		logger:   cfg.Logger,
-		db:       cfg.DB,
+		logger:          cfg.Logger,
+		db:              cfg.DB,
+		thinkingBudgets: cfg.ThinkingBudgets,
+		responseCache:   cfg.ResponseCache,
+		base:            *cfg,
+		credentials:     make(map[Provider]string),
 	}
 
 	// Create HTTP client with recording if database is available
@@ -480,20 +643,27 @@ func NewManager(cfg *Config) (*Manager, error) {
 	// Store the HTTP client for use with custom models
 	manager.httpc = httpc
 
+	manager.services = manager.buildServices(cfg)
+
+	return manager, nil
+}
+
+// buildServices creates the services of the built-in models available with cfg
+func (m *Manager) buildServices(cfg *Config) map[string]serviceEntry {
+	services := make(map[string]serviceEntry)
 	for _, model := range All() {
-		svc, err := model.Factory(cfg, httpc)
+		svc, err := model.Factory(cfg, m.httpc)
 		if err != nil {
 			// Model not available (e.g., missing API key) - skip it
 			continue
 		}
-		manager.services[model.ID] = serviceEntry{
+		services[model.ID] = serviceEntry{
 			service:  svc,
 			provider: model.Provider,
 			modelID:  model.ID,
 		}
 	}
-
-	return manager, nil
+	return services
 }
 
 // GetService returns the LLM service for the given model ID, wrapped with logging
@@ -507,9 +677,12 @@ func (m *Manager) GetService(modelID string) (llm.Service, error) {
 				if model.ModelID == modelID {
 					svc := m.createServiceFromModel(&model)
 					if svc != nil {
+						svc = m.withThinking(modelID, svc)
+						svc = &structuredService{wrapped{svc}}
+						svc = m.withResponseCache(modelID, svc)
 						if m.logger != nil {
 							return &loggingService{
-								service:  svc,
+								wrapped:  wrapped{svc},
 								logger:   m.logger,
 								modelID:  modelID,
 								provider: Provider(model.ProviderType),
@@ -526,18 +699,24 @@ func (m *Manager) GetService(modelID string) (llm.Service, error) {
 	}
 
 	// No custom models - fall back to built-in models
-	if entry, ok := m.services[modelID]; ok {
+	m.mu.RLock()
+	entry, ok := m.services[modelID]
+	m.mu.RUnlock()
+	if ok {
+		svc := m.withThinking(modelID, entry.service)
+		svc = &structuredService{wrapped{svc}}
+		svc = m.withResponseCache(modelID, svc)
 		// Wrap with logging if we have a logger
 		if m.logger != nil {
 			return &loggingService{
-				service:  entry.service,
+				wrapped:  wrapped{svc},
 				logger:   m.logger,
 				modelID:  entry.modelID,
 				provider: entry.provider,
 				db:       m.db,
 			}, nil
 		}
-		return entry.service, nil
+		return svc, nil
 	}
 	return nil, fmt.Errorf("unsupported model: %s", modelID)
 }
@@ -557,6 +736,8 @@ func (m *Manager) GetAvailableModels() []string {
 	}
 
 	// No custom models - fall back to built-in models in the same order as All()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	all := All()
 	for _, model := range all {
 		if _, ok := m.services[model.ID]; ok {
@@ -574,29 +755,212 @@ func (m *Manager) HasModel(modelID string) bool {
 			return true
 		}
 	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	_, ok := m.services[modelID]
 	return ok
 }
 
-// ModelInfo contains display name and tags for a model
+// ModelInfo contains display name, tags and capabilities for a model
 type ModelInfo struct {
-	DisplayName string
-	Tags        string
+	DisplayName    string
+	Tags           string
+	Provider       Provider
+	SupportsVision bool
+	SupportsTools  bool
+	Pricing        *Pricing
 }
 
-// GetModelInfo returns the display name and tags for a model
+// GetModelInfo returns the display name, tags and capabilities for a model, or nil if it is unknown
 func (m *Manager) GetModelInfo(modelID string) *ModelInfo {
-	if m.db == nil {
-		return nil
+	if m.db != nil {
+		if model, err := m.db.GetModel(context.Background(), modelID); err == nil && model != nil {
+			return &ModelInfo{
+				DisplayName: model.DisplayName,
+				Tags:        model.Tags,
+				Provider:    Provider(model.ProviderType),
+				// Custom models are assumed to have the usual capabilities of their API
+				SupportsVision: model.ProviderType != "openai",
+				SupportsTools:  true,
+			}
+		}
 	}
-	model, err := m.db.GetModel(context.Background(), modelID)
-	if err != nil {
+	model := ByID(modelID)
+	if model == nil {
 		return nil
 	}
 	return &ModelInfo{
-		DisplayName: model.DisplayName,
-		Tags:        model.Tags,
+		Provider:       model.Provider,
+		SupportsVision: model.SupportsVision,
+		SupportsTools:  model.SupportsTools,
+		Pricing:        model.Pricing,
+	}
+}
+
+// CheckModel sends a minimal request to the model's provider to verify that it is reachable and the credentials work
+func (m *Manager) CheckModel(ctx context.Context, modelID string) error {
+	svc, err := m.GetService(modelID)
+	if err != nil {
+		return err
+	}
+	_, err = svc.Do(ctx, pingRequest())
+	return err
+}
+
+// pingMaxTokens bounds the answer to a check. Reasoning models count their
+// reasoning against it even when asked not to think, and reject or return
+// nothing for budgets much smaller than this.
+const pingMaxTokens = 1024
+
+// pingRequest returns the minimal request used to check a model
+func pingRequest() *llm.Request {
+	return &llm.Request{
+		Messages: []llm.Message{llm.UserStringMessage("Reply with OK.")},
+		// Keep the check cheap: no extended thinking and a short answer
+		Thinking: &llm.ThinkingConfig{},
+		Sampling: &llm.Sampling{MaxTokens: pingMaxTokens},
+	}
+}
+
+// ConfigurableProviders are the providers whose credentials can be set at runtime
+var ConfigurableProviders = []Provider{
+	ProviderAnthropic,
+	ProviderOpenAI,
+	ProviderGemini,
+	ProviderFireworks,
+	ProviderClaudeCode,
+}
+
+// credential returns the config field holding the credential of a provider:
+// the API key, or the bridge URL for claude-code
+func (c *Config) credential(provider Provider) (*string, error) {
+	switch provider {
+	case ProviderAnthropic:
+		return &c.AnthropicAPIKey, nil
+	case ProviderOpenAI:
+		return &c.OpenAIAPIKey, nil
+	case ProviderGemini:
+		return &c.GeminiAPIKey, nil
+	case ProviderFireworks:
+		return &c.FireworksAPIKey, nil
+	case ProviderClaudeCode:
+		return &c.ClaudeCodeBridgeURL, nil
+	default:
+		return nil, fmt.Errorf("provider %s has no configurable credential", provider)
+	}
+}
+
+// configWith returns a copy of the manager's config with the runtime
+// credentials applied, and value as the credential of provider when it is not empty.
+// The caller must hold m.mu.
+func (m *Manager) configWith(provider Provider, value string) (*Config, error) {
+	cfg := m.base
+	for p, v := range m.credentials {
+		field, err := cfg.credential(p)
+		if err != nil {
+			return nil, err
+		}
+		*field = v
+	}
+	if value != "" {
+		field, err := cfg.credential(provider)
+		if err != nil {
+			return nil, err
+		}
+		*field = value
+	}
+	return &cfg, nil
+}
+
+// CheckProviderCredential verifies that value is a working credential for
+// provider by sending a minimal request to its first built-in model
+func (m *Manager) CheckProviderCredential(ctx context.Context, provider Provider, value string) error {
+	if value == "" {
+		return fmt.Errorf("credential for %s is empty", provider)
+	}
+	m.mu.RLock()
+	cfg, err := m.configWith(provider, value)
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	for _, model := range All() {
+		if model.Provider != provider {
+			continue
+		}
+		svc, err := model.Factory(cfg, m.httpc)
+		if err != nil {
+			return err
+		}
+		_, err = svc.Do(ctx, pingRequest())
+		return err
+	}
+	return fmt.Errorf("provider %s has no models", provider)
+}
+
+// SetProviderCredential replaces the credential of provider for all new
+// requests, overriding the one the manager was created with
+func (m *Manager) SetProviderCredential(provider Provider, value string) error {
+	if value == "" {
+		return fmt.Errorf("credential for %s is empty", provider)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cfg, err := m.configWith(provider, value)
+	if err != nil {
+		return err
+	}
+	m.credentials[provider] = value
+	m.services = m.buildServices(cfg)
+	return nil
+}
+
+// ClearProviderCredential removes the runtime credential of provider, going
+// back to the one the manager was created with
+func (m *Manager) ClearProviderCredential(provider Provider) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.base.credential(provider); err != nil {
+		return err
+	}
+	delete(m.credentials, provider)
+	cfg, err := m.configWith(provider, "")
+	if err != nil {
+		return err
+	}
+	m.services = m.buildServices(cfg)
+	return nil
+}
+
+// ProviderStatus describes the credential state of a provider
+type ProviderStatus struct {
+	Provider Provider
+	// Configured reports whether the provider has a credential
+	Configured bool
+	// Runtime reports whether the credential was set at runtime rather than by flags or environment
+	Runtime bool
+	// Models are the built-in models available with the credential
+	Models []string
+}
+
+// ProviderStatuses returns the state of each of ConfigurableProviders
+func (m *Manager) ProviderStatuses() []ProviderStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cfg, _ := m.configWith("", "")
+	statuses := make([]ProviderStatus, 0, len(ConfigurableProviders))
+	for _, provider := range ConfigurableProviders {
+		field, _ := cfg.credential(provider)
+		_, runtime := m.credentials[provider]
+		status := ProviderStatus{Provider: provider, Configured: *field != "", Runtime: runtime}
+		for _, model := range All() {
+			if _, ok := m.services[model.ID]; ok && model.Provider == provider {
+				status.Models = append(status.Models, model.ID)
+			}
+		}
+		statuses = append(statuses, status)
 	}
+	return statuses
 }
 
 // createServiceFromModel creates an LLM service from a database model configuration
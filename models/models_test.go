@@ -182,7 +182,7 @@ func TestLoggingService(t *testing.T) {
 	logger := slog.Default()
 
 	loggingSvc := &loggingService{
-		service:  mockService,
+		wrapped:  wrapped{mockService},
 		logger:   logger,
 		modelID:  "test-model",
 		provider: ProviderBuiltIn,
@@ -229,9 +229,11 @@ type mockLLMService struct {
 	tokenContextWindow int
 	maxImageDimension  int
 	useSimplifiedPatch bool
+	lastRequest        *llm.Request
 }
 
 func (m *mockLLMService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	m.lastRequest = request
 	return &llm.Response{
 		Content: llm.TextContent("Hello, world!"),
 		Usage: llm.Usage{
@@ -260,6 +262,40 @@ func (m *mockLLMService) UseSimplifiedPatch() bool {
 	return m.useSimplifiedPatch
 }
 
+func TestThinkingService(t *testing.T) {
+	mockService := &mockLLMService{useSimplifiedPatch: true}
+	manager := &Manager{thinkingBudgets: map[string]int{"thinker": 8000}}
+
+	if svc := manager.withThinking("other", mockService); svc != mockService {
+		t.Error("expected models without a budget to be left unwrapped")
+	}
+
+	svc := manager.withThinking("thinker", mockService)
+	request := &llm.Request{Messages: []llm.Message{llm.UserStringMessage("Hello")}}
+	if _, err := svc.Do(context.Background(), request); err != nil {
+		t.Fatalf("Do returned unexpected error: %v", err)
+	}
+	if got := mockService.lastRequest.Thinking; got == nil || got.BudgetTokens != 8000 {
+		t.Errorf("expected thinking budget 8000, got %+v", got)
+	}
+	if request.Thinking != nil {
+		t.Error("expected the caller's request to be left unchanged")
+	}
+
+	// An explicit thinking config on the request wins
+	explicit := &llm.ThinkingConfig{BudgetTokens: 2000}
+	if _, err := svc.Do(context.Background(), &llm.Request{Thinking: explicit}); err != nil {
+		t.Fatalf("Do returned unexpected error: %v", err)
+	}
+	if mockService.lastRequest.Thinking != explicit {
+		t.Errorf("expected explicit thinking config, got %+v", mockService.lastRequest.Thinking)
+	}
+
+	if sp, ok := svc.(llm.SimplifiedPatcher); !ok || !sp.UseSimplifiedPatch() {
+		t.Error("expected UseSimplifiedPatch to be delegated")
+	}
+}
+
 func TestManagerGetService(t *testing.T) {
 	// Test with predictable model (no API keys needed)
 	cfg := &Config{}
@@ -343,7 +379,7 @@ func TestUseSimplifiedPatch(t *testing.T) {
 	logger := slog.Default()
 
 	loggingSvc := &loggingService{
-		service:  mockService,
+		wrapped:  wrapped{mockService},
 		logger:   logger,
 		modelID:  "test-model",
 		provider: ProviderBuiltIn,
@@ -358,7 +394,7 @@ func TestUseSimplifiedPatch(t *testing.T) {
 	// Test with a service that implements SimplifiedPatcher
 	mockSimplifiedService := &mockSimplifiedLLMService{useSimplified: true}
 	loggingSvc2 := &loggingService{
-		service:  mockSimplifiedService,
+		wrapped:  wrapped{mockSimplifiedService},
 		logger:   logger,
 		modelID:  "test-model-2",
 		provider: ProviderBuiltIn,
@@ -404,3 +440,72 @@ func TestHTTPClientPassedToFactory(t *testing.T) {
 		t.Fatal("Factory returned nil service")
 	}
 }
+
+func TestManagerGetModelInfo(t *testing.T) {
+	manager, err := NewManager(&Config{})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	info := manager.GetModelInfo("claude-sonnet-4.5")
+	if info == nil {
+		t.Fatal("expected model info for a built-in model")
+	}
+	if info.Provider != ProviderAnthropic || !info.SupportsVision || !info.SupportsTools || info.Pricing == nil {
+		t.Errorf("unexpected model info: %+v", info)
+	}
+	if manager.GetModelInfo("non-existent-model") != nil {
+		t.Error("expected nil model info for an unknown model")
+	}
+
+	if err := manager.CheckModel(context.Background(), "predictable"); err != nil {
+		t.Errorf("CheckModel('predictable') failed: %v", err)
+	}
+	if err := manager.CheckModel(context.Background(), "non-existent-model"); err == nil {
+		t.Error("CheckModel('non-existent-model') should have failed but didn't")
+	}
+}
+
+func TestManagerProviderCredentials(t *testing.T) {
+	manager, err := NewManager(&Config{FireworksAPIKey: "env-key"})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if manager.HasModel("claude-opus-4.5") {
+		t.Fatal("expected no anthropic models without a key")
+	}
+
+	if err := manager.SetProviderCredential(ProviderAnthropic, "runtime-key"); err != nil {
+		t.Fatalf("SetProviderCredential failed: %v", err)
+	}
+	if !manager.HasModel("claude-opus-4.5") || !manager.HasModel("qwen3-coder-fireworks") {
+		t.Error("expected anthropic and fireworks models after setting the key")
+	}
+	statuses := map[Provider]ProviderStatus{}
+	for _, status := range manager.ProviderStatuses() {
+		statuses[status.Provider] = status
+	}
+	if s := statuses[ProviderAnthropic]; !s.Configured || !s.Runtime || len(s.Models) == 0 {
+		t.Errorf("unexpected anthropic status: %+v", s)
+	}
+	if s := statuses[ProviderFireworks]; !s.Configured || s.Runtime {
+		t.Errorf("unexpected fireworks status: %+v", s)
+	}
+	if s := statuses[ProviderGemini]; s.Configured || len(s.Models) != 0 {
+		t.Errorf("unexpected gemini status: %+v", s)
+	}
+
+	if err := manager.ClearProviderCredential(ProviderAnthropic); err != nil {
+		t.Fatalf("ClearProviderCredential failed: %v", err)
+	}
+	if manager.HasModel("claude-opus-4.5") || !manager.HasModel("qwen3-coder-fireworks") {
+		t.Error("expected only the environment credentials after clearing")
+	}
+
+	if err := manager.SetProviderCredential(ProviderBuiltIn, "key"); err == nil {
+		t.Error("expected an error for a provider without credentials")
+	}
+	if err := manager.CheckProviderCredential(context.Background(), ProviderAnthropic, ""); err == nil {
+		t.Error("expected an error for an empty credential")
+	}
+}
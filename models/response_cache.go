@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+// cachingService wraps an llm.Service to answer deterministic requests from
+// the response cache in the database. Only requests with a temperature of 0
+// are cached, so that conversations that want varied answers still get them.
+type cachingService struct {
+	wrapped
+	modelID string
+	db      *db.DB
+	logger  *slog.Logger
+}
+
+// Do returns the cached response to a deterministic request, or calls the
+// underlying service and caches its response.
+func (c *cachingService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	if !cacheable(request) {
+		return c.Service.Do(ctx, request)
+	}
+	key, err := responseCacheKey(c.modelID, request)
+	if err != nil {
+		return c.Service.Do(ctx, request)
+	}
+
+	cached, err := c.db.GetCachedResponse(ctx, key)
+	if err != nil {
+		c.logger.Warn("Failed to read response cache", "model", c.modelID, "error", err)
+	}
+	if cached != nil {
+		var response llm.Response
+		if err := json.Unmarshal([]byte(cached.Response), &response); err == nil {
+			response.Usage.CostUSD = 0
+			response.Usage.ResponseCacheHits = 1
+			return &response, nil
+		}
+	}
+
+	response, err := c.Service.Do(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(response); err == nil {
+		if err := c.db.SaveCachedResponse(ctx, key, c.modelID, string(data)); err != nil {
+			c.logger.Warn("Failed to write response cache", "model", c.modelID, "error", err)
+		}
+	}
+	return response, nil
+}
+
+// cacheable reports whether a request asks for a deterministic answer.
+func cacheable(request *llm.Request) bool {
+	return request.Sampling != nil && request.Sampling.Temperature != nil && *request.Sampling.Temperature == 0
+}
+
+// responseCacheKey hashes the model and everything in the request that
+// affects the answer. Tool timings and display data vary between runs of the
+// same conversation, so they are left out.
+func responseCacheKey(modelID string, request *llm.Request) (string, error) {
+	messages := make([]llm.Message, len(request.Messages))
+	for i, message := range request.Messages {
+		message.Content = append([]llm.Content(nil), message.Content...)
+		for j := range message.Content {
+			message.Content[j].ToolUseStartTime = nil
+			message.Content[j].ToolUseEndTime = nil
+			message.Content[j].Display = nil
+		}
+		messages[i] = message
+	}
+	data, err := json.Marshal(struct {
+		Model      string
+		System     []llm.SystemContent
+		Messages   []llm.Message
+		Tools      []*llm.Tool
+		ToolChoice *llm.ToolChoice
+		Thinking   *llm.ThinkingConfig
+		Sampling   *llm.Sampling
+		Format     *llm.ResponseFormat
+	}{modelID, request.System, messages, request.Tools, request.ToolChoice, request.Thinking, request.Sampling, request.ResponseFormat})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// withResponseCache wraps svc with the response cache if it is enabled
+func (m *Manager) withResponseCache(modelID string, svc llm.Service) llm.Service {
+	if !m.responseCache || m.db == nil {
+		return svc
+	}
+	logger := m.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &cachingService{wrapped: wrapped{svc}, modelID: modelID, db: m.db, logger: logger}
+}
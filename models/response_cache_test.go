@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+func TestResponseCache(t *testing.T) {
+	database, err := db.New(db.Config{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mockService := &mockLLMService{}
+	manager := &Manager{db: database, responseCache: true}
+	svc := manager.withResponseCache("mock", mockService)
+
+	zero := 0.0
+	request := func(text string, temperature *float64) *llm.Request {
+		now := time.Now()
+		return &llm.Request{
+			Messages: []llm.Message{{
+				Role: llm.MessageRoleUser,
+				Content: []llm.Content{
+					{Type: llm.ContentTypeToolResult, ToolUseID: "t1", ToolUseStartTime: &now, ToolUseEndTime: &now},
+					{Type: llm.ContentTypeText, Text: text},
+				},
+			}},
+			Sampling: &llm.Sampling{Temperature: temperature},
+		}
+	}
+
+	resp, err := svc.Do(t.Context(), request("Hello", &zero))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Usage.ResponseCacheHits != 0 || mockService.lastRequest == nil {
+		t.Fatalf("expected the first request to reach the model, got %+v", resp.Usage)
+	}
+
+	// The same request, at another time, is answered from the cache
+	mockService.lastRequest = nil
+	resp, err = svc.Do(t.Context(), request("Hello", &zero))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mockService.lastRequest != nil || resp.Usage.ResponseCacheHits != 1 || resp.Usage.CostUSD != 0 || resp.Usage.InputTokens != 10 {
+		t.Errorf("expected a cache hit, got %+v", resp.Usage)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Text != "Hello, world!" {
+		t.Errorf("unexpected cached content: %+v", resp.Content)
+	}
+
+	// Other requests and non-deterministic ones reach the model
+	for _, req := range []*llm.Request{request("Bye", &zero), request("Hello", nil)} {
+		mockService.lastRequest = nil
+		if _, err := svc.Do(t.Context(), req); err != nil {
+			t.Fatal(err)
+		}
+		if mockService.lastRequest == nil {
+			t.Error("expected the request to reach the model")
+		}
+	}
+
+	stats, err := database.GetResponseCacheStats(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 2 || stats.Hits != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// maxSchemaRetries is how many times a response that doesn't match the
+// requested schema is sent back to the model to be fixed.
+const maxSchemaRetries = 2
+
+// structuredService wraps an llm.Service to check responses to requests with
+// a ResponseFormat against its schema. Providers' structured output features
+// don't all enforce every keyword, and some providers have none, so a response
+// that doesn't match is sent back to the model with the validation error.
+type structuredService struct {
+	wrapped
+}
+
+// Do sends the request and retries until the response matches the schema.
+func (s *structuredService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	if request.ResponseFormat == nil {
+		return s.Service.Do(ctx, request)
+	}
+
+	attempt := *request
+	attempt.Messages = append([]llm.Message(nil), request.Messages...)
+	var usage llm.Usage
+	for retries := 0; ; retries++ {
+		response, err := s.Service.Do(ctx, &attempt)
+		if err != nil {
+			return nil, err
+		}
+		usage.Add(response.Usage)
+		if response.StopReason == llm.StopReasonToolUse {
+			// The format applies to the answer, not to tool calls on the way
+			response.Usage = usage
+			return response, nil
+		}
+		verr := request.ResponseFormat.Validate(responseText(response))
+		if verr == nil {
+			response.Usage = usage
+			return response, nil
+		}
+		if retries == maxSchemaRetries {
+			return nil, fmt.Errorf("response does not match the schema after %d attempts: %w", retries+1, verr)
+		}
+		attempt.Messages = append(attempt.Messages, response.ToMessage(), llm.UserStringMessage(
+			"Your response does not match the schema: "+verr.Error()+"\n\n"+request.ResponseFormat.Instruction()))
+	}
+}
+
+// responseText returns the text of a response without its thinking.
+func responseText(response *llm.Response) string {
+	var text strings.Builder
+	for _, content := range response.Content {
+		if content.Type == llm.ContentTypeText {
+			text.WriteString(content.Text)
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+// repliesService answers each request with the next of its replies.
+type repliesService struct {
+	replies  []string
+	requests []*llm.Request
+}
+
+func (s *repliesService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	reply := s.replies[len(s.requests)]
+	s.requests = append(s.requests, request)
+	return &llm.Response{
+		Role:       llm.MessageRoleAssistant,
+		Content:    []llm.Content{{Type: llm.ContentTypeText, Text: reply}},
+		StopReason: llm.StopReasonEndTurn,
+		Usage:      llm.Usage{InputTokens: 10, OutputTokens: 5},
+	}, nil
+}
+
+func (s *repliesService) TokenContextWindow() int { return 1000 }
+func (s *repliesService) MaxImageDimension() int  { return 0 }
+
+func TestStructuredOutput(t *testing.T) {
+	format := &llm.ResponseFormat{Schema: llm.MustSchema(`{"type": "object", "properties": {"n": {"type": "integer"}}, "required": ["n"]}`)}
+	request := &llm.Request{Messages: []llm.Message{llm.UserStringMessage("Pick a number")}, ResponseFormat: format}
+
+	inner := &repliesService{replies: []string{"Sure, 4!", `{"n": "four"}`, `{"n": 4}`}}
+	resp, err := (&structuredService{wrapped{inner}}).Do(t.Context(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content[0].Text != `{"n": 4}` || len(inner.requests) != 3 {
+		t.Fatalf("expected the third reply after two retries, got %q after %d requests", resp.Content[0].Text, len(inner.requests))
+	}
+	if resp.Usage.InputTokens != 30 || resp.Usage.OutputTokens != 15 {
+		t.Errorf("expected the usage of all attempts, got %+v", resp.Usage)
+	}
+	// Each retry shows the model its invalid reply and what was wrong with it
+	retry := inner.requests[2].Messages
+	if len(retry) != 5 || retry[3].Content[0].Text != `{"n": "four"}` || !strings.Contains(retry[4].Content[0].Text, "$.n: expected integer, got string") {
+		t.Errorf("unexpected retry messages: %+v", retry)
+	}
+	if len(request.Messages) != 1 {
+		t.Errorf("the caller's request was modified: %+v", request.Messages)
+	}
+
+	inner = &repliesService{replies: []string{"no", "no", "no"}}
+	if _, err := (&structuredService{wrapped{inner}}).Do(t.Context(), request); err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("expected the schema violation after 3 attempts, got %v", err)
+	}
+
+	// Requests without a format aren't checked
+	inner = &repliesService{replies: []string{"no"}}
+	if _, err := (&structuredService{wrapped{inner}}).Do(t.Context(), &llm.Request{Messages: request.Messages}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+
+	"shelley.exe.dev/llm"
+)
+
+// wrapped is embedded by the services of this package that wrap another
+// service. It passes the optional interfaces of the wrapped service through,
+// so that wrapping a service doesn't hide its sessions, batches or settings;
+// wrappers override the methods they change.
+type wrapped struct {
+	llm.Service
+}
+
+// UseSimplifiedPatch delegates to the wrapped service if it supports it
+func (w wrapped) UseSimplifiedPatch() bool {
+	if sp, ok := w.Service.(llm.SimplifiedPatcher); ok {
+		return sp.UseSimplifiedPatch()
+	}
+	return false
+}
+
+// ValidateSampling delegates to the wrapped service if it supports it
+func (w wrapped) ValidateSampling(s llm.Sampling) error {
+	if sv, ok := w.Service.(llm.SamplingValidator); ok {
+		return sv.ValidateSampling(s)
+	}
+	return nil
+}
+
+// ConfigDetails delegates to the wrapped service if it supports it
+func (w wrapped) ConfigDetails() map[string]string {
+	if ci, ok := w.Service.(ConfigInfo); ok {
+		return ci.ConfigDetails()
+	}
+	return nil
+}
+
+// ResetSession delegates to the wrapped service if it keeps sessions
+func (w wrapped) ResetSession(ctx context.Context, conversationID string) error {
+	if sr, ok := w.Service.(llm.SessionResetter); ok {
+		return sr.ResetSession(ctx, conversationID)
+	}
+	return llm.ErrSessionsUnsupported
+}
+
+// CompactSession delegates to the wrapped service if it can compact sessions
+func (w wrapped) CompactSession(ctx context.Context, conversationID string) error {
+	if sc, ok := w.Service.(llm.SessionCompactor); ok {
+		return sc.CompactSession(ctx, conversationID)
+	}
+	return llm.ErrSessionsUnsupported
+}
+
+// SubmitBatch delegates to the wrapped service if its provider runs batches
+func (w wrapped) SubmitBatch(ctx context.Context, items []llm.BatchItem) (string, error) {
+	if b, ok := w.Service.(llm.Batcher); ok {
+		return b.SubmitBatch(ctx, items)
+	}
+	return "", llm.ErrBatchesUnsupported
+}
+
+// PollBatch delegates to the wrapped service if its provider runs batches
+func (w wrapped) PollBatch(ctx context.Context, batchID string) (*llm.BatchStatus, []llm.BatchResult, error) {
+	if b, ok := w.Service.(llm.Batcher); ok {
+		return b.PollBatch(ctx, batchID)
+	}
+	return nil, nil, llm.ErrBatchesUnsupported
+}
+
+// CancelBatch delegates to the wrapped service if its provider runs batches
+func (w wrapped) CancelBatch(ctx context.Context, batchID string) error {
+	if b, ok := w.Service.(llm.Batcher); ok {
+		return b.CancelBatch(ctx, batchID)
+	}
+	return llm.ErrBatchesUnsupported
+}
@@ -0,0 +1,108 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// maxResponse bounds the body of a response to a plugin's HTTP request.
+const maxResponse = 4 << 20
+
+// httpRequestArgs is the JSON argument of shelley.http_request.
+type httpRequestArgs struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// httpResponse is the JSON result of shelley.http_request.
+type httpResponse struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// httpRequest implements shelley.http_request for the plugin. Failures,
+// including requests to hosts the manifest doesn't allow, are returned to the
+// plugin in the error field.
+func (p *Plugin) httpRequest(ctx context.Context, mod api.Module, ptr, size uint32) uint64 {
+	resp := p.doHTTPRequest(ctx, mod, ptr, size)
+	data, _ := json.Marshal(resp)
+	out, err := writeString(ctx, mod, data)
+	if err != nil {
+		panic(err) // wazero fails the plugin's call with it
+	}
+	return uint64(out)<<32 | uint64(len(data))
+}
+
+func (p *Plugin) doHTTPRequest(ctx context.Context, mod api.Module, ptr, size uint32) httpResponse {
+	data, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		return httpResponse{Error: "request is outside memory"}
+	}
+	var args httpRequestArgs
+	if err := json.Unmarshal(data, &args); err != nil {
+		return httpResponse{Error: "invalid request: " + err.Error()}
+	}
+	u, err := url.Parse(args.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return httpResponse{Error: "url must be an http or https URL"}
+	}
+	if !p.allowedHost(u.Hostname()) {
+		return httpResponse{Error: fmt.Sprintf("host %s is not in the plugin's allowed_hosts", u.Hostname())}
+	}
+
+	method := args.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, args.URL, bytes.NewReader([]byte(args.Body)))
+	if err != nil {
+		return httpResponse{Error: err.Error()}
+	}
+	for name, value := range args.Headers {
+		req.Header.Set(name, value)
+	}
+	// Redirects could leave the allowed hosts
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if !p.allowedHost(req.URL.Hostname()) {
+			return fmt.Errorf("redirect to %s is not in the plugin's allowed_hosts", req.URL.Hostname())
+		}
+		return nil
+	}}
+	res, err := client.Do(req)
+	if err != nil {
+		return httpResponse{Error: err.Error()}
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponse+1))
+	if err != nil {
+		return httpResponse{Error: "failed to read response: " + err.Error()}
+	}
+	if len(body) > maxResponse {
+		return httpResponse{Error: fmt.Sprintf("response is larger than %d bytes", maxResponse)}
+	}
+	headers := make(map[string]string, len(res.Header))
+	for name := range res.Header {
+		headers[name] = res.Header.Get(name)
+	}
+	return httpResponse{Status: res.StatusCode, Headers: headers, Body: string(body)}
+}
+
+// allowedHost reports whether the manifest allows requests to host.
+func (p *Plugin) allowedHost(host string) bool {
+	return slices.ContainsFunc(p.Manifest.AllowedHosts, func(allowed string) bool {
+		return strings.EqualFold(allowed, host)
+	})
+}
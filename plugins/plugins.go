@@ -0,0 +1,374 @@
+// Package plugins runs tools shipped as WebAssembly modules.
+//
+// Shelley loads every <name>.wasm file in the plugins directory at startup.
+// Plugins run in a wazero sandbox with WASI but no filesystem or network; they
+// can only reach the hosts their manifest allows, through the
+// shelley.http_request import.
+//
+// A plugin exports its linear memory as "memory" and these functions. Strings
+// are passed as a pointer and length into memory, and returned packed into an
+// i64 as ptr<<32 | len.
+//
+//	shelley_alloc(size i32) -> i32
+//	    Returns a buffer of size bytes for the host to write input into.
+//	shelley_tool_definition() -> i64
+//	    Returns the tool as JSON: {"name", "description", "input_schema"}.
+//	shelley_tool_run(ptr i32, len i32) -> i64
+//	    Runs the tool on the JSON input and returns JSON:
+//	    {"output": "...", "error": "..."}; a non-empty error fails the call.
+//
+// Plugins may import shelley.http_request(ptr i32, len i32) -> i64, which
+// takes {"method", "url", "headers", "body"} and returns {"status",
+// "headers", "body", "error"}.
+//
+// An optional <name>.json manifest next to the module configures it:
+//
+//	{"allowed_hosts": ["example.atlassian.net"], "env": {"JIRA_TOKEN": "..."}}
+//
+// Every call of a tool runs in a fresh instance of its module, so plugins
+// keep no state between calls and calls can run concurrently.
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"shelley.exe.dev/llm"
+)
+
+const (
+	// runTimeout bounds a call of a plugin tool.
+	runTimeout = 60 * time.Second
+	// memoryLimitPages bounds a plugin's memory (64 KiB pages, so 64 MiB).
+	memoryLimitPages = 1024
+	// maxOutput is the most of a tool's output sent to the model.
+	maxOutput = 64 * 1024
+)
+
+// Manifest configures a plugin. It is read from <name>.json next to the
+// module.
+type Manifest struct {
+	// AllowedHosts are the hosts the plugin may send HTTP requests to.
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	// Env is the plugin's WASI environment, e.g. for credentials.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// definition is the JSON returned by shelley_tool_definition.
+type definition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// result is the JSON returned by shelley_tool_run.
+type result struct {
+	Output string `json:"output"`
+	Error  string `json:"error"`
+}
+
+// Plugin is a loaded plugin module.
+type Plugin struct {
+	// Path is the module's file.
+	Path     string
+	Manifest Manifest
+
+	def      definition
+	logger   *slog.Logger
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+// Host holds the plugins loaded from a directory.
+type Host struct {
+	plugins []*Plugin
+}
+
+// Load loads the plugins in dir. A missing directory has no plugins; a
+// plugin that fails to load is logged and skipped.
+func Load(ctx context.Context, dir string, logger *slog.Logger) (*Host, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Host{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := &Host{}
+	names := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".wasm" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		p, err := LoadPlugin(ctx, path, logger)
+		if err != nil {
+			logger.Warn("Failed to load plugin", "path", path, "error", err)
+			continue
+		}
+		if other, ok := names[p.def.Name]; ok {
+			logger.Warn("Skipping plugin with a duplicate tool name", "path", path, "tool", p.def.Name, "other", other)
+			p.Close(ctx)
+			continue
+		}
+		names[p.def.Name] = path
+		h.plugins = append(h.plugins, p)
+		logger.Info("Loaded plugin", "path", path, "tool", p.def.Name)
+	}
+	return h, nil
+}
+
+// Plugins returns the loaded plugins.
+func (h *Host) Plugins() []*Plugin {
+	return h.plugins
+}
+
+// Tools returns the tools of the loaded plugins.
+func (h *Host) Tools() []*llm.Tool {
+	tools := make([]*llm.Tool, 0, len(h.plugins))
+	for _, p := range h.plugins {
+		tools = append(tools, p.Tool())
+	}
+	return tools
+}
+
+// Close releases the plugins' runtimes.
+func (h *Host) Close(ctx context.Context) error {
+	var errs []error
+	for _, p := range h.plugins {
+		errs = append(errs, p.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// ReadManifest reads the manifest of the module at path. A module without
+// one gets an empty manifest.
+func ReadManifest(path string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(strings.TrimSuffix(path, ".wasm") + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return m, nil
+}
+
+// LoadPlugin compiles the module at path and reads its tool definition. What
+// the plugin writes to stderr is logged to logger.
+func LoadPlugin(ctx context.Context, path string, logger *slog.Logger) (*Plugin, error) {
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plugin{Path: path, Manifest: manifest, logger: logger}
+	p.runtime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(memoryLimitPages))
+	if err := p.instantiateImports(ctx); err != nil {
+		p.runtime.Close(ctx)
+		return nil, err
+	}
+	p.compiled, err = p.runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		p.runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to compile: %w", err)
+	}
+
+	out, err := p.call(ctx, "shelley_tool_definition", nil)
+	if err != nil {
+		p.runtime.Close(ctx)
+		return nil, err
+	}
+	if err := json.Unmarshal(out, &p.def); err != nil {
+		p.runtime.Close(ctx)
+		return nil, fmt.Errorf("invalid tool definition: %w", err)
+	}
+	if err := validateDefinition(p.def); err != nil {
+		p.runtime.Close(ctx)
+		return nil, err
+	}
+	return p, nil
+}
+
+// validateDefinition checks a plugin's tool definition.
+func validateDefinition(def definition) error {
+	if def.Name == "" || strings.TrimSpace(def.Description) == "" {
+		return fmt.Errorf("tool definition needs a name and a description")
+	}
+	if !llm.ValidToolName(def.Name) {
+		return fmt.Errorf("tool name %q must be 1-64 letters, digits, underscores or hyphens", def.Name)
+	}
+	var schema struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(def.InputSchema, &schema); err != nil || schema.Type != "object" {
+		return fmt.Errorf("tool %s: input_schema must be a JSON schema of type object", def.Name)
+	}
+	return nil
+}
+
+// instantiateImports provides WASI and the shelley host module.
+func (p *Plugin) instantiateImports(ctx context.Context) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, p.runtime); err != nil {
+		return err
+	}
+	_, err := p.runtime.NewHostModuleBuilder("shelley").
+		NewFunctionBuilder().WithFunc(p.httpRequest).Export("http_request").
+		Instantiate(ctx)
+	return err
+}
+
+// Name returns the name of the plugin's tool.
+func (p *Plugin) Name() string {
+	return p.def.Name
+}
+
+// Tool returns the plugin's tool.
+func (p *Plugin) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        p.def.Name,
+		Description: p.def.Description,
+		InputSchema: p.def.InputSchema,
+		Run:         p.Run,
+	}
+}
+
+// Run runs the tool on input in a fresh instance of the module.
+func (p *Plugin) Run(ctx context.Context, input json.RawMessage) llm.ToolOut {
+	ctx, cancel := context.WithTimeout(ctx, runTimeout)
+	defer cancel()
+
+	out, err := p.call(ctx, "shelley_tool_run", input)
+	if err != nil {
+		return llm.ErrorfToolOut("%s: %w", p.def.Name, err)
+	}
+	var res result
+	if err := json.Unmarshal(out, &res); err != nil {
+		return llm.ErrorfToolOut("%s: invalid result: %w", p.def.Name, err)
+	}
+	if res.Error != "" {
+		return llm.ErrorfToolOut("%s: %s", p.def.Name, res.Error)
+	}
+	text := res.Output
+	if len(text) > maxOutput {
+		text = text[:maxOutput] + "\n[output truncated]"
+	}
+	if strings.TrimSpace(text) == "" {
+		text = "(no output)"
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(text)}
+}
+
+// Close releases the plugin's runtime.
+func (p *Plugin) Close(ctx context.Context) error {
+	return p.runtime.Close(ctx)
+}
+
+// call instantiates the module and calls the export fn, passing input if it
+// isn't nil, and returns the string it returns.
+func (p *Plugin) call(ctx context.Context, fn string, input []byte) ([]byte, error) {
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithStderr(pluginLog{p.Path, p.logger})
+	for _, k := range sortedKeys(p.Manifest.Env) {
+		config = config.WithEnv(k, p.Manifest.Env[k])
+	}
+	mod, err := p.runtime.InstantiateModule(ctx, p.compiled, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate: %w", err)
+	}
+	defer mod.Close(ctx)
+
+	f := mod.ExportedFunction(fn)
+	if f == nil {
+		return nil, fmt.Errorf("module does not export %s", fn)
+	}
+	var params []uint64
+	if input != nil {
+		ptr, err := writeString(ctx, mod, input)
+		if err != nil {
+			return nil, err
+		}
+		params = []uint64{uint64(ptr), uint64(len(input))}
+	}
+	results, err := f.Call(ctx, params...)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", fn, err)
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("%s returned %d values, want 1", fn, len(results))
+	}
+	return readString(mod, results[0])
+}
+
+// writeString copies data into memory allocated by the module.
+func writeString(ctx context.Context, mod api.Module, data []byte) (uint32, error) {
+	alloc := mod.ExportedFunction("shelley_alloc")
+	if alloc == nil {
+		return 0, fmt.Errorf("module does not export shelley_alloc")
+	}
+	results, err := alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("shelley_alloc failed: %w", err)
+	}
+	ptr := uint32(results[0])
+	if !mod.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("shelley_alloc returned %d, outside memory", ptr)
+	}
+	return ptr, nil
+}
+
+// readString reads a string returned packed as ptr<<32 | len.
+func readString(mod api.Module, packed uint64) ([]byte, error) {
+	ptr, size := uint32(packed>>32), uint32(packed)
+	data, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("returned string at %d (%d bytes) is outside memory", ptr, size)
+	}
+	// Read returns a view of memory, which is gone once the module closes
+	return slices.Clone(data), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// pluginLog logs what a plugin writes to stderr.
+type pluginLog struct {
+	path   string
+	logger *slog.Logger
+}
+
+func (l pluginLog) Write(p []byte) (int, error) {
+	l.logger.Debug("plugin output", "path", l.path, "text", strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,115 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pluginsDir returns a directory with the echo plugin under the given names.
+func pluginsDir(t *testing.T, names ...string) string {
+	t.Helper()
+	wasm, err := os.ReadFile("testdata/echo.wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), wasm, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	dir := pluginsDir(t, "echo.wasm", "echo2.wasm")
+	// Not a plugin
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# plugins"), 0o644)
+	os.WriteFile(filepath.Join(dir, "broken.wasm"), []byte("not wasm"), 0o644)
+
+	host, err := Load(ctx, dir, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close(ctx)
+	// echo2.wasm defines the same tool and broken.wasm doesn't compile
+	tools := host.Tools()
+	if len(tools) != 1 || tools[0].Name != "echo" {
+		t.Fatalf("tools = %v, want just echo", tools)
+	}
+	if !strings.Contains(string(tools[0].InputSchema), `"output"`) {
+		t.Errorf("input schema = %s", tools[0].InputSchema)
+	}
+
+	out := tools[0].Run(ctx, json.RawMessage(`{"output":"hello"}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if got := out.LLMContent[0].Text; got != "hello" {
+		t.Errorf("output = %q, want hello", got)
+	}
+	out = tools[0].Run(ctx, json.RawMessage(`{"error":"boom"}`))
+	if out.Error == nil || out.Error.Error() != "echo: boom" {
+		t.Errorf("error = %v, want echo: boom", out.Error)
+	}
+}
+
+func TestLoadMissingDir(t *testing.T) {
+	host, err := Load(context.Background(), filepath.Join(t.TempDir(), "missing"), slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(host.Plugins()) != 0 {
+		t.Errorf("plugins = %v, want none", host.Plugins())
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	m, err := ReadManifest(filepath.Join(dir, "none.wasm"))
+	if err != nil || len(m.AllowedHosts) != 0 {
+		t.Errorf("missing manifest = %+v, %v; want empty", m, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "jira.json"), []byte(`{"allowed_hosts":["example.atlassian.net"],"env":{"JIRA_TOKEN":"t"}}`), 0o644)
+	m, err = ReadManifest(filepath.Join(dir, "jira.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.AllowedHosts) != 1 || m.Env["JIRA_TOKEN"] != "t" {
+		t.Errorf("manifest = %+v", m)
+	}
+	p := &Plugin{Manifest: m}
+	if !p.allowedHost("Example.Atlassian.net") || p.allowedHost("evil.example.com") {
+		t.Error("allowedHost doesn't follow allowed_hosts")
+	}
+
+	os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{`), 0o644)
+	if _, err := ReadManifest(filepath.Join(dir, "bad.wasm")); err == nil {
+		t.Error("expected an error for an invalid manifest")
+	}
+}
+
+func TestValidateDefinition(t *testing.T) {
+	for _, tt := range []struct {
+		def definition
+		ok  bool
+	}{
+		{definition{Name: "jira", Description: "Searches Jira", InputSchema: json.RawMessage(`{"type":"object"}`)}, true},
+		{definition{Name: "", Description: "Searches Jira", InputSchema: json.RawMessage(`{"type":"object"}`)}, false},
+		{definition{Name: "jira", Description: " ", InputSchema: json.RawMessage(`{"type":"object"}`)}, false},
+		{definition{Name: "jira", Description: "Searches Jira", InputSchema: json.RawMessage(`{"type":"string"}`)}, false},
+		{definition{Name: "jira search", Description: "Searches Jira", InputSchema: json.RawMessage(`{"type":"object"}`)}, false},
+		{definition{Name: strings.Repeat("j", 65), Description: "Searches Jira", InputSchema: json.RawMessage(`{"type":"object"}`)}, false},
+	} {
+		if err := validateDefinition(tt.def); (err == nil) != tt.ok {
+			t.Errorf("validateDefinition(%+v) = %v, want ok=%v", tt.def, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+;; A plugin whose tool returns its input as the result, so the input's
+;; "output" and "error" fields become the tool's. Build with:
+;;   wat2wasm echo.wat -o echo.wasm
+(module
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  (func (export "shelley_alloc") (param $size i32) (result i32)
+    global.get $heap
+    global.get $heap
+    local.get $size
+    i32.add
+    global.set $heap)
+
+  ;; The definition is the 166 bytes of data at offset 16
+  (func (export "shelley_tool_definition") (result i64)
+    i64.const 0x10000000a6)
+
+  (func (export "shelley_tool_run") (param $ptr i32) (param $len i32) (result i64)
+    local.get $ptr
+    i64.extend_i32_u
+    i64.const 32
+    i64.shl
+    local.get $len
+    i64.extend_i32_u
+    i64.or)
+
+  (data (i32.const 16) "{\22name\22:\22echo\22,\22description\22:\22Returns its input's output field.\22,\22input_schema\22:{\22type\22:\22object\22,\22properties\22:{\22output\22:{\22type\22:\22string\22},\22error\22:{\22type\22:\22string\22}}}}"))
@@ -0,0 +1,301 @@
+// Package repomap generates a compact structural map of a repository for the
+// system prompt: its top-level directories, key files, and the exported
+// symbols of its source files, within a token budget.
+package repomap
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"shelley.exe.dev/llm/tokenizer"
+)
+
+const (
+	// maxFiles bounds the number of files considered.
+	maxFiles = 20000
+	// maxSymbolsPerFile bounds the symbols listed for one file.
+	maxSymbolsPerFile = 12
+	// maxSourceSize skips larger source files, which are usually generated.
+	maxSourceSize = 512 * 1024
+)
+
+// keyFiles are top-level files that tell the agent how the project is built and documented.
+var keyFiles = map[string]bool{
+	"README.md": true, "README": true, "README.rst": true, "AGENTS.md": true, "ARCHITECTURE.md": true,
+	"go.mod": true, "package.json": true, "Cargo.toml": true, "pyproject.toml": true, "setup.py": true,
+	"requirements.txt": true, "Gemfile": true, "pom.xml": true, "build.gradle": true, "CMakeLists.txt": true,
+	"Makefile": true, "Dockerfile": true, "docker-compose.yml": true, "tsconfig.json": true,
+}
+
+// skipDirs are directories whose contents are not mapped.
+var skipDirs = map[string]bool{"vendor": true, "node_modules": true, "testdata": true, "third_party": true}
+
+var (
+	pythonSymbolRegexp = regexp.MustCompile(`(?m)^(?:async\s+)?(?:def|class)\s+([A-Za-z][A-Za-z0-9_]*)`)
+	jsSymbolRegexp     = regexp.MustCompile(`(?m)^export\s+(?:default\s+)?(?:async\s+)?(?:function\*?|class|const|let|interface|type|enum)\s+([A-Za-z_$][A-Za-z0-9_$]*)`)
+)
+
+// Generate returns the map of the repository at root, at most budget tokens
+// long as counted by tok. It returns "" if root has no files.
+func Generate(root string, budget int, tok *tokenizer.Tokenizer) string {
+	files := listFiles(root)
+	if len(files) == 0 {
+		return ""
+	}
+
+	// Top-level directories with their file counts, and key files
+	counts := make(map[string]int)
+	var topFiles []string
+	for _, f := range files {
+		if dir, _, ok := strings.Cut(f, "/"); ok {
+			counts[dir]++
+		} else if keyFiles[f] {
+			topFiles = append(topFiles, f)
+		}
+	}
+	dirs := make([]string, 0, len(counts))
+	for dir := range counts {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var b strings.Builder
+	if len(dirs) > 0 {
+		b.WriteString("Directories:")
+		for _, dir := range dirs {
+			fmt.Fprintf(&b, " %s/ (%d)", dir, counts[dir])
+		}
+		b.WriteString("\n")
+	}
+	if len(topFiles) > 0 {
+		fmt.Fprintf(&b, "Key files: %s\n", strings.Join(topFiles, ", "))
+	}
+	used := tok.Count(b.String())
+	if used > budget {
+		return truncate(b.String(), budget, tok)
+	}
+
+	// Symbols, shallowest files first so the budget covers the overall structure
+	sources := files[:0:0]
+	for _, f := range files {
+		if symbolExtractor(f) != nil {
+			sources = append(sources, f)
+		}
+	}
+	sort.SliceStable(sources, func(i, j int) bool {
+		di, dj := strings.Count(sources[i], "/"), strings.Count(sources[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return sources[i] < sources[j]
+	})
+
+	header := "Exported symbols:\n"
+	wroteHeader := false
+	for i, f := range sources {
+		symbols := fileSymbols(root, f)
+		if len(symbols) == 0 {
+			continue
+		}
+		if len(symbols) > maxSymbolsPerFile {
+			symbols = append(symbols[:maxSymbolsPerFile], "...")
+		}
+		line := f + ": " + strings.Join(symbols, ", ") + "\n"
+		extra := tok.Count(line)
+		if !wroteHeader {
+			extra += tok.Count(header)
+		}
+		// Leave room for the note about omitted files unless this is the last one
+		if i < len(sources)-1 {
+			extra += tok.Count("(00000 more source files not shown)\n")
+		}
+		if used+extra > budget {
+			fmt.Fprintf(&b, "(%d more source files not shown)\n", len(sources)-i)
+			break
+		}
+		if !wroteHeader {
+			b.WriteString(header)
+			used += tok.Count(header)
+			wroteHeader = true
+		}
+		b.WriteString(line)
+		used += tok.Count(line)
+	}
+	return truncate(b.String(), budget, tok)
+}
+
+// truncate cuts s to budget tokens, at the end of a line if possible.
+func truncate(s string, budget int, tok *tokenizer.Tokenizer) string {
+	head := tok.Head(s, budget)
+	if len(head) == len(s) {
+		return strings.TrimSuffix(s, "\n")
+	}
+	if i := strings.LastIndex(head, "\n"); i > 0 {
+		return head[:i]
+	}
+	return head
+}
+
+// listFiles returns the repository's files relative to root, using git when
+// possible. Files in skipped or hidden directories are left out.
+func listFiles(root string) []string {
+	var files []string
+	cmd := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	if out, err := cmd.Output(); err == nil {
+		for _, f := range strings.Split(string(out), "\x00") {
+			if f != "" && !skipped(f) {
+				files = append(files, f)
+			}
+			if len(files) >= maxFiles {
+				break
+			}
+		}
+		sort.Strings(files)
+		return files
+	}
+
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != root && (strings.HasPrefix(d.Name(), ".") || skipDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(files) >= maxFiles {
+			return filepath.SkipAll
+		}
+		if rel, err := filepath.Rel(root, p); err == nil && d.Type().IsRegular() {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func skipped(file string) bool {
+	parts := strings.Split(path.Dir(file), "/")
+	for _, part := range parts {
+		if skipDirs[part] || (strings.HasPrefix(part, ".") && part != ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// symbolExtractor returns the function listing the exported symbols of a
+// source file, or nil if the file is not a supported source file.
+func symbolExtractor(file string) func(src []byte) []string {
+	switch ext := path.Ext(file); {
+	case ext == ".go" && !strings.HasSuffix(file, "_test.go"):
+		return goSymbols
+	case ext == ".py":
+		return regexpSymbols(pythonSymbolRegexp, func(name string) bool { return !strings.HasPrefix(name, "_") })
+	case ext == ".js" || ext == ".jsx" || ext == ".ts" || ext == ".tsx" || ext == ".mjs":
+		if strings.Contains(file, ".test.") || strings.Contains(file, ".spec.") || strings.HasSuffix(file, ".d.ts") {
+			return nil
+		}
+		return regexpSymbols(jsSymbolRegexp, nil)
+	}
+	return nil
+}
+
+func fileSymbols(root, file string) []string {
+	extract := symbolExtractor(file)
+	info, err := os.Stat(filepath.Join(root, file))
+	if err != nil || info.Size() > maxSourceSize {
+		return nil
+	}
+	src, err := os.ReadFile(filepath.Join(root, file))
+	if err != nil {
+		return nil
+	}
+	return extract(src)
+}
+
+// goSymbols returns the exported functions, types, methods (as Type.Method),
+// constants and variables of a Go file.
+func goSymbols(src []byte) []string {
+	if strings.HasPrefix(string(src), "// Code generated") {
+		return nil
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	var symbols []string
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverName(d.Recv.List[0].Type)
+				if recv == "" || !ast.IsExported(recv) {
+					continue
+				}
+				symbols = append(symbols, recv+"."+d.Name.Name)
+			} else {
+				symbols = append(symbols, d.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						symbols = append(symbols, s.Name.Name)
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						if name.IsExported() {
+							symbols = append(symbols, name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+func regexpSymbols(re *regexp.Regexp, keep func(string) bool) func(src []byte) []string {
+	return func(src []byte) []string {
+		var symbols []string
+		for _, m := range re.FindAllSubmatch(src, -1) {
+			name := string(m[1])
+			if keep == nil || keep(name) {
+				symbols = append(symbols, name)
+			}
+		}
+		return symbols
+	}
+}
@@ -0,0 +1,78 @@
+package repomap
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm/tokenizer"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":              "module example.com/x\n",
+		"README.md":           "# x\n",
+		"main.go":             "package main\n\nfunc main() {}\n",
+		"store/store.go":      "package store\n\ntype Store struct{}\n\nfunc New() *Store { return nil }\n\nfunc (s *Store) Get(k string) string { return \"\" }\n\nfunc helper() {}\n\nconst MaxSize = 10\n",
+		"store/store_test.go": "package store\n\nfunc TestGet() {}\n",
+		"store/gen.go":        "// Code generated by hand. DO NOT EDIT.\n\npackage store\n\nfunc Generated() {}\n",
+		"web/app.ts":          "export function startApp() {}\nexport default class Router {}\nfunction internal() {}\n",
+		"scripts/tool.py":     "def run():\n    pass\n\nclass Runner:\n    pass\n\ndef _private():\n    pass\n",
+		"node_modules/x/a.js": "export function skipped() {}\n",
+		".hidden/secret.go":   "package hidden\n\nfunc Hidden() {}\n",
+	})
+	if err := exec.Command("git", "-C", root, "init", "-q").Run(); err != nil {
+		t.Skip("git not available")
+	}
+
+	m := Generate(root, 1000, tokenizer.Default)
+	for _, want := range []string{
+		"Directories: scripts/ (1) store/ (3) web/ (1)",
+		"Key files: README.md, go.mod",
+		"store/store.go: Store, New, Store.Get, MaxSize",
+		"web/app.ts: startApp, Router",
+		"scripts/tool.py: run, Runner",
+	} {
+		if !strings.Contains(m, want) {
+			t.Errorf("expected %q in map:\n%s", want, m)
+		}
+	}
+	for _, unwanted := range []string{"helper", "TestGet", "Generated", "internal", "_private", "skipped", "Hidden"} {
+		if strings.Contains(m, unwanted) {
+			t.Errorf("unexpected %q in map:\n%s", unwanted, m)
+		}
+	}
+}
+
+func TestGenerateBudget(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{}
+	for _, pkg := range []string{"a", "b", "c", "d", "e", "f"} {
+		files[pkg+"/"+pkg+".go"] = "package " + pkg + "\n\nfunc ExportedFunctionWithALongName() {}\n"
+	}
+	writeFiles(t, root, files)
+
+	m := Generate(root, 40, tokenizer.Default)
+	if n := tokenizer.Default.Count(m); n > 40 {
+		t.Errorf("map exceeds budget: %d tokens", n)
+	}
+	if !strings.Contains(m, "a/a.go") || !strings.Contains(m, "more source files not shown") {
+		t.Errorf("expected truncated map, got:\n%s", m)
+	}
+}
@@ -0,0 +1,92 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Keychain entry holding the secrets key
+const (
+	keychainService = "shelley-secrets"
+	keychainAccount = "shelley"
+)
+
+// ErrKeychainUnavailable is returned when there is no OS keychain to use:
+// the platform is unsupported or its command-line tool isn't installed.
+var ErrKeychainUnavailable = errors.New("OS keychain is not available")
+
+// KeychainKey returns the secrets key stored in the OS keychain, creating and
+// storing a new one on first use. It uses security(1) on macOS and
+// secret-tool(1) from libsecret on Linux.
+func KeychainKey(ctx context.Context) ([]byte, error) {
+	value, err := keychainLookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		key, err := GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		value = hex.EncodeToString(key)
+		if err := keychainStore(ctx, value); err != nil {
+			return nil, err
+		}
+	}
+	key, err := hex.DecodeString(value)
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("keychain entry %s is not a secrets key", keychainService)
+	}
+	return key, nil
+}
+
+// keychainLookup returns the stored key, or "" if there is none.
+func keychainLookup(ctx context.Context) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+	case "linux":
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", keychainService, "account", keychainAccount)
+	default:
+		return "", ErrKeychainUnavailable
+	}
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", ErrKeychainUnavailable
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// Both tools exit non-zero when there is no matching entry
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read keychain: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func keychainStore(ctx context.Context, value string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "security", "add-generic-password", "-s", keychainService, "-a", keychainAccount, "-w", value)
+	case "linux":
+		cmd = exec.CommandContext(ctx, "secret-tool", "store", "--label=Shelley secrets key", "service", keychainService, "account", keychainAccount)
+		cmd.Stdin = strings.NewReader(value)
+	default:
+		return ErrKeychainUnavailable
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to store key in keychain: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
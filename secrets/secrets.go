@@ -0,0 +1,101 @@
+// Package secrets encrypts values stored at rest, such as provider API keys
+// and secret environment variables.
+//
+// The key comes from a master passphrase, the OS keychain, or, when neither is
+// configured, a random key kept in the database itself. The last option only
+// keeps secrets out of casual view of the database file.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the size of a secrets key in bytes (AES-256).
+const KeySize = 32
+
+// SaltSize is the size of the salt used to derive a key from a passphrase.
+const SaltSize = 16
+
+// passphraseIterations is the PBKDF2-SHA256 work factor for passphrase keys.
+const passphraseIterations = 600_000
+
+// ErrInvalid is returned when a sealed value can't be opened with the key:
+// it was sealed with another key or has been tampered with.
+var ErrInvalid = errors.New("secret cannot be decrypted with this key")
+
+// Cipher seals and opens values with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher returns a Cipher for a key of KeySize bytes.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secrets key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts value, returning the base64 nonce and ciphertext.
+func (c *Cipher) Seal(value string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+// Open decrypts a value returned by Seal.
+func (c *Cipher) Open(sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", ErrInvalid
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	value, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	return string(value), nil
+}
+
+// GenerateKey returns a new random key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GenerateSalt returns a new random salt for DeriveKey.
+func GenerateSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// DeriveKey derives a key from a master passphrase.
+func DeriveKey(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+	return pbkdf2.Key(sha256.New, passphrase, salt, passphraseIterations, KeySize)
+}
@@ -0,0 +1,68 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCipher(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := c.Seal("sk-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "sk-secret") {
+		t.Fatal("sealed value contains the plaintext")
+	}
+	if again, _ := c.Seal("sk-secret"); again == sealed {
+		t.Error("expected a fresh nonce for each seal")
+	}
+	if value, err := c.Open(sealed); err != nil || value != "sk-secret" {
+		t.Errorf("Open = %q, %v", value, err)
+	}
+
+	otherKey, _ := GenerateKey()
+	other, _ := NewCipher(otherKey)
+	if _, err := other.Open(sealed); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid with another key, got %v", err)
+	}
+	if _, err := c.Open("not sealed"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for plaintext, got %v", err)
+	}
+
+	if _, err := NewCipher([]byte("short")); err == nil {
+		t.Error("expected an error for a short key")
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	salt, _ := GenerateSalt()
+	a, err := DeriveKey("correct horse", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := DeriveKey("correct horse", salt)
+	if !bytes.Equal(a, b) || len(a) != KeySize {
+		t.Error("expected the same key for the same passphrase and salt")
+	}
+	if c, _ := DeriveKey("wrong horse", salt); bytes.Equal(a, c) {
+		t.Error("expected a different key for another passphrase")
+	}
+	otherSalt, _ := GenerateSalt()
+	if c, _ := DeriveKey("correct horse", otherSalt); bytes.Equal(a, c) {
+		t.Error("expected a different key for another salt")
+	}
+	if _, err := DeriveKey("", salt); err == nil {
+		t.Error("expected an error for an empty passphrase")
+	}
+}
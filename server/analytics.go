@@ -0,0 +1,267 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+const (
+	// analyticsDefaultDays is the period covered by GET /api/analytics without ?days=
+	analyticsDefaultDays = 30
+	// analyticsMaxDays bounds ?days= so a request doesn't scan the whole history
+	analyticsMaxDays = 365
+)
+
+// AnalyticsAPI is the response of GET /api/analytics
+type AnalyticsAPI struct {
+	Since  time.Time `json:"since"`
+	Bucket string    `json:"bucket"`
+	// Turns counts user prompts; a turn's latency runs from the prompt to the last agent message answering it
+	Turns            int                     `json:"turns"`
+	AvgTurnLatencyMs int64                   `json:"avg_turn_latency_ms"`
+	Conversations    []ConversationAnalytics `json:"conversations"`
+	Tools            []ToolAnalytics         `json:"tools"`
+	Tokens           []TokenAnalytics        `json:"tokens"`
+}
+
+// ConversationAnalytics summarizes the turns of one conversation
+type ConversationAnalytics struct {
+	ConversationID   string `json:"conversation_id"`
+	Turns            int    `json:"turns"`
+	AvgTurnLatencyMs int64  `json:"avg_turn_latency_ms"`
+}
+
+// ToolAnalytics counts the calls of one tool and how many of them failed
+type ToolAnalytics struct {
+	Name        string  `json:"name"`
+	Calls       int     `json:"calls"`
+	Failures    int     `json:"failures"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// TokenAnalytics is the token usage of one model in one time window
+type TokenAnalytics struct {
+	Window       time.Time `json:"window"`
+	Model        string    `json:"model"`
+	Requests     int       `json:"requests"`
+	InputTokens  uint64    `json:"input_tokens"`
+	OutputTokens uint64    `json:"output_tokens"`
+	CostUSD      float64   `json:"cost_usd"`
+}
+
+// analyticsWindow returns the start of the window t falls in
+func analyticsWindow(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if bucket == "week" {
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+// handleAnalytics handles GET /api/analytics. It summarizes the messages of the
+// last ?days= days (30 by default), with token usage per ?bucket=day or week.
+func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	days := analyticsDefaultDays
+	if v := query.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > analyticsMaxDays {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	bucket := query.Get("bucket")
+	switch bucket {
+	case "":
+		bucket = "day"
+	case "day", "week":
+	default:
+		http.Error(w, "Invalid bucket", http.StatusBadRequest)
+		return
+	}
+
+	since := analyticsWindow(time.Now().AddDate(0, 0, -days+1), "day")
+	rows, err := s.db.ListMessagesForAnalytics(r.Context(), since)
+	if err != nil {
+		s.logger.Error("Failed to list messages for analytics", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type turnStats struct {
+		turns   int
+		timed   int
+		latency time.Duration
+	}
+	type tokenKey struct {
+		window time.Time
+		model  string
+	}
+	conversations := map[string]*turnStats{}
+	var conversationOrder []string
+	tools := map[string]*ToolAnalytics{}
+	tokens := map[tokenKey]*TokenAnalytics{}
+	toolNames := map[string]string{} // tool use ID -> tool name
+
+	// The current turn of the conversation being scanned; rows are ordered by conversation
+	var (
+		conversationID string
+		turnStart      time.Time
+		turnEnd        time.Time
+	)
+	endTurn := func() {
+		if stats := conversations[conversationID]; stats != nil && !turnStart.IsZero() && !turnEnd.IsZero() {
+			stats.timed++
+			stats.latency += turnEnd.Sub(turnStart)
+		}
+		turnStart, turnEnd = time.Time{}, time.Time{}
+	}
+
+	for _, row := range rows {
+		if row.ConversationID != conversationID {
+			endTurn()
+			conversationID = row.ConversationID
+			clear(toolNames)
+		}
+		if row.LlmData == nil {
+			continue
+		}
+		var msg llm.Message
+		if err := json.Unmarshal([]byte(*row.LlmData), &msg); err != nil {
+			continue
+		}
+
+		switch row.Type {
+		case string(db.MessageTypeUser):
+			// Tool results come back in user messages; prompts are those with text only
+			hasText, hasToolResult := false, false
+			for _, content := range msg.Content {
+				switch content.Type {
+				case llm.ContentTypeText:
+					hasText = true
+				case llm.ContentTypeToolResult:
+					hasToolResult = true
+					if name, ok := toolNames[content.ToolUseID]; ok && content.ToolError {
+						tools[name].Failures++
+					}
+				}
+			}
+			if !hasText || hasToolResult {
+				continue
+			}
+			endTurn()
+			stats := conversations[row.ConversationID]
+			if stats == nil {
+				stats = &turnStats{}
+				conversations[row.ConversationID] = stats
+				conversationOrder = append(conversationOrder, row.ConversationID)
+			}
+			stats.turns++
+			turnStart = row.CreatedAt
+
+		case string(db.MessageTypeAgent):
+			var usage llm.Usage
+			if row.UsageData != nil {
+				json.Unmarshal([]byte(*row.UsageData), &usage)
+			}
+			if !turnStart.IsZero() {
+				// created_at only has second precision
+				turnEnd = row.CreatedAt
+				if usage.EndTime != nil && usage.EndTime.After(turnStart) {
+					turnEnd = *usage.EndTime
+				}
+			}
+			for _, content := range msg.Content {
+				if content.Type != llm.ContentTypeToolUse {
+					continue
+				}
+				toolNames[content.ID] = content.ToolName
+				tool := tools[content.ToolName]
+				if tool == nil {
+					tool = &ToolAnalytics{Name: content.ToolName}
+					tools[content.ToolName] = tool
+				}
+				tool.Calls++
+			}
+
+			if usage.IsZero() {
+				continue
+			}
+			model := usage.Model
+			if model == "" && row.Model != nil {
+				model = *row.Model
+			}
+			key := tokenKey{window: analyticsWindow(row.CreatedAt, bucket), model: model}
+			entry := tokens[key]
+			if entry == nil {
+				entry = &TokenAnalytics{Window: key.window, Model: model}
+				tokens[key] = entry
+			}
+			entry.Requests++
+			entry.InputTokens += usage.TotalInputTokens()
+			entry.OutputTokens += usage.OutputTokens
+			entry.CostUSD += usage.CostUSD
+		}
+	}
+	endTurn()
+
+	resp := AnalyticsAPI{
+		Since:         since,
+		Bucket:        bucket,
+		Conversations: make([]ConversationAnalytics, 0, len(conversations)),
+		Tools:         make([]ToolAnalytics, 0, len(tools)),
+		Tokens:        make([]TokenAnalytics, 0, len(tokens)),
+	}
+	var timed int
+	var latency time.Duration
+	for _, id := range conversationOrder {
+		stats := conversations[id]
+		entry := ConversationAnalytics{ConversationID: id, Turns: stats.turns}
+		if stats.timed > 0 {
+			entry.AvgTurnLatencyMs = (stats.latency / time.Duration(stats.timed)).Milliseconds()
+		}
+		resp.Turns += stats.turns
+		timed += stats.timed
+		latency += stats.latency
+		resp.Conversations = append(resp.Conversations, entry)
+	}
+	if timed > 0 {
+		resp.AvgTurnLatencyMs = (latency / time.Duration(timed)).Milliseconds()
+	}
+	sort.SliceStable(resp.Conversations, func(i, j int) bool {
+		return resp.Conversations[i].Turns > resp.Conversations[j].Turns
+	})
+
+	for _, tool := range tools {
+		tool.FailureRate = float64(tool.Failures) / float64(tool.Calls)
+		resp.Tools = append(resp.Tools, *tool)
+	}
+	sort.Slice(resp.Tools, func(i, j int) bool {
+		if resp.Tools[i].Calls != resp.Tools[j].Calls {
+			return resp.Tools[i].Calls > resp.Tools[j].Calls
+		}
+		return resp.Tools[i].Name < resp.Tools[j].Name
+	})
+
+	for _, entry := range tokens {
+		resp.Tokens = append(resp.Tokens, *entry)
+	}
+	sort.Slice(resp.Tokens, func(i, j int) bool {
+		if !resp.Tokens[i].Window.Equal(resp.Tokens[j].Window) {
+			return resp.Tokens[i].Window.Before(resp.Tokens[j].Window)
+		}
+		return resp.Tokens[i].Model < resp.Tokens[j].Model
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
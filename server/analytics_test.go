@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAnalytics(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("patch fail", "")
+	h.WaitToolResult()
+	h.WaitResponse()
+	h.waitIdle()
+	h.Chat("bash: echo hi")
+	h.WaitToolResult()
+	h.WaitResponse()
+	h.waitIdle()
+	h.Chat("echo: done")
+	h.WaitResponse()
+	h.waitIdle()
+
+	get := func(query string) (*httptest.ResponseRecorder, AnalyticsAPI) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.handleAnalytics(w, httptest.NewRequest("GET", "/api/analytics"+query, nil))
+		var resp AnalyticsAPI
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	w, resp := get("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp.Bucket != "day" || resp.Turns != 3 {
+		t.Errorf("expected 3 turns bucketed by day, got %d by %s", resp.Turns, resp.Bucket)
+	}
+	if len(resp.Conversations) != 1 || resp.Conversations[0].ConversationID != h.convID || resp.Conversations[0].Turns != 3 {
+		t.Errorf("unexpected conversations: %+v", resp.Conversations)
+	}
+
+	tools := map[string]ToolAnalytics{}
+	for _, tool := range resp.Tools {
+		tools[tool.Name] = tool
+	}
+	if patch := tools["patch"]; patch.Calls != 1 || patch.Failures != 1 || patch.FailureRate != 1 {
+		t.Errorf("expected one failed patch call, got %+v", patch)
+	}
+	if bash := tools["bash"]; bash.Calls != 1 || bash.Failures != 0 {
+		t.Errorf("expected one successful bash call, got %+v", bash)
+	}
+
+	if len(resp.Tokens) != 1 {
+		t.Fatalf("expected token usage of one model in one window, got %+v", resp.Tokens)
+	}
+	tokens := resp.Tokens[0]
+	if tokens.Model == "" || tokens.InputTokens == 0 || tokens.OutputTokens == 0 {
+		t.Errorf("unexpected token usage: %+v", tokens)
+	}
+	if today := analyticsWindow(time.Now(), "day"); !tokens.Window.Equal(today) {
+		t.Errorf("expected window %v, got %v", today, tokens.Window)
+	}
+
+	if _, resp := get("?bucket=week"); len(resp.Tokens) != 1 || resp.Tokens[0].Window.Weekday() != time.Monday {
+		t.Errorf("expected weekly windows to start on Monday, got %+v", resp.Tokens)
+	}
+	if w, _ := get("?days=0"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for days=0, got %d", w.Code)
+	}
+	if w, _ := get("?bucket=month"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown bucket, got %d", w.Code)
+	}
+}
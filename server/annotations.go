@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// Message ratings
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+)
+
+// AnnotationRequest is the body of POST /api/conversation/<id>/messages/<message_id>/annotation.
+// An empty annotation removes it.
+type AnnotationRequest struct {
+	Rating  string `json:"rating,omitempty"` // "up", "down" or empty
+	Flagged bool   `json:"flagged,omitempty"`
+	Note    string `json:"note,omitempty"`
+}
+
+// AnnotationAPI is the API representation of a message annotation
+type AnnotationAPI struct {
+	MessageID string    `json:"message_id"`
+	Rating    string    `json:"rating,omitempty"`
+	Flagged   bool      `json:"flagged"`
+	Note      string    `json:"note,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AnnotatedMessageAPI is an entry of the feedback dataset served by GET /api/annotations
+type AnnotatedMessageAPI struct {
+	AnnotationAPI
+	ConversationID string `json:"conversation_id"`
+	Model          string `json:"model,omitempty"`
+	MessageType    string `json:"message_type"`
+	Text           string `json:"text"`
+}
+
+func toAnnotationAPI(a generated.MessageAnnotation) AnnotationAPI {
+	api := AnnotationAPI{MessageID: a.MessageID, Flagged: a.Flagged, Note: a.Note, UpdatedAt: a.UpdatedAt}
+	if a.Rating != nil {
+		api.Rating = *a.Rating
+	}
+	return api
+}
+
+// messageText returns the text content of a stored LLM message
+func messageText(llmData *string) string {
+	if llmData == nil {
+		return ""
+	}
+	var msg llm.Message
+	if err := json.Unmarshal([]byte(*llmData), &msg); err != nil {
+		return ""
+	}
+	var texts []string
+	for _, content := range msg.Content {
+		if content.Type == llm.ContentTypeText && content.Text != "" {
+			texts = append(texts, content.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// handleMessageAnnotations handles GET /conversation/<id>/annotations
+func (s *Server) handleMessageAnnotations(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	annotations, err := s.db.ListMessageAnnotations(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list annotations", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]AnnotationAPI, 0, len(annotations))
+	for _, a := range annotations {
+		resp = append(resp, toAnnotationAPI(a))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleMessageAnnotation handles POST /conversation/<id>/messages/<message_id>/annotation
+func (s *Server) handleMessageAnnotation(w http.ResponseWriter, r *http.Request, conversationID, messageID string) {
+	ctx := r.Context()
+	message, err := s.db.GetMessageByID(ctx, messageID)
+	if err != nil || message.ConversationID != conversationID {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	var req AnnotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Rating != "" && req.Rating != RatingUp && req.Rating != RatingDown {
+		http.Error(w, "Invalid rating", http.StatusBadRequest)
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+
+	if req.Rating == "" && !req.Flagged && req.Note == "" {
+		if err := s.db.DeleteMessageAnnotation(ctx, messageID); err != nil {
+			s.logger.Error("Failed to delete annotation", "messageID", messageID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	params := generated.UpsertMessageAnnotationParams{
+		MessageID:      messageID,
+		ConversationID: conversationID,
+		Flagged:        req.Flagged,
+		Note:           req.Note,
+	}
+	if req.Rating != "" {
+		params.Rating = &req.Rating
+	}
+	annotation, err := s.db.SaveMessageAnnotation(ctx, params)
+	if err != nil {
+		s.logger.Error("Failed to save annotation", "messageID", messageID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toAnnotationAPI(*annotation))
+}
+
+// handleAnnotatedMessages handles GET /api/annotations, the annotated messages of
+// all conversations with their text, most recently annotated first. It can be
+// filtered with ?rating=up|down and ?flagged=1, and capped with ?limit=N.
+func (s *Server) handleAnnotatedMessages(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	rating := query.Get("rating")
+	flaggedOnly := query.Get("flagged") == "1" || query.Get("flagged") == "true"
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	rows, err := s.db.ListAnnotatedMessages(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list annotated messages", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp := []AnnotatedMessageAPI{}
+	for _, row := range rows {
+		if rating != "" && (row.Rating == nil || *row.Rating != rating) {
+			continue
+		}
+		if flaggedOnly && !row.Flagged {
+			continue
+		}
+		entry := AnnotatedMessageAPI{
+			AnnotationAPI: toAnnotationAPI(generated.MessageAnnotation{
+				MessageID: row.MessageID,
+				Rating:    row.Rating,
+				Flagged:   row.Flagged,
+				Note:      row.Note,
+				UpdatedAt: row.UpdatedAt,
+			}),
+			ConversationID: row.ConversationID,
+			MessageType:    row.Type,
+			Text:           messageText(row.LlmData),
+		}
+		if row.Model != nil {
+			entry.Model = *row.Model
+		}
+		resp = append(resp, entry)
+		if limit > 0 && len(resp) == limit {
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shelley.exe.dev/db"
+)
+
+func TestMessageAnnotations(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: hello there", "")
+	h.WaitResponse()
+	h.waitIdle()
+
+	messages, err := h.db.ListMessages(h.t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var agentMessage string
+	for _, msg := range messages {
+		if msg.Type == string(db.MessageTypeAgent) {
+			agentMessage = msg.MessageID
+		}
+	}
+	if agentMessage == "" {
+		t.Fatal("no agent message")
+	}
+
+	getAnnotations := func() []AnnotationAPI {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("GET", "/"+h.convID+"/annotations", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var annotations []AnnotationAPI
+		json.Unmarshal(w.Body.Bytes(), &annotations)
+		return annotations
+	}
+	if got := getAnnotations(); len(got) != 0 {
+		t.Fatalf("expected no annotations, got %v", got)
+	}
+
+	path := "/messages/" + agentMessage + "/annotation"
+	if w := h.post(path, AnnotationRequest{Rating: "sideways"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid rating, got %d", w.Code)
+	}
+	if w := h.post("/messages/missing/annotation", AnnotationRequest{Rating: RatingUp}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown message, got %d", w.Code)
+	}
+
+	w := h.post(path, AnnotationRequest{Rating: RatingDown, Flagged: true, Note: " wrong file "})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	got := getAnnotations()
+	if len(got) != 1 || got[0].MessageID != agentMessage || got[0].Rating != RatingDown || !got[0].Flagged || got[0].Note != "wrong file" {
+		t.Fatalf("unexpected annotations: %+v", got)
+	}
+
+	// The feedback dataset includes the message text
+	exportAnnotations := func(query string) []AnnotatedMessageAPI {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.handleAnnotatedMessages(w, httptest.NewRequest("GET", "/api/annotations"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var entries []AnnotatedMessageAPI
+		json.Unmarshal(w.Body.Bytes(), &entries)
+		return entries
+	}
+	entries := exportAnnotations("?flagged=1")
+	if len(entries) != 1 || entries[0].ConversationID != h.convID || entries[0].Text != "hello there" || entries[0].Model != "predictable" {
+		t.Fatalf("unexpected export: %+v", entries)
+	}
+	if entries := exportAnnotations("?rating=up"); len(entries) != 0 {
+		t.Errorf("expected no thumbs-up entries, got %+v", entries)
+	}
+
+	// Updating replaces the annotation; clearing it removes it
+	if w := h.post(path, AnnotationRequest{Rating: RatingUp}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := getAnnotations(); len(got) != 1 || got[0].Rating != RatingUp || got[0].Flagged || got[0].Note != "" {
+		t.Fatalf("unexpected annotations after update: %+v", got)
+	}
+	if w := h.post(path, AnnotationRequest{}); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if got := getAnnotations(); len(got) != 0 {
+		t.Fatalf("expected the annotation to be removed, got %+v", got)
+	}
+}
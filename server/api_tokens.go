@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// apiTokenPrefix starts every API token so leaked tokens are easy to recognize.
+const apiTokenPrefix = "shelley_"
+
+// API token scopes. A read token can only make GET requests; an execute token
+// can do anything except manage tokens.
+const (
+	apiTokenScopeRead    = "read"
+	apiTokenScopeExecute = "execute"
+)
+
+// APITokenRequest is the request body for creating an API token
+type APITokenRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+// APITokenAPI is the API representation of an API token. Token is only set
+// in the response that creates it.
+type APITokenAPI struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func toAPITokenAPI(t generated.ApiToken) APITokenAPI {
+	return APITokenAPI{
+		ID:         t.TokenID,
+		Name:       t.Name,
+		Scope:      t.Scope,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
+
+// hashAPIToken returns the stored form of a token
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAPIToken returns a random token
+func newAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + hex.EncodeToString(b), nil
+}
+
+type apiTokenContextKey struct{}
+
+// apiTokenFromContext returns the token that authenticated a request, or nil
+// when the request did not use one.
+func apiTokenFromContext(ctx context.Context) *generated.ApiToken {
+	token, _ := ctx.Value(apiTokenContextKey{}).(*generated.ApiToken)
+	return token
+}
+
+// apiTokenMiddleware authenticates requests that carry an
+// "Authorization: Bearer" API token. Such requests act as the token's owner
+// and skip the CSRF and identity header checks, which exist for browsers.
+// Requests without a token pass through unchanged.
+func (s *Server) apiTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !strings.HasPrefix(bearer, apiTokenPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, err := s.db.GetAPITokenByHash(r.Context(), hashAPIToken(bearer))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "invalid API token", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to look up API token", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if token.Scope == apiTokenScopeRead && !isReadOnlyRequest(r) {
+			http.Error(w, "API token is read-only", http.StatusForbidden)
+			return
+		}
+		if err := s.db.TouchAPIToken(r.Context(), token.TokenID); err != nil {
+			s.logger.Warn("Failed to record API token use", "id", token.TokenID, "error", err)
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), apiTokenContextKey{}, token))
+		if s.requireHeader != "" {
+			r.Header.Set(s.requireHeader, token.Owner)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isReadOnlyRequest reports whether a request can't change anything. Websocket
+// upgrades are GETs but open interactive shells, so they don't count.
+func isReadOnlyRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return r.Header.Get("Upgrade") == ""
+}
+
+// validateAPIToken checks an API token's name and scope
+func validateAPIToken(req APITokenRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("token name is required")
+	}
+	if req.Scope != apiTokenScopeRead && req.Scope != apiTokenScopeExecute {
+		return fmt.Errorf("token scope must be %q or %q", apiTokenScopeRead, apiTokenScopeExecute)
+	}
+	return nil
+}
+
+// handleAPITokens handles /api/tokens: GET lists the tokens, POST creates one.
+func (s *Server) handleAPITokens(w http.ResponseWriter, r *http.Request) {
+	if apiTokenFromContext(r.Context()) != nil {
+		http.Error(w, "API tokens cannot manage API tokens", http.StatusForbidden)
+		return
+	}
+	ctx := r.Context()
+	owner := s.requestUser(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		tokens, err := s.db.ListAPITokens(ctx, owner)
+		if err != nil {
+			s.logger.Error("Failed to list API tokens", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		result := make([]APITokenAPI, 0, len(tokens))
+		for _, token := range tokens {
+			result = append(result, toAPITokenAPI(token))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	case http.MethodPost:
+		var req APITokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateAPIToken(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		secret, err := newAPIToken()
+		if err != nil {
+			s.logger.Error("Failed to generate API token", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		token, err := s.db.CreateAPIToken(ctx, generated.CreateAPITokenParams{
+			Owner:     owner,
+			Name:      strings.TrimSpace(req.Name),
+			TokenHash: hashAPIToken(secret),
+			Scope:     req.Scope,
+		})
+		if err != nil {
+			s.logger.Error("Failed to create API token", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		result := toAPITokenAPI(*token)
+		result.Token = secret
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(result)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPIToken handles DELETE /api/tokens/{id}, which revokes a token.
+func (s *Server) handleAPIToken(w http.ResponseWriter, r *http.Request) {
+	if apiTokenFromContext(r.Context()) != nil {
+		http.Error(w, "API tokens cannot manage API tokens", http.StatusForbidden)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+	if err := s.db.DeleteAPIToken(r.Context(), s.requestUser(r), id); errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "API token not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		s.logger.Error("Failed to revoke API token", "id", id, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
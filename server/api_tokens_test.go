@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPITokens(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.server.requireHeader = "X-Exedev-Userid"
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	handler := h.server.apiTokenMiddleware(RequireHeaderMiddleware(h.server.requireHeader)(CSRFMiddleware()(mux)))
+
+	// request makes a request as a browser user when token is empty, else with the token
+	request := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		r := httptest.NewRequest(method, path, bytes.NewReader(data))
+		if token == "" {
+			r.Header.Set("X-Exedev-Userid", "alice")
+			r.Header.Set("X-Shelley-Request", "1")
+		} else {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	create := func(name, scope string) APITokenAPI {
+		t.Helper()
+		w := request("POST", "/api/tokens", "", APITokenRequest{Name: name, Scope: scope})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		var token APITokenAPI
+		json.Unmarshal(w.Body.Bytes(), &token)
+		if !strings.HasPrefix(token.Token, apiTokenPrefix) {
+			t.Fatalf("expected the token in the response, got %+v", token)
+		}
+		return token
+	}
+
+	if w := request("POST", "/api/tokens", "", APITokenRequest{Name: "ci", Scope: "admin"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown scope, got %d", w.Code)
+	}
+	reader := create("dashboard", apiTokenScopeRead)
+	executor := create("ci", apiTokenScopeExecute)
+
+	// Only the hash is stored, and the list doesn't show tokens
+	if _, err := h.db.GetAPITokenByHash(t.Context(), reader.Token); err == nil {
+		t.Error("expected the plaintext token not to be stored")
+	}
+	w := request("GET", "/api/tokens", "", nil)
+	if strings.Contains(w.Body.String(), reader.Token) || strings.Contains(w.Body.String(), executor.Token) {
+		t.Error("expected the list not to contain tokens")
+	}
+	var listed []APITokenAPI
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	if len(listed) != 2 {
+		t.Errorf("expected 2 tokens, got %d", len(listed))
+	}
+
+	if w := request("GET", "/api/conversations", "shelley_bogus", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unknown token, got %d", w.Code)
+	}
+	if w := request("GET", "/api/conversations", reader.Token, nil); w.Code != http.StatusOK {
+		t.Errorf("expected a read token to list conversations, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := request("POST", "/api/snippets", reader.Token, SnippetRequest{Name: "x", Body: "y"}); w.Code != http.StatusForbidden {
+		t.Errorf("expected a read token to be refused a write, got %d", w.Code)
+	}
+	if w := request("POST", "/api/snippets", executor.Token, SnippetRequest{Name: "x", Body: "y"}); w.Code != http.StatusCreated {
+		t.Errorf("expected an execute token to write without CSRF header, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := request("GET", "/api/tokens", executor.Token, nil); w.Code != http.StatusForbidden {
+		t.Errorf("expected tokens not to manage tokens, got %d", w.Code)
+	}
+
+	// The token acts as its owner
+	snippets, err := h.db.ListSnippets(t.Context(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 1 {
+		t.Errorf("expected the snippet to belong to the token owner, got %d", len(snippets))
+	}
+
+	// Revoked tokens stop working
+	if w := request("DELETE", fmt.Sprintf("/api/tokens/%d", executor.ID), "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w := request("GET", "/api/conversations", executor.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a revoked token, got %d", w.Code)
+	}
+	if w := request("DELETE", fmt.Sprintf("/api/tokens/%d", executor.ID), "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a revoked token, got %d", w.Code)
+	}
+}
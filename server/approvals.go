@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"shelley.exe.dev/claudetool"
+)
+
+// CommandApprovalAPI is a risky shell command the agent wants to run, held
+// until the user approves or denies it
+type CommandApprovalAPI struct {
+	ID         string `json:"id"`
+	Command    string `json:"command"`
+	Class      string `json:"class"`
+	WorkingDir string `json:"working_dir"`
+	// Explanation says what the command will do, from a fast model; empty
+	// if none could explain it
+	Explanation string    `json:"explanation,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// CommandApprovalRequest is the request body for deciding on a command
+// awaiting approval
+type CommandApprovalRequest struct {
+	ID      string `json:"id"`
+	Approve bool   `json:"approve"`
+	// Reason is sent to the agent when the command is denied
+	Reason string `json:"reason,omitempty"`
+}
+
+// pendingApproval is a command awaiting approval.
+type pendingApproval struct {
+	CommandApprovalAPI
+	decided chan error // receives nil when approved, the denial otherwise
+}
+
+// approveCommand is the bash tool's ApproveCommand in conversations that
+// require approving risky commands. It holds the command until the user
+// decides on it or the turn is cancelled.
+func (cm *ConversationManager) approveCommand(ctx context.Context, req claudetool.CommandApproval) error {
+	p := &pendingApproval{
+		CommandApprovalAPI: CommandApprovalAPI{
+			ID:          uuid.New().String(),
+			Command:     req.Command,
+			Class:       req.Class,
+			WorkingDir:  req.WorkingDir,
+			Explanation: req.Explanation,
+			RequestedAt: time.Now(),
+		},
+		decided: make(chan error, 1),
+	}
+	cm.mu.Lock()
+	cm.approval = p
+	onApproval := cm.onApproval
+	cm.mu.Unlock()
+	cm.logger.Info("Holding risky command for approval", "class", req.Class)
+	cm.publishApproval(&p.CommandApprovalAPI)
+	if onApproval != nil {
+		onApproval(p.CommandApprovalAPI)
+	}
+
+	var err error
+	select {
+	case err = <-p.decided:
+	case <-ctx.Done():
+		err = fmt.Errorf("the command was not approved before the turn ended: %w", ctx.Err())
+	}
+
+	cm.mu.Lock()
+	if cm.approval == p {
+		cm.approval = nil
+	}
+	cm.mu.Unlock()
+	cm.publishApproval(nil)
+	return err
+}
+
+// publishApproval tells clients about the command awaiting approval, if any.
+func (cm *ConversationManager) publishApproval(approval *CommandApprovalAPI) {
+	cm.mu.Lock()
+	onStateChange := cm.onStateChange
+	state := ConversationState{
+		ConversationID: cm.conversationID,
+		Working:        cm.agentWorking,
+		Model:          cm.modelID,
+		Approval:       approval,
+	}
+	cm.mu.Unlock()
+	if onStateChange != nil {
+		onStateChange(state)
+	}
+}
+
+// pendingApproval returns the command awaiting approval, or nil.
+func (cm *ConversationManager) pendingApproval() *CommandApprovalAPI {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.approval == nil {
+		return nil
+	}
+	approval := cm.approval.CommandApprovalAPI
+	return &approval
+}
+
+// decideApproval approves or denies the command awaiting approval with the
+// given ID, and returns it. It returns nil if there is no such command.
+func (cm *ConversationManager) decideApproval(id string, approve bool, reason string) *CommandApprovalAPI {
+	cm.mu.Lock()
+	p := cm.approval
+	if p == nil || p.ID != id {
+		cm.mu.Unlock()
+		return nil
+	}
+	cm.approval = nil
+	cm.mu.Unlock()
+
+	if approve {
+		p.decided <- nil
+	} else {
+		denial := "the user denied running this command"
+		if reason != "" {
+			denial += ": " + reason
+		}
+		p.decided <- errors.New(denial)
+	}
+	return &p.CommandApprovalAPI
+}
+
+// notifyCommandApproval dispatches notifications that a command awaits the
+// user's approval.
+func (s *Server) notifyCommandApproval(conversationID string, approval CommandApprovalAPI) {
+	ctx := context.Background()
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		s.logger.Warn("Failed to get conversation for approval notification", "conversationID", conversationID, "error", err)
+		return
+	}
+	s.mu.Lock()
+	manager := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	end := turnEnd{
+		Event:     EventApprovalNeeded,
+		Text:      approval.Explanation,
+		Body:      "Wants to run: " + strings.Join(strings.Fields(approval.Command), " "),
+		Reason:    "a " + strings.ReplaceAll(approval.Class, "_", " ") + " command needs your approval",
+		NeedsUser: true,
+	}
+	if manager != nil {
+		manager.mu.Lock()
+		end.Owner = manager.pushOwner
+		end.Duration = time.Since(manager.turnStarted)
+		manager.mu.Unlock()
+	}
+	s.dispatchNotification(ctx, conversation, end)
+}
+
+// handleCommandApproval handles GET /conversation/<id>/approval, the command
+// awaiting approval or null, and POST /conversation/<id>/approval, which
+// approves or denies it.
+func (s *Server) handleCommandApproval(w http.ResponseWriter, r *http.Request, conversationID string) {
+	s.mu.Lock()
+	manager := s.activeConversations[conversationID]
+	s.mu.Unlock()
+
+	if r.Method == http.MethodPost {
+		var req CommandApprovalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		var approval *CommandApprovalAPI
+		if manager != nil {
+			approval = manager.decideApproval(req.ID, req.Approve, strings.TrimSpace(req.Reason))
+		}
+		if approval == nil {
+			http.Error(w, "No such command awaits approval", http.StatusConflict)
+			return
+		}
+		s.audit(r, AuditActionCommandApproval, conversationID, map[string]any{
+			"command":  approval.Command,
+			"class":    approval.Class,
+			"approved": req.Approve,
+		})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var approval *CommandApprovalAPI
+	if manager != nil {
+		approval = manager.pendingApproval()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approval)
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/claudetool/bashkit"
+)
+
+func TestCommandApproval(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	// Risky commands are explained by a model from the provider
+	h.server.toolSetConfig.LLMProvider = h.server.llmManager
+
+	dir := t.TempDir()
+	build := filepath.Join(dir, "build")
+	if err := os.Mkdir(build, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	h.NewConversation("echo: hello", dir)
+	h.WaitResponse()
+	h.waitIdle()
+	if w := h.post("/settings", ConversationSettings{ApproveRiskyCommands: true}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	getApproval := func() *CommandApprovalAPI {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("GET", "/"+h.convID+"/approval", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET approval: %d", w.Code)
+		}
+		var approval *CommandApprovalAPI
+		if err := json.Unmarshal(w.Body.Bytes(), &approval); err != nil {
+			t.Fatal(err)
+		}
+		return approval
+	}
+	waitApproval := func() *CommandApprovalAPI {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if approval := getApproval(); approval != nil {
+				return approval
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("timed out waiting for an approval request")
+		return nil
+	}
+
+	// Commands that aren't risky run without asking
+	if approval := getApproval(); approval != nil {
+		t.Fatalf("expected no approval request, got %+v", approval)
+	}
+
+	// A denied command doesn't run, and the agent is told why
+	h.Chat("bash: rm -rf " + build)
+	approval := waitApproval()
+	if approval.Class != bashkit.RiskDestructive || approval.Command != "rm -rf "+build || approval.WorkingDir != dir {
+		t.Errorf("unexpected approval request %+v", approval)
+	}
+	if approval.Explanation == "" {
+		t.Error("expected the command to be explained")
+	}
+	if w := h.post("/approval", CommandApprovalRequest{ID: "other"}); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for an unknown approval, got %d", w.Code)
+	}
+	if w := h.post("/approval", CommandApprovalRequest{ID: approval.ID, Reason: "the build is needed"}); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if result := h.WaitToolResult(); !strings.Contains(result, "denied") || !strings.Contains(result, "the build is needed") {
+		t.Errorf("tool result = %q, want the denial", result)
+	}
+	h.waitIdle()
+	if _, err := os.Stat(build); err != nil {
+		t.Fatalf("expected the denied command not to run: %v", err)
+	}
+	if approval := getApproval(); approval != nil {
+		t.Fatalf("expected no approval request after the decision, got %+v", approval)
+	}
+
+	// An approved one runs
+	h.Chat("bash: rm -rf " + build)
+	approval = waitApproval()
+	if w := h.post("/approval", CommandApprovalRequest{ID: approval.ID, Approve: true}); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	h.waitIdle()
+	if _, err := os.Stat(build); !os.IsNotExist(err) {
+		t.Fatalf("expected the approved command to run, got %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+// Audit log actions
+const (
+	AuditActionToolCall        = "tool_call"
+	AuditActionPlanApproved    = "plan_approved"
+	AuditActionCommandApproval = "command_approval"
+	AuditActionConfigChange    = "config_change"
+	AuditActionLogin           = "login"
+)
+
+// auditActorAgent is the actor of the tool calls the agent makes.
+const auditActorAgent = "agent"
+
+// maxAuditToolInput is how much of a tool call's input is kept in the audit log.
+const maxAuditToolInput = 4096
+
+// auditPageSize is the number of events exported per database query.
+const auditPageSize = 500
+
+// AuditEventAPI is the API representation of an audit log event
+type AuditEventAPI struct {
+	ID             int64           `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Actor          string          `json:"actor"`
+	Action         string          `json:"action"`
+	ConversationID string          `json:"conversation_id,omitempty"`
+	Details        json.RawMessage `json:"details"`
+}
+
+func toAuditEventAPI(e generated.AuditLog) AuditEventAPI {
+	return AuditEventAPI{
+		ID:             e.AuditID,
+		CreatedAt:      e.CreatedAt,
+		Actor:          e.Actor,
+		Action:         e.Action,
+		ConversationID: e.ConversationID,
+		Details:        json.RawMessage(e.Details),
+	}
+}
+
+// recordAuditEvent appends an event to the audit log. Failures are logged:
+// auditing must not break the action being audited.
+func recordAuditEvent(ctx context.Context, database *db.DB, logger *slog.Logger, actor, action, conversationID string, details map[string]any) {
+	data, err := json.Marshal(details)
+	if err != nil {
+		logger.Error("Failed to encode audit event", "action", action, "error", err)
+		return
+	}
+	if err := database.InsertAuditEvent(context.WithoutCancel(ctx), generated.InsertAuditEventParams{
+		Actor:          actor,
+		Action:         action,
+		ConversationID: conversationID,
+		Details:        string(data),
+	}); err != nil {
+		logger.Error("Failed to record audit event", "action", action, "error", err)
+	}
+}
+
+// audit records an action taken by the user making r. Requests made with an
+// API token name the token in the details.
+func (s *Server) audit(r *http.Request, action, conversationID string, details map[string]any) {
+	if details == nil {
+		details = map[string]any{}
+	}
+	if token := apiTokenFromContext(r.Context()); token != nil {
+		details["api_token"] = token.Name
+	}
+	recordAuditEvent(r.Context(), s.db, s.logger, s.requestUser(r), action, conversationID, details)
+}
+
+// auditToolCalls wraps beforeTool, which may be nil, to record each tool call
+// in the audit log, including the calls a before_tool hook blocks.
+func (cm *ConversationManager) auditToolCalls(beforeTool loop.BeforeToolFunc) loop.BeforeToolFunc {
+	database, logger, conversationID := cm.db, cm.logger, cm.conversationID
+	return func(ctx context.Context, toolName string, input json.RawMessage) ([]llm.Content, error) {
+		var content []llm.Content
+		var err error
+		if beforeTool != nil {
+			content, err = beforeTool(ctx, toolName, input)
+		}
+		details := map[string]any{"tool": toolName}
+		if len(input) <= maxAuditToolInput && json.Valid(input) {
+			details["input"] = input
+		} else {
+			details["input"] = string(input[:min(len(input), maxAuditToolInput)])
+			details["input_truncated"] = true
+		}
+		if err != nil {
+			details["blocked"] = err.Error()
+		}
+		recordAuditEvent(ctx, database, logger, auditActorAgent, AuditActionToolCall, conversationID, details)
+		return content, err
+	}
+}
+
+// auditedConfigPath reports whether a state-changing request to path changes
+// configuration, and the conversation it configures, if any.
+func auditedConfigPath(path string) (conversationID string, ok bool) {
+	switch {
+	case path == "/upgrade", path == "/exit", path == "/api/system-prompt-template", path == "/api/config/reload",
+		path == "/api/admin/response-cache":
+		return "", true
+	case path == "/api/custom-models", strings.HasPrefix(path, "/api/custom-models/"),
+		strings.HasPrefix(path, "/api/providers/"),
+		path == "/api/tokens", strings.HasPrefix(path, "/api/tokens/"),
+		path == "/api/http-tools", strings.HasPrefix(path, "/api/http-tools/"),
+		path == "/api/templates", strings.HasPrefix(path, "/api/templates/"):
+		return "", true
+	}
+	rest, found := strings.CutPrefix(path, "/api/conversation/")
+	if !found {
+		return "", false
+	}
+	id, sub, _ := strings.Cut(rest, "/")
+	switch sub {
+	case "settings", "hooks", "hooks/remove", "env", "env/unset", "plan",
+		"directories", "directories/remove", "approval":
+		return id, true
+	}
+	return "", false
+}
+
+// auditMiddleware records the first API request of each user since the server
+// started as a login, and successful configuration changes. It must run after
+// the identity of the request is established.
+func (s *Server) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			s.auditLogin(r)
+		}
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		conversationID, ok := auditedConfigPath(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if sw.status < 300 {
+			s.audit(r, AuditActionConfigChange, conversationID, map[string]any{"method": r.Method, "path": r.URL.Path})
+		}
+	})
+}
+
+// auditLogin records a login the first time a user is seen. Without an
+// identity header there are no users to tell apart, so nothing is recorded.
+func (s *Server) auditLogin(r *http.Request) {
+	token := apiTokenFromContext(r.Context())
+	if s.requireHeader == "" && token == nil {
+		return
+	}
+	key := s.requestUser(r)
+	if token != nil {
+		key += "\x00" + strconv.FormatInt(token.TokenID, 10)
+	}
+	if _, seen := s.auditedLogins.LoadOrStore(key, true); !seen {
+		s.audit(r, AuditActionLogin, "", map[string]any{"remote_addr": r.RemoteAddr, "user_agent": r.UserAgent()})
+	}
+}
+
+// statusWriter remembers the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// SetAdmins sets the users, by identity header value, allowed to use the
+// admin API. Without an identity header everyone is an admin.
+func (s *Server) SetAdmins(admins []string) {
+	s.updateSettings(func(settings *Settings) { settings.Admins = admins })
+}
+
+func (s *Server) isAdmin(r *http.Request) bool {
+	return s.isAdminUser(s.requestUser(r))
+}
+
+// isAdminUser reports whether user, an identity header value, is an admin.
+func (s *Server) isAdminUser(user string) bool {
+	return s.requireHeader == "" || slices.Contains(s.Settings().Admins, user)
+}
+
+// auditFilter reads the event filters from the query string: action, actor,
+// conversation_id, since (RFC 3339) and before (an event ID, for paging).
+func auditFilter(r *http.Request) (generated.ListAuditEventsParams, error) {
+	q := r.URL.Query()
+	params := generated.ListAuditEventsParams{
+		BeforeID:       math.MaxInt64,
+		Action:         q.Get("action"),
+		Actor:          q.Get("actor"),
+		ConversationID: q.Get("conversation_id"),
+	}
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return params, err
+		}
+		params.Since = since.UTC()
+	}
+	if v := q.Get("before"); v != "" {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return params, err
+		}
+		params.BeforeID = before
+	}
+	return params, nil
+}
+
+// handleAuditLog handles GET /api/admin/audit, which returns up to limit
+// (default 100, at most 1000) events, newest first.
+func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	params, err := auditFilter(r)
+	if err != nil {
+		http.Error(w, "Invalid filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	params.Limit = 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		params.Limit = limit
+		if limit > 1000 {
+			params.Limit = 1000
+		}
+	}
+
+	events, err := s.db.ListAuditEvents(r.Context(), params)
+	if err != nil {
+		s.logger.Error("Failed to list audit events", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	result := make([]AuditEventAPI, 0, len(events))
+	for _, e := range events {
+		result = append(result, toAuditEventAPI(e))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleAuditExport handles GET /api/admin/audit/export, which downloads all
+// events matching the filters as JSON lines, or as CSV with format=csv.
+func (s *Server) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	params, err := auditFilter(r)
+	if err != nil {
+		http.Error(w, "Invalid filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "jsonl"
+	}
+	if format != "jsonl" && format != "csv" {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	var write func(e generated.AuditLog) error
+	var csvWriter *csv.Writer
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		csvWriter = csv.NewWriter(w)
+		csvWriter.Write([]string{"id", "created_at", "actor", "action", "conversation_id", "details"})
+		write = func(e generated.AuditLog) error {
+			return csvWriter.Write([]string{strconv.FormatInt(e.AuditID, 10), e.CreatedAt.UTC().Format(time.RFC3339), e.Actor, e.Action, e.ConversationID, e.Details})
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		write = func(e generated.AuditLog) error {
+			return enc.Encode(toAuditEventAPI(e))
+		}
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="shelley-audit.`+format+`"`)
+
+	params.Limit = auditPageSize
+	for {
+		events, err := s.db.ListAuditEvents(r.Context(), params)
+		if err != nil {
+			// The response has started; end it early and log the failure
+			s.logger.Error("Failed to export audit events", "error", err)
+			return
+		}
+		for _, e := range events {
+			if err := write(e); err != nil {
+				return
+			}
+		}
+		if len(events) < auditPageSize {
+			break
+		}
+		params.BeforeID = events[len(events)-1].AuditID
+	}
+	if csvWriter != nil {
+		csvWriter.Flush()
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db/generated"
+)
+
+func TestAuditToolCalls(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("bash: echo audited", "")
+	h.WaitToolResult()
+
+	events, err := h.db.ListAuditEvents(t.Context(), generated.ListAuditEventsParams{BeforeID: 1 << 62, Action: AuditActionToolCall, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ConversationID != h.convID || events[0].Actor != auditActorAgent {
+		t.Fatalf("expected the tool call to be audited, got %+v", events)
+	}
+	if !strings.Contains(events[0].Details, `"tool":"bash"`) || !strings.Contains(events[0].Details, "echo audited") {
+		t.Errorf("unexpected details: %s", events[0].Details)
+	}
+
+	// The log is append-only
+	if err := h.db.Pool().Exec(t.Context(), "DELETE FROM audit_log"); err == nil {
+		t.Error("expected deleting audit events to fail")
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.server.requireHeader = "X-Exedev-Userid"
+	h.server.SetAdmins([]string{"admin"})
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	handler := h.server.apiTokenMiddleware(RequireHeaderMiddleware(h.server.requireHeader)(CSRFMiddleware()(h.server.auditMiddleware(mux))))
+
+	request := func(method, path, user string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		r := httptest.NewRequest(method, path, bytes.NewReader(data))
+		r.Header.Set("X-Exedev-Userid", user)
+		r.Header.Set("X-Shelley-Request", "1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request("POST", "/api/tokens", "alice", APITokenRequest{Name: "ci", Scope: apiTokenScopeRead}); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	request("GET", "/api/conversations", "alice", nil)
+	if w := request("POST", "/api/tokens", "alice", APITokenRequest{Name: "bad"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+
+	if w := request("GET", "/api/admin/audit", "alice", nil); w.Code != http.StatusForbidden {
+		t.Errorf("expected non-admins to be refused, got %d", w.Code)
+	}
+	w := request("GET", "/api/admin/audit?actor=alice", "admin", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var events []AuditEventAPI
+	json.Unmarshal(w.Body.Bytes(), &events)
+	// Newest first: one config change (the failed one isn't recorded) and one login
+	if len(events) != 2 || events[0].Action != AuditActionConfigChange || events[1].Action != AuditActionLogin {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if !strings.Contains(string(events[0].Details), `"path":"/api/tokens"`) {
+		t.Errorf("unexpected details: %s", events[0].Details)
+	}
+
+	w = request("GET", "/api/admin/audit/export?format=csv&action=login", "admin", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	// A header and the logins of alice and admin
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "id,created_at,actor,action") {
+		t.Errorf("unexpected export: %q", w.Body.String())
+	}
+}
+
+func TestAuditedConfigPath(t *testing.T) {
+	for _, tt := range []struct {
+		path           string
+		conversationID string
+		audited        bool
+	}{
+		{"/api/tokens", "", true},
+		{"/api/config/reload", "", true},
+		{"/api/admin/response-cache", "", true},
+		{"/api/http-tools", "", true},
+		{"/api/http-tools/3", "", true},
+		{"/api/templates", "", true},
+		{"/api/templates/7", "", true},
+		{"/api/conversation/c1/settings", "c1", true},
+		{"/api/conversation/c1/directories", "c1", true},
+		{"/api/conversation/c1/directories/remove", "c1", true},
+		{"/api/conversation/c1/approval", "c1", true},
+		{"/api/conversation/c1/chat", "", false},
+		{"/api/conversations", "", false},
+	} {
+		conversationID, audited := auditedConfigPath(tt.path)
+		if conversationID != tt.conversationID || audited != tt.audited {
+			t.Errorf("auditedConfigPath(%q) = %q, %v; want %q, %v", tt.path, conversationID, audited, tt.conversationID, tt.audited)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.server.SetBasePath("/shelley/")
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	handler := h.server.basePathHandler(mux)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	if w := get("/shelley/api/conversations"); w.Code != http.StatusOK {
+		t.Errorf("expected the API under the base path, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := get("/api/conversations"); w.Code != http.StatusNotFound {
+		t.Errorf("expected the API at the root to be gone, got %d", w.Code)
+	}
+	if w := get("/shelley"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/shelley/" {
+		t.Errorf("expected a redirect to the base path, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w := get("/shelley/")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the UI under the base path, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"base_path":"/shelley"`) {
+		t.Error("expected the base path in the init data")
+	}
+	if strings.Contains(body, `src="/main.js"`) || strings.Contains(body, `href="/main.css"`) {
+		t.Error("expected asset links to be rewritten under the base path")
+	}
+}
+
+func TestSetBasePath(t *testing.T) {
+	s := &Server{}
+	for in, want := range map[string]string{"": "", "/": "", "shelley": "/shelley", "/shelley/": "/shelley", "/a/b": "/a/b"} {
+		s.SetBasePath(in)
+		if s.basePath != want {
+			t.Errorf("SetBasePath(%q) = %q, want %q", in, s.basePath, want)
+		}
+	}
+}
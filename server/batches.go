@@ -0,0 +1,713 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/llmhttp"
+	"shelley.exe.dev/slug"
+)
+
+const (
+	// maxBatchTasks bounds the prompts of one batch.
+	maxBatchTasks = 100
+	// maxParallelBatchTasks bounds the conversations a parallel batch runs at once.
+	maxParallelBatchTasks = 4
+	// batchesListed is how many of a user's batches /api/batches returns.
+	batchesListed = 50
+)
+
+// batchPollInterval is how often a batch checks whether its running task's
+// turn has ended.
+var batchPollInterval = 500 * time.Millisecond
+
+// providerBatchPollInterval is how often a provider batch's status is
+// polled. Providers take minutes to hours to run a batch.
+var providerBatchPollInterval = time.Minute
+
+// BatchRequest is the request body for POST /api/batches
+type BatchRequest struct {
+	Prompts []string `json:"prompts"`
+	// Mode is sequential (the default), running the prompts in order in one
+	// conversation, or parallel, running each in a new conversation.
+	Mode  string `json:"mode,omitempty"`
+	Model string `json:"model,omitempty"`
+	Cwd   string `json:"cwd,omitempty"`
+	// ConversationID runs a sequential batch in an existing conversation
+	ConversationID string `json:"conversation_id,omitempty"`
+	// ProviderBatch submits a parallel batch to the provider's batch API,
+	// which answers each prompt once, without tools, at half the price.
+	// Results come in within a day, each in a new conversation.
+	ProviderBatch bool `json:"provider_batch,omitempty"`
+}
+
+// BatchTaskAPI is a prompt of a batch and how it went
+type BatchTaskAPI struct {
+	ID             int64      `json:"id"`
+	Prompt         string     `json:"prompt"`
+	Status         string     `json:"status"`
+	Error          string     `json:"error,omitempty"`
+	ConversationID *string    `json:"conversation_id,omitempty"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+// BatchAPI is a batch of prompts as returned by the API
+type BatchAPI struct {
+	ID             int64     `json:"id"`
+	Mode           string    `json:"mode"`
+	Model          string    `json:"model"`
+	Cwd            string    `json:"cwd,omitempty"`
+	ConversationID *string   `json:"conversation_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	// ProviderBatchID is the provider's ID of a batch submitted to its batch
+	// API, and ProviderStatus the batch's status there when last polled
+	ProviderBatchID *string `json:"provider_batch_id,omitempty"`
+	ProviderStatus  string  `json:"provider_status,omitempty"`
+	// Status is running while tasks are pending or running, then failed if a
+	// task failed, canceled if one was canceled, and done otherwise
+	Status string         `json:"status"`
+	Tasks  []BatchTaskAPI `json:"tasks"`
+}
+
+func toBatchAPI(batch generated.Batch, tasks []generated.BatchTask) BatchAPI {
+	result := BatchAPI{
+		ID:              batch.BatchID,
+		Mode:            batch.Mode,
+		Model:           batch.Model,
+		Cwd:             batch.Cwd,
+		ConversationID:  batch.ConversationID,
+		CreatedAt:       batch.CreatedAt,
+		ProviderBatchID: batch.ProviderBatchID,
+		ProviderStatus:  batch.ProviderStatus,
+		Status:          db.BatchTaskDone,
+		Tasks:           make([]BatchTaskAPI, 0, len(tasks)),
+	}
+	for _, t := range tasks {
+		result.Tasks = append(result.Tasks, BatchTaskAPI{
+			ID:             t.TaskID,
+			Prompt:         t.Prompt,
+			Status:         t.Status,
+			Error:          t.Error,
+			ConversationID: t.ConversationID,
+			StartedAt:      t.StartedAt,
+			FinishedAt:     t.FinishedAt,
+		})
+		switch {
+		case t.Status == db.BatchTaskPending || t.Status == db.BatchTaskRunning:
+			result.Status = db.BatchTaskRunning
+		case t.Status == db.BatchTaskFailed && result.Status != db.BatchTaskRunning:
+			result.Status = db.BatchTaskFailed
+		case t.Status == db.BatchTaskCanceled && result.Status == db.BatchTaskDone:
+			result.Status = db.BatchTaskCanceled
+		}
+	}
+	return result
+}
+
+// handleBatches handles GET /api/batches, listing the user's recent batches,
+// and POST /api/batches, which queues a batch and starts running it.
+func (s *Server) handleBatches(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := s.requestUser(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		batches, err := s.db.ListBatches(ctx, owner, batchesListed)
+		if err != nil {
+			s.logger.Error("Failed to list batches", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		result := make([]BatchAPI, 0, len(batches))
+		for _, batch := range batches {
+			tasks, err := s.db.ListBatchTasks(ctx, batch.BatchID)
+			if err != nil {
+				s.logger.Error("Failed to list batch tasks", "batchID", batch.BatchID, "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			result = append(result, toBatchAPI(batch, tasks))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+
+	case http.MethodPost:
+		var req BatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		params, err := s.batchParams(ctx, &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		params.Owner = owner
+
+		// A sequential batch runs in one conversation, created up front
+		if params.Mode == db.BatchModeSequential && params.ConversationID == nil {
+			conversationID, err := s.createBatchConversation(ctx, params.Cwd, params.Model)
+			if err != nil {
+				s.logger.Error("Failed to create batch conversation", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			params.ConversationID = &conversationID
+		}
+
+		// A provider batch is submitted before it is queued, so that it isn't
+		// queued if the provider refuses it
+		if req.ProviderBatch {
+			providerBatchID, err := s.submitProviderBatch(ctx, params.Model, req.Prompts)
+			if errors.Is(err, llm.ErrBatchesUnsupported) {
+				http.Error(w, fmt.Sprintf("model %s has no batch API", params.Model), http.StatusBadRequest)
+				return
+			} else if err != nil {
+				s.logger.Error("Failed to submit provider batch", "model", params.Model, "error", err)
+				http.Error(w, "Failed to submit batch to the provider", http.StatusBadGateway)
+				return
+			}
+			params.ProviderBatchID = &providerBatchID
+		}
+
+		batch, err := s.db.CreateBatch(ctx, params, req.Prompts)
+		if err != nil {
+			s.logger.Error("Failed to create batch", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		tasks, err := s.db.ListBatchTasks(ctx, batch.BatchID)
+		if err != nil {
+			s.logger.Error("Failed to list batch tasks", "batchID", batch.BatchID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		s.logger.Info("Queued batch", "batchID", batch.BatchID, "mode", batch.Mode, "tasks", len(tasks))
+		go s.runBatch(context.WithoutCancel(ctx), *batch)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(toBatchAPI(*batch, tasks))
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// batchParams validates a batch request and fills in the defaults.
+func (s *Server) batchParams(ctx context.Context, req *BatchRequest) (generated.CreateBatchParams, error) {
+	var params generated.CreateBatchParams
+	if len(req.Prompts) == 0 {
+		return params, fmt.Errorf("prompts are required")
+	}
+	if len(req.Prompts) > maxBatchTasks {
+		return params, fmt.Errorf("a batch can have at most %d prompts", maxBatchTasks)
+	}
+	for _, prompt := range req.Prompts {
+		if strings.TrimSpace(prompt) == "" {
+			return params, fmt.Errorf("prompts cannot be empty")
+		}
+	}
+
+	params.Mode = req.Mode
+	if params.Mode == "" {
+		params.Mode = db.BatchModeSequential
+		if req.ProviderBatch {
+			params.Mode = db.BatchModeParallel
+		}
+	}
+	if params.Mode != db.BatchModeSequential && params.Mode != db.BatchModeParallel {
+		return params, fmt.Errorf("mode must be %s or %s", db.BatchModeSequential, db.BatchModeParallel)
+	}
+	if req.ProviderBatch && params.Mode != db.BatchModeParallel {
+		return params, fmt.Errorf("provider batches must be parallel")
+	}
+
+	params.Model = req.Model
+	params.Cwd = req.Cwd
+	if req.ConversationID != "" {
+		if params.Mode != db.BatchModeSequential {
+			return params, fmt.Errorf("only sequential batches can run in an existing conversation")
+		}
+		conversation, err := s.db.GetConversationByID(ctx, req.ConversationID)
+		if err != nil {
+			return params, fmt.Errorf("conversation not found: %s", req.ConversationID)
+		}
+		params.ConversationID = &conversation.ConversationID
+		if conversation.Model != nil && *conversation.Model != "" {
+			params.Model = *conversation.Model
+		}
+		if conversation.Cwd != nil {
+			params.Cwd = *conversation.Cwd
+		}
+	} else if params.Cwd != "" {
+		if err := validateDirectory(params.Cwd); err != nil {
+			return params, err
+		}
+	}
+
+	if params.Model == "" {
+		params.Model = s.Settings().DefaultModel
+	}
+	if params.Model == "" && s.predictableOnly {
+		params.Model = "predictable"
+	}
+	if _, err := s.llmManager.GetService(params.Model); err != nil {
+		return params, fmt.Errorf("unsupported model: %s", params.Model)
+	}
+	return params, nil
+}
+
+// handleBatch handles GET /api/batches/{id}
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	batch, ok := s.ownBatch(w, r)
+	if !ok {
+		return
+	}
+	tasks, err := s.db.ListBatchTasks(r.Context(), batch.BatchID)
+	if err != nil {
+		s.logger.Error("Failed to list batch tasks", "batchID", batch.BatchID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toBatchAPI(*batch, tasks))
+}
+
+// handleCancelBatch handles POST /api/batches/{id}/cancel. Pending tasks are
+// canceled, and so are the turns of running ones and the provider's batch.
+func (s *Server) handleCancelBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	batch, ok := s.ownBatch(w, r)
+	if !ok {
+		return
+	}
+	if batch.ProviderBatchID != nil {
+		// Results that come in anyway are not ingested, as their tasks are canceled
+		if err := s.cancelProviderBatch(ctx, *batch); err != nil {
+			s.logger.Warn("Failed to cancel provider batch", "batchID", batch.BatchID, "providerBatchID", *batch.ProviderBatchID, "error", err)
+		}
+	}
+	if err := s.db.CancelBatch(ctx, batch.BatchID); err != nil {
+		s.logger.Error("Failed to cancel batch", "batchID", batch.BatchID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	tasks, err := s.db.ListBatchTasks(ctx, batch.BatchID)
+	if err != nil {
+		s.logger.Error("Failed to list batch tasks", "batchID", batch.BatchID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for _, task := range tasks {
+		if task.Status != db.BatchTaskRunning || task.ConversationID == nil {
+			continue
+		}
+		// Finish the task first, so that the runner doesn't record the canceled turn as done
+		if err := s.db.FinishBatchTask(ctx, task.TaskID, db.BatchTaskCanceled, ""); err != nil {
+			s.logger.Error("Failed to cancel batch task", "taskID", task.TaskID, "error", err)
+			continue
+		}
+		s.mu.Lock()
+		manager, ok := s.activeConversations[*task.ConversationID]
+		s.mu.Unlock()
+		if ok && manager.IsAgentWorking() {
+			if err := manager.CancelConversation(ctx); err != nil {
+				s.logger.Error("Failed to cancel batch conversation", "conversationID", *task.ConversationID, "error", err)
+			}
+		}
+	}
+	s.handleBatch(w, r)
+}
+
+// ownBatch returns the batch named in the path if it belongs to the user, or
+// writes an error.
+func (s *Server) ownBatch(w http.ResponseWriter, r *http.Request) (*generated.Batch, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid batch ID", http.StatusBadRequest)
+		return nil, false
+	}
+	batch, err := s.db.GetBatch(r.Context(), id)
+	if err != nil {
+		s.logger.Error("Failed to get batch", "batchID", id, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if batch == nil || batch.Owner != s.requestUser(r) {
+		http.Error(w, "Batch not found", http.StatusNotFound)
+		return nil, false
+	}
+	return batch, true
+}
+
+// createBatchConversation creates a conversation for batch tasks.
+func (s *Server) createBatchConversation(ctx context.Context, cwd, modelID string) (string, error) {
+	var cwdPtr *string
+	if cwd != "" {
+		cwdPtr = &cwd
+	}
+	conversation, err := s.db.CreateConversation(ctx, nil, true, cwdPtr, &modelID)
+	if err != nil {
+		return "", err
+	}
+	go s.publishConversationListUpdate(ConversationListUpdate{
+		Type:         "update",
+		Conversation: conversation,
+	})
+	return conversation.ConversationID, nil
+}
+
+// resumeBatches restarts the batches left unfinished when the server last
+// stopped. Tasks that were running wait for their resumed turns to end.
+func (s *Server) resumeBatches(ctx context.Context) {
+	batches, err := s.db.ListUnfinishedBatches(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list unfinished batches", "error", err)
+		return
+	}
+	for _, batch := range batches {
+		s.logger.Info("Resuming batch", "batchID", batch.BatchID)
+		go s.runBatch(ctx, batch)
+	}
+}
+
+// runBatch runs the unfinished tasks of a batch: one after the other in the
+// batch's conversation, or each in its own conversation, a few at a time.
+func (s *Server) runBatch(ctx context.Context, batch generated.Batch) {
+	// Slugs and provider batch polls yield to interactive requests too
+	ctx = llmhttp.WithPriority(ctx, priorityBackground.llm)
+	tasks, err := s.db.ListBatchTasks(ctx, batch.BatchID)
+	if err != nil {
+		s.logger.Error("Failed to list batch tasks", "batchID", batch.BatchID, "error", err)
+		return
+	}
+
+	if batch.ProviderBatchID != nil {
+		s.runProviderBatch(ctx, batch, tasks)
+		return
+	}
+
+	if batch.Mode == db.BatchModeSequential {
+		for _, task := range tasks {
+			s.runBatchTask(ctx, batch, task, *batch.ConversationID)
+		}
+		return
+	}
+
+	sem := make(chan struct{}, maxParallelBatchTasks)
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			s.runBatchTask(ctx, batch, task, "")
+		}()
+	}
+	wg.Wait()
+}
+
+// runBatchTask sends a task's prompt to conversationID, or to a new
+// conversation if it is empty, waits for the turn to end and records how it
+// went.
+func (s *Server) runBatchTask(ctx context.Context, batch generated.Batch, task generated.BatchTask, conversationID string) {
+	switch task.Status {
+	case db.BatchTaskRunning:
+		// Started before a restart; its turn was resumed or ended
+		if task.ConversationID != nil {
+			s.waitForBatchTurn(ctx, *task.ConversationID)
+			s.finishBatchTask(ctx, task, *task.ConversationID)
+		}
+		return
+	case db.BatchTaskPending:
+	default:
+		return
+	}
+
+	if conversationID == "" {
+		var err error
+		conversationID, err = s.createBatchConversation(ctx, batch.Cwd, batch.Model)
+		if err != nil {
+			s.logger.Error("Failed to create batch conversation", "batchID", batch.BatchID, "error", err)
+			if err := s.db.FinishBatchTask(ctx, task.TaskID, db.BatchTaskFailed, err.Error()); err != nil {
+				s.logger.Error("Failed to record batch task", "taskID", task.TaskID, "error", err)
+			}
+			return
+		}
+	} else {
+		// Let a turn the user started in the conversation end first
+		s.waitForBatchTurn(ctx, conversationID)
+	}
+
+	started, err := s.db.StartBatchTask(ctx, task.TaskID, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to start batch task", "taskID", task.TaskID, "error", err)
+		return
+	}
+	if !started {
+		// Canceled while waiting
+		return
+	}
+	task.Status = db.BatchTaskRunning
+
+	if err := s.sendPrompt(withTurnPriority(ctx, priorityBackground), conversationID, batch.Model, task.Prompt); err != nil {
+		s.logger.Warn("Failed to run batch task", "taskID", task.TaskID, "conversationID", conversationID, "error", err)
+		if err := s.db.FinishBatchTask(ctx, task.TaskID, db.BatchTaskFailed, err.Error()); err != nil {
+			s.logger.Error("Failed to record batch task", "taskID", task.TaskID, "error", err)
+		}
+		return
+	}
+	s.waitForBatchTurn(ctx, conversationID)
+	s.finishBatchTask(ctx, task, conversationID)
+}
+
+// sendPrompt sends prompt as a user message, starting a turn with the
+// priority of ctx.
+func (s *Server) sendPrompt(ctx context.Context, conversationID, modelID, prompt string) error {
+	service, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		return fmt.Errorf("unsupported model: %s", modelID)
+	}
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	manager.RunStartHooks(ctx)
+
+	userMessage := llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: prompt}},
+	}
+	firstMessage, err := manager.AcceptUserMessage(ctx, service, modelID, userMessage)
+	if err != nil {
+		return err
+	}
+	if firstMessage {
+		go func() {
+			slugCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+			defer cancel()
+			_, err := slug.GenerateSlug(slugCtx, s.llmManager, s.db, s.logger, conversationID, prompt, modelID)
+			if err != nil {
+				s.logger.Warn("Failed to generate slug for conversation", "conversationID", conversationID, "error", err)
+			} else {
+				go s.notifySubscribers(ctx, conversationID)
+			}
+		}()
+	}
+	return nil
+}
+
+// waitForBatchTurn waits until the agent isn't working in the conversation.
+func (s *Server) waitForBatchTurn(ctx context.Context, conversationID string) {
+	for {
+		s.mu.Lock()
+		manager, ok := s.activeConversations[conversationID]
+		s.mu.Unlock()
+		if !ok || !manager.IsAgentWorking() {
+			return
+		}
+		// Keep the conversation from being cleaned up as idle
+		manager.Touch()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(batchPollInterval):
+		}
+	}
+}
+
+// finishBatchTask records a task whose turn ended as failed if the turn
+// ended with an error, and as done otherwise.
+func (s *Server) finishBatchTask(ctx context.Context, task generated.BatchTask, conversationID string) {
+	status, errText := db.BatchTaskDone, ""
+	msg, err := s.db.GetLatestMessage(ctx, conversationID)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		status, errText = db.BatchTaskFailed, err.Error()
+	} else if msg != nil && msg.Type == string(db.MessageTypeError) {
+		status, errText = db.BatchTaskFailed, "the turn ended with an error"
+		if msg.LlmData != nil {
+			var llmMsg llm.Message
+			if json.Unmarshal([]byte(*msg.LlmData), &llmMsg) == nil {
+				for _, c := range llmMsg.Content {
+					if c.Type == llm.ContentTypeText && c.Text != "" {
+						errText = c.Text
+						break
+					}
+				}
+			}
+		}
+	}
+	if err := s.db.FinishBatchTask(ctx, task.TaskID, status, errText); err != nil {
+		s.logger.Error("Failed to record batch task", "taskID", task.TaskID, "error", err)
+	}
+}
+
+// providerBatchCustomID names a task in a provider batch by its position.
+func providerBatchCustomID(position int) string {
+	return fmt.Sprintf("prompt-%d", position)
+}
+
+// batcher returns the batch API of a model's service.
+func (s *Server) batcher(modelID string) (llm.Batcher, error) {
+	service, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported model: %s", modelID)
+	}
+	batcher, ok := service.(llm.Batcher)
+	if !ok {
+		return nil, llm.ErrBatchesUnsupported
+	}
+	return batcher, nil
+}
+
+// submitProviderBatch submits each prompt as a request of its own to the
+// model's batch API, and returns the provider's ID of the batch.
+func (s *Server) submitProviderBatch(ctx context.Context, modelID string, prompts []string) (string, error) {
+	batcher, err := s.batcher(modelID)
+	if err != nil {
+		return "", err
+	}
+	items := make([]llm.BatchItem, 0, len(prompts))
+	for i, prompt := range prompts {
+		items = append(items, llm.BatchItem{
+			CustomID: providerBatchCustomID(i),
+			Request: &llm.Request{Messages: []llm.Message{{
+				Role:    llm.MessageRoleUser,
+				Content: []llm.Content{{Type: llm.ContentTypeText, Text: prompt}},
+			}}},
+		})
+	}
+	return batcher.SubmitBatch(ctx, items)
+}
+
+// cancelProviderBatch asks the provider to stop running a batch.
+func (s *Server) cancelProviderBatch(ctx context.Context, batch generated.Batch) error {
+	batcher, err := s.batcher(batch.Model)
+	if err != nil {
+		return err
+	}
+	return batcher.CancelBatch(ctx, *batch.ProviderBatchID)
+}
+
+// runProviderBatch polls a provider batch until it ends, then records each
+// result in a new conversation. Tasks the provider has no result for fail.
+func (s *Server) runProviderBatch(ctx context.Context, batch generated.Batch, tasks []generated.BatchTask) {
+	batcher, err := s.batcher(batch.Model)
+	if err != nil {
+		s.logger.Error("Failed to poll provider batch", "batchID", batch.BatchID, "error", err)
+		return
+	}
+	var results []llm.BatchResult
+	for {
+		status, polled, err := batcher.PollBatch(ctx, *batch.ProviderBatchID)
+		if err != nil {
+			// Keep polling; the provider may be down for a while
+			s.logger.Warn("Failed to poll provider batch", "batchID", batch.BatchID, "providerBatchID", *batch.ProviderBatchID, "error", err)
+		} else {
+			if status.Status != batch.ProviderStatus {
+				batch.ProviderStatus = status.Status
+				if err := s.db.UpdateBatchProviderStatus(ctx, batch.BatchID, status.Status); err != nil {
+					s.logger.Error("Failed to record provider batch status", "batchID", batch.BatchID, "error", err)
+				}
+			}
+			if status.Ended {
+				results = polled
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(providerBatchPollInterval):
+		}
+	}
+
+	byID := make(map[string]llm.BatchResult, len(results))
+	for _, result := range results {
+		byID[result.CustomID] = result
+	}
+	for _, task := range tasks {
+		if task.Status != db.BatchTaskPending {
+			continue
+		}
+		result, ok := byID[providerBatchCustomID(int(task.Position))]
+		if !ok {
+			result.Err = errors.New("no result from the provider")
+		}
+		s.ingestProviderBatchResult(ctx, batch, task, result)
+	}
+}
+
+// ingestProviderBatchResult records a task's prompt and the provider's
+// answer in a new conversation.
+func (s *Server) ingestProviderBatchResult(ctx context.Context, batch generated.Batch, task generated.BatchTask, result llm.BatchResult) {
+	finish := func(status, errText string) {
+		if err := s.db.FinishBatchTask(ctx, task.TaskID, status, errText); err != nil {
+			s.logger.Error("Failed to record batch task", "taskID", task.TaskID, "error", err)
+		}
+	}
+	conversationID, err := s.createBatchConversation(ctx, batch.Cwd, batch.Model)
+	if err != nil {
+		s.logger.Error("Failed to create batch conversation", "batchID", batch.BatchID, "error", err)
+		finish(db.BatchTaskFailed, err.Error())
+		return
+	}
+	started, err := s.db.StartBatchTask(ctx, task.TaskID, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to start batch task", "taskID", task.TaskID, "error", err)
+		return
+	}
+	if !started {
+		// Canceled while the provider ran it
+		return
+	}
+
+	userMessage := llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: task.Prompt}},
+	}
+	if err := s.recordMessage(ctx, conversationID, userMessage, llm.Usage{}); err != nil {
+		finish(db.BatchTaskFailed, err.Error())
+		return
+	}
+	if result.Err != nil {
+		errorMessage := llm.Message{
+			Role:      llm.MessageRoleAssistant,
+			Content:   []llm.Content{{Type: llm.ContentTypeText, Text: "LLM request failed: " + result.Err.Error()}},
+			EndOfTurn: true,
+			ErrorType: llm.ErrorTypeLLMRequest,
+		}
+		if err := s.recordMessage(ctx, conversationID, errorMessage, llm.Usage{}); err != nil {
+			s.logger.Error("Failed to record batch error", "conversationID", conversationID, "error", err)
+		}
+		finish(db.BatchTaskFailed, result.Err.Error())
+		return
+	}
+	message := result.Response.ToMessage()
+	message.Role = llm.MessageRoleAssistant
+	message.EndOfTurn = true
+	if err := s.recordMessage(ctx, conversationID, message, result.Response.Usage); err != nil {
+		finish(db.BatchTaskFailed, err.Error())
+		return
+	}
+	finish(db.BatchTaskDone, "")
+
+	slugCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	if _, err := slug.GenerateSlug(slugCtx, s.llmManager, s.db, s.logger, conversationID, task.Prompt, batch.Model); err != nil {
+		s.logger.Warn("Failed to generate slug for conversation", "conversationID", conversationID, "error", err)
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+)
+
+func TestBatches(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	batchPollInterval = 10 * time.Millisecond
+	h.server.requireHeader = "X-Exedev-Userid"
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path, user string, body any) (int, []byte) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("X-Exedev-Userid", user)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code, w.Body.Bytes()
+	}
+	submit := func(req BatchRequest) BatchAPI {
+		t.Helper()
+		code, body := do("POST", "/api/batches", "alice", req)
+		if code != http.StatusCreated {
+			t.Fatalf("submit: status %d: %s", code, body)
+		}
+		var batch BatchAPI
+		json.Unmarshal(body, &batch)
+		return batch
+	}
+	wait := func(id int64) BatchAPI {
+		t.Helper()
+		deadline := time.Now().Add(h.timeout)
+		for {
+			_, body := do("GET", "/api/batches/"+strconv.FormatInt(id, 10), "alice", nil)
+			var batch BatchAPI
+			json.Unmarshal(body, &batch)
+			if batch.Status != db.BatchTaskRunning {
+				return batch
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("batch %d still running: %s", id, body)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// A sequential batch runs its prompts in order in one conversation
+	batch := wait(submit(BatchRequest{Prompts: []string{"echo: one", "echo: two"}}).ID)
+	if batch.Status != db.BatchTaskDone || batch.ConversationID == nil || len(batch.Tasks) != 2 {
+		t.Fatalf("sequential batch = %+v", batch)
+	}
+	h.convID = *batch.ConversationID
+	if prompts := h.prompts(); len(prompts) != 2 || prompts[0] != "echo: one" || prompts[1] != "echo: two" {
+		t.Errorf("conversation prompts = %v", prompts)
+	}
+
+	// A parallel batch runs each prompt in its own conversation
+	batch = wait(submit(BatchRequest{Prompts: []string{"echo: a", "echo: b"}, Mode: db.BatchModeParallel}).ID)
+	if batch.Status != db.BatchTaskDone || batch.Tasks[0].ConversationID == nil || batch.Tasks[1].ConversationID == nil ||
+		*batch.Tasks[0].ConversationID == *batch.Tasks[1].ConversationID {
+		t.Fatalf("parallel batch = %+v", batch)
+	}
+
+	// Canceling stops the running turn and skips the rest
+	id := submit(BatchRequest{Prompts: []string{"delay: 30", "echo: never"}}).ID
+	deadline := time.Now().Add(h.timeout)
+	for {
+		_, body := do("GET", "/api/batches/"+strconv.FormatInt(id, 10), "alice", nil)
+		json.Unmarshal(body, &batch)
+		if batch.Tasks[0].Status == db.BatchTaskRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("first task never started: %s", body)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if code, body := do("POST", "/api/batches/"+strconv.FormatInt(id, 10)+"/cancel", "alice", nil); code != http.StatusOK {
+		t.Fatalf("cancel: status %d: %s", code, body)
+	}
+	batch = wait(id)
+	if batch.Status != db.BatchTaskCanceled || batch.Tasks[0].Status != db.BatchTaskCanceled || batch.Tasks[1].Status != db.BatchTaskCanceled {
+		t.Errorf("canceled batch = %+v", batch)
+	}
+
+	// Batches are private to their owner
+	if code, _ := do("GET", "/api/batches/"+strconv.FormatInt(id, 10), "bob", nil); code != http.StatusNotFound {
+		t.Errorf("another user's batch: status %d, want 404", code)
+	}
+	_, body := do("GET", "/api/batches", "alice", nil)
+	var batches []BatchAPI
+	json.Unmarshal(body, &batches)
+	if len(batches) != 3 {
+		t.Errorf("listed %d batches, want 3", len(batches))
+	}
+
+	for _, bad := range []BatchRequest{
+		{},
+		{Prompts: []string{"  "}},
+		{Prompts: []string{"echo: x"}, Mode: "random"},
+		{Prompts: []string{"echo: x"}, Mode: db.BatchModeParallel, ConversationID: h.convID},
+	} {
+		if code, _ := do("POST", "/api/batches", "alice", bad); code != http.StatusBadRequest {
+			t.Errorf("submit %+v: status %d, want 400", bad, code)
+		}
+	}
+}
+
+func TestProviderBatches(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	providerBatchPollInterval = 10 * time.Millisecond
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path string, body any) (int, []byte) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code, w.Body.Bytes()
+	}
+
+	code, body := do("POST", "/api/batches", BatchRequest{Prompts: []string{"echo: hello", "error: boom"}, ProviderBatch: true})
+	if code != http.StatusCreated {
+		t.Fatalf("submit: status %d: %s", code, body)
+	}
+	var batch BatchAPI
+	json.Unmarshal(body, &batch)
+	if batch.Mode != db.BatchModeParallel || batch.ProviderBatchID == nil {
+		t.Fatalf("provider batch = %+v", batch)
+	}
+
+	deadline := time.Now().Add(h.timeout)
+	for batch.Status == db.BatchTaskRunning {
+		if time.Now().After(deadline) {
+			t.Fatalf("batch still running: %+v", batch)
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, body = do("GET", "/api/batches/"+strconv.FormatInt(batch.ID, 10), nil)
+		json.Unmarshal(body, &batch)
+	}
+	if batch.ProviderStatus != "ended" || batch.Status != db.BatchTaskFailed {
+		t.Fatalf("ended provider batch = %+v", batch)
+	}
+
+	// Each result is recorded in a conversation of its own
+	done, failed := batch.Tasks[0], batch.Tasks[1]
+	if done.Status != db.BatchTaskDone || done.ConversationID == nil {
+		t.Fatalf("first task = %+v", done)
+	}
+	h.convID = *done.ConversationID
+	if prompts := h.prompts(); len(prompts) != 1 || prompts[0] != "echo: hello" {
+		t.Errorf("conversation prompts = %v", prompts)
+	}
+	msg, err := h.db.GetLatestMessage(t.Context(), *done.ConversationID)
+	if err != nil || msg.Type != string(db.MessageTypeAgent) || !strings.Contains(*msg.LlmData, "hello") {
+		t.Errorf("latest message = %+v, %v", msg, err)
+	}
+	if failed.Status != db.BatchTaskFailed || !strings.Contains(failed.Error, "boom") || failed.ConversationID == nil {
+		t.Errorf("second task = %+v", failed)
+	}
+
+	for _, bad := range []BatchRequest{
+		{Prompts: []string{"echo: x"}, Mode: db.BatchModeSequential, ProviderBatch: true},
+	} {
+		if code, _ := do("POST", "/api/batches", bad); code != http.StatusBadRequest {
+			t.Errorf("submit %+v: status %d, want 400", bad, code)
+		}
+	}
+}
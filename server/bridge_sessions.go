@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/llmhttp"
+)
+
+// BridgeSessionAPI is the API representation of a conversation's bridge session
+type BridgeSessionAPI struct {
+	SessionID  string     `json:"session_id"`
+	WorkingDir string     `json:"working_dir"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// handleBridgeSession handles GET /conversation/<id>/bridge-session,
+// POST /conversation/<id>/bridge-session/reset and
+// POST /conversation/<id>/bridge-session/compact. Resetting ends the session
+// kept by the Claude Code bridge so the next request starts a new one;
+// compacting asks the bridge to summarize it.
+func (s *Server) handleBridgeSession(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		s.mu.Lock()
+		manager := s.activeConversations[conversationID]
+		s.mu.Unlock()
+		if manager != nil && manager.IsAgentWorking() {
+			http.Error(w, "Conversation is busy", http.StatusConflict)
+			return
+		}
+
+		modelID := s.Settings().DefaultModel
+		if conversation.Model != nil {
+			modelID = *conversation.Model
+		}
+		service, err := s.llmManager.GetService(modelID)
+		if err != nil {
+			http.Error(w, "Unsupported model: "+modelID, http.StatusBadRequest)
+			return
+		}
+		if conversation.Cwd != nil {
+			ctx = llmhttp.WithWorkingDir(ctx, *conversation.Cwd)
+		}
+
+		err = llm.ErrSessionsUnsupported
+		if strings.HasSuffix(r.URL.Path, "/compact") {
+			if sc, ok := service.(llm.SessionCompactor); ok {
+				err = sc.CompactSession(ctx, conversationID)
+			}
+		} else if sr, ok := service.(llm.SessionResetter); ok {
+			err = sr.ResetSession(ctx, conversationID)
+		}
+		if errors.Is(err, llm.ErrSessionsUnsupported) {
+			http.Error(w, "Model "+modelID+" does not keep sessions", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to update bridge session", "conversationID", conversationID, "path", r.URL.Path, "error", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	session, err := s.db.GetBridgeSession(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get bridge session", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var resp BridgeSessionAPI
+	if session != nil {
+		resp = BridgeSessionAPI{SessionID: session.SessionID, WorkingDir: session.WorkingDir, UpdatedAt: &session.UpdatedAt}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBridgeSession(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: hello", "")
+	h.WaitResponse()
+	h.waitIdle()
+
+	get := func() BridgeSessionAPI {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("GET", "/"+h.convID+"/bridge-session", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var session BridgeSessionAPI
+		json.Unmarshal(w.Body.Bytes(), &session)
+		return session
+	}
+
+	if session := get(); session.SessionID != "" {
+		t.Errorf("expected no session, got %+v", session)
+	}
+	if err := h.db.SetBridgeSession(t.Context(), h.convID, "session-1", "/src/project"); err != nil {
+		t.Fatal(err)
+	}
+	if session := get(); session.SessionID != "session-1" || session.WorkingDir != "/src/project" || session.UpdatedAt == nil {
+		t.Errorf("unexpected session: %+v", session)
+	}
+
+	// The predictable model keeps no sessions
+	for _, path := range []string{"/bridge-session/reset", "/bridge-session/compact"} {
+		if w := h.post(path, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+}
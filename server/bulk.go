@@ -0,0 +1,194 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// maxBulkConversations bounds the conversations of one bulk request.
+const maxBulkConversations = 1000
+
+// Bulk actions
+const (
+	BulkArchive   = "archive"
+	BulkUnarchive = "unarchive"
+	BulkDelete    = "delete"
+	BulkTag       = "tag"
+	BulkUntag     = "untag"
+	BulkExport    = "export"
+)
+
+// BulkRequest is the request body for POST /api/conversations/bulk
+type BulkRequest struct {
+	Action          string   `json:"action"`
+	ConversationIDs []string `json:"conversation_ids"`
+	// Tags are added or removed by the tag and untag actions
+	Tags []string `json:"tags,omitempty"`
+}
+
+// BulkResult is how the action went for one conversation
+type BulkResult struct {
+	ConversationID string `json:"conversation_id"`
+	OK             bool   `json:"ok"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ConversationExport is a conversation and its messages, from the export action
+type ConversationExport struct {
+	Conversation generated.Conversation `json:"conversation"`
+	Messages     []APIMessage           `json:"messages"`
+	Tags         []string               `json:"tags"`
+}
+
+// BulkResponse reports the action per conversation; a failure for one
+// conversation does not stop the others.
+type BulkResponse struct {
+	Results   []BulkResult         `json:"results"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Exports   []ConversationExport `json:"exports,omitempty"`
+}
+
+// validateBulkRequest checks a bulk request and normalizes its tags.
+func validateBulkRequest(req *BulkRequest) error {
+	switch req.Action {
+	case BulkArchive, BulkUnarchive, BulkDelete, BulkExport:
+	case BulkTag, BulkUntag:
+		tags := make([]string, 0, len(req.Tags))
+		for _, tag := range req.Tags {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) == 0 {
+			return fmt.Errorf("tags are required")
+		}
+		req.Tags = tags
+	default:
+		return fmt.Errorf("unknown action %q", req.Action)
+	}
+	if len(req.ConversationIDs) == 0 {
+		return fmt.Errorf("conversation_ids are required")
+	}
+	if len(req.ConversationIDs) > maxBulkConversations {
+		return fmt.Errorf("at most %d conversations can be changed at once", maxBulkConversations)
+	}
+	return nil
+}
+
+// handleBulkConversations handles POST /api/conversations/bulk: archives,
+// unarchives, deletes, tags, untags or exports many conversations at once.
+func (s *Server) handleBulkConversations(w http.ResponseWriter, r *http.Request) {
+	var req BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := validateBulkRequest(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	var tagsByID map[string][]string
+	if req.Action == BulkExport {
+		tags, err := s.db.ListConversationTags(ctx)
+		if err != nil {
+			s.logger.Error("Failed to list conversation tags", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		tagsByID = groupConversationTags(tags)
+	}
+
+	resp := BulkResponse{Results: make([]BulkResult, 0, len(req.ConversationIDs))}
+	seen := make(map[string]bool)
+	for _, conversationID := range req.ConversationIDs {
+		if seen[conversationID] {
+			continue
+		}
+		seen[conversationID] = true
+		result := BulkResult{ConversationID: conversationID}
+		if err := s.bulkApply(r, &req, conversationID, tagsByID, &resp); err != nil {
+			result.Error = err.Error()
+			resp.Failed++
+		} else {
+			result.OK = true
+			resp.Succeeded++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	s.logger.Info("Bulk conversation action", "action", req.Action, "succeeded", resp.Succeeded, "failed", resp.Failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// bulkApply runs a bulk action on one conversation. Internal errors are logged
+// and reported without their details.
+func (s *Server) bulkApply(r *http.Request, req *BulkRequest, conversationID string, tagsByID map[string][]string, resp *BulkResponse) error {
+	ctx := r.Context()
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("conversation not found")
+	}
+
+	switch req.Action {
+	case BulkArchive:
+		conversation, err = s.db.ArchiveConversation(ctx, conversationID)
+	case BulkUnarchive:
+		conversation, err = s.db.UnarchiveConversation(ctx, conversationID)
+	case BulkDelete:
+		err = s.db.DeleteConversation(ctx, conversationID)
+	case BulkTag:
+		err = s.db.AddConversationTags(ctx, conversationID, req.Tags)
+	case BulkUntag:
+		err = s.db.RemoveConversationTags(ctx, conversationID, req.Tags)
+	case BulkExport:
+		var messages []generated.Message
+		messages, err = s.db.ListMessages(ctx, conversationID)
+		if err == nil {
+			apiMessages := toAPIMessages(messages)
+			if !showReasoning(r) {
+				apiMessages = withoutReasoning(apiMessages)
+			}
+			tags := tagsByID[conversationID]
+			if tags == nil {
+				tags = []string{}
+			}
+			resp.Exports = append(resp.Exports, ConversationExport{Conversation: *conversation, Messages: apiMessages, Tags: tags})
+		}
+	}
+	if err != nil {
+		s.logger.Error("Bulk conversation action failed", "action", req.Action, "conversationID", conversationID, "error", err)
+		return fmt.Errorf("failed to %s conversation", req.Action)
+	}
+
+	// Notify conversation list subscribers
+	switch req.Action {
+	case BulkArchive, BulkUnarchive:
+		go s.publishConversationListUpdate(ConversationListUpdate{
+			Type:         "update",
+			Conversation: conversation,
+		})
+	case BulkDelete:
+		go s.publishConversationListUpdate(ConversationListUpdate{
+			Type:           "delete",
+			ConversationID: conversationID,
+		})
+	}
+	return nil
+}
+
+// groupConversationTags returns the tags of each conversation.
+func groupConversationTags(tags []generated.ConversationTag) map[string][]string {
+	byID := make(map[string][]string)
+	for _, tag := range tags {
+		byID[tag.ConversationID] = append(byID[tag.ConversationID], tag.Tag)
+	}
+	return byID
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestBulkConversations(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	ctx := t.Context()
+
+	var ids []string
+	for range 3 {
+		h.NewConversation("echo: hi", "")
+		h.WaitResponse()
+		h.waitIdle()
+		ids = append(ids, h.convID)
+	}
+	bulk := func(req BulkRequest) (int, BulkResponse) {
+		t.Helper()
+		data, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.server.handleBulkConversations(w, httptest.NewRequest("POST", "/api/conversations/bulk", bytes.NewReader(data)))
+		var resp BulkResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	if code, _ := bulk(BulkRequest{Action: "rename", ConversationIDs: ids}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown action, got %d", code)
+	}
+	if code, _ := bulk(BulkRequest{Action: BulkTag, ConversationIDs: ids, Tags: []string{" "}}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for tagging without tags, got %d", code)
+	}
+
+	// Unknown conversations fail without stopping the others
+	code, resp := bulk(BulkRequest{Action: BulkTag, ConversationIDs: append(ids, "missing"), Tags: []string{"billing", "urgent"}})
+	if code != http.StatusOK || resp.Succeeded != 3 || resp.Failed != 1 {
+		t.Fatalf("tag: status %d, response %+v", code, resp)
+	}
+	if last := resp.Results[3]; last.ConversationID != "missing" || last.OK || last.Error == "" {
+		t.Errorf("result for a missing conversation = %+v", last)
+	}
+	if _, resp := bulk(BulkRequest{Action: BulkUntag, ConversationIDs: ids[:1], Tags: []string{"urgent"}}); resp.Succeeded != 1 {
+		t.Errorf("untag: %+v", resp)
+	}
+
+	_, resp = bulk(BulkRequest{Action: BulkExport, ConversationIDs: ids[:2]})
+	if len(resp.Exports) != 2 || len(resp.Exports[0].Messages) == 0 {
+		t.Fatalf("export = %+v", resp)
+	}
+	if got := resp.Exports[0].Tags; !slices.Equal(got, []string{"billing"}) {
+		t.Errorf("exported tags = %v", got)
+	}
+	if got := resp.Exports[1].Tags; !slices.Equal(got, []string{"billing", "urgent"}) {
+		t.Errorf("exported tags = %v", got)
+	}
+
+	if _, resp := bulk(BulkRequest{Action: BulkArchive, ConversationIDs: ids[:2]}); resp.Succeeded != 2 {
+		t.Errorf("archive: %+v", resp)
+	}
+	conversation, err := h.db.GetConversationByID(ctx, ids[0])
+	if err != nil || !conversation.Archived {
+		t.Errorf("conversation not archived: %v", err)
+	}
+	if _, resp := bulk(BulkRequest{Action: BulkDelete, ConversationIDs: ids}); resp.Succeeded != 3 {
+		t.Errorf("delete: %+v", resp)
+	}
+	if _, err := h.db.GetConversationByID(ctx, ids[2]); err == nil {
+		t.Error("conversation not deleted")
+	}
+}
@@ -3,10 +3,15 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -359,6 +364,75 @@ func TestCancelDuringTextGeneration(t *testing.T) {
 	}
 }
 
+// TestCancelKillsToolProcess verifies that cancel returns only after the running
+// bash process has been killed.
+func TestCancelKillsToolProcess(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	llmManager := &testLLMManager{service: loop.NewPredictableService()}
+	server := NewServer(database, llmManager, claudetool.ToolSetConfig{}, slog.Default(), true, "", "predictable", "", nil)
+
+	conversation, err := database.CreateConversation(context.Background(), nil, true, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	conversationID := conversation.ConversationID
+
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	chatBody, _ := json.Marshal(ChatRequest{
+		Message: "bash: echo $$ > " + pidFile + "; sleep 60",
+		Model:   "predictable",
+	})
+	req := httptest.NewRequest("POST", "/api/conversation/"+conversationID+"/chat", strings.NewReader(string(chatBody)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.handleChatConversation(w, req, conversationID)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var pid int
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		data, err := os.ReadFile(pidFile)
+		if err == nil {
+			if pid, err = strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("bash command did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancelW := httptest.NewRecorder()
+	server.handleCancelConversation(cancelW, httptest.NewRequest("POST", "/api/conversation/"+conversationID+"/cancel", nil), conversationID)
+	if cancelW.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", cancelW.Code, cancelW.Body.String())
+	}
+
+	process, _ := os.FindProcess(pid)
+	if err := process.Signal(syscall.Signal(0)); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("expected bash process %d to be gone after cancel, got %v", pid, err)
+	}
+
+	var messages []generated.Message
+	err = database.Queries(context.Background(), func(q *generated.Queries) error {
+		var qerr error
+		messages, qerr = q.ListMessages(context.Background(), conversationID)
+		return qerr
+	})
+	if err != nil {
+		t.Fatalf("failed to get messages: %v", err)
+	}
+	last := messages[len(messages)-1]
+	if last.LlmData == nil || !strings.Contains(*last.LlmData, "[Operation cancelled]") {
+		t.Errorf("expected cancellation marker to be the last message, got type %s", last.Type)
+	}
+}
+
 // testLLMManager is a simple test implementation of LLMProvider
 type testLLMManager struct {
 	service llm.Service
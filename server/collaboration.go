@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"slices"
+)
+
+// UserMessageData is the user_data of a user message
+type UserMessageData struct {
+	// Author is the user who sent the message, when the server identifies
+	// users by a header
+	Author string `json:"author,omitempty"`
+}
+
+// PresenceAPI lists the users viewing a conversation
+type PresenceAPI struct {
+	Users []string `json:"users"`
+}
+
+type messageAuthorKey struct{}
+
+// withMessageAuthor attributes the user messages recorded with ctx to author.
+func withMessageAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, messageAuthorKey{}, author)
+}
+
+// messageAuthorFrom returns the author set by withMessageAuthor, or "".
+func messageAuthorFrom(ctx context.Context) string {
+	author, _ := ctx.Value(messageAuthorKey{}).(string)
+	return author
+}
+
+// presence returns the users streaming the conversation, sorted.
+func (cm *ConversationManager) presence() *PresenceAPI {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	users := []string{}
+	for user := range cm.viewers {
+		if user != "" {
+			users = append(users, user)
+		}
+	}
+	slices.Sort(users)
+	return &PresenceAPI{Users: users}
+}
+
+func (cm *ConversationManager) broadcastPresence() {
+	cm.subpub.Broadcast(StreamResponse{Presence: cm.presence()})
+}
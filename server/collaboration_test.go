@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+)
+
+func TestCollaboration(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.requireHeader = "X-Exedev-Userid"
+	defer func() { h.server.requireHeader = "" }()
+	ctx := t.Context()
+
+	h.NewConversation("echo: hello", "")
+	h.WaitResponse()
+	h.waitIdle()
+	chat := func(user, msg string) {
+		t.Helper()
+		data, _ := json.Marshal(ChatRequest{Message: msg, Model: "predictable"})
+		r := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/chat", strings.NewReader(string(data)))
+		r.Header.Set("X-Exedev-Userid", user)
+		w := httptest.NewRecorder()
+		h.server.handleChatConversation(w, r, h.convID)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("chat as %s: status %d: %s", user, w.Code, w.Body.String())
+		}
+	}
+
+	// User messages are attributed to their authors
+	chat("alice", "echo: from alice")
+	h.WaitResponse()
+	h.waitIdle()
+	chat("bob", "echo: from bob")
+	h.WaitResponse()
+	h.waitIdle()
+	messages, err := h.db.ListMessages(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var authors []string
+	for _, msg := range messages {
+		if msg.Type != string(db.MessageTypeUser) || msg.UserData == nil {
+			continue
+		}
+		var data UserMessageData
+		if err := json.Unmarshal([]byte(*msg.UserData), &data); err != nil {
+			t.Fatal(err)
+		}
+		authors = append(authors, data.Author)
+	}
+	if want := []string{"alice", "bob"}; !slices.Equal(authors, want) {
+		t.Errorf("authors = %v, want %v", authors, want)
+	}
+
+	// Viewers are told when users start and stop viewing
+	manager, err := h.server.getOrCreateConversationManager(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := manager.subpub.Subscribe(ctx, 1<<62)
+	presence := func() []string {
+		t.Helper()
+		for {
+			resp, ok := next()
+			if !ok {
+				t.Fatal("subscription ended")
+			}
+			if resp.Presence != nil {
+				return resp.Presence.Users
+			}
+		}
+	}
+	leaveAlice := manager.addStream("alice")
+	if got := presence(); !slices.Equal(got, []string{"alice"}) {
+		t.Errorf("presence = %v", got)
+	}
+	leaveBob := manager.addStream("bob")
+	if got := presence(); !slices.Equal(got, []string{"alice", "bob"}) {
+		t.Errorf("presence = %v", got)
+	}
+	// A second tab of a viewer changes nothing
+	manager.addStream("alice")()
+	leaveAlice()
+	if got := presence(); !slices.Equal(got, []string{"bob"}) {
+		t.Errorf("presence after alice left = %v", got)
+	}
+	leaveBob()
+}
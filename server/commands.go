@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"shelley.exe.dev/commands"
+)
+
+// slashCommands returns the slash commands available to conversations in dir.
+func slashCommands(dir string) []commands.Command {
+	var gitRoot string
+	if dir != "" {
+		if info, err := collectGitInfo(dir); err == nil {
+			gitRoot = info.Root
+		}
+	}
+	return commands.Discover(commands.Dirs(dir, gitRoot))
+}
+
+// expandSlashCommand replaces a "/name args" message with the body of the
+// matching command in dir. Other messages are returned unchanged.
+func expandSlashCommand(message, dir string) string {
+	name, args, ok := commands.ParseInvocation(message)
+	if !ok {
+		return message
+	}
+	for _, cmd := range slashCommands(dir) {
+		if cmd.Name == name {
+			return cmd.Expand(args)
+		}
+	}
+	return message
+}
+
+// handleCommands handles GET /api/commands?cwd=... and lists the slash
+// commands available in that directory.
+func (s *Server) handleCommands(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(slashCommands(r.URL.Query().Get("cwd")))
+}
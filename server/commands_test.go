@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shelley.exe.dev/commands"
+)
+
+func TestSlashCommands(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	commandsDir := filepath.Join(dir, ".shelley", "commands")
+	if err := os.MkdirAll(commandsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(commandsDir, "say.md"), []byte("---\ndescription: Repeat the arguments\n---\necho: said $ARGUMENTS"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.server.handleCommands(w, httptest.NewRequest("GET", "/api/commands?cwd="+dir, nil))
+	var listed []commands.Command
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].Name != "say" || listed[0].Description != "Repeat the arguments" {
+		t.Fatalf("unexpected commands: %+v", listed)
+	}
+
+	// Commands are expanded for new conversations and follow-up messages
+	h.NewConversation("/say hello there", dir)
+	if got := h.WaitResponse(); got != "said hello there" {
+		t.Errorf("expected expanded command, got %q", got)
+	}
+	h.Chat("/say again")
+	if got := h.WaitResponse(); got != "said again" {
+		t.Errorf("expected expanded command, got %q", got)
+	}
+
+	// Unknown commands are sent as typed
+	h.Chat("/unknown")
+	h.WaitResponse()
+	if prompts := h.prompts(); prompts[len(prompts)-1] != "/unknown" {
+		t.Errorf("expected unknown command to be sent unchanged, got %q", prompts[len(prompts)-1])
+	}
+}
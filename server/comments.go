@@ -0,0 +1,236 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// CommentRequest is the body of POST /api/conversation/<id>/comments. A
+// comment starts a thread on a message (MessageID) or on lines of a file in a
+// diff (DiffID, FilePath and the line range), or replies to the thread of
+// ParentID.
+type CommentRequest struct {
+	ParentID  int64  `json:"parent_id,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+	DiffID    string `json:"diff_id,omitempty"`
+	FilePath  string `json:"file_path,omitempty"`
+	StartLine int64  `json:"start_line,omitempty"`
+	EndLine   int64  `json:"end_line,omitempty"`
+	Body      string `json:"body"`
+}
+
+// CommentUpdateRequest is the body of PUT /api/conversation/<id>/comments/<comment_id>.
+// Fields left out are unchanged.
+type CommentUpdateRequest struct {
+	Body     *string `json:"body,omitempty"`
+	Resolved *bool   `json:"resolved,omitempty"`
+}
+
+// CommentAPI is the API representation of a review comment. Replies carry the
+// message or diff lines of their thread.
+type CommentAPI struct {
+	ID        int64     `json:"id"`
+	ParentID  int64     `json:"parent_id,omitempty"`
+	MessageID string    `json:"message_id,omitempty"`
+	DiffID    string    `json:"diff_id,omitempty"`
+	FilePath  string    `json:"file_path,omitempty"`
+	StartLine int64     `json:"start_line,omitempty"`
+	EndLine   int64     `json:"end_line,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	Body      string    `json:"body"`
+	Resolved  bool      `json:"resolved"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func toCommentAPI(c generated.ReviewComment) CommentAPI {
+	api := CommentAPI{
+		ID:        c.CommentID,
+		DiffID:    c.DiffID,
+		FilePath:  c.FilePath,
+		StartLine: c.StartLine,
+		EndLine:   c.EndLine,
+		Author:    c.Author,
+		Body:      c.Body,
+		Resolved:  c.Resolved,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+	if c.ParentID != nil {
+		api.ParentID = *c.ParentID
+	}
+	if c.MessageID != nil {
+		api.MessageID = *c.MessageID
+	}
+	return api
+}
+
+// handleReviewComments handles GET and POST /conversation/<id>/comments.
+// Review comments are kept apart from the messages, so they never reach the LLM.
+func (s *Server) handleReviewComments(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		comments, err := s.db.ListReviewComments(ctx, conversationID)
+		if err != nil {
+			s.logger.Error("Failed to list comments", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		resp := make([]CommentAPI, 0, len(comments))
+		for _, c := range comments {
+			resp = append(resp, toCommentAPI(c))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		http.Error(w, "body is required", http.StatusBadRequest)
+		return
+	}
+	params := generated.CreateReviewCommentParams{
+		ConversationID: conversationID,
+		Author:         s.requestUser(r),
+		Body:           req.Body,
+	}
+	switch {
+	case req.ParentID != 0:
+		parent, err := s.db.GetReviewComment(ctx, req.ParentID)
+		if err != nil {
+			s.logger.Error("Failed to get comment", "commentID", req.ParentID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if parent == nil || parent.ConversationID != conversationID {
+			http.Error(w, "Parent comment not found", http.StatusNotFound)
+			return
+		}
+		// Replies to replies join the thread of the comment that started it
+		params.ParentID = &parent.CommentID
+		if parent.ParentID != nil {
+			params.ParentID = parent.ParentID
+		}
+		params.MessageID = parent.MessageID
+		params.DiffID = parent.DiffID
+		params.FilePath = parent.FilePath
+		params.StartLine = parent.StartLine
+		params.EndLine = parent.EndLine
+	case req.MessageID != "":
+		message, err := s.db.GetMessageByID(ctx, req.MessageID)
+		if err != nil || message.ConversationID != conversationID {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		params.MessageID = &req.MessageID
+	case req.FilePath != "":
+		if req.StartLine < 0 || req.EndLine < req.StartLine {
+			http.Error(w, "Invalid line range", http.StatusBadRequest)
+			return
+		}
+		params.DiffID = req.DiffID
+		params.FilePath = req.FilePath
+		params.StartLine = req.StartLine
+		params.EndLine = req.EndLine
+	default:
+		http.Error(w, "A comment needs a message_id, a file_path or a parent_id", http.StatusBadRequest)
+		return
+	}
+
+	comment, err := s.db.CreateReviewComment(ctx, params)
+	if err != nil {
+		s.logger.Error("Failed to create comment", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(toCommentAPI(*comment))
+}
+
+// handleReviewComment handles PUT and DELETE /conversation/<id>/comments/<comment_id>.
+// Anyone can resolve a thread, but only its author can edit or delete a comment.
+// Deleting a comment deletes its replies.
+func (s *Server) handleReviewComment(w http.ResponseWriter, r *http.Request, conversationID, commentIDStr string) {
+	ctx := r.Context()
+	commentID, err := strconv.ParseInt(commentIDStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+	comment, err := s.db.GetReviewComment(ctx, commentID)
+	if err != nil {
+		s.logger.Error("Failed to get comment", "commentID", commentID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if comment == nil || comment.ConversationID != conversationID {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	isAuthor := comment.Author == s.requestUser(r)
+
+	if r.Method == http.MethodDelete {
+		if !isAuthor {
+			http.Error(w, "Only the author can delete a comment", http.StatusForbidden)
+			return
+		}
+		if err := s.db.DeleteReviewComment(ctx, commentID); err != nil {
+			s.logger.Error("Failed to delete comment", "commentID", commentID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var req CommentUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	params := generated.UpdateReviewCommentParams{
+		Body:      comment.Body,
+		Resolved:  comment.Resolved,
+		CommentID: commentID,
+	}
+	if req.Body != nil {
+		body := strings.TrimSpace(*req.Body)
+		if body == "" {
+			http.Error(w, "body is required", http.StatusBadRequest)
+			return
+		}
+		if !isAuthor && body != comment.Body {
+			http.Error(w, "Only the author can edit a comment", http.StatusForbidden)
+			return
+		}
+		params.Body = body
+	}
+	if req.Resolved != nil {
+		params.Resolved = *req.Resolved
+	}
+	updated, err := s.db.UpdateReviewComment(ctx, params)
+	if err != nil {
+		s.logger.Error("Failed to update comment", "commentID", commentID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toCommentAPI(*updated))
+}
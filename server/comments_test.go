@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+)
+
+func TestReviewComments(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.requireHeader = "X-Exedev-Userid"
+	defer func() { h.server.requireHeader = "" }()
+
+	h.NewConversation("echo: hello there", "")
+	h.WaitResponse()
+	h.waitIdle()
+
+	messages, err := h.db.ListMessages(t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var agentMessage string
+	for _, msg := range messages {
+		if msg.Type == string(db.MessageTypeAgent) {
+			agentMessage = msg.MessageID
+		}
+	}
+
+	do := func(method, user, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		r := httptest.NewRequest(method, "/"+h.convID+"/comments"+path, strings.NewReader(string(data)))
+		r.Header.Set("X-Exedev-Userid", user)
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, r)
+		return w
+	}
+	create := func(user string, req CommentRequest) CommentAPI {
+		t.Helper()
+		w := do("POST", user, "", req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+		}
+		var c CommentAPI
+		json.Unmarshal(w.Body.Bytes(), &c)
+		return c
+	}
+
+	if w := do("POST", "alice", "", CommentRequest{Body: "no target"}); w.Code != http.StatusBadRequest {
+		t.Errorf("comment without a target: status %d", w.Code)
+	}
+	if w := do("POST", "alice", "", CommentRequest{MessageID: "missing", Body: "x"}); w.Code != http.StatusNotFound {
+		t.Errorf("comment on an unknown message: status %d", w.Code)
+	}
+
+	onMessage := create("alice", CommentRequest{MessageID: agentMessage, Body: " why this? "})
+	if onMessage.Author != "alice" || onMessage.Body != "why this?" {
+		t.Errorf("comment = %+v", onMessage)
+	}
+	onDiff := create("alice", CommentRequest{DiffID: "working", FilePath: "main.go", StartLine: 10, EndLine: 14, Body: "missing error check"})
+	// Replies join the thread of the comment that started it, on the same lines
+	reply := create("bob", CommentRequest{ParentID: onDiff.ID, Body: "fixed"})
+	nested := create("alice", CommentRequest{ParentID: reply.ID, Body: "thanks"})
+	if nested.ParentID != onDiff.ID || nested.FilePath != "main.go" || nested.StartLine != 10 || nested.EndLine != 14 {
+		t.Errorf("reply = %+v", nested)
+	}
+
+	// Only the author can edit or delete a comment, but anyone can resolve it
+	path := "/" + strconv.FormatInt(onDiff.ID, 10)
+	edited := "edited"
+	if w := do("PUT", "bob", path, CommentUpdateRequest{Body: &edited}); w.Code != http.StatusForbidden {
+		t.Errorf("edit by another user: status %d", w.Code)
+	}
+	if w := do("DELETE", "bob", path, nil); w.Code != http.StatusForbidden {
+		t.Errorf("delete by another user: status %d", w.Code)
+	}
+	resolved := true
+	w := do("PUT", "bob", path, CommentUpdateRequest{Resolved: &resolved})
+	var updated CommentAPI
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if w.Code != http.StatusOK || !updated.Resolved || updated.Body != "missing error check" {
+		t.Errorf("resolve: status %d, comment %+v", w.Code, updated)
+	}
+
+	list := func() []CommentAPI {
+		t.Helper()
+		w := do("GET", "alice", "", nil)
+		var comments []CommentAPI
+		json.Unmarshal(w.Body.Bytes(), &comments)
+		return comments
+	}
+	if got := list(); len(got) != 4 {
+		t.Fatalf("got %d comments, want 4", len(got))
+	}
+	// Deleting a comment deletes its thread
+	if w := do("DELETE", "alice", path, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d", w.Code)
+	}
+	if got := list(); len(got) != 1 || got[0].ID != onMessage.ID {
+		t.Errorf("comments after delete = %+v", got)
+	}
+
+	// Comments stay out of the conversation's messages
+	after, err := h.db.ListMessages(t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(messages) {
+		t.Errorf("comments added messages: %d, want %d", len(after), len(messages))
+	}
+}
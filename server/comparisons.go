@@ -0,0 +1,416 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// comparisonsListed is how many of a user's comparisons /api/comparisons returns.
+const comparisonsListed = 50
+
+// ComparisonRequest is the request body for POST /api/comparisons
+type ComparisonRequest struct {
+	Prompt string `json:"prompt"`
+	ModelA string `json:"model_a"`
+	ModelB string `json:"model_b"`
+	// Cwd and CwdB are the working directories of the two conversations,
+	// e.g. two checkouts of a repository. They must not overlap, so that
+	// neither model sees or overwrites the other's changes.
+	Cwd  string `json:"cwd"`
+	CwdB string `json:"cwd_b"`
+}
+
+// ChooseComparisonRequest is the request body for POST /api/comparisons/{id}/choose
+type ChooseComparisonRequest struct {
+	ConversationID string `json:"conversation_id"`
+}
+
+// ComparisonSideAPI is one model's conversation of a comparison and its
+// response to the prompt
+type ComparisonSideAPI struct {
+	ConversationID string `json:"conversation_id"`
+	Model          string `json:"model"`
+	Working        bool   `json:"working"`
+	// Response is the agent's text in the turn answering the prompt
+	Response string `json:"response"`
+	// Error is set if that turn ended with an error
+	Error string    `json:"error,omitempty"`
+	Usage llm.Usage `json:"usage"`
+}
+
+// ComparisonAPI is an A/B comparison as returned by the API
+type ComparisonAPI struct {
+	ID                   int64             `json:"id"`
+	Prompt               string            `json:"prompt"`
+	A                    ComparisonSideAPI `json:"a"`
+	B                    ComparisonSideAPI `json:"b"`
+	ChosenConversationID *string           `json:"chosen_conversation_id,omitempty"`
+	CreatedAt            time.Time         `json:"created_at"`
+	ChosenAt             *time.Time        `json:"chosen_at,omitempty"`
+}
+
+// handleComparisons handles GET /api/comparisons, listing the user's recent
+// comparisons, and POST /api/comparisons, which sends a prompt to two models
+// in new conversations at once.
+func (s *Server) handleComparisons(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := s.requestUser(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		comparisons, err := s.db.ListComparisons(ctx, owner, comparisonsListed)
+		if err != nil {
+			s.logger.Error("Failed to list comparisons", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		result := make([]ComparisonAPI, 0, len(comparisons))
+		for _, c := range comparisons {
+			api, err := s.toComparisonAPI(ctx, c)
+			if err != nil {
+				s.logger.Error("Failed to load comparison", "comparisonID", c.ComparisonID, "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			result = append(result, api)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+
+	case http.MethodPost:
+		var req ComparisonRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.validateComparisonRequest(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		conversationA, err := s.createBatchConversation(ctx, req.Cwd, req.ModelA)
+		if err != nil {
+			s.logger.Error("Failed to create comparison conversation", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		conversationB, err := s.createBatchConversation(ctx, req.CwdB, req.ModelB)
+		if err != nil {
+			s.logger.Error("Failed to create comparison conversation", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		comparison, err := s.db.CreateComparison(ctx, generated.CreateComparisonParams{
+			Owner:         owner,
+			Prompt:        req.Prompt,
+			ConversationA: conversationA,
+			ConversationB: conversationB,
+		})
+		if err != nil {
+			s.logger.Error("Failed to create comparison", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Both turns start before the response, so that they show as working
+		sendCtx := context.WithoutCancel(ctx)
+		errs := make([]error, 2)
+		var wg sync.WaitGroup
+		for i, side := range [][2]string{{conversationA, req.ModelA}, {conversationB, req.ModelB}} {
+			wg.Go(func() { errs[i] = s.sendPrompt(sendCtx, side[0], side[1], req.Prompt) })
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				s.logger.Error("Failed to send comparison prompt", "comparisonID", comparison.ComparisonID, "error", err)
+				http.Error(w, "Failed to send prompt: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		s.logger.Info("Started comparison", "comparisonID", comparison.ComparisonID, "modelA", req.ModelA, "modelB", req.ModelB)
+
+		api, err := s.toComparisonAPI(ctx, *comparison)
+		if err != nil {
+			s.logger.Error("Failed to load comparison", "comparisonID", comparison.ComparisonID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(api)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// validateComparisonRequest checks a comparison request.
+func (s *Server) validateComparisonRequest(req *ComparisonRequest) error {
+	if strings.TrimSpace(req.Prompt) == "" {
+		return fmt.Errorf("prompt is required")
+	}
+	if req.ModelA == "" || req.ModelB == "" {
+		return fmt.Errorf("model_a and model_b are required")
+	}
+	for _, model := range []string{req.ModelA, req.ModelB} {
+		if _, err := s.llmManager.GetService(model); err != nil {
+			return fmt.Errorf("unsupported model: %s", model)
+		}
+	}
+	if req.Cwd == "" || req.CwdB == "" {
+		return fmt.Errorf("cwd and cwd_b are required")
+	}
+	for _, cwd := range []string{req.Cwd, req.CwdB} {
+		if err := validateDirectory(cwd); err != nil {
+			return err
+		}
+	}
+	a, b := filepath.Clean(req.Cwd), filepath.Clean(req.CwdB)
+	if isWithinDir(a, b) || isWithinDir(b, a) {
+		return fmt.Errorf("cwd and cwd_b must be separate directories, such as two checkouts, so the models don't change each other's files")
+	}
+	return nil
+}
+
+// isWithinDir reports whether dir is base or one of its descendants. Both
+// paths must be clean.
+func isWithinDir(dir, base string) bool {
+	return dir == base || strings.HasPrefix(dir, strings.TrimSuffix(base, "/")+"/")
+}
+
+// handleComparison handles GET /api/comparisons/{id}, with both responses
+// side by side.
+func (s *Server) handleComparison(w http.ResponseWriter, r *http.Request) {
+	comparison, ok := s.ownComparison(w, r)
+	if !ok {
+		return
+	}
+	api, err := s.toComparisonAPI(r.Context(), *comparison)
+	if err != nil {
+		s.logger.Error("Failed to load comparison", "comparisonID", comparison.ComparisonID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api)
+}
+
+// handleChooseComparison handles POST /api/comparisons/{id}/choose, recording
+// the conversation that continues and archiving the other one.
+func (s *Server) handleChooseComparison(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	comparison, ok := s.ownComparison(w, r)
+	if !ok {
+		return
+	}
+	var req ChooseComparisonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	var other string
+	switch req.ConversationID {
+	case comparison.ConversationA:
+		other = comparison.ConversationB
+	case comparison.ConversationB:
+		other = comparison.ConversationA
+	default:
+		http.Error(w, "conversation_id must be one of the comparison's conversations", http.StatusBadRequest)
+		return
+	}
+
+	chosen, err := s.db.ChooseComparison(ctx, comparison.ComparisonID, req.ConversationID)
+	if err != nil {
+		s.logger.Error("Failed to choose comparison conversation", "comparisonID", comparison.ComparisonID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if chosen == nil {
+		http.Error(w, "A conversation was chosen already", http.StatusConflict)
+		return
+	}
+
+	conversation, err := s.db.ArchiveConversation(ctx, other)
+	if err != nil {
+		s.logger.Error("Failed to archive comparison conversation", "conversationID", other, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	go s.publishConversationListUpdate(ConversationListUpdate{
+		Type:         "update",
+		Conversation: conversation,
+	})
+
+	api, err := s.toComparisonAPI(ctx, *chosen)
+	if err != nil {
+		s.logger.Error("Failed to load comparison", "comparisonID", comparison.ComparisonID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api)
+}
+
+// ownComparison returns the comparison named in the path if it belongs to
+// the user, or writes an error.
+func (s *Server) ownComparison(w http.ResponseWriter, r *http.Request) (*generated.Comparison, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid comparison ID", http.StatusBadRequest)
+		return nil, false
+	}
+	comparison, err := s.db.GetComparison(r.Context(), id)
+	if err != nil {
+		s.logger.Error("Failed to get comparison", "comparisonID", id, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if comparison == nil || comparison.Owner != s.requestUser(r) {
+		http.Error(w, "Comparison not found", http.StatusNotFound)
+		return nil, false
+	}
+	return comparison, true
+}
+
+func (s *Server) toComparisonAPI(ctx context.Context, c generated.Comparison) (ComparisonAPI, error) {
+	api := ComparisonAPI{
+		ID:                   c.ComparisonID,
+		Prompt:               c.Prompt,
+		ChosenConversationID: c.ChosenConversationID,
+		CreatedAt:            c.CreatedAt,
+		ChosenAt:             c.ChosenAt,
+	}
+	var err error
+	if api.A, err = s.comparisonSide(ctx, c.ConversationA); err != nil {
+		return api, err
+	}
+	if api.B, err = s.comparisonSide(ctx, c.ConversationB); err != nil {
+		return api, err
+	}
+	return api, nil
+}
+
+// comparisonSide returns a conversation's response to the compared prompt,
+// its first one.
+func (s *Server) comparisonSide(ctx context.Context, conversationID string) (ComparisonSideAPI, error) {
+	side := ComparisonSideAPI{ConversationID: conversationID}
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return side, err
+	}
+	if conversation.Model != nil {
+		side.Model = *conversation.Model
+	}
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	side.Working = ok && manager.IsAgentWorking()
+
+	messages, err := s.db.ListMessages(ctx, conversationID)
+	if err != nil {
+		return side, err
+	}
+	if turns := promptTurns(messages); len(turns) > 0 {
+		side.Response, side.Error, side.Usage = turns[0].Response, turns[0].Error, turns[0].Usage
+	}
+	return side, nil
+}
+
+// promptTurn is a prompt of a conversation and what the agent did about it,
+// until the next prompt.
+type promptTurn struct {
+	Prompt string
+	// Response is the agent's text
+	Response  string
+	Error     string
+	ToolCalls int
+	Usage     llm.Usage
+}
+
+// promptTurns splits a conversation's messages at the user's prompts.
+func promptTurns(messages []generated.Message) []promptTurn {
+	var turns []promptTurn
+	var texts []string
+	for _, msg := range messages {
+		if isPromptMessage(msg) {
+			if len(turns) > 0 {
+				turns[len(turns)-1].Response = strings.Join(texts, "\n\n")
+			}
+			texts = nil
+			turns = append(turns, promptTurn{Prompt: messageText(msg.LlmData)})
+			continue
+		}
+		if len(turns) == 0 {
+			continue
+		}
+		turn := &turns[len(turns)-1]
+		if msg.UsageData != nil {
+			var usage llm.Usage
+			if json.Unmarshal([]byte(*msg.UsageData), &usage) == nil {
+				turn.Usage.Add(usage)
+			}
+		}
+		switch msg.Type {
+		case string(db.MessageTypeAgent):
+			if text := messageText(msg.LlmData); text != "" {
+				texts = append(texts, text)
+			}
+			turn.ToolCalls += countToolUses(msg.LlmData)
+		case string(db.MessageTypeError):
+			turn.Error = messageText(msg.LlmData)
+			if turn.Error == "" {
+				turn.Error = "the turn ended with an error"
+			}
+		}
+	}
+	if len(turns) > 0 {
+		turns[len(turns)-1].Response = strings.Join(texts, "\n\n")
+	}
+	return turns
+}
+
+func countToolUses(llmData *string) int {
+	if llmData == nil {
+		return 0
+	}
+	var msg llm.Message
+	if err := json.Unmarshal([]byte(*llmData), &msg); err != nil {
+		return 0
+	}
+	n := 0
+	for _, content := range msg.Content {
+		if content.Type == llm.ContentTypeToolUse {
+			n++
+		}
+	}
+	return n
+}
+
+// isPromptMessage reports whether a message was sent by the user, rather than
+// carrying tool results back to the LLM.
+func isPromptMessage(msg generated.Message) bool {
+	if msg.Type != string(db.MessageTypeUser) || msg.LlmData == nil {
+		return false
+	}
+	var llmMsg llm.Message
+	if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+		return false
+	}
+	for _, content := range llmMsg.Content {
+		if content.Type == llm.ContentTypeToolResult {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestComparisons(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.requireHeader = "X-Exedev-Userid"
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path, user string, body any) (int, []byte) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("X-Exedev-Userid", user)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code, w.Body.Bytes()
+	}
+
+	dirA, dirB := t.TempDir(), t.TempDir()
+	if code, _ := do("POST", "/api/comparisons", "alice", ComparisonRequest{Prompt: "echo: hi", ModelA: "predictable", Cwd: dirA, CwdB: dirB}); code != http.StatusBadRequest {
+		t.Errorf("missing model: status %d, want 400", code)
+	}
+	// The models must not change files in the same directory
+	for _, cwdB := range []string{"", dirA, filepath.Join(dirA, ".")} {
+		if code, _ := do("POST", "/api/comparisons", "alice", ComparisonRequest{Prompt: "echo: hi", ModelA: "predictable", ModelB: "predictable", Cwd: dirA, CwdB: cwdB}); code != http.StatusBadRequest {
+			t.Errorf("cwd_b %q: status %d, want 400", cwdB, code)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dirA, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := do("POST", "/api/comparisons", "alice", ComparisonRequest{Prompt: "echo: hi", ModelA: "predictable", ModelB: "predictable", Cwd: dirA, CwdB: filepath.Join(dirA, "sub")}); code != http.StatusBadRequest {
+		t.Errorf("nested cwd_b: status %d, want 400", code)
+	}
+
+	code, body := do("POST", "/api/comparisons", "alice", ComparisonRequest{Prompt: "echo: hi", ModelA: "predictable", ModelB: "predictable", Cwd: dirA, CwdB: dirB})
+	if code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", code, body)
+	}
+	var comparison ComparisonAPI
+	json.Unmarshal(body, &comparison)
+	if comparison.A.ConversationID == comparison.B.ConversationID {
+		t.Fatalf("both sides share conversation %s", comparison.A.ConversationID)
+	}
+	path := "/api/comparisons/" + strconv.FormatInt(comparison.ID, 10)
+
+	// Both models answer the prompt
+	deadline := time.Now().Add(h.timeout)
+	for comparison.A.Working || comparison.B.Working || comparison.A.Response == "" || comparison.B.Response == "" {
+		if time.Now().After(deadline) {
+			t.Fatalf("comparison not answered: %+v", comparison)
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, body = do("GET", path, "alice", nil)
+		json.Unmarshal(body, &comparison)
+	}
+	if comparison.A.Response != "hi" || comparison.B.Response != "hi" || comparison.A.Model != "predictable" {
+		t.Errorf("comparison = %+v", comparison)
+	}
+	if code, _ := do("GET", path, "bob", nil); code != http.StatusNotFound {
+		t.Errorf("other user: status %d, want 404", code)
+	}
+
+	// Choosing one side archives the other
+	if code, _ := do("POST", path+"/choose", "alice", ChooseComparisonRequest{ConversationID: "nope"}); code != http.StatusBadRequest {
+		t.Errorf("choose unknown conversation: status %d, want 400", code)
+	}
+	code, body = do("POST", path+"/choose", "alice", ChooseComparisonRequest{ConversationID: comparison.A.ConversationID})
+	if code != http.StatusOK {
+		t.Fatalf("choose: status %d: %s", code, body)
+	}
+	json.Unmarshal(body, &comparison)
+	if comparison.ChosenConversationID == nil || *comparison.ChosenConversationID != comparison.A.ConversationID || comparison.ChosenAt == nil {
+		t.Errorf("chosen comparison = %+v", comparison)
+	}
+	other, err := h.db.GetConversationByID(t.Context(), comparison.B.ConversationID)
+	if err != nil || !other.Archived {
+		t.Errorf("other conversation = %+v, %v; want archived", other, err)
+	}
+	if code, _ := do("POST", path+"/choose", "alice", ChooseComparisonRequest{ConversationID: comparison.B.ConversationID}); code != http.StatusConflict {
+		t.Errorf("second choice: status %d, want 409", code)
+	}
+
+	code, body = do("GET", "/api/comparisons", "alice", nil)
+	var list []ComparisonAPI
+	json.Unmarshal(body, &list)
+	if code != http.StatusOK || len(list) != 1 || list[0].ID != comparison.ID {
+		t.Errorf("list: status %d: %s", code, body)
+	}
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/loop"
+)
+
+// connectivityRetryInterval is how often turns held while the LLM providers
+// are unreachable send their request again, and how often the providers are
+// checked in the meantime.
+var connectivityRetryInterval = 30 * time.Second
+
+// isUnreachable reports whether an LLM request failed because its provider
+// couldn't be reached at all, rather than because it answered with an error.
+func isUnreachable(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
+
+// connectivity tracks whether the LLM providers are reachable. While they
+// aren't, turns are held instead of failing, and resume when a request
+// succeeds again or a check finds a provider reachable.
+type connectivity struct {
+	mu           sync.Mutex
+	offlineSince time.Time // zero while online
+	lastError    string
+	waiting      map[string]int // turns held, by conversation
+	online       chan struct{}  // closed when the providers are reachable again
+	// check, if set, reports whether any provider is reachable; it is called
+	// every connectivityRetryInterval while offline.
+	check  func(ctx context.Context) bool
+	logger *slog.Logger
+	// onChange is called with the conversations whose turns are held when
+	// that changes, and with those whose turns resumed.
+	onChange func(waiting, resumed []string)
+}
+
+func newConnectivity(logger *slog.Logger) *connectivity {
+	return &connectivity{waiting: make(map[string]int), logger: logger}
+}
+
+// wait holds a turn of the conversation whose LLM request failed with err, if
+// the provider was unreachable, until the providers may be reachable again.
+// It returns whether the request should be sent again.
+func (c *connectivity) wait(ctx context.Context, conversationID string, err error) bool {
+	if !isUnreachable(err) {
+		return false
+	}
+	c.mu.Lock()
+	if c.offlineSince.IsZero() {
+		c.offlineSince = time.Now()
+		c.online = make(chan struct{})
+		c.logger.Warn("LLM providers unreachable; holding turns until they are back", "error", err)
+		if c.check != nil {
+			go c.checkUntilOnline(c.online)
+		}
+	}
+	c.lastError = err.Error()
+	c.waiting[conversationID]++
+	online := c.online
+	c.mu.Unlock()
+	c.notify(nil)
+
+	select {
+	case <-ctx.Done():
+	case <-online:
+	case <-time.After(connectivityRetryInterval):
+	}
+
+	c.mu.Lock()
+	if c.waiting[conversationID]--; c.waiting[conversationID] == 0 {
+		delete(c.waiting, conversationID)
+	}
+	c.mu.Unlock()
+	c.notify([]string{conversationID})
+	return ctx.Err() == nil
+}
+
+// checkUntilOnline checks the providers until one is reachable or online is
+// closed.
+func (c *connectivity) checkUntilOnline(online chan struct{}) {
+	ticker := time.NewTicker(connectivityRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-online:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), providerHealthTimeout)
+		reachable := c.check(ctx)
+		cancel()
+		if reachable {
+			c.markOnline()
+			return
+		}
+	}
+}
+
+// markOnline records that the providers are reachable, resuming held turns.
+func (c *connectivity) markOnline() {
+	c.mu.Lock()
+	if c.offlineSince.IsZero() {
+		c.mu.Unlock()
+		return
+	}
+	c.logger.Info("LLM providers reachable again; resuming held turns", "offline_for", time.Since(c.offlineSince).Round(time.Second))
+	c.offlineSince = time.Time{}
+	c.lastError = ""
+	close(c.online)
+	c.mu.Unlock()
+	c.notify(nil)
+}
+
+func (c *connectivity) notify(resumed []string) {
+	if c.onChange == nil {
+		return
+	}
+	c.onChange(c.waitingConversations(), resumed)
+}
+
+func (c *connectivity) waitingConversations() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	waiting := make([]string, 0, len(c.waiting))
+	for id := range c.waiting {
+		waiting = append(waiting, id)
+	}
+	slices.Sort(waiting)
+	return waiting
+}
+
+// ConnectivityAPI is the response from /api/connectivity
+type ConnectivityAPI struct {
+	// Online is false from an LLM request failing to reach its provider
+	// until a request succeeds or a provider check finds one reachable
+	Online       bool       `json:"online"`
+	OfflineSince *time.Time `json:"offline_since,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	// Waiting are the conversations whose turns are held until the providers
+	// are reachable; they resume on their own
+	Waiting []string `json:"waiting"`
+}
+
+func (c *connectivity) snapshot() ConnectivityAPI {
+	waiting := c.waitingConversations()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp := ConnectivityAPI{Online: c.offlineSince.IsZero(), LastError: c.lastError, Waiting: waiting}
+	if !resp.Online {
+		since := c.offlineSince
+		resp.OfflineSince = &since
+	}
+	return resp
+}
+
+// isWaiting reports whether a turn of the conversation is held.
+func (c *connectivity) isWaiting(conversationID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.waiting[conversationID] > 0
+}
+
+// providersReachable checks whether any configured provider answers.
+func (s *Server) providersReachable(ctx context.Context) bool {
+	checker, ok := s.llmManager.(ProviderHealthChecker)
+	if !ok {
+		return false
+	}
+	for _, h := range checker.CheckProviderHealth(ctx) {
+		if h.Configured && h.Reachable {
+			return true
+		}
+	}
+	return false
+}
+
+// publishConnectivity tells clients which conversations' turns are held
+// until the providers are reachable, and which resumed.
+func (s *Server) publishConnectivity(waiting, resumed []string) {
+	for _, id := range waiting {
+		s.publishConversationState(ConversationState{ConversationID: id, Working: true, Offline: true})
+	}
+	if len(resumed) == 0 {
+		return
+	}
+	working := s.getWorkingConversations()
+	for _, id := range resumed {
+		if !slices.Contains(waiting, id) {
+			s.publishConversationState(ConversationState{ConversationID: id, Working: working[id]})
+		}
+	}
+}
+
+// waitOnline returns the loop's WaitOnline, which holds the conversation's
+// turns while the LLM providers are unreachable.
+func (cm *ConversationManager) waitOnline() loop.OnlineWaitFunc {
+	cm.mu.Lock()
+	conn := cm.connectivity
+	cm.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return func(ctx context.Context, err error) bool {
+		return conn.wait(ctx, cm.conversationID, err)
+	}
+}
+
+// handleConnectivity handles GET /api/connectivity: whether the LLM
+// providers are reachable, and the turns held until they are.
+func (s *Server) handleConnectivity(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.connectivity.snapshot())
+}
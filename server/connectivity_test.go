@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+// unreachableService fails requests as if its provider couldn't be reached
+// while down is set.
+type unreachableService struct {
+	llm.Service
+	down atomic.Bool
+}
+
+func (s *unreachableService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	if s.down.Load() {
+		return nil, fmt.Errorf("request failed: %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+	}
+	return s.Service.Do(ctx, req)
+}
+
+func TestIsUnreachable(t *testing.T) {
+	for err, want := range map[error]bool{
+		errors.New("status 500"): false,
+		fmt.Errorf("wrapped: %w", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}):       true,
+		errors.Join(errors.New("status 503"), &net.DNSError{Err: "no such host", Name: "x"}): true,
+		context.DeadlineExceeded: false,
+	} {
+		if got := isUnreachable(err); got != want {
+			t.Errorf("isUnreachable(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
+
+func TestOfflineTurnsResume(t *testing.T) {
+	oldInterval := connectivityRetryInterval
+	connectivityRetryInterval = 50 * time.Millisecond
+	t.Cleanup(func() { connectivityRetryInterval = oldInterval })
+
+	h := NewTestHarness(t)
+	defer h.Close()
+	service := &unreachableService{Service: h.llm}
+	h.server.llmManager = &testLLMManager{service: service}
+
+	getConnectivity := func() ConnectivityAPI {
+		w := httptest.NewRecorder()
+		h.server.handleConnectivity(w, httptest.NewRequest("GET", "/api/connectivity", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/connectivity: %d", w.Code)
+		}
+		var resp ConnectivityAPI
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if status := getConnectivity(); !status.Online {
+		t.Fatalf("expected to start online, got %+v", status)
+	}
+
+	// Turns sent while the providers are unreachable are held
+	service.down.Store(true)
+	h.NewConversation("echo: first", "")
+	deadline := time.Now().Add(5 * time.Second)
+	for status := getConnectivity(); status.Online || !slices.Contains(status.Waiting, h.convID); status = getConnectivity() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the turn to be held, got %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status := getConnectivity(); status.OfflineSince == nil || status.LastError == "" {
+		t.Errorf("expected when and why the providers went offline, got %+v", status)
+	}
+	h.Chat("echo: second")
+	time.Sleep(200 * time.Millisecond)
+	if responses := h.responses(); len(responses) != 0 {
+		t.Fatalf("expected no responses or errors while offline, got %q", responses)
+	}
+
+	// Both turns run once the providers are back
+	service.down.Store(false)
+	h.WaitResponse()
+	if h.responsesCount < 2 {
+		h.WaitResponse()
+	}
+	if responses := h.responses(); !slices.Equal(responses, []string{"first", "second"}) {
+		t.Errorf("responses = %q", responses)
+	}
+	if status := getConnectivity(); !status.Online || len(status.Waiting) != 0 || status.OfflineSince != nil {
+		t.Errorf("expected to be back online, got %+v", status)
+	}
+}
+
+// responses returns the text of the agent messages ending turns, including
+// errors.
+func (h *TestHarness) responses() []string {
+	h.t.Helper()
+	messages, err := h.db.ListMessages(h.t.Context(), h.convID)
+	if err != nil {
+		h.t.Fatalf("responses: %v", err)
+	}
+	var out []string
+	for _, msg := range messages {
+		if msg.Type != string(db.MessageTypeAgent) || msg.LlmData == nil {
+			continue
+		}
+		var llmMsg llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil || !llmMsg.EndOfTurn {
+			continue
+		}
+		out = append(out, llmMsg.Content[0].Text)
+	}
+	return out
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// Context breakdown categories, in the order they are returned
+const (
+	contextSystemPrompt  = "system_prompt"
+	contextGuidanceFiles = "guidance_files"
+	contextMemories      = "memories"
+	contextUserText      = "user_text"
+	contextAssistantText = "assistant_text"
+	contextThinking      = "thinking"
+	contextToolCalls     = "tool_calls"
+	contextToolResults   = "tool_results"
+	contextImages        = "images"
+)
+
+var contextCategories = []string{
+	contextSystemPrompt,
+	contextGuidanceFiles,
+	contextMemories,
+	contextUserText,
+	contextAssistantText,
+	contextThinking,
+	contextToolCalls,
+	contextToolResults,
+	contextImages,
+}
+
+// imageTokens is the estimated cost of an image; providers charge by its
+// size, which is not recorded, and typical screenshots come to about this.
+const imageTokens = 1500
+
+var rootGuidancePattern = regexp.MustCompile(`(?s)<root_guidance file="([^"]*)">(.*?)</root_guidance>`)
+
+// ContextCategoryAPI is the estimated size of one kind of content in a
+// conversation's context
+type ContextCategoryAPI struct {
+	Name   string `json:"name"`
+	Tokens int    `json:"tokens"`
+	Chars  int    `json:"chars"`
+}
+
+// ContextFileAPI is the estimated size of a guidance file in the system prompt
+type ContextFileAPI struct {
+	File   string `json:"file"`
+	Tokens int    `json:"tokens"`
+}
+
+// ContextBreakdownAPI is the response of GET /api/conversations/{id}/context-breakdown.
+// Token counts are estimated from the stored messages at about four
+// characters per token; ContextWindowUsed is what the provider last reported.
+type ContextBreakdownAPI struct {
+	Categories      []ContextCategoryAPI `json:"categories"`
+	GuidanceFiles   []ContextFileAPI     `json:"guidance_files"`
+	EstimatedTokens int                  `json:"estimated_tokens"`
+	// ContextWindowUsed is the context used by the last LLM request
+	ContextWindowUsed uint64 `json:"context_window_used"`
+	// ContextWindowSize is the model's context window, if the model is known
+	ContextWindowSize int `json:"context_window_size"`
+}
+
+// estimateTokens estimates the tokens of text, at about four characters per token.
+func estimateTokens(chars int) int {
+	return (chars + 3) / 4
+}
+
+// contextBreakdown tallies the context of a conversation by category.
+type contextBreakdown struct {
+	chars         map[string]int
+	images        int
+	guidanceFiles []ContextFileAPI
+}
+
+// addSystemPrompt splits the stored system prompt into the guidance files
+// and memories sections and the rest.
+func (b *contextBreakdown) addSystemPrompt(text string) {
+	if start, end := sectionBounds(text, "<guidance>", "</guidance>"); start >= 0 {
+		section := text[start:end]
+		for _, m := range rootGuidancePattern.FindAllStringSubmatch(section, -1) {
+			b.guidanceFiles = append(b.guidanceFiles, ContextFileAPI{File: m[1], Tokens: estimateTokens(len(m[0]))})
+		}
+		b.chars[contextGuidanceFiles] += len(section)
+		text = text[:start] + text[end:]
+	}
+	if start, end := sectionBounds(text, "<memories>", "</memories>"); start >= 0 {
+		b.chars[contextMemories] += end - start
+		text = text[:start] + text[end:]
+	}
+	b.chars[contextSystemPrompt] += len(text)
+}
+
+// sectionBounds returns the bounds of the first section of text between the
+// open and close tags, inclusive, or -1 if there is none.
+func sectionBounds(text, open, close string) (int, int) {
+	start := strings.Index(text, open)
+	if start < 0 {
+		return -1, -1
+	}
+	n := strings.Index(text[start:], close)
+	if n < 0 {
+		return -1, -1
+	}
+	return start, start + n + len(close)
+}
+
+func (b *contextBreakdown) addContent(c llm.Content, role llm.MessageRole) {
+	switch c.Type {
+	case llm.ContentTypeText, llm.ContentTypeDocument:
+		// Images are sent as text content with a media type
+		if c.Type == llm.ContentTypeText && c.MediaType != "" {
+			b.images++
+			return
+		}
+		if role == llm.MessageRoleAssistant {
+			b.chars[contextAssistantText] += len(c.Text)
+		} else {
+			b.chars[contextUserText] += len(c.Text)
+		}
+	case llm.ContentTypeThinking:
+		b.chars[contextThinking] += len(c.Thinking)
+	case llm.ContentTypeRedactedThinking:
+		b.chars[contextThinking] += len(c.Data)
+	case llm.ContentTypeToolUse:
+		b.chars[contextToolCalls] += len(c.ToolName) + len(c.ToolInput)
+	case llm.ContentTypeToolResult:
+		for _, result := range c.ToolResult {
+			if result.MediaType != "" {
+				b.images++
+				continue
+			}
+			b.chars[contextToolResults] += len(result.Text)
+		}
+	case llm.ContentTypeImage:
+		b.images++
+	}
+}
+
+func (b *contextBreakdown) api() ContextBreakdownAPI {
+	resp := ContextBreakdownAPI{
+		Categories:    make([]ContextCategoryAPI, 0, len(contextCategories)),
+		GuidanceFiles: b.guidanceFiles,
+	}
+	if resp.GuidanceFiles == nil {
+		resp.GuidanceFiles = []ContextFileAPI{}
+	}
+	for _, name := range contextCategories {
+		category := ContextCategoryAPI{Name: name, Chars: b.chars[name], Tokens: estimateTokens(b.chars[name])}
+		if name == contextImages {
+			category.Tokens = b.images * imageTokens
+		}
+		resp.Categories = append(resp.Categories, category)
+		resp.EstimatedTokens += category.Tokens
+	}
+	return resp
+}
+
+// breakdownContext tallies the messages sent to the LLM, skipping those
+// shown only to the user as the conversation manager does.
+func breakdownContext(messages []generated.Message) (ContextBreakdownAPI, uint64) {
+	b := &contextBreakdown{chars: make(map[string]int)}
+	var used uint64
+	for _, msg := range messages {
+		if msg.UsageData != nil {
+			var usage llm.Usage
+			if err := json.Unmarshal([]byte(*msg.UsageData), &usage); err == nil && usage.ContextWindowUsed() > 0 {
+				used = usage.ContextWindowUsed()
+			}
+		}
+		if msg.Type == string(db.MessageTypeGitInfo) || msg.Type == string(db.MessageTypeError) {
+			continue
+		}
+		llmMsg, err := convertToLLMMessage(msg)
+		if err != nil {
+			continue
+		}
+		for _, c := range llmMsg.Content {
+			if msg.Type == string(db.MessageTypeSystem) {
+				if c.Type == llm.ContentTypeText {
+					b.addSystemPrompt(c.Text)
+				}
+				continue
+			}
+			b.addContent(c, llmMsg.Role)
+		}
+	}
+	return b.api(), used
+}
+
+// handleContextBreakdown handles GET /api/conversations/{id}/context-breakdown,
+// an estimate of what takes up a conversation's context. Tool definitions
+// and the system prompt sections added when a conversation is loaded, such
+// as plan mode, are not stored and so not counted.
+func (s *Server) handleContextBreakdown(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conversationID := r.PathValue("id")
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	messages, err := s.db.ListMessagesForContext(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, used := breakdownContext(messages)
+	resp.ContextWindowUsed = used
+	if conversation.Model != nil {
+		if service, err := s.llmManager.GetService(*conversation.Model); err == nil {
+			resp.ContextWindowSize = service.TokenContextWindow()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextBreakdownSystemPrompt(t *testing.T) {
+	b := &contextBreakdown{chars: make(map[string]int)}
+	guidance := "<guidance>\n<root_guidance file=\"/repo/AGENTS.md\">\nUse tabs.\n</root_guidance>\n</guidance>"
+	memories := "<memories>\n- [id=1, global] Likes tests\n</memories>"
+	b.addSystemPrompt("You are an agent.\n" + guidance + "\nBe brief.\n\n" + memories)
+
+	if got := b.chars[contextGuidanceFiles]; got != len(guidance) {
+		t.Errorf("guidance chars = %d, want %d", got, len(guidance))
+	}
+	if got := b.chars[contextMemories]; got != len(memories) {
+		t.Errorf("memories chars = %d, want %d", got, len(memories))
+	}
+	if got, want := b.chars[contextSystemPrompt], len("You are an agent.\n\nBe brief.\n\n"); got != want {
+		t.Errorf("system prompt chars = %d, want %d", got, want)
+	}
+	if len(b.guidanceFiles) != 1 || b.guidanceFiles[0].File != "/repo/AGENTS.md" || b.guidanceFiles[0].Tokens == 0 {
+		t.Errorf("guidance files = %+v", b.guidanceFiles)
+	}
+}
+
+func TestContextBreakdown(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("bash: echo hello", t.TempDir())
+	h.WaitResponse()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	get := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/conversations/"+id+"/context-breakdown", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(h.convID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp ContextBreakdownAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	tokens := make(map[string]int)
+	sum := 0
+	for _, c := range resp.Categories {
+		tokens[c.Name] = c.Tokens
+		sum += c.Tokens
+	}
+	for _, name := range []string{contextSystemPrompt, contextUserText, contextToolCalls, contextToolResults} {
+		if tokens[name] == 0 {
+			t.Errorf("no %s tokens: %+v", name, resp.Categories)
+		}
+	}
+	if len(resp.Categories) != len(contextCategories) || resp.EstimatedTokens != sum {
+		t.Errorf("breakdown = %+v", resp)
+	}
+	if resp.ContextWindowSize == 0 {
+		t.Errorf("context window size not set")
+	}
+
+	if w := get("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("missing conversation: status %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// Message context flags. When a conversation's context is compacted, as when
+// it is continued in a new conversation or summarized, pinned messages are
+// kept in full and evictable ones are left out.
+const (
+	ContextFlagPinned    = "pinned"
+	ContextFlagEvictable = "evictable"
+)
+
+// ContextFlagRequest is the body of POST /api/conversation/<id>/messages/<message_id>/context-flag.
+// An empty flag removes it.
+type ContextFlagRequest struct {
+	Flag string `json:"flag"` // "pinned", "evictable" or empty
+}
+
+// ContextFlagAPI is the API representation of a message context flag
+type ContextFlagAPI struct {
+	MessageID string    `json:"message_id"`
+	Flag      string    `json:"flag"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toContextFlagAPI(f generated.MessageContextFlag) ContextFlagAPI {
+	return ContextFlagAPI{MessageID: f.MessageID, Flag: f.Flag, CreatedAt: f.CreatedAt}
+}
+
+// messageContextFlags returns the context flags of a conversation's messages
+// by message ID.
+func (s *Server) messageContextFlags(ctx context.Context, conversationID string) (map[string]string, error) {
+	flags, err := s.db.ListMessageContextFlags(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	byMessage := make(map[string]string, len(flags))
+	for _, f := range flags {
+		byMessage[f.MessageID] = f.Flag
+	}
+	return byMessage, nil
+}
+
+// handleMessageContextFlags handles GET /conversation/<id>/context-flags
+func (s *Server) handleMessageContextFlags(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	flags, err := s.db.ListMessageContextFlags(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list context flags", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]ContextFlagAPI, 0, len(flags))
+	for _, f := range flags {
+		resp = append(resp, toContextFlagAPI(f))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleMessageContextFlag handles POST /conversation/<id>/messages/<message_id>/context-flag
+func (s *Server) handleMessageContextFlag(w http.ResponseWriter, r *http.Request, conversationID, messageID string) {
+	ctx := r.Context()
+	message, err := s.db.GetMessageByID(ctx, messageID)
+	if err != nil || message.ConversationID != conversationID {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	var req ContextFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Flag {
+	case "":
+		if err := s.db.DeleteMessageContextFlag(ctx, messageID); err != nil {
+			s.logger.Error("Failed to delete context flag", "messageID", messageID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case ContextFlagPinned, ContextFlagEvictable:
+	default:
+		http.Error(w, "Invalid flag", http.StatusBadRequest)
+		return
+	}
+
+	flag, err := s.db.SaveMessageContextFlag(ctx, generated.UpsertMessageContextFlagParams{
+		MessageID:      messageID,
+		ConversationID: conversationID,
+		Flag:           req.Flag,
+	})
+	if err != nil {
+		s.logger.Error("Failed to save context flag", "messageID", messageID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toContextFlagAPI(*flag))
+}
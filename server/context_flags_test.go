@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+func TestMessageContextFlags(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: keep this", "")
+	h.WaitResponse()
+	h.waitIdle()
+	h.Chat("echo: drop this")
+	h.WaitResponse()
+	h.waitIdle()
+
+	messages, err := h.db.ListMessages(h.t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var agentMessages []string
+	for _, msg := range messages {
+		if msg.Type == string(db.MessageTypeAgent) {
+			agentMessages = append(agentMessages, msg.MessageID)
+		}
+	}
+	if len(agentMessages) != 2 {
+		t.Fatalf("expected 2 agent messages, got %d", len(agentMessages))
+	}
+
+	getFlags := func() []ContextFlagAPI {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("GET", "/"+h.convID+"/context-flags", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var flags []ContextFlagAPI
+		json.Unmarshal(w.Body.Bytes(), &flags)
+		return flags
+	}
+	if got := getFlags(); len(got) != 0 {
+		t.Fatalf("expected no flags, got %v", got)
+	}
+
+	pinPath := "/messages/" + agentMessages[0] + "/context-flag"
+	evictPath := "/messages/" + agentMessages[1] + "/context-flag"
+	if w := h.post(pinPath, ContextFlagRequest{Flag: "sticky"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid flag, got %d", w.Code)
+	}
+	if w := h.post("/messages/missing/context-flag", ContextFlagRequest{Flag: ContextFlagPinned}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown message, got %d", w.Code)
+	}
+	if w := h.post(pinPath, ContextFlagRequest{Flag: ContextFlagPinned}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := h.post(evictPath, ContextFlagRequest{Flag: ContextFlagEvictable}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := getFlags(); len(got) != 2 {
+		t.Fatalf("expected 2 flags, got %+v", got)
+	}
+
+	// Evictable messages are left out of the transcript used for compaction
+	flags, err := h.server.messageContextFlags(h.t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcript := conversationTranscript(messages, flags)
+	if !strings.Contains(transcript, "Agent: keep this") {
+		t.Errorf("expected the pinned message in the transcript, got %q", transcript)
+	}
+	if strings.Contains(transcript, "Agent: drop this") {
+		t.Errorf("expected the evictable message to be left out, got %q", transcript)
+	}
+
+	// Clearing a flag removes it
+	if w := h.post(evictPath, ContextFlagRequest{}); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if got := getFlags(); len(got) != 1 || got[0].MessageID != agentMessages[0] || got[0].Flag != ContextFlagPinned {
+		t.Fatalf("unexpected flags after clearing: %+v", got)
+	}
+}
+
+func TestConversationTranscriptKeepsPinnedToolOutput(t *testing.T) {
+	long := strings.Repeat("x", 400)
+	data, _ := json.Marshal(llm.Message{
+		Role: llm.MessageRoleUser,
+		Content: []llm.Content{{
+			Type:       llm.ContentTypeToolResult,
+			ToolResult: []llm.Content{{Type: llm.ContentTypeText, Text: long}},
+		}},
+	})
+	llmData := string(data)
+	messages := []generated.Message{{MessageID: "m1", Type: string(db.MessageTypeUser), LlmData: &llmData}}
+
+	if got := conversationTranscript(messages, nil); strings.Contains(got, long) {
+		t.Errorf("expected the tool output to be cut, got %q", got)
+	}
+	if got := conversationTranscript(messages, map[string]string{"m1": ContextFlagPinned}); !strings.Contains(got, long) {
+		t.Errorf("expected the pinned tool output in full, got %q", got)
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// maskedValue replaces secret values in API responses and recorded messages.
+const maskedValue = "********"
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvVarRequest is the request body for setting or unsetting a conversation environment variable
+type EnvVarRequest struct {
+	Name   string `json:"name"`
+	Value  string `json:"value,omitempty"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// EnvVarAPI is the API representation of a conversation environment variable.
+// Secret values are always masked.
+type EnvVarAPI struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret"`
+}
+
+// validateEnvName checks that name is usable as an environment variable name
+func validateEnvName(name string) error {
+	if !envNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid environment variable name: %q", name)
+	}
+	return nil
+}
+
+// conversationEnv splits a conversation's stored variables into KEY=VALUE
+// entries and the secret values that must be masked.
+func conversationEnv(vars []generated.ConversationEnv) (env, secrets []string) {
+	for _, v := range vars {
+		env = append(env, v.Name+"="+v.Value)
+		if v.Secret && v.Value != "" {
+			secrets = append(secrets, v.Value)
+		}
+	}
+	// Longest first, so a secret containing another is masked as a whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return env, secrets
+}
+
+// handleConversationEnv handles GET /conversation/<id>/env, POST /conversation/<id>/env
+// and POST /conversation/<id>/env/unset
+func (s *Server) handleConversationEnv(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req EnvVarRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateEnvName(req.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Tools pick up their environment when the loop starts, so it is restarted
+		s.mu.Lock()
+		manager := s.activeConversations[conversationID]
+		s.mu.Unlock()
+		if manager != nil && manager.IsAgentWorking() {
+			http.Error(w, "Conversation is busy", http.StatusConflict)
+			return
+		}
+
+		var err error
+		if strings.HasSuffix(r.URL.Path, "/unset") {
+			err = s.db.UnsetConversationEnv(ctx, conversationID, req.Name)
+		} else {
+			err = s.db.SetConversationEnv(ctx, conversationID, req.Name, req.Value, req.Secret)
+		}
+		if err != nil {
+			s.logger.Error("Failed to update conversation environment", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if manager != nil {
+			if err := manager.ReloadContext(); err != nil {
+				s.logger.Warn("Failed to reload conversation context", "conversationID", conversationID, "error", err)
+			}
+		}
+	}
+
+	vars, err := s.db.ListConversationEnv(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list conversation environment", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	result := make([]EnvVarAPI, 0, len(vars))
+	for _, v := range vars {
+		api := EnvVarAPI{Name: v.Name, Value: v.Value, Secret: v.Secret}
+		if v.Secret {
+			api.Value = maskedValue
+		}
+		result = append(result, api)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]EnvVarAPI{"env": result})
+}
+
+// maskSecrets returns a copy of message with every occurrence of the secret
+// values in its text, tool inputs, tool results and display data masked.
+func maskSecrets(message llm.Message, secrets []string) llm.Message {
+	if len(secrets) == 0 {
+		return message
+	}
+	message.Content = maskContents(message.Content, secrets)
+	return message
+}
+
+func maskContents(contents []llm.Content, secrets []string) []llm.Content {
+	if contents == nil {
+		return nil
+	}
+	masked := make([]llm.Content, len(contents))
+	for i, c := range contents {
+		c.Text = maskString(c.Text, secrets)
+		c.Thinking = maskString(c.Thinking, secrets)
+		if len(c.ToolInput) > 0 {
+			if v, ok := maskJSON(c.ToolInput, secrets); ok {
+				c.ToolInput = v
+			}
+		}
+		c.ToolResult = maskContents(c.ToolResult, secrets)
+		if c.Display != nil {
+			if data, err := json.Marshal(c.Display); err == nil {
+				if v, ok := maskJSON(data, secrets); ok {
+					c.Display = v
+				}
+			}
+		}
+		masked[i] = c
+	}
+	return masked
+}
+
+func maskString(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, maskedValue)
+	}
+	return s
+}
+
+// maskJSON masks secrets in the string values of a JSON document,
+// reporting whether anything changed.
+func maskJSON(data []byte, secrets []string) (json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	changed := false
+	v = maskJSONValue(v, secrets, &changed)
+	if !changed {
+		return nil, false
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func maskJSONValue(v any, secrets []string, changed *bool) any {
+	switch v := v.(type) {
+	case string:
+		masked := maskString(v, secrets)
+		if masked != v {
+			*changed = true
+		}
+		return masked
+	case []any:
+		for i := range v {
+			v[i] = maskJSONValue(v[i], secrets, changed)
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = maskJSONValue(v[k], secrets, changed)
+		}
+	}
+	return v
+}
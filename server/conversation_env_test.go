@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestConversationEnv(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+	h.waitIdle()
+
+	list := func(w *httptest.ResponseRecorder) map[string]EnvVarAPI {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp map[string][]EnvVarAPI
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		vars := make(map[string]EnvVarAPI)
+		for _, v := range resp["env"] {
+			vars[v.Name] = v
+		}
+		return vars
+	}
+
+	if w := h.post("/env", EnvVarRequest{Name: "BAD NAME", Value: "x"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid name, got %d", w.Code)
+	}
+	list(h.post("/env", EnvVarRequest{Name: "PLAIN_VAR", Value: "visible"}))
+	vars := list(h.post("/env", EnvVarRequest{Name: "SECRET_VAR", Value: "hunter2-secret", Secret: true}))
+	if vars["PLAIN_VAR"].Value != "visible" {
+		t.Errorf("expected plain value to be returned, got %q", vars["PLAIN_VAR"].Value)
+	}
+	if v := vars["SECRET_VAR"]; !v.Secret || v.Value != maskedValue {
+		t.Errorf("expected secret value to be masked, got %+v", v)
+	}
+
+	// Tools see both values, but the recorded output masks the secret
+	h.Chat("bash: echo $PLAIN_VAR $SECRET_VAR")
+	out := h.WaitToolResult()
+	if !strings.Contains(out, "visible "+maskedValue) {
+		t.Errorf("expected masked tool output, got %q", out)
+	}
+	h.WaitResponse()
+	h.waitIdle()
+
+	w := httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("GET", "/"+h.convID, nil))
+	if strings.Contains(w.Body.String(), "hunter2-secret") {
+		t.Errorf("secret value leaked into the conversation")
+	}
+
+	vars = list(h.post("/env/unset", EnvVarRequest{Name: "PLAIN_VAR"}))
+	if _, ok := vars["PLAIN_VAR"]; ok {
+		t.Errorf("expected PLAIN_VAR to be unset")
+	}
+	h.Chat("bash: echo \"[$PLAIN_VAR]\"")
+	h.WaitResponse()
+	messages, err := h.db.ListMessages(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last string
+	for _, m := range messages {
+		var msg llm.Message
+		if m.LlmData == nil || json.Unmarshal([]byte(*m.LlmData), &msg) != nil {
+			continue
+		}
+		for _, c := range msg.Content {
+			if c.Type == llm.ContentTypeToolResult && len(c.ToolResult) > 0 {
+				last = c.ToolResult[0].Text
+			}
+		}
+	}
+	if !strings.Contains(last, "[]") {
+		t.Errorf("expected unset variable to be empty, got %q", last)
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	msg := llm.Message{
+		Role: llm.MessageRoleUser,
+		Content: []llm.Content{{
+			Type:      llm.ContentTypeToolResult,
+			ToolInput: json.RawMessage(`{"command":"echo 12345678","timeout":12345678}`),
+			ToolResult: []llm.Content{
+				{Type: llm.ContentTypeText, Text: "value=12345678"},
+			},
+			Display: map[string]any{"output": "12345678"},
+		}},
+	}
+	masked := maskSecrets(msg, []string{"12345678"})
+
+	c := masked.Content[0]
+	if c.ToolResult[0].Text != "value="+maskedValue {
+		t.Errorf("unexpected tool result text: %q", c.ToolResult[0].Text)
+	}
+	var input map[string]any
+	if err := json.Unmarshal(c.ToolInput, &input); err != nil {
+		t.Fatalf("masked tool input is not valid JSON: %v", err)
+	}
+	if input["command"] != "echo "+maskedValue || input["timeout"] != float64(12345678) {
+		t.Errorf("unexpected masked tool input: %s", c.ToolInput)
+	}
+	if data, _ := json.Marshal(c.Display); strings.Contains(string(data), "12345678") {
+		t.Errorf("secret left in display data: %s", data)
+	}
+	if msg.Content[0].ToolResult[0].Text != "value=12345678" {
+		t.Errorf("original message was modified")
+	}
+}
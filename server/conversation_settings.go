@@ -0,0 +1,293 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+// ConversationSettings are the sampling, sandbox, timeout and tool policy settings of a conversation; unset fields use the model's
+// or the server's defaults
+type ConversationSettings struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   *int64   `json:"max_tokens,omitempty"`
+	// Sandbox limits what models that run tools themselves, such as Codex, may do
+	Sandbox *string `json:"sandbox,omitempty"`
+	// TurnTimeout bounds a turn, from the user's message to the agent's answer, in seconds
+	TurnTimeout *int64 `json:"turn_timeout_seconds,omitempty"`
+	// ToolTimeout bounds each tool execution, in seconds
+	ToolTimeout *int64 `json:"tool_timeout_seconds,omitempty"`
+	// ReadOnly limits the agent to tools and commands that don't modify
+	// files or change anything over the network, for analysis only
+	ReadOnly bool `json:"read_only,omitempty"`
+	// ApproveRiskyCommands holds shell commands in a risk class, such as
+	// force pushes and recursive deletes, until the user approves them
+	ApproveRiskyCommands bool `json:"approve_risky_commands,omitempty"`
+	// WindowsPaths tells the agent to give paths to the user in the form of
+	// the Windows host and lets tools take such paths, when Shelley runs in WSL
+	WindowsPaths bool `json:"windows_paths,omitempty"`
+	// Timezone is the user's IANA time zone, such as Europe/Berlin, and
+	// Locale their BCP 47 language tag, such as de-DE. If either is set, the
+	// agent is told the user's local time with their messages, when the date
+	// or either of them changes.
+	Timezone *string `json:"timezone,omitempty"`
+	Locale   *string `json:"locale,omitempty"`
+	// ResponseSchema is a JSON schema the agent's answers must match, for
+	// programs consuming them; unset leaves answers free-form
+	ResponseSchema json.RawMessage `json:"response_schema,omitempty"`
+}
+
+func toConversationSettings(s *generated.ConversationSetting) ConversationSettings {
+	if s == nil {
+		return ConversationSettings{}
+	}
+	var responseSchema json.RawMessage
+	if s.ResponseSchema != nil {
+		responseSchema = json.RawMessage(*s.ResponseSchema)
+	}
+	return ConversationSettings{
+		Temperature:          s.Temperature,
+		TopP:                 s.TopP,
+		MaxTokens:            s.MaxTokens,
+		Sandbox:              s.Sandbox,
+		TurnTimeout:          s.TurnTimeoutSeconds,
+		ToolTimeout:          s.ToolTimeoutSeconds,
+		ReadOnly:             s.ReadOnly,
+		ApproveRiskyCommands: s.ApproveRiskyCommands,
+		WindowsPaths:         s.WindowsPaths,
+		Timezone:             s.Timezone,
+		Locale:               s.Locale,
+		ResponseSchema:       responseSchema,
+	}
+}
+
+// limits returns the loop limits with the conversation's timeouts, if set.
+func (c ConversationSettings) limits(limits loop.Limits) loop.Limits {
+	if c.TurnTimeout != nil {
+		limits.TurnTimeout = time.Duration(*c.TurnTimeout) * time.Second
+	}
+	if c.ToolTimeout != nil {
+		limits.ToolTimeout = time.Duration(*c.ToolTimeout) * time.Second
+	}
+	return limits
+}
+
+// sampling returns the settings as request sampling parameters, or nil if all are unset.
+func (c ConversationSettings) sampling() *llm.Sampling {
+	if c.Temperature == nil && c.TopP == nil && c.MaxTokens == nil {
+		return nil
+	}
+	sampling := &llm.Sampling{Temperature: c.Temperature, TopP: c.TopP}
+	if c.MaxTokens != nil {
+		sampling.MaxTokens = int(*c.MaxTokens)
+	}
+	return sampling
+}
+
+// responseSchema returns the response schema to save, or nil if it is unset
+// or null.
+func (c ConversationSettings) responseSchema() *string {
+	if len(c.ResponseSchema) == 0 || string(c.ResponseSchema) == "null" {
+		return nil
+	}
+	schema := string(c.ResponseSchema)
+	return &schema
+}
+
+// responseFormat returns the response format asking for answers matching the
+// response schema, or nil if it is unset.
+func (c ConversationSettings) responseFormat() *llm.ResponseFormat {
+	schema := c.responseSchema()
+	if schema == nil {
+		return nil
+	}
+	return &llm.ResponseFormat{Name: "response", Schema: json.RawMessage(*schema)}
+}
+
+// location returns the user's time zone, or nil if it is unset.
+func (c ConversationSettings) location() *time.Location {
+	if c.Timezone == nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(*c.Timezone)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// localTime returns the note telling the agent the user's local time at now
+// and their locale, and a key of what it says apart from the time of day:
+// the user's date, UTC offset and locale. Both are empty if neither the time
+// zone nor the locale is set.
+func (c ConversationSettings) localTime(now time.Time) (note, key string) {
+	if c.Timezone == nil && c.Locale == nil {
+		return "", ""
+	}
+	loc := c.location()
+	if loc == nil {
+		loc = time.Local
+	}
+	var locale string
+	if c.Locale != nil {
+		locale = *c.Locale
+	}
+	now = now.In(loc)
+	return LocalTimePrompt(now, locale), now.Format("2006-01-02 -07:00 ") + locale
+}
+
+// validLocale reports whether locale looks like a BCP 47 language tag: a
+// language of letters followed by subtags of up to 8 letters or digits,
+// separated by hyphens.
+func validLocale(locale string) bool {
+	subtags := strings.Split(locale, "-")
+	if n := len(subtags[0]); n < 2 || n > 8 || strings.IndexFunc(subtags[0], func(r rune) bool { return !isASCIILetter(r) }) >= 0 {
+		return false
+	}
+	for _, subtag := range subtags[1:] {
+		if subtag == "" || len(subtag) > 8 || strings.IndexFunc(subtag, func(r rune) bool { return !isASCIILetter(r) && (r < '0' || r > '9') }) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCIILetter(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
+
+// validate checks the settings against the limits of the model's provider.
+func (c ConversationSettings) validate(service llm.Service) error {
+	if c.MaxTokens != nil && *c.MaxTokens <= 0 {
+		return errors.New("max_tokens must be positive")
+	}
+	if c.TurnTimeout != nil && *c.TurnTimeout <= 0 {
+		return errors.New("turn_timeout_seconds must be positive")
+	}
+	if c.ToolTimeout != nil && *c.ToolTimeout <= 0 {
+		return errors.New("tool_timeout_seconds must be positive")
+	}
+	if c.Sandbox != nil && !slices.Contains(llm.SandboxModes, *c.Sandbox) {
+		return fmt.Errorf("sandbox must be one of %s", strings.Join(llm.SandboxModes, ", "))
+	}
+	if c.ReadOnly && c.Sandbox != nil && *c.Sandbox != llm.SandboxReadOnly {
+		return fmt.Errorf("the sandbox of a read-only conversation must be %s", llm.SandboxReadOnly)
+	}
+	if c.Timezone != nil && (*c.Timezone == "" || *c.Timezone == "Local" || c.location() == nil) {
+		return fmt.Errorf("timezone %q is not an IANA time zone", *c.Timezone)
+	}
+	if c.Locale != nil && !validLocale(*c.Locale) {
+		return fmt.Errorf("locale %q is not a BCP 47 language tag", *c.Locale)
+	}
+	if schema := c.responseSchema(); schema != nil {
+		var v map[string]any
+		if err := json.Unmarshal([]byte(*schema), &v); err != nil {
+			return errors.New("response_schema must be a JSON schema object")
+		}
+	}
+	sampling := c.sampling()
+	if sampling == nil {
+		return nil
+	}
+	if sv, ok := service.(llm.SamplingValidator); ok {
+		return sv.ValidateSampling(*sampling)
+	}
+	return nil
+}
+
+// validateSettings checks the settings like validate, and that the server
+// can honor them.
+func (s *Server) validateSettings(c ConversationSettings, service llm.Service) error {
+	if c.WindowsPaths && s.wsl == nil {
+		return errors.New("windows_paths requires Shelley to run in WSL")
+	}
+	return c.validate(service)
+}
+
+// handleConversationSettings handles GET /conversation/<id>/settings and POST /conversation/<id>/settings.
+// POST changes the settings present in the body and keeps the others; null
+// sets a setting back to the model's or the server's default.
+func (s *Server) handleConversationSettings(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	settings, err := s.db.GetConversationSettings(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation settings", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodPost {
+		// Decoding over the current settings changes only the fields present
+		req := toConversationSettings(settings)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		modelID := s.Settings().DefaultModel
+		if conversation.Model != nil {
+			modelID = *conversation.Model
+		}
+		service, err := s.llmManager.GetService(modelID)
+		if err != nil {
+			http.Error(w, "Unsupported model: "+modelID, http.StatusBadRequest)
+			return
+		}
+		if err := s.validateSettings(req, service); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Settings are read when the loop starts, so it is restarted
+		s.mu.Lock()
+		manager := s.activeConversations[conversationID]
+		s.mu.Unlock()
+		if manager != nil && manager.IsAgentWorking() {
+			http.Error(w, "Conversation is busy", http.StatusConflict)
+			return
+		}
+
+		settings, err = s.db.SaveConversationSettings(ctx, generated.UpsertConversationSettingsParams{
+			ConversationID:       conversationID,
+			Temperature:          req.Temperature,
+			TopP:                 req.TopP,
+			MaxTokens:            req.MaxTokens,
+			Sandbox:              req.Sandbox,
+			TurnTimeoutSeconds:   req.TurnTimeout,
+			ToolTimeoutSeconds:   req.ToolTimeout,
+			ReadOnly:             req.ReadOnly,
+			ApproveRiskyCommands: req.ApproveRiskyCommands,
+			WindowsPaths:         req.WindowsPaths,
+			Timezone:             req.Timezone,
+			Locale:               req.Locale,
+			ResponseSchema:       req.responseSchema(),
+		})
+		if err != nil {
+			s.logger.Error("Failed to save conversation settings", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if manager != nil {
+			if err := manager.ReloadContext(); err != nil {
+				s.logger.Warn("Failed to reload conversation context", "conversationID", conversationID, "error", err)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toConversationSettings(settings))
+}
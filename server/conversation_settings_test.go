@@ -0,0 +1,315 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestConversationSettings(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: hello", t.TempDir())
+	h.WaitResponse()
+	h.waitIdle()
+
+	// lastSampling returns the sampling of the last agent request; slug
+	// generation sends requests without a system prompt
+	lastSampling := func() *llm.Sampling {
+		t.Helper()
+		requests := h.llm.GetRecentRequests()
+		for i := len(requests) - 1; i >= 0; i-- {
+			if len(requests[i].System) > 0 {
+				return requests[i].Sampling
+			}
+		}
+		t.Fatal("no agent request")
+		return nil
+	}
+
+	getSettings := func() ConversationSettings {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("GET", "/"+h.convID+"/settings", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var settings ConversationSettings
+		json.Unmarshal(w.Body.Bytes(), &settings)
+		return settings
+	}
+
+	if settings := getSettings(); settings.Temperature != nil || settings.TopP != nil || settings.MaxTokens != nil {
+		t.Fatalf("expected default settings, got %+v", settings)
+	}
+	if sampling := lastSampling(); sampling != nil {
+		t.Fatalf("expected no sampling overrides by default, got %+v", sampling)
+	}
+
+	temperature, maxTokens := 0.2, int64(2048)
+	if w := h.post("/settings", ConversationSettings{Temperature: &temperature, MaxTokens: &maxTokens}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if settings := getSettings(); settings.Temperature == nil || *settings.Temperature != 0.2 || settings.MaxTokens == nil || *settings.MaxTokens != 2048 || settings.TopP != nil {
+		t.Fatalf("unexpected settings: %+v", settings)
+	}
+
+	// The next turn uses the new settings
+	h.Chat("echo: again")
+	h.WaitResponse()
+	h.waitIdle()
+	if sampling := lastSampling(); sampling == nil || sampling.Temperature == nil || *sampling.Temperature != 0.2 || sampling.MaxTokens != 2048 || sampling.TopP != nil {
+		t.Errorf("unexpected sampling in request: %+v", sampling)
+	}
+
+	invalid := int64(0)
+	if w := h.post("/settings", ConversationSettings{MaxTokens: &invalid}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid max_tokens, got %d", w.Code)
+	}
+	sandbox := "none"
+	if w := h.post("/settings", ConversationSettings{Sandbox: &sandbox}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid sandbox, got %d", w.Code)
+	}
+	sandbox = llm.SandboxReadOnly
+	if w := h.post("/settings", ConversationSettings{Sandbox: &sandbox}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if settings := getSettings(); settings.Sandbox == nil || *settings.Sandbox != llm.SandboxReadOnly {
+		t.Errorf("unexpected settings: %+v", settings)
+	}
+
+	// Omitted fields are kept, and null ones go back to the defaults
+	if w := h.post("/settings", map[string]any{"temperature": nil}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if settings := getSettings(); settings.Temperature != nil || settings.MaxTokens == nil || settings.Sandbox == nil {
+		t.Errorf("expected only the temperature to be cleared, got %+v", settings)
+	}
+	if w := h.post("/settings", map[string]any{"max_tokens": nil, "sandbox": nil}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if settings := getSettings(); settings.MaxTokens != nil || settings.Sandbox != nil {
+		t.Errorf("expected settings to be cleared, got %+v", settings)
+	}
+}
+
+func TestConversationTimeouts(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: hello", t.TempDir())
+	h.WaitResponse()
+	h.waitIdle()
+
+	turnTimeout := int64(1)
+	if w := h.post("/settings", ConversationSettings{TurnTimeout: &turnTimeout}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	invalid := int64(-1)
+	if w := h.post("/settings", ConversationSettings{TurnTimeout: &turnTimeout, ToolTimeout: &invalid}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid tool_timeout_seconds, got %d", w.Code)
+	}
+
+	// The tool is stopped with its partial output and the turn ends
+	h.Chat("bash: echo started; sleep 30")
+	if result := h.WaitToolResult(); !strings.Contains(result, "started") || !strings.Contains(result, "timed out") {
+		t.Errorf("tool result = %q, want partial output and a timeout note", result)
+	}
+	h.waitIdle()
+	latest, err := h.db.GetLatestMessage(t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg llm.Message
+	json.Unmarshal([]byte(*latest.LlmData), &msg)
+	if msg.ErrorType != llm.ErrorTypeTimeout {
+		t.Errorf("turn ended with %+v, want a timeout", msg)
+	}
+}
+
+func TestReadOnlyConversation(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	h.NewConversation("echo: hello", dir)
+	h.WaitResponse()
+	h.waitIdle()
+
+	sandbox := llm.SandboxWorkspaceWrite
+	if w := h.post("/settings", ConversationSettings{ReadOnly: true, Sandbox: &sandbox}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a writable sandbox, got %d", w.Code)
+	}
+	if w := h.post("/settings", ConversationSettings{ReadOnly: true}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Commands that modify anything are refused, and writing tools are gone
+	h.Chat("bash: touch " + filepath.Join(dir, "notes.txt"))
+	if result := h.WaitToolResult(); !strings.Contains(result, "read-only") {
+		t.Errorf("tool result = %q, want a read-only refusal", result)
+	}
+	h.waitIdle()
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected the file not to be created, got %v", err)
+	}
+	if !strings.Contains(h.systemPrompt(), "<read_only>") {
+		t.Error("expected the system prompt to explain read-only mode")
+	}
+	requests := h.llm.GetRecentRequests()
+	for _, tool := range requests[len(requests)-1].Tools {
+		if tool.Name == "patch" {
+			t.Error("expected no patch tool in a read-only conversation")
+		}
+	}
+
+	// Changing another setting keeps the conversation read-only
+	toolTimeout := int64(60)
+	if w := h.post("/settings", ConversationSettings{ToolTimeout: &toolTimeout}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("bash: touch " + filepath.Join(dir, "notes.txt"))
+	if result := h.WaitToolResult(); !strings.Contains(result, "read-only") {
+		t.Errorf("tool result = %q, want a read-only refusal after a partial update", result)
+	}
+	h.waitIdle()
+
+	// Turning it off brings writing back
+	if w := h.post("/settings", map[string]any{"read_only": false}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("bash: touch " + filepath.Join(dir, "notes.txt"))
+	h.WaitToolResult()
+	h.waitIdle()
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("expected the file to be created, got %v", err)
+	}
+}
+
+func TestConversationLocale(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: hello", t.TempDir())
+	h.WaitResponse()
+	h.waitIdle()
+	// localTime returns the local time notes of the last LLM request
+	localTime := func() []string {
+		t.Helper()
+		requests := h.llm.GetRecentRequests()
+		var notes []string
+		for _, m := range requests[len(requests)-1].Messages {
+			for _, c := range m.Content {
+				if strings.Contains(c.Text, "<local_time>") {
+					notes = append(notes, c.Text)
+				}
+			}
+		}
+		return notes
+	}
+	if notes := localTime(); len(notes) != 0 {
+		t.Errorf("expected no local time without a time zone or locale, got %q", notes)
+	}
+
+	str := func(s string) *string { return &s }
+	for _, settings := range []ConversationSettings{
+		{Timezone: str("Mars/Olympus_Mons")},
+		{Timezone: str("")},
+		{Timezone: str("Local")},
+		{Locale: str("de_DE")},
+		{Locale: str("x")},
+		{Locale: str("en-")},
+	} {
+		if w := h.post("/settings", settings); w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %+v, got %d", settings, w.Code)
+		}
+	}
+
+	if w := h.post("/settings", ConversationSettings{Timezone: str("Pacific/Kiritimati"), Locale: str("de-DE")}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("echo: again")
+	h.WaitResponse()
+	h.waitIdle()
+	// The note goes with the user's message, keeping the system prompt
+	// unchanged so that the history stays cached
+	if strings.Contains(h.systemPrompt(), "<local_time>") {
+		t.Error("expected no local time in the system prompt")
+	}
+	notes := localTime()
+	if len(notes) != 1 || !strings.Contains(notes[0], "Pacific/Kiritimati") || !strings.Contains(notes[0], "UTC+14:00") || !strings.Contains(notes[0], "locale is de-DE") {
+		t.Errorf("expected the user's local time and locale with the message, got %q", notes)
+	}
+	requests := h.llm.GetRecentRequests()
+	var hasNow bool
+	for _, tool := range requests[len(requests)-1].Tools {
+		hasNow = hasNow || tool.Name == "now"
+	}
+	if !hasNow {
+		t.Error("expected the now tool")
+	}
+
+	// It is not repeated while the date and time zone stay the same
+	h.Chat("echo: once more")
+	h.WaitResponse()
+	h.waitIdle()
+	if notes := localTime(); len(notes) != 1 {
+		t.Errorf("expected the local time once in the history, got %q", notes)
+	}
+}
+
+func TestConversationResponseSchema(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: hello", t.TempDir())
+	h.WaitResponse()
+	h.waitIdle()
+
+	lastFormat := func() *llm.ResponseFormat {
+		t.Helper()
+		requests := h.llm.GetRecentRequests()
+		for i := len(requests) - 1; i >= 0; i-- {
+			if len(requests[i].System) > 0 {
+				return requests[i].ResponseFormat
+			}
+		}
+		t.Fatal("no agent request")
+		return nil
+	}
+	if format := lastFormat(); format != nil {
+		t.Fatalf("expected no response format by default, got %+v", format)
+	}
+
+	if w := h.post("/settings", map[string]any{"response_schema": []int{1}}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a schema that isn't an object, got %d", w.Code)
+	}
+	schema := `{"type":"object","properties":{"ok":{"type":"boolean"}},"required":["ok"]}`
+	if w := h.post("/settings", ConversationSettings{ResponseSchema: json.RawMessage(schema)}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat(`echo: {"ok": true}`)
+	h.WaitResponse()
+	h.waitIdle()
+	if format := lastFormat(); format == nil || string(format.Schema) != schema {
+		t.Errorf("expected the conversation's schema in the request, got %+v", format)
+	}
+
+	// null sets answers back to free-form
+	if w := h.post("/settings", map[string]any{"response_schema": nil}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("echo: again")
+	h.WaitResponse()
+	h.waitIdle()
+	if format := lastFormat(); format != nil {
+		t.Errorf("expected no response format after clearing the schema, got %+v", format)
+	}
+}
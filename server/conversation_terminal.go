@@ -0,0 +1,46 @@
+package server
+
+import (
+	"cmp"
+	"net/http"
+	"os"
+	"os/exec"
+)
+
+// handleConversationTerminal handles GET /api/conversations/{id}/terminal, a
+// websocket running an interactive shell in a PTY, in the environment the
+// conversation's tools use: its current working directory, its project and
+// conversation variables and SHELLEY_CONVERSATION_ID. The messages are the
+// same as for /api/exec-ws.
+func (s *Server) handleConversationTerminal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conversationID := r.PathValue("id")
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to load conversation for terminal", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	cwd, env := manager.TerminalEnvironment()
+	if cwd == "" && conversation.Cwd != nil {
+		cwd = *conversation.Cwd
+	}
+	if cwd == "" {
+		cwd, err = os.Getwd()
+		if err != nil {
+			cwd = "/"
+		}
+	}
+
+	shell := exec.CommandContext(ctx, cmp.Or(os.Getenv("SHELL"), "bash"))
+	shell.Dir = cwd
+	shell.Env = append(append(os.Environ(), "TERM=xterm-256color"), env...)
+	s.serveTerminal(w, r, shell)
+}
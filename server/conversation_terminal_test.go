@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+func TestConversationTerminal(t *testing.T) {
+	t.Setenv("SHELL", "bash")
+	h := NewTestHarness(t)
+	defer h.cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	conversation, err := h.db.CreateConversation(ctx, nil, true, &dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.db.SetConversationEnv(ctx, conversation.ConversationID, "TERMINAL_VAR", "from-conversation", false); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/conversations/"
+
+	if _, resp, err := websocket.Dial(ctx, wsURL+"missing/terminal", nil); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing conversation, got %v", err)
+	}
+
+	conn, _, err := websocket.Dial(ctx, wsURL+conversation.ConversationID+"/terminal", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial websocket: %v", err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "test done")
+	if err := wsjson.Write(ctx, conn, ExecMessage{Type: "init", Cols: 80, Rows: 24}); err != nil {
+		t.Fatal(err)
+	}
+	input := `echo "env:$TERMINAL_VAR:$SHELLEY_CONVERSATION_ID:$(pwd)"; exit 3` + "\n"
+	if err := wsjson.Write(ctx, conn, ExecMessage{Type: "input", Data: input}); err != nil {
+		t.Fatal(err)
+	}
+
+	var output strings.Builder
+	exitCode := ""
+	for {
+		var msg ExecMessage
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			break
+		}
+		switch msg.Type {
+		case "output":
+			data, _ := base64.StdEncoding.DecodeString(msg.Data)
+			output.Write(data)
+		case "exit":
+			exitCode = msg.Data
+		}
+	}
+	want := "env:from-conversation:" + conversation.ConversationID + ":" + dir
+	if !strings.Contains(output.String(), want) {
+		t.Errorf("expected %q in the terminal output, got %q", want, output.String())
+	}
+	if exitCode != "3" {
+		t.Errorf("expected exit code 3, got %q", exitCode)
+	}
+}
@@ -14,12 +14,23 @@ import (
 	"shelley.exe.dev/gitstate"
 	"shelley.exe.dev/llm"
 	"shelley.exe.dev/llm/llmhttp"
+	"shelley.exe.dev/llm/tokenizer"
 	"shelley.exe.dev/loop"
 	"shelley.exe.dev/subpub"
+	"shelley.exe.dev/wslpath"
 )
 
 var errConversationModelMismatch = errors.New("conversation model mismatch")
 
+var errConversationBusy = errors.New("conversation is busy")
+
+// cancelLoopWaitTimeout bounds how long CancelConversation waits for the loop to exit.
+const cancelLoopWaitTimeout = 5 * time.Second
+
+// toolOutputBudget bounds the text of a tool result sent to the LLM, in
+// estimated tokens. Bash saves outputs over 50KB to a file on its own.
+var toolOutputBudget = 8000
+
 // ConversationManager manages a single active conversation
 type ConversationManager struct {
 	conversationID string
@@ -27,8 +38,12 @@ type ConversationManager struct {
 	loop           *loop.Loop
 	loopCancel     context.CancelFunc
 	loopCtx        context.Context
+	loopDone       chan struct{} // closed when the loop goroutine returns
 	mu             sync.Mutex
+	acceptMu       sync.Mutex // orders concurrently accepted user messages
 	lastActivity   time.Time
+	streams        int            // number of clients streaming the conversation
+	viewers        map[string]int // streams of each user viewing the conversation
 	modelID        string
 	history        []llm.Message
 	system         []llm.SystemContent
@@ -41,11 +56,62 @@ type ConversationManager struct {
 
 	hydrated              bool
 	hasConversationEvents bool
-	cwd                   string // working directory for tools
+	cwd                   string   // working directory for tools
+	env                   []string // extra KEY=VALUE environment for tools
+	secrets               []string // secret env values masked in recorded messages
+	hooks                 []generated.ConversationHook
+	planMode              bool          // whether write-capable tools are disabled until a plan is approved
+	sampling              *llm.Sampling // sampling settings of the conversation; nil for the model's defaults
+	sandbox               string        // sandbox mode of the conversation; empty for the model's default
+	pendingContext        []llm.Content // hook output to send with the next user message
+	localTimeKey          string        // what the last local time note said apart from the time; see ConversationSettings.localTime
+	editedFiles           []string      // files edited since the after_edit hooks last passed
+	editsUnchecked        bool          // whether files were edited since the after_edit hooks last ran
+	limits                loop.Limits   // the server's limits of turns; see settings
+	concurrentReadOnly    bool          // whether read-only tool calls of a response run at once
+	settings              ConversationSettings
+	promptOverrides       map[string]PromptOverride // changes of the system prompt by model ID
 
 	// agentWorking tracks whether the agent is currently working.
 	// This is explicitly managed and broadcast to subscribers when it changes.
 	agentWorking bool
+	turnStarted  time.Time // when the agent last started working
+
+	// turnInFlight is whether the database records the turn as in flight;
+	// inFlightMu orders the writes keeping it in step with agentWorking.
+	inFlightMu   sync.Mutex
+	turnInFlight bool
+
+	// pushOwner is the user who last sent a message, who is notified by
+	// Web Push when turns end.
+	pushOwner string
+
+	// leaseHolder is the instance ID under which the conversation is leased
+	// while its loop runs; empty when leasing is disabled.
+	leaseHolder string
+
+	// scheduler decides when turns start; nil to start them at once.
+	// Subagents run within their parent's turn and don't wait for it.
+	scheduler    *turnScheduler
+	subagent     bool
+	turnPriority turnPriority // of the turn started by the last user message
+
+	// timings collects the latencies of the phases of turns; nil to not
+	// collect them.
+	timings *loopTimings
+
+	// connectivity holds turns while the LLM providers are unreachable; nil
+	// to end them with the error.
+	connectivity *connectivity
+
+	// wsl translates the paths of conversations with windows_paths set;
+	// nil outside WSL.
+	wsl *wslpath.Translator
+
+	// approval is the risky command awaiting the user's approval, if any,
+	// and onApproval is called when one starts waiting.
+	approval   *pendingApproval
+	onApproval func(approval CommandApprovalAPI)
 
 	// onStateChange is called when the conversation state changes.
 	// This allows the server to broadcast state changes to all subscribers.
@@ -80,12 +146,21 @@ func (cm *ConversationManager) SetAgentWorking(working bool) {
 		return
 	}
 	cm.agentWorking = working
+	if working {
+		cm.turnStarted = time.Now()
+	}
 	onStateChange := cm.onStateChange
 	convID := cm.conversationID
 	modelID := cm.modelID
 	cm.mu.Unlock()
 
 	cm.logger.Debug("agent working state changed", "working", working)
+	cm.markTurnInFlight()
+	state := "idle"
+	if working {
+		state = "working"
+	}
+	cm.recordEvent(context.Background(), generated.InsertConversationEventParams{Type: EventTypeState, Name: state})
 	if onStateChange != nil {
 		onStateChange(ConversationState{
 			ConversationID: convID,
@@ -95,6 +170,40 @@ func (cm *ConversationManager) SetAgentWorking(working bool) {
 	}
 }
 
+// markTurnInFlight records a turn as interrupted by a crash while it runs, so
+// that a server which stops without checkpointing it finds it at startup. It
+// writes to the database only when the working state differs from what was
+// recorded, reading the state again so that concurrent changes record the
+// latest one.
+func (cm *ConversationManager) markTurnInFlight() {
+	cm.inFlightMu.Lock()
+	defer cm.inFlightMu.Unlock()
+	working := cm.IsAgentWorking()
+	if working == cm.turnInFlight {
+		return
+	}
+	ctx := context.Background()
+	var err error
+	if working {
+		err = cm.db.SetInterruptedTurn(ctx, cm.conversationID, interruptReasonCrash)
+	} else {
+		err = cm.db.DeleteInterruptedTurn(ctx, cm.conversationID)
+	}
+	if err != nil {
+		cm.logger.Error("Failed to update in-flight turn", "working", working, "error", err)
+		return
+	}
+	cm.turnInFlight = working
+}
+
+// SetPushOwner records the user sending a message, to be notified when the
+// turn ends.
+func (cm *ConversationManager) SetPushOwner(owner string) {
+	cm.mu.Lock()
+	cm.pushOwner = owner
+	cm.mu.Unlock()
+}
+
 // IsAgentWorking returns the current agent working state.
 func (cm *ConversationManager) IsAgentWorking() bool {
 	cm.mu.Lock()
@@ -109,6 +218,19 @@ func (cm *ConversationManager) GetModel() string {
 	return cm.modelID
 }
 
+// TerminalEnvironment returns the working directory of the conversation's
+// tools, if they are running, and the environment they add, for a terminal
+// in the same environment.
+func (cm *ConversationManager) TerminalEnvironment() (cwd string, env []string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.toolSet != nil {
+		cwd = cm.toolSet.WorkingDir().Get()
+	}
+	env = append([]string{"SHELLEY_CONVERSATION_ID=" + cm.conversationID}, cm.env...)
+	return cwd, env
+}
+
 // Hydrate loads conversation state from the database, generating a system prompt if missing.
 func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 	cm.mu.Lock()
@@ -129,7 +251,10 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 		var err error
 		// Use ListMessagesForContext to exclude messages marked as excluded_from_context
 		messages, err = q.ListMessagesForContext(ctx, cm.conversationID)
-		return err
+		if err != nil {
+			return err
+		}
+		return db.LoadMessageBlobs(ctx, q, messages)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to get conversation history: %w", err)
@@ -157,10 +282,10 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 		var err error
 		if conversation.ParentConversationID != nil {
 			// Subagent conversation - use minimal prompt
-			systemMsg, err = cm.createSubagentSystemPrompt(ctx)
+			systemMsg, err = cm.createSubagentSystemPrompt(ctx, modelID)
 		} else if conversation.UserInitiated {
 			// User-initiated conversation - use full prompt
-			systemMsg, err = cm.createSystemPrompt(ctx)
+			systemMsg, err = cm.createSystemPrompt(ctx, modelID)
 		}
 		if err != nil {
 			return err
@@ -172,13 +297,90 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 
 	history, system := cm.partitionMessages(messages)
 
+	dirs, err := cm.db.ListConversationDirectories(ctx, cm.conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation directories: %w", err)
+	}
+	if len(dirs) > 0 {
+		system = append(system, llm.SystemContent{Type: "text", Text: AdditionalDirectoriesPrompt(dirs)})
+	}
+
+	// Apply the project profile covering the working directory
+	var env []string
+	if p, err := cm.db.FindProjectForDirectory(ctx, cwd); err != nil {
+		return fmt.Errorf("failed to find project: %w", err)
+	} else if p != nil {
+		project, err := toProjectAPI(*p)
+		if err != nil {
+			return err
+		}
+		env = envList(project.Env)
+		if project.Instructions != "" {
+			system = append(system, llm.SystemContent{Type: "text", Text: ProjectInstructionsPrompt(project.Directory, project.Instructions)})
+		}
+	}
+
+	if workspace, err := cm.db.FindWorkspaceForDirectory(ctx, cwd); err != nil {
+		return fmt.Errorf("failed to find workspace: %w", err)
+	} else if workspace != nil {
+		system = append(system, llm.SystemContent{Type: "text", Text: RepositoryPrompt(*workspace)})
+	}
+
+	// Conversation variables are appended last so they override the project's
+	vars, err := cm.db.ListConversationEnv(ctx, cm.conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation environment: %w", err)
+	}
+	convEnv, secrets := conversationEnv(vars)
+	env = append(env, convEnv...)
+
+	hooks, err := cm.db.ListConversationHooks(ctx, cm.conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation hooks: %w", err)
+	}
+
+	plan, err := cm.db.GetConversationPlan(ctx, cm.conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation plan: %w", err)
+	}
+	planMode := plan != nil && plan.Mode == db.PlanModePlan
+	if planMode {
+		system = append(system, llm.SystemContent{Type: "text", Text: PlanModePrompt()})
+	}
+
+	settings, err := cm.db.GetConversationSettings(ctx, cm.conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation settings: %w", err)
+	}
+	if settings != nil && settings.ReadOnly {
+		system = append(system, llm.SystemContent{Type: "text", Text: ReadOnlyPrompt()})
+	}
+	if settings != nil && settings.WindowsPaths && cm.wsl != nil {
+		system = append(system, llm.SystemContent{Type: "text", Text: WindowsPathsPrompt(cm.wsl, cm.cwd)})
+	}
+
 	cm.mu.Lock()
 	cm.history = history
 	cm.system = system
+	cm.subagent = conversation.ParentConversationID != nil
 	cm.hasConversationEvents = len(history) > 0
 	cm.lastActivity = time.Now()
 	cm.hydrated = true
 	cm.modelID = modelID
+	cm.env = env
+	cm.secrets = secrets
+	cm.hooks = hooks
+	cm.planMode = planMode
+	cm.settings = toConversationSettings(settings)
+	cm.sampling = cm.settings.sampling()
+	cm.sandbox = ""
+	if settings != nil && settings.Sandbox != nil {
+		cm.sandbox = *settings.Sandbox
+	}
+	if cm.settings.ReadOnly {
+		// Backends that run tools themselves are held to read-only too
+		cm.sandbox = llm.SandboxReadOnly
+	}
 	cm.mu.Unlock()
 
 	if modelID != "" {
@@ -197,6 +399,9 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 		return false, fmt.Errorf("llm service is required")
 	}
 
+	if err := cm.acquireLease(ctx); err != nil {
+		return false, err
+	}
 	if err := cm.Hydrate(ctx); err != nil {
 		return false, err
 	}
@@ -205,12 +410,31 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 		return false, err
 	}
 
+	message = attachDocuments(message)
+
+	// Messages sent at once by several users are recorded and sent to the
+	// LLM in the same order
+	cm.acceptMu.Lock()
+	defer cm.acceptMu.Unlock()
+
 	cm.mu.Lock()
 	isFirst := !cm.hasConversationEvents
 	cm.hasConversationEvents = true
+	cm.turnPriority = turnPriorityFrom(ctx)
 	loopInstance := cm.loop
 	cm.lastActivity = time.Now()
 	recordMessage := cm.recordMessage
+	if len(cm.pendingContext) > 0 {
+		message.Content = append(append([]llm.Content(nil), message.Content...), cm.pendingContext...)
+		cm.pendingContext = nil
+	}
+	// The user's local time is told when the date, time zone or locale changes
+	if note, key := cm.settings.localTime(time.Now()); key != cm.localTimeKey {
+		if note != "" {
+			message.Content = append(append([]llm.Content(nil), message.Content...), llm.Content{Type: llm.ContentTypeText, Text: note})
+		}
+		cm.localTimeKey = key
+	}
 	cm.mu.Unlock()
 
 	if loopInstance == nil {
@@ -241,6 +465,45 @@ func (cm *ConversationManager) Touch() {
 	cm.mu.Unlock()
 }
 
+// addStream records a client of user streaming the conversation; the
+// returned function removes it. Viewers are told when users come and go.
+func (cm *ConversationManager) addStream(user string) func() {
+	cm.mu.Lock()
+	cm.streams++
+	if cm.viewers == nil {
+		cm.viewers = make(map[string]int)
+	}
+	cm.viewers[user]++
+	joined := cm.viewers[user] == 1
+	cm.mu.Unlock()
+	if joined && user != "" {
+		cm.broadcastPresence()
+	}
+	return func() {
+		cm.mu.Lock()
+		cm.streams--
+		cm.lastActivity = time.Now()
+		cm.viewers[user]--
+		left := cm.viewers[user] == 0
+		if left {
+			delete(cm.viewers, user)
+		}
+		cm.mu.Unlock()
+		if left && user != "" {
+			cm.broadcastPresence()
+		}
+	}
+}
+
+// idleState reports whether the conversation has had no activity for longer
+// than ttl, whether its loop is running, and whether a client is streaming it.
+func (cm *ConversationManager) idleState(ttl time.Duration) (idle, running, streamed bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	idle = !cm.agentWorking && time.Since(cm.lastActivity) > ttl
+	return idle, cm.loop != nil, cm.streams > 0
+}
+
 func hasSystemMessage(messages []generated.Message) bool {
 	for _, msg := range messages {
 		if msg.Type == string(db.MessageTypeSystem) {
@@ -250,17 +513,37 @@ func hasSystemMessage(messages []generated.Message) bool {
 	return false
 }
 
-func (cm *ConversationManager) createSystemPrompt(ctx context.Context) (*generated.Message, error) {
-	systemPrompt, err := GenerateSystemPrompt(cm.cwd)
+func (cm *ConversationManager) createSystemPrompt(ctx context.Context, modelID string) (*generated.Message, error) {
+	customTemplate, err := cm.systemPromptTemplate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system prompt template: %w", err)
+	}
+	systemPrompt, err := GenerateSystemPromptForModel(cm.cwd, customTemplate, modelID)
+	if err != nil && customTemplate != "" {
+		// A broken custom template should not make the conversation unusable
+		cm.logger.Warn("Custom system prompt template failed, using built-in template", "error", err)
+		systemPrompt, err = GenerateSystemPromptForModel(cm.cwd, "", modelID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate system prompt: %w", err)
 	}
+	if override, ok := cm.promptOverrides[modelID]; ok {
+		systemPrompt = override.Apply(systemPrompt)
+	}
 
 	if systemPrompt == "" {
 		cm.logger.Info("Skipping empty system prompt generation")
 		return nil, nil
 	}
 
+	memories, err := cm.db.ListMemoriesForDirectory(ctx, cm.cwd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memories: %w", err)
+	}
+	if len(memories) > 0 {
+		systemPrompt += "\n\n" + MemoriesPrompt(memories)
+	}
+
 	systemMessage := llm.Message{
 		Role:    llm.MessageRoleUser,
 		Content: []llm.Content{{Type: llm.ContentTypeText, Text: systemPrompt}},
@@ -286,11 +569,14 @@ func (cm *ConversationManager) createSystemPrompt(ctx context.Context) (*generat
 	return created, nil
 }
 
-func (cm *ConversationManager) createSubagentSystemPrompt(ctx context.Context) (*generated.Message, error) {
+func (cm *ConversationManager) createSubagentSystemPrompt(ctx context.Context, modelID string) (*generated.Message, error) {
 	systemPrompt, err := GenerateSubagentSystemPrompt(cm.cwd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate subagent system prompt: %w", err)
 	}
+	if override, ok := cm.promptOverrides[modelID]; ok {
+		systemPrompt = override.Apply(systemPrompt)
+	}
 
 	if systemPrompt == "" {
 		cm.logger.Info("Skipping empty subagent system prompt generation")
@@ -382,27 +668,91 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	recordMessage := cm.recordMessage
 	logger := cm.logger
 	cwd := cm.cwd
+	env := cm.env
+	secrets := cm.secrets
+	hooks := cm.hooks
+	planMode := cm.planMode
+	sampling := cm.sampling
+	responseFormat := cm.settings.responseFormat()
+	sandbox := cm.sandbox
+	readOnly := cm.settings.ReadOnly
+	approveRisky := cm.settings.ApproveRiskyCommands
+	var wsl *wslpath.Translator
+	if cm.settings.WindowsPaths {
+		wsl = cm.wsl
+	}
+	limits := cm.settings.limits(cm.limits)
+	location := cm.settings.location()
 	toolSetConfig := cm.toolSetConfig
 	conversationID := cm.conversationID
 	db := cm.db
 	cm.mu.Unlock()
 
+	if len(secrets) > 0 {
+		record := recordMessage
+		recordMessage = func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			return record(ctx, maskSecrets(message, secrets), usage)
+		}
+	}
+
 	// Create tools for this conversation with the conversation's working directory
 	toolSetConfig.WorkingDir = cwd
+	toolSetConfig.Env = env
 	toolSetConfig.ModelID = modelID
 	toolSetConfig.ConversationID = conversationID
 	toolSetConfig.ParentConversationID = conversationID // For subagent tool
+	toolSetConfig.PlanMode = planMode
+	toolSetConfig.ReadOnly = readOnly
+	if approveRisky {
+		toolSetConfig.ApproveCommand = cm.approveCommand
+	}
+	if wsl != nil {
+		toolSetConfig.TranslatePath = wsl.ToLinux
+	}
+	toolSetConfig.Location = location
 	toolSetConfig.OnWorkingDirChange = func(newDir string) {
 		// Persist working directory change to database
 		if err := db.UpdateConversationCwd(context.Background(), conversationID, newDir); err != nil {
 			logger.Error("failed to persist working directory change", "error", err, "newDir", newDir)
 		}
+		// Backends that keep their own session per project start a new one
+		if sr, ok := service.(llm.SessionResetter); ok {
+			if err := sr.ResetSession(context.Background(), conversationID); err != nil && !errors.Is(err, llm.ErrSessionsUnsupported) {
+				logger.Warn("failed to reset backend session after working directory change", "error", err, "newDir", newDir)
+			}
+		}
+	}
+
+	httpTools, err := loadHTTPTools(context.Background(), db)
+	if err != nil {
+		return fmt.Errorf("failed to load HTTP tools: %w", err)
 	}
+	toolSetConfig.HTTPTools = httpTools
 
 	// Create a context with the conversation ID for LLM request recording/prefix dedup
 	baseCtx := llmhttp.WithConversationID(context.Background(), conversationID)
+	baseCtx = llmhttp.WithPriority(baseCtx, cm.llmPriority)
+	if sandbox != "" {
+		baseCtx = llmhttp.WithSandbox(baseCtx, sandbox)
+	}
 	processCtx, cancel := context.WithTimeout(baseCtx, 12*time.Hour)
 	toolSet := claudetool.NewToolSet(processCtx, toolSetConfig)
+	loopDone := make(chan struct{})
+
+	var beforeTool loop.BeforeToolFunc
+	if hasHooks(hooks, HookEventBeforeTool) {
+		beforeTool = cm.beforeToolHook(toolSet.WorkingDir().Get)
+	}
+	var onTurnEnd loop.TurnEndFunc
+	if hasHooks(hooks, HookEventAfterTurn) {
+		onTurnEnd = cm.afterTurnHook(toolSet.WorkingDir().Get)
+	}
+	var checkTools loop.ToolCheckFunc
+	if hasHooks(hooks, HookEventAfterEdit) {
+		beforeTool = cm.trackEdits(beforeTool, toolSet.WorkingDir().Get)
+		checkTools = cm.afterEditHook(toolSet.WorkingDir().Get)
+	}
+	beforeTool = cm.auditToolCalls(beforeTool)
 
 	loopInstance := loop.NewLoop(loop.Config{
 		LLM:           service,
@@ -416,6 +766,27 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 		OnGitStateChange: func(ctx context.Context, state *gitstate.GitState) {
 			cm.recordGitStateChange(ctx, state)
 		},
+		BeforeTool:     beforeTool,
+		OnTurnEnd:      onTurnEnd,
+		CheckTools:     checkTools,
+		OnEvent:        cm.recordLoopEvent,
+		WaitTurn:       cm.waitTurn(),
+		WaitOnline:     cm.waitOnline(),
+		Sampling:       sampling,
+		Limits:         limits,
+		ResponseFormat: responseFormat,
+		// The full output of truncated tool results is kept for the
+		// tool_output tool and the API
+		ToolOutputBudget: toolOutputBudget,
+		Tokenizer:        tokenizer.ForModel(modelID),
+		SaveToolOutput: func(ctx context.Context, toolUseID, output string) error {
+			return db.SaveToolOutput(ctx, conversationID, toolUseID, maskString(output, secrets))
+		},
+		SaveImage: saveGeneratedImage,
+		// Reading an unchanged file again costs a note rather than its content
+		DedupToolOutputs: true,
+		// Reads, searches and the like made in one response run at once
+		ConcurrentReadOnlyTools: cm.concurrentReadOnly,
 	})
 
 	cm.mu.Lock()
@@ -434,6 +805,7 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	cm.loop = loopInstance
 	cm.loopCancel = cancel
 	cm.loopCtx = processCtx
+	cm.loopDone = loopDone
 	cm.modelID = modelID
 	cm.toolSet = toolSet
 	cm.history = nil
@@ -447,7 +819,11 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 		}
 	}
 
+	if cm.leaseHolder != "" {
+		go cm.renewLease(processCtx)
+	}
 	go func() {
+		defer close(loopDone)
 		if err := loopInstance.Go(processCtx); err != nil && err != context.DeadlineExceeded && err != context.Canceled {
 			if logger != nil {
 				logger.Error("Conversation loop stopped", "error", err)
@@ -466,6 +842,7 @@ func (cm *ConversationManager) stopLoop() {
 	toolSet := cm.toolSet
 	cm.loopCancel = nil
 	cm.loopCtx = nil
+	cm.loopDone = nil
 	cm.loop = nil
 	cm.modelID = ""
 	cm.toolSet = nil
@@ -473,39 +850,17 @@ func (cm *ConversationManager) stopLoop() {
 
 	if cancel != nil {
 		cancel()
+		cm.releaseLease()
 	}
 	if toolSet != nil {
 		toolSet.Cleanup()
 	}
 }
 
-// CancelConversation cancels the current conversation loop and records a cancelled tool result if a tool was in progress
-func (cm *ConversationManager) CancelConversation(ctx context.Context) error {
-	cm.mu.Lock()
-	loopInstance := cm.loop
-	loopCtx := cm.loopCtx
-	cancel := cm.loopCancel
-	cm.mu.Unlock()
-
-	if loopInstance == nil {
-		cm.logger.Info("No active loop to cancel")
-		return nil
-	}
-
-	cm.logger.Info("Cancelling conversation")
-
-	// Check if there's an in-progress tool call by examining the history
-	history := loopInstance.GetHistory()
-	var inProgressToolID string
-	var inProgressToolName string
-
-	// Find tool_uses that don't have corresponding tool_results.
-	// Strategy:
-	// 1. Find the last assistant message that contains tool_uses
-	// 2. Collect all tool_result IDs from user messages AFTER that assistant message
-	// 3. Find tool_uses that don't have matching results
-
-	// Step 1: Find the index of the last assistant message with tool_uses
+// unfinishedToolUses returns the tool calls of the last assistant message that
+// made any which have no tool result yet.
+func unfinishedToolUses(history []llm.Message) []llm.Content {
+	// Find the index of the last assistant message with tool_uses
 	lastToolUseAssistantIdx := -1
 	for i := len(history) - 1; i >= 0; i-- {
 		msg := history[i]
@@ -523,46 +878,74 @@ func (cm *ConversationManager) CancelConversation(ctx context.Context) error {
 			}
 		}
 	}
+	if lastToolUseAssistantIdx < 0 {
+		return nil
+	}
 
-	if lastToolUseAssistantIdx >= 0 {
-		// Step 2: Collect all tool_result IDs from messages after the assistant message
-		toolResultIDs := make(map[string]bool)
-		for i := lastToolUseAssistantIdx + 1; i < len(history); i++ {
-			msg := history[i]
-			if msg.Role == llm.MessageRoleUser {
-				for _, content := range msg.Content {
-					if content.Type == llm.ContentTypeToolResult {
-						toolResultIDs[content.ToolUseID] = true
-					}
+	// Collect all tool_result IDs from messages after the assistant message
+	toolResultIDs := make(map[string]bool)
+	for i := lastToolUseAssistantIdx + 1; i < len(history); i++ {
+		msg := history[i]
+		if msg.Role == llm.MessageRoleUser {
+			for _, content := range msg.Content {
+				if content.Type == llm.ContentTypeToolResult {
+					toolResultIDs[content.ToolUseID] = true
 				}
 			}
 		}
+	}
 
-		// Step 3: Find the first tool_use that doesn't have a result
-		assistantMsg := history[lastToolUseAssistantIdx]
-		for _, content := range assistantMsg.Content {
-			if content.Type == llm.ContentTypeToolUse {
-				if !toolResultIDs[content.ID] {
-					inProgressToolID = content.ID
-					inProgressToolName = content.ToolName
-					break
-				}
-			}
+	// Find the tool_uses that don't have a result
+	var pending []llm.Content
+	for _, content := range history[lastToolUseAssistantIdx].Content {
+		if content.Type == llm.ContentTypeToolUse && !toolResultIDs[content.ID] {
+			pending = append(pending, content)
 		}
 	}
+	return pending
+}
+
+// CancelConversation cancels the current conversation loop and records a cancelled tool result if a tool was in progress
+func (cm *ConversationManager) CancelConversation(ctx context.Context) error {
+	cm.mu.Lock()
+	loopInstance := cm.loop
+	loopDone := cm.loopDone
+	cancel := cm.loopCancel
+	toolSet := cm.toolSet
+	cm.mu.Unlock()
+
+	if loopInstance == nil {
+		cm.logger.Info("No active loop to cancel")
+		return nil
+	}
+
+	cm.logger.Info("Cancelling conversation")
+
+	// Check if there's an in-progress tool call by examining the history
+	var inProgressToolID string
+	var inProgressToolName string
+	if pending := unfinishedToolUses(loopInstance.GetHistory()); len(pending) > 0 {
+		inProgressToolID = pending[0].ID
+		inProgressToolName = pending[0].ToolName
+	}
 
 	// Cancel the context
 	if cancel != nil {
 		cancel()
 	}
 
-	// Wait briefly for the loop to stop
-	if loopCtx != nil {
+	// Wait for the loop goroutine to exit so that the in-flight LLM request
+	// and any running tool processes are gone before we record the cancellation.
+	if loopDone != nil {
 		select {
-		case <-loopCtx.Done():
-		case <-time.After(100 * time.Millisecond):
+		case <-loopDone:
+		case <-time.After(cancelLoopWaitTimeout):
+			cm.logger.Warn("Loop did not exit after cancel", "timeout", cancelLoopWaitTimeout)
 		}
 	}
+	if toolSet != nil {
+		toolSet.Cleanup()
+	}
 
 	// Record cancellation messages
 	if inProgressToolID != "" {
@@ -608,8 +991,10 @@ func (cm *ConversationManager) CancelConversation(ctx context.Context) error {
 	cm.mu.Lock()
 	cm.loopCancel = nil
 	cm.loopCtx = nil
+	cm.loopDone = nil
 	cm.loop = nil
 	cm.modelID = ""
+	cm.toolSet = nil
 	// Reset hydrated so that the next AcceptUserMessage will reload history from the database
 	cm.hydrated = false
 	cm.mu.Unlock()
@@ -617,6 +1002,237 @@ func (cm *ConversationManager) CancelConversation(ctx context.Context) error {
 	return nil
 }
 
+const (
+	// interruptReasonShutdown marks turns checkpointed by a server shutdown.
+	interruptReasonShutdown = "shutdown"
+	// interruptReasonCrash marks turns in flight; the record is removed when
+	// the turn ends, so one found at startup means the server crashed.
+	interruptReasonCrash = "crash"
+)
+
+// Checkpoint stops a turn in flight so that it can be resumed later: the loop
+// and its tool processes are stopped, tool calls that did not finish get an
+// error result, and the turn is recorded as interrupted. Messages the loop
+// recorded before it stopped, such as partial assistant output, are kept.
+// It reports whether a turn was in flight.
+func (cm *ConversationManager) Checkpoint(ctx context.Context, reason string) (bool, error) {
+	cm.mu.Lock()
+	working := cm.agentWorking && cm.loop != nil
+	loopDone := cm.loopDone
+	cancel := cm.loopCancel
+	toolSet := cm.toolSet
+	cm.loopCancel = nil
+	cm.loopCtx = nil
+	cm.loopDone = nil
+	cm.loop = nil
+	cm.modelID = ""
+	cm.toolSet = nil
+	cm.hydrated = false
+	cm.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	if working && loopDone != nil {
+		select {
+		case <-loopDone:
+		case <-time.After(cancelLoopWaitTimeout):
+			cm.logger.Warn("Loop did not exit after cancel", "timeout", cancelLoopWaitTimeout)
+		}
+	}
+	if toolSet != nil {
+		toolSet.Cleanup()
+	}
+	if !working {
+		return false, nil
+	}
+
+	cm.logger.Info("Checkpointing interrupted turn", "reason", reason)
+
+	if err := cm.recordInterruptedToolResults(ctx, reason); err != nil {
+		return true, err
+	}
+	if err := cm.db.SetInterruptedTurn(ctx, cm.conversationID, reason); err != nil {
+		return true, fmt.Errorf("failed to record interrupted turn: %w", err)
+	}
+	return true, nil
+}
+
+// recordInterruptedToolResults records an error result for the tool calls
+// that have none. The loop's history may hold messages it could not record
+// once cancelled, so this looks at what was persisted.
+func (cm *ConversationManager) recordInterruptedToolResults(ctx context.Context, reason string) error {
+	var messages []generated.Message
+	err := cm.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessagesForContext(ctx, cm.conversationID)
+		if err != nil {
+			return err
+		}
+		return db.LoadMessageBlobs(ctx, q, messages)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get conversation history: %w", err)
+	}
+	history, _ := cm.partitionMessages(messages)
+	pending := unfinishedToolUses(history)
+	if len(pending) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	results := make([]llm.Content, 0, len(pending))
+	for _, toolUse := range pending {
+		results = append(results, llm.Content{
+			Type:             llm.ContentTypeToolResult,
+			ToolUseID:        toolUse.ID,
+			ToolError:        true,
+			ToolResult:       []llm.Content{{Type: llm.ContentTypeText, Text: fmt.Sprintf("Tool execution interrupted (%s); it can be retried", reason)}},
+			ToolUseStartTime: &now,
+			ToolUseEndTime:   &now,
+		})
+	}
+	interruptedMessage := llm.Message{Role: llm.MessageRoleUser, Content: results}
+	if err := cm.recordMessage(ctx, interruptedMessage, llm.Usage{}); err != nil {
+		return fmt.Errorf("failed to record interrupted tool results: %w", err)
+	}
+	return nil
+}
+
+// FailInterruptedTurn ends a turn that cannot be resumed, recording an error
+// result for its unfinished tool calls and an error message telling the user
+// how to continue.
+func (cm *ConversationManager) FailInterruptedTurn(ctx context.Context, reason, text string) error {
+	if err := cm.recordInterruptedToolResults(ctx, reason); err != nil {
+		return err
+	}
+	errorMessage := llm.Message{
+		Role:      llm.MessageRoleAssistant,
+		Content:   []llm.Content{{Type: llm.ContentTypeText, Text: text}},
+		EndOfTurn: true,
+		ErrorType: llm.ErrorTypeInterrupted,
+	}
+	if err := cm.recordMessage(ctx, errorMessage, llm.Usage{}); err != nil {
+		return fmt.Errorf("failed to record interrupted turn error: %w", err)
+	}
+	cm.SetAgentWorking(false)
+	return nil
+}
+
+// ResumeTurn continues a turn stopped by Checkpoint by sending the history to
+// the LLM again. It reports whether there was a turn to resume: nothing is
+// resumed if the loop is already running or the assistant had the last word.
+func (cm *ConversationManager) ResumeTurn(ctx context.Context, service llm.Service, modelID string) (bool, error) {
+	if service == nil {
+		return false, fmt.Errorf("llm service is required")
+	}
+
+	if err := cm.acquireLease(ctx); err != nil {
+		return false, err
+	}
+	if err := cm.Hydrate(ctx); err != nil {
+		return false, err
+	}
+
+	cm.mu.Lock()
+	running := cm.loop != nil
+	history := cm.history
+	cm.mu.Unlock()
+	if running || len(history) == 0 || history[len(history)-1].Role != llm.MessageRoleUser {
+		return false, nil
+	}
+
+	if err := cm.ensureLoop(service, modelID); err != nil {
+		return false, err
+	}
+
+	cm.mu.Lock()
+	loopInstance := cm.loop
+	cm.hasConversationEvents = true
+	cm.lastActivity = time.Now()
+	cm.mu.Unlock()
+
+	cm.logger.Info("Resuming interrupted turn")
+	loopInstance.ResumeTurn()
+	cm.SetAgentWorking(true)
+	return true, nil
+}
+
+// ResumeStuckTurn continues a turn the loop paused as stuck, sending the
+// pending tool results to the LLM. Guidance, if any, is sent with them as a
+// user message.
+func (cm *ConversationManager) ResumeStuckTurn(ctx context.Context, service llm.Service, modelID, guidance string) error {
+	if cm.IsAgentWorking() {
+		return errConversationBusy
+	}
+	if guidance != "" {
+		_, err := cm.AcceptUserMessage(ctx, service, modelID, llm.Message{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: guidance}},
+		})
+		return err
+	}
+
+	cm.mu.Lock()
+	loopInstance := cm.loop
+	cm.lastActivity = time.Now()
+	cm.mu.Unlock()
+	if loopInstance == nil {
+		// The loop was released while the turn was paused
+		_, err := cm.ResumeTurn(ctx, service, modelID)
+		return err
+	}
+	cm.logger.Info("Resuming stuck turn")
+	loopInstance.ResumeTurn()
+	cm.SetAgentWorking(true)
+	return nil
+}
+
+// TruncateHistory removes the messages from fromSequenceID onwards, retaining
+// them for undo, and stops the loop so the next user message starts from the
+// shortened history.
+func (cm *ConversationManager) TruncateHistory(ctx context.Context, fromSequenceID int64) error {
+	if cm.IsAgentWorking() {
+		return errConversationBusy
+	}
+	cm.stopLoop()
+	if _, err := cm.db.TruncateMessages(ctx, cm.conversationID, fromSequenceID); err != nil {
+		return fmt.Errorf("failed to truncate messages: %w", err)
+	}
+	cm.mu.Lock()
+	cm.hydrated = false
+	cm.mu.Unlock()
+	return nil
+}
+
+// ReloadContext stops the loop and drops the cached history so that the next
+// user message rebuilds the LLM context from the database.
+func (cm *ConversationManager) ReloadContext() error {
+	if cm.IsAgentWorking() {
+		return errConversationBusy
+	}
+	cm.stopLoop()
+	cm.mu.Lock()
+	cm.hydrated = false
+	cm.mu.Unlock()
+	return nil
+}
+
+// RestoreTruncatedHistory undoes the most recent TruncateHistory.
+func (cm *ConversationManager) RestoreTruncatedHistory(ctx context.Context) error {
+	if cm.IsAgentWorking() {
+		return errConversationBusy
+	}
+	cm.stopLoop()
+	if _, err := cm.db.RestoreTruncatedMessages(ctx, cm.conversationID); err != nil {
+		return err
+	}
+	cm.mu.Lock()
+	cm.hydrated = false
+	cm.mu.Unlock()
+	return nil
+}
+
 // GitInfoUserData is the structured data stored in user_data for gitinfo messages.
 type GitInfoUserData struct {
 	Worktree string `json:"worktree"`
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -588,3 +589,49 @@ func TestSystemPromptUsesCwdFromConversation(t *testing.T) {
 		t.Errorf("system prompt should reference the cwd directory: %s", tmpDir)
 	}
 }
+
+// TestListDirectoryPaths tests that the directory picker gets paths with
+// slashes on every platform, and that drive roots have no parent.
+func TestListDirectoryPaths(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	list := func(path string) ListDirectoryResponse {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/list-directory?path="+url.QueryEscape(path), nil)
+		w := httptest.NewRecorder()
+		h.server.handleListDirectory(w, req)
+		var resp ListDirectoryResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		return resp
+	}
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	resp := list(filepath.ToSlash(dir))
+	if resp.Path != filepath.ToSlash(dir) || resp.Parent != filepath.ToSlash(filepath.Dir(dir)) {
+		t.Errorf("got path %q and parent %q for %s", resp.Path, resp.Parent, dir)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0].Name != "sub" {
+		t.Errorf("expected entry sub, got %+v", resp.Entries)
+	}
+	// The picker appends names to the path with a slash
+	if resp := list(resp.Path + "/sub/"); resp.Parent != filepath.ToSlash(dir) {
+		t.Errorf("expected parent %q of sub, got %q", filepath.ToSlash(dir), resp.Parent)
+	}
+
+	volume := filepath.VolumeName(dir)
+	root := list(volume + "/")
+	if root.Path != volume+"/" || root.Parent != "" {
+		t.Errorf("expected root %q without a parent, got path %q and parent %q", volume+"/", root.Path, root.Parent)
+	}
+	if volume != "" {
+		if resp := list(volume); resp.Path != volume+"/" {
+			t.Errorf("expected %s to list its root, got %q", volume, resp.Path)
+		}
+	}
+}
@@ -485,3 +485,9 @@ loadRequests();
 </body>
 </html>
 `
+
+// handleDebugDB returns the database pool's transaction and SQLITE_BUSY counters
+func (s *Server) handleDebugDB(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.db.Pool().Stats())
+}
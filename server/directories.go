@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// recentDirectoriesShown is how many recently used directories the picker offers.
+const recentDirectoriesShown = 10
+
+// DirectoryAPI is a recently used or favorite working directory
+type DirectoryAPI struct {
+	Path       string     `json:"path"`
+	Favorite   bool       `json:"favorite"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// Exists is false for favorites that were removed or renamed since
+	Exists bool `json:"exists"`
+}
+
+// RecentDirectoriesResponse is the response from /api/directories/recent
+type RecentDirectoriesResponse struct {
+	Recent    []DirectoryAPI `json:"recent"`
+	Favorites []DirectoryAPI `json:"favorites"`
+}
+
+// FavoriteDirectoryRequest is the request body for pinning or unpinning a directory
+type FavoriteDirectoryRequest struct {
+	Path     string `json:"path"`
+	Favorite bool   `json:"favorite"`
+}
+
+func toDirectoryAPI(d generated.UserDirectory) DirectoryAPI {
+	info, err := os.Stat(d.Path)
+	return DirectoryAPI{
+		Path:       d.Path,
+		Favorite:   d.Favorite,
+		LastUsedAt: d.LastUsedAt,
+		Exists:     err == nil && info.IsDir(),
+	}
+}
+
+// recordDirectoryUse adds a conversation's working directory to the user's
+// recent directories.
+func (s *Server) recordDirectoryUse(r *http.Request, cwd string) {
+	if cwd == "" {
+		return
+	}
+	if err := s.db.TouchUserDirectory(r.Context(), s.requestUser(r), filepath.Clean(cwd)); err != nil {
+		s.logger.Warn("Failed to record recent directory", "cwd", cwd, "error", err)
+	}
+}
+
+// handleRecentDirectories handles GET /api/directories/recent: the user's
+// recently used directories, newest first and leaving out those that no
+// longer exist, and their favorites.
+func (s *Server) handleRecentDirectories(w http.ResponseWriter, r *http.Request) {
+	s.writeRecentDirectories(w, r)
+}
+
+// handleFavoriteDirectory handles PUT /api/directories/favorites, which pins
+// or unpins a directory, and responds like /api/directories/recent.
+func (s *Server) handleFavoriteDirectory(w http.ResponseWriter, r *http.Request) {
+	var req FavoriteDirectoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Path = s.hostPath(req.Path)
+	if !filepath.IsAbs(req.Path) {
+		http.Error(w, "path must be absolute", http.StatusBadRequest)
+		return
+	}
+	path := filepath.Clean(req.Path)
+	if req.Favorite {
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			http.Error(w, "path is not a directory", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.db.SetUserDirectoryFavorite(r.Context(), s.requestUser(r), path, req.Favorite); err != nil {
+		s.logger.Error("Failed to save favorite directory", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.writeRecentDirectories(w, r)
+}
+
+func (s *Server) writeRecentDirectories(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := s.requestUser(r)
+
+	// Ask for extra recent directories in case some no longer exist
+	recent, err := s.db.ListRecentUserDirectories(ctx, owner, 2*recentDirectoriesShown)
+	if err != nil {
+		s.logger.Error("Failed to list recent directories", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	favorites, err := s.db.ListFavoriteUserDirectories(ctx, owner)
+	if err != nil {
+		s.logger.Error("Failed to list favorite directories", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := RecentDirectoriesResponse{Recent: []DirectoryAPI{}, Favorites: []DirectoryAPI{}}
+	for _, d := range recent {
+		if dir := toDirectoryAPI(d); dir.Exists && len(resp.Recent) < recentDirectoriesShown {
+			dir.Path = s.clientPath(r, dir.Path)
+			resp.Recent = append(resp.Recent, dir)
+		}
+	}
+	for _, d := range favorites {
+		dir := toDirectoryAPI(d)
+		dir.Path = s.clientPath(r, dir.Path)
+		resp.Favorites = append(resp.Favorites, dir)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
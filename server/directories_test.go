@@ -0,0 +1,170 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConversationDirectories(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	extra := t.TempDir()
+	other := t.TempDir()
+
+	chatBody, _ := json.Marshal(ChatRequest{Message: "echo: hi", Model: "predictable", Directories: []string{extra}})
+	w := httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", bytes.NewReader(chatBody)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+	h.WaitResponse()
+	h.waitIdle()
+
+	// systemText returns the system prompt sent with the most recent LLM request
+	systemText := func() string {
+		t.Helper()
+		return h.systemPrompt()
+	}
+	// chat sends a message so that a fresh request reaches the LLM
+	chat := func() {
+		t.Helper()
+		h.Chat("echo: again")
+		h.WaitResponse()
+		h.waitIdle()
+	}
+	if !strings.Contains(systemText(), extra) {
+		t.Errorf("expected system prompt to list %s", extra)
+	}
+
+	list := func(w *httptest.ResponseRecorder) []string {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp map[string][]string
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp["directories"]
+	}
+
+	dirs := list(h.post("/directories", DirectoryRequest{Path: other}))
+	if len(dirs) != 2 || dirs[1] != other {
+		t.Errorf("expected %s to be added, got %v", other, dirs)
+	}
+	chat()
+	if !strings.Contains(systemText(), other) {
+		t.Errorf("expected system prompt to list %s after adding it", other)
+	}
+
+	dirs = list(h.post("/directories/remove", DirectoryRequest{Path: extra}))
+	if len(dirs) != 1 || dirs[0] != other {
+		t.Errorf("expected only %s to remain, got %v", other, dirs)
+	}
+	chat()
+	if strings.Contains(systemText(), extra) {
+		t.Errorf("expected %s to be gone from the system prompt", extra)
+	}
+
+	if w := h.post("/directories", DirectoryRequest{Path: "relative/path"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected relative path to be rejected, got %d", w.Code)
+	}
+}
+
+func TestRecentDirectories(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.requireHeader = "X-Exedev-Userid"
+	defer func() { h.server.requireHeader = "" }()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	request := func(user, method, path string, body any) RecentDirectoriesResponse {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		r := httptest.NewRequest(method, path, bytes.NewReader(data))
+		r.Header.Set("X-Exedev-Userid", user)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body.String())
+		}
+		var resp RecentDirectoriesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	paths := func(dirs []DirectoryAPI) []string {
+		var result []string
+		for _, d := range dirs {
+			result = append(result, d.Path)
+		}
+		return result
+	}
+
+	root := t.TempDir()
+	project, gone := filepath.Join(root, "project"), filepath.Join(root, "gone")
+	os.Mkdir(project, 0o755)
+	os.Mkdir(gone, 0o755)
+
+	// Starting a conversation records its directory for the user
+	data, _ := json.Marshal(ChatRequest{Message: "echo: hi", Model: "predictable", Cwd: project + "/"})
+	r := httptest.NewRequest("POST", "/api/conversations/new", bytes.NewReader(data))
+	r.Header.Set("X-Exedev-Userid", "alice")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("new conversation: status %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	h.convID = created.ConversationID
+	defer h.waitIdle()
+	if err := h.db.TouchUserDirectory(context.Background(), "alice", gone); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(gone)
+
+	resp := request("alice", "GET", "/api/directories/recent", nil)
+	if got := paths(resp.Recent); len(got) != 1 || got[0] != project {
+		t.Errorf("recent = %v, want just %s", got, project)
+	}
+	if len(request("bob", "GET", "/api/directories/recent", nil).Recent) != 0 {
+		t.Error("expected no recent directories for another user")
+	}
+
+	resp = request("alice", "PUT", "/api/directories/favorites", FavoriteDirectoryRequest{Path: root, Favorite: true})
+	if len(resp.Favorites) != 1 || resp.Favorites[0].Path != root || !resp.Favorites[0].Exists {
+		t.Errorf("favorites = %+v, want %s", resp.Favorites, root)
+	}
+	resp = request("alice", "PUT", "/api/directories/favorites", FavoriteDirectoryRequest{Path: root, Favorite: false})
+	if len(resp.Favorites) != 0 {
+		t.Errorf("favorites = %+v after unpinning, want none", resp.Favorites)
+	}
+
+	for _, body := range []FavoriteDirectoryRequest{{Path: "relative", Favorite: true}, {Path: gone, Favorite: true}} {
+		data, _ := json.Marshal(body)
+		r := httptest.NewRequest("PUT", "/api/directories/favorites", bytes.NewReader(data))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("favorite %q: expected 400, got %d", body.Path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/models"
+	"shelley.exe.dev/ui"
+)
+
+// Statuses of a self-check
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// Free disk space next to the database below which the disk check warns or fails
+const (
+	diskWarnBytes = 1 << 30
+	diskFailBytes = 100 << 20
+)
+
+// doctorTimeout bounds the checks of GET /api/health/full
+const doctorTimeout = 30 * time.Second
+
+// providerEnvVars are the environment variables holding each provider's credential
+var providerEnvVars = map[models.Provider]string{
+	models.ProviderAnthropic:  "ANTHROPIC_API_KEY",
+	models.ProviderOpenAI:     "OPENAI_API_KEY",
+	models.ProviderGemini:     "GEMINI_API_KEY",
+	models.ProviderFireworks:  "FIREWORKS_API_KEY",
+	models.ProviderClaudeCode: "CLAUDE_CODE_BRIDGE_URL",
+}
+
+// DoctorCheck is the result of one self-check, with how to fix a problem
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// DoctorReport is the result of all self-checks, from shelley doctor and GET /api/health/full
+type DoctorReport struct {
+	// Status is the worst status of the checks
+	Status string        `json:"status"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+// DoctorConfig is what the self-checks look at
+type DoctorConfig struct {
+	DB     *db.DB
+	DBPath string
+	// Providers checks the LLM providers and the Claude Code bridge; nil skips them
+	Providers ProviderHealthChecker
+	// Models are the available models, used when no provider is configured
+	Models []string
+	// Assets are the embedded UI assets, ui.Dist if nil
+	Assets fs.FS
+}
+
+// RunDoctor checks that Shelley can run: the database, LLM providers, the
+// Claude Code bridge, git, free disk space and the embedded UI.
+func RunDoctor(ctx context.Context, cfg DoctorConfig) DoctorReport {
+	var checks []DoctorCheck
+	checks = append(checks, checkDatabase(ctx, cfg.DB)...)
+	if cfg.Providers != nil {
+		checks = append(checks, checkProviders(ctx, cfg.Providers, cfg.Models)...)
+	}
+	checks = append(checks, checkGit(), checkDiskSpace(cfg.DBPath), checkUIAssets(cfg.Assets))
+
+	report := DoctorReport{Status: CheckOK, Checks: checks}
+	for _, c := range checks {
+		if c.Status == CheckFail || (c.Status == CheckWarn && report.Status == CheckOK) {
+			report.Status = c.Status
+		}
+	}
+	return report
+}
+
+func checkDatabase(ctx context.Context, database *db.DB) []DoctorCheck {
+	integrity := DoctorCheck{Name: "database integrity", Status: CheckOK}
+	problems, err := database.QuickCheck(ctx)
+	switch {
+	case err != nil:
+		integrity.Status, integrity.Detail = CheckFail, err.Error()
+		integrity.Fix = "Check that the database file is readable and not locked by another program"
+	case len(problems) > 0:
+		integrity.Status = CheckFail
+		integrity.Detail = strings.Join(problems, "; ")
+		integrity.Fix = "Stop Shelley, back up the database file and recover it with: sqlite3 shelley.db .recover | sqlite3 recovered.db"
+	}
+
+	schema := DoctorCheck{Name: "database schema", Status: CheckOK}
+	status, err := database.CheckMigrations(ctx)
+	switch {
+	case err != nil:
+		schema.Status, schema.Detail = CheckFail, err.Error()
+	case len(status.Unknown) > 0:
+		schema.Status = CheckFail
+		schema.Detail = fmt.Sprintf("the database was used by a newer release (schema version %d, this release knows %d)", status.Version, status.Latest)
+		schema.Fix = "Upgrade Shelley, or restore a backup made before the newer release"
+	case len(status.Pending) > 0:
+		schema.Status = CheckWarn
+		schema.Detail = fmt.Sprintf("%d migrations pending", len(status.Pending))
+		schema.Fix = "Start shelley serve, which applies them"
+	default:
+		schema.Detail = fmt.Sprintf("schema version %d", status.Version)
+	}
+	return []DoctorCheck{integrity, schema}
+}
+
+func checkProviders(ctx context.Context, checker ProviderHealthChecker, available []string) []DoctorCheck {
+	var checks []DoctorCheck
+	configured := 0
+	for _, h := range checker.CheckProviderHealth(ctx) {
+		name := "provider " + string(h.Provider)
+		if h.Provider == models.ProviderClaudeCode {
+			name = "Claude Code bridge"
+		}
+		if !h.Configured {
+			continue
+		}
+		configured++
+		c := DoctorCheck{Name: name, Status: CheckOK, Detail: fmt.Sprintf("answered in %s", h.Latency.Round(time.Millisecond))}
+		switch {
+		case h.AuthValid != nil && !*h.AuthValid:
+			c.Status, c.Detail = CheckFail, h.Error
+			c.Fix = fmt.Sprintf("Replace the key in %s, or save a new one with POST /api/providers/%s", providerEnvVars[h.Provider], h.Provider)
+		case !h.Reachable:
+			c.Status, c.Detail = CheckFail, h.Error
+			c.Fix = "Check the network connection and any proxy or gateway settings"
+			if h.Provider == models.ProviderClaudeCode {
+				c.Fix = fmt.Sprintf("Start the bridge, or check the URL in %s or the claude_code_bridge config", providerEnvVars[h.Provider])
+			}
+		case h.Error != "":
+			c.Status, c.Detail = CheckWarn, h.Error
+		}
+		checks = append(checks, c)
+	}
+	// Custom models work without a configured provider
+	hasModels := slices.ContainsFunc(available, func(id string) bool { return id != "predictable" })
+	if configured == 0 && !hasModels {
+		checks = append(checks, DoctorCheck{
+			Name:   "providers",
+			Status: CheckFail,
+			Detail: "no LLM provider is configured",
+			Fix:    "Set ANTHROPIC_API_KEY (or OPENAI_API_KEY, GEMINI_API_KEY, FIREWORKS_API_KEY), or add a custom model",
+		})
+	}
+	return checks
+}
+
+func checkGit() DoctorCheck {
+	c := DoctorCheck{Name: "git", Status: CheckOK}
+	path, err := exec.LookPath("git")
+	if err != nil {
+		c.Status, c.Detail = CheckWarn, "git is not installed"
+		c.Fix = "Install git; without it diffs, checkpoints and project detection are unavailable"
+		return c
+	}
+	output, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		c.Status, c.Detail = CheckWarn, err.Error()
+		c.Fix = "Reinstall git"
+		return c
+	}
+	c.Detail = strings.TrimSpace(string(output))
+	return c
+}
+
+func checkDiskSpace(dbPath string) DoctorCheck {
+	c := DoctorCheck{Name: "disk space", Status: CheckOK}
+	dir := filepath.Dir(dbPath)
+	free, err := diskFree(dir)
+	if err != nil {
+		c.Status, c.Detail = CheckWarn, err.Error()
+		return c
+	}
+	c.Detail = fmt.Sprintf("%d MiB free in %s", free>>20, dir)
+	switch {
+	case free < diskFailBytes:
+		c.Status = CheckFail
+	case free < diskWarnBytes:
+		c.Status = CheckWarn
+	}
+	if c.Status != CheckOK {
+		c.Fix = "Free up space on the disk holding the database"
+	}
+	return c
+}
+
+func checkUIAssets(assets fs.FS) DoctorCheck {
+	c := DoctorCheck{Name: "UI assets", Status: CheckOK}
+	if assets == nil {
+		assets = ui.Dist
+	}
+	for _, name := range []string{"dist/index.html", "dist/build-info.json"} {
+		if _, err := fs.Stat(assets, name); err != nil {
+			c.Status, c.Detail = CheckFail, name+" is missing from the binary"
+			c.Fix = "Rebuild the UI with make ui and then the binary"
+			return c
+		}
+	}
+	return c
+}
+
+// handleFullHealth handles GET /api/health/full, running the checks of
+// shelley doctor. It responds 503 when a check fails.
+func (s *Server) handleFullHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), doctorTimeout)
+	defer cancel()
+	cfg := DoctorConfig{DB: s.db, DBPath: DBPath, Models: s.llmManager.GetAvailableModels()}
+	if checker, ok := s.llmManager.(ProviderHealthChecker); ok {
+		cfg.Providers = checker
+	}
+	report := RunDoctor(ctx, cfg)
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == CheckFail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"shelley.exe.dev/models"
+)
+
+type fakeHealthChecker []models.ProviderHealth
+
+func (f fakeHealthChecker) CheckProviderHealth(ctx context.Context) []models.ProviderHealth {
+	return f
+}
+
+func TestRunDoctor(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	rejected := false
+	report := RunDoctor(t.Context(), DoctorConfig{
+		DB:     h.db,
+		DBPath: t.TempDir() + "/shelley.db",
+		Providers: fakeHealthChecker{
+			{Provider: models.ProviderAnthropic, Configured: true, Reachable: true, AuthValid: &rejected, Error: "credential rejected: 401 Unauthorized"},
+			{Provider: models.ProviderOpenAI},
+		},
+		Assets: fstest.MapFS{"dist/index.html": {}},
+	})
+	byName := make(map[string]DoctorCheck)
+	for _, c := range report.Checks {
+		byName[c.Name] = c
+	}
+	if report.Status != CheckFail {
+		t.Errorf("status = %s, want fail", report.Status)
+	}
+	if c := byName["database integrity"]; c.Status != CheckOK {
+		t.Errorf("database integrity = %+v", c)
+	}
+	if c := byName["database schema"]; c.Status != CheckOK {
+		t.Errorf("database schema = %+v", c)
+	}
+	if c := byName["provider anthropic"]; c.Status != CheckFail || c.Fix == "" {
+		t.Errorf("rejected provider = %+v", c)
+	}
+	if _, ok := byName["provider openai"]; ok {
+		t.Error("unconfigured provider was reported")
+	}
+	if c := byName["UI assets"]; c.Status != CheckFail || c.Detail != "dist/build-info.json is missing from the binary" {
+		t.Errorf("UI assets = %+v", c)
+	}
+
+	// Custom models don't need a provider
+	report = RunDoctor(t.Context(), DoctorConfig{DB: h.db, Providers: fakeHealthChecker{}, Models: []string{"custom-abc"}})
+	for _, c := range report.Checks {
+		if c.Name == "providers" {
+			t.Errorf("providers = %+v with a custom model", c)
+		}
+	}
+	if c := checkUIAssets(nil); c.Status != CheckOK {
+		t.Errorf("embedded UI assets = %+v", c)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"shelley.exe.dev/claudetool/browse"
+	"shelley.exe.dev/doctext"
+	"shelley.exe.dev/llm"
+)
+
+const (
+	// maxDocumentText caps the extracted text of a document given to the model.
+	maxDocumentText = 200_000
+	// maxNativeDocumentPages is the most pages of a PDF sent to providers
+	// that read PDFs natively; longer documents are sent as text.
+	maxNativeDocumentPages = 100
+)
+
+// attachmentRegexp matches the "[path]" references that the UI inserts into
+// messages for uploaded files.
+var attachmentRegexp = regexp.MustCompile(`\[(/[^\]\n]+)\]`)
+
+// attachDocuments adds the uploaded documents referenced in a user message to
+// it, so that the model can read them without a tool call.
+func attachDocuments(message llm.Message) llm.Message {
+	var docs []llm.Content
+	seen := make(map[string]bool)
+	for _, c := range message.Content {
+		if c.Type != llm.ContentTypeText || c.MediaType != "" {
+			continue
+		}
+		for _, m := range attachmentRegexp.FindAllStringSubmatch(c.Text, -1) {
+			path := filepath.Clean(m[1])
+			if seen[path] || !strings.HasPrefix(path, browse.ScreenshotDir+"/") || !doctext.Supported(path) {
+				continue
+			}
+			seen[path] = true
+			docs = append(docs, documentContent(path))
+		}
+	}
+	if len(docs) == 0 {
+		return message
+	}
+	message.Content = append(append([]llm.Content(nil), message.Content...), docs...)
+	return message
+}
+
+// documentContent extracts the text of the document at path. Extraction
+// errors are reported to the model in the text rather than failing the message.
+func documentContent(path string) llm.Content {
+	mediaType := doctext.MediaTypes[strings.ToLower(filepath.Ext(path))]
+	c := llm.Content{Type: llm.ContentTypeDocument, MediaType: mediaType}
+	doc, err := extractDocument(path)
+	if err != nil {
+		c.Text = fmt.Sprintf("[could not read the text of document %s: %v]", path, err)
+		return c
+	}
+
+	text := doc.Text
+	if len(text) > maxDocumentText {
+		text = text[:maxDocumentText] + "\n[document truncated; read the rest from the file]"
+	}
+	if strings.TrimSpace(text) == "" {
+		text = "[no text found; the document may be scanned images]"
+	}
+	header := fmt.Sprintf("Document %s", path)
+	if doc.Pages == 1 {
+		header += " (1 page)"
+	} else if doc.Pages > 1 {
+		header += fmt.Sprintf(" (%d pages)", doc.Pages)
+	}
+	c.Text = header + ":\n\n" + text
+
+	if mediaType == "application/pdf" && doc.Pages <= maxNativeDocumentPages {
+		if data, err := os.ReadFile(path); err == nil {
+			c.Data = base64.StdEncoding.EncodeToString(data)
+		}
+	}
+	return c
+}
+
+// extractDocument extracts the text of a document, turning a panic of the
+// parser on a malformed file into an error, since it runs on the paths of
+// conversations and batches.
+func extractDocument(path string) (doc *doctext.Document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("malformed document: %v", r)
+		}
+	}()
+	return doctext.Extract(path)
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool/browse"
+	"shelley.exe.dev/llm"
+)
+
+func TestAttachDocuments(t *testing.T) {
+	if err := os.MkdirAll(browse.ScreenshotDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(browse.ScreenshotDir, "upload_documents_test.docx")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>The spec says 42.</w:t></w:r></w:p></w:body></w:document>`))
+	zw.Close()
+	f.Close()
+
+	broken := filepath.Join(browse.ScreenshotDir, "upload_documents_test.pdf")
+	os.WriteFile(broken, []byte("not a pdf"), 0o644)
+	defer os.Remove(broken)
+
+	msg := attachDocuments(llm.UserStringMessage("What does this say? [" + path + "] [" + path + "] [/etc/passwd.pdf] [" + broken + "]"))
+	if len(msg.Content) != 3 {
+		t.Fatalf("attached %d contents, want the message and two documents: %+v", len(msg.Content), msg.Content)
+	}
+	doc := msg.Content[1]
+	if doc.Type != llm.ContentTypeDocument || !strings.Contains(doc.MediaType, "wordprocessingml") ||
+		!strings.HasPrefix(doc.Text, "Document "+path+" (1 page):") || !strings.Contains(doc.Text, "The spec says 42.") {
+		t.Errorf("document content = %+v", doc)
+	}
+	if bad := msg.Content[2]; bad.MediaType != "application/pdf" || bad.Data != "" || !strings.Contains(bad.Text, "could not read") {
+		t.Errorf("unreadable document content = %+v", bad)
+	}
+
+	plain := llm.UserStringMessage("no attachments here")
+	if got := attachDocuments(plain); len(got.Content) != 1 {
+		t.Errorf("message without attachments got %d contents", len(got.Content))
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/email"
+)
+
+// SetEmailNotifier sets the notifier of the email channel. Emails are
+// disabled until it is set. They go to the notifier's configured recipients,
+// so only turns of admins send them.
+func (s *Server) SetEmailNotifier(notifier *email.Notifier) {
+	s.emailNotifier = notifier
+}
+
+// emailTurnEnded emails the end of a turn: an approval request if the
+// conversation waits for the user, otherwise that it finished or failed.
+func (s *Server) emailTurnEnded(ctx context.Context, conversation *generated.Conversation, end turnEnd) {
+	event := email.EventTaskCompleted
+	if end.NeedsUser {
+		event = email.EventApprovalRequest
+	}
+	data := email.Data{
+		ConversationID: conversation.ConversationID,
+		Title:          "Shelley",
+		Summary:        end.Text,
+		Reason:         end.Reason,
+		Plan:           end.Plan,
+		Failed:         end.Failed,
+		Duration:       end.Duration.Round(time.Second),
+	}
+	if conversation.Slug != nil {
+		data.Title = *conversation.Slug
+		data.URL = s.emailNotifier.ConversationURL(*conversation.Slug)
+	}
+	if conversation.Model != nil {
+		data.Model = *conversation.Model
+	}
+	if err := s.emailNotifier.Notify(ctx, event, data); err != nil {
+		s.logger.Warn("Failed to send notification email", "conversationID", conversation.ConversationID, "error", err)
+	}
+}
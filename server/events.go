@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/loop"
+)
+
+// EventTypeState is the type of the timeline events of the agent starting
+// and stopping work, named "working" and "idle". The other types are those
+// of loop.Event.
+const EventTypeState = "state"
+
+// ConversationEventAPI is the API representation of a conversation timeline event
+type ConversationEventAPI struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+	// RefID links the start and end of an LLM request or tool call.
+	RefID string `json:"ref_id,omitempty"`
+	// Name is the state, the model of LLM requests, or the tool of tool calls.
+	Name       string    `json:"name,omitempty"`
+	DurationMs *int64    `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func toConversationEventAPI(e generated.ConversationEvent) ConversationEventAPI {
+	return ConversationEventAPI{
+		ID:         e.EventID,
+		Type:       e.Type,
+		RefID:      e.RefID,
+		Name:       e.Name,
+		DurationMs: e.DurationMs,
+		Error:      e.Error,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+// recordEvent appends an event to the conversation's timeline and streams it
+// to subscribers. Failures are logged: the timeline must not break the turn.
+func (cm *ConversationManager) recordEvent(ctx context.Context, params generated.InsertConversationEventParams) {
+	params.ConversationID = cm.conversationID
+	params.CreatedAt = time.Now()
+	event, err := cm.db.InsertConversationEvent(context.WithoutCancel(ctx), params)
+	if err != nil {
+		cm.logger.Warn("Failed to record conversation event", "type", params.Type, "error", err)
+		return
+	}
+	cm.subpub.Broadcast(StreamResponse{Events: []ConversationEventAPI{toConversationEventAPI(*event)}})
+}
+
+// recordLoopEvent records an LLM request or tool call starting or ending.
+func (cm *ConversationManager) recordLoopEvent(ctx context.Context, event loop.Event) {
+	params := generated.InsertConversationEventParams{
+		Type:  string(event.Type),
+		RefID: event.ID,
+		Name:  event.Name,
+		Error: event.Error,
+	}
+	if event.Type == loop.EventLLMRequestEnd || event.Type == loop.EventToolEnd {
+		ms := event.Duration.Milliseconds()
+		params.DurationMs = &ms
+	}
+	switch event.Type {
+	case loop.EventLLMRequestEnd:
+		cm.timings.observe(phaseLLMRequest, event.Duration)
+		if event.Error == "" && cm.connectivity != nil {
+			cm.connectivity.markOnline()
+		}
+	case loop.EventToolEnd:
+		cm.timings.observe("tool/"+event.Name, event.Duration)
+	}
+	cm.recordEvent(ctx, params)
+}
+
+// handleConversationEvents handles GET /api/conversations/<id>/events, which
+// returns up to limit (default 500, at most 5000) events after the event
+// ?after, oldest first.
+func (s *Server) handleConversationEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conversationID := r.PathValue("id")
+
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	var after int64
+	if v := r.URL.Query().Get("after"); v != "" {
+		var err error
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "Invalid after", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := int64(500)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.ParseInt(v, 10, 64); err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit > 5000 {
+			limit = 5000
+		}
+	}
+
+	events, err := s.db.ListConversationEvents(ctx, conversationID, after, limit)
+	if err != nil {
+		s.logger.Error("Failed to list conversation events", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	result := make([]ConversationEventAPI, 0, len(events))
+	for _, e := range events {
+		result = append(result, toConversationEventAPI(e))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/loop"
+)
+
+func TestConversationEvents(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("bash: echo hello", "")
+	h.WaitResponse()
+	h.waitIdle()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	list := func(query string) []ConversationEventAPI {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/conversations/"+h.convID+"/events"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		var events []ConversationEventAPI
+		json.Unmarshal(w.Body.Bytes(), &events)
+		return events
+	}
+
+	// The idle state is recorded after the last message, so wait for it
+	var events []ConversationEventAPI
+	deadline := time.Now().Add(h.timeout)
+	for {
+		events = list("")
+		if len(events) > 0 && events[len(events)-1].Name == "idle" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []string{
+		EventTypeState,
+		string(loop.EventLLMRequestStart), string(loop.EventLLMRequestEnd),
+		string(loop.EventToolStart), string(loop.EventToolEnd),
+		string(loop.EventLLMRequestStart), string(loop.EventLLMRequestEnd),
+		EventTypeState,
+	}
+	if !slices.Equal(types, want) {
+		t.Fatalf("event types = %v, want %v", types, want)
+	}
+	if events[0].Name != "working" {
+		t.Errorf("first state = %q", events[0].Name)
+	}
+	toolEnd := events[4]
+	if toolEnd.Name != "bash" || toolEnd.RefID != events[3].RefID || toolEnd.DurationMs == nil {
+		t.Errorf("tool end event = %+v", toolEnd)
+	}
+	if events[1].DurationMs != nil || events[2].DurationMs == nil {
+		t.Errorf("only end events have durations: %+v, %+v", events[1], events[2])
+	}
+
+	if after := list("?after=" + strconv.FormatInt(events[5].ID, 10) + "&limit=1"); len(after) != 1 || after[0].ID != events[6].ID {
+		t.Errorf("events after %d = %+v", events[5].ID, after)
+	}
+}
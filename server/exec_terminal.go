@@ -8,8 +8,6 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
-	"syscall"
-	"unsafe"
 
 	"github.com/coder/websocket"
 	"github.com/coder/websocket/wsjson"
@@ -46,6 +44,19 @@ func (s *Server) handleExecWS(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	shellCmd := exec.CommandContext(ctx, "bash", "-c", cmd)
+	shellCmd.Dir = cwd
+	shellCmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	s.serveTerminal(w, r, shellCmd)
+}
+
+// serveTerminal upgrades the request to a websocket and runs shellCmd in a PTY,
+// relaying its output and the client's input and resizes until the command
+// exits or the client goes away. The client sends an init message with the
+// terminal size first.
+func (s *Server) serveTerminal(w http.ResponseWriter, r *http.Request, shellCmd *exec.Cmd) {
+	ctx := r.Context()
+
 	// Upgrade to websocket
 	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
 		CompressionMode: websocket.CompressionDisabled,
@@ -78,18 +89,13 @@ func (s *Server) handleExecWS(w http.ResponseWriter, r *http.Request) {
 		rows = 24
 	}
 
-	// Create command
-	shellCmd := exec.CommandContext(ctx, "bash", "-c", cmd)
-	shellCmd.Dir = cwd
-	shellCmd.Env = append(os.Environ(), "TERM=xterm-256color")
-
 	// Start with pty
 	ptmx, err := pty.StartWithSize(shellCmd, &pty.Winsize{
 		Cols: cols,
 		Rows: rows,
 	})
 	if err != nil {
-		s.logger.Error("Failed to start command with pty", "error", err, "cmd", cmd)
+		s.logger.Error("Failed to start command with pty", "error", err, "cmd", shellCmd.Args)
 		errMsg := ExecMessage{
 			Type: "error",
 			Data: err.Error(),
@@ -198,23 +204,5 @@ func (s *Server) handleExecWS(w http.ResponseWriter, r *http.Request) {
 
 // setWinsize sets the terminal window size
 func setWinsize(f *os.File, cols, rows uint16) error {
-	ws := struct {
-		Rows uint16
-		Cols uint16
-		X    uint16
-		Y    uint16
-	}{
-		Rows: rows,
-		Cols: cols,
-	}
-	_, _, errno := syscall.Syscall(
-		syscall.SYS_IOCTL,
-		f.Fd(),
-		uintptr(syscall.TIOCSWINSZ),
-		uintptr(unsafe.Pointer(&ws)),
-	)
-	if errno != 0 {
-		return errno
-	}
-	return nil
+	return pty.Setsize(f, &pty.Winsize{Rows: rows, Cols: cols})
 }
@@ -80,7 +80,7 @@ func (s *Server) handleGitDiffs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cwd := r.URL.Query().Get("cwd")
+	cwd := s.hostPath(r.URL.Query().Get("cwd"))
 	if cwd == "" {
 		http.Error(w, "cwd parameter required", http.StatusBadRequest)
 		return
@@ -155,7 +155,7 @@ func (s *Server) handleGitDiffs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"diffs":   diffs,
-		"gitRoot": gitRoot,
+		"gitRoot": s.clientPath(r, gitRoot),
 	})
 }
 
@@ -175,7 +175,7 @@ func (s *Server) handleGitDiffFiles(w http.ResponseWriter, r *http.Request) {
 	}
 	diffID := parts[0]
 
-	cwd := r.URL.Query().Get("cwd")
+	cwd := s.hostPath(r.URL.Query().Get("cwd"))
 	if cwd == "" {
 		http.Error(w, "cwd parameter required", http.StatusBadRequest)
 		return
@@ -278,7 +278,7 @@ func (s *Server) handleGitFileDiff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cwd := r.URL.Query().Get("cwd")
+	cwd := s.hostPath(r.URL.Query().Get("cwd"))
 	if cwd == "" {
 		http.Error(w, "cwd parameter required", http.StatusBadRequest)
 		return
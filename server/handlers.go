@@ -1,7 +1,6 @@
 package server
 
 import (
-	"compress/gzip"
 	"context"
 	"crypto/rand"
 	"database/sql"
@@ -10,7 +9,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"mime"
 	"net/http"
 	"net/url"
 	"os"
@@ -25,7 +23,6 @@ import (
 	"shelley.exe.dev/llm"
 	"shelley.exe.dev/models"
 	"shelley.exe.dev/slug"
-	"shelley.exe.dev/ui"
 	"shelley.exe.dev/version"
 )
 
@@ -104,7 +101,7 @@ func (s *Server) handleWriteFile(w http.ResponseWriter, r *http.Request) {
 
 	// Security: only allow writing within certain directories
 	// For now, require the path to be within a git repository
-	clean := filepath.Clean(req.Path)
+	clean := filepath.Clean(s.hostPath(req.Path))
 	if !filepath.IsAbs(clean) {
 		http.Error(w, "absolute path required", http.StatusBadRequest)
 		return
@@ -183,15 +180,34 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"path": filename})
 }
 
-// staticHandler serves files from the provided filesystem.
-// For JS/CSS files, it serves pre-compressed .gz versions with content-based ETags.
-func isConversationSlugPath(path string) bool {
-	return strings.HasPrefix(path, "/c/")
+// saveGeneratedImage saves an image generated by the LLM as an attachment
+// next to uploads, where /api/read serves it, and returns its path.
+func saveGeneratedImage(ctx context.Context, mediaType string, data []byte) (string, error) {
+	randBytes := make([]byte, 8)
+	if _, err := rand.Read(randBytes); err != nil {
+		return "", err
+	}
+	ext := ".png"
+	switch mediaType {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/webp":
+		ext = ".webp"
+	case "image/gif":
+		ext = ".gif"
+	}
+	if err := os.MkdirAll(browse.ScreenshotDir, 0o755); err != nil {
+		return "", err
+	}
+	filename := filepath.Join(browse.ScreenshotDir, fmt.Sprintf("generated_%s%s", hex.EncodeToString(randBytes), ext))
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		return "", err
+	}
+	return filename, nil
 }
 
-// acceptsGzip returns true if the client accepts gzip encoding
-func acceptsGzip(r *http.Request) bool {
-	return strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+func isConversationSlugPath(path string) bool {
+	return strings.HasPrefix(path, "/c/")
 }
 
 // etagMatches checks if the client's If-None-Match header matches the given ETag.
@@ -221,11 +237,10 @@ func etagMatches(ifNoneMatch, etag string) bool {
 	return false
 }
 
+// staticHandler serves the UI from the provided filesystem: index.html with
+// initialization data, and its assets with content-based caching.
 func (s *Server) staticHandler(fsys http.FileSystem) http.Handler {
-	fileServer := http.FileServer(fsys)
-
-	// Load checksums for ETag support (content-based, not git-based)
-	checksums := ui.Checksums()
+	assets := newStaticAssets(fsys)
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Inject initialization data into index.html
@@ -234,66 +249,10 @@ func (s *Server) staticHandler(fsys http.FileSystem) http.Handler {
 			w.Header().Set("Pragma", "no-cache")
 			w.Header().Set("Expires", "0")
 			w.Header().Set("Content-Type", "text/html")
-			s.serveIndexWithInit(w, r, fsys)
-			return
-		}
-
-		// For JS and CSS files, serve from .gz files (only .gz versions are embedded)
-		if strings.HasSuffix(r.URL.Path, ".js") || strings.HasSuffix(r.URL.Path, ".css") {
-			gzPath := r.URL.Path + ".gz"
-			gzFile, err := fsys.Open(gzPath)
-			if err != nil {
-				// No .gz file, fall through to regular file server
-				fileServer.ServeHTTP(w, r)
-				return
-			}
-			defer gzFile.Close()
-
-			stat, err := gzFile.Stat()
-			if err != nil || stat.IsDir() {
-				fileServer.ServeHTTP(w, r)
-				return
-			}
-
-			// Get filename without leading slash for checksum lookup
-			filename := strings.TrimPrefix(r.URL.Path, "/")
-
-			// Check ETag for cache validation (content-based)
-			if checksums != nil {
-				if hash, ok := checksums[filename]; ok {
-					etag := `"` + hash + `"`
-					w.Header().Set("ETag", etag)
-					if etagMatches(r.Header.Get("If-None-Match"), etag) {
-						w.WriteHeader(http.StatusNotModified)
-						return
-					}
-				}
-			}
-
-			w.Header().Set("Content-Type", mime.TypeByExtension(filepath.Ext(r.URL.Path)))
-			w.Header().Set("Vary", "Accept-Encoding")
-			// Use must-revalidate so browsers check ETag on each request.
-			// We can't use immutable since we don't have content-hashed filenames.
-			w.Header().Set("Cache-Control", "public, max-age=0, must-revalidate")
-
-			if acceptsGzip(r) {
-				// Client accepts gzip - serve compressed directly
-				w.Header().Set("Content-Encoding", "gzip")
-				io.Copy(w, gzFile)
-			} else {
-				// Rare: client doesn't accept gzip - decompress on the fly
-				gr, err := gzip.NewReader(gzFile)
-				if err != nil {
-					http.Error(w, "failed to decompress", http.StatusInternalServerError)
-					return
-				}
-				defer gr.Close()
-				io.Copy(w, gr)
-			}
+			s.serveIndexWithInit(w, r, assets)
 			return
 		}
-
-		fileServer.ServeHTTP(w, r)
+		assets.ServeHTTP(w, r)
 	})
 }
 
@@ -342,9 +301,9 @@ func generateFaviconSVG(hostname string) string {
 }
 
 // serveIndexWithInit serves index.html with injected initialization data
-func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs http.FileSystem) {
+func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, assets *staticAssets) {
 	// Read index.html from the filesystem
-	file, err := fs.Open("/index.html")
+	file, err := assets.fsys.Open("/index.html")
 	if err != nil {
 		http.Error(w, "index.html not found", http.StatusNotFound)
 		return
@@ -361,7 +320,7 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 	modelList := s.getModelList()
 
 	// Select default model - use configured default if available, otherwise first ready model
-	defaultModel := s.defaultModel
+	defaultModel := s.Settings().DefaultModel
 	if defaultModel == "" {
 		defaultModel = models.Default().ID
 	}
@@ -407,12 +366,20 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 		"hostname":      hostname,
 		"default_cwd":   defaultCwd,
 		"home_dir":      homeDir,
+		"base_path":     s.basePath,
+	}
+	settings := s.Settings()
+	if settings.TerminalURL != "" {
+		initData["terminal_url"] = settings.TerminalURL
 	}
-	if s.terminalURL != "" {
-		initData["terminal_url"] = s.terminalURL
+	if len(settings.Links) > 0 {
+		initData["links"] = settings.Links
 	}
-	if len(s.links) > 0 {
-		initData["links"] = s.links
+	if s.transcriber != nil {
+		initData["transcription"] = true
+	}
+	if s.pushSender != nil {
+		initData["vapid_public_key"] = s.pushSender.PublicKey()
 	}
 
 	initJSON, err := json.Marshal(initData)
@@ -430,13 +397,24 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 	initScript := fmt.Sprintf(`<script>window.__SHELLEY_INIT__=%s;</script>`, initJSON)
 	injection := faviconLink + initScript
 	modifiedHTML := strings.Replace(string(indexHTML), "</head>", injection+"</head>", 1)
+	modifiedHTML = assets.versionLinks(modifiedHTML)
+	if s.basePath != "" {
+		// Asset links in index.html are absolute
+		modifiedHTML = strings.ReplaceAll(modifiedHTML, `href="/`, `href="`+s.basePath+`/`)
+		modifiedHTML = strings.ReplaceAll(modifiedHTML, `src="/`, `src="`+s.basePath+`/`)
+	}
 
 	w.Write([]byte(modifiedHTML))
 }
 
 // handleConfig returns server configuration
-// handleConversations handles GET /conversations
+// handleConversations handles GET /conversations, and POST /conversations?template=<name>
+// to start a conversation from a template
 func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		s.handleConversationFromTemplate(w, r)
+		return
+	}
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -484,6 +462,29 @@ func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
 
 	// Get working states for all active conversations
 	workingStates := s.getWorkingConversations()
+	summaries, err := s.db.ListConversationSummaries(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get conversation summaries", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	summaryByID := make(map[string]generated.ConversationSummary, len(summaries))
+	for _, summary := range summaries {
+		summaryByID[summary.ConversationID] = summary
+	}
+	unread, err := s.unreadMessages(ctx, s.requestUser(r))
+	if err != nil {
+		s.logger.Error("Failed to get unread messages", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	tags, err := s.db.ListConversationTags(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get conversation tags", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	tagsByID := groupConversationTags(tags)
 
 	// Build response with working state included
 	result := make([]ConversationWithState, len(conversations))
@@ -491,6 +492,23 @@ func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
 		result[i] = ConversationWithState{
 			Conversation: conv,
 			Working:      workingStates[conv.ConversationID],
+			Tags:         tagsByID[conv.ConversationID],
+		}
+		if conv.Cwd != nil && *conv.Cwd != "" {
+			result[i].ProjectRoot = s.projectFor(*conv.Cwd).Root
+		}
+		if summary, ok := summaryByID[conv.ConversationID]; ok {
+			result[i].Summary = toConversationSummaryAPI(summary)
+		}
+		if messages := unread[conv.ConversationID]; len(messages) > 0 {
+			cwd := ""
+			if conv.Cwd != nil {
+				cwd = *conv.Cwd
+			}
+			result[i].UnreadCount = len(messages)
+			if files := touchedFiles(messages, cwd); len(files) > 0 {
+				result[i].ChangedFiles = files
+			}
 		}
 	}
 
@@ -518,6 +536,81 @@ func (s *Server) conversationMux() *http.ServeMux {
 	mux.HandleFunc("POST /{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
 		s.handleCancelConversation(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/retry", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRetryConversation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/resume", func(w http.ResponseWriter, r *http.Request) {
+		s.handleResumeConversation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/edit", func(w http.ResponseWriter, r *http.Request) {
+		s.handleEditMessage(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/undo-truncation", func(w http.ResponseWriter, r *http.Request) {
+		s.handleUndoTruncation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/directories", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationDirectories(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/directories", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationDirectories(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/directories/remove", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationDirectories(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/env", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationEnv(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/env", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationEnv(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/env/unset", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationEnv(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/hooks", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationHooks(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/hooks", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationHooks(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/hooks/remove", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationHooks(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/settings", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationSettings(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/settings", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationSettings(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/notifications", func(w http.ResponseWriter, r *http.Request) {
+		s.handleNotificationPreferences(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/notifications", func(w http.ResponseWriter, r *http.Request) {
+		s.handleNotificationPreferences(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/plan", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationPlan(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/plan", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationPlan(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/plan/approve", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationPlan(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/approval", func(w http.ResponseWriter, r *http.Request) {
+		s.handleCommandApproval(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/approval", func(w http.ResponseWriter, r *http.Request) {
+		s.handleCommandApproval(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/bridge-session", func(w http.ResponseWriter, r *http.Request) {
+		s.handleBridgeSession(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/bridge-session/reset", func(w http.ResponseWriter, r *http.Request) {
+		s.handleBridgeSession(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/bridge-session/compact", func(w http.ResponseWriter, r *http.Request) {
+		s.handleBridgeSession(w, r, r.PathValue("id"))
+	})
 	mux.HandleFunc("POST /{id}/archive", func(w http.ResponseWriter, r *http.Request) {
 		s.handleArchiveConversation(w, r, r.PathValue("id"))
 	})
@@ -533,6 +626,33 @@ func (s *Server) conversationMux() *http.ServeMux {
 	mux.HandleFunc("GET /{id}/subagents", func(w http.ResponseWriter, r *http.Request) {
 		s.handleGetSubagents(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("GET /{id}/annotations", func(w http.ResponseWriter, r *http.Request) {
+		s.handleMessageAnnotations(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/messages/{message_id}/annotation", func(w http.ResponseWriter, r *http.Request) {
+		s.handleMessageAnnotation(w, r, r.PathValue("id"), r.PathValue("message_id"))
+	})
+	mux.HandleFunc("GET /{id}/context-flags", func(w http.ResponseWriter, r *http.Request) {
+		s.handleMessageContextFlags(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/messages/{message_id}/context-flag", func(w http.ResponseWriter, r *http.Request) {
+		s.handleMessageContextFlag(w, r, r.PathValue("id"), r.PathValue("message_id"))
+	})
+	mux.HandleFunc("GET /{id}/comments", func(w http.ResponseWriter, r *http.Request) {
+		s.handleReviewComments(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/comments", func(w http.ResponseWriter, r *http.Request) {
+		s.handleReviewComments(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("PUT /{id}/comments/{comment_id}", func(w http.ResponseWriter, r *http.Request) {
+		s.handleReviewComment(w, r, r.PathValue("id"), r.PathValue("comment_id"))
+	})
+	mux.HandleFunc("DELETE /{id}/comments/{comment_id}", func(w http.ResponseWriter, r *http.Request) {
+		s.handleReviewComment(w, r, r.PathValue("id"), r.PathValue("comment_id"))
+	})
+	mux.HandleFunc("POST /{id}/read", func(w http.ResponseWriter, r *http.Request) {
+		s.handleMarkConversationRead(w, r, r.PathValue("id"))
+	})
 	return mux
 }
 
@@ -554,6 +674,9 @@ func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request, c
 		if err != nil {
 			return err
 		}
+		if err := db.LoadMessageBlobs(ctx, q, messages); err != nil {
+			return err
+		}
 		conversation, err = q.GetConversation(ctx, conversationID)
 		return err
 	})
@@ -566,9 +689,15 @@ func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request, c
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if err := s.db.MarkConversationRead(ctx, s.requestUser(r), conversationID); err != nil {
+		s.logger.Warn("Failed to mark conversation read", "conversationID", conversationID, "error", err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	apiMessages := toAPIMessages(messages)
+	if !showReasoning(r) {
+		apiMessages = withoutReasoning(apiMessages)
+	}
 	json.NewEncoder(w).Encode(StreamResponse{
 		Messages:     apiMessages,
 		Conversation: conversation,
@@ -582,6 +711,16 @@ type ChatRequest struct {
 	Message string `json:"message"`
 	Model   string `json:"model,omitempty"`
 	Cwd     string `json:"cwd,omitempty"`
+	// Directories are additional working directories (new conversations only)
+	Directories []string `json:"directories,omitempty"`
+	// Hooks are added to the conversation before it starts (new conversations only)
+	Hooks []HookRequest `json:"hooks,omitempty"`
+	// PlanMode starts the conversation in plan mode (new conversations only)
+	PlanMode bool `json:"plan_mode,omitempty"`
+	// Settings are the conversation's sampling and sandbox settings (new conversations only)
+	Settings *ConversationSettings `json:"settings,omitempty"`
+	// Snippet is rendered and sent before Message
+	Snippet *SnippetFill `json:"snippet,omitempty"`
 }
 
 // handleChatConversation handles POST /conversation/<id>/chat
@@ -599,16 +738,30 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	if !s.fillSnippet(w, r, &req) {
+		return
+	}
 
 	if req.Message == "" {
 		http.Error(w, "Message is required", http.StatusBadRequest)
 		return
 	}
 
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	var cwd string
+	if conversation.Cwd != nil {
+		cwd = *conversation.Cwd
+	}
+	req.Message = expandSlashCommand(req.Message, cwd)
+
 	// Get LLM service for the requested model
 	modelID := req.Model
 	if modelID == "" {
-		modelID = s.defaultModel
+		modelID = s.Settings().DefaultModel
 	}
 
 	llmService, err := s.llmManager.GetService(modelID)
@@ -638,17 +791,28 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 		},
 	}
 
-	firstMessage, err := manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
+	author := s.requestUser(r)
+	manager.SetPushOwner(author)
+	firstMessage, err := manager.AcceptUserMessage(withMessageAuthor(ctx, author), llmService, modelID, userMessage)
 	if errors.Is(err, errConversationModelMismatch) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if errors.Is(err, errConversationLeased) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		s.logger.Error("Failed to accept user message", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	// The draft has been sent; clear it so other devices don't restore it
+	if _, err := s.db.SaveDraft(ctx, conversationID, ""); err != nil {
+		s.logger.Warn("Failed to clear draft", "conversationID", conversationID, "error", err)
+	}
+
 	if firstMessage {
 		ctxNoCancel := context.WithoutCancel(ctx)
 		go func() {
@@ -674,22 +838,40 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-
 	// Parse request
 	var req ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	if !s.fillSnippet(w, r, &req) {
+		return
+	}
+	s.startNewConversation(w, r, req)
+}
 
+// startNewConversation creates a conversation for req and sends its first message.
+func (s *Server) startNewConversation(w http.ResponseWriter, r *http.Request, req ChatRequest) {
+	ctx := r.Context()
 	if req.Message == "" {
 		http.Error(w, "Message is required", http.StatusBadRequest)
 		return
 	}
+	req.Cwd = s.hostPath(req.Cwd)
+	req.Message = expandSlashCommand(req.Message, req.Cwd)
+
+	project, err := s.findProject(ctx, req.Cwd)
+	if err != nil {
+		s.logger.Error("Failed to find project", "cwd", req.Cwd, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
 	// Get LLM service for the requested model
 	modelID := req.Model
+	if modelID == "" && project != nil {
+		modelID = project.DefaultModel
+	}
 	if modelID == "" {
 		// Default to Qwen3 Coder on Fireworks
 		modelID = "qwen3-coder-fireworks"
@@ -702,6 +884,25 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for _, dir := range req.Directories {
+		if err := validateDirectory(dir); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	for _, hook := range req.Hooks {
+		if err := validateHook(hook); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Settings != nil {
+		if err := s.validateSettings(*req.Settings, llmService); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Create new conversation with optional cwd
 	var cwdPtr *string
 	if req.Cwd != "" {
@@ -714,6 +915,55 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	conversationID := conversation.ConversationID
+	s.recordDirectoryUse(r, req.Cwd)
+
+	for _, dir := range req.Directories {
+		if err := s.db.AddConversationDirectory(ctx, conversationID, filepath.Clean(dir)); err != nil {
+			s.logger.Error("Failed to add conversation directory", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	for _, hook := range req.Hooks {
+		if _, err := s.db.AddConversationHook(ctx, generated.AddConversationHookParams{
+			ConversationID: conversationID,
+			Event:          hook.Event,
+			Command:        hook.Command,
+			InjectOutput:   hook.InjectOutput,
+		}); err != nil {
+			s.logger.Error("Failed to add conversation hook", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.PlanMode {
+		if _, err := s.db.SetConversationPlanMode(ctx, conversationID, db.PlanModePlan); err != nil {
+			s.logger.Error("Failed to set plan mode", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.Settings != nil {
+		if _, err := s.db.SaveConversationSettings(ctx, generated.UpsertConversationSettingsParams{
+			ConversationID:       conversationID,
+			Temperature:          req.Settings.Temperature,
+			TopP:                 req.Settings.TopP,
+			MaxTokens:            req.Settings.MaxTokens,
+			Sandbox:              req.Settings.Sandbox,
+			TurnTimeoutSeconds:   req.Settings.TurnTimeout,
+			ToolTimeoutSeconds:   req.Settings.ToolTimeout,
+			ReadOnly:             req.Settings.ReadOnly,
+			ApproveRiskyCommands: req.Settings.ApproveRiskyCommands,
+			WindowsPaths:         req.Settings.WindowsPaths,
+			Timezone:             req.Settings.Timezone,
+			Locale:               req.Settings.Locale,
+			ResponseSchema:       req.Settings.responseSchema(),
+		}); err != nil {
+			s.logger.Error("Failed to save conversation settings", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
 
 	// Notify conversation list subscribers about the new conversation
 	go s.publishConversationListUpdate(ConversationListUpdate{
@@ -733,6 +983,32 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Run the project's setup commands before the agent starts.
+	// On failure the output is recorded in the conversation and the agent is not started.
+	if project != nil && len(project.SetupCommands) > 0 {
+		if output, err := runCommands(ctx, req.Cwd, envList(project.Env), project.SetupCommands, setupCommandTimeout); err != nil {
+			s.logger.Warn("Project setup failed", "conversationID", conversationID, "error", err)
+			errMsg := llm.Message{
+				Role:      llm.MessageRoleAssistant,
+				Content:   []llm.Content{{Type: llm.ContentTypeText, Text: fmt.Sprintf("Project setup failed: %v\n\n%s", err, output)}},
+				EndOfTurn: true,
+				ErrorType: llm.ErrorTypeHook,
+			}
+			if err := s.recordMessage(ctx, conversationID, errMsg, llm.Usage{}); err != nil {
+				s.logger.Error("Failed to record setup failure", "conversationID", conversationID, "error", err)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":          "setup_failed",
+				"conversation_id": conversationID,
+			})
+			return
+		}
+	}
+
+	manager.RunStartHooks(ctx)
+
 	// Create user message
 	userMessage := llm.Message{
 		Role: llm.MessageRoleUser,
@@ -741,11 +1017,17 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	firstMessage, err := manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
+	author := s.requestUser(r)
+	manager.SetPushOwner(author)
+	firstMessage, err := manager.AcceptUserMessage(withMessageAuthor(ctx, author), llmService, modelID, userMessage)
 	if errors.Is(err, errConversationModelMismatch) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if errors.Is(err, errConversationLeased) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		s.logger.Error("Failed to accept user message", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -803,6 +1085,7 @@ func (s *Server) handleContinueConversation(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "source_conversation_id is required", http.StatusBadRequest)
 		return
 	}
+	req.Cwd = s.hostPath(req.Cwd)
 
 	// Get source conversation
 	sourceConv, err := s.db.GetConversationByID(ctx, req.SourceConversationID)
@@ -825,7 +1108,13 @@ func (s *Server) handleContinueConversation(w http.ResponseWriter, r *http.Reque
 	if sourceConv.Slug != nil {
 		sourceSlug = *sourceConv.Slug
 	}
-	summary := buildConversationSummary(sourceSlug, messages)
+	flags, err := s.messageContextFlags(ctx, req.SourceConversationID)
+	if err != nil {
+		s.logger.Error("Failed to get context flags", "conversationID", req.SourceConversationID, "error", err)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+	summary := buildConversationSummary(sourceSlug, messages, flags)
 
 	// Determine model to use
 	modelID := req.Model
@@ -850,6 +1139,7 @@ func (s *Server) handleContinueConversation(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	conversationID := conversation.ConversationID
+	s.recordDirectoryUse(r, req.Cwd)
 
 	// Notify conversation list subscribers about the new conversation
 	go s.publishConversationListUpdate(ConversationListUpdate{
@@ -894,15 +1184,26 @@ func (s *Server) handleContinueConversation(w http.ResponseWriter, r *http.Reque
 }
 
 // buildConversationSummary creates a summary of messages from a conversation
-// for use as the initial prompt in a continuation conversation
-func buildConversationSummary(slug string, messages []generated.Message) string {
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("Continue the conversation with slug %q. Here are the user and agent messages so far (including tool inputs up to ~250 characters and tool outputs up to ~250 characters); use sqlite to look up additional details.\n\n", slug))
+// for use as the initial prompt in a continuation conversation. flags are the
+// messages' context flags by message ID.
+func buildConversationSummary(slug string, messages []generated.Message, flags map[string]string) string {
+	return fmt.Sprintf("Continue the conversation with slug %q. Here are the user and agent messages so far (including tool inputs up to ~250 characters and tool outputs up to ~250 characters, except in messages the user pinned, which are in full); use sqlite to look up additional details.\n\n", slug) +
+		conversationTranscript(messages, flags)
+}
 
+// conversationTranscript renders the user and agent messages as text, with
+// tool inputs and outputs cut to ~250 characters. Messages flagged evictable
+// are left out and pinned ones are rendered in full.
+func conversationTranscript(messages []generated.Message, flags map[string]string) string {
+	var sb strings.Builder
 	for _, msg := range messages {
 		if msg.Type != string(db.MessageTypeUser) && msg.Type != string(db.MessageTypeAgent) {
 			continue
 		}
+		if flags[msg.MessageID] == ContextFlagEvictable {
+			continue
+		}
+		pinned := flags[msg.MessageID] == ContextFlagPinned
 
 		if msg.LlmData == nil {
 			continue
@@ -928,7 +1229,7 @@ func buildConversationSummary(slug string, messages []generated.Message) string
 				}
 			case llm.ContentTypeToolUse:
 				inputStr := string(content.ToolInput)
-				if len(inputStr) > 250 {
+				if len(inputStr) > 250 && !pinned {
 					inputStr = inputStr[:250] + "..."
 				}
 				sb.WriteString(fmt.Sprintf("%s: [Tool: %s] %s\n\n", role, content.ToolName, inputStr))
@@ -941,7 +1242,7 @@ func buildConversationSummary(slug string, messages []generated.Message) string
 						break
 					}
 				}
-				if len(resultText) > 250 {
+				if len(resultText) > 250 && !pinned {
 					resultText = resultText[:250] + "..."
 				}
 				if resultText != "" {
@@ -993,6 +1294,397 @@ func (s *Server) handleCancelConversation(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
 }
 
+// EditMessageRequest replaces a previous user message and everything after it
+type EditMessageRequest struct {
+	SequenceID int64  `json:"sequence_id"`
+	Message    string `json:"message"`
+}
+
+// userPrompt returns the LLM message for msg if it is a message typed by the user
+// (as opposed to a tool result, which is also stored with the user type).
+func userPrompt(msg generated.Message) (llm.Message, bool) {
+	var llmMsg llm.Message
+	if msg.Type != string(db.MessageTypeUser) || msg.LlmData == nil {
+		return llmMsg, false
+	}
+	if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+		return llmMsg, false
+	}
+	hasText := false
+	for _, content := range llmMsg.Content {
+		switch content.Type {
+		case llm.ContentTypeToolResult:
+			return llmMsg, false
+		case llm.ContentTypeText:
+			hasText = true
+		}
+	}
+	return llmMsg, hasText
+}
+
+// handleRetryConversation handles POST /conversation/<id>/retry.
+// It discards the last user message and everything after it, then sends that message again.
+func (s *Server) handleRetryConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	messages, err := s.db.ListMessages(r.Context(), conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if prompt, ok := userPrompt(messages[i]); ok {
+			s.resendFrom(w, r, conversationID, messages[i].SequenceID, prompt)
+			return
+		}
+	}
+	http.Error(w, "No user message to retry", http.StatusBadRequest)
+}
+
+// ResumeRequest continues a turn paused as stuck
+type ResumeRequest struct {
+	// Guidance is sent to the agent with the pending tool results
+	Guidance string `json:"guidance,omitempty"`
+}
+
+// isStuck reports whether msg is the message of a turn paused as stuck.
+func isStuck(msg *generated.Message) bool {
+	if msg == nil || msg.Type != string(db.MessageTypeError) || msg.LlmData == nil {
+		return false
+	}
+	var llmMsg llm.Message
+	if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+		return false
+	}
+	return llmMsg.ErrorType == llm.ErrorTypeStuck
+}
+
+// handleResumeConversation handles POST /conversation/<id>/resume.
+// It continues a turn the agent loop paused as stuck, optionally with guidance.
+func (s *Server) handleResumeConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	var req ResumeRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	latest, err := s.db.GetLatestMessage(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get latest message", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !isStuck(latest) {
+		http.Error(w, "Conversation is not paused", http.StatusConflict)
+		return
+	}
+
+	modelID := s.Settings().DefaultModel
+	if conversation.Model != nil {
+		modelID = *conversation.Model
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		s.logger.Error("Unsupported model requested", "model", modelID, "error", err)
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
+
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	err = manager.ResumeStuckTurn(ctx, llmService, modelID, strings.TrimSpace(req.Guidance))
+	if errors.Is(err, errConversationBusy) {
+		http.Error(w, "Conversation is busy", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to resume conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "resumed"})
+}
+
+// handleEditMessage handles POST /conversation/<id>/edit.
+// It discards the given user message and everything after it, then sends the edited message.
+func (s *Server) handleEditMessage(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Message == "" {
+		http.Error(w, "Message is required", http.StatusBadRequest)
+		return
+	}
+
+	messages, err := s.db.ListMessages(r.Context(), conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for _, msg := range messages {
+		if msg.SequenceID != req.SequenceID {
+			continue
+		}
+		if _, ok := userPrompt(msg); !ok {
+			break
+		}
+		s.resendFrom(w, r, conversationID, msg.SequenceID, llm.Message{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: req.Message}},
+		})
+		return
+	}
+	http.Error(w, "Sequence ID does not refer to a user message", http.StatusBadRequest)
+}
+
+// resendFrom truncates the conversation at fromSequenceID and sends message to the agent.
+func (s *Server) resendFrom(w http.ResponseWriter, r *http.Request, conversationID string, fromSequenceID int64, message llm.Message) {
+	ctx := r.Context()
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	modelID := s.Settings().DefaultModel
+	if conversation.Model != nil {
+		modelID = *conversation.Model
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		s.logger.Error("Unsupported model requested", "model", modelID, "error", err)
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
+
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	err = manager.TruncateHistory(ctx, fromSequenceID)
+	if errors.Is(err, errConversationBusy) {
+		http.Error(w, "Conversation is busy", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to truncate conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	author := s.requestUser(r)
+	manager.SetPushOwner(author)
+	if _, err := manager.AcceptUserMessage(withMessageAuthor(ctx, author), llmService, modelID, message); errors.Is(err, errConversationLeased) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		s.logger.Error("Failed to accept user message", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+}
+
+// handleUndoTruncation handles POST /conversation/<id>/undo-truncation.
+// It restores the messages discarded by the most recent retry or edit.
+func (s *Server) handleUndoTruncation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = manager.RestoreTruncatedHistory(ctx)
+	if errors.Is(err, errConversationBusy) {
+		http.Error(w, "Conversation is busy", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, db.ErrNoTruncation) {
+		http.Error(w, "Nothing to undo", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to restore truncated messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "restored"})
+}
+
+// DraftRequest is the body of PUT /api/conversations/<id>/draft
+type DraftRequest struct {
+	Text string `json:"text"`
+}
+
+// DraftResponse is the saved draft of a conversation; Text is empty when there is none
+type DraftResponse struct {
+	Text      string     `json:"text"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// handleConversationDraft handles GET and PUT /api/conversations/<id>/draft
+func (s *Server) handleConversationDraft(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conversationID := r.PathValue("id")
+
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	var draft *generated.Draft
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		draft, err = s.db.GetDraft(ctx, conversationID)
+	case http.MethodPut:
+		var req DraftRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		draft, err = s.db.SaveDraft(ctx, conversationID, req.Text)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to access draft", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var resp DraftResponse
+	if draft != nil {
+		resp.Text = draft.Text
+		resp.UpdatedAt = &draft.UpdatedAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DirectoryRequest names a directory to add to or remove from a conversation
+type DirectoryRequest struct {
+	Path string `json:"path"`
+}
+
+// validateDirectory checks that path is an absolute path to an existing directory
+func validateDirectory(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("directory must be an absolute path: %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("directory does not exist: %s", path)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("path is not a directory: %s", path)
+	}
+	return nil
+}
+
+// handleConversationDirectories handles GET and POST /conversation/<id>/directories
+// and POST /conversation/<id>/directories/remove. All of them return the resulting list.
+func (s *Server) handleConversationDirectories(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req DirectoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		remove := strings.HasSuffix(r.URL.Path, "/remove")
+		if !remove {
+			if err := validateDirectory(req.Path); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// The directory list is part of the system prompt, so the context is rebuilt
+		s.mu.Lock()
+		manager := s.activeConversations[conversationID]
+		s.mu.Unlock()
+		if manager != nil && manager.IsAgentWorking() {
+			http.Error(w, "Conversation is busy", http.StatusConflict)
+			return
+		}
+
+		var err error
+		if remove {
+			err = s.db.RemoveConversationDirectory(ctx, conversationID, filepath.Clean(req.Path))
+		} else {
+			err = s.db.AddConversationDirectory(ctx, conversationID, filepath.Clean(req.Path))
+		}
+		if err != nil {
+			s.logger.Error("Failed to update conversation directories", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if manager != nil {
+			if err := manager.ReloadContext(); err != nil {
+				s.logger.Warn("Failed to reload conversation context", "conversationID", conversationID, "error", err)
+			}
+		}
+	}
+
+	dirs, err := s.db.ListConversationDirectories(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list conversation directories", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"directories": dirs})
+}
+
 // handleStreamConversation handles GET /conversation/<id>/stream
 func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
 	if r.Method != http.MethodGet {
@@ -1017,6 +1709,9 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 		if err != nil {
 			return err
 		}
+		if err := db.LoadMessageBlobs(ctx, q, messages); err != nil {
+			return err
+		}
 		conversation, err = q.GetConversation(ctx, conversationID)
 		return err
 	})
@@ -1032,9 +1727,14 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
 		return
 	}
+	defer manager.addStream(s.requestUser(r))()
 
 	// Send current messages, conversation data, and conversation state
+	reasoning := showReasoning(r)
 	apiMessages := toAPIMessages(messages)
+	if !reasoning {
+		apiMessages = withoutReasoning(apiMessages)
+	}
 	streamData := StreamResponse{
 		Messages:     apiMessages,
 		Conversation: conversation,
@@ -1042,8 +1742,12 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 			ConversationID: conversationID,
 			Working:        manager.IsAgentWorking(),
 			Model:          manager.GetModel(),
+			QueuePosition:  s.scheduler.position(conversationID),
+			Offline:        s.connectivity.isWaiting(conversationID),
+			Approval:       manager.pendingApproval(),
 		},
 		ContextWindowSize: calculateContextWindowSize(apiMessages),
+		Presence:          manager.presence(),
 	}
 	data, _ := json.Marshal(streamData)
 	fmt.Fprintf(w, "data: %s\n\n", data)
@@ -1060,6 +1764,9 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 		if !cont {
 			break
 		}
+		if !reasoning {
+			streamData.Messages = withoutReasoning(streamData.Messages)
+		}
 		// Always forward updates, even if only the conversation changed (e.g., slug added)
 		data, _ := json.Marshal(streamData)
 		fmt.Fprintf(w, "data: %s\n\n", data)
@@ -1080,17 +1787,30 @@ func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
 
 // ModelInfo represents a model in the API response
 type ModelInfo struct {
-	ID               string `json:"id"`
-	DisplayName      string `json:"display_name,omitempty"`
-	Ready            bool   `json:"ready"`
-	MaxContextTokens int    `json:"max_context_tokens,omitempty"`
+	ID               string        `json:"id"`
+	DisplayName      string        `json:"display_name,omitempty"`
+	Ready            bool          `json:"ready"`
+	MaxContextTokens int           `json:"max_context_tokens,omitempty"`
+	Provider         string        `json:"provider,omitempty"`
+	SupportsVision   bool          `json:"supports_vision"`
+	SupportsTools    bool          `json:"supports_tools"`
+	Pricing          *ModelPricing `json:"pricing,omitempty"`
+	// Available is the result of a live check against the provider, set only when requested with ?check=1
+	Available         *bool  `json:"available,omitempty"`
+	AvailabilityError string `json:"availability_error,omitempty"`
+}
+
+// ModelPricing is the list price of a model in USD per million tokens
+type ModelPricing struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
 }
 
 // getModelList returns the list of available models
 func (s *Server) getModelList() []ModelInfo {
 	var modelList []ModelInfo
 	if s.predictableOnly {
-		modelList = append(modelList, ModelInfo{ID: "predictable", Ready: true, MaxContextTokens: 200000})
+		modelList = append(modelList, ModelInfo{ID: "predictable", Ready: true, MaxContextTokens: 200000, Provider: string(models.ProviderBuiltIn), SupportsTools: true})
 	} else {
 		modelIDs := s.llmManager.GetAvailableModels()
 		for _, id := range modelIDs {
@@ -1104,9 +1824,15 @@ func (s *Server) getModelList() []ModelInfo {
 				maxCtx = svc.TokenContextWindow()
 			}
 			info := ModelInfo{ID: id, Ready: err == nil, MaxContextTokens: maxCtx}
-			// Add display name from model info
+			// Add display name and capabilities from model info
 			if modelInfo := s.llmManager.GetModelInfo(id); modelInfo != nil {
 				info.DisplayName = modelInfo.DisplayName
+				info.Provider = string(modelInfo.Provider)
+				info.SupportsVision = modelInfo.SupportsVision
+				info.SupportsTools = modelInfo.SupportsTools
+				if modelInfo.Pricing != nil {
+					info.Pricing = &ModelPricing{InputPerMTok: modelInfo.Pricing.InputPerMTok, OutputPerMTok: modelInfo.Pricing.OutputPerMTok}
+				}
 			}
 			modelList = append(modelList, info)
 		}
@@ -1114,14 +1840,19 @@ func (s *Server) getModelList() []ModelInfo {
 	return modelList
 }
 
-// handleModels returns the list of available models
+// handleModels returns the list of available models.
+// With ?check=1, each ready model is also checked live against its provider.
 func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	modelList := s.getModelList()
+	if check, _ := strconv.ParseBool(r.URL.Query().Get("check")); check {
+		s.checkModels(r.Context(), modelList)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(s.getModelList())
+	json.NewEncoder(w).Encode(modelList)
 }
 
 // handleArchivedConversations handles GET /api/conversations/archived
@@ -1379,6 +2110,9 @@ func (s *Server) handleExit(w http.ResponseWriter, r *http.Request) {
 	go func() {
 		time.Sleep(100 * time.Millisecond)
 		s.logger.Info("Exiting Shelley via /exit endpoint")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		s.checkpointTurns(ctx)
+		cancel()
 		os.Exit(0)
 	}()
 }
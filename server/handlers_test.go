@@ -419,3 +419,60 @@ func TestHandleWriteFile(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
 	}
 }
+
+func TestHandleConversationDraft(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.cleanup()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+
+	conv, err := h.db.CreateConversation(context.Background(), nil, true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	path := "/api/conversations/" + conv.ConversationID + "/draft"
+
+	getDraft := func() DraftResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var resp DraftResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return resp
+	}
+	putDraft := func(text string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(`{"text": "`+text+`"}`)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	}
+
+	if resp := getDraft(); resp.Text != "" || resp.UpdatedAt != nil {
+		t.Errorf("Expected no draft, got %+v", resp)
+	}
+
+	putDraft("half-written")
+	if resp := getDraft(); resp.Text != "half-written" || resp.UpdatedAt == nil {
+		t.Errorf("Expected saved draft, got %+v", resp)
+	}
+
+	putDraft("")
+	if resp := getDraft(); resp.Text != "" {
+		t.Errorf("Expected draft to be cleared, got %+v", resp)
+	}
+
+	// Unknown conversation
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/conversations/missing/draft", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+// Hook events. A failing before_tool hook blocks the tool call, and a failing
+// after_edit hook is sent back to the agent to fix before its turn ends;
+// failures of the other hooks are recorded in the conversation but do not stop the agent.
+//
+// after_edit hooks run after each round of tool calls in which the agent edited
+// files with the patch tool, and their failures are added to the round's tool
+// results. The edited files are listed one per line in SHELLEY_CHANGED_FILES,
+// so that e.g. "gofmt -l $SHELLEY_CHANGED_FILES" checks them.
+const (
+	HookEventConversationStart = "conversation_start"
+	HookEventBeforeTool        = "before_tool"
+	HookEventAfterEdit         = "after_edit"
+	HookEventAfterTurn         = "after_turn"
+)
+
+// hookTimeout bounds each hook command.
+const hookTimeout = time.Minute
+
+// HookRequest is the request body for adding or removing a conversation hook
+type HookRequest struct {
+	ID           int64  `json:"id,omitempty"` // for removal
+	Event        string `json:"event,omitempty"`
+	Command      string `json:"command,omitempty"`
+	InjectOutput bool   `json:"inject_output,omitempty"`
+}
+
+// HookAPI is the API representation of a conversation hook
+type HookAPI struct {
+	ID           int64  `json:"id"`
+	Event        string `json:"event"`
+	Command      string `json:"command"`
+	InjectOutput bool   `json:"inject_output"`
+}
+
+// validateHook checks that a hook can be added
+func validateHook(req HookRequest) error {
+	switch req.Event {
+	case HookEventConversationStart, HookEventBeforeTool, HookEventAfterEdit, HookEventAfterTurn:
+	default:
+		return fmt.Errorf("invalid hook event: %q", req.Event)
+	}
+	if strings.TrimSpace(req.Command) == "" {
+		return fmt.Errorf("hook command is required")
+	}
+	return nil
+}
+
+// handleConversationHooks handles GET /conversation/<id>/hooks, POST /conversation/<id>/hooks
+// and POST /conversation/<id>/hooks/remove
+func (s *Server) handleConversationHooks(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req HookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		remove := strings.HasSuffix(r.URL.Path, "/remove")
+		if !remove {
+			if err := validateHook(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Hooks are attached to the loop when it starts, so it is restarted
+		s.mu.Lock()
+		manager := s.activeConversations[conversationID]
+		s.mu.Unlock()
+		if manager != nil && manager.IsAgentWorking() {
+			http.Error(w, "Conversation is busy", http.StatusConflict)
+			return
+		}
+
+		var err error
+		if remove {
+			err = s.db.RemoveConversationHook(ctx, conversationID, req.ID)
+		} else {
+			_, err = s.db.AddConversationHook(ctx, generated.AddConversationHookParams{
+				ConversationID: conversationID,
+				Event:          req.Event,
+				Command:        req.Command,
+				InjectOutput:   req.InjectOutput,
+			})
+		}
+		if err != nil {
+			s.logger.Error("Failed to update conversation hooks", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if manager != nil {
+			if err := manager.ReloadContext(); err != nil {
+				s.logger.Warn("Failed to reload conversation context", "conversationID", conversationID, "error", err)
+			}
+		}
+	}
+
+	hooks, err := s.db.ListConversationHooks(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list conversation hooks", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	result := make([]HookAPI, 0, len(hooks))
+	for _, h := range hooks {
+		result = append(result, HookAPI{ID: h.HookID, Event: h.Event, Command: h.Command, InjectOutput: h.InjectOutput})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]HookAPI{"hooks": result})
+}
+
+// hasHooks reports whether any hook is configured for event.
+func hasHooks(hooks []generated.ConversationHook, event string) bool {
+	for _, h := range hooks {
+		if h.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// runHooks runs the hooks configured for event in order, stopping at the first failure.
+// It returns the output of the hooks that asked for it to be injected into the context.
+// Secret environment values are masked in both the output and the error.
+func (cm *ConversationManager) runHooks(ctx context.Context, event, dir string, extraEnv ...string) ([]llm.Content, error) {
+	cm.mu.Lock()
+	hooks := cm.hooks
+	env := append([]string(nil), cm.env...)
+	secrets := cm.secrets
+	conversationID := cm.conversationID
+	cm.mu.Unlock()
+
+	env = append(env, "SHELLEY_CONVERSATION_ID="+conversationID, "SHELLEY_HOOK_EVENT="+event)
+	env = append(env, extraEnv...)
+
+	var injected []llm.Content
+	for _, h := range hooks {
+		if h.Event != event {
+			continue
+		}
+		output, err := runCommands(ctx, dir, env, []string{h.Command}, hookTimeout)
+		output = maskString(output, secrets)
+		if err != nil {
+			return injected, fmt.Errorf("%s hook failed: %s\n\n%s", event, maskString(err.Error(), secrets), output)
+		}
+		if h.InjectOutput {
+			injected = append(injected, llm.Content{
+				Type: llm.ContentTypeText,
+				Text: fmt.Sprintf("<hook_output event=%q command=%q>\n%s</hook_output>", event, h.Command, output),
+			})
+		}
+	}
+	return injected, nil
+}
+
+// recordHookFailure records a failed hook as an error message in the conversation.
+func (cm *ConversationManager) recordHookFailure(ctx context.Context, err error) {
+	cm.logger.Warn("Hook failed", "error", err)
+	message := llm.Message{
+		Role:      llm.MessageRoleAssistant,
+		Content:   []llm.Content{{Type: llm.ContentTypeText, Text: err.Error()}},
+		ErrorType: llm.ErrorTypeHook,
+	}
+	if err := cm.recordMessage(ctx, message, llm.Usage{}); err != nil {
+		cm.logger.Error("failed to record hook failure", "error", err)
+	}
+}
+
+// addPendingContext queues content to be sent with the next user message.
+func (cm *ConversationManager) addPendingContext(content []llm.Content) {
+	if len(content) == 0 {
+		return
+	}
+	cm.mu.Lock()
+	cm.pendingContext = append(cm.pendingContext, content...)
+	cm.mu.Unlock()
+}
+
+// RunStartHooks runs the conversation_start hooks. It must be called before the
+// first user message is accepted so that injected output reaches the LLM with it.
+func (cm *ConversationManager) RunStartHooks(ctx context.Context) {
+	cm.mu.Lock()
+	run := hasHooks(cm.hooks, HookEventConversationStart)
+	cwd := cm.cwd
+	cm.mu.Unlock()
+	if !run {
+		return
+	}
+	content, err := cm.runHooks(ctx, HookEventConversationStart, cwd)
+	if err != nil {
+		cm.recordHookFailure(ctx, err)
+	}
+	cm.addPendingContext(content)
+}
+
+// beforeToolHook returns the loop callback that runs before_tool hooks in the
+// tools' current working directory.
+func (cm *ConversationManager) beforeToolHook(getWorkingDir func() string) loop.BeforeToolFunc {
+	return func(ctx context.Context, toolName string, input json.RawMessage) ([]llm.Content, error) {
+		return cm.runHooks(ctx, HookEventBeforeTool, getWorkingDir(),
+			"SHELLEY_TOOL_NAME="+toolName,
+			"SHELLEY_TOOL_INPUT="+string(input),
+		)
+	}
+}
+
+// afterTurnHook returns the loop callback that runs after_turn hooks. Injected
+// output is sent with the next user message.
+func (cm *ConversationManager) afterTurnHook(getWorkingDir func() string) loop.TurnEndFunc {
+	return func(ctx context.Context) {
+		content, err := cm.runHooks(ctx, HookEventAfterTurn, getWorkingDir())
+		if err != nil {
+			cm.recordHookFailure(ctx, err)
+		}
+		cm.addPendingContext(content)
+	}
+}
+
+// trackEdits wraps beforeTool, which may be nil, to record the files the patch
+// tool is asked to edit for the after_edit hooks.
+func (cm *ConversationManager) trackEdits(beforeTool loop.BeforeToolFunc, getWorkingDir func() string) loop.BeforeToolFunc {
+	return func(ctx context.Context, toolName string, input json.RawMessage) ([]llm.Content, error) {
+		if toolName == "patch" {
+			var patch struct {
+				Path string `json:"path"`
+			}
+			if json.Unmarshal(input, &patch) == nil && patch.Path != "" {
+				path := patch.Path
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(getWorkingDir(), path)
+				}
+				cm.mu.Lock()
+				if !slices.Contains(cm.editedFiles, path) {
+					cm.editedFiles = append(cm.editedFiles, path)
+				}
+				cm.editsUnchecked = true
+				cm.mu.Unlock()
+			}
+		}
+		if beforeTool == nil {
+			return nil, nil
+		}
+		return beforeTool(ctx, toolName, input)
+	}
+}
+
+// afterEditHook returns the loop callback that runs after_edit hooks on the
+// files edited during the turn. Failures are returned to the agent, which is
+// asked to fix them before finishing; the hooks run again only after further edits.
+func (cm *ConversationManager) afterEditHook(getWorkingDir func() string) loop.ToolCheckFunc {
+	return func(ctx context.Context) []llm.Content {
+		cm.mu.Lock()
+		unchecked := cm.editsUnchecked
+		var files []string
+		for _, f := range cm.editedFiles {
+			// Skip edits that failed to create the file
+			if _, err := os.Stat(f); err == nil {
+				files = append(files, f)
+			}
+		}
+		cm.editsUnchecked = false
+		cm.mu.Unlock()
+		if !unchecked || len(files) == 0 {
+			return nil
+		}
+
+		_, err := cm.runHooks(ctx, HookEventAfterEdit, getWorkingDir(), "SHELLEY_CHANGED_FILES="+strings.Join(files, "\n"))
+		if err == nil {
+			cm.mu.Lock()
+			cm.editedFiles = nil
+			cm.mu.Unlock()
+			return nil
+		}
+		cm.logger.Info("after_edit hook failed", "files", len(files))
+		return []llm.Content{{
+			Type: llm.ContentTypeText,
+			Text: fmt.Sprintf("<hook_output event=%q>\n%s\n</hook_output>\nThe checks run on the files you edited failed. Fix the problems above before finishing.", HookEventAfterEdit, strings.TrimSpace(err.Error())),
+		}}
+	}
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+func TestConversationHooks(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+
+	chatBody, _ := json.Marshal(ChatRequest{
+		Message: "bash: echo tool-ran",
+		Model:   "predictable",
+		Cwd:     dir,
+		Hooks: []HookRequest{
+			{Event: HookEventConversationStart, Command: "echo started-$SHELLEY_HOOK_EVENT", InjectOutput: true},
+			{Event: HookEventBeforeTool, Command: "echo $SHELLEY_TOOL_NAME >> tools.log; echo checked", InjectOutput: true},
+			{Event: HookEventAfterTurn, Command: "echo turn-done"},
+			{Event: HookEventAfterTurn, Command: "echo turn-summary", InjectOutput: true},
+		},
+	})
+	w := httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", bytes.NewReader(chatBody)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+	h.WaitResponse()
+	h.waitIdle()
+
+	// userTexts returns the text of every user message in the conversation
+	userTexts := func() []string {
+		t.Helper()
+		messages, err := h.db.ListMessages(context.Background(), h.convID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var texts []string
+		for _, m := range messages {
+			if m.Type != string(db.MessageTypeUser) || m.LlmData == nil {
+				continue
+			}
+			var msg llm.Message
+			json.Unmarshal([]byte(*m.LlmData), &msg)
+			var parts []string
+			for _, c := range msg.Content {
+				parts = append(parts, c.Text)
+				for _, r := range c.ToolResult {
+					parts = append(parts, r.Text)
+				}
+			}
+			texts = append(texts, strings.Join(parts, "\n"))
+		}
+		return texts
+	}
+
+	texts := userTexts()
+	if len(texts) < 2 {
+		t.Fatalf("expected prompt and tool result, got %q", texts)
+	}
+	if !strings.Contains(texts[0], "started-conversation_start") {
+		t.Errorf("expected start hook output in first message, got %q", texts[0])
+	}
+	if !strings.Contains(texts[1], "tool-ran") || !strings.Contains(texts[1], `<hook_output event="before_tool"`) {
+		t.Errorf("expected tool output and before_tool hook output, got %q", texts[1])
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "tools.log")); err != nil || strings.TrimSpace(string(data)) != "bash" {
+		t.Errorf("expected before_tool hook to see the tool name, got %q, %v", data, err)
+	}
+
+	// The after_turn hook runs once the turn has ended; its output goes with the next message
+	manager := h.server.activeConversations[h.convID]
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		manager.mu.Lock()
+		pending := len(manager.pendingContext)
+		manager.mu.Unlock()
+		if pending > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for after_turn hook")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.Chat("echo: next")
+	h.WaitResponse()
+	h.waitIdle()
+	texts = userTexts()
+	last := texts[len(texts)-1]
+	if !strings.Contains(last, "turn-summary") || strings.Contains(last, "turn-done") || !strings.Contains(last, "echo: next") {
+		t.Errorf("expected only injected after_turn output with the next message, got %q", last)
+	}
+
+	list := func(w *httptest.ResponseRecorder) []HookAPI {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp map[string][]HookAPI
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp["hooks"]
+	}
+
+	if w := h.post("/hooks", HookRequest{Event: "sometimes", Command: "true"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid event, got %d", w.Code)
+	}
+
+	// Replace the hooks with one that blocks every tool call
+	for _, hook := range list(h.post("/hooks/remove", HookRequest{ID: 0})) {
+		list(h.post("/hooks/remove", HookRequest{ID: hook.ID}))
+	}
+	hooks := list(h.post("/hooks", HookRequest{Event: HookEventBeforeTool, Command: "echo not allowed; exit 1"}))
+	if len(hooks) != 1 || hooks[0].Event != HookEventBeforeTool {
+		t.Fatalf("unexpected hooks: %+v", hooks)
+	}
+
+	h.Chat("bash: touch blocked-file")
+	h.WaitResponse()
+	texts = userTexts()
+	if last := texts[len(texts)-1]; !strings.Contains(last, "before_tool hook failed") || !strings.Contains(last, "not allowed") {
+		t.Errorf("expected blocked tool result, got %q", last)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blocked-file")); !os.IsNotExist(err) {
+		t.Errorf("expected blocked tool not to run, stat error: %v", err)
+	}
+}
+
+func TestAfterEditHook(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	os.WriteFile(file, []byte("an example\n"), 0o644)
+
+	chatBody, _ := json.Marshal(ChatRequest{
+		Message: "patch: " + file,
+		Model:   "predictable",
+		Cwd:     dir,
+		Hooks: []HookRequest{
+			// Fails on the patched content, like a linter rejecting an edit
+			{Event: HookEventAfterEdit, Command: `echo "$SHELLEY_CHANGED_FILES" >> checked.log; ! grep -H updated $SHELLEY_CHANGED_FILES`},
+		},
+	})
+	w := httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", bytes.NewReader(chatBody)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+	h.WaitResponse()
+	h.waitIdle()
+
+	messages, err := h.db.ListMessages(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var feedback []string
+	for _, m := range messages {
+		if m.Type != string(db.MessageTypeUser) || m.LlmData == nil {
+			continue
+		}
+		var msg llm.Message
+		json.Unmarshal([]byte(*m.LlmData), &msg)
+		// The feedback is added to the results of the edits
+		for _, c := range msg.Content {
+			for _, r := range c.ToolResult {
+				if strings.Contains(r.Text, `<hook_output event="after_edit">`) {
+					feedback = append(feedback, r.Text)
+				}
+			}
+		}
+	}
+	if len(feedback) != 1 {
+		t.Fatalf("expected one after_edit feedback in the tool results, got %q", feedback)
+	}
+	if !strings.Contains(feedback[0], "notes.txt:an updated example") || !strings.Contains(feedback[0], "after_edit hook failed") {
+		t.Errorf("expected the hook failure output, got %q", feedback[0])
+	}
+
+	// Without further edits the hook does not run again
+	data, err := os.ReadFile(filepath.Join(dir, "checked.log"))
+	if err != nil || strings.TrimSpace(string(data)) != file {
+		t.Errorf("expected one check of %s, got %q, %v", file, data, err)
+	}
+
+	// Turns without edits do not run the hook
+	h.Chat("echo: no edits")
+	h.WaitResponse()
+	h.waitIdle()
+	if data, _ := os.ReadFile(filepath.Join(dir, "checked.log")); strings.Count(string(data), file) != 1 {
+		t.Errorf("expected no further checks, got %q", data)
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// HTTPToolRequest is the request body for creating or updating an HTTP tool
+type HTTPToolRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+	URL         string          `json:"url"`
+	// Headers replace the tool's headers; on update, omitting them keeps the
+	// current ones.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// HTTPToolAPI is the API representation of an HTTP tool. Header values may
+// hold credentials, so they are kept in the secrets store and only their
+// names are returned.
+type HTTPToolAPI struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+	URL         string          `json:"url"`
+	HeaderNames []string        `json:"header_names"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+func toHTTPToolAPI(t generated.HttpTool) HTTPToolAPI {
+	var headers map[string]string
+	json.Unmarshal([]byte(t.Headers), &headers)
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return HTTPToolAPI{
+		ID:          t.ToolID,
+		Name:        t.Name,
+		Description: t.Description,
+		InputSchema: json.RawMessage(t.InputSchema),
+		URL:         t.Url,
+		HeaderNames: names,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
+
+// loadHTTPTools returns the user-defined HTTP tools for a conversation's tool set.
+func loadHTTPTools(ctx context.Context, database *db.DB) ([]*claudetool.HTTPTool, error) {
+	rows, err := database.ListHTTPTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]*claudetool.HTTPTool, 0, len(rows))
+	for _, row := range rows {
+		headers, err := database.HTTPToolHeaders(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, &claudetool.HTTPTool{
+			Name:        row.Name,
+			Description: row.Description,
+			InputSchema: json.RawMessage(row.InputSchema),
+			URL:         row.Url,
+			Headers:     headers,
+		})
+	}
+	return tools, nil
+}
+
+// validateHTTPTool checks an HTTP tool definition
+func validateHTTPTool(req HTTPToolRequest) error {
+	if !llm.ValidToolName(req.Name) {
+		return fmt.Errorf("tool name must be 1-64 letters, digits, underscores or hyphens")
+	}
+	if strings.TrimSpace(req.Description) == "" {
+		return fmt.Errorf("tool description is required")
+	}
+	var schema struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(req.InputSchema, &schema); err != nil || schema.Type != "object" {
+		return fmt.Errorf("input_schema must be a JSON schema of type object")
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	return nil
+}
+
+// handleHTTPTools handles /api/http-tools: GET lists the tools, POST creates
+// one. A loop keeps the tools it started with, so idle conversations are
+// reloaded to have the change from their next turn; busy ones keep their
+// tools until they are reloaded. A tool named like a built-in tool is left
+// out.
+func (s *Server) handleHTTPTools(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		tools, err := s.db.ListHTTPTools(ctx)
+		if err != nil {
+			s.logger.Error("Failed to list HTTP tools", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		result := make([]HTTPToolAPI, 0, len(tools))
+		for _, tool := range tools {
+			result = append(result, toHTTPToolAPI(tool))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	case http.MethodPost:
+		var req HTTPToolRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateHTTPTool(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tool, err := s.db.CreateHTTPTool(ctx, generated.CreateHTTPToolParams{
+			Name:        req.Name,
+			Description: req.Description,
+			InputSchema: string(req.InputSchema),
+			Url:         req.URL,
+		}, req.Headers)
+		if err != nil {
+			s.httpToolWriteError(w, err)
+			return
+		}
+		s.reloadIdleConversations()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(toHTTPToolAPI(*tool))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleHTTPTool handles /api/http-tools/{id} (GET, PUT, DELETE).
+func (s *Server) handleHTTPTool(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/http-tools/"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid tool ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tool, err := s.db.GetHTTPTool(ctx, id)
+		if err != nil {
+			s.httpToolWriteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toHTTPToolAPI(*tool))
+	case http.MethodPut:
+		var req HTTPToolRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateHTTPTool(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tool, err := s.db.UpdateHTTPTool(ctx, generated.UpdateHTTPToolParams{
+			Name:        req.Name,
+			Description: req.Description,
+			InputSchema: string(req.InputSchema),
+			Url:         req.URL,
+			ToolID:      id,
+		}, req.Headers)
+		if err != nil {
+			s.httpToolWriteError(w, err)
+			return
+		}
+		s.reloadIdleConversations()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toHTTPToolAPI(*tool))
+	case http.MethodDelete:
+		if err := s.db.DeleteHTTPTool(ctx, id); err != nil {
+			s.httpToolWriteError(w, err)
+			return
+		}
+		s.reloadIdleConversations()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// httpToolWriteError maps an HTTP tool database error to a response
+func (s *Server) httpToolWriteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Tool not found", http.StatusNotFound)
+	case strings.Contains(err.Error(), "UNIQUE constraint failed"):
+		http.Error(w, "A tool with this name already exists", http.StatusConflict)
+	default:
+		s.logger.Error("Failed to save HTTP tool", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
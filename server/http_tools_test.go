@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"shelley.exe.dev/loop"
+)
+
+func TestHTTPTools(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	var mu sync.Mutex
+	var gotBody, gotAuth, gotConversation string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		gotBody, gotAuth, gotConversation = string(body), r.Header.Get("Authorization"), r.Header.Get("X-Shelley-Conversation-Id")
+		mu.Unlock()
+		fmt.Fprint(w, `{"status": "shipped"}`)
+	}))
+	defer endpoint.Close()
+
+	request := func(method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, bytes.NewReader(data))
+		if path == "/api/http-tools" {
+			h.server.handleHTTPTools(w, r)
+		} else {
+			h.server.handleHTTPTool(w, r)
+		}
+		return w
+	}
+
+	tool := HTTPToolRequest{
+		Name:        "order_status",
+		Description: "Look up the status of an order",
+		InputSchema: json.RawMessage(`{"type": "object", "properties": {"order": {"type": "string"}}}`),
+		URL:         endpoint.URL,
+		Headers:     map[string]string{"Authorization": "Bearer secret"},
+	}
+	bad := tool
+	bad.Name = "order status"
+	if w := request("POST", "/api/http-tools", bad); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid name, got %d", w.Code)
+	}
+	bad = tool
+	bad.URL = "file:///etc/passwd"
+	if w := request("POST", "/api/http-tools", bad); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-HTTP URL, got %d", w.Code)
+	}
+
+	w := request("POST", "/api/http-tools", tool)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created HTTPToolAPI
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if strings.Contains(w.Body.String(), "secret") || !reflect.DeepEqual(created.HeaderNames, []string{"Authorization"}) {
+		t.Errorf("expected only header names in the response, got %s", w.Body.String())
+	}
+	if w := request("POST", "/api/http-tools", tool); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a duplicate name, got %d", w.Code)
+	}
+
+	// Updating without headers keeps them
+	update := tool
+	update.Description = "Look up an order"
+	update.Headers = nil
+	if w := request("PUT", fmt.Sprintf("/api/http-tools/%d", created.ID), update); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Authorization") {
+		t.Errorf("expected the update to keep the headers, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The model can call the tool in a conversation
+	h.Script("where is my order?",
+		loop.ScriptToolCall("order_status", map[string]string{"order": "A-1"}),
+		loop.ScriptText("It has shipped."),
+	)
+	h.NewConversation("where is my order?", "")
+	if result := h.WaitToolResult(); result != `{"status": "shipped"}` {
+		t.Errorf("expected the endpoint's response, got %q", result)
+	}
+	h.WaitResponse()
+	mu.Lock()
+	if gotBody != `{"order":"A-1"}` || gotAuth != "Bearer secret" || gotConversation != h.ConversationID() {
+		t.Errorf("unexpected call: body %q, auth %q, conversation %q", gotBody, gotAuth, gotConversation)
+	}
+	mu.Unlock()
+	var sent bool
+	for _, req := range h.llm.GetRecentRequests() {
+		for _, tool := range req.Tools {
+			sent = sent || (tool.Name == "order_status" && tool.Description == "Look up an order")
+		}
+	}
+	if !sent {
+		t.Error("expected the tool to be sent to the model")
+	}
+
+	h.waitIdle()
+	if w := request("DELETE", fmt.Sprintf("/api/http-tools/%d", created.ID), nil); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+	if w := request("GET", fmt.Sprintf("/api/http-tools/%d", created.ID), nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", w.Code)
+	}
+
+	// The idle conversation's next turn no longer has the tool
+	h.Chat("echo: again")
+	h.WaitResponse()
+	requests := h.llm.GetRecentRequests()
+	for _, tool := range requests[len(requests)-1].Tools {
+		if tool.Name == "order_status" {
+			t.Error("expected the deleted tool to be gone from the next turn")
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdleCleanup(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	h.waitIdle()
+
+	active := func() *ConversationManager {
+		h.server.mu.Lock()
+		defer h.server.mu.Unlock()
+		return h.server.activeConversations[h.convID]
+	}
+	manager := active()
+	if manager == nil {
+		t.Fatal("expected an active conversation manager")
+	}
+
+	// Nothing is released before the timeout
+	h.server.Cleanup()
+	if active() != manager {
+		t.Fatal("expected the conversation to be kept before the idle timeout")
+	}
+
+	// A streamed conversation keeps its manager but releases its loop
+	h.server.SetIdleTimeout(time.Nanosecond)
+	removeStream := manager.addStream("")
+	time.Sleep(time.Millisecond)
+	h.server.Cleanup()
+	if active() != manager {
+		t.Fatal("expected a streamed conversation to keep its manager")
+	}
+	if _, running, _ := manager.idleState(0); running {
+		t.Error("expected the loop of an idle conversation to be stopped")
+	}
+	removeStream()
+	time.Sleep(time.Millisecond)
+	h.server.Cleanup()
+	if active() != nil {
+		t.Fatal("expected the idle conversation to be dropped")
+	}
+
+	// The next message revives the conversation with its history
+	h.server.SetIdleTimeout(0)
+	h.Chat("echo: second")
+	if got := h.WaitResponse(); got != "second" {
+		t.Errorf("expected the revived conversation to answer, got %q", got)
+	}
+	requests := h.llm.GetRecentRequests()
+	last := requests[len(requests)-1]
+	var texts []string
+	for _, msg := range last.Messages {
+		for _, content := range msg.Content {
+			texts = append(texts, content.Text)
+		}
+	}
+	if !strings.Contains(strings.Join(texts, "\n"), "echo: first") {
+		t.Errorf("expected the earlier history to be sent after revival, got %v", texts)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errConversationLeased is returned when another instance sharing the
+// database drives the conversation's agent loop.
+var errConversationLeased = errors.New("conversation is running on another instance")
+
+// conversationLeaseTTL is how long a lease lasts unless renewed. An instance
+// that stops without releasing its leases holds them for at most this long.
+const conversationLeaseTTL = 30 * time.Second
+
+// SetInstanceID names this instance when several share the database. Each
+// conversation's agent loop then runs on one instance at a time, which holds
+// a lease on the conversation while the loop runs. Empty disables leasing.
+func (s *Server) SetInstanceID(id string) {
+	s.instanceID = id
+}
+
+// acquireLease takes the conversation's lease before its loop starts. As
+// another instance may have driven the conversation since it was loaded, it
+// is loaded again from the database.
+func (cm *ConversationManager) acquireLease(ctx context.Context) error {
+	cm.mu.Lock()
+	running := cm.loop != nil
+	cm.mu.Unlock()
+	if cm.leaseHolder == "" || running {
+		return nil
+	}
+	ok, err := cm.db.AcquireConversationLease(ctx, cm.conversationID, cm.leaseHolder, conversationLeaseTTL)
+	if err != nil {
+		return fmt.Errorf("failed to acquire conversation lease: %w", err)
+	}
+	if !ok {
+		return errConversationLeased
+	}
+	cm.mu.Lock()
+	cm.hydrated = false
+	cm.mu.Unlock()
+	return nil
+}
+
+// renewLease keeps the conversation's lease while the loop of ctx runs. If
+// another instance took the lease over, as it expired while this one was
+// unable to renew it, the loop is stopped.
+func (cm *ConversationManager) renewLease(ctx context.Context) {
+	ticker := time.NewTicker(conversationLeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := cm.db.AcquireConversationLease(ctx, cm.conversationID, cm.leaseHolder, conversationLeaseTTL)
+		if err != nil {
+			if ctx.Err() == nil {
+				cm.logger.Warn("Failed to renew conversation lease", "error", err)
+			}
+			continue
+		}
+		if ok {
+			continue
+		}
+		cm.mu.Lock()
+		current := cm.loopCtx == ctx
+		cm.mu.Unlock()
+		if current {
+			cm.logger.Error("Conversation lease was taken over by another instance; stopping the loop")
+			cm.stopLoop()
+			cm.SetAgentWorking(false)
+			cm.mu.Lock()
+			cm.hydrated = false
+			cm.mu.Unlock()
+		}
+		return
+	}
+}
+
+// releaseLease gives up the conversation's lease once its loop stopped.
+func (cm *ConversationManager) releaseLease() {
+	if cm.leaseHolder == "" {
+		return
+	}
+	if err := cm.db.ReleaseConversationLease(context.Background(), cm.conversationID, cm.leaseHolder); err != nil {
+		cm.logger.Warn("Failed to release conversation lease", "error", err)
+	}
+}
+
+// leasedElsewhere reports whether another instance holds an unexpired lease
+// on a conversation.
+func (s *Server) leasedElsewhere(ctx context.Context, conversationID string) (bool, error) {
+	if s.instanceID == "" {
+		return false, nil
+	}
+	lease, err := s.db.GetConversationLease(ctx, conversationID)
+	if err != nil || lease == nil {
+		return false, err
+	}
+	return lease.Holder != s.instanceID && lease.ExpiresAt.After(time.Now()), nil
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConversationLeases(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	ctx := t.Context()
+	h.server.SetInstanceID("a")
+
+	// A second instance sharing the database
+	s := h.server
+	other := NewServer(h.db, s.llmManager, s.toolSetConfig, s.logger, true, "", "predictable", "", nil)
+	other.SetInstanceID("b")
+	chat := func(srv *Server, msg string) int {
+		t.Helper()
+		data, _ := json.Marshal(ChatRequest{Message: msg, Model: "predictable"})
+		w := httptest.NewRecorder()
+		srv.handleChatConversation(w, httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/chat", strings.NewReader(string(data))), h.convID)
+		return w.Code
+	}
+	holder := func() string {
+		t.Helper()
+		lease, err := h.db.GetConversationLease(ctx, h.convID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lease == nil || lease.ExpiresAt.Before(time.Now()) {
+			return ""
+		}
+		return lease.Holder
+	}
+
+	// The instance running the conversation's loop holds its lease
+	h.NewConversation("echo: one", "")
+	h.WaitResponse()
+	h.waitIdle()
+	if got := holder(); got != "a" {
+		t.Fatalf("lease holder = %q, want a", got)
+	}
+	if code := chat(other, "echo: two"); code != http.StatusConflict {
+		t.Fatalf("chat on the other instance: status %d, want 409", code)
+	}
+
+	// Once the loop stops, another instance can take the conversation over
+	manager, err := h.server.getOrCreateConversationManager(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.ReloadContext(); err != nil {
+		t.Fatal(err)
+	}
+	if got := holder(); got != "" {
+		t.Fatalf("lease holder after stopping the loop = %q", got)
+	}
+	if code := chat(other, "echo: two"); code != http.StatusAccepted {
+		t.Fatalf("chat on the other instance: status %d", code)
+	}
+	if got := h.WaitResponse(); got != "two" {
+		t.Errorf("response = %q, want two", got)
+	}
+	if got := holder(); got != "b" {
+		t.Errorf("lease holder = %q, want b", got)
+	}
+	if code := chat(h.server, "echo: three"); code != http.StatusConflict {
+		t.Errorf("chat on the first instance: status %d, want 409", code)
+	}
+
+	otherManager, err := other.getOrCreateConversationManager(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(h.timeout)
+	for otherManager.ReloadContext() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("other instance still working")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
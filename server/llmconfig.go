@@ -36,6 +36,15 @@ type LLMConfig struct {
 	// Links are custom links to be displayed in the UI (optional)
 	Links []Link
 
+	// ThinkingBudgets maps model IDs to their extended thinking budget in tokens (optional)
+	ThinkingBudgets map[string]int
+
+	// PromptOverrides maps model IDs to changes of their system prompt (optional)
+	PromptOverrides map[string]PromptOverride
+
+	// ResponseCache caches the responses to deterministic (temperature 0) requests in DB (optional)
+	ResponseCache bool
+
 	// DB is the database for recording LLM requests (optional)
 	DB *db.DB
 
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// handleMemories handles /api/memories: GET lists the memories (those that
+// apply to ?cwd= when given), DELETE ?id= forgets one.
+func (s *Server) handleMemories(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		var memories []generated.Memory
+		var err error
+		if cwd := r.URL.Query().Get("cwd"); cwd != "" {
+			memories, err = s.db.ListMemoriesForDirectory(ctx, cwd)
+		} else {
+			memories, err = s.db.ListMemories(ctx)
+		}
+		if err != nil {
+			s.logger.Error("Failed to list memories", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(memories)
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid memory ID", http.StatusBadRequest)
+			return
+		}
+		memory, err := s.db.DeleteMemory(ctx, id)
+		if err != nil {
+			s.logger.Error("Failed to delete memory", "id", id, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if memory == nil {
+			http.Error(w, "Memory not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
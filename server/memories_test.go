@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db/generated"
+)
+
+func TestMemories(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	// systemFor starts a conversation in dir and returns the system prompt sent to the LLM
+	systemFor := func(dir string) string {
+		t.Helper()
+		h.NewConversation("echo: hi", dir)
+		h.WaitResponse()
+		return h.systemPrompt()
+	}
+
+	project := t.TempDir()
+	h.NewConversation("remember: tests run with make check", project)
+	if result := h.WaitToolResult(); !strings.Contains(result, "Remembered as memory") {
+		t.Fatalf("unexpected tool result: %q", result)
+	}
+	if _, err := h.db.CreateMemory(context.Background(), "", "the user prefers short answers"); err != nil {
+		t.Fatal(err)
+	}
+
+	system := systemFor(project)
+	if !strings.Contains(system, "tests run with make check") || !strings.Contains(system, "the user prefers short answers") {
+		t.Errorf("expected project and global memories in system prompt, got %q", system)
+	}
+	system = systemFor(t.TempDir())
+	if strings.Contains(system, "tests run with make check") || !strings.Contains(system, "the user prefers short answers") {
+		t.Errorf("expected only global memory in another directory, got %q", system)
+	}
+
+	list := func(query string) []generated.Memory {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.handleMemories(w, httptest.NewRequest("GET", "/api/memories"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var memories []generated.Memory
+		json.Unmarshal(w.Body.Bytes(), &memories)
+		return memories
+	}
+	memories := list("?cwd=" + project)
+	if len(memories) != 2 || memories[0].Directory != project {
+		t.Fatalf("unexpected memories: %+v", memories)
+	}
+
+	w := httptest.NewRecorder()
+	h.server.handleMemories(w, httptest.NewRequest("DELETE", fmt.Sprintf("/api/memories?id=%d", memories[0].MemoryID), nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if memories := list(""); len(memories) != 1 {
+		t.Errorf("expected one memory after delete, got %+v", memories)
+	}
+	w = httptest.NewRecorder()
+	h.server.handleMemories(w, httptest.NewRequest("DELETE", fmt.Sprintf("/api/memories?id=%d", memories[0].MemoryID), nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for deleted memory, got %d", w.Code)
+	}
+}
@@ -28,8 +28,8 @@ func LoggerMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 func CSRFMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Only check state-changing methods
-			if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodDelete {
+			// Only check state-changing methods from browsers; API tokens aren't sent automatically
+			if apiTokenFromContext(r.Context()) == nil && (r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodDelete) {
 				// Require X-Shelley-Request header (value doesn't matter, just presence)
 				if r.Header.Get("X-Shelley-Request") == "" {
 					http.Error(w, "CSRF protection: X-Shelley-Request header required", http.StatusForbidden)
@@ -46,8 +46,8 @@ func CSRFMiddleware() func(http.Handler) http.Handler {
 func RequireHeaderMiddleware(headerName string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Only check API routes
-			if strings.HasPrefix(r.URL.Path, "/api/") {
+			// Only check API routes; requests with an API token act as its owner
+			if strings.HasPrefix(r.URL.Path, "/api/") && apiTokenFromContext(r.Context()) == nil {
 				if r.Header.Get(headerName) == "" {
 					http.Error(w, "missing required header: "+headerName, http.StatusForbidden)
 					return
@@ -58,6 +58,15 @@ func RequireHeaderMiddleware(headerName string) func(http.Handler) http.Handler
 	}
 }
 
+// requestUser returns the user making a request, who owns what it creates:
+// the value of the required identity header, or "" when the server has none.
+func (s *Server) requestUser(r *http.Request) string {
+	if s.requireHeader == "" {
+		return ""
+	}
+	return r.Header.Get(s.requireHeader)
+}
+
 // gzipResponseWriter wraps http.ResponseWriter to compress responses
 type gzipResponseWriter struct {
 	http.ResponseWriter
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// modelCheckTTL is how long the result of a live model check is reused
+	modelCheckTTL = 5 * time.Minute
+	// modelCheckTimeout bounds a single live model check
+	modelCheckTimeout = 30 * time.Second
+)
+
+// ModelChecker is implemented by LLM providers that can verify a model against its provider
+type ModelChecker interface {
+	CheckModel(ctx context.Context, modelID string) error
+}
+
+type modelCheck struct {
+	err       error
+	checkedAt time.Time
+}
+
+// modelChecks caches live model checks so that refreshing the model list doesn't hit every provider
+type modelChecks struct {
+	mu      sync.Mutex
+	results map[string]modelCheck
+}
+
+func (c *modelChecks) check(ctx context.Context, checker ModelChecker, modelID string) error {
+	c.mu.Lock()
+	result, ok := c.results[modelID]
+	c.mu.Unlock()
+	if ok && time.Since(result.checkedAt) < modelCheckTTL {
+		return result.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, modelCheckTimeout)
+	defer cancel()
+	err := checker.CheckModel(ctx, modelID)
+	if err != nil && ctx.Err() == context.Canceled {
+		// The client went away; don't cache a result that says nothing about the model
+		return err
+	}
+
+	c.mu.Lock()
+	if c.results == nil {
+		c.results = make(map[string]modelCheck)
+	}
+	c.results[modelID] = modelCheck{err: err, checkedAt: time.Now()}
+	c.mu.Unlock()
+	return err
+}
+
+// reset drops all cached results
+func (c *modelChecks) reset() {
+	c.mu.Lock()
+	c.results = nil
+	c.mu.Unlock()
+}
+
+// checkModels sets the availability of each model in modelList, checking ready models
+// concurrently against their providers.
+func (s *Server) checkModels(ctx context.Context, modelList []ModelInfo) {
+	checker, canCheck := s.llmManager.(ModelChecker)
+	var wg sync.WaitGroup
+	for i := range modelList {
+		info := &modelList[i]
+		if !info.Ready || !canCheck {
+			available := info.Ready
+			info.Available = &available
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.modelChecks.check(ctx, checker, info.ID)
+			available := err == nil
+			info.Available = &available
+			if err != nil {
+				info.AvailabilityError = err.Error()
+				s.logger.Warn("Model check failed", "model", info.ID, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/models"
+)
+
+func TestModelCatalog(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	llmManager, err := models.NewManager(&models.Config{})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	server := NewServer(database, llmManager, claudetool.ToolSetConfig{}, slog.Default(), true, "", "predictable", "", nil)
+	mux := http.NewServeMux()
+	server.RegisterRoutes(mux)
+
+	getModels := func(query string) []ModelInfo {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/models"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var list []ModelInfo
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(list) != 1 || list[0].ID != "predictable" {
+			t.Fatalf("expected the predictable model, got %+v", list)
+		}
+		return list
+	}
+
+	info := getModels("")[0]
+	if !info.Ready || !info.SupportsTools || info.Provider != string(models.ProviderBuiltIn) || info.MaxContextTokens == 0 {
+		t.Errorf("unexpected model info: %+v", info)
+	}
+	if info.Available != nil {
+		t.Errorf("expected no live check without ?check=1, got %v", *info.Available)
+	}
+
+	info = getModels("?check=1")[0]
+	if info.Available == nil || !*info.Available || info.AvailabilityError != "" {
+		t.Errorf("expected the model to pass the live check, got %+v", info)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// NotificationPreferencesAPI is the response of the notification preferences endpoints.
+type NotificationPreferencesAPI struct {
+	// Preferences are the saved preferences; events they omit are inherited.
+	Preferences NotificationPreferences `json:"preferences"`
+	// Effective are the preferences in effect, including inherited ones.
+	Effective NotificationPreferences `json:"effective"`
+	Events    []string                `json:"events"`
+	// Channels are the channels the server is configured to send to for the
+	// user.
+	Channels []string `json:"channels"`
+}
+
+// handleNotificationPreferences handles GET and POST of the user's notification
+// preferences: their defaults when conversationID is "", at
+// /api/notification-preferences, or a conversation's at
+// /api/conversation/<id>/notifications. POST takes {"preferences": {...}}
+// and replaces the saved preferences; null preferences delete them.
+func (s *Server) handleNotificationPreferences(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	owner := s.requestUser(r)
+
+	if conversationID != "" {
+		if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Preferences NotificationPreferences `json:"preferences"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := req.Preferences.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		if req.Preferences == nil {
+			err = s.db.DeleteNotificationPreferences(ctx, owner, conversationID)
+		} else {
+			data, _ := json.Marshal(req.Preferences)
+			err = s.db.SaveNotificationPreferences(ctx, generated.UpsertNotificationPreferencesParams{
+				Owner:          owner,
+				ConversationID: conversationID,
+				Preferences:    string(data),
+			})
+		}
+		if err != nil {
+			s.logger.Error("Failed to save notification preferences", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	prefs, err := s.loadNotificationPreferences(ctx, owner, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to load notification preferences", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	effective, err := s.effectiveNotificationPreferences(ctx, owner, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to load notification preferences", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(NotificationPreferencesAPI{
+		Preferences: prefs,
+		Effective:   effective,
+		Events:      notificationEvents,
+		Channels:    s.configuredNotificationChannels(owner),
+	})
+}
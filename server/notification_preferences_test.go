@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/email"
+	"shelley.exe.dev/webhook"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	posted := make(chan NotificationWebhookPayload, 10)
+	hookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload NotificationWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		posted <- payload
+	}))
+	defer hookServer.Close()
+	sender, err := webhook.NewSender(webhook.Config{URL: hookServer.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.server.SetNotificationWebhooks(NotificationWebhooks{BaseURL: "https://shelley.example.com/", Webhook: sender})
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	request := func(method, path, body string) NotificationPreferencesAPI {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body.String())
+		}
+		var resp NotificationPreferencesAPI
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	resp := request("GET", "/api/notification-preferences", "")
+	if resp.Preferences != nil || !slices.Equal(resp.Effective[EventTurnComplete], defaultNotificationPreferences[EventTurnComplete]) {
+		t.Errorf("preferences before saving = %+v", resp)
+	}
+	if !slices.Equal(resp.Channels, []string{ChannelWebhook}) {
+		t.Errorf("channels = %v, want only the webhook", resp.Channels)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/notification-preferences", strings.NewReader(`{"preferences": {"turn_complete": ["pager"]}}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("saving an unknown channel: status %d, want 400", w.Code)
+	}
+
+	// The user turns off notifications of completed turns by default...
+	resp = request("POST", "/api/notification-preferences", `{"preferences": {"turn_complete": []}}`)
+	if len(resp.Effective[EventTurnComplete]) != 0 || len(resp.Effective[EventError]) == 0 {
+		t.Errorf("effective preferences = %v", resp.Effective)
+	}
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	h.waitIdle()
+
+	// ...but turns them on for this conversation
+	resp = request("POST", "/api/conversation/"+h.convID+"/notifications", `{"preferences": {"turn_complete": ["webhook"]}}`)
+	if !slices.Equal(resp.Effective[EventTurnComplete], []string{ChannelWebhook}) {
+		t.Errorf("conversation's effective preferences = %v", resp.Effective)
+	}
+	h.Chat("echo: second")
+	h.WaitResponse()
+	select {
+	case payload := <-posted:
+		if payload.Event != EventTurnComplete || payload.Text != "second" || payload.ConversationID != h.convID {
+			t.Errorf("webhook payload = %+v", payload)
+		}
+		if !strings.HasPrefix(payload.URL, "https://shelley.example.com/c/") {
+			t.Errorf("webhook URL = %q", payload.URL)
+		}
+	case <-time.After(h.timeout):
+		t.Fatal("turn end was not posted to the webhook")
+	}
+
+	// Deleting the conversation's preferences inherits the user's again
+	resp = request("POST", "/api/conversation/"+h.convID+"/notifications", `{"preferences": null}`)
+	if resp.Preferences != nil || len(resp.Effective[EventTurnComplete]) != 0 {
+		t.Errorf("preferences after deleting = %+v", resp)
+	}
+}
+
+func TestEmailNotificationsAdminOnly(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	notifier, err := email.NewNotifier(email.Config{Host: "smtp.example.com", From: "shelley@example.com", To: []string{"admin@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.server.SetEmailNotifier(notifier)
+	if !slices.Contains(h.server.configuredNotificationChannels(""), ChannelEmail) {
+		t.Error("expected email without an identity header, where everyone is an admin")
+	}
+
+	// Email goes to the admins' addresses, so other users can't use it
+	h.server.requireHeader = "X-Exedev-Userid"
+	h.server.SetAdmins([]string{"admin"})
+	if !slices.Contains(h.server.configuredNotificationChannels("admin"), ChannelEmail) {
+		t.Error("expected email for an admin")
+	}
+	if slices.Contains(h.server.configuredNotificationChannels("alice"), ChannelEmail) {
+		t.Error("expected no email for a user who isn't an admin")
+	}
+}
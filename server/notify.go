@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/webhook"
+)
+
+// Notification events.
+const (
+	EventTurnComplete   = "turn_complete"
+	EventError          = "error"
+	EventApprovalNeeded = "approval_needed"
+)
+
+// Notification channels.
+const (
+	ChannelPush    = "push"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelSlack   = "slack"
+)
+
+var (
+	notificationEvents   = []string{EventTurnComplete, EventError, EventApprovalNeeded}
+	notificationChannels = []string{ChannelPush, ChannelEmail, ChannelWebhook, ChannelSlack}
+)
+
+// NotificationPreferences maps events to the channels notifying of them.
+type NotificationPreferences map[string][]string
+
+// defaultNotificationPreferences apply to events the user has no preferences for.
+var defaultNotificationPreferences = NotificationPreferences{
+	EventTurnComplete:   {ChannelPush, ChannelWebhook},
+	EventError:          {ChannelPush, ChannelWebhook},
+	EventApprovalNeeded: {ChannelPush, ChannelEmail, ChannelWebhook, ChannelSlack},
+}
+
+func (p NotificationPreferences) validate() error {
+	for event, channels := range p {
+		if !slices.Contains(notificationEvents, event) {
+			return fmt.Errorf("unknown notification event %q", event)
+		}
+		for _, channel := range channels {
+			if !slices.Contains(notificationChannels, channel) {
+				return fmt.Errorf("unknown notification channel %q", channel)
+			}
+		}
+	}
+	return nil
+}
+
+// NotificationWebhooks are the webhook channels of notifications.
+type NotificationWebhooks struct {
+	// BaseURL is Shelley's external URL, used to link to conversations.
+	BaseURL string
+	// Webhook receives NotificationWebhookPayloads.
+	Webhook *webhook.Sender
+	// Slack is a Slack incoming webhook.
+	Slack *webhook.Sender
+}
+
+// NotificationWebhookPayload is posted to the notification webhook.
+type NotificationWebhookPayload struct {
+	Event          string `json:"event"`
+	ConversationID string `json:"conversation_id"`
+	Slug           string `json:"slug,omitempty"`
+	URL            string `json:"url,omitempty"`
+	// Message is a one-line description of the event.
+	Message string `json:"message"`
+	// Text is the agent's last message.
+	Text            string  `json:"text,omitempty"`
+	Plan            string  `json:"plan,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// SetNotificationWebhooks sets the webhook and Slack notification channels.
+func (s *Server) SetNotificationWebhooks(hooks NotificationWebhooks) {
+	s.updateSettings(func(settings *Settings) { settings.Webhooks = hooks })
+}
+
+// configuredNotificationChannels returns the channels that are set up for
+// user. Email goes to the configured recipients, not to the user, so only
+// admins' conversations send email.
+func (s *Server) configuredNotificationChannels(user string) []string {
+	var channels []string
+	if s.pushSender != nil {
+		channels = append(channels, ChannelPush)
+	}
+	if s.emailNotifier != nil && s.isAdminUser(user) {
+		channels = append(channels, ChannelEmail)
+	}
+	hooks := s.Settings().Webhooks
+	if hooks.Webhook != nil {
+		channels = append(channels, ChannelWebhook)
+	}
+	if hooks.Slack != nil {
+		channels = append(channels, ChannelSlack)
+	}
+	return channels
+}
+
+// loadNotificationPreferences returns a user's saved preferences for a
+// conversation, or their defaults when conversationID is "", or nil.
+func (s *Server) loadNotificationPreferences(ctx context.Context, owner, conversationID string) (NotificationPreferences, error) {
+	row, err := s.db.GetNotificationPreferences(ctx, owner, conversationID)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	var prefs NotificationPreferences
+	if err := json.Unmarshal([]byte(row.Preferences), &prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// effectiveNotificationPreferences returns the preferences in effect for a
+// conversation: for each event, the conversation's, else the user's
+// defaults, else defaultNotificationPreferences.
+func (s *Server) effectiveNotificationPreferences(ctx context.Context, owner, conversationID string) (NotificationPreferences, error) {
+	var levels []NotificationPreferences
+	if conversationID != "" {
+		prefs, err := s.loadNotificationPreferences(ctx, owner, conversationID)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, prefs)
+	}
+	prefs, err := s.loadNotificationPreferences(ctx, owner, "")
+	if err != nil {
+		return nil, err
+	}
+	levels = append(levels, prefs, defaultNotificationPreferences)
+
+	effective := make(NotificationPreferences)
+	for _, event := range notificationEvents {
+		for _, level := range levels {
+			if channels, ok := level[event]; ok {
+				effective[event] = channels
+				break
+			}
+		}
+	}
+	return effective, nil
+}
+
+// turnEnd describes how a turn ended, for notifying the user.
+type turnEnd struct {
+	// Event is the notification event of the outcome.
+	Event string
+	// Owner is the user who started the turn.
+	Owner    string
+	Duration time.Duration
+	// Text is the agent's last text.
+	Text string
+	// Body is a one-line description of the outcome.
+	Body string
+	// Reason says what the user must do, when NeedsUser is set: the turn
+	// was paused as stuck, or a plan or a risky command awaits approval.
+	Reason    string
+	NeedsUser bool
+	Failed    bool
+	// Plan is the plan awaiting approval.
+	Plan string
+}
+
+// notifyTurnEnded dispatches notifications about the end of a turn to the
+// channels the user chose for its event.
+func (s *Server) notifyTurnEnded(ctx context.Context, manager *ConversationManager, conversation *generated.Conversation, msg *generated.Message) {
+	if msg.LlmData == nil {
+		return
+	}
+	manager.mu.Lock()
+	end := turnEnd{
+		Owner:    manager.pushOwner,
+		Duration: time.Since(manager.turnStarted),
+	}
+	manager.mu.Unlock()
+	if len(s.configuredNotificationChannels(end.Owner)) == 0 {
+		return
+	}
+
+	var message llm.Message
+	if err := json.Unmarshal([]byte(*msg.LlmData), &message); err != nil {
+		return
+	}
+	for _, c := range message.Content {
+		if c.Type == llm.ContentTypeText && c.Text != "" {
+			end.Text = c.Text
+		}
+	}
+
+	switch {
+	case message.ErrorType == llm.ErrorTypeStuck:
+		end.Event = EventApprovalNeeded
+		end.Body = "Paused and needs your guidance"
+		end.Reason = "it is stuck and needs your guidance"
+		end.NeedsUser = true
+	case message.ErrorType != llm.ErrorTypeNone:
+		end.Event = EventError
+		end.Body = "Failed: " + end.Text
+		end.Failed = true
+	default:
+		plan, err := s.db.GetConversationPlan(ctx, conversation.ConversationID)
+		if err == nil && plan != nil && plan.Mode == db.PlanModePlan && plan.Plan != "" && plan.ApprovedAt == nil {
+			end.Event = EventApprovalNeeded
+			end.Body = "A plan is ready for your approval"
+			end.Reason = "a plan is ready for your approval"
+			end.NeedsUser = true
+			end.Plan = plan.Plan
+		} else {
+			end.Event = EventTurnComplete
+			end.Body = "Finished: " + end.Text
+		}
+	}
+	end.Body = strings.Join(strings.Fields(end.Body), " ")
+	s.dispatchNotification(ctx, conversation, end)
+}
+
+// dispatchNotification sends a notification of end to the channels the user
+// chose for its event.
+func (s *Server) dispatchNotification(ctx context.Context, conversation *generated.Conversation, end turnEnd) {
+	configured := s.configuredNotificationChannels(end.Owner)
+	if len(configured) == 0 {
+		return
+	}
+	prefs, err := s.effectiveNotificationPreferences(ctx, end.Owner, conversation.ConversationID)
+	if err != nil {
+		s.logger.Error("Failed to load notification preferences", "conversationID", conversation.ConversationID, "error", err)
+		prefs = defaultNotificationPreferences
+	}
+	for _, channel := range prefs[end.Event] {
+		if !slices.Contains(configured, channel) {
+			continue
+		}
+		switch channel {
+		case ChannelPush:
+			s.pushTurnEnded(ctx, conversation, end)
+		case ChannelEmail:
+			s.emailTurnEnded(ctx, conversation, end)
+		case ChannelWebhook:
+			s.webhookTurnEnded(ctx, conversation, end)
+		case ChannelSlack:
+			s.slackTurnEnded(ctx, conversation, end)
+		}
+	}
+}
+
+// notificationURL returns the external link to a conversation, or "".
+func (s *Server) notificationURL(conversation *generated.Conversation) string {
+	baseURL := s.Settings().Webhooks.BaseURL
+	if baseURL == "" || conversation.Slug == nil {
+		return ""
+	}
+	return baseURL + "/c/" + *conversation.Slug
+}
+
+// webhookTurnEnded posts the end of a turn to the notification webhook.
+func (s *Server) webhookTurnEnded(ctx context.Context, conversation *generated.Conversation, end turnEnd) {
+	sender := s.Settings().Webhooks.Webhook
+	if sender == nil {
+		return
+	}
+	payload := NotificationWebhookPayload{
+		Event:           end.Event,
+		ConversationID:  conversation.ConversationID,
+		URL:             s.notificationURL(conversation),
+		Message:         end.Body,
+		Text:            end.Text,
+		Plan:            end.Plan,
+		DurationSeconds: end.Duration.Seconds(),
+	}
+	if conversation.Slug != nil {
+		payload.Slug = *conversation.Slug
+	}
+	if err := sender.Post(ctx, payload); err != nil {
+		s.logger.Warn("Failed to post notification webhook", "conversationID", conversation.ConversationID, "error", err)
+	}
+}
+
+// slackEscaper escapes the characters Slack message text reserves.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackTurnEnded posts the end of a turn to Slack.
+func (s *Server) slackTurnEnded(ctx context.Context, conversation *generated.Conversation, end turnEnd) {
+	sender := s.Settings().Webhooks.Slack
+	if sender == nil {
+		return
+	}
+	title := "Shelley"
+	if conversation.Slug != nil {
+		title = *conversation.Slug
+	}
+	text := "*" + slackEscaper.Replace(title) + "*: " + slackEscaper.Replace(end.Body)
+	if url := s.notificationURL(conversation); url != "" {
+		text += "\n<" + url + "|Open the conversation>"
+	}
+	if err := sender.Post(ctx, map[string]string{"text": text}); err != nil {
+		s.logger.Warn("Failed to post Slack notification", "conversationID", conversation.ConversationID, "error", err)
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// planApprovedMessage is sent to the agent when the user approves its plan.
+const planApprovedMessage = "The plan is approved. Go ahead and implement it."
+
+// PlanRequest is the request body for switching a conversation's plan mode
+type PlanRequest struct {
+	Mode string `json:"mode"`
+}
+
+// PlanAPI is the API representation of a conversation's plan state
+type PlanAPI struct {
+	Mode       string     `json:"mode"`
+	Plan       string     `json:"plan"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+}
+
+func toPlanAPI(p *generated.ConversationPlan) PlanAPI {
+	if p == nil {
+		return PlanAPI{Mode: db.PlanModeExecute}
+	}
+	return PlanAPI{Mode: p.Mode, Plan: p.Plan, ApprovedAt: p.ApprovedAt}
+}
+
+// handleConversationPlan handles GET /conversation/<id>/plan, POST /conversation/<id>/plan
+// and POST /conversation/<id>/plan/approve. Approving a plan switches the
+// conversation to execute mode and tells the agent to implement it.
+func (s *Server) handleConversationPlan(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	var plan *generated.ConversationPlan
+	if r.Method == http.MethodPost {
+		approve := strings.HasSuffix(r.URL.Path, "/approve")
+		var req PlanRequest
+		if !approve {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid JSON", http.StatusBadRequest)
+				return
+			}
+			if req.Mode != db.PlanModePlan && req.Mode != db.PlanModeExecute {
+				http.Error(w, "Invalid mode", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// The mode decides the tools and system prompt of the loop, so it is restarted
+		s.mu.Lock()
+		manager := s.activeConversations[conversationID]
+		s.mu.Unlock()
+		if manager != nil && manager.IsAgentWorking() {
+			http.Error(w, "Conversation is busy", http.StatusConflict)
+			return
+		}
+
+		if approve {
+			plan, err = s.db.ApproveConversationPlan(ctx, conversationID)
+			if err == nil && plan == nil {
+				http.Error(w, "No plan has been submitted", http.StatusConflict)
+				return
+			}
+		} else {
+			plan, err = s.db.SetConversationPlanMode(ctx, conversationID, req.Mode)
+		}
+		if err != nil {
+			s.logger.Error("Failed to update conversation plan", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if manager != nil {
+			if err := manager.ReloadContext(); err != nil {
+				s.logger.Warn("Failed to reload conversation context", "conversationID", conversationID, "error", err)
+			}
+		}
+		if approve {
+			s.audit(r, AuditActionPlanApproved, conversationID, map[string]any{"plan": plan.Plan})
+			if err := s.startApprovedPlan(ctx, conversation); err != nil {
+				s.logger.Error("Failed to start approved plan", "conversationID", conversationID, "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+	} else {
+		plan, err = s.db.GetConversationPlan(ctx, conversationID)
+		if err != nil {
+			s.logger.Error("Failed to get conversation plan", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toPlanAPI(plan))
+}
+
+// startApprovedPlan sends planApprovedMessage with the conversation's model.
+func (s *Server) startApprovedPlan(ctx context.Context, conversation *generated.Conversation) error {
+	modelID := s.Settings().DefaultModel
+	if conversation.Model != nil {
+		modelID = *conversation.Model
+	}
+	service, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		return fmt.Errorf("unsupported model %s: %w", modelID, err)
+	}
+	manager, err := s.getOrCreateConversationManager(ctx, conversation.ConversationID)
+	if err != nil {
+		return err
+	}
+	_, err = manager.AcceptUserMessage(ctx, service, modelID, llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: planApprovedMessage}},
+	})
+	return err
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanMode(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	chatBody, _ := json.Marshal(ChatRequest{
+		Message:  "bash: touch created.txt",
+		Model:    "predictable",
+		Cwd:      dir,
+		PlanMode: true,
+	})
+	w := httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", bytes.NewReader(chatBody)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+
+	if result := h.WaitToolResult(); !strings.Contains(result, "plan mode") {
+		t.Errorf("expected bash to be refused in plan mode, got %q", result)
+	}
+	h.WaitResponse()
+	h.waitIdle()
+	if _, err := os.Stat(filepath.Join(dir, "created.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected no file to be created in plan mode, got %v", err)
+	}
+
+	// toolNames returns the tools offered in the last agent request; slug
+	// generation sends requests without a system prompt or tools
+	toolNames := func() []string {
+		t.Helper()
+		requests := h.llm.GetRecentRequests()
+		for i := len(requests) - 1; i >= 0; i-- {
+			if len(requests[i].System) == 0 {
+				continue
+			}
+			var names []string
+			for _, tool := range requests[i].Tools {
+				names = append(names, tool.Name)
+			}
+			return names
+		}
+		return nil
+	}
+	names := strings.Join(toolNames(), ",")
+	if strings.Contains(names, "patch") || !strings.Contains(names, "submit_plan") {
+		t.Errorf("unexpected tools in plan mode: %s", names)
+	}
+	if !strings.Contains(h.systemPrompt(), "<plan_mode>") {
+		t.Error("expected plan mode section in system prompt")
+	}
+
+	getPlan := func() PlanAPI {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("GET", "/"+h.convID+"/plan", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var plan PlanAPI
+		json.Unmarshal(w.Body.Bytes(), &plan)
+		return plan
+	}
+
+	if w := h.post("/plan/approve", nil); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 approving without a plan, got %d", w.Code)
+	}
+
+	h.Chat("plan: 1. Add created.txt")
+	h.WaitResponse()
+	h.waitIdle()
+	if plan := getPlan(); plan.Mode != "plan" || plan.Plan != "1. Add created.txt" || plan.ApprovedAt != nil {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+
+	w = h.post("/plan/approve", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if plan := getPlan(); plan.Mode != "execute" || plan.ApprovedAt == nil {
+		t.Fatalf("unexpected plan after approval: %+v", plan)
+	}
+
+	// Approval tells the agent to go ahead
+	h.WaitResponse()
+	if prompts := h.prompts(); prompts[len(prompts)-1] != planApprovedMessage {
+		t.Errorf("expected approval message, got %q", prompts)
+	}
+	names = strings.Join(toolNames(), ",")
+	if !strings.Contains(names, "patch") || strings.Contains(names, "submit_plan") {
+		t.Errorf("unexpected tools after approval: %s", names)
+	}
+	if strings.Contains(h.systemPrompt(), "<plan_mode>") {
+		t.Error("expected no plan mode section after approval")
+	}
+
+	// Switching back to plan mode is allowed at any time
+	h.waitIdle()
+	if w := h.post("/plan", PlanRequest{Mode: "plan"}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := h.post("/plan", PlanRequest{Mode: "bogus"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid mode, got %d", w.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"sync"
+	"time"
+)
+
+// loopTimingSamples is how many of the latest samples of a phase the loop
+// timings report keeps.
+const loopTimingSamples = 1000
+
+// Phases of the loop timings report, besides one per tool, named "tool/"
+// followed by the tool's name.
+const (
+	phaseLLMRequest    = "llm_request"
+	phaseTurnWait      = "turn_wait"
+	phaseRecordMessage = "record_message"
+)
+
+// phaseSamples are the latest durations of a phase, in a ring.
+type phaseSamples struct {
+	durations []time.Duration
+	next      int
+	count     int64
+}
+
+// loopTimings keeps the latencies of the phases of turns in memory, so that
+// slow ones can be found in production without a profiler.
+type loopTimings struct {
+	mu     sync.Mutex
+	since  time.Time
+	phases map[string]*phaseSamples
+}
+
+func newLoopTimings() *loopTimings {
+	return &loopTimings{since: time.Now(), phases: make(map[string]*phaseSamples)}
+}
+
+// observe records that a phase took d. It does nothing on a nil loopTimings.
+func (lt *loopTimings) observe(phase string, d time.Duration) {
+	if lt == nil {
+		return
+	}
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	p, ok := lt.phases[phase]
+	if !ok {
+		p = &phaseSamples{}
+		lt.phases[phase] = p
+	}
+	if len(p.durations) < loopTimingSamples {
+		p.durations = append(p.durations, d)
+	} else {
+		p.durations[p.next] = d
+		p.next = (p.next + 1) % loopTimingSamples
+	}
+	p.count++
+}
+
+// LoopPhaseTimingAPI is the latency of a phase of turns over its latest samples
+type LoopPhaseTimingAPI struct {
+	Phase string `json:"phase"`
+	// Count is how many times the phase ran; Samples how many of the latest
+	// the percentiles are of
+	Count   int64   `json:"count"`
+	Samples int     `json:"samples"`
+	P50Ms   float64 `json:"p50_ms"`
+	P90Ms   float64 `json:"p90_ms"`
+	P99Ms   float64 `json:"p99_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// LoopTimingsAPI is the response from /api/debug/loop-timings
+type LoopTimingsAPI struct {
+	Since  time.Time            `json:"since"`
+	Phases []LoopPhaseTimingAPI `json:"phases"`
+}
+
+// report returns the percentiles of each phase, sorted by phase.
+func (lt *loopTimings) report() LoopTimingsAPI {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	result := LoopTimingsAPI{Since: lt.since, Phases: make([]LoopPhaseTimingAPI, 0, len(lt.phases))}
+	for name, p := range lt.phases {
+		sorted := slices.Clone(p.durations)
+		slices.Sort(sorted)
+		percentile := func(q float64) float64 {
+			return durationMs(sorted[int(q*float64(len(sorted)-1))])
+		}
+		result.Phases = append(result.Phases, LoopPhaseTimingAPI{
+			Phase:   name,
+			Count:   p.count,
+			Samples: len(sorted),
+			P50Ms:   percentile(0.5),
+			P90Ms:   percentile(0.9),
+			P99Ms:   percentile(0.99),
+			MaxMs:   durationMs(sorted[len(sorted)-1]),
+		})
+	}
+	slices.SortFunc(result.Phases, func(a, b LoopPhaseTimingAPI) int {
+		return cmp.Compare(a.Phase, b.Phase)
+	})
+	return result
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// handleLoopTimings handles GET /api/debug/loop-timings, the latency
+// percentiles of the phases of turns since the server started. Admins only.
+func (s *Server) handleLoopTimings(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.loopTimings.report())
+}
+
+// adminOnly allows only admins to use h.
+func (s *Server) adminOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAdmin(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// registerProfiling registers the pprof handlers under /debug/pprof/,
+// for admins only.
+func (s *Server) registerProfiling(mux *http.ServeMux) {
+	mux.Handle("GET /debug/pprof/", s.adminOnly(http.HandlerFunc(pprof.Index)))
+	mux.Handle("GET /debug/pprof/cmdline", s.adminOnly(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("GET /debug/pprof/profile", s.adminOnly(http.HandlerFunc(pprof.Profile)))
+	mux.Handle("GET /debug/pprof/symbol", s.adminOnly(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("POST /debug/pprof/symbol", s.adminOnly(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("GET /debug/pprof/trace", s.adminOnly(http.HandlerFunc(pprof.Trace)))
+}
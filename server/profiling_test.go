@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoopTimingsReport(t *testing.T) {
+	lt := newLoopTimings()
+	for i := 1; i <= 100; i++ {
+		lt.observe(phaseLLMRequest, time.Duration(i)*time.Millisecond)
+	}
+	for i := 0; i < loopTimingSamples+10; i++ {
+		lt.observe("tool/bash", time.Millisecond)
+	}
+	var nilTimings *loopTimings
+	nilTimings.observe(phaseLLMRequest, time.Second)
+
+	report := lt.report()
+	if len(report.Phases) != 2 || report.Phases[0].Phase != phaseLLMRequest || report.Phases[1].Phase != "tool/bash" {
+		t.Fatalf("phases = %+v", report.Phases)
+	}
+	llm := report.Phases[0]
+	if llm.Count != 100 || llm.P50Ms != 50 || llm.P90Ms != 90 || llm.P99Ms != 99 || llm.MaxMs != 100 {
+		t.Errorf("llm_request timing = %+v", llm)
+	}
+	if bash := report.Phases[1]; bash.Count != loopTimingSamples+10 || bash.Samples != loopTimingSamples {
+		t.Errorf("only the latest samples should be kept: %+v", bash)
+	}
+}
+
+func TestProfilingEndpoints(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("bash: echo timed", "")
+	h.WaitToolResult()
+	h.WaitResponse()
+
+	h.server.requireHeader = "X-Exedev-Userid"
+	h.server.SetAdmins([]string{"admin"})
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	get := func(path, user string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("X-Exedev-Userid", user)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, path := range []string{"/api/debug/loop-timings", "/debug/pprof/", "/debug/pprof/goroutine"} {
+		if w := get(path, "alice"); w.Code != http.StatusForbidden {
+			t.Errorf("%s for a non-admin: status %d, want 403", path, w.Code)
+		}
+		if w := get(path, "admin"); w.Code != http.StatusOK {
+			t.Errorf("%s for an admin: status %d, want 200", path, w.Code)
+		}
+	}
+
+	var report LoopTimingsAPI
+	if err := json.Unmarshal(get("/api/debug/loop-timings", "admin").Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	phases := map[string]LoopPhaseTimingAPI{}
+	for _, p := range report.Phases {
+		phases[p.Phase] = p
+	}
+	for _, phase := range []string{phaseLLMRequest, phaseRecordMessage, "tool/bash"} {
+		if phases[phase].Count == 0 {
+			t.Errorf("no timings of %s in %+v", phase, report.Phases)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// projectInfoTTL is how long the project detected for a directory is reused.
+const projectInfoTTL = 5 * time.Minute
+
+// projectInfo is the project a working directory belongs to: its git
+// repository, or the directory itself outside of one.
+type projectInfo struct {
+	Root      string
+	Name      string
+	RemoteURL string
+	detected  time.Time
+}
+
+// detectProject finds the project of dir from its git root and remote.
+func detectProject(dir string) projectInfo {
+	info := projectInfo{Root: dir, detected: time.Now()}
+	if root, err := getGitRoot(dir); err == nil {
+		info.Root = root
+		cmd := exec.Command("git", "config", "--get", "remote.origin.url")
+		cmd.Dir = root
+		if output, err := cmd.Output(); err == nil {
+			info.RemoteURL = redactRemoteURL(strings.TrimSpace(string(output)))
+		}
+	}
+	info.Name = projectName(info.Root, info.RemoteURL)
+	return info
+}
+
+// redactRemoteURL removes the credentials from an http(s) remote URL.
+func redactRemoteURL(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return remote
+	}
+	u.User = nil
+	return u.String()
+}
+
+// projectName titles a project after its remote's repository path, such as
+// "org/repo", or else the name of its root directory.
+func projectName(root, remote string) string {
+	var path string
+	u, err := url.Parse(remote)
+	switch {
+	case err == nil && u.Host != "":
+		path = u.Path
+	case err == nil && u.Opaque != "":
+		// scp-like syntax with a host alias: host:org/repo.git
+		path = u.Opaque
+	case err != nil:
+		// scp-like syntax: git@host:org/repo.git
+		if _, after, ok := strings.Cut(remote, ":"); ok {
+			path = after
+		}
+	}
+	path = strings.Trim(strings.TrimSuffix(path, ".git"), "/")
+	if path != "" {
+		return path
+	}
+	return filepath.Base(root)
+}
+
+// projectFor returns the project of a working directory, detecting it at most
+// once per projectInfoTTL.
+func (s *Server) projectFor(dir string) projectInfo {
+	if cached, ok := s.projectInfos.Load(dir); ok {
+		if info := cached.(projectInfo); time.Since(info.detected) < projectInfoTTL {
+			return info
+		}
+	}
+	info := detectProject(dir)
+	s.projectInfos.Store(dir, info)
+	return info
+}
+
+// ProjectGroupAPI is a project of the conversation list, from GET /api/conversations/projects
+type ProjectGroupAPI struct {
+	// Root is the git root of the conversations' working directory, or the
+	// directory itself outside a repository; empty for conversations without one
+	Root              string    `json:"root"`
+	Name              string    `json:"name"`
+	RemoteURL         string    `json:"remote_url,omitempty"`
+	ConversationCount int       `json:"conversation_count"`
+	WorkingCount      int       `json:"working_count"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// handleConversationProjects handles GET /api/conversations/projects: the
+// projects of the non-archived conversations, most recently active first.
+func (s *Server) handleConversationProjects(w http.ResponseWriter, r *http.Request) {
+	conversations, err := s.db.ListConversations(r.Context(), 5000, 0)
+	if err != nil {
+		s.logger.Error("Failed to list conversations", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	working := s.getWorkingConversations()
+
+	groups := make(map[string]*ProjectGroupAPI)
+	for _, conv := range conversations {
+		group := ProjectGroupAPI{Name: "No directory"}
+		if conv.Cwd != nil && *conv.Cwd != "" {
+			info := s.projectFor(*conv.Cwd)
+			group = ProjectGroupAPI{Root: info.Root, Name: info.Name, RemoteURL: info.RemoteURL}
+		}
+		g, ok := groups[group.Root]
+		if !ok {
+			g = &group
+			groups[group.Root] = g
+		}
+		g.ConversationCount++
+		if working[conv.ConversationID] {
+			g.WorkingCount++
+		}
+		if conv.UpdatedAt.After(g.UpdatedAt) {
+			g.UpdatedAt = conv.UpdatedAt
+		}
+	}
+
+	result := make([]ProjectGroupAPI, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UpdatedAt.After(result[j].UpdatedAt)
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// setupCommandTimeout bounds each project setup command.
+const setupCommandTimeout = 5 * time.Minute
+
+// ProjectAPI is the API representation of a project profile
+type ProjectAPI struct {
+	Directory            string            `json:"directory"`
+	DefaultModel         string            `json:"default_model,omitempty"`
+	Env                  map[string]string `json:"env"`
+	SetupCommands        []string          `json:"setup_commands"`
+	Instructions         string            `json:"instructions"`
+	SystemPromptTemplate string            `json:"system_prompt_template,omitempty"` // overrides the global template
+}
+
+func toProjectAPI(p generated.Project) (ProjectAPI, error) {
+	api := ProjectAPI{Directory: p.Directory, Instructions: p.Instructions, SystemPromptTemplate: p.SystemPromptTemplate}
+	if p.DefaultModel != nil {
+		api.DefaultModel = *p.DefaultModel
+	}
+	if err := json.Unmarshal([]byte(p.Env), &api.Env); err != nil {
+		return api, fmt.Errorf("invalid env for project %s: %w", p.Directory, err)
+	}
+	if err := json.Unmarshal([]byte(p.SetupCommands), &api.SetupCommands); err != nil {
+		return api, fmt.Errorf("invalid setup commands for project %s: %w", p.Directory, err)
+	}
+	return api, nil
+}
+
+// envList converts an environment map into sorted KEY=VALUE entries
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// findProject returns the project profile covering dir, or nil.
+func (s *Server) findProject(ctx context.Context, dir string) (*ProjectAPI, error) {
+	p, err := s.db.FindProjectForDirectory(ctx, dir)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	project, err := toProjectAPI(*p)
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// runCommands runs commands in dir with the extra environment, stopping at
+// the first failure. Each command is limited to timeout.
+// It returns the combined output of the commands that ran.
+func runCommands(ctx context.Context, dir string, env, commands []string, timeout time.Duration) (string, error) {
+	var out bytes.Buffer
+	for _, command := range commands {
+		fmt.Fprintf(&out, "$ %s\n", command)
+		cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+		cmd := exec.CommandContext(cmdCtx, "bash", "-c", command)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		err := cmd.Run()
+		cancel()
+		if err != nil {
+			return out.String(), fmt.Errorf("command %q failed: %w", command, err)
+		}
+	}
+	return out.String(), nil
+}
+
+// handleProjects handles /api/projects:
+// GET lists profiles, PUT creates or replaces one, DELETE ?directory=... removes one.
+func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		projects, err := s.db.ListProjects(ctx)
+		if err != nil {
+			s.logger.Error("Failed to list projects", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		result := make([]ProjectAPI, 0, len(projects))
+		for _, p := range projects {
+			api, err := toProjectAPI(p)
+			if err != nil {
+				s.logger.Error("Failed to decode project", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			result = append(result, api)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+
+	case http.MethodPut:
+		var req ProjectAPI
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateDirectory(req.Directory); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for k := range req.Env {
+			if err := validateEnvName(k); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := validateSystemPromptTemplate(req.SystemPromptTemplate); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.DefaultModel != "" && !s.llmManager.HasModel(req.DefaultModel) {
+			http.Error(w, fmt.Sprintf("Unsupported model: %s", req.DefaultModel), http.StatusBadRequest)
+			return
+		}
+		if req.Env == nil {
+			req.Env = map[string]string{}
+		}
+		if req.SetupCommands == nil {
+			req.SetupCommands = []string{}
+		}
+		env, _ := json.Marshal(req.Env)
+		setup, _ := json.Marshal(req.SetupCommands)
+		var defaultModel *string
+		if req.DefaultModel != "" {
+			defaultModel = &req.DefaultModel
+		}
+		project, err := s.db.UpsertProject(ctx, generated.UpsertProjectParams{
+			Directory:            filepath.Clean(req.Directory),
+			DefaultModel:         defaultModel,
+			Env:                  string(env),
+			SetupCommands:        string(setup),
+			Instructions:         req.Instructions,
+			SystemPromptTemplate: req.SystemPromptTemplate,
+		})
+		if err != nil {
+			s.logger.Error("Failed to save project", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		api, err := toProjectAPI(*project)
+		if err != nil {
+			s.logger.Error("Failed to decode project", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api)
+
+	case http.MethodDelete:
+		directory := r.URL.Query().Get("directory")
+		if directory == "" {
+			http.Error(w, "directory is required", http.StatusBadRequest)
+			return
+		}
+		if err := s.db.DeleteProject(ctx, filepath.Clean(directory)); err != nil {
+			s.logger.Error("Failed to delete project", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
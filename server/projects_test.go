@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+func TestProjects(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(p ProjectAPI) *httptest.ResponseRecorder {
+		t.Helper()
+		body, _ := json.Marshal(p)
+		w := httptest.NewRecorder()
+		h.server.handleProjects(w, httptest.NewRequest("PUT", "/api/projects", bytes.NewReader(body)))
+		return w
+	}
+
+	if w := put(ProjectAPI{Directory: "relative"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for relative directory, got %d", w.Code)
+	}
+	if w := put(ProjectAPI{Directory: dir, DefaultModel: "no-such-model"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown model, got %d", w.Code)
+	}
+
+	w := put(ProjectAPI{
+		Directory:     dir,
+		DefaultModel:  "predictable",
+		Env:           map[string]string{"PROJECT_GREETING": "hello-from-project"},
+		SetupCommands: []string{"touch setup-ran"},
+		Instructions:  "Always answer in haiku.",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.server.handleProjects(w, httptest.NewRequest("GET", "/api/projects", nil))
+	var projects []ProjectAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &projects); err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 1 || projects[0].Directory != dir || projects[0].Env["PROJECT_GREETING"] != "hello-from-project" {
+		t.Fatalf("unexpected projects: %+v", projects)
+	}
+
+	// A conversation in a subdirectory picks up the profile, including the default model.
+	chatBody, _ := json.Marshal(ChatRequest{Message: "bash: echo $PROJECT_GREETING", Cwd: sub})
+	w = httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", bytes.NewReader(chatBody)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+
+	if out := h.WaitToolResult(); !strings.Contains(out, "hello-from-project") {
+		t.Errorf("expected project env in tool output, got %q", out)
+	}
+	h.WaitResponse()
+	if _, err := os.Stat(filepath.Join(sub, "setup-ran")); err != nil {
+		t.Errorf("expected setup command to run in the conversation cwd: %v", err)
+	}
+	if !strings.Contains(h.systemPrompt(), "Always answer in haiku.") {
+		t.Errorf("expected project instructions in system prompt")
+	}
+
+	// A failing setup command is recorded and the agent is not started.
+	if w := put(ProjectAPI{Directory: dir, SetupCommands: []string{"echo broken; exit 3"}}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	chatBody, _ = json.Marshal(ChatRequest{Message: "echo: hi", Model: "predictable", Cwd: dir})
+	w = httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", bytes.NewReader(chatBody)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var failed struct {
+		Status         string `json:"status"`
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &failed)
+	if failed.Status != "setup_failed" {
+		t.Fatalf("expected setup_failed, got %q", failed.Status)
+	}
+	messages, err := h.db.ListMessages(context.Background(), failed.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range messages {
+		if m.Type == string(db.MessageTypeUser) {
+			t.Fatalf("expected the user message not to be recorded after setup failure")
+		}
+	}
+	var msg llm.Message
+	json.Unmarshal([]byte(*messages[len(messages)-1].LlmData), &msg)
+	if msg.ErrorType != llm.ErrorTypeHook || !strings.Contains(msg.Content[0].Text, "broken") {
+		t.Errorf("unexpected setup failure message: %+v", msg)
+	}
+
+	w = httptest.NewRecorder()
+	h.server.handleProjects(w, httptest.NewRequest("DELETE", "/api/projects?directory="+dir, nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if p, err := h.db.FindProjectForDirectory(context.Background(), sub); err != nil || p != nil {
+		t.Errorf("expected project to be deleted, got %+v, %v", p, err)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// systemPromptTemplateSetting is the settings key holding the global system prompt template override.
+const systemPromptTemplateSetting = "system_prompt_template"
+
+// SystemPromptTemplateRequest is the request body for PUT /api/system-prompt-template
+type SystemPromptTemplateRequest struct {
+	Template string `json:"template"`
+}
+
+// SystemPromptTemplateResponse describes the global system prompt template
+type SystemPromptTemplateResponse struct {
+	Template string `json:"template"` // empty when the built-in template is used
+	Default  string `json:"default"`
+}
+
+// validateSystemPromptTemplate checks that a custom template parses
+func validateSystemPromptTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	if _, err := ParseSystemPromptTemplate(text); err != nil {
+		return fmt.Errorf("invalid system prompt template: %w", err)
+	}
+	return nil
+}
+
+// systemPromptTemplate returns the custom system prompt template for the
+// conversation: its project's template, then the global one.
+// It returns "" when the built-in template should be used.
+func (cm *ConversationManager) systemPromptTemplate(ctx context.Context) (string, error) {
+	p, err := cm.db.FindProjectForDirectory(ctx, cm.cwd)
+	if err != nil {
+		return "", err
+	}
+	if p != nil && p.SystemPromptTemplate != "" {
+		return p.SystemPromptTemplate, nil
+	}
+	return cm.db.GetSetting(ctx, systemPromptTemplateSetting)
+}
+
+// handleSystemPromptTemplate handles /api/system-prompt-template:
+// GET returns the override and the built-in template, PUT sets the override, DELETE clears it.
+func (s *Server) handleSystemPromptTemplate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req SystemPromptTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateSystemPromptTemplate(req.Template); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		if req.Template == "" {
+			err = s.db.DeleteSetting(ctx, systemPromptTemplateSetting)
+		} else {
+			err = s.db.SetSetting(ctx, systemPromptTemplateSetting, req.Template)
+		}
+		if err != nil {
+			s.logger.Error("Failed to save system prompt template", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	case http.MethodDelete:
+		if err := s.db.DeleteSetting(ctx, systemPromptTemplateSetting); err != nil {
+			s.logger.Error("Failed to delete system prompt template", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	text, err := s.db.GetSetting(ctx, systemPromptTemplateSetting)
+	if err != nil {
+		s.logger.Error("Failed to get system prompt template", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SystemPromptTemplateResponse{Template: text, Default: systemPromptTemplate})
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateSystemPromptFromTemplate(t *testing.T) {
+	dir := t.TempDir()
+	prompt, err := GenerateSystemPromptFromTemplate(dir, "cwd={{.WorkingDirectory}} os={{.OS}} date={{.Date}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "cwd=" + dir + " os=" + runtime.GOOS + " date=" + time.Now().Format("2006-01-02")
+	if prompt != want {
+		t.Errorf("got %q, want %q", prompt, want)
+	}
+
+	def, err := GenerateSystemPromptFromTemplate(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(def, "You are Shelley") {
+		t.Errorf("expected empty template to use the built-in one")
+	}
+}
+
+func TestSystemPromptTemplateOverrides(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	request := func(method string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		h.server.handleSystemPromptTemplate(w, httptest.NewRequest(method, "/api/system-prompt-template", bytes.NewReader(data)))
+		return w
+	}
+	// systemFor starts a conversation in dir and returns the system prompt sent to the LLM
+	systemFor := func(dir string) string {
+		t.Helper()
+		h.NewConversation("echo: hi", dir)
+		h.WaitResponse()
+		return h.systemPrompt()
+	}
+
+	if w := request("PUT", SystemPromptTemplateRequest{Template: "{{.Broken"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid template, got %d", w.Code)
+	}
+	w := request("PUT", SystemPromptTemplateRequest{Template: "GLOBAL PROMPT in {{.WorkingDirectory}}"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp SystemPromptTemplateResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Template != "GLOBAL PROMPT in {{.WorkingDirectory}}" || !strings.Contains(resp.Default, "You are Shelley") {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	plain := t.TempDir()
+	if system := systemFor(plain); !strings.Contains(system, "GLOBAL PROMPT in "+plain) || strings.Contains(system, "You are Shelley") {
+		t.Errorf("expected global template, got %q", system)
+	}
+
+	// A project template takes precedence over the global one
+	projectDir := t.TempDir()
+	body, _ := json.Marshal(ProjectAPI{Directory: projectDir, SystemPromptTemplate: "PROJECT PROMPT on {{.OS}}"})
+	pw := httptest.NewRecorder()
+	h.server.handleProjects(pw, httptest.NewRequest("PUT", "/api/projects", bytes.NewReader(body)))
+	if pw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", pw.Code, pw.Body.String())
+	}
+	if system := systemFor(projectDir); !strings.Contains(system, "PROJECT PROMPT on "+runtime.GOOS) || strings.Contains(system, "GLOBAL PROMPT") {
+		t.Errorf("expected project template, got %q", system)
+	}
+
+	if w := request("DELETE", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if system := systemFor(t.TempDir()); !strings.Contains(system, "You are Shelley") {
+		t.Errorf("expected built-in template after reset, got %q", system)
+	}
+}
+
+func TestPromptOverride(t *testing.T) {
+	prompt := "Intro\n<repo_map>\nmap\n</repo_map>\n<skills>\nskill\n</skills>\nOutro\n"
+	override := PromptOverride{Remove: []string{"repo_map", "missing"}, Prepend: "First.", Append: "Call one tool at a time.\n"}
+	want := "First.\n\nIntro\n<skills>\nskill\n</skills>\nOutro\n\nCall one tool at a time.\n"
+	if got := override.Apply(prompt); got != want {
+		t.Errorf("Apply = %q, want %q", got, want)
+	}
+
+	h := NewTestHarness(t)
+	defer h.Close()
+	settings := h.server.Settings()
+	settings.PromptOverrides = map[string]PromptOverride{
+		"predictable": {Remove: []string{"customization"}, Append: "STRICT TOOL USE"},
+	}
+	h.server.ApplySettings(settings)
+
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+	system := h.systemPrompt()
+	if !strings.Contains(system, "STRICT TOOL USE") || strings.Contains(system, "<customization>") {
+		t.Errorf("override not applied: %q", system)
+	}
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/models"
+)
+
+// providerCheckTimeout bounds the validation ping made before a credential is saved
+const providerCheckTimeout = 30 * time.Second
+
+// ProviderConfigurer is implemented by LLM providers whose credentials can be changed at runtime
+type ProviderConfigurer interface {
+	CheckProviderCredential(ctx context.Context, provider models.Provider, value string) error
+	SetProviderCredential(provider models.Provider, value string) error
+	ClearProviderCredential(provider models.Provider) error
+	ProviderStatuses() []models.ProviderStatus
+}
+
+// providerHealthTimeout bounds the checks of GET /api/health/providers
+const providerHealthTimeout = 15 * time.Second
+
+// ProviderHealthChecker is implemented by LLM providers that can check that their providers answer
+type ProviderHealthChecker interface {
+	CheckProviderHealth(ctx context.Context) []models.ProviderHealth
+}
+
+// ProviderHealthAPI is the API representation of a provider health check
+type ProviderHealthAPI struct {
+	Provider   string `json:"provider"`
+	Configured bool   `json:"configured"`
+	Reachable  bool   `json:"reachable"`
+	AuthValid  *bool  `json:"auth_valid,omitempty"` // Unset if the check can't tell
+	LatencyMs  int64  `json:"latency_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ProviderAPI describes a provider's credential state. The credential itself is never returned.
+type ProviderAPI struct {
+	Provider   string   `json:"provider"`
+	Configured bool     `json:"configured"`
+	Runtime    bool     `json:"runtime"` // Set through the API rather than flags or environment
+	Models     []string `json:"models"`
+}
+
+// ProviderCredentialRequest is the body of POST /api/providers/<provider>.
+// APIKey is used by the API providers and URL by claude-code.
+type ProviderCredentialRequest struct {
+	APIKey string `json:"api_key,omitempty"`
+	URL    string `json:"url,omitempty"`
+}
+
+func toProviderAPI(status models.ProviderStatus) ProviderAPI {
+	modelIDs := status.Models
+	if modelIDs == nil {
+		modelIDs = []string{}
+	}
+	return ProviderAPI{
+		Provider:   string(status.Provider),
+		Configured: status.Configured,
+		Runtime:    status.Runtime,
+		Models:     modelIDs,
+	}
+}
+
+// providerSecretName is the name of the secret holding a provider's credential
+func providerSecretName(provider models.Provider) string {
+	return "provider/" + string(provider)
+}
+
+// loadProviderCredentials applies the credentials stored through the API, which
+// take precedence over flags and environment. Credentials that can't be
+// decrypted or applied are logged and skipped.
+func loadProviderCredentials(ctx context.Context, database *db.DB, configurer ProviderConfigurer, logger *slog.Logger) {
+	for _, provider := range models.ConfigurableProviders {
+		value, err := database.GetSecret(ctx, providerSecretName(provider))
+		if err == nil && value != "" {
+			err = configurer.SetProviderCredential(provider, value)
+		}
+		if err != nil {
+			logger.Error("Failed to apply provider credential", "provider", provider, "error", err)
+		}
+	}
+}
+
+// providerConfigurer returns the LLM manager as a ProviderConfigurer, writing
+// an error response if it doesn't support runtime configuration
+func (s *Server) providerConfigurer(w http.ResponseWriter) (ProviderConfigurer, bool) {
+	configurer, ok := s.llmManager.(ProviderConfigurer)
+	if !ok {
+		http.Error(w, "Provider configuration is not supported", http.StatusNotImplemented)
+	}
+	return configurer, ok
+}
+
+// handleProviders handles GET /api/providers
+func (s *Server) handleProviders(w http.ResponseWriter, r *http.Request) {
+	configurer, ok := s.providerConfigurer(w)
+	if !ok {
+		return
+	}
+	statuses := configurer.ProviderStatuses()
+	providers := make([]ProviderAPI, 0, len(statuses))
+	for _, status := range statuses {
+		providers = append(providers, toProviderAPI(status))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(providers)
+}
+
+// handleProviderHealth handles GET /api/health/providers. It contacts every
+// configured provider and reports whether it answered, whether it accepted
+// the credential and how long it took.
+func (s *Server) handleProviderHealth(w http.ResponseWriter, r *http.Request) {
+	checker, ok := s.llmManager.(ProviderHealthChecker)
+	if !ok {
+		http.Error(w, "Provider health checks are not supported", http.StatusNotImplemented)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), providerHealthTimeout)
+	defer cancel()
+	checks := checker.CheckProviderHealth(ctx)
+	results := make([]ProviderHealthAPI, 0, len(checks))
+	for _, h := range checks {
+		results = append(results, ProviderHealthAPI{
+			Provider:   string(h.Provider),
+			Configured: h.Configured,
+			Reachable:  h.Reachable,
+			AuthValid:  h.AuthValid,
+			LatencyMs:  h.Latency.Milliseconds(),
+			Error:      h.Error,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// handleProvider handles POST and DELETE /api/providers/<provider>. POST checks
+// the credential against the provider before saving it; DELETE goes back to
+// the credential from flags or environment.
+func (s *Server) handleProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	configurer, ok := s.providerConfigurer(w)
+	if !ok {
+		return
+	}
+	provider := models.Provider(r.PathValue("provider"))
+	if !slices.Contains(models.ConfigurableProviders, provider) {
+		http.Error(w, "Unknown provider", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := s.db.DeleteSecret(ctx, providerSecretName(provider)); err != nil {
+			s.logger.Error("Failed to delete provider credential", "provider", provider, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := configurer.ClearProviderCredential(provider); err != nil {
+			s.logger.Error("Failed to clear provider credential", "provider", provider, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		s.providerCredentialsChanged()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var req ProviderCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	value := strings.TrimSpace(req.APIKey)
+	if provider == models.ProviderClaudeCode {
+		value = strings.TrimSpace(req.URL)
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "url must be an http or https URL", http.StatusBadRequest)
+			return
+		}
+	} else if value == "" {
+		http.Error(w, "api_key is required", http.StatusBadRequest)
+		return
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, providerCheckTimeout)
+	defer cancel()
+	if err := configurer.CheckProviderCredential(checkCtx, provider, value); err != nil {
+		http.Error(w, fmt.Sprintf("Provider check failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.SetSecret(ctx, providerSecretName(provider), value); err != nil {
+		s.logger.Error("Failed to save provider credential", "provider", provider, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := configurer.SetProviderCredential(provider, value); err != nil {
+		s.logger.Error("Failed to apply provider credential", "provider", provider, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.providerCredentialsChanged()
+
+	for _, status := range configurer.ProviderStatuses() {
+		if status.Provider == provider {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(toProviderAPI(status))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// providerCredentialsChanged drops state that depends on the old credentials:
+// cached model checks, and the loops of idle conversations, which hold their
+// services. Busy conversations keep their service until they are reloaded.
+func (s *Server) providerCredentialsChanged() {
+	s.modelChecks.reset()
+	s.reloadIdleConversations()
+}
+
+// reloadIdleConversations stops the loops of the active conversations that
+// are not working, so that their next turn starts from the current state.
+func (s *Server) reloadIdleConversations() {
+	s.mu.Lock()
+	managers := make([]*ConversationManager, 0, len(s.activeConversations))
+	for _, manager := range s.activeConversations {
+		managers = append(managers, manager)
+	}
+	s.mu.Unlock()
+	for _, manager := range managers {
+		if err := manager.ReloadContext(); err != nil && !errors.Is(err, errConversationBusy) {
+			s.logger.Warn("Failed to reload conversation context", "conversationID", manager.conversationID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/models"
+)
+
+func TestProviderCredentials(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// A fake Anthropic API that accepts a single key
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "good-key" {
+			http.Error(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude","content":[{"type":"text","text":"OK"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer anthropic.Close()
+
+	llmManager := NewLLMServiceManager(&LLMConfig{Gateway: anthropic.URL, Logger: slog.Default(), DB: database})
+	server := NewServer(database, llmManager, claudetool.ToolSetConfig{}, slog.Default(), false, "", "predictable", "", nil)
+	mux := http.NewServeMux()
+	server.RegisterRoutes(mux)
+
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewReader(data)))
+		return w
+	}
+	anthropicStatus := func() ProviderAPI {
+		t.Helper()
+		w := do("GET", "/api/providers", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var providers []ProviderAPI
+		json.Unmarshal(w.Body.Bytes(), &providers)
+		for _, p := range providers {
+			if p.Provider == string(models.ProviderAnthropic) {
+				return p
+			}
+		}
+		t.Fatalf("anthropic missing from %+v", providers)
+		return ProviderAPI{}
+	}
+
+	if p := anthropicStatus(); p.Configured {
+		t.Fatalf("expected anthropic to be unconfigured, got %+v", p)
+	}
+
+	if w := do("POST", "/api/providers/anthropic", ProviderCredentialRequest{APIKey: "bad-key"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a key that fails the check, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/providers/bogus", ProviderCredentialRequest{APIKey: "good-key"}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown provider, got %d", w.Code)
+	}
+	if w := do("POST", "/api/providers/claude-code", ProviderCredentialRequest{URL: "not a url"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid bridge URL, got %d", w.Code)
+	}
+	if value, _ := database.GetSecret(context.Background(), "provider/anthropic"); value != "" {
+		t.Fatalf("expected nothing saved after failed checks, got %q", value)
+	}
+
+	w := do("POST", "/api/providers/anthropic", ProviderCredentialRequest{APIKey: "good-key"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "good-key") {
+		t.Error("response must not contain the key")
+	}
+	if p := anthropicStatus(); !p.Configured || !p.Runtime || len(p.Models) == 0 {
+		t.Errorf("unexpected anthropic status: %+v", p)
+	}
+	if !llmManager.HasModel("claude-opus-4.5") {
+		t.Error("expected anthropic models to be available")
+	}
+
+	if value, err := database.GetSecret(context.Background(), "provider/anthropic"); err != nil || value != "good-key" {
+		t.Fatalf("expected the stored credential, got %q, %v", value, err)
+	}
+
+	// A restarted server picks up the stored credential
+	if restarted := NewLLMServiceManager(&LLMConfig{Gateway: anthropic.URL, Logger: slog.Default(), DB: database}); !restarted.HasModel("claude-opus-4.5") {
+		t.Error("expected the stored credential to be applied at startup")
+	}
+
+	if w := do("DELETE", "/api/providers/anthropic", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if p := anthropicStatus(); p.Configured || p.Runtime {
+		t.Errorf("expected anthropic to be unconfigured after delete, got %+v", p)
+	}
+	if value, _ := database.GetSecret(context.Background(), "provider/anthropic"); value != "" {
+		t.Errorf("expected the stored credential to be deleted, got %q", value)
+	}
+}
+
+func TestProviderHealth(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_/gateway/anthropic/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("X-Api-Key") != "good-key" {
+			http.Error(w, "invalid x-api-key", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer gateway.Close()
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer bridge.Close()
+
+	check := func(anthropicKey string) map[string]ProviderHealthAPI {
+		t.Helper()
+		llmManager := NewLLMServiceManager(&LLMConfig{Gateway: gateway.URL, AnthropicAPIKey: anthropicKey, ClaudeCodeBridgeURL: bridge.URL, Logger: slog.Default()})
+		server := NewServer(nil, llmManager, claudetool.ToolSetConfig{}, slog.Default(), false, "", "predictable", "", nil)
+		w := httptest.NewRecorder()
+		server.handleProviderHealth(w, httptest.NewRequest("GET", "/api/health/providers", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var results []ProviderHealthAPI
+		json.Unmarshal(w.Body.Bytes(), &results)
+		byProvider := make(map[string]ProviderHealthAPI)
+		for _, h := range results {
+			byProvider[h.Provider] = h
+		}
+		return byProvider
+	}
+
+	results := check("good-key")
+	if h := results[string(models.ProviderAnthropic)]; !h.Reachable || h.AuthValid == nil || !*h.AuthValid || h.Error != "" {
+		t.Errorf("expected anthropic to be healthy, got %+v", h)
+	}
+	if h := results[string(models.ProviderClaudeCode)]; !h.Reachable || h.AuthValid != nil || h.Error != "" {
+		t.Errorf("expected the bridge to be healthy, got %+v", h)
+	}
+	if h := results[string(models.ProviderOpenAI)]; h.Configured || h.Reachable {
+		t.Errorf("expected openai to be unconfigured, got %+v", h)
+	}
+
+	results = check("bad-key")
+	if h := results[string(models.ProviderAnthropic)]; !h.Reachable || h.AuthValid == nil || *h.AuthValid || !strings.Contains(h.Error, "401") {
+		t.Errorf("expected the anthropic key to be rejected, got %+v", h)
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/webpush"
+)
+
+// pushMinTurn is how long a turn must run before its end is pushed; the user
+// is likely still watching shorter ones. Turns needing the user are always pushed.
+var pushMinTurn = 30 * time.Second
+
+// pushTTL is how long push services keep notifications for offline devices.
+const pushTTL = 12 * time.Hour
+
+// pushServiceHosts are the hosts of the browsers' push services; those
+// starting with a dot match their subdomains. The server sends requests to
+// the endpoints of subscriptions, so endpoints elsewhere are refused.
+var pushServiceHosts = []string{
+	"fcm.googleapis.com",         // Chrome and other Chromium based browsers
+	"android.googleapis.com",     // older Chrome
+	".push.services.mozilla.com", // Firefox
+	".push.apple.com",            // Safari
+	".notify.windows.com",        // Edge
+}
+
+// PushNotification is the payload of Web Push messages, shown by the UI's
+// service worker.
+type PushNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// URL is the page opened when the notification is clicked.
+	URL string `json:"url"`
+	// Tag groups notifications, so that a conversation shows only its latest.
+	Tag string `json:"tag"`
+}
+
+// SetPushSender sets the sender of Web Push notifications. Notifications are
+// disabled until it is set.
+func (s *Server) SetPushSender(sender *webpush.Sender) {
+	s.pushSender = sender
+}
+
+// handlePushSubscription handles POST /api/push/subscriptions, which saves
+// the browser's subscription (PushSubscription.toJSON()) for the user, and
+// DELETE /api/push/subscriptions with {"endpoint": ...}, which removes the
+// user's subscription.
+func (s *Server) handlePushSubscription(w http.ResponseWriter, r *http.Request) {
+	if s.pushSender == nil {
+		http.Error(w, "push notifications are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var sub webpush.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	owner := s.requestUser(r)
+
+	if r.Method == http.MethodDelete {
+		if err := s.db.DeletePushSubscription(r.Context(), owner, sub.Endpoint); err != nil {
+			s.logger.Error("Failed to delete push subscription", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := validatePushEndpoint(sub.Endpoint); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+		http.Error(w, "keys.p256dh and keys.auth are required", http.StatusBadRequest)
+		return
+	}
+	err := s.db.SavePushSubscription(r.Context(), generated.UpsertPushSubscriptionParams{
+		Endpoint: sub.Endpoint,
+		Owner:    owner,
+		P256dh:   sub.Keys.P256dh,
+		Auth:     sub.Keys.Auth,
+	})
+	if err != nil {
+		s.logger.Error("Failed to save push subscription", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// validatePushEndpoint checks that a subscription's endpoint is an https URL
+// of a known push service.
+func validatePushEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return errors.New("endpoint must be an https URL")
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, known := range pushServiceHosts {
+		if host == known || strings.HasPrefix(known, ".") && strings.HasSuffix(host, known) {
+			return nil
+		}
+	}
+	return fmt.Errorf("endpoint host %q is not a known push service", host)
+}
+
+// pushTurnEnded notifies the user who started a turn that it ended, if it
+// ran long or needs them: it was paused as stuck or a plan awaits approval.
+func (s *Server) pushTurnEnded(ctx context.Context, conversation *generated.Conversation, end turnEnd) {
+	if !end.NeedsUser && end.Duration < pushMinTurn {
+		return
+	}
+
+	body := end.Body
+	if runes := []rune(body); len(runes) > 200 {
+		body = string(runes[:200]) + "..."
+	}
+	notification := PushNotification{
+		Title: "Shelley",
+		Body:  body,
+		URL:   s.basePath + "/",
+		Tag:   conversation.ConversationID,
+	}
+	if conversation.Slug != nil {
+		notification.Title = *conversation.Slug
+		notification.URL = s.basePath + "/c/" + *conversation.Slug
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return
+	}
+
+	subs, err := s.db.ListPushSubscriptions(ctx, end.Owner)
+	if err != nil {
+		s.logger.Error("Failed to list push subscriptions", "error", err)
+		return
+	}
+	for _, sub := range subs {
+		var target webpush.Subscription
+		target.Endpoint = sub.Endpoint
+		target.Keys.P256dh = sub.P256dh
+		target.Keys.Auth = sub.Auth
+		err := s.pushSender.Send(ctx, target, payload, pushTTL)
+		if errors.Is(err, webpush.ErrGone) {
+			if err := s.db.DeletePushSubscription(ctx, sub.Owner, sub.Endpoint); err != nil {
+				s.logger.Error("Failed to delete expired push subscription", "error", err)
+			}
+		} else if err != nil {
+			s.logger.Warn("Failed to send push notification", "conversationID", conversation.ConversationID, "error", err)
+		}
+	}
+}
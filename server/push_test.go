@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/webpush"
+)
+
+func TestPushNotifications(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	pushMinTurn = 0
+	defer func() { pushMinTurn = 30 * time.Second }()
+	defer func(hosts []string) { pushServiceHosts = hosts }(pushServiceHosts)
+	pushServiceHosts = append(pushServiceHosts, "127.0.0.1")
+
+	pushed := make(chan string, 10)
+	pushService := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		pushed <- r.Header.Get("Content-Encoding")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer pushService.Close()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	subscribe := func(method, endpoint, user string) int {
+		t.Helper()
+		key, _ := ecdh.P256().GenerateKey(rand.Reader)
+		auth := make([]byte, 16)
+		rand.Read(auth)
+		var sub webpush.Subscription
+		sub.Endpoint = endpoint
+		sub.Keys.P256dh = base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes())
+		sub.Keys.Auth = base64.RawURLEncoding.EncodeToString(auth)
+		data, _ := json.Marshal(sub)
+		req := httptest.NewRequest(method, "/api/push/subscriptions", bytes.NewReader(data))
+		req.Header.Set("X-Exedev-Userid", user)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := subscribe("POST", pushService.URL+"/push", ""); code != http.StatusServiceUnavailable {
+		t.Fatalf("subscribe without VAPID keys: status %d, want 503", code)
+	}
+	cfg, _ := webpush.GenerateConfig("mailto:ops@example.com")
+	sender, err := webpush.NewSender(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.HTTPClient = pushService.Client()
+	h.server.SetPushSender(sender)
+
+	if code := subscribe("POST", "http://insecure.example.com/push", ""); code != http.StatusBadRequest {
+		t.Errorf("subscribe with an http endpoint: status %d, want 400", code)
+	}
+	if code := subscribe("POST", "https://metadata.internal/push", ""); code != http.StatusBadRequest {
+		t.Errorf("subscribe with an unknown push service: status %d, want 400", code)
+	}
+	if code := subscribe("POST", pushService.URL+"/push", ""); code != http.StatusCreated {
+		t.Fatalf("subscribe: status %d", code)
+	}
+	if code := subscribe("POST", pushService.URL+"/gone", ""); code != http.StatusCreated {
+		t.Fatalf("subscribe: status %d", code)
+	}
+
+	// The end of a turn is pushed to the user's subscriptions, and those the
+	// push service no longer knows are forgotten
+	h.NewConversation("echo: hello", "")
+	h.WaitResponse()
+	select {
+	case encoding := <-pushed:
+		if encoding != "aes128gcm" {
+			t.Errorf("push Content-Encoding = %q", encoding)
+		}
+	case <-time.After(h.timeout):
+		t.Fatal("turn end was not pushed")
+	}
+	deadline := time.Now().Add(h.timeout)
+	for {
+		subs, err := h.db.ListPushSubscriptions(t.Context(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(subs) == 1 && subs[0].Endpoint == pushService.URL+"/push" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("subscriptions = %+v, want the expired one deleted", subs)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Users can only remove their own subscriptions
+	h.server.requireHeader = "X-Exedev-Userid"
+	if code := subscribe("DELETE", pushService.URL+"/push", "mallory"); code != http.StatusNoContent {
+		t.Errorf("unsubscribe another user: status %d", code)
+	}
+	if subs, _ := h.db.ListPushSubscriptions(t.Context(), ""); len(subs) != 1 {
+		t.Errorf("subscriptions after another user unsubscribed = %+v", subs)
+	}
+	h.server.requireHeader = ""
+
+	if code := subscribe("DELETE", pushService.URL+"/push", ""); code != http.StatusNoContent {
+		t.Errorf("unsubscribe: status %d", code)
+	}
+	if subs, _ := h.db.ListPushSubscriptions(t.Context(), ""); len(subs) != 0 {
+		t.Errorf("subscriptions after unsubscribing = %+v", subs)
+	}
+}
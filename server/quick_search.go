@@ -0,0 +1,218 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// quickSearchConversations is how many recent conversations are searched.
+	quickSearchConversations = 500
+	// quickSearchHalfLife is how long it takes the recency score of an item
+	// to halve.
+	quickSearchHalfLife = 3 * 24 * time.Hour
+)
+
+// QuickSearchItem is a conversation, directory or slash command offered by
+// the quick switcher
+type QuickSearchItem struct {
+	// Type is "conversation", "directory" or "command"
+	Type string `json:"type"`
+	// ID is the conversation ID, the directory's path or "/" and the command name
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+	// LastUsedAt and Uses are what the recency and frequency scores are
+	// computed from
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Uses       int64      `json:"uses,omitempty"`
+	Favorite   bool       `json:"favorite,omitempty"`
+	Score      float64    `json:"score"`
+}
+
+// fuzzyScore scores how well text matches query, like the UI's command
+// palette: exact matches score highest, then prefixes, then substrings, then
+// the query's characters appearing in order. It returns -1 if text doesn't
+// match.
+func fuzzyScore(query, text string) float64 {
+	query, text = strings.ToLower(query), strings.ToLower(text)
+	if text == query {
+		return 1000
+	}
+	if strings.HasPrefix(text, query) {
+		return 500 + float64(len(query))/float64(len(text))*100
+	}
+	if strings.Contains(text, query) {
+		return 100 + float64(len(query))/float64(len(text))*50
+	}
+	q := []rune(query)
+	var score, bonus float64
+	i := 0
+	for _, c := range text {
+		if i == len(q) {
+			break
+		}
+		if c == q[i] {
+			score += 1 + bonus
+			bonus += 0.5
+			i++
+		} else {
+			bonus = 0
+		}
+	}
+	if i != len(q) {
+		return -1
+	}
+	return score
+}
+
+// frecencyScore scores an item by how recently and how often it was used.
+func frecencyScore(lastUsed *time.Time, uses int64, now time.Time) float64 {
+	var score float64
+	if lastUsed != nil {
+		age := max(now.Sub(*lastUsed), 0)
+		score += 100 * math.Exp2(-float64(age)/float64(quickSearchHalfLife))
+	}
+	return score + 20*math.Log2(1+float64(uses))
+}
+
+// handleQuickSearch handles GET /api/quick-search?q=...&cwd=...&limit=... and
+// returns the conversations, directories and slash commands (those available
+// in cwd) that match q, best first. Without q, everything is ranked by
+// recency and frequency of use.
+func (s *Server) handleQuickSearch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	conversations, err := s.db.ListQuickSearchConversations(ctx, quickSearchConversations)
+	if err != nil {
+		s.logger.Error("Failed to list conversations", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	owner := s.requestUser(r)
+	recent, err := s.db.ListRecentUserDirectories(ctx, owner, 2*recentDirectoriesShown)
+	if err != nil {
+		s.logger.Error("Failed to list recent directories", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	favorites, err := s.db.ListFavoriteUserDirectories(ctx, owner)
+	if err != nil {
+		s.logger.Error("Failed to list favorite directories", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var items []QuickSearchItem
+	// A directory is used once per conversation started in it
+	directories := make(map[string]*QuickSearchItem)
+	directory := func(path string) *QuickSearchItem {
+		path = filepath.Clean(path)
+		d, ok := directories[path]
+		if !ok {
+			d = &QuickSearchItem{Type: "directory", ID: path, Title: filepath.Base(path), Subtitle: path}
+			directories[path] = d
+		}
+		return d
+	}
+	for _, c := range conversations {
+		item := QuickSearchItem{
+			Type:       "conversation",
+			ID:         c.ConversationID,
+			Title:      "Untitled",
+			LastUsedAt: &c.UpdatedAt,
+			Uses:       c.UserMessageCount,
+		}
+		if c.Slug != nil {
+			item.Title = *c.Slug
+		}
+		if c.Cwd != nil && *c.Cwd != "" {
+			item.Subtitle = *c.Cwd
+			d := directory(*c.Cwd)
+			d.Uses++
+			if d.LastUsedAt == nil || c.UpdatedAt.After(*d.LastUsedAt) {
+				d.LastUsedAt = &c.UpdatedAt
+			}
+		}
+		items = append(items, item)
+	}
+	for _, ud := range recent {
+		d := directory(ud.Path)
+		if ud.LastUsedAt != nil && (d.LastUsedAt == nil || ud.LastUsedAt.After(*d.LastUsedAt)) {
+			d.LastUsedAt = ud.LastUsedAt
+		}
+	}
+	for _, ud := range favorites {
+		directory(ud.Path).Favorite = true
+	}
+	for _, d := range directories {
+		if info, err := os.Stat(d.ID); err == nil && info.IsDir() {
+			items = append(items, *d)
+		}
+	}
+	for _, cmd := range slashCommands(r.URL.Query().Get("cwd")) {
+		items = append(items, QuickSearchItem{
+			Type:     "command",
+			ID:       "/" + cmd.Name,
+			Title:    "/" + cmd.Name,
+			Subtitle: cmd.Description,
+		})
+	}
+
+	now := time.Now()
+	ranked := make([]QuickSearchItem, 0, len(items))
+	for _, item := range items {
+		item.Score = frecencyScore(item.LastUsedAt, item.Uses, now)
+		if item.Favorite {
+			item.Score += 50
+		}
+		if query != "" {
+			match := fuzzyScore(query, item.Title)
+			if item.Type == "command" {
+				// Match commands with or without their slash
+				match = max(match, fuzzyScore(query, item.Title[1:]))
+			}
+			if item.Subtitle != "" {
+				match = max(match, 0.8*fuzzyScore(query, item.Subtitle))
+			}
+			if match <= 0 {
+				continue
+			}
+			item.Score += match
+		}
+		item.Score = math.Round(item.Score*100) / 100
+		ranked = append(ranked, item)
+	}
+	slices.SortStableFunc(ranked, func(a, b QuickSearchItem) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Title, b.Title)
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ranked)
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shelley.exe.dev/db"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	for _, tc := range []struct {
+		query, text string
+		match       bool
+	}{
+		{"deploy", "deploy", true},
+		{"dep", "deploy-fix", true},
+		{"fix", "deploy-fix", true},
+		{"dfx", "deploy-fix", true},
+		{"xfd", "deploy-fix", false},
+	} {
+		if got := fuzzyScore(tc.query, tc.text) > 0; got != tc.match {
+			t.Errorf("fuzzyScore(%q, %q) matches = %v, want %v", tc.query, tc.text, got, tc.match)
+		}
+	}
+	exact, prefix, contains, subsequence := fuzzyScore("fix", "fix"), fuzzyScore("fix", "fix-tests"),
+		fuzzyScore("fix", "deploy-fix"), fuzzyScore("fix", "f-i-x")
+	if !(exact > prefix && prefix > contains && contains > subsequence) {
+		t.Errorf("scores not ordered: exact %v, prefix %v, contains %v, subsequence %v", exact, prefix, contains, subsequence)
+	}
+}
+
+func TestQuickSearch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	h := NewTestHarness(t)
+	defer h.Close()
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+
+	project := filepath.Join(t.TempDir(), "project")
+	if err := os.MkdirAll(filepath.Join(project, ".shelley", "commands"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, ".shelley", "commands", "review.md"), []byte("Review the diff"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := t.Context()
+	for _, slug := range []string{"deploy-fix", "refactor-db"} {
+		c, err := h.db.CreateConversation(ctx, &slug, true, &project, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slug == "refactor-db" {
+			for range 3 {
+				if _, err := h.db.CreateMessage(ctx, db.CreateMessageParams{ConversationID: c.ConversationID, Type: db.MessageTypeUser}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	search := func(query string) []QuickSearchItem {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/quick-search?cwd="+url.QueryEscape(project)+"&q="+url.QueryEscape(query), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		var items []QuickSearchItem
+		if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+			t.Fatal(err)
+		}
+		return items
+	}
+	titles := func(items []QuickSearchItem) []string {
+		var result []string
+		for _, item := range items {
+			result = append(result, item.Type+":"+item.Title)
+		}
+		return result
+	}
+
+	// Without a query, the more used of two recent conversations ranks first,
+	// and the directory they share counts both
+	items := search("")
+	if len(items) != 4 {
+		t.Fatalf("items = %v", titles(items))
+	}
+	rank := make(map[string]int)
+	for i, item := range items {
+		rank[item.Type+":"+item.Title] = i
+		if item.Type == "directory" && item.Uses != 2 {
+			t.Errorf("directory uses = %d, want 2", item.Uses)
+		}
+	}
+	if rank["conversation:refactor-db"] > rank["conversation:deploy-fix"] {
+		t.Errorf("frequently used conversation ranked lower: %v", titles(items))
+	}
+	if _, ok := rank["command:/review"]; !ok {
+		t.Errorf("command missing: %v", titles(items))
+	}
+
+	// A query keeps only matches, best match first
+	if got := titles(search("deploy")); len(got) != 1 || got[0] != "conversation:deploy-fix" {
+		t.Errorf("search deploy = %v", got)
+	}
+	if got := titles(search("review")); len(got) != 1 || got[0] != "command:/review" {
+		t.Errorf("search review = %v", got)
+	}
+	if got := titles(search("project")); len(got) == 0 || got[0] != "directory:project" {
+		t.Errorf("search project = %v", got)
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// handleMarkConversationRead handles POST /conversation/<id>/read: the
+// requesting user has read the conversation up to its latest message.
+func (s *Server) handleMarkConversationRead(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if err := s.db.MarkConversationRead(r.Context(), s.requestUser(r), conversationID); err != nil {
+		s.logger.Error("Failed to mark conversation read", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unreadMessages returns the messages owner has not read, by conversation.
+// Conversations the user never opened have no entry.
+func (s *Server) unreadMessages(ctx context.Context, owner string) (map[string][]generated.Message, error) {
+	messages, err := s.db.ListUnreadMessages(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	unread := make(map[string][]generated.Message)
+	for _, msg := range messages {
+		unread[msg.ConversationID] = append(unread[msg.ConversationID], msg)
+	}
+	return unread, nil
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestUnreadSinceLastVisit(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	cwd := t.TempDir()
+
+	list := func() ConversationWithState {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.handleConversations(w, httptest.NewRequest("GET", "/api/conversations", nil))
+		var list []ConversationWithState
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 {
+			t.Fatalf("conversation list = %+v", list)
+		}
+		return list[0]
+	}
+
+	h.NewConversation("echo: hi", cwd)
+	h.WaitResponse()
+	h.waitIdle()
+	// A conversation that was never opened has nothing to report
+	if conv := list(); conv.UnreadCount != 0 {
+		t.Errorf("unread count before first visit = %d", conv.UnreadCount)
+	}
+
+	w := httptest.NewRecorder()
+	h.server.handleGetConversation(w, httptest.NewRequest("GET", "/api/conversation/"+h.convID, nil), h.convID)
+	h.Chat("patch: " + filepath.Join(cwd, "main.go"))
+	h.waitIdle()
+	conv := list()
+	if conv.UnreadCount < 2 || !slices.Equal(conv.ChangedFiles, []string{"main.go"}) {
+		t.Errorf("after new messages: unread %d, changed files %v", conv.UnreadCount, conv.ChangedFiles)
+	}
+
+	if w := h.post("/read", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if conv := list(); conv.UnreadCount != 0 || conv.ChangedFiles != nil {
+		t.Errorf("after marking read: unread %d, changed files %v", conv.UnreadCount, conv.ChangedFiles)
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+// showReasoning reports whether the request asked for thinking content with ?show_reasoning=1.
+// Thinking blocks are kept in the database either way; without the flag they are left out
+// of the messages sent to the client.
+func showReasoning(r *http.Request) bool {
+	show, _ := strconv.ParseBool(r.URL.Query().Get("show_reasoning"))
+	return show
+}
+
+// withoutReasoning returns a copy of messages with thinking content removed from their llm_data.
+func withoutReasoning(messages []APIMessage) []APIMessage {
+	out := make([]APIMessage, len(messages))
+	for i, msg := range messages {
+		out[i] = msg
+		if msg.LlmData == nil || msg.Type != string(db.MessageTypeAgent) {
+			continue
+		}
+		var message llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &message); err != nil {
+			continue
+		}
+		content := make([]llm.Content, 0, len(message.Content))
+		for _, c := range message.Content {
+			if c.Type != llm.ContentTypeThinking && c.Type != llm.ContentTypeRedactedThinking {
+				content = append(content, c)
+			}
+		}
+		if len(content) == len(message.Content) {
+			continue
+		}
+		message.Content = content
+		data, err := json.Marshal(message)
+		if err != nil {
+			continue
+		}
+		llmData := string(data)
+		out[i].LlmData = &llmData
+	}
+	return out
+}
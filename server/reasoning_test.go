@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+func TestShowReasoning(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	conv, err := database.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	_, err = database.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: conv.ConversationID,
+		Type:           db.MessageTypeAgent,
+		LLMData: llm.Message{
+			Role: llm.MessageRoleAssistant,
+			Content: []llm.Content{
+				{Type: llm.ContentTypeThinking, Thinking: "secret reasoning", Signature: "sig"},
+				{Type: llm.ContentTypeText, Text: "The answer"},
+			},
+			EndOfTurn: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create agent message: %v", err)
+	}
+
+	llmManager := &testLLMManager{service: loop.NewPredictableService()}
+	server := NewServer(database, llmManager, claudetool.ToolSetConfig{}, nil, true, "", "predictable", "", nil)
+	mux := http.NewServeMux()
+	server.RegisterRoutes(mux)
+
+	// contents returns the content types of the agent message
+	contents := func(query string) []llm.Content {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/conversation/"+conv.ConversationID+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp StreamResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(resp.Messages) != 1 || resp.Messages[0].LlmData == nil {
+			t.Fatalf("expected one agent message, got %+v", resp.Messages)
+		}
+		var message llm.Message
+		if err := json.Unmarshal([]byte(*resp.Messages[0].LlmData), &message); err != nil {
+			t.Fatalf("Failed to parse llm_data: %v", err)
+		}
+		if !message.EndOfTurn {
+			t.Error("expected end of turn to be kept")
+		}
+		return message.Content
+	}
+
+	hidden := contents("")
+	if len(hidden) != 1 || hidden[0].Text != "The answer" {
+		t.Errorf("expected thinking to be hidden by default, got %+v", hidden)
+	}
+
+	shown := contents("?show_reasoning=1")
+	if len(shown) != 2 || shown[0].Type != llm.ContentTypeThinking || !strings.Contains(shown[0].Thinking, "secret") {
+		t.Errorf("expected thinking with show_reasoning, got %+v", shown)
+	}
+}
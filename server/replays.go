@@ -0,0 +1,346 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// sandboxCopyTimeout bounds copying a working directory for a replay.
+const sandboxCopyTimeout = 5 * time.Minute
+
+// ReplayOutcomeAPI is what the agent did about one prompt
+type ReplayOutcomeAPI struct {
+	Response  string    `json:"response"`
+	Error     string    `json:"error,omitempty"`
+	ToolCalls int       `json:"tool_calls"`
+	Usage     llm.Usage `json:"usage"`
+}
+
+// ReplayStepAPI is a replayed prompt with the original outcome and, once the
+// prompt has run, the replayed one
+type ReplayStepAPI struct {
+	Prompt string `json:"prompt"`
+	// Status is the status of the prompt's batch task
+	Status   string            `json:"status"`
+	Original ReplayOutcomeAPI  `json:"original"`
+	Replay   *ReplayOutcomeAPI `json:"replay,omitempty"`
+}
+
+// ReplayTotalsAPI totals the outcomes of one side of a replay
+type ReplayTotalsAPI struct {
+	Model     string    `json:"model"`
+	Errors    int       `json:"errors"`
+	ToolCalls int       `json:"tool_calls"`
+	Usage     llm.Usage `json:"usage"`
+}
+
+// ReplayAPI is a replay of a conversation's prompts with another model, as a
+// report comparing the outcomes and cost of each prompt
+type ReplayAPI struct {
+	ID                   int64  `json:"id"`
+	ConversationID       string `json:"conversation_id"`
+	ReplayConversationID string `json:"replay_conversation_id"`
+	// BatchID is the batch running the prompts, which can be canceled
+	BatchID  int64  `json:"batch_id"`
+	ReadOnly bool   `json:"read_only"`
+	Sandbox  string `json:"sandbox,omitempty"`
+	// Status is the status of the batch
+	Status    string          `json:"status"`
+	CreatedAt time.Time       `json:"created_at"`
+	Steps     []ReplayStepAPI `json:"steps"`
+	// Original and Replay total the outcomes of the replayed prompts
+	Original ReplayTotalsAPI `json:"original"`
+	Replay   ReplayTotalsAPI `json:"replay"`
+}
+
+// handleReplayConversation handles POST /api/conversations/{id}/replay?model=...,
+// which runs the conversation's prompts again with another model in a new
+// conversation. It works in a copy of the original working directory, or,
+// with read_only=true, in the original one in plan mode.
+func (s *Server) handleReplayConversation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conversationID := r.PathValue("id")
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	model := r.URL.Query().Get("model")
+	if model == "" {
+		http.Error(w, "model is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.llmManager.GetService(model); err != nil {
+		http.Error(w, "unsupported model: "+model, http.StatusBadRequest)
+		return
+	}
+	readOnly := false
+	if v := r.URL.Query().Get("read_only"); v != "" {
+		if readOnly, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "Invalid read_only", http.StatusBadRequest)
+			return
+		}
+	}
+
+	messages, err := s.db.ListMessages(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var prompts []string
+	for _, turn := range promptTurns(messages) {
+		if strings.TrimSpace(turn.Prompt) != "" {
+			prompts = append(prompts, turn.Prompt)
+		}
+	}
+	if len(prompts) == 0 {
+		http.Error(w, "The conversation has no prompts to replay", http.StatusBadRequest)
+		return
+	}
+	if len(prompts) > maxBatchTasks {
+		http.Error(w, fmt.Sprintf("Only conversations with at most %d prompts can be replayed", maxBatchTasks), http.StatusBadRequest)
+		return
+	}
+
+	var cwd, sandbox string
+	if conversation.Cwd != nil {
+		cwd = *conversation.Cwd
+	}
+	if !readOnly {
+		if cwd == "" {
+			http.Error(w, "The conversation has no working directory to copy; replay it with read_only=true", http.StatusBadRequest)
+			return
+		}
+		sandbox, err = s.copySandbox(ctx, cwd)
+		if err != nil {
+			s.logger.Error("Failed to copy working directory for replay", "cwd", cwd, "error", err)
+			http.Error(w, "Failed to copy the working directory: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cwd = sandbox
+	}
+
+	replayConversationID, err := s.createBatchConversation(ctx, cwd, model)
+	if err != nil {
+		s.logger.Error("Failed to create replay conversation", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if readOnly {
+		if _, err := s.db.SetConversationPlanMode(ctx, replayConversationID, db.PlanModePlan); err != nil {
+			s.logger.Error("Failed to set plan mode of replay conversation", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	owner := s.requestUser(r)
+	batch, err := s.db.CreateBatch(ctx, generated.CreateBatchParams{
+		Owner:          owner,
+		Mode:           db.BatchModeSequential,
+		Model:          model,
+		Cwd:            cwd,
+		ConversationID: &replayConversationID,
+	}, prompts)
+	if err != nil {
+		s.logger.Error("Failed to create replay batch", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	replay, err := s.db.CreateReplay(ctx, generated.CreateReplayParams{
+		Owner:                owner,
+		ConversationID:       conversationID,
+		ReplayConversationID: replayConversationID,
+		BatchID:              batch.BatchID,
+		ReadOnly:             readOnly,
+		Sandbox:              sandbox,
+	})
+	if err != nil {
+		s.logger.Error("Failed to create replay", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info("Replaying conversation", "conversationID", conversationID, "replayID", replay.ReplayID, "model", model, "prompts", len(prompts), "readOnly", readOnly)
+	go s.runBatch(context.WithoutCancel(ctx), *batch)
+
+	api, err := s.toReplayAPI(ctx, *replay)
+	if err != nil {
+		s.logger.Error("Failed to load replay", "replayID", replay.ReplayID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(api)
+}
+
+// copySandbox copies a working directory, including any git repository,
+// into the workspaces directory or a temporary one.
+func (s *Server) copySandbox(ctx context.Context, cwd string) (string, error) {
+	if err := validateDirectory(cwd); err != nil {
+		return "", err
+	}
+	var dir string
+	var err error
+	if s.workspacesDir != "" {
+		dir, err = reserveWorkspace(s.workspacesDir, "replay-"+filepath.Base(cwd))
+	} else {
+		dir, err = os.MkdirTemp("", "shelley-replay-")
+	}
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sandboxCopyTimeout)
+	defer cancel()
+	if output, err := exec.CommandContext(ctx, "cp", "-a", cwd+"/.", dir).CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return dir, nil
+}
+
+// handleConversationReplays handles GET /api/conversations/{id}/replays,
+// listing the user's replays of a conversation.
+func (s *Server) handleConversationReplays(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	replays, err := s.db.ListConversationReplays(ctx, r.PathValue("id"))
+	if err != nil {
+		s.logger.Error("Failed to list replays", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	owner := s.requestUser(r)
+	result := make([]ReplayAPI, 0, len(replays))
+	for _, replay := range replays {
+		if replay.Owner != owner {
+			continue
+		}
+		api, err := s.toReplayAPI(ctx, replay)
+		if err != nil {
+			s.logger.Error("Failed to load replay", "replayID", replay.ReplayID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		result = append(result, api)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleReplay handles GET /api/replays/{id}, the report of a replay.
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid replay ID", http.StatusBadRequest)
+		return
+	}
+	replay, err := s.db.GetReplay(r.Context(), id)
+	if err != nil {
+		s.logger.Error("Failed to get replay", "replayID", id, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if replay == nil || replay.Owner != s.requestUser(r) {
+		http.Error(w, "Replay not found", http.StatusNotFound)
+		return
+	}
+	api, err := s.toReplayAPI(r.Context(), *replay)
+	if err != nil {
+		s.logger.Error("Failed to load replay", "replayID", id, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api)
+}
+
+// toReplayAPI pairs the prompts of the original conversation with those of
+// the replay, by position.
+func (s *Server) toReplayAPI(ctx context.Context, replay generated.Replay) (ReplayAPI, error) {
+	api := ReplayAPI{
+		ID:                   replay.ReplayID,
+		ConversationID:       replay.ConversationID,
+		ReplayConversationID: replay.ReplayConversationID,
+		BatchID:              replay.BatchID,
+		ReadOnly:             replay.ReadOnly,
+		Sandbox:              replay.Sandbox,
+		CreatedAt:            replay.CreatedAt,
+		Steps:                []ReplayStepAPI{},
+	}
+	batch, err := s.db.GetBatch(ctx, replay.BatchID)
+	if err != nil {
+		return api, err
+	}
+	if batch == nil {
+		return api, fmt.Errorf("batch %d not found", replay.BatchID)
+	}
+	tasks, err := s.db.ListBatchTasks(ctx, replay.BatchID)
+	if err != nil {
+		return api, err
+	}
+	api.Status = toBatchAPI(*batch, tasks).Status
+
+	sides := make([][]promptTurn, 2)
+	for i, conversationID := range []string{replay.ConversationID, replay.ReplayConversationID} {
+		conversation, err := s.db.GetConversationByID(ctx, conversationID)
+		if err != nil {
+			return api, err
+		}
+		totals := &api.Original
+		if i == 1 {
+			totals = &api.Replay
+		}
+		if conversation.Model != nil {
+			totals.Model = *conversation.Model
+		}
+		messages, err := s.db.ListMessages(ctx, conversationID)
+		if err != nil {
+			return api, err
+		}
+		for _, turn := range promptTurns(messages) {
+			if strings.TrimSpace(turn.Prompt) != "" {
+				sides[i] = append(sides[i], turn)
+			}
+		}
+	}
+
+	for i, task := range tasks {
+		step := ReplayStepAPI{Prompt: task.Prompt, Status: task.Status}
+		if i < len(sides[0]) {
+			step.Original = replayOutcome(sides[0][i])
+			addReplayTotals(&api.Original, step.Original)
+		}
+		if i < len(sides[1]) && task.Status != db.BatchTaskPending {
+			outcome := replayOutcome(sides[1][i])
+			step.Replay = &outcome
+			addReplayTotals(&api.Replay, outcome)
+		}
+		api.Steps = append(api.Steps, step)
+	}
+	return api, nil
+}
+
+func replayOutcome(turn promptTurn) ReplayOutcomeAPI {
+	return ReplayOutcomeAPI{Response: turn.Response, Error: turn.Error, ToolCalls: turn.ToolCalls, Usage: turn.Usage}
+}
+
+func addReplayTotals(totals *ReplayTotalsAPI, outcome ReplayOutcomeAPI) {
+	if outcome.Error != "" {
+		totals.Errors++
+	}
+	totals.ToolCalls += outcome.ToolCalls
+	totals.Usage.Add(outcome.Usage)
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+)
+
+func TestReplayConversation(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	batchPollInterval = 10 * time.Millisecond
+
+	cwd := t.TempDir()
+	os.WriteFile(filepath.Join(cwd, "input.txt"), []byte("hello\n"), 0o644)
+	h.NewConversation("echo: first", cwd)
+	h.WaitResponse()
+	h.Chat("bash: cp input.txt output.txt")
+	h.WaitResponse()
+	os.Remove(filepath.Join(cwd, "output.txt"))
+	h.server.workspacesDir = t.TempDir()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path string) (int, []byte) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewReader(nil))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code, w.Body.Bytes()
+	}
+	base := "/api/conversations/" + h.convID
+	wait := func(id int64) ReplayAPI {
+		t.Helper()
+		deadline := time.Now().Add(h.timeout)
+		for {
+			_, body := do("GET", "/api/replays/"+strconv.FormatInt(id, 10))
+			var replay ReplayAPI
+			json.Unmarshal(body, &replay)
+			if replay.Status != db.BatchTaskRunning {
+				return replay
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("replay %d still running: %s", id, body)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	if code, _ := do("POST", base+"/replay"); code != http.StatusBadRequest {
+		t.Errorf("no model: status %d, want 400", code)
+	}
+
+	code, body := do("POST", base+"/replay?model=predictable")
+	if code != http.StatusCreated {
+		t.Fatalf("replay: status %d: %s", code, body)
+	}
+	var replay ReplayAPI
+	json.Unmarshal(body, &replay)
+	if replay.Sandbox == "" || replay.Sandbox == cwd || len(replay.Steps) != 2 {
+		t.Fatalf("replay = %+v", replay)
+	}
+
+	replay = wait(replay.ID)
+	if replay.Status != db.BatchTaskDone {
+		t.Fatalf("replay status = %s", replay.Status)
+	}
+	first, second := replay.Steps[0], replay.Steps[1]
+	if first.Prompt != "echo: first" || first.Original.Response != "first" || first.Replay == nil || first.Replay.Response != "first" {
+		t.Errorf("first step = %+v", first)
+	}
+	if second.Original.ToolCalls != 1 || second.Replay == nil || second.Replay.ToolCalls != 1 {
+		t.Errorf("second step = %+v", second)
+	}
+	if replay.Original.Model != "predictable" || replay.Replay.ToolCalls != 1 || replay.Replay.Usage.OutputTokens == 0 {
+		t.Errorf("totals = %+v, %+v", replay.Original, replay.Replay)
+	}
+
+	// The replay worked in the sandbox, not the original directory
+	if _, err := os.Stat(filepath.Join(replay.Sandbox, "output.txt")); err != nil {
+		t.Errorf("sandbox output: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cwd, "output.txt")); !os.IsNotExist(err) {
+		t.Errorf("original directory changed: %v", err)
+	}
+
+	// A read-only replay runs in the original directory in plan mode
+	code, body = do("POST", base+"/replay?model=predictable&read_only=true")
+	if code != http.StatusCreated {
+		t.Fatalf("read-only replay: status %d: %s", code, body)
+	}
+	var readOnly ReplayAPI
+	json.Unmarshal(body, &readOnly)
+	plan, err := h.db.GetConversationPlan(t.Context(), readOnly.ReplayConversationID)
+	if !readOnly.ReadOnly || readOnly.Sandbox != "" || err != nil || plan == nil || plan.Mode != db.PlanModePlan {
+		t.Errorf("read-only replay = %+v, plan %+v, %v", readOnly, plan, err)
+	}
+	if readOnly = wait(readOnly.ID); readOnly.Status != db.BatchTaskDone {
+		t.Errorf("read-only replay status = %s", readOnly.Status)
+	}
+
+	code, body = do("GET", base+"/replays")
+	var replays []ReplayAPI
+	json.Unmarshal(body, &replays)
+	if code != http.StatusOK || len(replays) != 2 {
+		t.Errorf("replays: status %d: %s", code, body)
+	}
+}
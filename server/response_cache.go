@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ResponseCacheStats is the response of GET /api/admin/response-cache
+type ResponseCacheStats struct {
+	// Entries is the number of cached responses
+	Entries int64 `json:"entries"`
+	// Hits is how often requests were answered from the cache
+	Hits int64 `json:"hits"`
+}
+
+// handleResponseCache handles GET /api/admin/response-cache, which returns
+// the size and use of the cache of deterministic LLM responses, and DELETE,
+// which empties it, e.g. after changing a model's provider. Admins only.
+func (s *Server) handleResponseCache(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		stats, err := s.db.GetResponseCacheStats(ctx)
+		if err != nil {
+			s.logger.Error("Failed to get response cache stats", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ResponseCacheStats{Entries: stats.Entries, Hits: stats.Hits})
+	case http.MethodDelete:
+		if err := s.db.ClearResponseCache(ctx); err != nil {
+			s.logger.Error("Failed to clear response cache", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseCacheAdmin(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.requireHeader = "X-Exedev-Userid"
+	h.server.SetAdmins([]string{"admin"})
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, user string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/admin/response-cache", nil)
+		req.Header.Set("X-Exedev-Userid", user)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	stats := func() ResponseCacheStats {
+		t.Helper()
+		w := do("GET", "admin")
+		if w.Code != http.StatusOK {
+			t.Fatalf("stats: status %d: %s", w.Code, w.Body.String())
+		}
+		var stats ResponseCacheStats
+		json.Unmarshal(w.Body.Bytes(), &stats)
+		return stats
+	}
+
+	ctx := t.Context()
+	if err := h.server.db.SaveCachedResponse(ctx, "key", "predictable", `{}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.server.db.GetCachedResponse(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if got := stats(); got.Entries != 1 || got.Hits != 1 {
+		t.Errorf("stats = %+v, want 1 entry and 1 hit", got)
+	}
+
+	if w := do("DELETE", "alice"); w.Code != http.StatusForbidden {
+		t.Errorf("clearing as a user who isn't an admin: status %d, want 403", w.Code)
+	}
+	if w := do("DELETE", "admin"); w.Code != http.StatusNoContent {
+		t.Fatalf("clear: status %d: %s", w.Code, w.Body.String())
+	}
+	if got := stats(); got.Entries != 0 {
+		t.Errorf("stats after clearing = %+v", got)
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEditRetryAndUndo(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: one", "")
+	h.WaitResponse()
+	h.Chat("echo: two")
+	h.WaitResponse()
+	h.waitIdle()
+
+	messages, err := h.db.ListMessages(t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var twoSeq int64
+	for _, msg := range messages {
+		if prompt, ok := userPrompt(msg); ok && prompt.Content[0].Text == "echo: two" {
+			twoSeq = msg.SequenceID
+		}
+	}
+
+	// Edit the second message
+	if w := h.post("/edit", EditMessageRequest{SequenceID: twoSeq, Message: "echo: three"}); w.Code != http.StatusAccepted {
+		t.Fatalf("edit: expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	h.responsesCount = 1
+	if got := h.WaitResponse(); got != "three" {
+		t.Errorf("expected response three, got %q", got)
+	}
+	h.waitIdle()
+	if got := strings.Join(h.prompts(), ","); got != "echo: one,echo: three" {
+		t.Errorf("unexpected prompts after edit: %s", got)
+	}
+
+	// Undo brings back the original tail
+	if w := h.post("/undo-truncation", nil); w.Code != http.StatusOK {
+		t.Fatalf("undo: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := strings.Join(h.prompts(), ","); got != "echo: one,echo: two" {
+		t.Errorf("unexpected prompts after undo: %s", got)
+	}
+
+	// Retry resends the last prompt
+	if w := h.post("/retry", nil); w.Code != http.StatusAccepted {
+		t.Fatalf("retry: expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	h.responsesCount = 1
+	if got := h.WaitResponse(); got != "two" {
+		t.Errorf("expected response two, got %q", got)
+	}
+	if got := strings.Join(h.prompts(), ","); got != "echo: one,echo: two" {
+		t.Errorf("unexpected prompts after retry: %s", got)
+	}
+
+	// Editing a non-prompt message is rejected
+	if w := h.post("/edit", EditMessageRequest{SequenceID: 1, Message: "x"}); w.Code != http.StatusBadRequest {
+		t.Errorf("edit system message: expected 400, got %d", w.Code)
+	}
+}
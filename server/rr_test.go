@@ -0,0 +1,21 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRRConversation(t *testing.T) {
+	h := NewRRTestHarness(t, "rr_conversation")
+	defer h.Close()
+
+	h.NewConversation("Reply with exactly the word: pong", "")
+	if resp := h.WaitResponse(); !strings.Contains(strings.ToLower(resp), "pong") {
+		t.Errorf("expected pong, got %q", resp)
+	}
+
+	h.Chat("Now reply with exactly the word: ping")
+	if resp := h.WaitResponse(); !strings.Contains(strings.ToLower(resp), "ping") {
+		t.Errorf("expected ping, got %q", resp)
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/llm/llmhttp"
+	"shelley.exe.dev/loop"
+)
+
+// turnPriority orders the turns waiting for the scheduler. Turns of lower
+// priority start only when no turn of higher priority waits.
+type turnPriority int
+
+const (
+	// priorityInteractive is for turns started by a user.
+	priorityInteractive turnPriority = iota
+	// priorityBackground is for turns started without a user waiting, such
+	// as batch tasks.
+	priorityBackground
+)
+
+func (p turnPriority) String() string {
+	if p == priorityBackground {
+		return "background"
+	}
+	return "interactive"
+}
+
+type turnPriorityKey struct{}
+
+// withTurnPriority makes the user messages accepted with ctx start turns of
+// priority p.
+func withTurnPriority(ctx context.Context, p turnPriority) context.Context {
+	return context.WithValue(ctx, turnPriorityKey{}, p)
+}
+
+// turnPriorityFrom returns the priority set by withTurnPriority, or
+// priorityInteractive.
+func turnPriorityFrom(ctx context.Context) turnPriority {
+	p, _ := ctx.Value(turnPriorityKey{}).(turnPriority)
+	return p
+}
+
+// llm returns the priority of the LLM requests of turns of priority p.
+func (p turnPriority) llm() llmhttp.Priority {
+	if p == priorityBackground {
+		return llmhttp.PriorityBackground
+	}
+	return llmhttp.PriorityInteractive
+}
+
+// turnWaiter is a conversation waiting to start a turn.
+type turnWaiter struct {
+	conversationID string
+	priority       turnPriority
+	since          time.Time
+	ready          chan struct{} // closed when the turn may start
+}
+
+// turnScheduler caps how many conversations run turns at once. Turns over
+// the limit wait in a queue ordered by priority, then first come first
+// served.
+type turnScheduler struct {
+	mu      sync.Mutex
+	limit   int // 0 for no limit
+	running map[string]time.Time
+	queue   []*turnWaiter
+	// onQueueChange is called with the queued conversations, in order, when
+	// the queue changes, and with those that left the queue.
+	onQueueChange func(queued, started []string)
+}
+
+func newTurnScheduler() *turnScheduler {
+	return &turnScheduler{running: make(map[string]time.Time)}
+}
+
+// setLimit changes the limit, starting waiting turns if it was raised.
+func (ts *turnScheduler) setLimit(limit int) {
+	ts.mu.Lock()
+	ts.limit = limit
+	started := ts.dispatchLocked()
+	ts.mu.Unlock()
+	ts.notify(started)
+}
+
+// wait blocks until a turn of the conversation may start. The returned
+// function must be called when the turn ends.
+func (ts *turnScheduler) wait(ctx context.Context, conversationID string, priority turnPriority) (func(), error) {
+	ts.mu.Lock()
+	if ts.limit <= 0 || (len(ts.running) < ts.limit && len(ts.queue) == 0) {
+		ts.running[conversationID] = time.Now()
+		ts.mu.Unlock()
+		return ts.doneFunc(conversationID), nil
+	}
+	w := &turnWaiter{conversationID: conversationID, priority: priority, since: time.Now(), ready: make(chan struct{})}
+	i := len(ts.queue)
+	for i > 0 && ts.queue[i-1].priority > priority {
+		i--
+	}
+	ts.queue = append(ts.queue[:i], append([]*turnWaiter{w}, ts.queue[i:]...)...)
+	ts.mu.Unlock()
+	ts.notify(nil)
+
+	select {
+	case <-w.ready:
+		return ts.doneFunc(conversationID), nil
+	case <-ctx.Done():
+	}
+	ts.mu.Lock()
+	for i, queued := range ts.queue {
+		if queued == w {
+			ts.queue = append(ts.queue[:i], ts.queue[i+1:]...)
+			ts.mu.Unlock()
+			ts.notify(nil)
+			return nil, ctx.Err()
+		}
+	}
+	ts.mu.Unlock()
+	// The turn was started as the context ended
+	ts.doneFunc(conversationID)()
+	return nil, ctx.Err()
+}
+
+func (ts *turnScheduler) doneFunc(conversationID string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ts.mu.Lock()
+			delete(ts.running, conversationID)
+			started := ts.dispatchLocked()
+			ts.mu.Unlock()
+			ts.notify(started)
+		})
+	}
+}
+
+// dispatchLocked starts waiting turns while there is room, returning their
+// conversations.
+func (ts *turnScheduler) dispatchLocked() []string {
+	var started []string
+	for len(ts.queue) > 0 && (ts.limit <= 0 || len(ts.running) < ts.limit) {
+		w := ts.queue[0]
+		ts.queue = ts.queue[1:]
+		ts.running[w.conversationID] = time.Now()
+		close(w.ready)
+		started = append(started, w.conversationID)
+	}
+	return started
+}
+
+func (ts *turnScheduler) notify(started []string) {
+	if ts.onQueueChange == nil {
+		return
+	}
+	ts.mu.Lock()
+	queued := make([]string, len(ts.queue))
+	for i, w := range ts.queue {
+		queued[i] = w.conversationID
+	}
+	ts.mu.Unlock()
+	ts.onQueueChange(queued, started)
+}
+
+// position returns the conversation's 1-based position in the queue, or 0
+// if it isn't waiting.
+func (ts *turnScheduler) position(conversationID string) int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for i, w := range ts.queue {
+		if w.conversationID == conversationID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// waitTurn returns the loop's WaitTurn, which makes the conversation's turns
+// wait for the scheduler.
+func (cm *ConversationManager) waitTurn() loop.TurnWaitFunc {
+	cm.mu.Lock()
+	scheduler, subagent := cm.scheduler, cm.subagent
+	cm.mu.Unlock()
+	if scheduler == nil || subagent {
+		return nil
+	}
+	return func(ctx context.Context) (func(), error) {
+		cm.mu.Lock()
+		priority, timings := cm.turnPriority, cm.timings
+		cm.mu.Unlock()
+		start := time.Now()
+		done, err := scheduler.wait(ctx, cm.conversationID, priority)
+		timings.observe(phaseTurnWait, time.Since(start))
+		return done, err
+	}
+}
+
+// llmPriority returns the priority of the LLM requests of the conversation's
+// current turn.
+func (cm *ConversationManager) llmPriority() llmhttp.Priority {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.turnPriority.llm()
+}
+
+// QueuedTurnAPI is a conversation waiting to start a turn
+type QueuedTurnAPI struct {
+	ConversationID string    `json:"conversation_id"`
+	Position       int       `json:"position"`
+	Priority       string    `json:"priority"`
+	Since          time.Time `json:"since"`
+}
+
+// SchedulerAPI is the response from /api/scheduler
+type SchedulerAPI struct {
+	// Limit is how many conversations can run turns at once; 0 for no limit
+	Limit   int             `json:"limit"`
+	Running []string        `json:"running"`
+	Queued  []QueuedTurnAPI `json:"queued"`
+}
+
+func (ts *turnScheduler) snapshot() SchedulerAPI {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	resp := SchedulerAPI{Limit: ts.limit, Running: []string{}, Queued: []QueuedTurnAPI{}}
+	for id := range ts.running {
+		resp.Running = append(resp.Running, id)
+	}
+	for i, w := range ts.queue {
+		resp.Queued = append(resp.Queued, QueuedTurnAPI{
+			ConversationID: w.conversationID,
+			Position:       i + 1,
+			Priority:       w.priority.String(),
+			Since:          w.since,
+		})
+	}
+	return resp
+}
+
+// SetMaxConcurrentTurns caps how many conversations run turns at once; other
+// turns wait in a queue, where those started by users go before batch tasks.
+// Zero removes the limit.
+func (s *Server) SetMaxConcurrentTurns(n int) {
+	s.scheduler.setLimit(n)
+}
+
+// publishQueuePositions tells clients the queue positions of waiting
+// conversations, and that started ones left the queue.
+func (s *Server) publishQueuePositions(queued, started []string) {
+	for i, id := range queued {
+		s.publishConversationState(ConversationState{ConversationID: id, Working: true, QueuePosition: i + 1})
+	}
+	for _, id := range started {
+		s.publishConversationState(ConversationState{ConversationID: id, Working: true})
+	}
+}
+
+// handleScheduler handles GET /api/scheduler: the turns running and waiting
+// to start.
+func (s *Server) handleScheduler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.scheduler.snapshot())
+}
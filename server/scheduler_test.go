@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/llm/llmhttp"
+)
+
+func TestTurnScheduler(t *testing.T) {
+	ts := newTurnScheduler()
+	ts.setLimit(1)
+	ctx := t.Context()
+
+	started := make(chan string, 10)
+	wait := func(ctx context.Context, id string, p turnPriority) chan func() {
+		done := make(chan func(), 1)
+		go func() {
+			release, err := ts.wait(ctx, id, p)
+			if err != nil {
+				close(done)
+				return
+			}
+			started <- id
+			done <- release
+		}()
+		// Let the turn join the queue before the next one
+		deadline := time.Now().Add(time.Second)
+		for ts.position(id) == 0 && len(started) == 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		return done
+	}
+
+	first := wait(ctx, "running", priorityInteractive)
+	if id := <-started; id != "running" {
+		t.Fatalf("started %q", id)
+	}
+	batch := wait(ctx, "batch", priorityBackground)
+	cancelCtx, cancel := context.WithCancel(ctx)
+	canceled := wait(cancelCtx, "canceled", priorityInteractive)
+	user := wait(ctx, "user", priorityInteractive)
+
+	// Interactive turns go before background ones, in arrival order
+	queued := ts.snapshot().Queued
+	var order []string
+	for _, q := range queued {
+		order = append(order, q.ConversationID)
+	}
+	if want := []string{"canceled", "user", "batch"}; !slices.Equal(order, want) {
+		t.Fatalf("queue = %v, want %v", order, want)
+	}
+	cancel()
+	if _, ok := <-canceled; ok {
+		t.Fatal("canceled turn started")
+	}
+	if pos := ts.position("user"); pos != 1 {
+		t.Errorf("position after cancellation = %d, want 1", pos)
+	}
+
+	// Each finished turn lets the next one start
+	(<-first)()
+	if id := <-started; id != "user" {
+		t.Fatalf("started %q, want user", id)
+	}
+	(<-user)()
+	if id := <-started; id != "batch" {
+		t.Fatalf("started %q, want batch", id)
+	}
+	release := <-batch
+	release()
+	release() // releasing twice is harmless
+	if s := ts.snapshot(); len(s.Running) != 0 || len(s.Queued) != 0 {
+		t.Errorf("scheduler not empty: %+v", s)
+	}
+}
+
+func TestSchedulerQueuePositions(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.SetMaxConcurrentTurns(1)
+
+	// A turn holds the only slot, so the conversation's turn waits
+	release, err := h.server.scheduler.wait(t.Context(), "other", priorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.NewConversation("echo: hello", "")
+	deadline := time.Now().Add(h.timeout)
+	for h.server.scheduler.position(h.convID) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("turn did not wait for the scheduler")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	h.server.handleScheduler(w, httptest.NewRequest("GET", "/api/scheduler", nil))
+	var resp SchedulerAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Limit != 1 || len(resp.Queued) != 1 || resp.Queued[0].ConversationID != h.convID || resp.Queued[0].Priority != "interactive" {
+		t.Errorf("scheduler = %+v", resp)
+	}
+
+	release()
+	if got := h.WaitResponse(); got != "hello" {
+		t.Errorf("response = %q, want hello", got)
+	}
+}
+
+func TestLLMRequestPriority(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("echo: hello", "")
+	h.WaitResponse()
+
+	manager, err := h.server.getOrCreateConversationManager(t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := manager.llmPriority(); got != llmhttp.PriorityInteractive {
+		t.Errorf("priority of a user's turn = %s, want interactive", got)
+	}
+
+	// A batch's turn sends background requests, and a user's next turn
+	// interactive ones again
+	if err := h.server.sendPrompt(withTurnPriority(t.Context(), priorityBackground), h.convID, "predictable", "echo: batch"); err != nil {
+		t.Fatal(err)
+	}
+	if got := manager.llmPriority(); got != llmhttp.PriorityBackground {
+		t.Errorf("priority of a batch's turn = %s, want background", got)
+	}
+	h.waitIdle()
+	h.Chat("echo: again")
+	if got := manager.llmPriority(); got != llmhttp.PriorityInteractive {
+		t.Errorf("priority of a user's next turn = %s, want interactive", got)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/loop"
+)
+
+func TestScriptedToolCallThenText(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.Script("check the date",
+		loop.ScriptToolCall("bash", map[string]string{"command": "echo scripted"}),
+		loop.ScriptText("All done."),
+	)
+	h.NewConversation("check the date", "")
+	if result := h.WaitToolResult(); !strings.Contains(result, "scripted") {
+		t.Errorf("expected the scripted command's output, got %q", result)
+	}
+	if resp := h.WaitResponse(); resp != "All done." {
+		t.Errorf("expected the scripted text, got %q", resp)
+	}
+}
+
+func TestScriptedError(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.Script("break",
+		loop.ScriptToolCall("bash", map[string]string{"command": "echo ok"}),
+		loop.ScriptError("overloaded"),
+	)
+	h.NewConversation("break", "")
+	h.WaitToolResult()
+	h.waitIdle()
+
+	messages, err := h.db.ListMessages(t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := messages[len(messages)-1]
+	if last.Type != string(db.MessageTypeError) || last.LlmData == nil || !strings.Contains(*last.LlmData, "overloaded") {
+		t.Errorf("expected the scripted error to end the turn, got a %s message", last.Type)
+	}
+}
+
+func TestScriptedCancelMidToolCall(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	started := filepath.Join(t.TempDir(), "started")
+	h.Script("slow task",
+		loop.ScriptToolCall("bash", map[string]string{"command": "touch " + started + "; sleep 60"}),
+		loop.ScriptText("unreachable"),
+	)
+	h.NewConversation("slow task", "")
+
+	deadline := time.Now().Add(h.timeout)
+	for {
+		if _, err := os.Stat(started); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("scripted tool call did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.Cancel()
+
+	if result := h.WaitToolResult(); !strings.Contains(result, "cancelled") {
+		t.Errorf("expected a cancelled tool result, got %q", result)
+	}
+	if resp := h.WaitResponse(); resp != "[Operation cancelled]" {
+		t.Errorf("expected the cancellation marker, got %q", resp)
+	}
+	h.Chat("echo: still here")
+	if resp := h.WaitResponse(); resp != "still here" {
+		t.Errorf("expected the conversation to continue, got %q", resp)
+	}
+}
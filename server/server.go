@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -21,9 +22,14 @@ import (
 	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/email"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
 	"shelley.exe.dev/models"
+	"shelley.exe.dev/transcribe"
 	"shelley.exe.dev/ui"
+	"shelley.exe.dev/webpush"
+	"shelley.exe.dev/wslpath"
 )
 
 // APIMessage is the message format sent to clients
@@ -47,12 +53,32 @@ type ConversationState struct {
 	ConversationID string `json:"conversation_id"`
 	Working        bool   `json:"working"`
 	Model          string `json:"model,omitempty"`
+	// QueuePosition is the 1-based position of the conversation's next turn
+	// among those waiting for the scheduler, or 0 if it isn't waiting
+	QueuePosition int `json:"queue_position,omitempty"`
+	// Offline is set while the conversation's turn is held because the LLM
+	// providers are unreachable; it resumes when they are back
+	Offline bool `json:"offline,omitempty"`
+	// Approval is the risky command the conversation's turn is held on
+	// until the user approves or denies it
+	Approval *CommandApprovalAPI `json:"approval,omitempty"`
 }
 
 // ConversationWithState combines a conversation with its working state.
 type ConversationWithState struct {
 	generated.Conversation
 	Working bool `json:"working"`
+	// ProjectRoot is the root of the conversation's project, as in
+	// /api/conversations/projects
+	ProjectRoot string `json:"project_root,omitempty"`
+	// Summary is generated when the conversation goes idle
+	Summary *ConversationSummaryAPI `json:"summary,omitempty"`
+	// UnreadCount is the number of user and agent messages since the
+	// requesting user last read the conversation; zero if they never did
+	UnreadCount int `json:"unread_count,omitempty"`
+	// ChangedFiles are the files the agent edited in the unread messages
+	ChangedFiles []string `json:"changed_files,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
 }
 
 // StreamResponse represents the response format for conversation streaming
@@ -63,6 +89,10 @@ type StreamResponse struct {
 	ContextWindowSize uint64                 `json:"context_window_size,omitempty"`
 	// ConversationListUpdate is set when another conversation in the list changed
 	ConversationListUpdate *ConversationListUpdate `json:"conversation_list_update,omitempty"`
+	// Events are new events of the conversation's timeline
+	Events []ConversationEventAPI `json:"events,omitempty"`
+	// Presence is set when users start or stop viewing the conversation
+	Presence *PresenceAPI `json:"presence,omitempty"`
 }
 
 // LLMProvider is an interface for getting LLM services
@@ -83,6 +113,8 @@ func NewLLMServiceManager(cfg *LLMConfig) LLMProvider {
 		FireworksAPIKey:     cfg.FireworksAPIKey,
 		ClaudeCodeBridgeURL: cfg.ClaudeCodeBridgeURL,
 		Gateway:             cfg.Gateway,
+		ThinkingBudgets:     cfg.ThinkingBudgets,
+		ResponseCache:       cfg.ResponseCache,
 		Logger:              cfg.Logger,
 		DB:                  cfg.DB,
 	}
@@ -92,6 +124,9 @@ func NewLLMServiceManager(cfg *LLMConfig) LLMProvider {
 		// This shouldn't happen in practice, but handle it gracefully
 		cfg.Logger.Error("Failed to create models manager", "error", err)
 	}
+	if manager != nil && cfg.DB != nil {
+		loadProviderCredentials(context.Background(), cfg.DB, manager, cfg.Logger)
+	}
 
 	return manager
 }
@@ -216,14 +251,34 @@ type Server struct {
 	mu                  sync.Mutex
 	logger              *slog.Logger
 	predictableOnly     bool
-	terminalURL         string
-	defaultModel        string
-	links               []Link
+	settingsMu          sync.RWMutex
+	settings            Settings     // settings a config reload can change
+	configReloader      func() error // rereads the config file; nil if there is none
 	requireHeader       string
 	conversationGroup   singleflight.Group[string, *ConversationManager]
 	versionChecker      *VersionChecker
+	modelChecks         modelChecks
+	tls                 TLSConfig
+	basePath            string                 // path prefix the server is mounted under, e.g. "/shelley"; empty for the root
+	workspacesDir       string                 // where repositories are cloned for new conversations; empty disables cloning
+	transcriber         transcribe.Transcriber // speech-to-text for dictation; nil disables it
+	pushSender          *webpush.Sender        // sends Web Push notifications; nil disables them
+	emailNotifier       *email.Notifier        // sends notification emails; nil disables them
+	auditedLogins       sync.Map               // users whose login has been audited since the server started
+	projectInfos        sync.Map               // working directory -> projectInfo
+	instanceID          string                 // holder of conversation leases when instances share the database; empty disables leasing
+	scheduler           *turnScheduler         // caps how many conversations run turns at once
+	loopTimings         *loopTimings           // latencies of the phases of turns
+	connectivity        *connectivity          // whether the LLM providers are reachable
+	wsl                 *wslpath.Translator    // translates paths of the Windows host; nil outside WSL
 }
 
+// DefaultIdleTimeout is how long a conversation can be idle before Cleanup releases its resources
+const DefaultIdleTimeout = 30 * time.Minute
+
+// DefaultLoopLimits are the limits of turns unless SetLoopLimits changes them.
+var DefaultLoopLimits = loop.Limits{MaxToolIterations: 200, MaxRepeatedToolCalls: 5}
+
 // NewServer creates a new server instance
 func NewServer(database *db.DB, llmManager LLMProvider, toolSetConfig claudetool.ToolSetConfig, logger *slog.Logger, predictableOnly bool, terminalURL, defaultModel, requireHeader string, links []Link) *Server {
 	s := &Server{
@@ -233,17 +288,34 @@ func NewServer(database *db.DB, llmManager LLMProvider, toolSetConfig claudetool
 		activeConversations: make(map[string]*ConversationManager),
 		logger:              logger,
 		predictableOnly:     predictableOnly,
-		terminalURL:         terminalURL,
-		defaultModel:        defaultModel,
 		requireHeader:       requireHeader,
-		links:               links,
 		versionChecker:      NewVersionChecker(),
-	}
+		scheduler:           newTurnScheduler(),
+		loopTimings:         newLoopTimings(),
+		connectivity:        newConnectivity(logger),
+		wsl:                 wslpath.Detect(),
+		settings: Settings{
+			DefaultModel: defaultModel,
+			TerminalURL:  terminalURL,
+			Links:        links,
+			IdleTimeout:  DefaultIdleTimeout,
+			LoopLimits:   DefaultLoopLimits,
+		},
+	}
+	s.scheduler.onQueueChange = s.publishQueuePositions
+	s.connectivity.onChange = s.publishConnectivity
+	s.connectivity.check = s.providersReachable
 
 	// Set up subagent support
 	s.toolSetConfig.SubagentRunner = NewSubagentRunner(s)
 	s.toolSetConfig.SubagentDB = &db.SubagentDBAdapter{DB: database}
 
+	// Set up memories
+	s.toolSetConfig.MemoryStore = &db.MemoryDBAdapter{DB: database}
+	s.toolSetConfig.PlanStore = &db.PlanDBAdapter{DB: database}
+	s.toolSetConfig.TodoStore = &db.TodoDBAdapter{DB: database}
+	s.toolSetConfig.ToolOutputStore = &db.ToolOutputDBAdapter{DB: database}
+
 	return s
 }
 
@@ -252,18 +324,39 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	// API routes - wrap with gzip where beneficial
 	mux.Handle("/api/conversations", gzipHandler(http.HandlerFunc(s.handleConversations)))
 	mux.Handle("/api/conversations/archived", gzipHandler(http.HandlerFunc(s.handleArchivedConversations)))
-	mux.Handle("/api/conversations/new", http.HandlerFunc(s.handleNewConversation))           // Small response
-	mux.Handle("/api/conversations/continue", http.HandlerFunc(s.handleContinueConversation)) // Small response
+	mux.Handle("GET /api/conversations/projects", gzipHandler(http.HandlerFunc(s.handleConversationProjects)))
+	mux.Handle("POST /api/conversations/bulk", gzipHandler(http.HandlerFunc(s.handleBulkConversations)))
+	mux.Handle("/api/conversations/new", http.HandlerFunc(s.handleNewConversation))              // Small response
+	mux.Handle("/api/conversations/continue", http.HandlerFunc(s.handleContinueConversation))    // Small response
+	mux.Handle("GET /api/conversations/{id}/draft", http.HandlerFunc(s.handleConversationDraft)) // Small response
+	mux.Handle("PUT /api/conversations/{id}/draft", http.HandlerFunc(s.handleConversationDraft))
+	mux.Handle("GET /api/conversations/{id}/todos", http.HandlerFunc(s.handleConversationTodos))
+	mux.Handle("GET /api/conversations/{id}/events", gzipHandler(http.HandlerFunc(s.handleConversationEvents)))
+	mux.Handle("GET /api/conversations/{id}/tool-results/{tool_use_id}/full", gzipHandler(http.HandlerFunc(s.handleToolOutput)))
+	mux.Handle("GET /api/conversations/{id}/terminal", http.HandlerFunc(s.handleConversationTerminal)) // Websocket
+	mux.Handle("POST /api/conversations/{id}/share", http.HandlerFunc(s.handleShareConversation))
+	mux.Handle("POST /api/conversations/{id}/replay", http.HandlerFunc(s.handleReplayConversation))
+	mux.Handle("GET /api/conversations/{id}/replays", gzipHandler(http.HandlerFunc(s.handleConversationReplays)))
+	mux.Handle("GET /api/conversations/{id}/context-breakdown", gzipHandler(http.HandlerFunc(s.handleContextBreakdown)))
 	mux.Handle("/api/conversation/", http.StripPrefix("/api/conversation", s.conversationMux()))
 	mux.Handle("/api/conversation-by-slug/", gzipHandler(http.HandlerFunc(s.handleConversationBySlug)))
 	mux.Handle("/api/validate-cwd", http.HandlerFunc(s.handleValidateCwd)) // Small response
 	mux.Handle("/api/list-directory", gzipHandler(http.HandlerFunc(s.handleListDirectory)))
 	mux.Handle("/api/create-directory", http.HandlerFunc(s.handleCreateDirectory))
+	mux.Handle("GET /api/directories/recent", http.HandlerFunc(s.handleRecentDirectories))    // Small response
+	mux.Handle("PUT /api/directories/favorites", http.HandlerFunc(s.handleFavoriteDirectory)) // Small response
+	mux.Handle("POST /api/workspaces/clone", http.HandlerFunc(s.handleCloneWorkspace))
 	mux.Handle("/api/git/diffs", gzipHandler(http.HandlerFunc(s.handleGitDiffs)))
 	mux.Handle("/api/git/diffs/", gzipHandler(http.HandlerFunc(s.handleGitDiffFiles)))
 	mux.Handle("/api/git/file-diff/", gzipHandler(http.HandlerFunc(s.handleGitFileDiff)))
-	mux.HandleFunc("/api/upload", s.handleUpload)                      // Binary uploads
-	mux.HandleFunc("/api/read", s.handleRead)                          // Serves images
+	mux.HandleFunc("/api/upload", s.handleUpload)              // Binary uploads
+	mux.HandleFunc("/api/read", s.handleRead)                  // Serves images
+	mux.HandleFunc("POST /api/transcribe", s.handleTranscribe) // Audio uploads
+	mux.Handle("POST /api/push/subscriptions", http.HandlerFunc(s.handlePushSubscription))
+	mux.Handle("DELETE /api/push/subscriptions", http.HandlerFunc(s.handlePushSubscription))
+	mux.Handle("/api/notification-preferences", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleNotificationPreferences(w, r, "")
+	}))
 	mux.Handle("/api/write-file", http.HandlerFunc(s.handleWriteFile)) // Small response
 	mux.HandleFunc("/api/exec-ws", s.handleExecWS)                     // Websocket for shell commands
 
@@ -274,6 +367,39 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 
 	// Models API (dynamic list refresh)
 	mux.Handle("/api/models", http.HandlerFunc(s.handleModels))
+	mux.Handle("GET /api/providers", http.HandlerFunc(s.handleProviders))
+	mux.Handle("POST /api/providers/{provider}", http.HandlerFunc(s.handleProvider))
+	mux.Handle("DELETE /api/providers/{provider}", http.HandlerFunc(s.handleProvider))
+	mux.Handle("GET /api/health/providers", http.HandlerFunc(s.handleProviderHealth))
+	mux.Handle("GET /api/health/full", http.HandlerFunc(s.handleFullHealth))
+	mux.Handle("POST /api/config/reload", http.HandlerFunc(s.handleReloadConfig))
+	mux.Handle("/api/projects", http.HandlerFunc(s.handleProjects))
+	mux.Handle("/api/commands", http.HandlerFunc(s.handleCommands))
+	mux.Handle("GET /api/scheduler", http.HandlerFunc(s.handleScheduler)) // Small response
+	mux.Handle("GET /api/connectivity", http.HandlerFunc(s.handleConnectivity))
+	mux.Handle("GET /api/quick-search", gzipHandler(http.HandlerFunc(s.handleQuickSearch)))
+	mux.Handle("/api/snippets", http.HandlerFunc(s.handleSnippets))
+	mux.Handle("/api/snippets/", http.HandlerFunc(s.handleSnippet))
+	mux.Handle("/api/templates", http.HandlerFunc(s.handleTemplates))
+	mux.Handle("/api/templates/{id}", http.HandlerFunc(s.handleTemplate))
+	mux.Handle("/api/http-tools", http.HandlerFunc(s.handleHTTPTools))
+	mux.Handle("/api/http-tools/", http.HandlerFunc(s.handleHTTPTool))
+	mux.Handle("/api/tokens", http.HandlerFunc(s.handleAPITokens))
+	mux.Handle("DELETE /api/tokens/{id}", http.HandlerFunc(s.handleAPIToken))
+	mux.Handle("GET /api/admin/audit", http.HandlerFunc(s.handleAuditLog))
+	mux.Handle("GET /api/admin/audit/export", http.HandlerFunc(s.handleAuditExport))
+	mux.Handle("/api/admin/response-cache", http.HandlerFunc(s.handleResponseCache))
+	mux.Handle("/api/memories", http.HandlerFunc(s.handleMemories))
+	mux.Handle("/api/batches", http.HandlerFunc(s.handleBatches))
+	mux.Handle("GET /api/batches/{id}", http.HandlerFunc(s.handleBatch))
+	mux.Handle("POST /api/batches/{id}/cancel", http.HandlerFunc(s.handleCancelBatch))
+	mux.Handle("/api/comparisons", http.HandlerFunc(s.handleComparisons))
+	mux.Handle("GET /api/comparisons/{id}", http.HandlerFunc(s.handleComparison))
+	mux.Handle("POST /api/comparisons/{id}/choose", http.HandlerFunc(s.handleChooseComparison))
+	mux.Handle("GET /api/replays/{id}", gzipHandler(http.HandlerFunc(s.handleReplay)))
+	mux.Handle("GET /api/annotations", http.HandlerFunc(s.handleAnnotatedMessages))
+	mux.Handle("GET /api/analytics", http.HandlerFunc(s.handleAnalytics))
+	mux.Handle("/api/system-prompt-template", http.HandlerFunc(s.handleSystemPromptTemplate))
 
 	// Version endpoints
 	mux.Handle("GET /version", http.HandlerFunc(s.handleVersion))
@@ -288,6 +414,12 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("GET /debug/llm_requests/{id}/request", http.HandlerFunc(s.handleDebugLLMRequestBody))
 	mux.Handle("GET /debug/llm_requests/{id}/request_full", http.HandlerFunc(s.handleDebugLLMRequestBodyFull))
 	mux.Handle("GET /debug/llm_requests/{id}/response", http.HandlerFunc(s.handleDebugLLMResponseBody))
+	mux.Handle("GET /debug/db", http.HandlerFunc(s.handleDebugDB))
+	mux.Handle("GET /api/debug/loop-timings", http.HandlerFunc(s.handleLoopTimings))
+	s.registerProfiling(mux)
+
+	// Read-only shared transcripts; the token is the credential
+	mux.Handle("GET /share/{token}", gzipHandler(http.HandlerFunc(s.handleSharedConversation)))
 
 	// Serve embedded UI assets
 	mux.Handle("/", s.staticHandler(ui.Assets()))
@@ -300,7 +432,7 @@ func (s *Server) handleValidateCwd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := r.URL.Query().Get("path")
+	path := s.hostPath(r.URL.Query().Get("path"))
 	if path == "" {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -364,7 +496,7 @@ func (s *Server) handleListDirectory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := r.URL.Query().Get("path")
+	path := s.hostPath(r.URL.Query().Get("path"))
 	if path == "" {
 		// Default to home directory or root
 		homeDir, err := os.UserHomeDir()
@@ -377,6 +509,10 @@ func (s *Server) handleListDirectory(w http.ResponseWriter, r *http.Request) {
 
 	// Clean and resolve the path
 	path = filepath.Clean(path)
+	if path == filepath.VolumeName(path) {
+		// A bare drive such as C: means its root, not its current directory
+		path += string(filepath.Separator)
+	}
 
 	// Verify path exists and is a directory
 	info, err := os.Stat(path)
@@ -451,9 +587,10 @@ func (s *Server) handleListDirectory(w http.ResponseWriter, r *http.Request) {
 		parent = ""
 	}
 
+	// The picker builds paths with slashes, which Windows accepts as well
 	response := ListDirectoryResponse{
-		Path:    path,
-		Parent:  parent,
+		Path:    s.clientPath(r, filepath.ToSlash(path)),
+		Parent:  s.clientPath(r, filepath.ToSlash(parent)),
 		Entries: entries,
 	}
 
@@ -528,7 +665,7 @@ func (s *Server) handleCreateDirectory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Clean the path
-	path := filepath.Clean(req.Path)
+	path := filepath.Clean(s.hostPath(req.Path))
 
 	// Check if path already exists
 	if _, err := os.Stat(path); err == nil {
@@ -589,6 +726,18 @@ func (s *Server) getOrCreateConversationManager(ctx context.Context, conversatio
 		}
 
 		manager := NewConversationManager(conversationID, s.db, s.logger, s.toolSetConfig, recordMessage, onStateChange)
+		settings := s.Settings()
+		manager.limits = settings.LoopLimits
+		manager.concurrentReadOnly = settings.ConcurrentReadOnlyTools
+		manager.promptOverrides = settings.PromptOverrides
+		manager.leaseHolder = s.instanceID
+		manager.scheduler = s.scheduler
+		manager.timings = s.loopTimings
+		manager.connectivity = s.connectivity
+		manager.wsl = s.wsl
+		manager.onApproval = func(approval CommandApprovalAPI) {
+			go s.notifyCommandApproval(conversationID, approval)
+		}
 		if err := manager.Hydrate(ctx); err != nil {
 			return nil, err
 		}
@@ -633,6 +782,8 @@ func ExtractDisplayData(message llm.Message) interface{} {
 
 // recordMessage records a new message to the database and also notifies subscribers
 func (s *Server) recordMessage(ctx context.Context, conversationID string, message llm.Message, usage llm.Usage) error {
+	defer func(start time.Time) { s.loopTimings.observe(phaseRecordMessage, time.Since(start)) }(time.Now())
+
 	// Log message based on role
 	if message.Role == llm.MessageRoleUser {
 		s.logger.Info("User message", "conversation_id", conversationID, "content_items", len(message.Content))
@@ -649,12 +800,17 @@ func (s *Server) recordMessage(ctx context.Context, conversationID string, messa
 	// Extract display data from content items
 	displayDataToStore := ExtractDisplayData(message)
 
+	var userData any
+	if author := messageAuthorFrom(ctx); author != "" && messageType == db.MessageTypeUser {
+		userData = UserMessageData{Author: author}
+	}
+
 	// Create message
 	createdMsg, err := s.db.CreateMessage(ctx, db.CreateMessageParams{
 		ConversationID:      conversationID,
 		Type:                messageType,
 		LLMData:             message,
-		UserData:            nil,
+		UserData:            userData,
 		UsageData:           usage,
 		DisplayData:         displayDataToStore,
 		ExcludedFromContext: message.ExcludedFromContext,
@@ -793,6 +949,7 @@ func (s *Server) notifySubscribersNewMessage(ctx context.Context, conversationID
 	// Update agent working state based on message type
 	if isAgentEndOfTurn(newMsg) {
 		manager.SetAgentWorking(false)
+		go s.notifyTurnEnded(ctx, manager, &conversation, newMsg)
 	}
 
 	// Publish only the new message
@@ -858,25 +1015,191 @@ func (s *Server) getWorkingConversations() map[string]bool {
 	return working
 }
 
-// Cleanup removes inactive conversation managers
+// SetIdleTimeout sets how long a conversation can be idle before its
+// resources are released; zero disables the cleanup.
+func (s *Server) SetIdleTimeout(d time.Duration) {
+	s.updateSettings(func(settings *Settings) { settings.IdleTimeout = d })
+}
+
+// SetLoopLimits sets the limits of turns. Conversations can set their own timeouts.
+func (s *Server) SetLoopLimits(limits loop.Limits) {
+	s.updateSettings(func(settings *Settings) { settings.LoopLimits = limits })
+}
+
+// SetBasePath mounts the UI and API under a path prefix such as "/shelley/",
+// for serving behind a reverse proxy alongside other apps.
+func (s *Server) SetBasePath(p string) {
+	p = "/" + strings.Trim(p, "/")
+	if p == "/" {
+		p = ""
+	}
+	s.basePath = p
+}
+
+// basePathHandler serves next under the base path, with the prefix removed from
+// the request path so that routes and middleware see the same paths as at the root.
+func (s *Server) basePathHandler(next http.Handler) http.Handler {
+	if s.basePath == "" {
+		return next
+	}
+	mux := http.NewServeMux()
+	mux.Handle(s.basePath+"/", http.StripPrefix(s.basePath, next))
+	mux.Handle(s.basePath, http.RedirectHandler(s.basePath+"/", http.StatusMovedPermanently))
+	return mux
+}
+
+// Cleanup releases the resources of conversations idle for longer than the
+// idle timeout: it stops their loops, which shuts down the tools' browser and
+// other per-conversation resources. The next user message rehydrates the
+// conversation from the database and starts a new loop. Managers are dropped
+// unless a client is still streaming the conversation. Conversations going
+// idle are summarized for the conversation list.
 func (s *Server) Cleanup() {
+	idleTimeout := s.Settings().IdleTimeout
+	if idleTimeout <= 0 {
+		return
+	}
+	// Stopping loops can take a while, so it is done without holding s.mu
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	now := time.Now()
+	idleManagers := make(map[string]*ConversationManager)
 	for id, manager := range s.activeConversations {
-		// Remove managers that have been inactive for more than 30 minutes
-		manager.mu.Lock()
-		lastActivity := manager.lastActivity
-		manager.mu.Unlock()
-		if now.Sub(lastActivity) > 30*time.Minute {
-			manager.stopLoop()
+		if idle, running, streamed := manager.idleState(idleTimeout); idle && (running || !streamed) {
+			idleManagers[id] = manager
+		}
+	}
+	s.mu.Unlock()
+
+	for id, manager := range idleManagers {
+		if err := manager.ReloadContext(); err != nil {
+			// The agent started working again
+			continue
+		}
+		s.mu.Lock()
+		// A request getting the manager since touched it, making it not idle
+		idle, _, streamed := manager.idleState(idleTimeout)
+		if idle && !streamed && s.activeConversations[id] == manager {
 			delete(s.activeConversations, id)
-			s.logger.Debug("Cleaned up inactive conversation", "conversationID", id)
+		}
+		s.mu.Unlock()
+		s.logger.Debug("Cleaned up idle conversation", "conversationID", id, "streamed", streamed)
+		go func() {
+			if err := s.summarizeConversation(context.Background(), id); err != nil {
+				s.logger.Warn("Failed to summarize conversation", "conversationID", id, "error", err)
+			}
+		}()
+	}
+}
+
+// checkpointTurns stops the loops and tool processes of all active
+// conversations, recording the turns in flight so that they are resumed when
+// the server starts again.
+func (s *Server) checkpointTurns(ctx context.Context) {
+	s.mu.Lock()
+	managers := make(map[string]*ConversationManager, len(s.activeConversations))
+	for id, manager := range s.activeConversations {
+		managers[id] = manager
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for id, manager := range managers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			interrupted, err := manager.Checkpoint(ctx, interruptReasonShutdown)
+			if err != nil {
+				s.logger.Error("Failed to checkpoint conversation", "conversationID", id, "error", err)
+			} else if interrupted {
+				s.logger.Info("Checkpointed interrupted turn", "conversationID", id)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// resumeInterruptedTurns handles the turns left interrupted when the server
+// last stopped. Turns checkpointed by a shutdown are resumed; turns cut short
+// by a crash are ended with an error telling the user how to continue, as
+// resuming them could crash the server again. Subagent conversations are not
+// resumed on their own: the interrupted tool call of their parent is retried
+// instead.
+func (s *Server) resumeInterruptedTurns(ctx context.Context) {
+	turns, err := s.db.ListInterruptedTurns(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list interrupted turns", "error", err)
+		return
+	}
+	for _, turn := range turns {
+		// The turn may be running on another instance sharing the database
+		if leased, err := s.leasedElsewhere(ctx, turn.ConversationID); err != nil {
+			s.logger.Error("Failed to get conversation lease", "conversationID", turn.ConversationID, "error", err)
+			continue
+		} else if leased {
+			continue
+		}
+		// Delete the record first: a resumed turn records itself as in flight
+		if err := s.db.DeleteInterruptedTurn(ctx, turn.ConversationID); err != nil {
+			s.logger.Error("Failed to delete interrupted turn", "conversationID", turn.ConversationID, "error", err)
+			continue
+		}
+
+		var text string
+		switch turn.Reason {
+		case interruptReasonShutdown:
+			err := s.resumeInterruptedTurn(ctx, turn.ConversationID)
+			if err == nil {
+				continue
+			}
+			s.logger.Warn("Failed to resume interrupted turn", "conversationID", turn.ConversationID, "error", err)
+			text = fmt.Sprintf("This turn was interrupted by a server restart and could not be resumed: %v. Send a message to continue.", err)
+		default:
+			s.logger.Warn("Found turn interrupted by a crash", "conversationID", turn.ConversationID)
+			text = "Shelley stopped unexpectedly while this turn was running. Send a message to continue where it left off."
+		}
+
+		manager, err := s.getOrCreateConversationManager(ctx, turn.ConversationID)
+		if err != nil {
+			s.logger.Error("Failed to load interrupted conversation", "conversationID", turn.ConversationID, "error", err)
+			continue
+		}
+		if err := manager.FailInterruptedTurn(ctx, turn.Reason, text); err != nil {
+			s.logger.Error("Failed to end interrupted turn", "conversationID", turn.ConversationID, "error", err)
 		}
 	}
 }
 
+func (s *Server) resumeInterruptedTurn(ctx context.Context, conversationID string) error {
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("conversation not found: %w", err)
+	}
+	if conversation.ParentConversationID != nil {
+		return nil
+	}
+
+	modelID := s.Settings().DefaultModel
+	if conversation.Model != nil && *conversation.Model != "" {
+		modelID = *conversation.Model
+	}
+	service, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		return fmt.Errorf("unsupported model %q: %w", modelID, err)
+	}
+
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	resumed, err := manager.ResumeTurn(ctx, service, modelID)
+	if err != nil {
+		return err
+	}
+	if resumed {
+		s.logger.Info("Resumed interrupted turn", "conversationID", conversationID)
+	}
+	return nil
+}
+
 // Start starts the HTTP server and handles the complete lifecycle
 func (s *Server) Start(port string) error {
 	listener, err := net.Listen("tcp", ":"+port)
@@ -896,18 +1219,36 @@ func (s *Server) StartWithListener(listener net.Listener) error {
 
 	// Add middleware (applied in reverse order: last added = first executed)
 	handler := LoggerMiddleware(s.logger)(mux)
+	handler = s.auditMiddleware(handler)
 	handler = CSRFMiddleware()(handler)
 	if s.requireHeader != "" {
 		handler = RequireHeaderMiddleware(s.requireHeader)(handler)
 	}
+	handler = s.apiTokenMiddleware(handler)
+	handler = s.basePathHandler(handler)
+	scheme := "http"
+	if s.tls.Enabled() {
+		handler = SecureTransportMiddleware()(handler)
+		tlsConfig, err := s.tls.tlsConfig()
+		if err != nil {
+			return err
+		}
+		listener = tls.NewListener(listener, tlsConfig)
+		scheme = "https"
+	}
 
 	httpServer := &http.Server{
 		Handler: handler,
 	}
 
-	// Start cleanup routine
+	// Start cleanup routine; it runs without an idle timeout too, since
+	// reloading the config can set one
+	interval := 5 * time.Minute
+	if idleTimeout := s.Settings().IdleTimeout; idleTimeout > 0 && idleTimeout < interval {
+		interval = idleTimeout
+	}
 	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		for range ticker.C {
 			s.Cleanup()
@@ -920,12 +1261,18 @@ func (s *Server) StartWithListener(listener net.Listener) error {
 	// Start server in goroutine
 	serverErrCh := make(chan error, 1)
 	go func() {
-		s.logger.Info("Server starting", "port", actualPort, "url", fmt.Sprintf("http://localhost:%d", actualPort))
+		s.logger.Info("Server starting", "port", actualPort, "url", fmt.Sprintf("%s://localhost:%d%s/", scheme, actualPort, s.basePath))
 		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 			serverErrCh <- err
 		}
 	}()
 
+	go func() {
+		// Batches wait for their resumed turns, so those are resumed first
+		s.resumeInterruptedTurns(context.Background())
+		s.resumeBatches(context.Background())
+	}()
+
 	// Wait for shutdown signal or server error
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -942,9 +1289,17 @@ func (s *Server) StartWithListener(listener net.Listener) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := httpServer.Shutdown(ctx); err != nil {
-		s.logger.Error("Server forced to shutdown", "error", err)
-		return err
+	shutdownErr := httpServer.Shutdown(ctx)
+
+	// Checkpoint turns in flight so that they resume on restart. This gets its
+	// own deadline, as streaming clients may have used up the shutdown's.
+	checkpointCtx, checkpointCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer checkpointCancel()
+	s.checkpointTurns(checkpointCtx)
+
+	if shutdownErr != nil {
+		s.logger.Error("Server forced to shutdown", "error", shutdownErr)
+		return shutdownErr
 	}
 
 	s.logger.Info("Server exited")
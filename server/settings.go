@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/loop"
+)
+
+// Settings are the server settings that can change while it runs, when the
+// config file is reloaded. The others take effect on restart.
+type Settings struct {
+	DefaultModel string
+	TerminalURL  string
+	Links        []Link
+	// IdleTimeout is how long a conversation can be idle before its resources are released; zero keeps them
+	IdleTimeout time.Duration
+	// LoopLimits are the limits of turns; conversations can set their own timeouts
+	LoopLimits loop.Limits
+	// ConcurrentReadOnlyTools runs the read-only tool calls of a response at once
+	ConcurrentReadOnlyTools bool
+	Webhooks                NotificationWebhooks
+	// Admins are the users, by identity header value, allowed to use the admin API
+	Admins []string
+	// PromptOverrides maps model IDs to changes of the system prompt of new conversations
+	PromptOverrides map[string]PromptOverride
+}
+
+// Settings returns the current settings.
+func (s *Server) Settings() Settings {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings
+}
+
+// ApplySettings replaces the settings. Conversations already loaded keep
+// their loop limits until they are reloaded.
+func (s *Server) ApplySettings(settings Settings) {
+	settings.Webhooks.BaseURL = strings.TrimSuffix(settings.Webhooks.BaseURL, "/")
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.settings = settings
+}
+
+func (s *Server) updateSettings(update func(*Settings)) {
+	settings := s.Settings()
+	update(&settings)
+	s.ApplySettings(settings)
+}
+
+// SetConfigReloader sets the function that rereads the config file and
+// applies its settings, for POST /api/config/reload.
+func (s *Server) SetConfigReloader(reload func() error) {
+	s.configReloader = reload
+}
+
+// handleReloadConfig handles POST /api/config/reload, rereading the config
+// file as on SIGHUP. Only admins can reload it.
+func (s *Server) handleReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if s.configReloader == nil {
+		http.Error(w, "Config reload is not available", http.StatusNotImplemented)
+		return
+	}
+	if err := s.configReloader(); err != nil {
+		s.logger.Warn("Failed to reload config", "error", err)
+		http.Error(w, "Failed to reload config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.logger.Info("Reloaded config")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	reload := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.server.handleReloadConfig(w, httptest.NewRequest("POST", "/api/config/reload", nil))
+		return w
+	}
+	if w := reload(); w.Code != http.StatusNotImplemented {
+		t.Fatalf("reload without a config file: status %d", w.Code)
+	}
+
+	var reloadErr error
+	h.server.SetConfigReloader(func() error {
+		if reloadErr != nil {
+			return reloadErr
+		}
+		h.server.ApplySettings(Settings{
+			DefaultModel: "predictable",
+			Links:        []Link{{Title: "Docs", URL: "https://example.com"}},
+			IdleTimeout:  time.Hour,
+			Webhooks:     NotificationWebhooks{BaseURL: "https://shelley.example.com/"},
+		})
+		return nil
+	})
+	if w := reload(); w.Code != http.StatusOK {
+		t.Fatalf("reload: status %d: %s", w.Code, w.Body)
+	}
+	settings := h.server.Settings()
+	if settings.IdleTimeout != time.Hour || len(settings.Links) != 1 || settings.Webhooks.BaseURL != "https://shelley.example.com" {
+		t.Errorf("settings after reload = %+v", settings)
+	}
+
+	reloadErr = errors.New("invalid config")
+	if w := reload(); w.Code != http.StatusBadRequest {
+		t.Errorf("failed reload: status %d", w.Code)
+	}
+	if h.server.Settings().IdleTimeout != time.Hour {
+		t.Error("failed reload changed the settings")
+	}
+}
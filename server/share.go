@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+// shareKeySetting is the settings key holding the hex-encoded HMAC key for share tokens.
+const shareKeySetting = "share_key"
+
+//go:embed share.html
+var shareTemplateText string
+
+var shareTemplate = template.Must(template.New("share").Parse(shareTemplateText))
+
+var errInvalidShareToken = errors.New("invalid share token")
+
+// ShareRequest is the body of POST /api/conversations/<id>/share
+type ShareRequest struct {
+	// ExpiresIn is a Go duration (e.g. "24h"). Empty means the link never expires.
+	ExpiresIn string `json:"expires_in,omitempty"`
+}
+
+// ShareResponse describes a created share link
+type ShareResponse struct {
+	Token     string     `json:"token"`
+	URL       string     `json:"url"` // Path relative to the server root
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// shareKey returns the server's share signing key, creating it on first use.
+func (s *Server) shareKey(ctx context.Context) ([]byte, error) {
+	value, err := s.db.GetOrCreateSetting(ctx, shareKeySetting, func() (string, error) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(key), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share key: %w", err)
+	}
+	return hex.DecodeString(value)
+}
+
+// signShareToken returns a token granting read access to a conversation until expires.
+// A zero expires means the token never expires.
+func signShareToken(key []byte, conversationID string, expires time.Time) string {
+	var exp int64
+	if !expires.IsZero() {
+		exp = expires.Unix()
+	}
+	payload := conversationID + "." + strconv.FormatInt(exp, 10)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyShareToken checks the signature and expiry of a token and returns its conversation ID.
+func verifyShareToken(key []byte, token string, now time.Time) (string, error) {
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", errInvalidShareToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return "", errInvalidShareToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil {
+		return "", errInvalidShareToken
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", errInvalidShareToken
+	}
+	conversationID, expStr, ok := strings.Cut(string(payload), ".")
+	if !ok {
+		return "", errInvalidShareToken
+	}
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil {
+		return "", errInvalidShareToken
+	}
+	if exp != 0 && now.Unix() >= exp {
+		return "", fmt.Errorf("%w: expired", errInvalidShareToken)
+	}
+	return conversationID, nil
+}
+
+// handleShareConversation handles POST /api/conversations/<id>/share
+func (s *Server) handleShareConversation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conversationID := r.PathValue("id")
+
+	var req ShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	var expires time.Time
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid expires_in duration", http.StatusBadRequest)
+			return
+		}
+		expires = time.Now().Add(d)
+	}
+
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	key, err := s.shareKey(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get share key", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	token := signShareToken(key, conversationID, expires)
+	resp := ShareResponse{Token: token, URL: s.basePath + "/share/" + token}
+	if !expires.IsZero() {
+		resp.ExpiresAt = &expires
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// shareEntry is one block of a shared transcript
+type shareEntry struct {
+	Role  string // "user", "agent", "tool", "result" or "error"
+	Title string
+	Text  string
+}
+
+// handleSharedConversation handles GET /share/<token>, rendering a read-only transcript.
+func (s *Server) handleSharedConversation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	key, err := s.shareKey(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get share key", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	conversationID, err := verifyShareToken(key, r.PathValue("token"), time.Now())
+	if err != nil {
+		http.Error(w, "Share link is invalid or has expired", http.StatusNotFound)
+		return
+	}
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	messages, err := s.db.ListMessages(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var entries []shareEntry
+	for _, msg := range messages {
+		if msg.LlmData == nil {
+			continue
+		}
+		switch db.MessageType(msg.Type) {
+		case db.MessageTypeUser, db.MessageTypeAgent, db.MessageTypeError:
+		default:
+			continue
+		}
+		var llmMsg llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+			continue
+		}
+		entries = append(entries, shareEntries(db.MessageType(msg.Type), llmMsg)...)
+	}
+
+	title := conversationID
+	if conversation.Slug != nil {
+		title = *conversation.Slug
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	if err := shareTemplate.Execute(w, map[string]any{
+		"Title":     title,
+		"CreatedAt": conversation.CreatedAt,
+		"Entries":   entries,
+	}); err != nil {
+		s.logger.Error("Failed to render shared conversation", "conversationID", conversationID, "error", err)
+	}
+}
+
+// shareEntries converts a stored message into transcript blocks.
+func shareEntries(messageType db.MessageType, msg llm.Message) []shareEntry {
+	var entries []shareEntry
+	for _, content := range msg.Content {
+		switch content.Type {
+		case llm.ContentTypeText:
+			if content.Text == "" {
+				continue
+			}
+			role := "user"
+			if messageType == db.MessageTypeError {
+				role = "error"
+			} else if msg.Role == llm.MessageRoleAssistant {
+				role = "agent"
+			}
+			entries = append(entries, shareEntry{Role: role, Text: content.Text})
+		case llm.ContentTypeToolUse:
+			entries = append(entries, shareEntry{Role: "tool", Title: content.ToolName, Text: string(content.ToolInput)})
+		case llm.ContentTypeToolResult:
+			var texts []string
+			for _, result := range content.ToolResult {
+				if result.Type == llm.ContentTypeText {
+					texts = append(texts, result.Text)
+				}
+			}
+			title := "result"
+			if content.ToolError {
+				title = "error"
+			}
+			entries = append(entries, shareEntry{Role: "result", Title: title, Text: strings.Join(texts, "\n")})
+		}
+	}
+	return entries
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareToken(t *testing.T) {
+	key := []byte("test-key")
+	now := time.Now()
+
+	token := signShareToken(key, "cabc", time.Time{})
+	if id, err := verifyShareToken(key, token, now); err != nil || id != "cabc" {
+		t.Errorf("verify non-expiring token: got %q, %v", id, err)
+	}
+
+	token = signShareToken(key, "cabc", now.Add(time.Hour))
+	if _, err := verifyShareToken(key, token, now); err != nil {
+		t.Errorf("verify unexpired token: %v", err)
+	}
+	if _, err := verifyShareToken(key, token, now.Add(2*time.Hour)); err == nil {
+		t.Error("expected expired token to be rejected")
+	}
+	if _, err := verifyShareToken([]byte("other-key"), token, now); err == nil {
+		t.Error("expected token signed with another key to be rejected")
+	}
+	if _, err := verifyShareToken(key, "garbage", now); err == nil {
+		t.Error("expected malformed token to be rejected")
+	}
+}
+
+func TestShareConversation(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: shared <reply>", "")
+	h.WaitResponse()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/conversations/"+h.convID+"/share", bytes.NewBufferString(`{"expires_in": "1h"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("share: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp ShareResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse share response: %v", err)
+	}
+	if resp.ExpiresAt == nil {
+		t.Error("expected expires_at to be set")
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, resp.URL, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("view: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if body := w.Body.String(); !strings.Contains(body, "shared &lt;reply&gt;") {
+		t.Errorf("expected escaped transcript text in page, got:\n%s", body)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, resp.URL+"x", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("tampered token: expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+)
+
+// waitToolCall waits until the conversation has a tool call without a result.
+func (h *TestHarness) waitToolCall() {
+	h.t.Helper()
+	ctx := h.t.Context()
+	deadline := time.Now().Add(h.timeout)
+	for {
+		var messages []generated.Message
+		if err := h.db.Queries(ctx, func(q *generated.Queries) error {
+			var err error
+			messages, err = q.ListMessagesForContext(ctx, h.convID)
+			return err
+		}); err != nil {
+			h.t.Fatal(err)
+		}
+		history, _ := (&ConversationManager{}).partitionMessages(messages)
+		if len(unfinishedToolUses(history)) > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatal("waitToolCall: timed out waiting for the tool call")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitNoInterruptedTurns waits until no turn is recorded as interrupted.
+func (h *TestHarness) waitNoInterruptedTurns() {
+	h.t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for {
+		turns, err := h.db.ListInterruptedTurns(h.t.Context())
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		if len(turns) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("waitNoInterruptedTurns: still interrupted: %+v", turns)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// restart replaces the server with a new one on the same database.
+func (h *TestHarness) restart() {
+	s := h.server
+	h.server = NewServer(h.db, s.llmManager, s.toolSetConfig, s.logger, true, "", "predictable", "", nil)
+}
+
+func TestCheckpointAndResumeInterruptedTurn(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	ctx := context.Background()
+
+	h.NewConversation("bash: sleep 30", "")
+	h.waitToolCall()
+
+	h.server.checkpointTurns(ctx)
+
+	turns, err := h.db.ListInterruptedTurns(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(turns) != 1 || turns[0].ConversationID != h.convID || turns[0].Reason != interruptReasonShutdown {
+		t.Fatalf("expected the turn to be recorded as interrupted, got %+v", turns)
+	}
+	if result := h.WaitToolResult(); !strings.Contains(result, "interrupted") {
+		t.Errorf("expected an interrupted tool result, got %q", result)
+	}
+
+	// A restarted server resumes the turn
+	h.restart()
+	h.server.resumeInterruptedTurns(ctx)
+	if got := h.WaitResponse(); got == "" {
+		t.Error("expected the resumed turn to end with a response")
+	}
+	h.waitNoInterruptedTurns()
+}
+
+func TestCrashInterruptedTurn(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	ctx := context.Background()
+
+	h.NewConversation("bash: sleep 30", "")
+	h.waitToolCall()
+
+	turns, err := h.db.ListInterruptedTurns(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(turns) != 1 || turns[0].Reason != interruptReasonCrash {
+		t.Fatalf("expected the turn in flight to be recorded, got %+v", turns)
+	}
+
+	// Simulate a crash: the loop stops without a checkpoint
+	manager, err := h.server.getOrCreateConversationManager(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.stopLoop()
+
+	h.restart()
+	h.server.resumeInterruptedTurns(ctx)
+	h.waitNoInterruptedTurns()
+
+	if result := h.WaitToolResult(); !strings.Contains(result, "interrupted") {
+		t.Errorf("expected an interrupted tool result, got %q", result)
+	}
+	var messages []generated.Message
+	if err := h.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, h.convID)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	last := messages[len(messages)-1]
+	if last.Type != string(db.MessageTypeError) || !isAgentEndOfTurn(&last) || !strings.Contains(*last.LlmData, "stopped unexpectedly") {
+		t.Errorf("expected the turn to end with an interruption error, got %s %v", last.Type, *last.LlmData)
+	}
+}
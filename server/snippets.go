@@ -0,0 +1,265 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// snippetVariableRegexp matches {{name}} and {{name|default}} placeholders in a snippet body.
+var snippetVariableRegexp = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*(?:\|([^}]*))?\}\}`)
+
+// SnippetRequest is the request body for creating or updating a snippet
+type SnippetRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Body        string `json:"body"`
+}
+
+// SnippetAPI is the API representation of a prompt snippet
+type SnippetAPI struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Body        string    `json:"body"`
+	Variables   []string  `json:"variables"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SnippetFill selects a snippet and the values of its variables
+type SnippetFill struct {
+	ID        int64             `json:"id"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+func toSnippetAPI(s generated.Snippet) SnippetAPI {
+	return SnippetAPI{
+		ID:          s.SnippetID,
+		Name:        s.Name,
+		Description: s.Description,
+		Body:        s.Body,
+		Variables:   snippetVariables(s.Body),
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.UpdatedAt,
+	}
+}
+
+// snippetVariables returns the names of the variables in body without a
+// default value, in order of first appearance.
+func snippetVariables(body string) []string {
+	vars := []string{}
+	seen := make(map[string]bool)
+	for _, m := range snippetVariableRegexp.FindAllStringSubmatch(body, -1) {
+		if seen[m[1]] || strings.Contains(m[0], "|") {
+			continue
+		}
+		seen[m[1]] = true
+		vars = append(vars, m[1])
+	}
+	return vars
+}
+
+// renderSnippet replaces the placeholders in body with values. A placeholder
+// with no value uses its default; it is an error if there is neither.
+func renderSnippet(body string, values map[string]string) (string, error) {
+	var missing []string
+	text := snippetVariableRegexp.ReplaceAllStringFunc(body, func(m string) string {
+		sub := snippetVariableRegexp.FindStringSubmatch(m)
+		if v, ok := values[sub[1]]; ok {
+			return v
+		}
+		if strings.Contains(m, "|") {
+			return sub[2]
+		}
+		missing = append(missing, sub[1])
+		return m
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing snippet variables: %s", strings.Join(missing, ", "))
+	}
+	return text, nil
+}
+
+// fillSnippet renders req.Snippet into req.Message, placing the snippet before
+// any message text. It writes an error response and returns false on failure.
+func (s *Server) fillSnippet(w http.ResponseWriter, r *http.Request, req *ChatRequest) bool {
+	if req.Snippet == nil {
+		return true
+	}
+	snippet, err := s.db.GetSnippet(r.Context(), s.requestUser(r), req.Snippet.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Snippet not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		s.logger.Error("Failed to get snippet", "id", req.Snippet.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	text, err := renderSnippet(snippet.Body, req.Snippet.Variables)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if req.Message != "" {
+		text += "\n\n" + req.Message
+	}
+	req.Message = text
+	return true
+}
+
+// validateSnippet checks a snippet's name and body
+func validateSnippet(req SnippetRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("snippet name is required")
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return fmt.Errorf("snippet body is required")
+	}
+	return nil
+}
+
+// handleSnippets handles /api/snippets: GET lists the snippets, POST creates one.
+func (s *Server) handleSnippets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := s.requestUser(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		snippets, err := s.db.ListSnippets(ctx, owner)
+		if err != nil {
+			s.logger.Error("Failed to list snippets", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		result := make([]SnippetAPI, 0, len(snippets))
+		for _, snippet := range snippets {
+			result = append(result, toSnippetAPI(snippet))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	case http.MethodPost:
+		var req SnippetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateSnippet(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		snippet, err := s.db.CreateSnippet(ctx, generated.CreateSnippetParams{
+			Owner:       owner,
+			Name:        strings.TrimSpace(req.Name),
+			Description: req.Description,
+			Body:        req.Body,
+		})
+		if err != nil {
+			s.snippetWriteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(toSnippetAPI(*snippet))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSnippet handles /api/snippets/{id} (GET, PUT, DELETE) and
+// POST /api/snippets/{id}/render, which fills in the snippet's variables.
+func (s *Server) handleSnippet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := s.requestUser(r)
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/snippets/")
+	path, render := strings.CutSuffix(path, "/render")
+	id, err := strconv.ParseInt(path, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid snippet ID", http.StatusBadRequest)
+		return
+	}
+
+	if render {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var fill SnippetFill
+		if err := json.NewDecoder(r.Body).Decode(&fill); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		req := ChatRequest{Snippet: &SnippetFill{ID: id, Variables: fill.Variables}}
+		if !s.fillSnippet(w, r, &req) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"text": req.Message})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		snippet, err := s.db.GetSnippet(ctx, owner, id)
+		if err != nil {
+			s.snippetWriteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toSnippetAPI(*snippet))
+	case http.MethodPut:
+		var req SnippetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateSnippet(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		snippet, err := s.db.UpdateSnippet(ctx, generated.UpdateSnippetParams{
+			Name:        strings.TrimSpace(req.Name),
+			Description: req.Description,
+			Body:        req.Body,
+			SnippetID:   id,
+			Owner:       owner,
+		})
+		if err != nil {
+			s.snippetWriteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toSnippetAPI(*snippet))
+	case http.MethodDelete:
+		if err := s.db.DeleteSnippet(ctx, owner, id); err != nil {
+			s.snippetWriteError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// snippetWriteError maps a snippet database error to a response
+func (s *Server) snippetWriteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Snippet not found", http.StatusNotFound)
+	case strings.Contains(err.Error(), "UNIQUE constraint failed"):
+		http.Error(w, "A snippet with this name already exists", http.StatusConflict)
+	default:
+		s.logger.Error("Failed to save snippet", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
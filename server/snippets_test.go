@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRenderSnippet(t *testing.T) {
+	body := "Review {{ file }} for {{focus|bugs}}. Mention {{file}} by name."
+	if vars := snippetVariables(body); !reflect.DeepEqual(vars, []string{"file"}) {
+		t.Errorf("unexpected variables: %q", vars)
+	}
+
+	text, err := renderSnippet(body, map[string]string{"file": "main.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Review main.go for bugs. Mention main.go by name."; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+	if text, _ := renderSnippet(body, map[string]string{"file": "a.go", "focus": "style"}); text != "Review a.go for style. Mention a.go by name." {
+		t.Errorf("expected value to replace default, got %q", text)
+	}
+	if _, err := renderSnippet(body, nil); err == nil {
+		t.Error("expected error for missing variable")
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	request := func(method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, bytes.NewReader(data))
+		if path == "/api/snippets" {
+			h.server.handleSnippets(w, r)
+		} else {
+			h.server.handleSnippet(w, r)
+		}
+		return w
+	}
+
+	if w := request("POST", "/api/snippets", SnippetRequest{Name: "empty"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for missing body, got %d", w.Code)
+	}
+	w := request("POST", "/api/snippets", SnippetRequest{Name: "echo", Description: "Echo a word", Body: "echo: {{word}} {{suffix|!}}"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created SnippetAPI
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ID == 0 || !reflect.DeepEqual(created.Variables, []string{"word"}) {
+		t.Errorf("unexpected snippet: %+v", created)
+	}
+	if w := request("POST", "/api/snippets", SnippetRequest{Name: "echo", Body: "dup"}); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for duplicate name, got %d", w.Code)
+	}
+
+	path := fmt.Sprintf("/api/snippets/%d", created.ID)
+	w = request("PUT", path, SnippetRequest{Name: "echo", Body: "echo: {{word}}{{suffix|?}}"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := request("POST", path+"/render", SnippetFill{}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for missing variable, got %d", w.Code)
+	}
+	w = request("POST", path+"/render", SnippetFill{Variables: map[string]string{"word": "hello"}})
+	var rendered map[string]string
+	json.Unmarshal(w.Body.Bytes(), &rendered)
+	if rendered["text"] != "echo: hello?" {
+		t.Errorf("unexpected render: %q", w.Body.String())
+	}
+
+	// Variables are filled in when the snippet is sent
+	chatBody, _ := json.Marshal(ChatRequest{
+		Model:   "predictable",
+		Cwd:     t.TempDir(),
+		Snippet: &SnippetFill{ID: created.ID, Variables: map[string]string{"word": "filled"}},
+	})
+	cw := httptest.NewRecorder()
+	h.server.handleNewConversation(cw, httptest.NewRequest("POST", "/api/conversations/new", bytes.NewReader(chatBody)))
+	if cw.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", cw.Code, cw.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(cw.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+	if got := h.WaitResponse(); got != "filled?" {
+		t.Errorf("expected rendered snippet to be sent, got %q", got)
+	}
+
+	// Snippets are scoped to the user named by the identity header
+	h.server.requireHeader = "X-Exedev-Userid"
+	r := httptest.NewRequest("GET", "/api/snippets", nil)
+	r.Header.Set("X-Exedev-Userid", "someone-else")
+	lw := httptest.NewRecorder()
+	h.server.handleSnippets(lw, r)
+	var others []SnippetAPI
+	json.Unmarshal(lw.Body.Bytes(), &others)
+	if len(others) != 0 {
+		t.Errorf("expected another user's library to be empty, got %+v", others)
+	}
+	h.server.requireHeader = ""
+
+	if w := request("DELETE", path, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w := request("GET", path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", w.Code)
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// cacheImmutable is for requests of an asset's current version, whose
+	// content never changes under that URL.
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// cacheRevalidate makes browsers check the ETag on each request.
+	cacheRevalidate = "public, max-age=0, must-revalidate"
+)
+
+// staticAssets serves the built UI. Scripts and stylesheets are embedded only
+// precompressed, and are served brotli- or gzip-encoded as the client accepts,
+// or decompressed for the rare client that accepts neither.
+//
+// Every file has a content-based ETag. index.html links its assets with their
+// hash as a ?v= query, and requests for an asset's current version may be
+// cached forever, so reloads over slow links take no round trips. Requests
+// without it, such as the editor's dynamic imports, revalidate with the ETag.
+type staticAssets struct {
+	fsys       http.FileSystem
+	fileServer http.Handler
+	checksums  map[string]string // by name, computed by the UI build
+
+	mu     sync.Mutex
+	hashes map[string]string // of files not in checksums, computed on first use
+}
+
+func newStaticAssets(fsys http.FileSystem) *staticAssets {
+	a := &staticAssets{
+		fsys:       fsys,
+		fileServer: http.FileServer(fsys),
+		hashes:     make(map[string]string),
+	}
+	if f, err := fsys.Open("/checksums.json"); err == nil {
+		json.NewDecoder(f).Decode(&a.checksums)
+		f.Close()
+	}
+	return a
+}
+
+// version returns the content hash of the named file, or "" if there is no
+// such file. Scripts and stylesheets are hashed by their compressed content.
+func (a *staticAssets) version(name string) string {
+	if hash, ok := a.checksums[name]; ok {
+		return hash
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if hash, ok := a.hashes[name]; ok {
+		return hash
+	}
+	for _, candidate := range []string{name, name + ".gz"} {
+		f, err := a.fsys.Open("/" + candidate)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		if stat, err := f.Stat(); err != nil || stat.IsDir() {
+			return ""
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return ""
+		}
+		hash := hex.EncodeToString(h.Sum(nil))[:16]
+		a.hashes[name] = hash
+		return hash
+	}
+	return ""
+}
+
+// cacheControl returns the Cache-Control of a response of an asset with the
+// given hash.
+func (a *staticAssets) cacheControl(r *http.Request, hash string) string {
+	if v := r.URL.Query().Get("v"); v != "" && v == hash {
+		return cacheImmutable
+	}
+	return cacheRevalidate
+}
+
+var assetLinkPattern = regexp.MustCompile(`(href|src)="/([^"?#]+)"`)
+
+// versionLinks adds the content hash of each asset html links to as a ?v=
+// query, so browsers may cache it until it changes.
+func (a *staticAssets) versionLinks(html string) string {
+	return assetLinkPattern.ReplaceAllStringFunc(html, func(link string) string {
+		m := assetLinkPattern.FindStringSubmatch(link)
+		hash := a.version(m[2])
+		if hash == "" {
+			return link
+		}
+		return m[1] + `="/` + m[2] + "?v=" + hash + `"`
+	})
+}
+
+func (a *staticAssets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if strings.HasSuffix(name, ".js") || strings.HasSuffix(name, ".css") {
+		if a.serveCompressed(w, r, name) {
+			return
+		}
+	}
+	if hash := a.version(name); hash != "" {
+		// The file server answers If-None-Match with the ETag set here
+		w.Header().Set("ETag", `"`+hash+`"`)
+		w.Header().Set("Cache-Control", a.cacheControl(r, hash))
+	}
+	a.fileServer.ServeHTTP(w, r)
+}
+
+// serveCompressed serves a script or stylesheet from its precompressed files.
+// It returns false if the file isn't embedded compressed.
+func (a *staticAssets) serveCompressed(w http.ResponseWriter, r *http.Request, name string) bool {
+	gzFile, err := a.fsys.Open("/" + name + ".gz")
+	if err != nil {
+		return false
+	}
+	defer gzFile.Close()
+	if stat, err := gzFile.Stat(); err != nil || stat.IsDir() {
+		return false
+	}
+
+	var encoding string
+	var body io.Reader = gzFile
+	if acceptsEncoding(r, "br") {
+		if brFile, err := a.fsys.Open("/" + name + ".br"); err == nil {
+			defer brFile.Close()
+			encoding, body = "br", brFile
+		}
+	}
+	if encoding == "" && acceptsEncoding(r, "gzip") {
+		encoding = "gzip"
+	}
+
+	hash := a.version(name)
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.Header().Set("Cache-Control", a.cacheControl(r, hash))
+	if hash != "" {
+		// Each encoding is a different representation, with its own ETag
+		etag := `"` + hash + `"`
+		if encoding != "" {
+			etag = `"` + hash + "-" + encoding + `"`
+		}
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	if encoding == "" {
+		gr, err := gzip.NewReader(gzFile)
+		if err != nil {
+			http.Error(w, "failed to decompress", http.StatusInternalServerError)
+			return true
+		}
+		defer gr.Close()
+		body = gr
+	} else {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	if r.Method != http.MethodHead {
+		io.Copy(w, body)
+	}
+	return true
+}
+
+// acceptsEncoding reports whether the client's Accept-Encoding header accepts
+// the given content coding.
+func acceptsEncoding(r *http.Request, coding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), coding) {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(s))
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStaticAssets(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	fsys := fstest.MapFS{
+		"index.html":     {Data: []byte(`<html><head><link rel="manifest" href="/manifest.json" /><script type="module" src="/main.js"></script></head></html>`)},
+		"main.js.gz":     {Data: gzipped(t, "console.log('hi')")},
+		"main.js.br":     {Data: []byte("brotli bytes")},
+		"checksums.json": {Data: []byte(`{"main.js": "abc123"}`)},
+		"manifest.json":  {Data: []byte(`{"name": "Shelley"}`)},
+	}
+	handler := h.server.staticHandler(http.FS(fsys))
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// index.html links assets by their current version
+	body := get("/").Body.String()
+	if !strings.Contains(body, `src="/main.js?v=abc123"`) {
+		t.Errorf("expected a versioned script link, got %s", body)
+	}
+	if !strings.Contains(body, `href="/manifest.json?v=`) {
+		t.Errorf("expected a versioned manifest link, got %s", body)
+	}
+
+	// Brotli is preferred, then gzip, then the decompressed content
+	w := get("/main.js?v=abc123", "Accept-Encoding", "gzip, deflate, br")
+	if w.Header().Get("Content-Encoding") != "br" || w.Body.String() != "brotli bytes" {
+		t.Errorf("expected brotli, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if got := w.Header().Get("Cache-Control"); got != cacheImmutable {
+		t.Errorf("versioned request Cache-Control = %q", got)
+	}
+	if got := w.Header().Get("ETag"); got != `"abc123-br"` {
+		t.Errorf("brotli ETag = %q", got)
+	}
+	w = get("/main.js", "Accept-Encoding", "gzip, br;q=0")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("expected gzip, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if got := w.Header().Get("Cache-Control"); got != cacheRevalidate {
+		t.Errorf("unversioned request Cache-Control = %q", got)
+	}
+	w = get("/main.js")
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "console.log('hi')" {
+		t.Errorf("expected decompressed content, got %q %q", w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/javascript") {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	// A stale version is not cached forever
+	if got := get("/main.js?v=old").Header().Get("Cache-Control"); got != cacheRevalidate {
+		t.Errorf("stale version Cache-Control = %q", got)
+	}
+
+	// ETags revalidate every asset
+	if w := get("/main.js", "Accept-Encoding", "gzip", "If-None-Match", `"abc123-gzip"`); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching script ETag, got %d", w.Code)
+	}
+	w = get("/manifest.json")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected the manifest with an ETag, got %d %q", w.Code, etag)
+	}
+	if w := get("/manifest.json", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching manifest ETag, got %d", w.Code)
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                 false,
+		"gzip, br":         true,
+		"gzip;q=1.0, BR":   true,
+		"br;q=0.5":         true,
+		"gzip, br;q=0":     false,
+		"gzip, deflate":    false,
+		"gzip, brotli-ish": false,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", header)
+		if got := acceptsEncoding(req, "br"); got != want {
+			t.Errorf("acceptsEncoding(%q, br) = %v, want %v", header, got, want)
+		}
+	}
+}
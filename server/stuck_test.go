@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+func TestResumeStuckTurn(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.SetLoopLimits(loop.Limits{MaxRepeatedToolCalls: 2})
+
+	check := loop.ScriptToolCall("bash", map[string]string{"command": "true"})
+	h.Script("check twice", check, check, check, check, loop.ScriptText("checked"))
+	lastError := func() llm.ErrorType {
+		t.Helper()
+		latest, err := h.db.GetLatestMessage(t.Context(), h.convID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg llm.Message
+		json.Unmarshal([]byte(*latest.LlmData), &msg)
+		return msg.ErrorType
+	}
+
+	h.NewConversation("check twice", "")
+	h.waitIdle()
+	if got := lastError(); got != llm.ErrorTypeStuck {
+		t.Fatalf("turn ended with error type %q, want stuck", got)
+	}
+
+	// Resuming continues the turn, which gets stuck again
+	if w := h.post("/resume", ResumeRequest{}); w.Code != http.StatusAccepted {
+		t.Fatalf("resume: status %d: %s", w.Code, w.Body.String())
+	}
+	h.waitIdle()
+	if got := lastError(); got != llm.ErrorTypeStuck {
+		t.Fatalf("resumed turn ended with error type %q, want stuck", got)
+	}
+
+	// Guidance is sent to the agent as a message
+	if w := h.post("/resume", ResumeRequest{Guidance: "echo: giving up"}); w.Code != http.StatusAccepted {
+		t.Fatalf("resume with guidance: status %d: %s", w.Code, w.Body.String())
+	}
+	if got := h.WaitResponse(); got != "giving up" {
+		t.Errorf("response to guidance = %q", got)
+	}
+	h.waitIdle()
+
+	if w := h.post("/resume", ResumeRequest{}); w.Code != http.StatusConflict {
+		t.Errorf("resume of a finished turn: status %d, want 409", w.Code)
+	}
+}
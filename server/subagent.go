@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/llm"
 )
@@ -35,7 +36,7 @@ func (r *SubagentRunner) RunSubagent(ctx context.Context, conversationID, prompt
 
 	// Get the model ID from the server's default
 	// In predictable-only mode, use "predictable" as the model
-	modelID := s.defaultModel
+	modelID := s.Settings().DefaultModel
 	if modelID == "" && s.predictableOnly {
 		modelID = "predictable"
 	}
@@ -179,7 +180,10 @@ func (r *SubagentRunner) generateProgressSummary(ctx context.Context, conversati
 	err := s.db.Queries(ctx, func(q *generated.Queries) error {
 		var err error
 		messages, err = q.ListMessages(ctx, conversationID)
-		return err
+		if err != nil {
+			return err
+		}
+		return db.LoadMessageBlobs(ctx, q, messages)
 	})
 	if err != nil {
 		s.logger.Error("Failed to get messages for progress summary", "error", err)
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/models"
+)
+
+// summaryTranscriptLimit caps the transcript sent to summarize a
+// conversation; longer ones keep their beginning and end.
+const summaryTranscriptLimit = 30000
+
+const summaryPrompt = `Summarize this conversation between a user and a coding agent for a list of past conversations, so the user can recognize it without opening it.
+
+In one to three sentences, say what the user asked for and what the agent changed or found. Do not list file names. Respond with only the summary.
+
+Conversation:
+`
+
+// ConversationSummaryAPI is the summary of a conversation, generated when it goes idle
+type ConversationSummaryAPI struct {
+	Summary string `json:"summary"`
+	// Files are the files the agent edited, relative to the working directory when inside it
+	Files     []string  `json:"files"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func toConversationSummaryAPI(s generated.ConversationSummary) *ConversationSummaryAPI {
+	api := &ConversationSummaryAPI{Summary: s.Summary, Files: []string{}, UpdatedAt: s.UpdatedAt}
+	json.Unmarshal([]byte(s.Files), &api.Files)
+	return api
+}
+
+// touchedFiles returns the files the patch tool was asked to edit, in order
+// of first edit.
+func touchedFiles(messages []generated.Message, cwd string) []string {
+	files := []string{}
+	for _, msg := range messages {
+		if msg.Type != string(db.MessageTypeAgent) || msg.LlmData == nil {
+			continue
+		}
+		var llmMsg llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+			continue
+		}
+		for _, content := range llmMsg.Content {
+			if content.Type != llm.ContentTypeToolUse || content.ToolName != "patch" {
+				continue
+			}
+			var input struct {
+				Path string `json:"path"`
+			}
+			if json.Unmarshal(content.ToolInput, &input) != nil || input.Path == "" {
+				continue
+			}
+			path := input.Path
+			if cwd != "" && filepath.IsAbs(path) {
+				if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+					path = rel
+				}
+			}
+			if !slices.Contains(files, path) {
+				files = append(files, path)
+			}
+		}
+	}
+	return files
+}
+
+// smallModels are the small models of providers, which summarize
+// conversations of their provider's models when they are available.
+var smallModels = map[models.Provider]string{
+	models.ProviderAnthropic: "claude-haiku-4.5",
+	models.ProviderGemini:    "gemini-3-flash",
+}
+
+// summaryModel returns the model summarizing a conversation with modelID: the
+// small model of its provider, or else a model tagged for slugs, which are
+// small too. The conversation's own model is the last resort.
+func (s *Server) summaryModel(modelID string) string {
+	if modelID == "predictable" {
+		return modelID
+	}
+	if info := s.llmManager.GetModelInfo(modelID); info != nil {
+		if small, ok := smallModels[info.Provider]; ok && s.llmManager.HasModel(small) {
+			return small
+		}
+	}
+	for _, id := range s.llmManager.GetAvailableModels() {
+		if info := s.llmManager.GetModelInfo(id); info != nil && strings.Contains(info.Tags, "slug") {
+			return id
+		}
+	}
+	return modelID
+}
+
+// cutTranscript shortens transcript to about summaryTranscriptLimit bytes,
+// keeping its beginning and end around middle. It cuts between runes.
+func cutTranscript(transcript, middle string) string {
+	head := summaryTranscriptLimit / 3
+	tail := len(transcript) - (summaryTranscriptLimit - head)
+	for head > 0 && !utf8.RuneStart(transcript[head]) {
+		head--
+	}
+	for tail < len(transcript) && !utf8.RuneStart(transcript[tail]) {
+		tail++
+	}
+	return transcript[:head] + middle + transcript[tail:]
+}
+
+// summarizeConversation generates and saves the summary of a conversation
+// if it has messages that were not summarized yet. Subagent conversations are
+// not summarized.
+func (s *Server) summarizeConversation(ctx context.Context, conversationID string) error {
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	if conversation.ParentConversationID != nil || conversation.Model == nil {
+		return nil
+	}
+	messages, err := s.db.ListMessages(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	var lastSequence int64
+	hasAgent := false
+	for _, msg := range messages {
+		lastSequence = max(lastSequence, msg.SequenceID)
+		hasAgent = hasAgent || msg.Type == string(db.MessageTypeAgent)
+	}
+	if !hasAgent {
+		return nil
+	}
+	existing, err := s.db.GetConversationSummary(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.SequenceID >= lastSequence {
+		return nil
+	}
+
+	flags, err := s.messageContextFlags(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	transcript := conversationTranscript(messages, flags)
+	if len(transcript) > summaryTranscriptLimit {
+		// Pinned messages from the middle are kept in place of what is cut.
+		var pinned []generated.Message
+		for _, msg := range messages {
+			if flags[msg.MessageID] == ContextFlagPinned {
+				pinned = append(pinned, msg)
+			}
+		}
+		middle := "\n...\n\n"
+		if len(pinned) > 0 {
+			middle += conversationTranscript(pinned, flags) + "...\n\n"
+		}
+		transcript = cutTranscript(transcript, middle)
+	}
+	llmService, err := s.llmManager.GetService(s.summaryModel(*conversation.Model))
+	if err != nil {
+		return err
+	}
+	summaryCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	resp, err := llmService.Do(summaryCtx, &llm.Request{
+		Messages: []llm.Message{{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: summaryPrompt + transcript}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate summary: %w", err)
+	}
+	var summary string
+	for _, content := range resp.Content {
+		if content.Type == llm.ContentTypeText && content.Text != "" {
+			summary = strings.TrimSpace(content.Text)
+			break
+		}
+	}
+	if summary == "" {
+		return fmt.Errorf("empty summary from LLM")
+	}
+
+	cwd := ""
+	if conversation.Cwd != nil {
+		cwd = *conversation.Cwd
+	}
+	files, _ := json.Marshal(touchedFiles(messages, cwd))
+	_, err = s.db.SaveConversationSummary(ctx, generated.UpsertConversationSummaryParams{
+		ConversationID: conversationID,
+		Summary:        summary,
+		Files:          string(files),
+		SequenceID:     lastSequence,
+	})
+	return err
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestConversationSummaries(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	ctx := t.Context()
+	cwd := t.TempDir()
+
+	h.NewConversation("patch: "+filepath.Join(cwd, "main.go"), cwd)
+	h.waitIdle()
+
+	// Conversations going idle are summarized
+	h.server.SetIdleTimeout(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	h.server.Cleanup()
+	h.server.SetIdleTimeout(0)
+	deadline := time.Now().Add(h.timeout)
+	for {
+		summary, err := h.db.GetConversationSummary(ctx, h.convID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("conversation was not summarized")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Nothing new to summarize
+	requests := len(h.llm.GetRecentRequests())
+	if err := h.server.summarizeConversation(ctx, h.convID); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(h.llm.GetRecentRequests()); got != requests {
+		t.Errorf("summarized again without new messages")
+	}
+
+	w := httptest.NewRecorder()
+	h.server.handleConversations(w, httptest.NewRequest("GET", "/api/conversations", nil))
+	var list []ConversationWithState
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Summary == nil {
+		t.Fatalf("conversation list = %+v", list)
+	}
+	if list[0].Summary.Summary == "" || !slices.Equal(list[0].Summary.Files, []string{"main.go"}) {
+		t.Errorf("summary = %+v", list[0].Summary)
+	}
+}
+
+func TestCutTranscript(t *testing.T) {
+	// Multi-byte runes straddle both cuts
+	transcript := strings.Repeat("é", summaryTranscriptLimit)
+	cut := cutTranscript(transcript, "\n...\n\n")
+	if !utf8.ValidString(cut) {
+		t.Error("expected the cuts to fall between runes")
+	}
+	if len(cut) > summaryTranscriptLimit+len("\n...\n\n") {
+		t.Errorf("expected at most %d bytes, got %d", summaryTranscriptLimit, len(cut))
+	}
+}
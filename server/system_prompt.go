@@ -6,10 +6,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"text/template"
+	"time"
 
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm/tokenizer"
+	"shelley.exe.dev/repomap"
 	"shelley.exe.dev/skills"
+	"shelley.exe.dev/wslpath"
 )
 
 //go:embed system_prompt.txt
@@ -21,6 +28,9 @@ var subagentSystemPromptTemplate string
 // SystemPromptData contains all the data needed to render the system prompt template
 type SystemPromptData struct {
 	WorkingDirectory string
+	OS               string // runtime.GOOS, e.g. "linux"
+	Shell            string // shell of the bash tool, e.g. "bash" or "powershell"
+	Date             string // current date, YYYY-MM-DD
 	GitInfo          *GitInfo
 	Codebase         *CodebaseInfo
 	IsExeDev         bool
@@ -28,8 +38,16 @@ type SystemPromptData struct {
 	Hostname         string // For exe.dev, the public hostname (e.g., "vmname.exe.xyz")
 	ShelleyDBPath    string // Path to the shelley database
 	SkillsXML        string // XML block for available skills
+	RepoMap          string // Structural map of the git repository
 }
 
+// repoMapBudget is the maximum size in tokens of the repository map in the system prompt.
+const repoMapBudget = 1500
+
+// guidanceFileBudget is the maximum size in tokens of each guidance file in
+// the system prompt; longer files are cut and say to read the rest.
+const guidanceFileBudget = 8000
+
 // DBPath is the path to the shelley database, set at startup
 var DBPath string
 
@@ -46,12 +64,82 @@ type CodebaseInfo struct {
 // GenerateSystemPrompt generates the system prompt using the embedded template.
 // If workingDir is empty, it uses the current working directory.
 func GenerateSystemPrompt(workingDir string) (string, error) {
-	data, err := collectSystemData(workingDir)
+	return GenerateSystemPromptFromTemplate(workingDir, "")
+}
+
+// PromptOverride changes the system prompt of conversations using a model,
+// e.g. stricter tool-use instructions for a weaker model. It is set per model
+// ID in the "prompt_overrides" section of the config file.
+type PromptOverride struct {
+	// Remove are the sections to leave out, by tag: windows, exe_dev,
+	// customization, guidance, directory_specific_guidance_files, repo_map,
+	// skills or previous_conversations
+	Remove []string `json:"remove,omitempty"`
+	// Prepend and Append are added before and after the prompt
+	Prepend string `json:"prepend,omitempty"`
+	Append  string `json:"append,omitempty"`
+}
+
+// Apply returns the prompt with the override's sections removed and its text added.
+func (o PromptOverride) Apply(prompt string) string {
+	for _, tag := range o.Remove {
+		prompt = removePromptSection(prompt, tag)
+	}
+	if o.Prepend != "" {
+		prompt = strings.TrimSpace(o.Prepend) + "\n\n" + prompt
+	}
+	if o.Append != "" {
+		prompt = strings.TrimRight(prompt, "\n") + "\n\n" + strings.TrimSpace(o.Append) + "\n"
+	}
+	return prompt
+}
+
+// removePromptSection removes every <tag>...</tag> block from the prompt.
+func removePromptSection(prompt, tag string) string {
+	open, end := "<"+tag+">", "</"+tag+">"
+	for {
+		start := strings.Index(prompt, open)
+		if start < 0 {
+			return prompt
+		}
+		n := strings.Index(prompt[start:], end)
+		if n < 0 {
+			return prompt
+		}
+		stop := start + n + len(end)
+		if stop < len(prompt) && prompt[stop] == '\n' {
+			stop++
+		}
+		prompt = prompt[:start] + prompt[stop:]
+	}
+}
+
+// ParseSystemPromptTemplate parses a custom system prompt template.
+// The template is executed with SystemPromptData.
+func ParseSystemPromptTemplate(text string) (*template.Template, error) {
+	return template.New("system_prompt").Parse(text)
+}
+
+// GenerateSystemPromptFromTemplate generates the system prompt using a custom
+// template. An empty template means the embedded one.
+func GenerateSystemPromptFromTemplate(workingDir, text string) (string, error) {
+	return GenerateSystemPromptForModel(workingDir, text, "")
+}
+
+// GenerateSystemPromptForModel generates the system prompt like
+// GenerateSystemPromptFromTemplate, fitting the repository map and guidance
+// files to their budgets with the tokenizer of modelID.
+func GenerateSystemPromptForModel(workingDir, text, modelID string) (string, error) {
+	if text == "" {
+		text = systemPromptTemplate
+	}
+
+	data, err := collectSystemData(workingDir, tokenizer.ForModel(modelID))
 	if err != nil {
 		return "", fmt.Errorf("failed to collect system data: %w", err)
 	}
 
-	tmpl, err := template.New("system_prompt").Parse(systemPromptTemplate)
+	tmpl, err := ParseSystemPromptTemplate(text)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -65,7 +153,7 @@ func GenerateSystemPrompt(workingDir string) (string, error) {
 	return buf.String(), nil
 }
 
-func collectSystemData(workingDir string) (*SystemPromptData, error) {
+func collectSystemData(workingDir string, tok *tokenizer.Tokenizer) (*SystemPromptData, error) {
 	wd := workingDir
 	if wd == "" {
 		var err error
@@ -77,20 +165,27 @@ func collectSystemData(workingDir string) (*SystemPromptData, error) {
 
 	data := &SystemPromptData{
 		WorkingDirectory: wd,
+		OS:               runtime.GOOS,
+		Shell:            claudetool.DetectShell().Name,
+		Date:             time.Now().Format("2006-01-02"),
 	}
 
 	// Try to collect git info
-	gitInfo, err := collectGitInfo()
+	gitInfo, err := collectGitInfo(wd)
 	if err == nil {
 		data.GitInfo = gitInfo
 	}
 
 	// Collect codebase info
-	codebaseInfo, err := collectCodebaseInfo(wd, gitInfo)
+	codebaseInfo, err := collectCodebaseInfo(wd, gitInfo, tok)
 	if err == nil {
 		data.Codebase = codebaseInfo
 	}
 
+	if gitInfo != nil {
+		data.RepoMap = repomap.Generate(gitInfo.Root, repoMapBudget, tok)
+	}
+
 	// Check if running on exe.dev
 	data.IsExeDev = isExeDev()
 
@@ -132,9 +227,10 @@ func collectSystemData(workingDir string) (*SystemPromptData, error) {
 	return data, nil
 }
 
-func collectGitInfo() (*GitInfo, error) {
+func collectGitInfo(dir string) (*GitInfo, error) {
 	// Find git root
 	rootCmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	rootCmd.Dir = dir
 	rootOutput, err := rootCmd.Output()
 	if err != nil {
 		return nil, err
@@ -146,7 +242,7 @@ func collectGitInfo() (*GitInfo, error) {
 	}, nil
 }
 
-func collectCodebaseInfo(wd string, gitInfo *GitInfo) (*CodebaseInfo, error) {
+func collectCodebaseInfo(wd string, gitInfo *GitInfo, tok *tokenizer.Tokenizer) (*CodebaseInfo, error) {
 	info := &CodebaseInfo{
 		InjectFiles:        []string{},
 		InjectFileContents: make(map[string]string),
@@ -183,26 +279,10 @@ func collectCodebaseInfo(wd string, gitInfo *GitInfo) (*CodebaseInfo, error) {
 		searchRoot = gitInfo.Root
 	}
 
-	// Find root-level guidance files (case-insensitive)
-	rootGuidanceFiles := findGuidanceFilesInDir(searchRoot)
-	for _, file := range rootGuidanceFiles {
-		lowerPath := strings.ToLower(file)
-		if seenFiles[lowerPath] {
-			continue
-		}
-		seenFiles[lowerPath] = true
-
-		content, err := os.ReadFile(file)
-		if err == nil && len(content) > 0 {
-			info.InjectFiles = append(info.InjectFiles, file)
-			info.InjectFileContents[file] = string(content)
-		}
-	}
-
-	// If working directory is different from root, also check working directory
-	if wd != searchRoot {
-		wdGuidanceFiles := findGuidanceFilesInDir(wd)
-		for _, file := range wdGuidanceFiles {
+	// Inject guidance files from the root down to the working directory,
+	// so that more deeply nested files come later and take precedence
+	for _, dir := range guidanceDirs(searchRoot, wd) {
+		for _, file := range findGuidanceFilesInDir(dir) {
 			lowerPath := strings.ToLower(file)
 			if seenFiles[lowerPath] {
 				continue
@@ -217,6 +297,10 @@ func collectCodebaseInfo(wd string, gitInfo *GitInfo) (*CodebaseInfo, error) {
 		}
 	}
 
+	for file, content := range info.InjectFileContents {
+		info.InjectFileContents[file] = truncateGuidance(file, content, tok)
+	}
+
 	// Find all guidance files recursively for the directory listing
 	allGuidanceFiles := findAllGuidanceFiles(searchRoot)
 	info.GuidanceFiles = allGuidanceFiles
@@ -224,6 +308,38 @@ func collectCodebaseInfo(wd string, gitInfo *GitInfo) (*CodebaseInfo, error) {
 	return info, nil
 }
 
+// truncateGuidance cuts the content of a guidance file to guidanceFileBudget
+// tokens, at the end of a line if possible.
+func truncateGuidance(file, content string, tok *tokenizer.Tokenizer) string {
+	if tok.Count(content) <= guidanceFileBudget {
+		return content
+	}
+	head := tok.Head(content, guidanceFileBudget)
+	if i := strings.LastIndex(head, "\n"); i > 0 {
+		head = head[:i+1]
+	}
+	return head + fmt.Sprintf("\n[%s is truncated; read the rest from the file]\n", file)
+}
+
+// guidanceDirs returns root followed by each directory below it on the way to wd.
+// If wd is not inside root, only wd is returned.
+func guidanceDirs(root, wd string) []string {
+	rel, err := filepath.Rel(root, wd)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return []string{wd}
+	}
+	dirs := []string{root}
+	if rel == "." {
+		return dirs
+	}
+	dir := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
 func findGuidanceFilesInDir(dir string) []string {
 	// Read directory entries to handle case-insensitive file systems
 	entries, err := os.ReadDir(dir)
@@ -236,6 +352,7 @@ func findGuidanceFilesInDir(dir string) []string {
 		"agents.md":   true,
 		"claude.md":   true,
 		"dear_llm.md": true,
+		".shelley.md": true,
 		"readme.md":   true,
 	}
 
@@ -261,6 +378,7 @@ func findAllGuidanceFiles(root string) []string {
 		"agents.md":   true,
 		"claude.md":   true,
 		"dear_llm.md": true,
+		".shelley.md": true,
 	}
 
 	var found []string
@@ -328,6 +446,107 @@ func isSudoAvailable() bool {
 	return err == nil
 }
 
+// AdditionalDirectoriesPrompt renders the system prompt section listing a
+// conversation's additional working directories.
+func AdditionalDirectoriesPrompt(dirs []string) string {
+	var b strings.Builder
+	b.WriteString("<additional_directories>\n")
+	b.WriteString("Besides the working directory, this conversation also works in the directories below. ")
+	b.WriteString("Relative paths resolve against the working directory; use absolute paths for these.\n")
+	for _, dir := range dirs {
+		b.WriteString(dir)
+		b.WriteString("\n")
+	}
+	b.WriteString("</additional_directories>")
+	return b.String()
+}
+
+// ProjectInstructionsPrompt renders the system prompt section holding the
+// custom instructions of the project profile for dir.
+func ProjectInstructionsPrompt(dir, instructions string) string {
+	return fmt.Sprintf("<project_instructions directory=%q>\n%s\n</project_instructions>", dir, instructions)
+}
+
+// RepositoryPrompt renders the system prompt section for conversations
+// working in a repository Shelley cloned for them.
+func RepositoryPrompt(workspace generated.Workspace) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<repository path=%q>\n", workspace.Path)
+	fmt.Fprintf(&b, "This repository was cloned for this conversation from %s, its \"origin\" remote", workspace.OriginUrl)
+	if workspace.Branch != "" {
+		fmt.Fprintf(&b, ", with branch %s checked out", workspace.Branch)
+	}
+	b.WriteString(".\nCommit your work on a new branch and push it to origin when the user asks to share or publish it.\n")
+	b.WriteString("</repository>")
+	return b.String()
+}
+
+// PlanModePrompt renders the system prompt section for conversations in plan mode.
+func PlanModePrompt() string {
+	return `<plan_mode>
+This conversation is in plan mode. Explore the code with read-only tools and commands;
+you cannot edit files or run commands that modify anything. When you understand the task,
+use the submit_plan tool to submit a plan for the user to approve, then end your turn.
+Once the user approves the plan you will be able to implement it.
+</plan_mode>`
+}
+
+// ReadOnlyPrompt renders the system prompt section for read-only conversations.
+func ReadOnlyPrompt() string {
+	return `<read_only>
+This conversation is read-only: it is for analysis only. You can read files and run commands
+that only inspect state, but you cannot edit files, run tests or run commands that modify
+anything, locally or over the network. Report what you find and suggest changes instead.
+</read_only>`
+}
+
+// WindowsPathsPrompt renders the system prompt section for conversations of
+// users on the Windows host of the WSL distribution Shelley runs in.
+func WindowsPathsPrompt(wsl *wslpath.Translator, cwd string) string {
+	return fmt.Sprintf(`<windows_paths>
+The user works on the Windows host of the WSL distribution you run in, and knows its files by
+their Windows paths: the working directory is %s to them. When you mention a path to the user,
+give its Windows form, e.g. C:\Users\me for %s/c/Users/me. File tools also accept Windows paths.
+Commands still run in Linux, so use Linux paths in them, or translate with wslpath.
+</windows_paths>`, wsl.ToWindows(cwd), strings.TrimSuffix(wsl.MountRoot, "/"))
+}
+
+// LocalTimePrompt renders the note with the user's local time and locale,
+// sent with the user's messages when the date, time zone or locale changes.
+// It goes in messages rather than the system prompt, which must not change
+// from turn to turn for the history to stay cached.
+func LocalTimePrompt(now time.Time, locale string) string {
+	var b strings.Builder
+	b.WriteString("<local_time>\n")
+	fmt.Fprintf(&b, "The user's local time when sending this message is %s.\n", claudetool.FormatLocalTime(now))
+	if locale != "" {
+		fmt.Fprintf(&b, "The user's locale is %s: write dates, times and numbers for them the way it does.\n", locale)
+	}
+	b.WriteString("Date changelog entries and the like in the user's time zone, and use the now tool for the current time.\n")
+	machine := now.In(time.Local)
+	_, offset := now.Zone()
+	if _, machineOffset := machine.Zone(); offset != machineOffset {
+		fmt.Fprintf(&b, "Commands run on a machine whose local time is %s, not the user's: convert times for it, e.g. in cron expressions.\n", claudetool.FormatLocalTime(machine))
+	}
+	b.WriteString("</local_time>")
+	return b.String()
+}
+
+// MemoriesPrompt renders the system prompt section listing remembered facts.
+func MemoriesPrompt(memories []generated.Memory) string {
+	var b strings.Builder
+	b.WriteString("<memories>\nFacts remembered from earlier conversations. Use the memory tool with a memory's id to forget it.\n")
+	for _, m := range memories {
+		scope := "global"
+		if m.Directory != "" {
+			scope = m.Directory
+		}
+		fmt.Fprintf(&b, "- [id=%d, %s] %s\n", m.MemoryID, scope, m.Content)
+	}
+	b.WriteString("</memories>")
+	return b.String()
+}
+
 // SubagentSystemPromptData contains data for subagent system prompts (minimal subset)
 type SubagentSystemPromptData struct {
 	WorkingDirectory string
@@ -350,7 +569,7 @@ func GenerateSubagentSystemPrompt(workingDir string) (string, error) {
 	}
 
 	// Try to collect git info
-	gitInfo, err := collectGitInfo()
+	gitInfo, err := collectGitInfo(wd)
 	if err == nil {
 		data.GitInfo = gitInfo
 	}
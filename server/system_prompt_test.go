@@ -2,9 +2,12 @@ package server
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"shelley.exe.dev/llm/tokenizer"
 )
 
 // TestSystemPromptIncludesCwdGuidanceFiles verifies that AGENTS.md from the working directory
@@ -64,9 +67,165 @@ func TestSystemPromptEmptyCwdFallsBackToCurrentDir(t *testing.T) {
 	}
 }
 
+// TestSystemPromptHierarchicalGuidance verifies that guidance files are collected from
+// every directory between the repository root and the working directory, plus the global layer.
+func TestSystemPromptHierarchicalGuidance(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(home, ".config", "shelley", "AGENTS.md"), "GLOBAL_GUIDE")
+
+	root := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", root).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	wd := filepath.Join(root, "a", "b")
+	write(filepath.Join(root, "AGENTS.md"), "ROOT_GUIDE")
+	write(filepath.Join(root, "a", "CLAUDE.md"), "MID_GUIDE")
+	write(filepath.Join(wd, ".shelley.md"), "LEAF_GUIDE")
+	write(filepath.Join(root, "c", "AGENTS.md"), "SIBLING_GUIDE")
+
+	prompt, err := GenerateSystemPrompt(wd)
+	if err != nil {
+		t.Fatalf("GenerateSystemPrompt failed: %v", err)
+	}
+
+	last := -1
+	for _, guide := range []string{"GLOBAL_GUIDE", "ROOT_GUIDE", "MID_GUIDE", "LEAF_GUIDE"} {
+		i := strings.Index(prompt, guide)
+		if i < 0 {
+			t.Fatalf("system prompt should contain %s", guide)
+		}
+		if i < last {
+			t.Errorf("%s should come after the guidance of its parent directories", guide)
+		}
+		last = i
+	}
+	if strings.Contains(prompt, "SIBLING_GUIDE") {
+		t.Errorf("guidance outside the working directory's ancestry should not be injected")
+	}
+	if !strings.Contains(prompt, filepath.Join(root, "c", "AGENTS.md")) {
+		t.Errorf("sibling guidance file should still be listed")
+	}
+}
+
+func TestSystemPromptRepoMap(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	root := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", root).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pkg", "pkg.go"), []byte("package pkg\n\nfunc Exported() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt, err := GenerateSystemPrompt(root)
+	if err != nil {
+		t.Fatalf("GenerateSystemPrompt failed: %v", err)
+	}
+	if !strings.Contains(prompt, "<repo_map>") || !strings.Contains(prompt, "pkg/pkg.go: Exported") {
+		t.Errorf("expected repository map in system prompt")
+	}
+
+	prompt, err = GenerateSystemPrompt(t.TempDir())
+	if err != nil {
+		t.Fatalf("GenerateSystemPrompt failed: %v", err)
+	}
+	if strings.Contains(prompt, "<repo_map>") {
+		t.Errorf("expected no repository map outside a git repository")
+	}
+}
+
+func TestGuidanceDirs(t *testing.T) {
+	got := guidanceDirs("/repo", "/repo/a/b")
+	want := []string{"/repo", "/repo/a", "/repo/a/b"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := guidanceDirs("/repo", "/repo"); len(got) != 1 || got[0] != "/repo" {
+		t.Errorf("got %v for root itself", got)
+	}
+	if got := guidanceDirs("/repo", "/elsewhere"); len(got) != 1 || got[0] != "/elsewhere" {
+		t.Errorf("got %v for directory outside root", got)
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
 	}
 	return b
 }
+
+// TestSystemPromptTruncatesLongGuidance verifies that a guidance file over
+// its token budget is cut, counting tokens rather than bytes: a file of
+// Japanese text fits far fewer characters than one of English.
+func TestSystemPromptTruncatesLongGuidance(t *testing.T) {
+	dir := t.TempDir()
+	content := "FIRST_LINE_MARKER\n" + strings.Repeat("日本語のガイダンスです。\n", 2000) + "LAST_LINE_MARKER\n"
+	agentsFile := filepath.Join(dir, "AGENTS.md")
+	if err := os.WriteFile(agentsFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt, err := GenerateSystemPromptForModel(dir, "", "claude-sonnet-4.5")
+	if err != nil {
+		t.Fatalf("GenerateSystemPromptForModel failed: %v", err)
+	}
+	if !strings.Contains(prompt, "FIRST_LINE_MARKER") || strings.Contains(prompt, "LAST_LINE_MARKER") {
+		t.Errorf("expected the start of AGENTS.md without its end")
+	}
+	if !strings.Contains(prompt, agentsFile+" is truncated") {
+		t.Errorf("expected a note that AGENTS.md is truncated")
+	}
+
+	// The same number of bytes of ASCII fits
+	short := strings.Repeat("x", len(content)/4)
+	if got := truncateGuidance(agentsFile, short, tokenizer.Claude); got != short {
+		t.Errorf("expected %d bytes of ASCII to fit the budget", len(short))
+	}
+}
+
+func TestSystemPromptWindows(t *testing.T) {
+	tmpl, err := ParseSystemPromptTemplate(systemPromptTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := func(data SystemPromptData) string {
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, data); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	if prompt := render(SystemPromptData{WorkingDirectory: "/src", OS: "linux", Shell: "bash"}); strings.Contains(prompt, "<windows>") {
+		t.Error("expected no Windows guidance on linux")
+	}
+	for shell, want := range map[string]string{
+		"bash":       "bash (Git for Windows)",
+		"powershell": "PowerShell, not bash",
+		"cmd":        "cmd.exe, not bash",
+	} {
+		prompt := render(SystemPromptData{WorkingDirectory: `C:\src`, OS: "windows", Shell: shell})
+		if !strings.Contains(prompt, "<windows>") || !strings.Contains(prompt, want) {
+			t.Errorf("expected Windows guidance mentioning %q for %s", want, shell)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+)
+
+// TemplateConfig is what a conversation template sets up besides its prompt
+type TemplateConfig struct {
+	Model       string                `json:"model,omitempty"`
+	Cwd         string                `json:"cwd,omitempty"`
+	Directories []string              `json:"directories,omitempty"`
+	Hooks       []HookRequest         `json:"hooks,omitempty"`
+	PlanMode    bool                  `json:"plan_mode,omitempty"`
+	Settings    *ConversationSettings `json:"settings,omitempty"`
+}
+
+// TemplateRequest is the request body for creating or updating a conversation
+// template. With FromConversation, the model, working directories, hooks and
+// settings not given are those of the conversation, and the prompt defaults
+// to its first message.
+type TemplateRequest struct {
+	Name             string `json:"name"`
+	Description      string `json:"description,omitempty"`
+	Prompt           string `json:"prompt"`
+	FromConversation string `json:"from_conversation,omitempty"`
+	TemplateConfig
+}
+
+// TemplateAPI is the API representation of a conversation template
+type TemplateAPI struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Prompt      string `json:"prompt"`
+	TemplateConfig
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func toTemplateAPI(t generated.ConversationTemplate) TemplateAPI {
+	api := TemplateAPI{
+		ID:          t.TemplateID,
+		Name:        t.Name,
+		Description: t.Description,
+		Prompt:      t.Prompt,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+	json.Unmarshal([]byte(t.Config), &api.TemplateConfig)
+	return api
+}
+
+// chatRequest returns the request starting a conversation from the template.
+// The fields set in req take precedence, and its message follows the prompt.
+func (t TemplateAPI) chatRequest(req ChatRequest) ChatRequest {
+	message := t.Prompt
+	if req.Message != "" {
+		message += "\n\n" + req.Message
+	}
+	req.Message = message
+	if req.Model == "" {
+		req.Model = t.Model
+	}
+	if req.Cwd == "" {
+		req.Cwd = t.Cwd
+	}
+	req.Directories = append(append([]string{}, t.Directories...), req.Directories...)
+	req.Hooks = append(append([]HookRequest{}, t.Hooks...), req.Hooks...)
+	req.PlanMode = req.PlanMode || t.PlanMode
+	if req.Settings == nil {
+		req.Settings = t.Settings
+	}
+	return req
+}
+
+// fromConversation fills in the template from a conversation. It writes an
+// error response and returns false on failure.
+func (s *Server) fromConversation(w http.ResponseWriter, r *http.Request, req *TemplateRequest) bool {
+	ctx := r.Context()
+	conversation, err := s.db.GetConversationByID(ctx, req.FromConversation)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return false
+	}
+	dirs, err := s.db.ListConversationDirectories(ctx, conversation.ConversationID)
+	if err != nil {
+		s.logger.Error("Failed to list conversation directories", "conversationID", conversation.ConversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	hooks, err := s.db.ListConversationHooks(ctx, conversation.ConversationID)
+	if err != nil {
+		s.logger.Error("Failed to list conversation hooks", "conversationID", conversation.ConversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	settings, err := s.db.GetConversationSettings(ctx, conversation.ConversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation settings", "conversationID", conversation.ConversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	messages, err := s.db.ListMessages(ctx, conversation.ConversationID)
+	if err != nil {
+		s.logger.Error("Failed to list messages", "conversationID", conversation.ConversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	if req.Model == "" && conversation.Model != nil {
+		req.Model = *conversation.Model
+	}
+	if req.Cwd == "" && conversation.Cwd != nil {
+		req.Cwd = *conversation.Cwd
+	}
+	if req.Directories == nil {
+		req.Directories = dirs
+	}
+	if req.Hooks == nil {
+		for _, hook := range hooks {
+			req.Hooks = append(req.Hooks, HookRequest{Event: hook.Event, Command: hook.Command, InjectOutput: hook.InjectOutput})
+		}
+	}
+	if req.Settings == nil && settings != nil {
+		cs := toConversationSettings(settings)
+		req.Settings = &cs
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		for _, msg := range messages {
+			if msg.Type == string(db.MessageTypeUser) {
+				req.Prompt = messageText(msg.LlmData)
+				break
+			}
+		}
+	}
+	return true
+}
+
+// validateTemplate checks a template's name, prompt, directories and hooks
+func validateTemplate(req TemplateRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("template name is required")
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		return fmt.Errorf("template prompt is required")
+	}
+	for _, dir := range req.Directories {
+		if err := validateDirectory(dir); err != nil {
+			return err
+		}
+	}
+	for _, hook := range req.Hooks {
+		if err := validateHook(hook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTemplateRequest decodes, completes and validates a template request. It
+// writes an error response and returns false on failure.
+func (s *Server) readTemplateRequest(w http.ResponseWriter, r *http.Request) (TemplateRequest, string, bool) {
+	var req TemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return req, "", false
+	}
+	if req.FromConversation != "" && !s.fromConversation(w, r, &req) {
+		return req, "", false
+	}
+	if err := validateTemplate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return req, "", false
+	}
+	config, err := json.Marshal(req.TemplateConfig)
+	if err != nil {
+		http.Error(w, "Invalid template", http.StatusBadRequest)
+		return req, "", false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	return req, string(config), true
+}
+
+// handleTemplates handles /api/templates: GET lists the conversation
+// templates, POST creates one.
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := s.requestUser(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		templates, err := s.db.ListConversationTemplates(ctx, owner)
+		if err != nil {
+			s.logger.Error("Failed to list templates", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		result := make([]TemplateAPI, 0, len(templates))
+		for _, t := range templates {
+			result = append(result, toTemplateAPI(t))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	case http.MethodPost:
+		req, config, ok := s.readTemplateRequest(w, r)
+		if !ok {
+			return
+		}
+		template, err := s.db.CreateConversationTemplate(ctx, generated.CreateConversationTemplateParams{
+			Owner:       owner,
+			Name:        req.Name,
+			Description: req.Description,
+			Prompt:      req.Prompt,
+			Config:      config,
+		})
+		if err != nil {
+			s.templateWriteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(toTemplateAPI(*template))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTemplate handles /api/templates/{id} (GET, PUT, DELETE)
+func (s *Server) handleTemplate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := s.requestUser(r)
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid template ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		template, err := s.db.GetConversationTemplate(ctx, owner, id)
+		if err != nil {
+			s.templateWriteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toTemplateAPI(*template))
+	case http.MethodPut:
+		req, config, ok := s.readTemplateRequest(w, r)
+		if !ok {
+			return
+		}
+		template, err := s.db.UpdateConversationTemplate(ctx, generated.UpdateConversationTemplateParams{
+			Name:        req.Name,
+			Description: req.Description,
+			Prompt:      req.Prompt,
+			Config:      config,
+			TemplateID:  id,
+			Owner:       owner,
+		})
+		if err != nil {
+			s.templateWriteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toTemplateAPI(*template))
+	case http.MethodDelete:
+		if err := s.db.DeleteConversationTemplate(ctx, owner, id); err != nil {
+			s.templateWriteError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleConversationFromTemplate handles POST /api/conversations?template=<name>,
+// which starts a conversation from one of the user's templates. The body is
+// optional; it is a ChatRequest whose message is added after the template's
+// prompt and whose other fields override or add to the template's.
+func (s *Server) handleConversationFromTemplate(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("template")
+	if name == "" {
+		http.Error(w, "template is required", http.StatusBadRequest)
+		return
+	}
+	template, err := s.db.GetConversationTemplateByName(r.Context(), s.requestUser(r), name)
+	if err != nil {
+		s.templateWriteError(w, err)
+		return
+	}
+
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !s.fillSnippet(w, r, &req) {
+		return
+	}
+	s.startNewConversation(w, r, toTemplateAPI(*template).chatRequest(req))
+}
+
+// templateWriteError maps a template database error to a response
+func (s *Server) templateWriteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Template not found", http.StatusNotFound)
+	case strings.Contains(err.Error(), "UNIQUE constraint failed"):
+		http.Error(w, "A template with this name already exists", http.StatusConflict)
+	default:
+		s.logger.Error("Failed to save template", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConversationTemplates(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	cwd := t.TempDir()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, bytes.NewReader(data))
+		r.Header.Set("X-Shelley-Request", "1")
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	h.NewConversation("echo: onboarding", cwd)
+	h.WaitResponse()
+	h.waitIdle()
+	source := h.convID
+
+	if w := do("POST", "/api/templates", TemplateRequest{Name: "empty"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a missing prompt, got %d", w.Code)
+	}
+	// A template saved from a conversation takes its settings and first message
+	w := do("POST", "/api/templates", TemplateRequest{Name: "onboard", FromConversation: source})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created TemplateAPI
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Prompt != "echo: onboarding" || created.Model != "predictable" || created.Cwd != cwd {
+		t.Errorf("unexpected template: %+v", created)
+	}
+	if w := do("POST", "/api/templates", TemplateRequest{Name: "onboard", Prompt: "dup"}); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for duplicate name, got %d", w.Code)
+	}
+
+	if w := do("POST", "/api/conversations?template=missing", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown template, got %d", w.Code)
+	}
+	// The request's message follows the template's prompt
+	w = do("POST", "/api/conversations?template=onboard", ChatRequest{Message: "billing service"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID, h.responsesCount = resp.ConversationID, 0
+	if got := h.WaitResponse(); got != "onboarding\n\nbilling service" {
+		t.Errorf("response = %q", got)
+	}
+	conversation, err := h.db.GetConversationByID(t.Context(), resp.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conversation.Cwd == nil || *conversation.Cwd != cwd {
+		t.Errorf("conversation cwd = %v, want %s", conversation.Cwd, cwd)
+	}
+
+	path := fmt.Sprintf("/api/templates/%d", created.ID)
+	w = do("PUT", path, TemplateRequest{Name: "onboard", Prompt: "echo: updated", TemplateConfig: TemplateConfig{PlanMode: true}})
+	var updated TemplateAPI
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if w.Code != http.StatusOK || updated.Prompt != "echo: updated" || !updated.PlanMode || updated.Cwd != "" {
+		t.Errorf("update: status %d, template %+v", w.Code, updated)
+	}
+	if w := do("DELETE", path, nil); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+	var list []TemplateAPI
+	json.Unmarshal(do("GET", "/api/templates", nil).Body.Bytes(), &list)
+	if len(list) != 0 {
+		t.Errorf("templates after delete = %+v", list)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -15,6 +16,8 @@ import (
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/ant"
+	"shelley.exe.dev/llm/llmrr"
 	"shelley.exe.dev/loop"
 )
 
@@ -24,7 +27,7 @@ type TestHarness struct {
 	db             *db.DB
 	server         *Server
 	cleanup        func()
-	llm            *loop.PredictableService
+	llm            *loop.PredictableService // nil for record/replay harnesses
 	convID         string
 	timeout        time.Duration
 	responsesCount int // Number of agent responses seen so far
@@ -53,6 +56,47 @@ func NewTestHarness(t *testing.T) *TestHarness {
 	}
 }
 
+// NewRRTestHarness creates a test harness whose LLM replays the golden file
+// testdata/<name>.jsonl. With SHELLEY_LLM_RR=record it talks to Claude Haiku
+// instead, using $ANTHROPIC_API_KEY, and rewrites the golden file.
+func NewRRTestHarness(t *testing.T, name string) *TestHarness {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".jsonl")
+	mode := llmrr.ModeFromEnv()
+	var recorded llm.Service
+	if mode == llmrr.ModeRecord {
+		apiKey := os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			t.Skip("ANTHROPIC_API_KEY not set, can't record " + path)
+		}
+		recorded = &ant.Service{APIKey: apiKey, Model: ant.Claude45Haiku}
+	} else if _, err := os.Stat(path); err != nil {
+		t.Skipf("golden file %s missing, record it with %s=record", path, llmrr.EnvVar)
+	}
+	service, err := llmrr.Open(path, mode, recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database, cleanup := setupTestDB(t)
+	llmManager := &testLLMManager{service: service}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	server := NewServer(database, llmManager, claudetool.ToolSetConfig{EnableBrowser: false}, logger, true, "", "predictable", "", nil)
+
+	timeout := 5 * time.Second
+	if mode == llmrr.ModeRecord {
+		timeout = 60 * time.Second
+	}
+	return &TestHarness{
+		t:       t,
+		db:      database,
+		server:  server,
+		cleanup: cleanup,
+		timeout: timeout,
+	}
+}
+
 // Close cleans up the test harness resources.
 func (h *TestHarness) Close() {
 	h.cleanup()
@@ -114,6 +158,26 @@ func (h *TestHarness) Chat(msg string) *TestHarness {
 	return h
 }
 
+// Script makes msg start a scripted series of LLM responses; see
+// loop.PredictableService.Script.
+func (h *TestHarness) Script(msg string, steps ...loop.ScriptStep) *TestHarness {
+	h.llm.Script(msg, steps...)
+	return h
+}
+
+// Cancel cancels the current conversation's turn.
+func (h *TestHarness) Cancel() *TestHarness {
+	h.t.Helper()
+
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/cancel", nil)
+	w := httptest.NewRecorder()
+	h.server.handleCancelConversation(w, req, h.convID)
+	if w.Code != http.StatusOK {
+		h.t.Fatalf("Cancel: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	return h
+}
+
 // WaitToolResult waits for a tool result and returns its text content.
 func (h *TestHarness) WaitToolResult() string {
 	h.t.Helper()
@@ -250,3 +314,63 @@ func (h *TestHarness) GetContextWindowSize() uint64 {
 
 	return resp.ContextWindowSize
 }
+
+// waitIdle waits until the agent is no longer marked as working.
+func (h *TestHarness) waitIdle() {
+	h.t.Helper()
+	manager, err := h.server.getOrCreateConversationManager(h.t.Context(), h.convID)
+	if err != nil {
+		h.t.Fatalf("waitIdle: %v", err)
+	}
+	deadline := time.Now().Add(h.timeout)
+	for manager.IsAgentWorking() {
+		if time.Now().After(deadline) {
+			h.t.Fatal("waitIdle: agent still working")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// prompts returns the text of the user-typed messages in the conversation.
+func (h *TestHarness) prompts() []string {
+	h.t.Helper()
+	messages, err := h.db.ListMessages(h.t.Context(), h.convID)
+	if err != nil {
+		h.t.Fatalf("prompts: %v", err)
+	}
+	var out []string
+	for _, msg := range messages {
+		if prompt, ok := userPrompt(msg); ok {
+			out = append(out, prompt.Content[0].Text)
+		}
+	}
+	return out
+}
+
+// systemPrompt returns the system prompt of the most recent LLM request that had
+// one. Slug generation runs concurrently and sends requests without a system prompt.
+func (h *TestHarness) systemPrompt() string {
+	h.t.Helper()
+	requests := h.llm.GetRecentRequests()
+	for i := len(requests) - 1; i >= 0; i-- {
+		if len(requests[i].System) == 0 {
+			continue
+		}
+		var texts []string
+		for _, s := range requests[i].System {
+			texts = append(texts, s.Text)
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// post sends a POST request with a JSON body to a path of the conversation.
+func (h *TestHarness) post(path string, body any) *httptest.ResponseRecorder {
+	h.t.Helper()
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/"+h.convID+path, strings.NewReader(string(data)))
+	w := httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, req)
+	return w
+}
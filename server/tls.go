@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// hstsMaxAge is the max-age, in seconds, of the Strict-Transport-Security header.
+const hstsMaxAge = 365 * 24 * 60 * 60
+
+// TLSConfig configures HTTPS. Set either a certificate and key, or the
+// domains to get certificates for from Let's Encrypt.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+
+	// AutocertDomains are the host names to get certificates for. Let's
+	// Encrypt validates them by connecting to port 443 of each host.
+	AutocertDomains []string
+	// AutocertCacheDir is where certificates and the account key are kept.
+	AutocertCacheDir string
+	// AutocertEmail is the optional contact for certificate problems.
+	AutocertEmail string
+}
+
+// Enabled reports whether the configuration turns HTTPS on.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || len(c.AutocertDomains) > 0
+}
+
+func (c TLSConfig) validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("both a TLS certificate and key are required")
+	}
+	if c.CertFile != "" && len(c.AutocertDomains) > 0 {
+		return fmt.Errorf("a TLS certificate cannot be combined with automatic certificates")
+	}
+	if len(c.AutocertDomains) > 0 && c.AutocertCacheDir == "" {
+		return fmt.Errorf("automatic certificates require a cache directory")
+	}
+	return nil
+}
+
+// tlsConfig builds the configuration for the HTTPS listener.
+func (c TLSConfig) tlsConfig() (*tls.Config, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if len(c.AutocertDomains) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(c.AutocertDomains...),
+			Cache:      autocert.DirCache(c.AutocertCacheDir),
+			Email:      c.AutocertEmail,
+		}
+		// The manager's configuration answers the TLS-ALPN-01 challenge
+		return m.TLSConfig(), nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// SetTLS makes the server use HTTPS.
+func (s *Server) SetTLS(c TLSConfig) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	s.tls = c
+	return nil
+}
+
+// SecureTransportMiddleware tells browsers to only use HTTPS from now on and
+// marks the cookies set by handlers as Secure. Use it only when serving HTTPS.
+func SecureTransportMiddleware() func(http.Handler) http.Handler {
+	hsts := fmt.Sprintf("max-age=%d", hstsMaxAge)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Strict-Transport-Security", hsts)
+			next.ServeHTTP(&secureCookieWriter{ResponseWriter: w}, r)
+		})
+	}
+}
+
+// secureCookieWriter adds the Secure attribute to cookies before the headers are sent.
+type secureCookieWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *secureCookieWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		cookies := w.Header()["Set-Cookie"]
+		for i, cookie := range cookies {
+			if !hasCookieAttribute(cookie, "secure") {
+				cookies[i] = cookie + "; Secure"
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *secureCookieWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses working through the wrapper.
+func (w *secureCookieWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack keeps websocket upgrades working through the wrapper.
+func (w *secureCookieWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *secureCookieWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func hasCookieAttribute(cookie, name string) bool {
+	for _, part := range strings.Split(cookie, ";")[1:] {
+		attr, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if strings.EqualFold(attr, name) {
+			return true
+		}
+	}
+	return false
+}
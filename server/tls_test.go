@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TLSConfig
+		wantErr bool
+	}{
+		{"disabled", TLSConfig{}, false},
+		{"cert and key", TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}, false},
+		{"cert without key", TLSConfig{CertFile: "cert.pem"}, true},
+		{"autocert", TLSConfig{AutocertDomains: []string{"example.com"}, AutocertCacheDir: "cache"}, false},
+		{"autocert without cache", TLSConfig{AutocertDomains: []string{"example.com"}}, true},
+		{"cert and autocert", TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", AutocertDomains: []string{"example.com"}, AutocertCacheDir: "cache"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSConfigLoadsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := TLSConfig{CertFile: certFile, KeyFile: keyFile}.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Certificates) != 1 {
+		t.Errorf("expected one certificate, got %d", len(config.Certificates))
+	}
+
+	if _, err := (TLSConfig{CertFile: keyFile, KeyFile: certFile}).tlsConfig(); err == nil {
+		t.Error("expected an error for swapped certificate and key")
+	}
+}
+
+func TestSecureTransportMiddleware(t *testing.T) {
+	handler := SecureTransportMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Header().Add("Set-Cookie", "other=1; Path=/; Secure")
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if got := rec.Header().Get("Strict-Transport-Security"); !strings.HasPrefix(got, "max-age=") {
+		t.Errorf("expected an HSTS header, got %q", got)
+	}
+	cookies := rec.Header()["Set-Cookie"]
+	if len(cookies) != 2 {
+		t.Fatalf("expected 2 cookies, got %v", cookies)
+	}
+	for _, cookie := range cookies {
+		if strings.Count(strings.ToLower(cookie), "secure") != 1 {
+			t.Errorf("expected cookie to be marked Secure once, got %q", cookie)
+		}
+	}
+}
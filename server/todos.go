@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+)
+
+// TodosResponse is the agent's task list for a conversation; Todos is empty when there is none
+type TodosResponse struct {
+	Todos     []claudetool.Todo `json:"todos"`
+	UpdatedAt *time.Time        `json:"updated_at,omitempty"`
+}
+
+// handleConversationTodos handles GET /api/conversations/<id>/todos
+func (s *Server) handleConversationTodos(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conversationID := r.PathValue("id")
+
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	todos, err := s.db.GetConversationTodos(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get todos", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := TodosResponse{Todos: []claudetool.Todo{}}
+	if todos != nil {
+		if err := json.Unmarshal([]byte(todos.Todos), &resp.Todos); err != nil {
+			s.logger.Error("Failed to parse todos", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		resp.UpdatedAt = &todos.UpdatedAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConversationTodos(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: hi", "")
+	h.WaitResponse()
+
+	get := func() TodosResponse {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/conversations/"+h.convID+"/todos", nil)
+		req.SetPathValue("id", h.convID)
+		w := httptest.NewRecorder()
+		h.server.handleConversationTodos(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp TodosResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	if resp := get(); len(resp.Todos) != 0 || resp.UpdatedAt != nil {
+		t.Fatalf("expected no todos, got %+v", resp)
+	}
+
+	if _, err := h.db.SaveConversationTodos(t.Context(), h.convID, `[{"content":"step one","status":"in_progress"}]`); err != nil {
+		t.Fatal(err)
+	}
+	resp := get()
+	if len(resp.Todos) != 1 || resp.Todos[0].Content != "step one" || resp.Todos[0].Status != "in_progress" || resp.UpdatedAt == nil {
+		t.Fatalf("unexpected todos: %+v", resp)
+	}
+
+	req := httptest.NewRequest("GET", "/api/conversations/missing/todos", nil)
+	req.SetPathValue("id", "missing")
+	w := httptest.NewRecorder()
+	h.server.handleConversationTodos(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown conversation, got %d", w.Code)
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"io"
+	"net/http"
+)
+
+// handleToolOutput handles GET /api/conversations/{id}/tool-results/{tool_use_id}/full,
+// returning the full text of a tool result of the conversation that was
+// truncated for the model.
+func (s *Server) handleToolOutput(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conversationID := r.PathValue("id")
+	toolUseID := r.PathValue("tool_use_id")
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	output, err := s.db.GetToolOutput(ctx, conversationID, toolUseID)
+	if err != nil {
+		s.logger.Error("Failed to get tool output", "conversationID", conversationID, "toolUseID", toolUseID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if output == nil {
+		http.Error(w, "No truncated output for this tool result", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, output.Output)
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/loop"
+)
+
+func TestToolOutputTruncation(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	defer func(budget int) { toolOutputBudget = budget }(toolOutputBudget)
+	toolOutputBudget = 100
+
+	h.Script("count", loop.ScriptToolCall("bash", map[string]string{"command": "seq 1 2000"}), loop.ScriptText("counted"))
+	h.NewConversation("count", "")
+	result := h.WaitToolResult()
+	h.waitIdle()
+	if len(result) > 1000 || !strings.HasPrefix(result, "1\n2\n") || !strings.HasSuffix(strings.TrimSpace(result), "2000") {
+		t.Fatalf("tool result was not truncated to its start and end:\n%s", result)
+	}
+	m := regexp.MustCompile(`tool_output tool and id "([^"]+)"`).FindStringSubmatch(result)
+	if m == nil {
+		t.Fatalf("truncated result doesn't say how to read the full output:\n%s", result)
+	}
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	get := func(conversationID, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/conversations/"+conversationID+"/tool-results/"+id+"/full", nil))
+		return w
+	}
+	w := get(h.convID, m[1])
+	if w.Code != http.StatusOK {
+		t.Fatalf("full output: status %d: %s", w.Code, w.Body.String())
+	}
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 2000 || lines[1999] != "2000" {
+		t.Errorf("full output has %d lines, want 2000", len(lines))
+	}
+	if w := get(h.convID, "missing"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown tool result: status %d, want 404", w.Code)
+	}
+
+	// Other conversations can't read it
+	convID := h.convID
+	h.NewConversation("echo: hello", "")
+	h.WaitResponse()
+	if w := get(h.convID, m[1]); w.Code != http.StatusNotFound {
+		t.Errorf("tool result of another conversation: status %d, want 404", w.Code)
+	}
+	if w := get("missing", m[1]); w.Code != http.StatusNotFound {
+		t.Errorf("unknown conversation: status %d, want 404", w.Code)
+	}
+	if w := get(convID, m[1]); w.Code != http.StatusOK {
+		t.Errorf("full output after another conversation: status %d", w.Code)
+	}
+}
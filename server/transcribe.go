@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"shelley.exe.dev/transcribe"
+)
+
+// maxTranscribeSize is the largest recording accepted, the limit of the
+// OpenAI transcription API.
+const maxTranscribeSize = 25 * 1024 * 1024
+
+// SetTranscriber sets the speech-to-text provider used for dictation.
+// Transcription is disabled until it is set.
+func (s *Server) SetTranscriber(t transcribe.Transcriber) {
+	s.transcriber = t
+}
+
+// handleTranscribe transcribes a recording, uploaded as the "file" field of
+// a multipart form, and returns {"text": ...} for the UI to insert into the
+// message being written.
+func (s *Server) handleTranscribe(w http.ResponseWriter, r *http.Request) {
+	if s.transcriber == nil {
+		http.Error(w, "transcription is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxTranscribeSize+1024*1024)
+	if err := r.ParseMultipartForm(maxTranscribeSize); err != nil {
+		http.Error(w, "failed to parse form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "failed to get uploaded file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	audio, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "failed to read uploaded file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(audio) == 0 {
+		http.Error(w, "recording is empty", http.StatusBadRequest)
+		return
+	}
+
+	text, err := s.transcriber.Transcribe(r.Context(), audio, header.Filename)
+	if err != nil {
+		s.logger.Error("Transcription failed", "transcriber", s.transcriber.Name(), "error", err)
+		http.Error(w, "transcription failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"text": text})
+}
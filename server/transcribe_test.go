@@ -0,0 +1,62 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/loop"
+)
+
+type fakeTranscriber struct{}
+
+func (fakeTranscriber) Name() string { return "fake" }
+
+func (fakeTranscriber) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	return "heard " + string(audio) + " in " + filename, nil
+}
+
+func TestTranscribeEndpoint(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+	server := NewServer(database, &testLLMManager{service: loop.NewPredictableService()}, claudetool.ToolSetConfig{}, slog.Default(), true, "", "predictable", "", nil)
+	mux := http.NewServeMux()
+	server.RegisterRoutes(mux)
+
+	transcribe := func(audio string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "speech.webm")
+		part.Write([]byte(audio))
+		writer.Close()
+		req := httptest.NewRequest("POST", "/api/transcribe", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := transcribe("hello"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a transcriber: status %d, want 503", w.Code)
+	}
+
+	server.SetTranscriber(fakeTranscriber{})
+	w := transcribe("hello")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var response map[string]string
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["text"] != "heard hello in speech.webm" {
+		t.Errorf("response = %v", response)
+	}
+	if w := transcribe(""); w.Code != http.StatusBadRequest {
+		t.Errorf("empty recording: status %d, want 400", w.Code)
+	}
+}
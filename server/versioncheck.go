@@ -1,7 +1,6 @@
 package server
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"crypto/sha256"
@@ -16,7 +15,6 @@ import (
 	"os/exec"
 	"runtime"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
@@ -314,7 +312,7 @@ func (vc *VersionChecker) fetchLatestRelease(ctx context.Context) (*GitHubReleas
 // findDownloadURL finds the appropriate download URL for the current platform.
 func (vc *VersionChecker) findDownloadURL(release *GitHubRelease) string {
 	// Build expected asset name: shelley_<os>_<arch>
-	expectedName := fmt.Sprintf("shelley_%s_%s", runtime.GOOS, runtime.GOARCH)
+	expectedName := version.BinaryAsset()
 
 	for _, asset := range release.Assets {
 		if asset.Name == expectedName {
@@ -369,7 +367,8 @@ func parseMinorVersion(tag string) int {
 	return minor
 }
 
-// DoUpgrade downloads and applies the update with checksum verification.
+// DoUpgrade downloads and applies the update with checksum verification, and
+// signature verification in builds with a release public key.
 func (vc *VersionChecker) DoUpgrade(ctx context.Context) error {
 	if vc.skipCheck {
 		return fmt.Errorf("version checking is disabled")
@@ -524,54 +523,53 @@ func (vc *VersionChecker) doSudoUpgrade(binaryData []byte) error {
 }
 
 // fetchExpectedChecksum downloads checksums.txt and extracts the expected checksum for our binary.
+// When the build has a release public key, checksums.txt must carry a valid signature.
 func (vc *VersionChecker) fetchExpectedChecksum(ctx context.Context, release *GitHubRelease) (string, error) {
-	// Find checksums.txt URL
-	var checksumURL string
+	checksums, err := vc.fetchAsset(ctx, release, version.ChecksumsAsset)
+	if err != nil {
+		return "", err
+	}
+
+	if version.PublicKey != "" {
+		signature, err := vc.fetchAsset(ctx, release, version.SignatureAsset)
+		if err != nil {
+			return "", err
+		}
+		if err := version.VerifyChecksums(checksums, signature); err != nil {
+			return "", err
+		}
+	}
+
+	return version.Checksum(checksums, version.BinaryAsset())
+}
+
+// fetchAsset downloads a small release asset, such as checksums.txt.
+func (vc *VersionChecker) fetchAsset(ctx context.Context, release *GitHubRelease, name string) ([]byte, error) {
+	var assetURL string
 	for _, asset := range release.Assets {
-		if asset.Name == "checksums.txt" {
-			checksumURL = asset.BrowserDownloadURL
+		if asset.Name == name {
+			assetURL = asset.BrowserDownloadURL
 			break
 		}
 	}
-	if checksumURL == "" {
-		return "", fmt.Errorf("checksums.txt not found in release")
+	if assetURL == "" {
+		return nil, fmt.Errorf("%s not found in release", name)
 	}
 
-	// Download checksums.txt
-	req, err := http.NewRequestWithContext(ctx, "GET", checksumURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", assetURL, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download checksums: status %d", resp.StatusCode)
-	}
-
-	// Parse checksums.txt (format: "checksum  filename")
-	expectedBinaryName := fmt.Sprintf("shelley_%s_%s", runtime.GOOS, runtime.GOARCH)
-
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Fields(line)
-		if len(parts) >= 2 {
-			checksum := parts[0]
-			filename := parts[1]
-			if filename == expectedBinaryName {
-				return checksum, nil
-			}
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("error reading checksums: %w", err)
+		return nil, fmt.Errorf("failed to download %s: status %d", name, resp.StatusCode)
 	}
 
-	return "", fmt.Errorf("checksum for %s not found in checksums.txt", expectedBinaryName)
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 }
@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"io/fs"
@@ -10,6 +12,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"shelley.exe.dev/version"
 )
 
 func TestParseMinorVersion(t *testing.T) {
@@ -238,3 +242,53 @@ func TestIsPermissionError(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchExpectedChecksumSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := version.PublicKey
+	version.PublicKey = base64.StdEncoding.EncodeToString(pub)
+	t.Cleanup(func() { version.PublicKey = old })
+
+	checksums := []byte("abc123  " + version.BinaryAsset() + "\n")
+	signature := ed25519.Sign(priv, checksums)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/checksums.txt":
+			w.Write(checksums)
+		case "/checksums.txt.sig":
+			w.Write(signature)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	vc := &VersionChecker{}
+	release := &GitHubRelease{TagName: "v0.2.0"}
+	for _, name := range []string{version.ChecksumsAsset, version.SignatureAsset} {
+		release.Assets = append(release.Assets, struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		}{Name: name, BrowserDownloadURL: server.URL + "/" + name})
+	}
+
+	sum, err := vc.fetchExpectedChecksum(t.Context(), release)
+	if err != nil || sum != "abc123" {
+		t.Fatalf("fetchExpectedChecksum = %q, %v", sum, err)
+	}
+
+	// Tampered checksums are rejected
+	checksums = []byte("000000  " + version.BinaryAsset() + "\n")
+	if _, err := vc.fetchExpectedChecksum(t.Context(), release); err == nil {
+		t.Error("expected an error for checksums not matching the signature")
+	}
+
+	// Signed builds require a signature
+	release.Assets = release.Assets[:1]
+	if _, err := vc.fetchExpectedChecksum(t.Context(), release); err == nil {
+		t.Error("expected an error for a release without a signature")
+	}
+}
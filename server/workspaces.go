@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// cloneTimeout bounds a clone of a repository into the workspaces directory.
+const cloneTimeout = 30 * time.Minute
+
+// scpLikeURLRegexp matches git's scp-like syntax for SSH URLs, e.g.
+// git@github.com:org/repo.git.
+var scpLikeURLRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^/-]`)
+
+var workspaceNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// CloneRequest is the request body for POST /api/workspaces/clone
+type CloneRequest struct {
+	URL string `json:"url"`
+	// Branch is checked out instead of the remote's default branch
+	Branch string `json:"branch,omitempty"`
+}
+
+// CloneEvent is a line of the newline-delimited JSON response of
+// POST /api/workspaces/clone: progress events while git runs, then one done
+// or error event.
+type CloneEvent struct {
+	Type      string `json:"type"` // progress, done or error
+	Message   string `json:"message,omitempty"`
+	Path      string `json:"path,omitempty"`
+	OriginURL string `json:"origin_url,omitempty"`
+}
+
+// SetWorkspacesDir sets the directory repositories are cloned into for new
+// conversations. Cloning is disabled until it is set.
+func (s *Server) SetWorkspacesDir(dir string) {
+	s.workspacesDir = dir
+}
+
+// validateRepoURL checks that raw is a URL git can clone over the network or
+// from a local repository, and not something git would take as an option or
+// a transport helper.
+func validateRepoURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("url is required")
+	}
+	if scpLikeURLRegexp.MatchString(raw) {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	switch u.Scheme {
+	case "https", "http", "ssh", "git":
+		if u.Host == "" {
+			return fmt.Errorf("url has no host: %s", raw)
+		}
+		return nil
+	case "file":
+		return nil
+	}
+	return fmt.Errorf("url must be an https, http, ssh, git or file URL, or user@host:path")
+}
+
+// workspaceName derives a directory name from a repository URL, e.g. "repo"
+// for https://github.com/org/repo.git.
+func workspaceName(repoURL string) string {
+	p := repoURL
+	if u, err := url.Parse(repoURL); err == nil && u.Scheme != "" {
+		p = u.Path
+	} else if _, after, ok := strings.Cut(repoURL, ":"); ok {
+		p = after
+	}
+	name := strings.TrimSuffix(path.Base(strings.TrimRight(p, "/")), ".git")
+	name = strings.Trim(workspaceNameRegexp.ReplaceAllString(name, "-"), "-.")
+	if name == "" {
+		return "repo"
+	}
+	return name
+}
+
+// reserveWorkspace creates an empty directory for a clone named after the
+// repository, adding a number if the name is taken.
+func reserveWorkspace(dir, name string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	for i := 1; ; i++ {
+		candidate := filepath.Join(dir, name)
+		if i > 1 {
+			candidate = filepath.Join(dir, fmt.Sprintf("%s-%d", name, i))
+		}
+		err := os.Mkdir(candidate, 0o755)
+		if err == nil {
+			return candidate, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+	}
+}
+
+// handleCloneWorkspace handles POST /api/workspaces/clone. It clones a
+// repository into the workspaces directory, streaming git's progress, and
+// records where it came from. New conversations then use the clone as their
+// working directory.
+func (s *Server) handleCloneWorkspace(w http.ResponseWriter, r *http.Request) {
+	if s.workspacesDir == "" {
+		http.Error(w, "Cloning repositories is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req CloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if err := validateRepoURL(req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.HasPrefix(req.Branch, "-") {
+		http.Error(w, "Invalid branch name", http.StatusBadRequest)
+		return
+	}
+
+	dir, err := reserveWorkspace(s.workspacesDir, workspaceName(req.URL))
+	if err != nil {
+		s.logger.Error("Failed to create workspace directory", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	enc := json.NewEncoder(w)
+	send := func(event CloneEvent) {
+		enc.Encode(event)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	output, err := cloneRepository(r.Context(), req, dir, func(line string) {
+		send(CloneEvent{Type: "progress", Message: line})
+	})
+	if err != nil {
+		s.logger.Warn("Failed to clone repository", "url", req.URL, "error", err)
+		os.RemoveAll(dir)
+		msg := err.Error()
+		if output != "" {
+			msg += ": " + output
+		}
+		send(CloneEvent{Type: "error", Message: msg})
+		return
+	}
+
+	if _, err := s.db.CreateWorkspace(r.Context(), generated.CreateWorkspaceParams{
+		Path:      dir,
+		OriginUrl: req.URL,
+		Branch:    req.Branch,
+		Owner:     s.requestUser(r),
+	}); err != nil {
+		s.logger.Error("Failed to record workspace", "path", dir, "error", err)
+		send(CloneEvent{Type: "error", Message: "Internal server error"})
+		return
+	}
+	s.logger.Info("Cloned repository", "url", req.URL, "path", dir)
+	send(CloneEvent{Type: "done", Path: dir, OriginURL: req.URL})
+}
+
+// cloneRepository runs git clone into dir, passing each progress line to
+// progress. On failure it returns the last lines git printed.
+func cloneRepository(ctx context.Context, req CloneRequest, dir string, progress func(string)) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, cloneTimeout)
+	defer cancel()
+
+	args := []string{"clone", "--progress"}
+	if req.Branch != "" {
+		args = append(args, "--branch", req.Branch)
+	}
+	args = append(args, "--", req.URL, dir)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	// Fail instead of waiting for credentials nobody can type
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	// git redraws progress lines with carriage returns
+	var last []string
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		progress(line)
+		if last = append(last, line); len(last) > 5 {
+			last = last[1:]
+		}
+	}
+	io.Copy(io.Discard, stderr)
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return strings.Join(last, "\n"), fmt.Errorf("git clone failed: %w", err)
+	}
+	return "", nil
+}
+
+// scanProgressLines is a bufio.SplitFunc splitting on \n or \r.
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
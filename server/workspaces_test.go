@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCloneWorkspace(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.SetWorkspacesDir(filepath.Join(t.TempDir(), "workspaces"))
+
+	// A source repository with one commit
+	source := filepath.Join(t.TempDir(), "my-repo")
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main", source},
+		{"-C", source, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	clone := func(req CloneRequest) (int, []CloneEvent) {
+		t.Helper()
+		data, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/workspaces/clone", bytes.NewReader(data)))
+		var events []CloneEvent
+		scanner := bufio.NewScanner(w.Body)
+		for w.Code == http.StatusOK && scanner.Scan() {
+			var event CloneEvent
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				t.Fatalf("invalid event %s: %v", scanner.Bytes(), err)
+			}
+			events = append(events, event)
+		}
+		return w.Code, events
+	}
+
+	url := "file://" + source
+	code, events := clone(CloneRequest{URL: url})
+	if code != http.StatusOK || len(events) == 0 {
+		t.Fatalf("clone: status %d, events %v", code, events)
+	}
+	done := events[len(events)-1]
+	if done.Type != "done" || filepath.Base(done.Path) != "my-repo" || done.OriginURL != url {
+		t.Fatalf("last event = %+v, want done", done)
+	}
+	if _, err := os.Stat(filepath.Join(done.Path, ".git")); err != nil {
+		t.Errorf("clone has no .git: %v", err)
+	}
+
+	// The clone is recorded, also for its subdirectories
+	workspace, err := h.db.FindWorkspaceForDirectory(context.Background(), filepath.Join(done.Path, "sub"))
+	if err != nil || workspace == nil || workspace.OriginUrl != url {
+		t.Fatalf("FindWorkspaceForDirectory = %+v, %v", workspace, err)
+	}
+	if prompt := RepositoryPrompt(*workspace); !strings.Contains(prompt, url) {
+		t.Errorf("repository prompt doesn't mention the origin: %s", prompt)
+	}
+
+	// A second clone gets its own directory
+	_, events = clone(CloneRequest{URL: url, Branch: "main"})
+	if second := events[len(events)-1]; second.Type != "done" || filepath.Base(second.Path) != "my-repo-2" {
+		t.Errorf("second clone = %+v, want done in my-repo-2", second)
+	}
+
+	// A failed clone reports an error and leaves nothing behind
+	_, events = clone(CloneRequest{URL: "file://" + filepath.Join(t.TempDir(), "missing")})
+	if failed := events[len(events)-1]; failed.Type != "error" {
+		t.Errorf("clone of a missing repository = %+v, want an error", failed)
+	}
+	if _, err := os.Stat(filepath.Join(h.server.workspacesDir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("failed clone left its directory: %v", err)
+	}
+
+	for _, bad := range []CloneRequest{{URL: "--upload-pack=touch /tmp/x"}, {URL: "ext::sh -c touch% /tmp/x"}, {URL: url, Branch: "--bare"}} {
+		if code, _ := clone(bad); code != http.StatusBadRequest {
+			t.Errorf("clone %+v: status %d, want 400", bad, code)
+		}
+	}
+}
+
+func TestWorkspaceName(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://github.com/org/repo.git":    "repo",
+		"https://github.com/org/repo/":       "repo",
+		"git@github.com:org/my.repo.git":     "my.repo",
+		"ssh://git@example.com:22/a/b c.git": "b-c",
+		"https://example.com/":               "repo",
+	} {
+		if got := workspaceName(url); got != want {
+			t.Errorf("workspaceName(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import "net/http"
+
+// hostPath returns the path on this machine of a path from a client. When
+// Shelley runs in WSL, clients on the Windows host may send Windows paths,
+// such as C:\src for /mnt/c/src.
+func (s *Server) hostPath(p string) string {
+	if s.wsl == nil {
+		return p
+	}
+	return s.wsl.ToLinux(p)
+}
+
+// clientPath returns a path of this machine the way the client asked for
+// it: in its Windows form if Shelley runs in WSL and the request has
+// paths=windows, or as it is.
+func (s *Server) clientPath(r *http.Request, p string) string {
+	if s.wsl == nil || p == "" || r.URL.Query().Get("paths") != "windows" {
+		return p
+	}
+	return s.wsl.ToWindows(p)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/wslpath"
+)
+
+func TestWSLListDirectory(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	mounts := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(mounts, "c", "src", "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	h.server.wsl = &wslpath.Translator{MountRoot: mounts, Distro: "Ubuntu"}
+
+	list := func(query string) ListDirectoryResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.handleListDirectory(w, httptest.NewRequest("GET", "/api/list-directory?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp ListDirectoryResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		return resp
+	}
+
+	// Windows paths from the client are translated
+	resp := list("path=" + url.QueryEscape(`C:\src`))
+	if resp.Path != filepath.Join(mounts, "c", "src") {
+		t.Errorf("expected the mounted drive, got %q", resp.Path)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0].Name != "app" {
+		t.Errorf("expected the app directory, got %+v", resp.Entries)
+	}
+
+	// and returned in Windows form if asked for
+	resp = list("paths=windows&path=" + url.QueryEscape(`C:\src`))
+	if resp.Path != `C:\src` || resp.Parent != `C:\` {
+		t.Errorf("expected Windows paths, got path %q and parent %q", resp.Path, resp.Parent)
+	}
+	resp = list("paths=windows&path=" + url.QueryEscape(mounts))
+	if !strings.HasPrefix(resp.Path, `\\wsl.localhost\Ubuntu\`) {
+		t.Errorf("expected a path in the distribution's share, got %q", resp.Path)
+	}
+}
+
+func TestWindowsPathsSetting(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: hello", t.TempDir())
+	h.WaitResponse()
+	h.waitIdle()
+
+	if w := h.post("/settings", ConversationSettings{WindowsPaths: true}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 outside WSL, got %d", w.Code)
+	}
+
+	mounts := t.TempDir()
+	h.server.wsl = &wslpath.Translator{MountRoot: mounts, Distro: "Ubuntu"}
+	// The conversation's manager was created before
+	manager, err := h.server.getOrCreateConversationManager(t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.wsl = h.server.wsl
+
+	if w := h.post("/settings", ConversationSettings{WindowsPaths: true}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("echo: again")
+	h.WaitResponse()
+	h.waitIdle()
+	if prompt := h.systemPrompt(); !strings.Contains(prompt, "<windows_paths>") || !strings.Contains(prompt, `\\wsl.localhost\Ubuntu\`) {
+		t.Errorf("expected the system prompt to explain Windows paths, got %q", prompt)
+	}
+}
@@ -0,0 +1,228 @@
+// Package termtext processes terminal output: it collapses lines redrawn
+// with carriage returns, such as progress bars, and either strips ANSI escape
+// sequences or converts their colors to HTML classes.
+package termtext
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+const esc = '\x1b'
+
+// HasEscapes reports whether s contains escape sequences.
+func HasEscapes(s string) bool {
+	return strings.IndexByte(s, esc) >= 0
+}
+
+// Collapse keeps only the final state of lines redrawn with carriage
+// returns, so that a progress bar updated a thousand times is one line.
+func Collapse(s string) string {
+	if strings.IndexByte(s, '\r') < 0 {
+		return s
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		lines[i] = line[strings.LastIndexByte(line, '\r')+1:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Strip collapses s and removes its escape sequences and backspaces.
+func Strip(s string) string {
+	s = Collapse(s)
+	if !HasEscapes(s) && strings.IndexByte(s, '\b') < 0 {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case esc:
+			_, n := parseEscape(s[i:])
+			i += n
+		case '\b':
+			// Backspace overstrikes, e.g. "_\bx" underlines in man pages
+			if len(b) > 0 && b[len(b)-1] != '\n' {
+				b = b[:len(b)-1]
+			}
+			i++
+		default:
+			b = append(b, s[i])
+			i++
+		}
+	}
+	return string(b)
+}
+
+// HTML collapses s and converts it to HTML, with the text styled by SGR
+// escape sequences wrapped in spans with classes such as "ansi-bold" and
+// "ansi-fg-red". Other escape sequences are removed.
+func HTML(s string) string {
+	s = strings.ReplaceAll(Collapse(s), "\b", "")
+	var b strings.Builder
+	var st style
+	open := false
+	for len(s) > 0 {
+		i := strings.IndexByte(s, esc)
+		if i < 0 {
+			i = len(s)
+		}
+		if i > 0 {
+			if classes := st.classes(); classes != "" && !open {
+				b.WriteString(`<span class="` + classes + `">`)
+				open = true
+			}
+			b.WriteString(html.EscapeString(s[:i]))
+			s = s[i:]
+			continue
+		}
+		params, n := parseEscape(s)
+		s = s[n:]
+		if params == nil {
+			continue
+		}
+		if open {
+			b.WriteString("</span>")
+			open = false
+		}
+		st.apply(params)
+	}
+	if open {
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
+
+// parseEscape parses the escape sequence at the start of s and returns its
+// length. For SGR sequences ("ESC [ ... m"), it also returns their
+// parameters, which are never nil.
+func parseEscape(s string) (sgr []int, n int) {
+	if len(s) < 2 {
+		return nil, len(s)
+	}
+	switch s[1] {
+	case '[':
+		// CSI: parameter and intermediate bytes, then a final byte
+		for i := 2; i < len(s); i++ {
+			if c := s[i]; c >= 0x40 && c <= 0x7e {
+				if c != 'm' {
+					return nil, i + 1
+				}
+				sgr = []int{}
+				for _, p := range strings.FieldsFunc(s[2:i], func(r rune) bool { return r == ';' || r == ':' }) {
+					v, _ := strconv.Atoi(p)
+					sgr = append(sgr, v)
+				}
+				if len(sgr) == 0 {
+					sgr = append(sgr, 0)
+				}
+				return sgr, i + 1
+			}
+		}
+		return nil, len(s)
+	case ']':
+		// OSC: terminated by BEL or ESC \
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return nil, i + 1
+			}
+			if s[i] == esc && i+1 < len(s) && s[i+1] == '\\' {
+				return nil, i + 2
+			}
+		}
+		return nil, len(s)
+	case '(', ')':
+		// Character set selection
+		return nil, min(3, len(s))
+	default:
+		return nil, 2
+	}
+}
+
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// style is the text style set by SGR sequences.
+type style struct {
+	bold, dim, italic, underline bool
+	fg, bg                       string
+}
+
+func (st *style) apply(params []int) {
+	for i := 0; i < len(params); i++ {
+		switch p := params[i]; {
+		case p == 0:
+			*st = style{}
+		case p == 1:
+			st.bold = true
+		case p == 2:
+			st.dim = true
+		case p == 3:
+			st.italic = true
+		case p == 4:
+			st.underline = true
+		case p == 22:
+			st.bold, st.dim = false, false
+		case p == 23:
+			st.italic = false
+		case p == 24:
+			st.underline = false
+		case p >= 30 && p <= 37:
+			st.fg = colorNames[p-30]
+		case p == 39:
+			st.fg = ""
+		case p >= 40 && p <= 47:
+			st.bg = colorNames[p-40]
+		case p == 49:
+			st.bg = ""
+		case p >= 90 && p <= 97:
+			st.fg = "bright-" + colorNames[p-90]
+		case p >= 100 && p <= 107:
+			st.bg = "bright-" + colorNames[p-100]
+		case p == 38 || p == 48:
+			// Extended colors: 5;n for the 256-color palette, 2;r;g;b for
+			// RGB. Only the 16 basic colors of the palette have classes.
+			color := ""
+			if i+2 < len(params) && params[i+1] == 5 {
+				if n := params[i+2]; n < 8 {
+					color = colorNames[n]
+				} else if n < 16 {
+					color = "bright-" + colorNames[n-8]
+				}
+				i += 2
+			} else if i+1 < len(params) && params[i+1] == 2 {
+				i = min(i+4, len(params)-1)
+			}
+			if p == 38 {
+				st.fg = color
+			} else {
+				st.bg = color
+			}
+		}
+	}
+}
+
+func (st *style) classes() string {
+	var classes []string
+	if st.bold {
+		classes = append(classes, "ansi-bold")
+	}
+	if st.dim {
+		classes = append(classes, "ansi-dim")
+	}
+	if st.italic {
+		classes = append(classes, "ansi-italic")
+	}
+	if st.underline {
+		classes = append(classes, "ansi-underline")
+	}
+	if st.fg != "" {
+		classes = append(classes, "ansi-fg-"+st.fg)
+	}
+	if st.bg != "" {
+		classes = append(classes, "ansi-bg-"+st.bg)
+	}
+	return strings.Join(classes, " ")
+}
@@ -0,0 +1,33 @@
+package termtext
+
+import "testing"
+
+func TestStrip(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"plain\n", "plain\n"},
+		{"\x1b[1;32mok\x1b[0m  pkg\n", "ok  pkg\n"},
+		{"[  1%]\r[ 50%]\r[100%]\r\ndone\r\n", "[100%]\ndone\n"},
+		{"npm \x1b[2K\x1b[1Gfetching\r\x1b[2K\x1b[1Gdone\n", "done\n"},
+		{"\x1b]0;title\atext\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\", "textlink"},
+		{"_\bb_\bo\nx\b\b", "bo\n"},
+		{"trailing \x1b[", "trailing "},
+	} {
+		if got := Strip(tt.in); got != tt.want {
+			t.Errorf("Strip(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"a < b", "a &lt; b"},
+		{"\x1b[31mred\x1b[0m plain", `<span class="ansi-fg-red">red</span> plain`},
+		{"\x1b[1m\x1b[94mB\x1b[22mb\x1b[m", `<span class="ansi-bold ansi-fg-bright-blue">B</span><span class="ansi-fg-bright-blue">b</span>`},
+		{"\x1b[38;5;1;48;2;1;2;3;4mx", `<span class="ansi-underline ansi-fg-red">x</span>`},
+		{"\x1b[42m\x1b[Kbar\r\x1b[42mdone\x1b[49m", `<span class="ansi-bg-green">done</span>`},
+	} {
+		if got := HTML(tt.in); got != tt.want {
+			t.Errorf("HTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -761,22 +761,9 @@ func TestSystemPromptSentToLLM(t *testing.T) {
 			t.Fatalf("Expected status 201, got %d: %s", resp.StatusCode, body)
 		}
 
-		// Poll for async processing completion
-		// We need to wait for a request WITH a system prompt, not just any request
-		var lastReq *llm.Request
-		for i := 0; i < 50; i++ {
-			lastReq = predictableService.GetLastRequest()
-			if lastReq != nil && len(lastReq.System) > 0 {
-				break
-			}
-			time.Sleep(100 * time.Millisecond)
-		}
+		lastReq := waitTurnRequest(predictableService)
 		if lastReq == nil {
-			t.Fatal("No request was sent to the LLM service after 5 seconds")
-		}
-
-		if len(lastReq.System) == 0 {
-			t.Fatal("System prompt was not included in the LLM request")
+			t.Fatal("System prompt was not included in an LLM request after 5 seconds")
 		}
 
 		// Verify system prompt contains expected content
@@ -825,15 +812,7 @@ func TestSystemPromptSentToLLM(t *testing.T) {
 		conversationID := createResp.ConversationID
 
 		// Wait for first message to be processed
-		var firstReq *llm.Request
-		for i := 0; i < 50; i++ {
-			firstReq = predictableService.GetLastRequest()
-			if firstReq != nil {
-				break
-			}
-			time.Sleep(100 * time.Millisecond)
-		}
-		if firstReq == nil {
+		if waitTurnRequest(predictableService) == nil {
 			t.Fatal("First request was not sent to the LLM service after 5 seconds")
 		}
 
@@ -856,22 +835,9 @@ func TestSystemPromptSentToLLM(t *testing.T) {
 			t.Fatalf("Expected status 202, got %d: %s", resp2.StatusCode, body)
 		}
 
-		// Poll for second message to be processed
-		// We need to wait for a request WITH a system prompt, not just any request
-		var lastReq *llm.Request
-		for i := 0; i < 50; i++ {
-			lastReq = predictableService.GetLastRequest()
-			if lastReq != nil && len(lastReq.System) > 0 {
-				break
-			}
-			time.Sleep(100 * time.Millisecond)
-		}
+		lastReq := waitTurnRequest(predictableService)
 		if lastReq == nil {
-			t.Fatal("No request was sent to the LLM service after 5 seconds")
-		}
-
-		if len(lastReq.System) == 0 {
-			t.Fatal("System prompt was not included in subsequent LLM request")
+			t.Fatal("System prompt was not included in a subsequent LLM request after 5 seconds")
 		}
 
 		// Verify system prompt contains expected content
@@ -887,6 +853,22 @@ func TestSystemPromptSentToLLM(t *testing.T) {
 	})
 }
 
+// waitTurnRequest waits up to 5 seconds for a request of a turn, which has a
+// system prompt, and returns it, or nil. Slug generation sends requests
+// without one, which may be answered before or after the turn's.
+func waitTurnRequest(svc *loop.PredictableService) *llm.Request {
+	for i := 0; i < 50; i++ {
+		requests := svc.GetRecentRequests()
+		for j := len(requests) - 1; j >= 0; j-- {
+			if len(requests[j].System) > 0 {
+				return requests[j]
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
+
 // inspectableLLMManager is a test helper that always returns the same predictable service
 type inspectableLLMManager struct {
 	predictableService *loop.PredictableService
@@ -0,0 +1,202 @@
+// Package transcribe turns recorded speech into text, for dictating prompts.
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Transcriber converts speech to text.
+type Transcriber interface {
+	// Name identifies the transcriber and its model.
+	Name() string
+	// Transcribe returns the text spoken in audio. The filename of the
+	// recording tells its format, e.g. "speech.webm".
+	Transcribe(ctx context.Context, audio []byte, filename string) (string, error)
+}
+
+// Config selects and configures a speech-to-text provider.
+type Config struct {
+	// Provider is "openai" for an OpenAI-compatible transcription API, or
+	// "whisper.cpp" to run whisper.cpp locally. Empty selects openai when
+	// an API key is available and whisper.cpp when a model is configured.
+	Provider string `json:"provider"`
+	// Model is the transcription model (openai only).
+	Model string `json:"model"`
+	// BaseURL is the API base URL (openai only).
+	BaseURL string `json:"base_url"`
+	// APIKey authenticates with the API (openai only).
+	APIKey string `json:"-"`
+	// Command is the whisper.cpp command line program (whisper.cpp only).
+	Command string `json:"command"`
+	// ModelPath is the ggml model file (whisper.cpp only).
+	ModelPath string `json:"model_path"`
+	// Language is the spoken language, e.g. "en". Empty detects it.
+	Language string `json:"language"`
+}
+
+// New creates the transcriber described by cfg.
+func New(cfg Config) (Transcriber, error) {
+	provider := cfg.Provider
+	if provider == "" {
+		switch {
+		case cfg.APIKey != "":
+			provider = "openai"
+		case cfg.ModelPath != "":
+			provider = "whisper.cpp"
+		default:
+			return nil, fmt.Errorf("no transcription provider configured")
+		}
+	}
+	switch provider {
+	case "openai":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("openai transcription provider requires an API key")
+		}
+		t := &OpenAITranscriber{BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: cfg.Model, Language: cfg.Language}
+		if t.BaseURL == "" {
+			t.BaseURL = "https://api.openai.com/v1"
+		}
+		if t.Model == "" {
+			t.Model = "whisper-1"
+		}
+		return t, nil
+	case "whisper.cpp":
+		if cfg.ModelPath == "" {
+			return nil, fmt.Errorf("whisper.cpp transcription provider requires a model_path")
+		}
+		command := cfg.Command
+		if command == "" {
+			command = "whisper-cli"
+		}
+		if _, err := exec.LookPath(command); err != nil {
+			return nil, fmt.Errorf("whisper.cpp not found: %w", err)
+		}
+		if _, err := exec.LookPath("ffmpeg"); err != nil {
+			return nil, fmt.Errorf("whisper.cpp transcription requires ffmpeg: %w", err)
+		}
+		return &WhisperCPPTranscriber{Command: command, ModelPath: cfg.ModelPath, Language: cfg.Language}, nil
+	default:
+		return nil, fmt.Errorf("unknown transcription provider: %q", provider)
+	}
+}
+
+// OpenAITranscriber uses an OpenAI-compatible /audio/transcriptions API.
+type OpenAITranscriber struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	Language   string
+	HTTPClient *http.Client // optional
+}
+
+// Name implements Transcriber.
+func (t *OpenAITranscriber) Name() string {
+	return "openai:" + t.Model
+}
+
+// Transcribe implements Transcriber.
+func (t *OpenAITranscriber) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return "", err
+	}
+	fw.Write(audio)
+	mw.WriteField("model", t.Model)
+	mw.WriteField("response_format", "json")
+	if t.Language != "" {
+		mw.WriteField("language", t.Language)
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(t.BaseURL, "/")+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+t.APIKey)
+
+	client := t.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcription request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription request failed: %s: %s", resp.Status, data)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to parse transcription response: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// WhisperCPPTranscriber runs whisper.cpp locally. Recordings are converted
+// to the 16 kHz WAV it reads with ffmpeg.
+type WhisperCPPTranscriber struct {
+	Command   string
+	ModelPath string
+	Language  string
+}
+
+// Name implements Transcriber.
+func (t *WhisperCPPTranscriber) Name() string {
+	return "whisper.cpp:" + filepath.Base(t.ModelPath)
+}
+
+// Transcribe implements Transcriber.
+func (t *WhisperCPPTranscriber) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	dir, err := os.MkdirTemp("", "shelley-transcribe-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "input"+filepath.Ext(filename))
+	wav := filepath.Join(dir, "speech.wav")
+	if err := os.WriteFile(in, audio, 0o600); err != nil {
+		return "", err
+	}
+
+	ffmpeg := exec.CommandContext(ctx, "ffmpeg", "-nostdin", "-loglevel", "error", "-i", in, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", wav)
+	if out, err := ffmpeg.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("converting audio failed: %w: %s", err, out)
+	}
+
+	args := []string{"-m", t.ModelPath, "-f", wav, "--no-timestamps", "--no-prints"}
+	if t.Language != "" {
+		args = append(args, "-l", t.Language)
+	}
+	var stderr bytes.Buffer
+	whisper := exec.CommandContext(ctx, t.Command, args...)
+	whisper.Stderr = &stderr
+	out, err := whisper.Output()
+	if err != nil {
+		return "", fmt.Errorf("whisper.cpp failed: %w: %s", err, stderr.Bytes())
+	}
+	return strings.Join(strings.Fields(string(out)), " "), nil
+}
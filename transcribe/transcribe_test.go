@@ -0,0 +1,72 @@
+package transcribe
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Error("New without a provider succeeded")
+	}
+	tr, err := New(Config{APIKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Name() != "openai:whisper-1" {
+		t.Errorf("default transcriber = %s, want openai:whisper-1", tr.Name())
+	}
+	if _, err := New(Config{Provider: "openai"}); err == nil {
+		t.Error("openai without an API key succeeded")
+	}
+	if _, err := New(Config{Provider: "whisper.cpp"}); err == nil {
+		t.Error("whisper.cpp without a model succeeded")
+	}
+	if _, err := New(Config{Provider: "whisper.cpp", ModelPath: "/m.bin", Command: "/nonexistent/whisper-cli"}); err == nil {
+		t.Error("whisper.cpp with a missing command succeeded")
+	}
+	if _, err := New(Config{Provider: "nope"}); err == nil {
+		t.Error("unknown provider succeeded")
+	}
+}
+
+func TestOpenAITranscriber(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		f, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audio, _ := io.ReadAll(f)
+		if string(audio) != "AUDIO" || header.Filename != "speech.webm" || r.FormValue("model") != "whisper-1" || r.FormValue("language") != "en" {
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"text": " Run the tests, please. "}`))
+	}))
+	defer srv.Close()
+
+	tr, err := New(Config{BaseURL: srv.URL + "/v1/", APIKey: "key", Language: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := tr.Transcribe(context.Background(), []byte("AUDIO"), "speech.webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Run the tests, please." {
+		t.Errorf("Transcribe = %q", text)
+	}
+
+	tr, _ = New(Config{BaseURL: srv.URL + "/v1", APIKey: "wrong"})
+	if _, err := tr.Transcribe(context.Background(), []byte("AUDIO"), "speech.webm"); err == nil {
+		t.Error("Transcribe with a rejected key succeeded")
+	}
+}
@@ -102,34 +102,44 @@ async function build() {
     };
     fs.writeFileSync('dist/build-info.json', JSON.stringify(buildInfo, null, 2));
 
-    // Generate gzip versions of large files and remove originals to reduce binary size
-    // The server will decompress on-the-fly for the rare clients that don't support gzip
-    log('\nGenerating gzip compressed files...');
+    // Generate gzip and brotli versions of large files and remove originals to reduce binary size
+    // The server serves brotli or gzip as the client accepts, and decompresses the gzip
+    // version on-the-fly for the rare clients that support neither
+    log('\nGenerating compressed files...');
     const filesToCompress = ['monaco-editor.js', 'editor.worker.js', 'main.js', 'monaco-editor.css', 'styles.css', 'main.css'];
     const checksums = {};
     let totalOrigSize = 0;
     let totalGzSize = 0;
+    let totalBrSize = 0;
 
     for (const file of filesToCompress) {
       const inputPath = `dist/${file}`;
-      const outputPath = `dist/${file}.gz`;
       if (fs.existsSync(inputPath)) {
         const input = fs.readFileSync(inputPath);
         const compressed = zlib.gzipSync(input, { level: 9 });
-        fs.writeFileSync(outputPath, compressed);
-
-        // Compute SHA256 of the compressed content for ETag
+        fs.writeFileSync(`${inputPath}.gz`, compressed);
+        const brotli = zlib.brotliCompressSync(input, {
+          params: {
+            [zlib.constants.BROTLI_PARAM_QUALITY]: zlib.constants.BROTLI_MAX_QUALITY,
+            [zlib.constants.BROTLI_PARAM_SIZE_HINT]: input.length,
+          },
+        });
+        fs.writeFileSync(`${inputPath}.br`, brotli);
+
+        // Compute SHA256 of the compressed content for ETags and versioned URLs
         const hash = crypto.createHash('sha256').update(compressed).digest('hex').slice(0, 16);
         checksums[file] = hash;
 
         totalOrigSize += input.length;
         totalGzSize += compressed.length;
+        totalBrSize += brotli.length;
 
         if (verbose) {
           const origKb = (input.length / 1024).toFixed(1);
           const gzKb = (compressed.length / 1024).toFixed(1);
-          const ratio = ((compressed.length / input.length) * 100).toFixed(0);
-          console.log(`  ${file}: ${origKb} KB -> ${gzKb} KB gzip (${ratio}%) [${hash}]`);
+          const brKb = (brotli.length / 1024).toFixed(1);
+          const ratio = ((brotli.length / input.length) * 100).toFixed(0);
+          console.log(`  ${file}: ${origKb} KB -> ${gzKb} KB gzip, ${brKb} KB brotli (${ratio}%) [${hash}]`);
         }
 
         // Remove original to save space in embedded binary
@@ -144,7 +154,7 @@ async function build() {
     if (verbose) {
       console.log('\nOther files:');
       const otherFiles = fs.readdirSync('dist').filter(f =>
-        (f.endsWith('.ttf') || f.endsWith('.map')) && !f.endsWith('.gz')
+        (f.endsWith('.ttf') || f.endsWith('.map')) && !f.endsWith('.gz') && !f.endsWith('.br')
       );
       for (const file of otherFiles.sort()) {
         const stats = fs.statSync(`dist/${file}`);
@@ -155,7 +165,8 @@ async function build() {
 
     const elapsed = ((Date.now() - startTime) / 1000).toFixed(1);
     const totalGzKb = (totalGzSize / 1024).toFixed(0);
-    console.log(`UI built in ${elapsed}s (${totalGzKb} KB gzipped)`);
+    const totalBrKb = (totalBrSize / 1024).toFixed(0);
+    console.log(`UI built in ${elapsed}s (${totalGzKb} KB gzipped, ${totalBrKb} KB brotli)`);
   } catch (error) {
     console.error('Build failed:', error);
     process.exit(1);
@@ -5,6 +5,7 @@ import CommandPalette from "./components/CommandPalette";
 import ModelsModal from "./components/ModelsModal";
 import { Conversation, ConversationWithState, ConversationListUpdate } from "./types";
 import { api } from "./services/api";
+import { basePath, withBasePath } from "./services/basePath";
 
 // Check if a slug is a generated ID (format: cXXXX where X is alphanumeric)
 function isGeneratedId(slug: string | null): boolean {
@@ -14,7 +15,7 @@ function isGeneratedId(slug: string | null): boolean {
 
 // Get slug from the current URL path (expects /c/<slug> format)
 function getSlugFromPath(): string | null {
-  const path = window.location.pathname;
+  const path = window.location.pathname.slice(basePath.length);
   // Check for /c/<slug> format
   if (path.startsWith("/c/")) {
     const slug = path.slice(3); // Remove "/c/" prefix
@@ -37,9 +38,9 @@ function updateUrlWithSlug(conversation: Conversation | undefined) {
 
   if (currentSlug !== newSlug) {
     if (newSlug) {
-      window.history.replaceState({}, "", `/c/${newSlug}`);
+      window.history.replaceState({}, "", withBasePath(`/c/${newSlug}`));
     } else {
-      window.history.replaceState({}, "", "/");
+      window.history.replaceState({}, "", withBasePath("/"));
     }
   }
 }
@@ -102,7 +103,7 @@ function App() {
       }
 
       // Slug not found, clear the URL
-      window.history.replaceState({}, "", "/");
+      window.history.replaceState({}, "", withBasePath("/"));
       return null;
     },
     [],
@@ -263,7 +264,7 @@ function App() {
     setCurrentConversationId(null);
     setViewedConversation(null);
     // Clear URL when starting new conversation
-    window.history.replaceState({}, "", "/");
+    window.history.replaceState({}, "", withBasePath("/"));
     setDrawerOpen(false);
   };
 
@@ -352,7 +353,16 @@ function App() {
 
   const handleFirstMessage = async (message: string, model: string, cwd?: string) => {
     try {
-      const response = await api.sendMessageWithNewConversation({ message, model, cwd });
+      // The agent is told the user's local time and writes dates their way
+      const response = await api.sendMessageWithNewConversation({
+        message,
+        model,
+        cwd,
+        settings: {
+          timezone: Intl.DateTimeFormat().resolvedOptions().timeZone,
+          locale: navigator.language,
+        },
+      });
       const newConversationId = response.conversation_id;
 
       // Fetch the new conversation details
@@ -4,6 +4,8 @@ import { LLMContent } from "../types";
 // Display data from the bash tool backend
 interface BashDisplayData {
   workingDir: string;
+  // Colored output, converted to HTML by the server
+  outputHtml?: string;
 }
 
 interface BashToolProps {
@@ -128,9 +130,16 @@ function BashTool({
                 Output{hasError ? " (Error)" : ""}:
                 {executionTime && <span className="bash-tool-time">{executionTime}</span>}
               </div>
-              <pre className={`bash-tool-code ${hasError ? "error" : ""}`}>
-                {output || "(no output)"}
-              </pre>
+              {displayData?.outputHtml ? (
+                <pre
+                  className={`bash-tool-code ${hasError ? "error" : ""}`}
+                  dangerouslySetInnerHTML={{ __html: displayData.outputHtml }}
+                />
+              ) : (
+                <pre className={`bash-tool-code ${hasError ? "error" : ""}`}>
+                  {output || "(no output)"}
+                </pre>
+              )}
             </div>
           )}
         </div>
@@ -5,10 +5,20 @@ import {
   StreamResponse,
   LLMContent,
   ConversationListUpdate,
+  Todo,
+  MessageAnnotation,
+  ReviewComment,
+  ConversationEvent,
 } from "../types";
 import { api } from "../services/api";
 import { ThemeMode, getStoredTheme, setStoredTheme, applyTheme } from "../services/theme";
 import { setFaviconStatus } from "../services/favicon";
+import {
+  isPushSupported,
+  isPushSubscribed,
+  subscribePush,
+  unsubscribePush,
+} from "../services/push";
 import MessageComponent from "./Message";
 import MessageInput from "./MessageInput";
 import DiffViewer from "./DiffViewer";
@@ -25,16 +35,40 @@ import ChangeDirTool from "./ChangeDirTool";
 import BrowserResizeTool from "./BrowserResizeTool";
 import SubagentTool from "./SubagentTool";
 import OutputIframeTool from "./OutputIframeTool";
+import RunTestsTool from "./RunTestsTool";
+import PlanTool from "./PlanTool";
+import TodoTool, { TodoList } from "./TodoTool";
 import DirectoryPickerModal from "./DirectoryPickerModal";
+import TimelineModal from "./TimelineModal";
 import { useVersionChecker } from "./VersionChecker";
 import TerminalWidget from "./TerminalWidget";
 import ModelPicker from "./ModelPicker";
 
+// LocalStorage key for showing the model's thinking blocks
+const STORAGE_KEY_SHOW_REASONING = "shelley-show-reasoning";
+
+function getShowReasoningPreference(): boolean {
+  try {
+    return localStorage.getItem(STORAGE_KEY_SHOW_REASONING) === "true";
+  } catch {
+    return false;
+  }
+}
+
+function setShowReasoningPreference(value: boolean): void {
+  try {
+    localStorage.setItem(STORAGE_KEY_SHOW_REASONING, value ? "true" : "false");
+  } catch {
+    // Ignore storage errors
+  }
+}
+
 // Ephemeral terminal instance (not persisted to database)
 interface EphemeralTerminal {
   id: string;
   command: string;
   cwd: string;
+  conversationId?: string; // set for an interactive shell in the conversation's environment
   createdAt: Date;
 }
 
@@ -237,6 +271,9 @@ const TOOL_COMPONENTS: Record<string, React.ComponentType<any>> = {
   browser_resize: BrowserResizeTool,
   subagent: SubagentTool,
   output_iframe: OutputIframeTool,
+  run_tests: RunTestsTool,
+  submit_plan: PlanTool,
+  todo: TodoTool,
 };
 
 function CoalescedToolCall({
@@ -442,6 +479,7 @@ interface ConversationStateUpdate {
   conversation_id: string;
   working: boolean;
   model?: string;
+  queue_position?: number;
 }
 
 interface ChatInterfaceProps {
@@ -586,6 +624,8 @@ function ChatInterface({
   );
   const [diffCommentText, setDiffCommentText] = useState("");
   const [agentWorking, setAgentWorking] = useState(false);
+  // Position of the next turn among those waiting for the server's scheduler
+  const [queuePosition, setQueuePosition] = useState(0);
   const [cancelling, setCancelling] = useState(false);
   const [contextWindowSize, setContextWindowSize] = useState(0);
   const terminalURL = window.__SHELLEY_INIT__?.terminal_url || null;
@@ -598,6 +638,16 @@ function ChatInterface({
   // Ephemeral terminals are local-only and not persisted to the database
   const [ephemeralTerminals, setEphemeralTerminals] = useState<EphemeralTerminal[]>([]);
   const [terminalInjectedText, setTerminalInjectedText] = useState<string | null>(null);
+  const [todos, setTodos] = useState<Todo[]>([]);
+  const [annotations, setAnnotations] = useState<Record<string, MessageAnnotation>>({});
+  const [comments, setComments] = useState<ReviewComment[]>([]);
+  const [showTodos, setShowTodos] = useState(true);
+  const [showReasoning, setShowReasoning] = useState(getShowReasoningPreference);
+  const [pushSubscribed, setPushSubscribed] = useState(false);
+  const [showTimeline, setShowTimeline] = useState(false);
+  const [timelineEvents, setTimelineEvents] = useState<ConversationEvent[]>([]);
+  // Users viewing this conversation, on servers that identify users
+  const [viewers, setViewers] = useState<string[]>([]);
   const messagesEndRef = useRef<HTMLDivElement>(null);
   const messagesContainerRef = useRef<HTMLDivElement>(null);
   const eventSourceRef = useRef<EventSource | null>(null);
@@ -610,6 +660,8 @@ function ChatInterface({
   useEffect(() => {
     // Clear ephemeral terminals when conversation changes
     setEphemeralTerminals([]);
+    setTimelineEvents([]);
+    setViewers([]);
 
     if (conversationId) {
       setAgentWorking(false);
@@ -633,7 +685,14 @@ function ChatInterface({
         clearInterval(periodicRetryRef.current);
       }
     };
-  }, [conversationId]);
+  }, [conversationId, showReasoning]);
+
+  // Check whether this browser is subscribed to push notifications
+  useEffect(() => {
+    isPushSubscribed()
+      .then(setPushSubscribed)
+      .catch(() => setPushSubscribed(false));
+  }, []);
 
   // Update favicon when agent working state changes
   useEffect(() => {
@@ -714,7 +773,7 @@ function ChatInterface({
     try {
       setLoading(true);
       setError(null);
-      const response = await api.getConversation(conversationId);
+      const response = await api.getConversation(conversationId, showReasoning);
       setMessages(response.messages ?? []);
       // ConversationState is sent via the streaming endpoint, not on initial load
       // We don't update agentWorking here - the stream will provide the current state
@@ -740,7 +799,7 @@ function ChatInterface({
       eventSourceRef.current.close();
     }
 
-    const eventSource = api.createMessageStream(conversationId);
+    const eventSource = api.createMessageStream(conversationId, showReasoning);
     eventSourceRef.current = eventSource;
 
     eventSource.onmessage = (event) => {
@@ -772,6 +831,15 @@ function ChatInterface({
           onConversationUpdate(streamResponse.conversation);
         }
 
+        if (streamResponse.events && streamResponse.events.length > 0) {
+          const events = streamResponse.events;
+          setTimelineEvents((prev) => [...prev, ...events]);
+        }
+
+        if (streamResponse.presence) {
+          setViewers(streamResponse.presence.users);
+        }
+
         // Handle conversation list updates (for other conversations)
         if (onConversationListUpdate && streamResponse.conversation_list_update) {
           onConversationListUpdate(streamResponse.conversation_list_update);
@@ -786,6 +854,7 @@ function ChatInterface({
           // Update local state if this is for our conversation
           if (streamResponse.conversation_state.conversation_id === conversationId) {
             setAgentWorking(streamResponse.conversation_state.working);
+            setQueuePosition(streamResponse.conversation_state.queue_position || 0);
             // Update selected model from conversation (ensures consistency across sessions)
             if (streamResponse.conversation_state.model) {
               setSelectedModel(streamResponse.conversation_state.model);
@@ -944,6 +1013,99 @@ function ChatInterface({
     };
   }, [isDisconnected, conversationId]);
 
+  // Refresh the agent's task list as messages arrive
+  useEffect(() => {
+    if (!conversationId) {
+      setTodos([]);
+      return;
+    }
+    api
+      .getTodos(conversationId)
+      .then((resp) => setTodos(resp.todos))
+      .catch((err) => console.error("Failed to load todos:", err));
+  }, [conversationId, messages.length]);
+
+  // Keep the read marker at the latest message while the conversation is open
+  useEffect(() => {
+    if (!conversationId || messages.length === 0) {
+      return;
+    }
+    const timer = setTimeout(() => {
+      api
+        .markConversationRead(conversationId)
+        .catch((err) => console.error("Failed to mark conversation read:", err));
+    }, 2000);
+    return () => clearTimeout(timer);
+  }, [conversationId, messages.length]);
+
+  // Load the user's message annotations when switching conversations
+  useEffect(() => {
+    setAnnotations({});
+    if (!conversationId) {
+      return;
+    }
+    api
+      .getAnnotations(conversationId)
+      .then((list) => setAnnotations(Object.fromEntries(list.map((a) => [a.message_id, a]))))
+      .catch((err) => console.error("Failed to load annotations:", err));
+  }, [conversationId]);
+
+  const handleAnnotate = async (
+    messageId: string,
+    annotation: { rating?: "up" | "down"; flagged?: boolean; note?: string },
+  ) => {
+    if (!conversationId) return;
+    try {
+      const saved = await api.annotateMessage(conversationId, messageId, annotation);
+      setAnnotations((prev) => {
+        const next = { ...prev };
+        if (saved) {
+          next[messageId] = saved;
+        } else {
+          delete next[messageId];
+        }
+        return next;
+      });
+    } catch (err) {
+      console.error("Failed to annotate message:", err);
+    }
+  };
+
+  // Load the review comments when switching conversations
+  useEffect(() => {
+    setComments([]);
+    if (!conversationId) {
+      return;
+    }
+    api
+      .getComments(conversationId)
+      .then(setComments)
+      .catch((err) => console.error("Failed to load comments:", err));
+  }, [conversationId]);
+
+  const handleComment = async (messageId: string, body: string, parentId?: number) => {
+    if (!conversationId) return;
+    try {
+      const comment = await api.createComment(
+        conversationId,
+        parentId ? { parent_id: parentId, body } : { message_id: messageId, body },
+      );
+      setComments((prev) => [...prev, comment]);
+    } catch (err) {
+      console.error("Failed to add comment:", err);
+    }
+  };
+
+  const handleResolveComment = async (commentId: number, resolved: boolean) => {
+    if (!conversationId) return;
+    try {
+      const updated = await api.updateComment(conversationId, commentId, { resolved });
+      setComments((prev) => prev.map((c) => (c.id === commentId ? updated : c)));
+    } catch (err) {
+      console.error("Failed to update comment:", err);
+    }
+  };
+
   // Handle external trigger to open diff viewer
   useEffect(() => {
     if (openDiffViewerTrigger && openDiffViewerTrigger > 0) {
@@ -966,6 +1128,17 @@ function ChatInterface({
     }
   };
 
+  const handleResume = async (guidance: string) => {
+    if (!conversationId) return;
+    try {
+      await api.resumeConversation(conversationId, guidance || undefined);
+      setAgentWorking(true);
+    } catch (err) {
+      console.error("Failed to resume conversation:", err);
+      setError("Failed to resume. Please try again.");
+    }
+  };
+
   // Handler to continue conversation in a new one
   const handleContinueConversation = async () => {
     if (!conversationId || !onContinueConversation) return;
@@ -1113,11 +1286,15 @@ function ChatInterface({
             // Extract text content and tool uses separately
             const textContents: LLMContent[] = [];
             const toolUses: LLMContent[] = [];
+            let hasImage = false;
 
             llmData.Content.forEach((content: LLMContent) => {
               if (content.Type === 2) {
                 // text
                 textContents.push(content);
+              } else if (content.Type === 16) {
+                // generated image
+                hasImage = true;
               } else if (content.Type === 5) {
                 // tool_use
                 toolUses.push(content);
@@ -1129,7 +1306,7 @@ function ChatInterface({
               .map((c) => c.Text || "")
               .join("")
               .trim();
-            if (textString) {
+            if (textString || hasImage) {
               coalescedItems.push({ type: "message", message });
             }
 
@@ -1171,6 +1348,7 @@ function ChatInterface({
         key={terminal.id}
         command={terminal.command}
         cwd={terminal.cwd}
+        conversationId={terminal.conversationId}
         onInsertIntoInput={handleInsertFromTerminal}
         onClose={() => setEphemeralTerminals((prev) => prev.filter((t) => t.id !== terminal.id))}
       />
@@ -1230,6 +1408,12 @@ function ChatInterface({
               setShowDiffViewer(true);
             }}
             onCommentTextChange={setDiffCommentText}
+            annotation={annotations[item.message.message_id]}
+            onAnnotate={(annotation) => handleAnnotate(item.message.message_id, annotation)}
+            comments={comments.filter((c) => c.message_id === item.message.message_id)}
+            onComment={(body, parentId) => handleComment(item.message.message_id, body, parentId)}
+            onResolveComment={handleResolveComment}
+            onResume={index === coalescedItems.length - 1 ? handleResume : undefined}
           />
         );
       } else if (item.type === "tool") {
@@ -1300,6 +1484,15 @@ function ChatInterface({
         </div>
 
         <div className="header-actions">
+          {viewers.length > 1 && (
+            <div className="presence" title={`Viewing: ${viewers.join(", ")}`}>
+              {viewers.map((user) => (
+                <span key={user} className="presence-avatar">
+                  {user.charAt(0).toUpperCase()}
+                </span>
+              ))}
+            </div>
+          )}
           {/* Green + icon in circle for new conversation */}
           <button onClick={onNewConversation} className="btn-new" aria-label="New conversation">
             <svg
@@ -1362,13 +1555,29 @@ function ChatInterface({
                     Diffs
                   </button>
                 )}
-                {terminalURL && (
+                {(terminalURL || conversationId) && (
                   <button
                     onClick={() => {
                       setShowOverflowMenu(false);
                       const cwd = currentConversation?.cwd || selectedCwd || "";
-                      const url = terminalURL.replace("WORKING_DIR", encodeURIComponent(cwd));
-                      window.open(url, "_blank");
+                      if (!conversationId && terminalURL) {
+                        // No conversation environment yet
+                        const url = terminalURL.replace("WORKING_DIR", encodeURIComponent(cwd));
+                        window.open(url, "_blank");
+                        return;
+                      }
+                      if (!conversationId) return;
+                      setEphemeralTerminals((prev) => [
+                        ...prev,
+                        {
+                          id: `term-${Date.now()}-${Math.random().toString(36).slice(2, 9)}`,
+                          command: "shell",
+                          cwd,
+                          conversationId,
+                          createdAt: new Date(),
+                        },
+                      ]);
+                      setTimeout(() => scrollToBottom(), 100);
                     }}
                     className="overflow-menu-item"
                   >
@@ -1417,6 +1626,91 @@ function ChatInterface({
                   </button>
                 ))}
 
+                <button
+                  onClick={() => {
+                    setShowOverflowMenu(false);
+                    setShowReasoningPreference(!showReasoning);
+                    setShowReasoning(!showReasoning);
+                  }}
+                  className="overflow-menu-item"
+                >
+                  <svg
+                    fill="none"
+                    stroke="currentColor"
+                    viewBox="0 0 24 24"
+                    style={{ width: "1.25rem", height: "1.25rem", marginRight: "0.75rem" }}
+                  >
+                    <path
+                      strokeLinecap="round"
+                      strokeLinejoin="round"
+                      strokeWidth={2}
+                      d="M9.663 17h4.673M12 3v1m6.364 1.636l-.707.707M21 12h-1M4 12H3m3.343-5.657l-.707-.707m2.828 9.9a5 5 0 117.072 0l-.548.547A3.374 3.374 0 0014 18.469V19a2 2 0 11-4 0v-.531c0-.895-.356-1.754-.988-2.386l-.548-.547z"
+                    />
+                  </svg>
+                  {showReasoning ? "Hide reasoning" : "Show reasoning"}
+                </button>
+
+                {conversationId && (
+                  <button
+                    onClick={() => {
+                      setShowOverflowMenu(false);
+                      setShowTimeline(true);
+                    }}
+                    className="overflow-menu-item"
+                  >
+                    <svg
+                      fill="none"
+                      stroke="currentColor"
+                      viewBox="0 0 24 24"
+                      style={{ width: "1.25rem", height: "1.25rem", marginRight: "0.75rem" }}
+                    >
+                      <path
+                        strokeLinecap="round"
+                        strokeLinejoin="round"
+                        strokeWidth={2}
+                        d="M12 8v4l3 3m6-3a9 9 0 11-18 0 9 9 0 0118 0z"
+                      />
+                    </svg>
+                    Timeline
+                  </button>
+                )}
+
+                {isPushSupported() && (
+                  <button
+                    onClick={async () => {
+                      setShowOverflowMenu(false);
+                      try {
+                        if (pushSubscribed) {
+                          await unsubscribePush();
+                          setPushSubscribed(false);
+                        } else {
+                          await subscribePush();
+                          setPushSubscribed(true);
+                        }
+                      } catch (err) {
+                        console.error("Failed to update notifications:", err);
+                        setError(err instanceof Error ? err.message : "Failed to update notifications");
+                      }
+                    }}
+                    className="overflow-menu-item"
+                  >
+                    <svg
+                      fill="none"
+                      stroke="currentColor"
+                      viewBox="0 0 24 24"
+                      style={{ width: "1.25rem", height: "1.25rem", marginRight: "0.75rem" }}
+                    >
+                      <path
+                        strokeLinecap="round"
+                        strokeLinejoin="round"
+                        strokeWidth={2}
+                        d="M15 17h5l-1.405-1.405A2.032 2.032 0 0118 14.158V11a6.002 6.002 0 00-4-5.659V5a2 2 0 10-4 0v.341C7.67 6.165 6 8.388 6 11v3.159c0 .538-.214 1.055-.595 1.436L4 17h5m6 0v1a3 3 0 11-6 0v-1m6 0H9"
+                      />
+                    </svg>
+                    {pushSubscribed ? "Disable notifications" : "Enable notifications"}
+                  </button>
+                )}
+
                 {/* Version check */}
                 <div className="overflow-menu-divider" />
                 <button
@@ -1548,6 +1842,19 @@ function ChatInterface({
         )}
       </div>
 
+      {/* Todo panel - shows the agent's progress until every item is done */}
+      {todos.some((todo) => todo.status !== "completed") && (
+        <div className="todo-panel">
+          <button className="todo-panel-header" onClick={() => setShowTodos(!showTodos)}>
+            ☑️ Tasks {todos.filter((todo) => todo.status === "completed").length}/{todos.length}
+            <span className="todo-panel-current">
+              {todos.find((todo) => todo.status === "in_progress")?.content}
+            </span>
+          </button>
+          {showTodos && <TodoList todos={todos} />}
+        </div>
+      )}
+
       {/* Unified Status Bar */}
       <div className="status-bar">
         <div className="status-bar-content">
@@ -1581,7 +1888,13 @@ function ChatInterface({
             // Agent working - show status with stop button and context bar
             <div className="status-bar-active">
               <div className="status-working-group">
-                <AnimatedWorkingStatus />
+                {queuePosition > 0 ? (
+                  <span className="status-message" title="Other conversations are running turns">
+                    Queued (#{queuePosition})
+                  </span>
+                ) : (
+                  <AnimatedWorkingStatus />
+                )}
                 <button
                   onClick={handleCancel}
                   disabled={cancelling}
@@ -1697,6 +2010,15 @@ function ChatInterface({
 
       {/* Version Checker Modal */}
       {VersionModal}
+
+      {conversationId && (
+        <TimelineModal
+          isOpen={showTimeline}
+          onClose={() => setShowTimeline(false)}
+          conversationId={conversationId}
+          liveEvents={timelineEvents}
+        />
+      )}
     </div>
   );
 }
@@ -19,6 +19,24 @@ interface ConversationDrawerProps {
   subagentStateUpdate?: { conversation_id: string; working: boolean } | null; // When a subagent's working state changes
 }
 
+// unreadTooltip lists the files changed since the user last read a conversation
+function unreadTooltip(conversation: ConversationWithState): string {
+  const count = conversation.unread_count ?? 0;
+  const text = `${count} new message${count === 1 ? "" : "s"} since your last visit`;
+  const files = conversation.changed_files ?? [];
+  return files.length > 0 ? `${text}\n\nChanged: ${files.join(", ")}` : text;
+}
+
+// summaryTooltip describes a conversation from the summary generated when it went idle
+function summaryTooltip(conversation: ConversationWithState): string | undefined {
+  const summary = conversation.summary;
+  if (!summary) {
+    return undefined;
+  }
+  const files = summary.files ?? [];
+  return files.length > 0 ? `${summary.summary}\n\nFiles: ${files.join(", ")}` : summary.summary;
+}
+
 function ConversationDrawer({
   isOpen,
   isCollapsed,
@@ -407,7 +425,10 @@ function ConversationDrawer({
                                 }}
                               />
                             ) : (
-                              <div className="conversation-title">
+                              <div
+                                className="conversation-title"
+                                title={summaryTooltip(conversation as ConversationWithState)}
+                              >
                                 {getConversationPreview(conversation)}
                               </div>
                             )}
@@ -426,6 +447,22 @@ function ConversationDrawer({
                               }}
                             />
                           )}
+                          {((conversation as ConversationWithState).unread_count ?? 0) > 0 && (
+                            <span
+                              className="unread-badge"
+                              title={unreadTooltip(conversation as ConversationWithState)}
+                              style={{
+                                fontSize: "0.7rem",
+                                padding: "0 0.35rem",
+                                borderRadius: "0.5rem",
+                                backgroundColor: "var(--primary)",
+                                color: "white",
+                                flexShrink: 0,
+                              }}
+                            >
+                              {(conversation as ConversationWithState).unread_count}
+                            </span>
+                          )}
                         </div>
                         <div className="conversation-meta">
                           <span className="conversation-date">
@@ -1,5 +1,6 @@
 import React, { useState, useEffect, useRef, useCallback, useId } from "react";
 import { api } from "../services/api";
+import { RecentDirectory } from "../types";
 
 interface DirectoryEntry {
   name: string;
@@ -154,6 +155,78 @@ function DirectoryPickerModal({
     }
   }, [isOpen, initialPath]);
 
+  // Cloning a repository into a new workspace
+  const [isCloning, setIsCloning] = useState(false);
+  const [cloneURL, setCloneURL] = useState("");
+  const [cloneProgress, setCloneProgress] = useState<string | null>(null);
+  const [cloneError, setCloneError] = useState<string | null>(null);
+  const [cloneLoading, setCloneLoading] = useState(false);
+
+  const handleClone = async () => {
+    if (!cloneURL.trim()) {
+      setCloneError("Repository URL is required");
+      return;
+    }
+    setCloneLoading(true);
+    setCloneError(null);
+    setCloneProgress(null);
+    try {
+      const path = await api.cloneRepository(cloneURL.trim(), "", setCloneProgress);
+      setIsCloning(false);
+      setCloneURL("");
+      onSelect(path);
+      onClose();
+    } catch (err) {
+      setCloneError(err instanceof Error ? err.message : "Failed to clone repository");
+    } finally {
+      setCloneLoading(false);
+      setCloneProgress(null);
+    }
+  };
+
+  const handleCloneKeyDown = (e: React.KeyboardEvent<HTMLInputElement>) => {
+    if (e.nativeEvent.isComposing) return;
+    if (e.key === "Enter") {
+      e.preventDefault();
+      handleClone();
+    } else if (e.key === "Escape" && !cloneLoading) {
+      e.preventDefault();
+      setIsCloning(false);
+      setCloneError(null);
+    }
+  };
+
+  // Recent and favorite directories, shown above the listing
+  const [recentDirs, setRecentDirs] = useState<RecentDirectory[]>([]);
+  const [favoriteDirs, setFavoriteDirs] = useState<RecentDirectory[]>([]);
+
+  useEffect(() => {
+    if (!isOpen) return;
+    api
+      .getRecentDirectories()
+      .then((result) => {
+        setRecentDirs(result.recent || []);
+        setFavoriteDirs(result.favorites || []);
+      })
+      .catch((err) => console.error("Failed to load recent directories:", err));
+  }, [isOpen]);
+
+  const handleToggleFavorite = async (dir: RecentDirectory) => {
+    try {
+      const result = await api.setFavoriteDirectory(dir.path, !dir.favorite);
+      setRecentDirs(result.recent || []);
+      setFavoriteDirs(result.favorites || []);
+    } catch (err) {
+      console.error("Failed to update favorite directory:", err);
+    }
+  };
+
+  // Favorites first, then recent directories that aren't favorites
+  const quickDirs = [
+    ...favoriteDirs,
+    ...recentDirs.filter((dir) => !favoriteDirs.some((fav) => fav.path === dir.path)),
+  ];
+
   // Focus input when modal opens (but not on mobile to avoid keyboard popup)
   useEffect(() => {
     if (isOpen && inputRef.current) {
@@ -322,6 +395,71 @@ function DirectoryPickerModal({
             />
           </div>
 
+          {/* Clone a repository */}
+          {isCloning && (
+            <div className="directory-picker-clone">
+              <div className="directory-picker-clone-form">
+                <input
+                  type="text"
+                  value={cloneURL}
+                  onChange={(e) => setCloneURL(e.target.value)}
+                  onKeyDown={handleCloneKeyDown}
+                  placeholder="https://github.com/org/repo.git"
+                  className="directory-picker-input"
+                  aria-label="Repository URL"
+                  disabled={cloneLoading}
+                  autoFocus
+                />
+                <button
+                  className="btn-primary"
+                  onClick={handleClone}
+                  disabled={cloneLoading || !cloneURL.trim()}
+                >
+                  {cloneLoading ? <div className="spinner spinner-small"></div> : "Clone"}
+                </button>
+              </div>
+              {cloneProgress && <div className="directory-picker-clone-progress">{cloneProgress}</div>}
+              {cloneError && <div className="directory-picker-create-error">{cloneError}</div>}
+            </div>
+          )}
+
+          {/* Recent and favorite directories */}
+          {quickDirs.length > 0 && (
+            <div className="directory-picker-quick">
+              {quickDirs.map((dir) => (
+                <div key={dir.path} className="directory-picker-quick-item">
+                  <button
+                    className="directory-picker-quick-path"
+                    onClick={() => setInputPath(dir.path === "/" ? "/" : `${dir.path}/`)}
+                    disabled={!dir.exists}
+                    title={dir.exists ? dir.path : `${dir.path} no longer exists`}
+                  >
+                    {dir.path}
+                  </button>
+                  <button
+                    className={`directory-picker-favorite-btn${dir.favorite ? " active" : ""}`}
+                    onClick={() => handleToggleFavorite(dir)}
+                    aria-label={dir.favorite ? "Remove from favorites" : "Add to favorites"}
+                    title={dir.favorite ? "Remove from favorites" : "Add to favorites"}
+                  >
+                    <svg
+                      fill={dir.favorite ? "currentColor" : "none"}
+                      stroke="currentColor"
+                      viewBox="0 0 24 24"
+                    >
+                      <path
+                        strokeLinecap="round"
+                        strokeLinejoin="round"
+                        strokeWidth={2}
+                        d="M11.48 3.5a.56.56 0 011.04 0l2.12 5.11a.56.56 0 00.48.35l5.52.44c.5.04.7.66.32.99l-4.2 3.6a.56.56 0 00-.18.56l1.28 5.39a.56.56 0 01-.84.61l-4.72-2.88a.56.56 0 00-.59 0l-4.72 2.88a.56.56 0 01-.84-.61l1.28-5.39a.56.56 0 00-.18-.56l-4.2-3.6a.56.56 0 01.32-.99l5.52-.44a.56.56 0 00.48-.35z"
+                      />
+                    </svg>
+                  </button>
+                </div>
+              ))}
+            </div>
+          )}
+
           {/* Current directory indicator */}
           {displayDir && (
             <div
@@ -523,6 +661,15 @@ function DirectoryPickerModal({
               New Folder
             </button>
           )}
+          {!isCloning && (
+            <button
+              className="btn directory-picker-new-btn"
+              onClick={() => setIsCloning(true)}
+              title="Clone a git repository into a new workspace"
+            >
+              Clone Repository
+            </button>
+          )}
           <div className="directory-picker-footer-spacer"></div>
           <button className="btn" onClick={onClose}>
             Cancel
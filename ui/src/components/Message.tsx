@@ -1,6 +1,14 @@
 import React, { useState, useRef, useEffect } from "react";
 import { linkifyText } from "../utils/linkify";
-import { Message as MessageType, LLMMessage, LLMContent, Usage } from "../types";
+import { withBasePath } from "../services/basePath";
+import {
+  Message as MessageType,
+  LLMMessage,
+  LLMContent,
+  Usage,
+  MessageAnnotation,
+  ReviewComment,
+} from "../types";
 import BashTool from "./BashTool";
 import PatchTool from "./PatchTool";
 import ScreenshotTool from "./ScreenshotTool";
@@ -15,6 +23,9 @@ import ChangeDirTool from "./ChangeDirTool";
 import BrowserResizeTool from "./BrowserResizeTool";
 import SubagentTool from "./SubagentTool";
 import OutputIframeTool from "./OutputIframeTool";
+import RunTestsTool from "./RunTestsTool";
+import PlanTool from "./PlanTool";
+import TodoTool from "./TodoTool";
 import UsageDetailModal from "./UsageDetailModal";
 import MessageActionBar from "./MessageActionBar";
 
@@ -29,9 +40,58 @@ interface MessageProps {
   message: MessageType;
   onOpenDiffViewer?: (commit: string) => void;
   onCommentTextChange?: (text: string) => void;
+  annotation?: MessageAnnotation;
+  onAnnotate?: (annotation: { rating?: "up" | "down"; flagged?: boolean; note?: string }) => void;
+  // Review comments on the message, which never reach the LLM
+  comments?: ReviewComment[];
+  onComment?: (body: string, parentId?: number) => void;
+  onResolveComment?: (commentId: number, resolved: boolean) => void;
+  // Resumes a turn the agent loop paused as stuck; passed for the latest message
+  onResume?: (guidance: string) => Promise<void>;
 }
 
-function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProps) {
+// StuckResume lets the user resume a turn paused as stuck, with optional guidance.
+function StuckResume({ onResume }: { onResume: (guidance: string) => Promise<void> }) {
+  const [guidance, setGuidance] = useState("");
+  const [resuming, setResuming] = useState(false);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setResuming(true);
+    try {
+      await onResume(guidance.trim());
+    } finally {
+      setResuming(false);
+    }
+  };
+
+  return (
+    <form className="message-stuck-resume" onSubmit={handleSubmit}>
+      <input
+        type="text"
+        value={guidance}
+        onChange={(e) => setGuidance(e.target.value)}
+        placeholder="Guidance for the agent (optional)"
+        disabled={resuming}
+      />
+      <button type="submit" className="btn-primary" disabled={resuming}>
+        {resuming ? "Resuming..." : "Resume"}
+      </button>
+    </form>
+  );
+}
+
+function Message({
+  message,
+  onOpenDiffViewer,
+  onCommentTextChange,
+  annotation,
+  onAnnotate,
+  comments,
+  onComment,
+  onResolveComment,
+  onResume,
+}: MessageProps) {
   // Hide system messages from the UI
   if (message.type === "system") {
     return null;
@@ -146,7 +206,8 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   // Based on llm/llm.go constants (iota continues across types in same const block):
   // MessageRoleUser = 0, MessageRoleAssistant = 1,
   // ContentTypeText = 2, ContentTypeThinking = 3, ContentTypeRedactedThinking = 4,
-  // ContentTypeToolUse = 5, ContentTypeToolResult = 6
+  // ContentTypeToolUse = 5, ContentTypeToolResult = 6, ContentTypeImage = 16,
+  // ContentTypeDocument = 17
   const getContentType = (type: number): string => {
     switch (type) {
       case 0:
@@ -163,6 +224,10 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
         return "tool_use";
       case 6:
         return "tool_result";
+      case 16:
+        return "image";
+      case 17:
+        return "document";
       default:
         return "unknown";
     }
@@ -271,6 +336,8 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   }
 
   const isUser = message.type === "user" && !hasToolResult(llmMessage);
+  // Who sent a user message, on servers that identify users
+  const author = isUser ? messageAuthor(message) : "";
   const isTool = message.type === "tool" || hasToolContent(llmMessage);
   const isError = message.type === "error";
 
@@ -278,6 +345,66 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   const messageText = getMessageText();
   const hasCopyAction = !!messageText;
   const hasUsageAction = message.type === "agent" && !!usage;
+  const canAnnotate = message.type === "agent" && !!onAnnotate;
+
+  // Annotation changes keep the other fields of the current annotation
+  const current = {
+    rating: annotation?.rating,
+    flagged: annotation?.flagged ?? false,
+    note: annotation?.note ?? "",
+  };
+  const handleRate = (rating: "up" | "down") => {
+    onAnnotate?.({ ...current, rating: current.rating === rating ? undefined : rating });
+  };
+  const handleFlag = () => {
+    onAnnotate?.({ ...current, flagged: !current.flagged });
+  };
+  const handleNote = () => {
+    const note = window.prompt("Note for this message", current.note);
+    if (note !== null) {
+      onAnnotate?.({ ...current, note });
+    }
+  };
+
+  const annotationBadge = annotation && (
+    <div className="message-annotation" data-testid="message-annotation">
+      {annotation.rating === "up" && <span title="Rated helpful">👍</span>}
+      {annotation.rating === "down" && <span title="Rated unhelpful">👎</span>}
+      {annotation.flagged && <span title="Flagged as wrong">🚩</span>}
+      {annotation.note && <span className="message-annotation-note">{annotation.note}</span>}
+    </div>
+  );
+
+  const promptComment = (parentId?: number) => {
+    const body = window.prompt(parentId ? "Reply" : "Review comment for this message");
+    if (body && body.trim()) {
+      onComment?.(body, parentId);
+    }
+  };
+
+  const threads = (comments ?? []).filter((c) => !c.parent_id);
+  const reviewComments = threads.length > 0 && (
+    <div className="review-comments" data-testid="review-comments">
+      {threads.map((thread) => (
+        <div key={thread.id} className={`review-thread${thread.resolved ? " resolved" : ""}`}>
+          {[thread, ...(comments ?? []).filter((c) => c.parent_id === thread.id)].map((c) => (
+            <div key={c.id} className="review-comment">
+              <span className="review-comment-author">{c.author || "anonymous"}</span>
+              <span className="review-comment-body">{c.body}</span>
+            </div>
+          ))}
+          {onComment && (
+            <div className="review-thread-actions">
+              <button onClick={() => promptComment(thread.id)}>Reply</button>
+              <button onClick={() => onResolveComment?.(thread.id, !thread.resolved)}>
+                {thread.resolved ? "Reopen" : "Resolve"}
+              </button>
+            </div>
+          )}
+        </div>
+      ))}
+    </div>
+  );
 
   // Build a map of tool use IDs to their inputs for linking tool_result back to tool_use
   const toolUseMap: Record<string, { name: string; input: unknown }> = {};
@@ -380,6 +507,18 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
         if (content.ToolName === "output_iframe") {
           return <OutputIframeTool toolInput={content.ToolInput} isRunning={true} />;
         }
+        // Use specialized component for run tests tool
+        if (content.ToolName === "run_tests") {
+          return <RunTestsTool toolInput={content.ToolInput} isRunning={true} />;
+        }
+        // Use specialized component for submit plan tool
+        if (content.ToolName === "submit_plan") {
+          return <PlanTool toolInput={content.ToolInput} isRunning={true} />;
+        }
+        // Use specialized component for todo tool
+        if (content.ToolName === "todo") {
+          return <TodoTool toolInput={content.ToolInput} isRunning={true} />;
+        }
         // Use specialized component for browser console logs tools
         if (
           content.ToolName === "browser_recent_console_logs" ||
@@ -607,6 +746,48 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           );
         }
 
+        // Use specialized component for run tests tool
+        if (toolName === "run_tests") {
+          return (
+            <RunTestsTool
+              toolInput={toolInput}
+              isRunning={false}
+              toolResult={content.ToolResult}
+              hasError={hasError}
+              executionTime={executionTime}
+              display={content.Display}
+            />
+          );
+        }
+
+        // Use specialized component for submit plan tool
+        if (toolName === "submit_plan") {
+          return (
+            <PlanTool
+              toolInput={toolInput}
+              isRunning={false}
+              toolResult={content.ToolResult}
+              hasError={hasError}
+              executionTime={executionTime}
+              display={content.Display}
+            />
+          );
+        }
+
+        // Use specialized component for todo tool
+        if (toolName === "todo") {
+          return (
+            <TodoTool
+              toolInput={toolInput}
+              isRunning={false}
+              toolResult={content.ToolResult}
+              hasError={hasError}
+              executionTime={executionTime}
+              display={content.Display}
+            />
+          );
+        }
+
         // Use specialized component for browser console logs tools
         if (
           toolName === "browser_recent_console_logs" ||
@@ -636,11 +817,41 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           />
         );
       }
+      case "image": {
+        // Generated images are saved as attachments; Text is their path
+        const src = content.Text
+          ? withBasePath(`/api/read?path=${encodeURIComponent(content.Text)}`)
+          : content.Data
+            ? `data:${content.MediaType};base64,${content.Data}`
+            : undefined;
+        if (!src) return null;
+        return (
+          <figure className="generated-image">
+            <a href={src} target="_blank" rel="noopener noreferrer">
+              <img src={src} alt="Generated image" />
+            </a>
+            {content.Text && <figcaption>{content.Text}</figcaption>}
+          </figure>
+        );
+      }
+      case "document": {
+        // The extracted text goes to the model; show just its header line
+        const header = (content.Text || "").split("\n")[0].replace(/:$/, "");
+        return <div className="document-attachment">{header || "Document"}</div>;
+      }
       case "redacted_thinking":
         return <div className="text-tertiary italic text-sm">[Thinking content hidden]</div>;
-      case "thinking":
-        // Hide thinking content by default in main flow, but could be made expandable
-        return null;
+      case "thinking": {
+        // Thinking is only sent by the server when the user asked to show reasoning
+        const thinking = content.Thinking || content.Text;
+        if (!thinking) return null;
+        return (
+          <details className="thinking-block">
+            <summary>Reasoning</summary>
+            <div className="thinking-text">{thinking}</div>
+          </details>
+        );
+      }
       default: {
         // For unknown content types, show the type and try to display useful content
         const displayText = content.Text || content.Data || "";
@@ -819,6 +1030,7 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           )}
           <div className="message-content" data-testid="message-content">
             <div className="whitespace-pre-wrap break-words">{errorText}</div>
+            {llmMessage?.ErrorType === "stuck" && onResume && <StuckResume onResume={onResume} />}
           </div>
         </div>
         {showUsageModal && usage && (
@@ -878,9 +1090,9 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   const meaningfulContent =
     llmMessage?.Content?.filter((c) => {
       const contentType = c.Type;
-      // Filter out thinking (3), redacted thinking (4), tool_use (5), tool_result (6), and empty text content
+      // Filter out empty thinking (3), redacted thinking (4), tool_use (5), tool_result (6), and empty text content
       return (
-        contentType !== 3 &&
+        (contentType !== 3 || c.Thinking?.trim() || c.Text?.trim()) &&
         contentType !== 4 &&
         contentType !== 5 &&
         contentType !== 6 &&
@@ -915,18 +1127,27 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
         data-testid="message"
         role="article"
       >
-        {actionBarVisible && (hasCopyAction || hasUsageAction) && (
+        {actionBarVisible && (hasCopyAction || hasUsageAction || canAnnotate || !!onComment) && (
           <MessageActionBar
             onCopy={hasCopyAction ? handleCopy : undefined}
             onShowUsage={hasUsageAction ? handleShowUsage : undefined}
+            rating={annotation?.rating}
+            flagged={annotation?.flagged}
+            onRate={canAnnotate ? handleRate : undefined}
+            onFlag={canAnnotate ? handleFlag : undefined}
+            onNote={canAnnotate ? handleNote : undefined}
+            onComment={onComment ? () => promptComment() : undefined}
           />
         )}
+        {author && <div className="message-author">{author}</div>}
         {/* Message content */}
         <div className="message-content" data-testid="message-content">
           {contentToRender.map((content, index) => (
             <div key={index}>{renderContent(content)}</div>
           ))}
         </div>
+        {annotationBadge}
+        {reviewComments}
       </div>
       {showUsageModal && usage && (
         <UsageDetailModal
@@ -940,6 +1161,17 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
 }
 
 // Helper functions
+function messageAuthor(message: MessageType): string {
+  if (!message.user_data) return "";
+  try {
+    const data =
+      typeof message.user_data === "string" ? JSON.parse(message.user_data) : message.user_data;
+    return typeof data?.author === "string" ? data.author : "";
+  } catch {
+    return "";
+  }
+}
+
 function hasToolResult(llmMessage: LLMMessage | null): boolean {
   if (!llmMessage) return false;
   return llmMessage.Content?.some((c) => c.Type === 6) ?? false; // 6 = tool_result
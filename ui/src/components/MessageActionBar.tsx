@@ -3,9 +3,67 @@ import React, { useState } from "react";
 interface MessageActionBarProps {
   onCopy?: () => void;
   onShowUsage?: () => void;
+  rating?: "up" | "down";
+  flagged?: boolean;
+  onRate?: (rating: "up" | "down") => void;
+  onFlag?: () => void;
+  onNote?: () => void;
+  onComment?: () => void;
 }
 
-function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
+interface AnnotationButtonProps {
+  title: string;
+  active?: boolean;
+  onClick: () => void;
+  children: React.ReactNode;
+}
+
+// AnnotationButton is an action bar button that stays highlighted while active
+function AnnotationButton({ title, active, onClick, children }: AnnotationButtonProps) {
+  return (
+    <button
+      onClick={(e) => {
+        e.stopPropagation();
+        onClick();
+      }}
+      title={title}
+      aria-pressed={active}
+      style={{
+        display: "flex",
+        alignItems: "center",
+        justifyContent: "center",
+        width: "24px",
+        height: "24px",
+        borderRadius: "4px",
+        border: "none",
+        background: active ? "var(--bg-tertiary)" : "transparent",
+        cursor: "pointer",
+        fontSize: "13px",
+        opacity: active ? 1 : 0.6,
+        transition: "background-color 0.15s, opacity 0.15s",
+      }}
+      onMouseEnter={(e) => {
+        e.currentTarget.style.opacity = "1";
+      }}
+      onMouseLeave={(e) => {
+        e.currentTarget.style.opacity = active ? "1" : "0.6";
+      }}
+    >
+      {children}
+    </button>
+  );
+}
+
+function MessageActionBar({
+  onCopy,
+  onShowUsage,
+  rating,
+  flagged,
+  onRate,
+  onFlag,
+  onNote,
+  onComment,
+}: MessageActionBarProps) {
   const [copyFeedback, setCopyFeedback] = useState(false);
 
   const handleCopy = (e: React.MouseEvent) => {
@@ -139,6 +197,39 @@ function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
           </svg>
         </button>
       )}
+      {onRate && (
+        <>
+          <AnnotationButton
+            title="Good response"
+            active={rating === "up"}
+            onClick={() => onRate("up")}
+          >
+            👍
+          </AnnotationButton>
+          <AnnotationButton
+            title="Bad response"
+            active={rating === "down"}
+            onClick={() => onRate("down")}
+          >
+            👎
+          </AnnotationButton>
+        </>
+      )}
+      {onFlag && (
+        <AnnotationButton title="Flag as wrong" active={flagged} onClick={onFlag}>
+          🚩
+        </AnnotationButton>
+      )}
+      {onNote && (
+        <AnnotationButton title="Add note" onClick={onNote}>
+          📝
+        </AnnotationButton>
+      )}
+      {onComment && (
+        <AnnotationButton title="Add review comment" onClick={onComment}>
+          💬
+        </AnnotationButton>
+      )}
     </div>
   );
 }
@@ -1,4 +1,5 @@
 import React, { useState, useRef, useEffect, useCallback, useMemo } from "react";
+import { withBasePath } from "../services/basePath";
 
 // Web Speech API types
 interface SpeechRecognitionEvent extends Event {
@@ -83,6 +84,7 @@ function MessageInput({
   const textareaRef = useRef<HTMLTextAreaElement>(null);
   const fileInputRef = useRef<HTMLInputElement>(null);
   const recognitionRef = useRef<SpeechRecognition | null>(null);
+  const recorderRef = useRef<MediaRecorder | null>(null);
   // Track the base text (before speech recognition started) and finalized speech text
   const baseTextRef = useRef<string>("");
   const finalizedTextRef = useRef<string>("");
@@ -90,6 +92,14 @@ function MessageInput({
   // Check if speech recognition is available
   const speechRecognitionAvailable =
     typeof window !== "undefined" && (window.SpeechRecognition || window.webkitSpeechRecognition);
+  // When the server has a transcriber, record audio and transcribe it there instead;
+  // it works in browsers without speech recognition and is usually more accurate
+  const serverTranscriptionAvailable =
+    typeof window !== "undefined" &&
+    !!window.__SHELLEY_INIT__?.transcription &&
+    typeof MediaRecorder !== "undefined" &&
+    !!navigator.mediaDevices?.getUserMedia;
+  const voiceInputAvailable = serverTranscriptionAvailable || speechRecognitionAvailable;
 
   // Responsive placeholder text
   const placeholderText = useMemo(
@@ -111,9 +121,69 @@ function MessageInput({
       recognitionRef.current.stop();
       recognitionRef.current = null;
     }
+    if (recorderRef.current) {
+      // Stopping the recorder sends the recording to be transcribed
+      recorderRef.current.stop();
+      recorderRef.current = null;
+    }
     setIsListening(false);
   }, []);
 
+  const transcribeRecording = useCallback(async (audio: Blob) => {
+    const loadingText = "[transcribing...]";
+    setMessage((prev) => (prev && !/\s$/.test(prev) ? prev + " " : prev) + loadingText);
+    setUploadsInProgress((prev) => prev + 1);
+
+    try {
+      const extension = audio.type.includes("mp4") ? "mp4" : audio.type.includes("ogg") ? "ogg" : "webm";
+      const formData = new FormData();
+      formData.append("file", audio, `speech.${extension}`);
+
+      const response = await fetch(withBasePath("/api/transcribe"), {
+        method: "POST",
+        headers: { "X-Shelley-Request": "1" },
+        body: formData,
+      });
+
+      if (!response.ok) {
+        throw new Error(`Transcription failed: ${response.statusText}`);
+      }
+
+      const data = await response.json();
+      setMessage((currentMessage) => currentMessage.replace(loadingText, data.text || ""));
+    } catch (error) {
+      console.error("Failed to transcribe recording:", error);
+      const errorText = `[transcription failed: ${error instanceof Error ? error.message : "unknown error"}]`;
+      setMessage((currentMessage) => currentMessage.replace(loadingText, errorText));
+    } finally {
+      setUploadsInProgress((prev) => prev - 1);
+    }
+  }, []);
+
+  const startRecording = useCallback(async () => {
+    let stream: MediaStream;
+    try {
+      stream = await navigator.mediaDevices.getUserMedia({ audio: true });
+    } catch (error) {
+      console.error("Microphone access failed:", error);
+      return;
+    }
+    const recorder = new MediaRecorder(stream);
+    const chunks: Blob[] = [];
+    recorder.ondataavailable = (event) => {
+      if (event.data.size > 0) chunks.push(event.data);
+    };
+    recorder.onstop = () => {
+      stream.getTracks().forEach((track) => track.stop());
+      if (chunks.length > 0) {
+        transcribeRecording(new Blob(chunks, { type: recorder.mimeType }));
+      }
+    };
+    recorderRef.current = recorder;
+    recorder.start();
+    setIsListening(true);
+  }, [transcribeRecording]);
+
   const startListening = useCallback(() => {
     if (!speechRecognitionAvailable) return;
 
@@ -176,10 +246,12 @@ function MessageInput({
   const toggleListening = useCallback(() => {
     if (isListening) {
       stopListening();
+    } else if (serverTranscriptionAvailable) {
+      startRecording();
     } else {
       startListening();
     }
-  }, [isListening, startListening, stopListening]);
+  }, [isListening, serverTranscriptionAvailable, startListening, startRecording, stopListening]);
 
   // Cleanup on unmount
   useEffect(() => {
@@ -187,6 +259,11 @@ function MessageInput({
       if (recognitionRef.current) {
         recognitionRef.current.abort();
       }
+      if (recorderRef.current) {
+        // Discard the recording
+        recorderRef.current.ondataavailable = null;
+        recorderRef.current.stop();
+      }
     };
   }, []);
 
@@ -200,7 +277,7 @@ function MessageInput({
       const formData = new FormData();
       formData.append("file", file);
 
-      const response = await fetch("/api/upload", {
+      const response = await fetch(withBasePath("/api/upload"), {
         method: "POST",
         headers: { "X-Shelley-Request": "1" },
         body: formData,
@@ -432,7 +509,7 @@ function MessageInput({
           onChange={handleFileSelect}
           style={{ display: "none" }}
           multiple
-          accept="image/*,video/*,audio/*,.pdf,.txt,.md,.json,.csv,.xml,.html,.css,.js,.ts,.tsx,.jsx,.py,.go,.rs,.java,.c,.cpp,.h,.hpp,.sh,.yaml,.yml,.toml,.sql,.log,*"
+          accept="image/*,video/*,audio/*,.pdf,.docx,.txt,.md,.json,.csv,.xml,.html,.css,.js,.ts,.tsx,.jsx,.py,.go,.rs,.java,.c,.cpp,.h,.hpp,.sh,.yaml,.yml,.toml,.sql,.log,*"
           aria-hidden="true"
         />
         {isShellMode && (
@@ -493,7 +570,7 @@ function MessageInput({
             />
           </svg>
         </button>
-        {speechRecognitionAvailable && (
+        {voiceInputAvailable && (
           <button
             type="button"
             onClick={toggleListening}
@@ -109,6 +109,19 @@ function ModelPicker({
                 type="button"
               >
                 <span className="model-picker-option-name">{model.display_name || model.id}</span>
+                {model.ready && model.supports_vision && (
+                  <span className="model-picker-option-badge" title="Accepts images">
+                    vision
+                  </span>
+                )}
+                {model.ready && model.pricing && model.pricing.output_per_mtok > 0 && (
+                  <span
+                    className="model-picker-option-badge"
+                    title="USD per million input / output tokens"
+                  >
+                    ${model.pricing.input_per_mtok}/${model.pricing.output_per_mtok}
+                  </span>
+                )}
                 {!model.ready && <span className="model-picker-option-badge">not ready</span>}
                 {model.id === selectedModel && (
                   <svg
@@ -0,0 +1,100 @@
+import React, { useState } from "react";
+import { LLMContent } from "../types";
+import { api } from "../services/api";
+
+// PlanDisplay from the Go submit_plan tool
+interface PlanDisplay {
+  type: "plan";
+  plan: string;
+  conversation_id: string;
+}
+
+interface PlanToolProps {
+  // For tool_use (pending state)
+  toolInput?: unknown; // { plan: string }
+  isRunning?: boolean;
+
+  // For tool_result (completed state)
+  toolResult?: LLMContent[];
+  hasError?: boolean;
+  executionTime?: string;
+  display?: unknown;
+}
+
+function isPlanDisplay(display: unknown): display is PlanDisplay {
+  return (
+    typeof display === "object" &&
+    display !== null &&
+    "type" in display &&
+    (display as PlanDisplay).type === "plan"
+  );
+}
+
+function PlanTool({ toolInput, isRunning, toolResult, hasError, display }: PlanToolProps) {
+  const [approving, setApproving] = useState(false);
+  const [approved, setApproved] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  const inputPlan =
+    typeof toolInput === "object" &&
+    toolInput !== null &&
+    "plan" in toolInput &&
+    typeof toolInput.plan === "string"
+      ? toolInput.plan
+      : "";
+
+  const submitted = isPlanDisplay(display) ? display : undefined;
+  const plan = submitted ? submitted.plan : inputPlan;
+  const isComplete = !isRunning && toolResult !== undefined;
+
+  const approve = async () => {
+    if (!submitted) return;
+    setApproving(true);
+    setError(null);
+    try {
+      await api.approvePlan(submitted.conversation_id);
+      setApproved(true);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to approve plan");
+    } finally {
+      setApproving(false);
+    }
+  };
+
+  const errorText =
+    hasError && toolResult && toolResult.length > 0 && toolResult[0].Text ? toolResult[0].Text : "";
+
+  return (
+    <div
+      className="tool plan-tool"
+      data-testid={isComplete ? "tool-call-completed" : "tool-call-running"}
+    >
+      <div className="tool-header">
+        <div className="tool-summary">
+          <span className={`tool-emoji ${isRunning ? "running" : ""}`}>📋</span>
+          <span className="tool-command">Plan</span>
+          {isComplete && hasError && <span className="tool-error">✗</span>}
+        </div>
+      </div>
+
+      <div className="tool-details">
+        <pre className="plan-tool-text">{plan}</pre>
+        {errorText && <pre className="tool-code error">{errorText}</pre>}
+        {submitted && !hasError && (
+          <div className="plan-tool-actions">
+            {approved ? (
+              <span className="plan-tool-approved">✓ Approved</span>
+            ) : (
+              <button className="btn-primary" onClick={approve} disabled={approving}>
+                {approving ? "Approving..." : "Approve plan"}
+              </button>
+            )}
+            {error && <span className="tool-error">{error}</span>}
+          </div>
+        )}
+      </div>
+    </div>
+  );
+}
+
+export default PlanTool;
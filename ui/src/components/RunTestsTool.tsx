@@ -0,0 +1,211 @@
+import React, { useState } from "react";
+import { LLMContent } from "../types";
+
+interface TestResult {
+  name: string;
+  package?: string;
+  status: "pass" | "fail" | "error" | "skip";
+  duration?: number;
+  output?: string;
+}
+
+// TestRunDisplay from the Go tool
+interface TestRunDisplay {
+  runner: string;
+  command: string;
+  exit_code: number;
+  passed: number;
+  failed: number;
+  errors: number;
+  skipped: number;
+  duration: number;
+  tests: TestResult[];
+  output?: string;
+}
+
+interface RunTestsToolProps {
+  // For tool_use (pending state)
+  toolInput?: unknown; // { runner?: string, args?: string, timeout?: string }
+  isRunning?: boolean;
+
+  // For tool_result (completed state)
+  toolResult?: LLMContent[];
+  hasError?: boolean;
+  executionTime?: string;
+  display?: unknown;
+}
+
+const STATUS_ICONS: Record<TestResult["status"], string> = {
+  pass: "✓",
+  fail: "✗",
+  error: "!",
+  skip: "○",
+};
+
+function isTestRunDisplay(display: unknown): display is TestRunDisplay {
+  return (
+    typeof display === "object" &&
+    display !== null &&
+    "tests" in display &&
+    Array.isArray((display as TestRunDisplay).tests)
+  );
+}
+
+function RunTestsTool({
+  toolInput,
+  isRunning,
+  toolResult,
+  hasError,
+  executionTime,
+  display,
+}: RunTestsToolProps) {
+  const [isExpanded, setIsExpanded] = useState(false);
+  const [showPassing, setShowPassing] = useState(false);
+  const [openTests, setOpenTests] = useState<Set<number>>(new Set());
+
+  const args =
+    typeof toolInput === "object" &&
+    toolInput !== null &&
+    "args" in toolInput &&
+    typeof toolInput.args === "string"
+      ? toolInput.args
+      : "";
+
+  const run = isTestRunDisplay(display) ? display : undefined;
+  const isComplete = !isRunning && toolResult !== undefined;
+  const failing = run ? run.failed + run.errors : 0;
+  const output =
+    toolResult && toolResult.length > 0 && toolResult[0].Text ? toolResult[0].Text : "";
+
+  const toggleTest = (i: number) => {
+    const next = new Set(openTests);
+    if (next.has(i)) {
+      next.delete(i);
+    } else {
+      next.add(i);
+    }
+    setOpenTests(next);
+  };
+
+  const visibleTests = run
+    ? run.tests
+        .map((test, i) => ({ test, i }))
+        .filter(({ test }) => showPassing || test.status === "fail" || test.status === "error")
+    : [];
+
+  return (
+    <div
+      className="tool run-tests-tool"
+      data-testid={isComplete ? "tool-call-completed" : "tool-call-running"}
+    >
+      <div className="tool-header" onClick={() => setIsExpanded(!isExpanded)}>
+        <div className="tool-summary">
+          <span className={`tool-emoji ${isRunning ? "running" : ""}`}>🧪</span>
+          <span className="tool-command">{run ? run.command : args || "run tests"}</span>
+          {run && (
+            <span className="run-tests-counts">
+              <span className="run-tests-passed">{run.passed} passed</span>
+              {run.failed > 0 && <span className="run-tests-failed">{run.failed} failed</span>}
+              {run.errors > 0 && <span className="run-tests-failed">{run.errors} errors</span>}
+              {run.skipped > 0 && <span className="run-tests-skipped">{run.skipped} skipped</span>}
+            </span>
+          )}
+          {isComplete && (hasError || failing > 0) && <span className="tool-error">✗</span>}
+          {isComplete && !hasError && run && failing === 0 && (
+            <span className="tool-success">✓</span>
+          )}
+        </div>
+        <button
+          className="tool-toggle"
+          aria-label={isExpanded ? "Collapse" : "Expand"}
+          aria-expanded={isExpanded}
+        >
+          <svg
+            width="12"
+            height="12"
+            viewBox="0 0 12 12"
+            fill="none"
+            xmlns="http://www.w3.org/2000/svg"
+            style={{
+              transform: isExpanded ? "rotate(90deg)" : "rotate(0deg)",
+              transition: "transform 0.2s",
+            }}
+          >
+            <path
+              d="M4.5 3L7.5 6L4.5 9"
+              stroke="currentColor"
+              strokeWidth="1.5"
+              strokeLinecap="round"
+              strokeLinejoin="round"
+            />
+          </svg>
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="tool-details">
+          {isComplete && run && (
+            <div className="tool-section">
+              <div className="tool-label">
+                Tests:
+                {executionTime && <span className="tool-time">{executionTime}</span>}
+                {run.passed + run.skipped > 0 && (
+                  <button
+                    className="run-tests-show-passing"
+                    onClick={() => setShowPassing(!showPassing)}
+                  >
+                    {showPassing ? "Hide passing" : "Show all"}
+                  </button>
+                )}
+              </div>
+              {visibleTests.length === 0 && (
+                <div className="run-tests-empty">
+                  {run.tests.length === 0 ? "No tests were recognized." : "All tests passed."}
+                </div>
+              )}
+              <ul className="run-tests-list">
+                {visibleTests.map(({ test, i }) => (
+                  <li key={i} className={`run-tests-item ${test.status}`}>
+                    <div
+                      className="run-tests-item-header"
+                      onClick={() => test.output && toggleTest(i)}
+                    >
+                      <span className="run-tests-status">{STATUS_ICONS[test.status]}</span>
+                      <span className="run-tests-name">
+                        {test.package && <span className="run-tests-package">{test.package}</span>}
+                        {test.name}
+                      </span>
+                      {test.duration !== undefined && test.duration > 0 && (
+                        <span className="tool-time">{test.duration.toFixed(2)}s</span>
+                      )}
+                    </div>
+                    {test.output && openTests.has(i) && (
+                      <pre className="tool-code error">{test.output}</pre>
+                    )}
+                  </li>
+                ))}
+              </ul>
+              {run.tests.length === 0 && run.output && (
+                <pre className="tool-code">{run.output}</pre>
+              )}
+            </div>
+          )}
+
+          {isComplete && !run && (
+            <div className="tool-section">
+              <div className="tool-label">
+                Output{hasError ? " (Error)" : ""}:
+                {executionTime && <span className="tool-time">{executionTime}</span>}
+              </div>
+              <pre className={`tool-code ${hasError ? "error" : ""}`}>
+                {output || "(no output)"}
+              </pre>
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default RunTestsTool;
@@ -3,10 +3,14 @@ import { Terminal } from "@xterm/xterm";
 import { FitAddon } from "@xterm/addon-fit";
 import { WebLinksAddon } from "@xterm/addon-web-links";
 import "@xterm/xterm/css/xterm.css";
+import { basePath } from "../services/basePath";
 
 interface TerminalWidgetProps {
   command: string;
   cwd: string;
+  // If set, runs an interactive shell in the conversation's environment
+  // instead of command
+  conversationId?: string;
   onInsertIntoInput?: (text: string) => void;
   onClose?: () => void;
 }
@@ -179,6 +183,7 @@ const CloseIcon = () => (
 export default function TerminalWidget({
   command,
   cwd,
+  conversationId,
   onInsertIntoInput,
   onClose,
 }: TerminalWidgetProps) {
@@ -405,7 +410,9 @@ export default function TerminalWidget({
 
     // Connect websocket
     const protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
-    const wsUrl = `${protocol}//${window.location.host}/api/exec-ws?cmd=${encodeURIComponent(command)}&cwd=${encodeURIComponent(cwd)}`;
+    const wsUrl = conversationId
+      ? `${protocol}//${window.location.host}${basePath}/api/conversations/${encodeURIComponent(conversationId)}/terminal`
+      : `${protocol}//${window.location.host}${basePath}/api/exec-ws?cmd=${encodeURIComponent(command)}&cwd=${encodeURIComponent(cwd)}`;
     const ws = new WebSocket(wsUrl);
     wsRef.current = ws;
 
@@ -481,7 +488,7 @@ export default function TerminalWidget({
       ws.close();
       term.dispose();
     };
-  }, [command, cwd]); // Only recreate on command/cwd change, not on isDark change
+  }, [command, cwd, conversationId]); // Only recreate on command/cwd change, not on isDark change
 
   // Auto-size when process exits
   useEffect(() => {
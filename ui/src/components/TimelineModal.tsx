@@ -0,0 +1,129 @@
+import React, { useEffect, useState } from "react";
+import { ConversationEvent } from "../types";
+import { api } from "../services/api";
+import Modal from "./Modal";
+
+interface TimelineModalProps {
+  isOpen: boolean;
+  onClose: () => void;
+  conversationId: string;
+  // Events streamed since the conversation was opened
+  liveEvents: ConversationEvent[];
+}
+
+// A timeline step: a finished or running LLM request or tool call, or a
+// change of the agent's working state
+interface TimelineRow {
+  id: number;
+  kind: "llm" | "tool" | "state";
+  name: string;
+  start: number;
+  durationMs?: number;
+  error?: string;
+  running: boolean;
+}
+
+function formatDuration(ms: number): string {
+  if (ms < 1000) return `${ms}ms`;
+  if (ms < 60000) return `${(ms / 1000).toFixed(1)}s`;
+  return `${Math.floor(ms / 60000)}m ${Math.round((ms % 60000) / 1000)}s`;
+}
+
+// toRows pairs the start and end events of LLM requests and tool calls
+function toRows(events: ConversationEvent[]): TimelineRow[] {
+  const rows: TimelineRow[] = [];
+  const open = new Map<string, TimelineRow>();
+  for (const e of events) {
+    const time = new Date(e.created_at).getTime();
+    switch (e.type) {
+      case "state":
+        rows.push({ id: e.id, kind: "state", name: e.name || "", start: time, running: false });
+        break;
+      case "llm_request_start":
+      case "tool_start": {
+        const row: TimelineRow = {
+          id: e.id,
+          kind: e.type === "tool_start" ? "tool" : "llm",
+          name: e.name || "",
+          start: time,
+          running: true,
+        };
+        rows.push(row);
+        if (e.ref_id) open.set(e.ref_id, row);
+        break;
+      }
+      case "llm_request_end":
+      case "tool_end": {
+        const row = e.ref_id ? open.get(e.ref_id) : undefined;
+        if (!row) break;
+        open.delete(e.ref_id!);
+        row.running = false;
+        row.durationMs = e.duration_ms;
+        row.error = e.error;
+        if (e.name) row.name = e.name;
+        break;
+      }
+    }
+  }
+  return rows;
+}
+
+function TimelineModal({ isOpen, onClose, conversationId, liveEvents }: TimelineModalProps) {
+  const [history, setHistory] = useState<ConversationEvent[]>([]);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    if (!isOpen) return;
+    setError(null);
+    api
+      .getConversationEvents(conversationId)
+      .then(setHistory)
+      .catch((err) => setError(err instanceof Error ? err.message : "Failed to load timeline"));
+  }, [isOpen, conversationId]);
+
+  const byId = new Map<number, ConversationEvent>();
+  for (const e of [...history, ...liveEvents]) byId.set(e.id, e);
+  const rows = toRows([...byId.values()].sort((a, b) => a.id - b.id));
+  const maxDuration = Math.max(1, ...rows.map((r) => r.durationMs || 0));
+  const origin = rows.length > 0 ? rows[0].start : 0;
+
+  return (
+    <Modal isOpen={isOpen} onClose={onClose} title="Timeline" className="timeline-modal">
+      {error && <div className="timeline-error">{error}</div>}
+      {!error && rows.length === 0 && <div className="timeline-empty">No events yet</div>}
+      <div className="timeline-rows">
+        {rows.map((row) =>
+          row.kind === "state" ? (
+            <div key={row.id} className="timeline-state">
+              {row.name === "working" ? "Working" : "Idle"} ·{" "}
+              {new Date(row.start).toLocaleTimeString()}
+            </div>
+          ) : (
+            <div
+              key={row.id}
+              className={`timeline-row timeline-row-${row.kind}${row.error ? " timeline-row-error" : ""}`}
+              title={row.error || undefined}
+            >
+              <span className="timeline-offset">+{formatDuration(row.start - origin)}</span>
+              <span className="timeline-label">
+                {row.kind === "llm" ? "LLM" : "Tool"}
+                {row.name && `: ${row.name}`}
+              </span>
+              <span className="timeline-bar-track">
+                <span
+                  className="timeline-bar"
+                  style={{ width: `${((row.durationMs || 0) / maxDuration) * 100}%` }}
+                />
+              </span>
+              <span className="timeline-duration">
+                {row.running ? "running" : formatDuration(row.durationMs || 0)}
+              </span>
+            </div>
+          ),
+        )}
+      </div>
+    </Modal>
+  );
+}
+
+export default TimelineModal;
@@ -0,0 +1,77 @@
+import React from "react";
+import { LLMContent, Todo } from "../types";
+
+interface TodoToolProps {
+  // For tool_use (pending state)
+  toolInput?: unknown; // { todos: Todo[] }
+  isRunning?: boolean;
+
+  // For tool_result (completed state)
+  toolResult?: LLMContent[];
+  hasError?: boolean;
+  executionTime?: string;
+  display?: unknown;
+}
+
+const STATUS_ICONS: Record<Todo["status"], string> = {
+  pending: "○",
+  in_progress: "◐",
+  completed: "✓",
+};
+
+function todosFrom(value: unknown): Todo[] {
+  if (
+    typeof value === "object" &&
+    value !== null &&
+    "todos" in value &&
+    Array.isArray((value as { todos: unknown }).todos)
+  ) {
+    return (value as { todos: Todo[] }).todos;
+  }
+  return [];
+}
+
+// TodoList renders a task list; it is shared with the todo panel above the input
+export function TodoList({ todos }: { todos: Todo[] }) {
+  return (
+    <ul className="todo-list">
+      {todos.map((todo, i) => (
+        <li key={i} className={`todo-item ${todo.status}`}>
+          <span className="todo-status">{STATUS_ICONS[todo.status] || "○"}</span>
+          <span className="todo-content">{todo.content}</span>
+        </li>
+      ))}
+    </ul>
+  );
+}
+
+function TodoTool({ toolInput, isRunning, toolResult, hasError, display }: TodoToolProps) {
+  const todos = display ? todosFrom(display) : todosFrom(toolInput);
+  const isComplete = !isRunning && toolResult !== undefined;
+  const done = todos.filter((todo) => todo.status === "completed").length;
+  const errorText =
+    hasError && toolResult && toolResult.length > 0 && toolResult[0].Text ? toolResult[0].Text : "";
+
+  return (
+    <div
+      className="tool todo-tool"
+      data-testid={isComplete ? "tool-call-completed" : "tool-call-running"}
+    >
+      <div className="tool-header">
+        <div className="tool-summary">
+          <span className={`tool-emoji ${isRunning ? "running" : ""}`}>☑️</span>
+          <span className="tool-command">
+            Todos {done}/{todos.length}
+          </span>
+          {isComplete && hasError && <span className="tool-error">✗</span>}
+        </div>
+      </div>
+      <div className="tool-details">
+        <TodoList todos={todos} />
+        {errorText && <pre className="tool-code error">{errorText}</pre>}
+      </div>
+    </div>
+  );
+}
+
+export default TodoTool;
@@ -43,6 +43,7 @@ export interface ConversationStateForTS {
   conversation_id: string;
   working: boolean;
   model?: string;
+  queue_position?: number;
 }
 
 export interface StreamResponseForTS {
@@ -51,6 +52,12 @@ export interface StreamResponseForTS {
   conversation_state?: ConversationStateForTS | null;
 }
 
+export interface ConversationSummaryForTS {
+  summary: string;
+  files: string[] | null;
+  updated_at: string;
+}
+
 export interface ConversationWithStateForTS {
   conversation_id: string;
   slug: string | null;
@@ -62,6 +69,11 @@ export interface ConversationWithStateForTS {
   parent_conversation_id: string | null;
   model: string | null;
   working: boolean;
+  project_root?: string;
+  summary?: ConversationSummaryForTS | null;
+  unread_count?: number;
+  changed_files?: string[] | null;
+  tags?: string[] | null;
 }
 
 export type MessageType = "user" | "agent" | "tool" | "error" | "system" | "gitinfo";
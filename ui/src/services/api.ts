@@ -8,10 +8,21 @@ import {
   GitFileDiff,
   VersionInfo,
   CommitInfo,
+  Todo,
+  ConversationEvent,
+  Model,
+  MessageAnnotation,
+  ProjectGroup,
+  ReviewComment,
+  BulkAction,
+  BulkResponse,
+  RecentDirectory,
+  QuickSearchItem,
 } from "../types";
+import { withBasePath } from "./basePath";
 
 class ApiService {
-  private baseUrl = "/api";
+  private baseUrl = withBasePath("/api");
 
   // Common headers for state-changing requests (CSRF protection)
   private postHeaders = {
@@ -27,10 +38,34 @@ class ApiService {
     return response.json();
   }
 
-  async getModels(): Promise<
-    Array<{ id: string; display_name?: string; ready: boolean; max_context_tokens?: number }>
-  > {
-    const response = await fetch(`${this.baseUrl}/models`);
+  // Projects of the conversations, most recently active first; conversations
+  // name theirs in project_root
+  async getConversationProjects(): Promise<ProjectGroup[]> {
+    const response = await fetch(`${this.baseUrl}/conversations/projects`);
+    if (!response.ok) {
+      throw new Error(`Failed to get conversation projects: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async bulkConversations(
+    action: BulkAction,
+    conversationIds: string[],
+    tags?: string[],
+  ): Promise<BulkResponse> {
+    const response = await fetch(`${this.baseUrl}/conversations/bulk`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ action, conversation_ids: conversationIds, tags }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to ${action} conversations: ${await response.text()}`);
+    }
+    return response.json();
+  }
+
+  async getModels(check = false): Promise<Model[]> {
+    const response = await fetch(`${this.baseUrl}/models${check ? "?check=1" : ""}`);
     if (!response.ok) {
       throw new Error(`Failed to get models: ${response.statusText}`);
     }
@@ -81,8 +116,9 @@ class ApiService {
     return response.json();
   }
 
-  async getConversation(conversationId: string): Promise<StreamResponse> {
-    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}`);
+  async getConversation(conversationId: string, showReasoning = false): Promise<StreamResponse> {
+    const query = showReasoning ? "?show_reasoning=1" : "";
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}${query}`);
     if (!response.ok) {
       throw new Error(`Failed to get messages: ${response.statusText}`);
     }
@@ -100,8 +136,9 @@ class ApiService {
     }
   }
 
-  createMessageStream(conversationId: string): EventSource {
-    return new EventSource(`${this.baseUrl}/conversation/${conversationId}/stream`);
+  createMessageStream(conversationId: string, showReasoning = false): EventSource {
+    const query = showReasoning ? "?show_reasoning=1" : "";
+    return new EventSource(`${this.baseUrl}/conversation/${conversationId}/stream${query}`);
   }
 
   async cancelConversation(conversationId: string): Promise<void> {
@@ -114,6 +151,17 @@ class ApiService {
     }
   }
 
+  async resumeConversation(conversationId: string, guidance?: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/resume`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", "X-Shelley-Request": "1" },
+      body: JSON.stringify({ guidance }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to resume conversation: ${response.statusText}`);
+    }
+  }
+
   async validateCwd(path: string): Promise<{ valid: boolean; error?: string }> {
     const response = await fetch(`${this.baseUrl}/validate-cwd?path=${encodeURIComponent(path)}`);
     if (!response.ok) {
@@ -151,6 +199,88 @@ class ApiService {
     return response.json();
   }
 
+  async getRecentDirectories(): Promise<{
+    recent: RecentDirectory[];
+    favorites: RecentDirectory[];
+  }> {
+    const response = await fetch(`${this.baseUrl}/directories/recent`);
+    if (!response.ok) {
+      throw new Error(`Failed to get recent directories: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async setFavoriteDirectory(
+    path: string,
+    favorite: boolean,
+  ): Promise<{ recent: RecentDirectory[]; favorites: RecentDirectory[] }> {
+    const response = await fetch(`${this.baseUrl}/directories/favorites`, {
+      method: "PUT",
+      headers: this.postHeaders,
+      body: JSON.stringify({ path, favorite }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to update favorite directory: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // Ranks conversations, directories and the slash commands available in cwd
+  // by how well they match query and how recently and often they were used.
+  async quickSearch(query: string, cwd?: string, limit?: number): Promise<QuickSearchItem[]> {
+    const params = new URLSearchParams({ q: query });
+    if (cwd) params.set("cwd", cwd);
+    if (limit) params.set("limit", String(limit));
+    const response = await fetch(`${this.baseUrl}/quick-search?${params}`);
+    if (!response.ok) {
+      throw new Error(`Failed to search: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // Clones a repository into the server's workspaces directory, reporting
+  // git's progress lines, and returns the path of the clone.
+  async cloneRepository(
+    url: string,
+    branch: string,
+    onProgress: (message: string) => void,
+  ): Promise<string> {
+    const response = await fetch(`${this.baseUrl}/workspaces/clone`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ url, branch }),
+    });
+    if (!response.ok || !response.body) {
+      const text = await response.text();
+      throw new Error(text.trim() || `Failed to clone repository: ${response.statusText}`);
+    }
+
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = "";
+    for (;;) {
+      const { done, value } = await reader.read();
+      if (value) {
+        buffer += decoder.decode(value, { stream: true });
+      }
+      const lines = buffer.split("\n");
+      buffer = done ? "" : lines.pop() || "";
+      for (const line of lines) {
+        if (!line.trim()) continue;
+        const event: { type: string; message?: string; path?: string } = JSON.parse(line);
+        if (event.type === "progress" && event.message) {
+          onProgress(event.message);
+        } else if (event.type === "done" && event.path) {
+          return event.path;
+        } else if (event.type === "error") {
+          throw new Error(event.message || "Failed to clone repository");
+        }
+      }
+      if (done) break;
+    }
+    throw new Error("Clone ended without a result");
+  }
+
   async getArchivedConversations(): Promise<Conversation[]> {
     const response = await fetch(`${this.baseUrl}/conversations/archived`);
     if (!response.ok) {
@@ -181,6 +311,16 @@ class ApiService {
     return response.json();
   }
 
+  async markConversationRead(conversationId: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/read`, {
+      method: "POST",
+      headers: { "X-Shelley-Request": "1" },
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to mark conversation read: ${response.statusText}`);
+    }
+  }
+
   async deleteConversation(conversationId: string): Promise<void> {
     const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/delete`, {
       method: "POST",
@@ -246,6 +386,125 @@ class ApiService {
     return response.json();
   }
 
+  async getTodos(conversationId: string): Promise<{ todos: Todo[]; updated_at?: string }> {
+    const response = await fetch(`${this.baseUrl}/conversations/${conversationId}/todos`);
+    if (!response.ok) {
+      throw new Error(`Failed to get todos: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getConversationEvents(conversationId: string, after = 0): Promise<ConversationEvent[]> {
+    const response = await fetch(
+      `${this.baseUrl}/conversations/${conversationId}/events?after=${after}&limit=5000`,
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to get conversation events: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getAnnotations(conversationId: string): Promise<MessageAnnotation[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/annotations`);
+    if (!response.ok) {
+      throw new Error(`Failed to get annotations: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // An annotation without rating, flag or note is removed (null is returned)
+  async annotateMessage(
+    conversationId: string,
+    messageId: string,
+    annotation: { rating?: "up" | "down"; flagged?: boolean; note?: string },
+  ): Promise<MessageAnnotation | null> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/messages/${messageId}/annotation`,
+      {
+        method: "POST",
+        headers: this.postHeaders,
+        body: JSON.stringify(annotation),
+      },
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to annotate message: ${response.statusText}`);
+    }
+    return response.status === 204 ? null : response.json();
+  }
+
+  async getComments(conversationId: string): Promise<ReviewComment[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/comments`);
+    if (!response.ok) {
+      throw new Error(`Failed to get comments: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async createComment(
+    conversationId: string,
+    comment: {
+      parent_id?: number;
+      message_id?: string;
+      diff_id?: string;
+      file_path?: string;
+      start_line?: number;
+      end_line?: number;
+      body: string;
+    },
+  ): Promise<ReviewComment> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/comments`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify(comment),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to create comment: ${await response.text()}`);
+    }
+    return response.json();
+  }
+
+  async updateComment(
+    conversationId: string,
+    commentId: number,
+    update: { body?: string; resolved?: boolean },
+  ): Promise<ReviewComment> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/comments/${commentId}`,
+      {
+        method: "PUT",
+        headers: this.postHeaders,
+        body: JSON.stringify(update),
+      },
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to update comment: ${await response.text()}`);
+    }
+    return response.json();
+  }
+
+  async deleteComment(conversationId: string, commentId: number): Promise<void> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/comments/${commentId}`,
+      {
+        method: "DELETE",
+        headers: { "X-Shelley-Request": "1" },
+      },
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to delete comment: ${await response.text()}`);
+    }
+  }
+
+  async approvePlan(conversationId: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/plan/approve`, {
+      method: "POST",
+      headers: { "X-Shelley-Request": "1" },
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to approve plan: ${response.statusText}`);
+    }
+  }
+
   async getSubagents(conversationId: string): Promise<Conversation[]> {
     const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/subagents`);
     if (!response.ok) {
@@ -256,7 +515,7 @@ class ApiService {
 
   // Version check APIs
   async checkVersion(forceRefresh = false): Promise<VersionInfo> {
-    const url = forceRefresh ? "/version-check?refresh=true" : "/version-check";
+    const url = withBasePath(forceRefresh ? "/version-check?refresh=true" : "/version-check");
     const response = await fetch(url);
     if (!response.ok) {
       throw new Error(`Failed to check version: ${response.statusText}`);
@@ -266,7 +525,7 @@ class ApiService {
 
   async getChangelog(currentTag: string, latestTag: string): Promise<CommitInfo[]> {
     const params = new URLSearchParams({ current: currentTag, latest: latestTag });
-    const response = await fetch(`/version-changelog?${params}`);
+    const response = await fetch(withBasePath(`/version-changelog?${params}`));
     if (!response.ok) {
       throw new Error(`Failed to get changelog: ${response.statusText}`);
     }
@@ -274,7 +533,7 @@ class ApiService {
   }
 
   async upgrade(): Promise<{ status: string; message: string }> {
-    const response = await fetch("/upgrade", {
+    const response = await fetch(withBasePath("/upgrade"), {
       method: "POST",
       headers: { "X-Shelley-Request": "1" },
     });
@@ -286,7 +545,7 @@ class ApiService {
   }
 
   async exit(): Promise<{ status: string; message: string }> {
-    const response = await fetch("/exit", {
+    const response = await fetch(withBasePath("/exit"), {
       method: "POST",
       headers: { "X-Shelley-Request": "1" },
     });
@@ -330,7 +589,7 @@ export interface TestCustomModelRequest {
 }
 
 class CustomModelsApi {
-  private baseUrl = "/api";
+  private baseUrl = withBasePath("/api");
 
   private postHeaders = {
     "Content-Type": "application/json",
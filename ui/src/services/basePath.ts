@@ -0,0 +1,8 @@
+// basePath is the path prefix Shelley is served under (e.g. "/shelley"), or ""
+// when it is served at the root. The server injects it with the init data.
+export const basePath = window.__SHELLEY_INIT__?.base_path || "";
+
+// withBasePath prefixes an absolute path with the base path.
+export function withBasePath(path: string): string {
+  return basePath + path;
+}
@@ -0,0 +1,75 @@
+import { withBasePath } from "./basePath";
+
+// vapidPublicKey is the server's VAPID key, set when push notifications are
+// configured.
+const vapidPublicKey = window.__SHELLEY_INIT__?.vapid_public_key || "";
+
+export function isPushSupported(): boolean {
+  return (
+    vapidPublicKey !== "" &&
+    "serviceWorker" in navigator &&
+    "PushManager" in window &&
+    "Notification" in window
+  );
+}
+
+function decodeKey(key: string): Uint8Array {
+  const base64 = (key + "=".repeat((4 - (key.length % 4)) % 4))
+    .replace(/-/g, "+")
+    .replace(/_/g, "/");
+  return Uint8Array.from(atob(base64), (c) => c.charCodeAt(0));
+}
+
+async function getRegistration(): Promise<ServiceWorkerRegistration> {
+  return navigator.serviceWorker.register(withBasePath("/sw.js"), { scope: withBasePath("/") });
+}
+
+async function sendSubscription(method: string, body: unknown): Promise<void> {
+  const response = await fetch(withBasePath("/api/push/subscriptions"), {
+    method,
+    headers: { "Content-Type": "application/json", "X-Shelley-Request": "1" },
+    body: JSON.stringify(body),
+  });
+  if (!response.ok) {
+    throw new Error(`Failed to update push subscription: ${response.statusText}`);
+  }
+}
+
+// isPushSubscribed reports whether this browser is subscribed to notifications.
+export async function isPushSubscribed(): Promise<boolean> {
+  if (!isPushSupported() || Notification.permission !== "granted") {
+    return false;
+  }
+  const registration = await navigator.serviceWorker.getRegistration(withBasePath("/"));
+  const subscription = await registration?.pushManager.getSubscription();
+  return !!subscription;
+}
+
+// subscribePush asks for permission to show notifications and subscribes
+// this browser to them.
+export async function subscribePush(): Promise<void> {
+  const permission = await Notification.requestPermission();
+  if (permission !== "granted") {
+    throw new Error("Notification permission was denied");
+  }
+  const registration = await getRegistration();
+  let subscription = await registration.pushManager.getSubscription();
+  if (!subscription) {
+    subscription = await registration.pushManager.subscribe({
+      userVisibleOnly: true,
+      applicationServerKey: decodeKey(vapidPublicKey),
+    });
+  }
+  await sendSubscription("POST", subscription.toJSON());
+}
+
+// unsubscribePush stops notifications to this browser.
+export async function unsubscribePush(): Promise<void> {
+  const registration = await navigator.serviceWorker.getRegistration(withBasePath("/"));
+  const subscription = await registration?.pushManager.getSubscription();
+  if (!subscription) {
+    return;
+  }
+  await sendSubscription("DELETE", { endpoint: subscription.endpoint });
+  await subscription.unsubscribe();
+}
@@ -24,6 +24,7 @@ export interface LLMMessage {
   Role: number; // 0 = user, 1 = assistant
   Content: LLMContent[];
   ToolUse?: unknown;
+  ErrorType?: string; // set on system-generated error messages, e.g. "stuck"
 }
 
 export interface LLMContent {
@@ -47,23 +48,55 @@ export interface LLMContent {
 }
 
 // API types
+export interface ModelPricing {
+  input_per_mtok: number;
+  output_per_mtok: number;
+}
+
 export interface Model {
   id: string;
   display_name?: string;
   ready: boolean;
   max_context_tokens?: number;
+  provider?: string;
+  supports_vision?: boolean;
+  supports_tools?: boolean;
+  pricing?: ModelPricing;
+  // Set only when the list was requested with a live check
+  available?: boolean;
+  availability_error?: string;
 }
 
 export interface ChatRequest {
   message: string;
   model?: string;
   cwd?: string;
+  // Settings of the new conversation
+  settings?: {
+    timezone?: string;
+    locale?: string;
+  };
 }
 // StreamResponse represents the streaming response format
 export interface StreamResponse extends Omit<StreamResponseForTS, "messages"> {
   messages: Message[];
   context_window_size?: number;
   conversation_list_update?: ConversationListUpdate;
+  events?: ConversationEvent[];
+  // Users viewing the conversation, sent when they come and go
+  presence?: { users: string[] };
+}
+
+// Event of a conversation's timeline: the agent starting or stopping work
+// ("state"), or an LLM request or tool call starting or ending
+export interface ConversationEvent {
+  id: number;
+  type: "state" | "llm_request_start" | "llm_request_end" | "tool_start" | "tool_end";
+  ref_id?: string;
+  name?: string;
+  duration_ms?: number;
+  error?: string;
+  created_at: string;
 }
 
 // Link represents a custom link that can be added to the UI
@@ -82,6 +115,9 @@ export interface InitData {
   hostname?: string;
   terminal_url?: string;
   links?: Link[];
+  base_path?: string;
+  transcription?: boolean;
+  vapid_public_key?: string;
 }
 
 // Extend Window interface to include our init data
@@ -128,6 +164,59 @@ export interface DiffComment {
   diffId: string;
 }
 
+// Item of the agent's task list, kept with the todo tool
+export interface Todo {
+  content: string;
+  status: "pending" | "in_progress" | "completed";
+}
+
+// User annotation of a message: a rating, a flag and a note
+export interface MessageAnnotation {
+  message_id: string;
+  rating?: "up" | "down";
+  flagged: boolean;
+  note?: string;
+  updated_at: string;
+}
+
+// Review comment on a message or on lines of a file in a diff. Replies have
+// the parent_id of the comment that started their thread.
+export interface ReviewComment {
+  id: number;
+  parent_id?: number;
+  message_id?: string;
+  diff_id?: string;
+  file_path?: string;
+  start_line?: number;
+  end_line?: number;
+  author?: string;
+  body: string;
+  resolved: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+// Project of the conversation list: the git repository of the conversations'
+// working directory, or the directory itself outside one
+export interface ProjectGroup {
+  root: string;
+  name: string;
+  remote_url?: string;
+  conversation_count: number;
+  working_count: number;
+  updated_at: string;
+}
+
+// Bulk action on conversations, from POST /api/conversations/bulk
+export type BulkAction = "archive" | "unarchive" | "delete" | "tag" | "untag" | "export";
+
+export interface BulkResponse {
+  results: { conversation_id: string; ok: boolean; error?: string }[];
+  succeeded: number;
+  failed: number;
+  exports?: { conversation: Conversation; messages: Message[]; tags: string[] }[];
+}
+
 // Conversation list streaming update
 export interface ConversationListUpdate {
   type: "update" | "delete";
@@ -160,3 +249,23 @@ export interface CommitInfo {
   author: string;
   date: string;
 }
+
+export interface RecentDirectory {
+  path: string;
+  favorite: boolean;
+  last_used_at?: string;
+  exists: boolean;
+}
+
+// A conversation, directory or slash command offered by the quick switcher,
+// ranked by the server
+export interface QuickSearchItem {
+  type: "conversation" | "directory" | "command";
+  id: string;
+  title: string;
+  subtitle?: string;
+  last_used_at?: string;
+  uses?: number;
+  favorite?: boolean;
+  score: number;
+}
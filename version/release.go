@@ -0,0 +1,60 @@
+package version
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// PublicKey is the base64 ed25519 public key that signs checksums.txt of
+// releases, set at build time via ldflags. Builds without it verify only
+// checksums when upgrading.
+//
+// The signature asset is the raw signature of checksums.txt, as made by:
+//
+//	openssl pkeyutl -sign -inkey key.pem -rawin -in checksums.txt -out checksums.txt.sig
+var PublicKey = ""
+
+// Names of the release assets verifying the binaries
+const (
+	ChecksumsAsset = "checksums.txt"
+	SignatureAsset = "checksums.txt.sig"
+)
+
+// BinaryAsset returns the name of the release asset holding the binary for
+// this platform.
+func BinaryAsset() string {
+	return fmt.Sprintf("shelley_%s_%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// VerifyChecksums checks that signature is PublicKey's signature of checksums.
+func VerifyChecksums(checksums, signature []byte) error {
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release public key")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), checksums, signature) {
+		return fmt.Errorf("invalid signature of %s", ChecksumsAsset)
+	}
+	return nil
+}
+
+// Checksum returns the checksum of the named asset from the contents of
+// checksums.txt ("<sha256>  <name>" per line).
+func Checksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) >= 2 && parts[1] == name {
+			return parts[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading checksums: %w", err)
+	}
+	return "", fmt.Errorf("checksum for %s not found in %s", name, ChecksumsAsset)
+}
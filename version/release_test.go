@@ -0,0 +1,37 @@
+package version
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+)
+
+func TestVerifyChecksums(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := PublicKey
+	PublicKey = base64.StdEncoding.EncodeToString(pub)
+	t.Cleanup(func() { PublicKey = old })
+
+	checksums := []byte("abc123  " + BinaryAsset() + "\ndef456  other\n")
+	signature := ed25519.Sign(priv, checksums)
+	if err := VerifyChecksums(checksums, signature); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	if err := VerifyChecksums([]byte("000000  "+BinaryAsset()+"\n"), signature); err == nil {
+		t.Error("expected an error for tampered checksums")
+	}
+	PublicKey = "not-a-key"
+	if err := VerifyChecksums(checksums, signature); err == nil {
+		t.Error("expected an error for an invalid public key")
+	}
+
+	if sum, err := Checksum(checksums, BinaryAsset()); err != nil || sum != "abc123" {
+		t.Errorf("Checksum = %q, %v", sum, err)
+	}
+	if _, err := Checksum(checksums, "missing"); err == nil {
+		t.Error("expected an error for a missing asset")
+	}
+}
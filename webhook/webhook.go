@@ -0,0 +1,85 @@
+// Package webhook posts JSON notifications to HTTP endpoints, such as
+// generic webhooks and Slack incoming webhooks.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the body, as "sha256=<hex>",
+// when a secret is configured.
+const SignatureHeader = "X-Shelley-Signature"
+
+// Config configures a webhook.
+type Config struct {
+	URL string `json:"url"`
+	// Headers are added to requests, such as for authorization.
+	Headers map[string]string `json:"headers"`
+	// Secret signs requests, so receivers can check that they came from us.
+	Secret string `json:"secret"`
+}
+
+// Sender posts to a webhook.
+type Sender struct {
+	cfg        Config
+	HTTPClient *http.Client // optional
+}
+
+// NewSender creates a Sender for the webhook of cfg.
+func NewSender(cfg Config) (*Sender, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("webhook URL is not configured")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q", cfg.URL)
+	}
+	return &Sender{cfg: cfg}, nil
+}
+
+// Post posts payload as JSON.
+func (s *Sender) Post(ctx context.Context, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	if s.cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.cfg.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := s.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook request failed: %s: %s", resp.Status, data)
+	}
+	return nil
+}
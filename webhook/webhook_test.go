@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPost(t *testing.T) {
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "nope", http.StatusBadRequest)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer srv.Close()
+
+	sender, err := NewSender(Config{
+		URL:     srv.URL + "/hook",
+		Headers: map[string]string{"Authorization": "Bearer abc"},
+		Secret:  "s3cret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Post(context.Background(), map[string]string{"text": "done"}); err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"text":"done"}` {
+		t.Errorf("body = %s", body)
+	}
+	if header.Get("Authorization") != "Bearer abc" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", header)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	if got, want := header.Get(SignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+
+	failing, _ := NewSender(Config{URL: srv.URL + "/fail"})
+	if err := failing.Post(context.Background(), "x"); err == nil {
+		t.Error("Post to a failing webhook succeeded")
+	}
+	if _, err := NewSender(Config{URL: "ftp://example.com"}); err == nil {
+		t.Error("NewSender with an ftp URL succeeded")
+	}
+}
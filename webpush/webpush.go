@@ -0,0 +1,273 @@
+// Package webpush sends Web Push notifications to browsers: messages are
+// encrypted for the subscription (RFC 8291) and signed with the server's
+// VAPID key (RFC 8292).
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the server's VAPID keys, which identify it to push services.
+// Browsers subscribe with the public key, so it must not change.
+type Config struct {
+	// PublicKey is the uncompressed P-256 public key, base64url encoded.
+	PublicKey string `json:"vapid_public_key"`
+	// PrivateKey is the P-256 private key, base64url encoded.
+	PrivateKey string `json:"vapid_private_key"`
+	// Subject is a mailto: or https: URL push services can use to contact
+	// the server's operator.
+	Subject string `json:"subject"`
+}
+
+// GenerateConfig generates new VAPID keys.
+func GenerateConfig(subject string) (Config, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return Config{}, err
+	}
+	priv, err := key.Bytes()
+	if err != nil {
+		return Config{}, err
+	}
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return Config{}, err
+	}
+	return Config{
+		PublicKey:  base64.RawURLEncoding.EncodeToString(pub),
+		PrivateKey: base64.RawURLEncoding.EncodeToString(priv),
+		Subject:    subject,
+	}, nil
+}
+
+// Subscription is a browser's push subscription, in the JSON form of the
+// PushSubscription.toJSON() of browsers.
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// ErrGone is returned by Send when the push service reports that the
+// subscription expired or was unsubscribed; it should be deleted.
+var ErrGone = errors.New("push subscription is no longer valid")
+
+// Sender sends push messages.
+type Sender struct {
+	publicKey  string
+	key        *ecdsa.PrivateKey
+	subject    string
+	HTTPClient *http.Client // optional
+}
+
+// NewSender creates a Sender using the VAPID keys of cfg.
+func NewSender(cfg Config) (*Sender, error) {
+	if cfg.PublicKey == "" || cfg.PrivateKey == "" {
+		return nil, errors.New("VAPID keys are not configured")
+	}
+	priv, err := decodeBase64(cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), priv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if given, err := decodeBase64(cfg.PublicKey); err != nil || !bytes.Equal(given, pub) {
+		return nil, errors.New("VAPID public key does not match the private key")
+	}
+	subject := cfg.Subject
+	if subject == "" {
+		subject = "mailto:admin@localhost"
+	}
+	return &Sender{publicKey: base64.RawURLEncoding.EncodeToString(pub), key: key, subject: subject}, nil
+}
+
+// PublicKey returns the VAPID public key that browsers subscribe with.
+func (s *Sender) PublicKey() string {
+	return s.publicKey
+}
+
+// Send sends payload to the subscription. The push service drops the
+// message if the browser can't be reached within ttl.
+func (s *Sender) Send(ctx context.Context, sub Subscription, payload []byte, ttl time.Duration) error {
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil || endpoint.Scheme != "https" && endpoint.Scheme != "http" {
+		return fmt.Errorf("invalid push endpoint %q", sub.Endpoint)
+	}
+	body, err := encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+	token, err := s.vapidToken(endpoint.Scheme + "://" + endpoint.Host)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+	req.Header.Set("Authorization", "vapid t="+token+", k="+s.publicKey)
+
+	client := s.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("push request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode >= 300:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("push request failed: %s: %s", resp.Status, data)
+	}
+	return nil
+}
+
+// vapidToken returns a JWT for the push service at audience, signed with
+// the VAPID key.
+func (s *Sender) vapidToken(audience string) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": audience,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": s.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(signingInput))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, hash[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// recordSize is the record size of encrypted messages. Messages are sent
+// as a single record, so payloads must be smaller.
+const recordSize = 4096
+
+// encrypt encrypts payload for the subscription with the aes128gcm content
+// encoding of RFC 8188, keyed as RFC 8291 describes.
+func encrypt(sub Subscription, payload []byte) ([]byte, error) {
+	uaPublicBytes, err := decodeBase64(sub.Keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := decodeBase64(sub.Keys.Auth)
+	if err != nil || len(authSecret) == 0 {
+		return nil, fmt.Errorf("invalid auth secret")
+	}
+	// Push services take bodies of up to 4096 bytes: the 86-byte header, then
+	// the payload, the padding delimiter and the 16-byte tag
+	if len(payload) > recordSize-86-17 {
+		return nil, fmt.Errorf("push payload too large: %d bytes", len(payload))
+	}
+
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+	ecdhSecret, err := asKey.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	cek, nonce, err := deriveKeys(ecdhSecret, authSecret, salt, uaPublicBytes, asPublic)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, and the key id, which is our public key
+	var b bytes.Buffer
+	b.Write(salt)
+	binary.Write(&b, binary.BigEndian, uint32(recordSize))
+	b.WriteByte(byte(len(asPublic)))
+	b.Write(asPublic)
+	plaintext := append(append([]byte(nil), payload...), 0x02) // last record
+	return gcm.Seal(b.Bytes(), nonce, plaintext, nil), nil
+}
+
+// deriveKeys derives the content encryption key and nonce of a message.
+func deriveKeys(ecdhSecret, authSecret, salt, uaPublic, asPublic []byte) (cek, nonce []byte, err error) {
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, ecdhSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cek, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16); err != nil {
+		return nil, nil, err
+	}
+	if nonce, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12); err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
+
+// decodeBase64 decodes base64url or standard base64, padded or not, as
+// browsers and key generators differ.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,163 @@
+package webpush
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decrypt decrypts a message as the browser would.
+func decrypt(t *testing.T, body []byte, uaKey *ecdh.PrivateKey, authSecret []byte) string {
+	t.Helper()
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != recordSize {
+		t.Fatalf("record size = %d", rs)
+	}
+	idLen := int(body[20])
+	asPublicBytes := body[21 : 21+idLen]
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := uaKey.ECDH(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cek, nonce, err := deriveKeys(secret, authSecret, salt, uaKey.PublicKey().Bytes(), asPublicBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	if err != nil {
+		t.Fatalf("decrypting: %v", err)
+	}
+	if plaintext[len(plaintext)-1] != 0x02 {
+		t.Fatalf("missing padding delimiter: %q", plaintext)
+	}
+	return string(plaintext[:len(plaintext)-1])
+}
+
+// verifyVAPID checks the VAPID authorization of a push request.
+func verifyVAPID(t *testing.T, r *http.Request, audience string) {
+	t.Helper()
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "vapid ")
+	var token, key string
+	for _, part := range strings.Split(auth, ", ") {
+		if v, ok := strings.CutPrefix(part, "t="); ok {
+			token = v
+		} else if v, ok := strings.CutPrefix(part, "k="); ok {
+			key = v
+		}
+	}
+	pubBytes, _ := base64.RawURLEncoding.DecodeString(key)
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), pubBytes)
+	if err != nil {
+		t.Fatalf("VAPID key: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("VAPID token = %q", token)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(sig) != 64 || !ecdsa.Verify(pub, hash[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Fatal("VAPID signature does not verify")
+	}
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	json.Unmarshal(claimsJSON, &claims)
+	if claims.Aud != audience || claims.Sub != "mailto:ops@example.com" || claims.Exp < time.Now().Unix() {
+		t.Errorf("VAPID claims = %+v", claims)
+	}
+}
+
+func TestSend(t *testing.T) {
+	cfg, err := GenerateConfig("mailto:ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := NewSender(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender.PublicKey() != cfg.PublicKey {
+		t.Errorf("PublicKey = %s, want %s", sender.PublicKey(), cfg.PublicKey)
+	}
+
+	uaKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+
+	var received string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") != "3600" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		verifyVAPID(t, r, srv.URL)
+		body, _ := io.ReadAll(r.Body)
+		received = decrypt(t, body, uaKey, authSecret)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	var sub Subscription
+	sub.Endpoint = srv.URL + "/push/abc"
+	sub.Keys.P256dh = base64.RawURLEncoding.EncodeToString(uaKey.PublicKey().Bytes())
+	// Browsers send base64url; accept padded standard base64 too
+	sub.Keys.Auth = base64.StdEncoding.EncodeToString(authSecret)
+	if err := sender.Send(context.Background(), sub, []byte(`{"title":"Done"}`), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if received != `{"title":"Done"}` {
+		t.Errorf("received %q", received)
+	}
+
+	sub.Endpoint = srv.URL + "/gone"
+	if err := sender.Send(context.Background(), sub, []byte("x"), time.Hour); !errors.Is(err, ErrGone) {
+		t.Errorf("Send to an expired subscription = %v, want ErrGone", err)
+	}
+	if err := sender.Send(context.Background(), sub, make([]byte, 5000), time.Hour); err == nil {
+		t.Error("Send of an oversized payload succeeded")
+	}
+}
+
+func TestNewSender(t *testing.T) {
+	cfg, _ := GenerateConfig("")
+	other, _ := GenerateConfig("")
+	if _, err := NewSender(Config{}); err == nil {
+		t.Error("NewSender without keys succeeded")
+	}
+	if _, err := NewSender(Config{PublicKey: other.PublicKey, PrivateKey: cfg.PrivateKey}); err == nil {
+		t.Error("NewSender with mismatched keys succeeded")
+	}
+	if _, err := NewSender(Config{PublicKey: cfg.PublicKey, PrivateKey: "not base64!"}); err == nil {
+		t.Error("NewSender with an invalid key succeeded")
+	}
+}
@@ -0,0 +1,122 @@
+// Package wslpath translates paths between the Windows Subsystem for Linux
+// and the Windows host it runs on, the way the wslpath command does: the C:
+// drive is /mnt/c in WSL, and the distribution's own files are
+// \\wsl.localhost\<distribution> in Windows.
+package wslpath
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+// DefaultMountRoot is where WSL mounts Windows drives unless wsl.conf says
+// otherwise.
+const DefaultMountRoot = "/mnt"
+
+// Translator translates paths of one WSL distribution.
+type Translator struct {
+	// MountRoot is the directory Windows drives are mounted in, e.g. /mnt
+	// for C: at /mnt/c.
+	MountRoot string
+	// Distro is the name of the distribution. Paths outside the mounted
+	// drives have no Windows form if it is empty.
+	Distro string
+}
+
+// Detect returns the translator of the WSL distribution Shelley runs in, or
+// nil if it doesn't run in WSL.
+func Detect() *Translator {
+	distro := os.Getenv("WSL_DISTRO_NAME")
+	if distro == "" {
+		// Services don't inherit WSL_DISTRO_NAME
+		if _, err := os.Stat("/proc/sys/fs/binfmt_misc/WSLInterop"); err != nil {
+			return nil
+		}
+	}
+	conf, _ := os.ReadFile("/etc/wsl.conf")
+	return &Translator{MountRoot: mountRoot(string(conf)), Distro: distro}
+}
+
+// mountRoot returns the root setting of the automount section of wsl.conf.
+func mountRoot(conf string) string {
+	var section string
+	scanner := bufio.NewScanner(strings.NewReader(conf))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "automount" || strings.TrimSpace(key) != "root" {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if value = strings.TrimRight(value, "/"); value != "" {
+			return value
+		}
+		return "/"
+	}
+	return DefaultMountRoot
+}
+
+// ToWindows returns the Windows form of an absolute WSL path: C:\Users\me
+// for /mnt/c/Users/me, or \\wsl.localhost\<distribution>\home\me for
+// /home/me. Other paths are returned as they are.
+func (t *Translator) ToWindows(p string) string {
+	if !path.IsAbs(p) {
+		return p
+	}
+	p = path.Clean(p)
+	drives := strings.TrimSuffix(t.MountRoot, "/") + "/"
+	if rest, ok := strings.CutPrefix(p, drives); ok && len(rest) > 0 && isDriveLetter(rest[0]) && (len(rest) == 1 || rest[1] == '/') {
+		return strings.ToUpper(rest[:1]) + `:\` + strings.ReplaceAll(strings.TrimPrefix(rest[1:], "/"), "/", `\`)
+	}
+	if t.Distro == "" {
+		return p
+	}
+	return `\\wsl.localhost\` + t.Distro + strings.ReplaceAll(p, "/", `\`)
+}
+
+// ToLinux returns the WSL path of a Windows path: /mnt/c/Users/me for
+// C:\Users\me or C:/Users/me, and /home/me for \\wsl.localhost\<distribution>\home\me
+// or \\wsl$\<distribution>\home\me. Other paths, including those of other
+// distributions, are returned as they are.
+func (t *Translator) ToLinux(p string) string {
+	if hasDrive(p) {
+		rest := strings.ReplaceAll(p[2:], `\`, "/")
+		return path.Join(t.MountRoot, strings.ToLower(p[:1]), rest)
+	}
+	slashed := strings.ReplaceAll(p, `\`, "/")
+	for _, host := range []string{"//wsl.localhost/", "//wsl$/"} {
+		if len(slashed) < len(host) || !strings.EqualFold(slashed[:len(host)], host) {
+			continue
+		}
+		distro, rest, _ := strings.Cut(slashed[len(host):], "/")
+		if t.Distro != "" && strings.EqualFold(distro, t.Distro) {
+			return path.Join("/", rest)
+		}
+	}
+	return p
+}
+
+// IsWindowsPath reports whether p is a Windows path with a drive letter or
+// a WSL share, e.g. C:\src or \\wsl.localhost\Ubuntu\home.
+func IsWindowsPath(p string) bool {
+	if hasDrive(p) {
+		return true
+	}
+	slashed := strings.ToLower(strings.ReplaceAll(p, `\`, "/"))
+	return strings.HasPrefix(slashed, "//wsl.localhost/") || strings.HasPrefix(slashed, "//wsl$/")
+}
+
+// hasDrive reports whether p starts with a drive, as in C: or C:\.
+func hasDrive(p string) bool {
+	return len(p) >= 2 && isDriveLetter(p[0]) && p[1] == ':' && (len(p) == 2 || p[2] == '\\' || p[2] == '/')
+}
+
+func isDriveLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,93 @@
+package wslpath
+
+import "testing"
+
+func TestToWindows(t *testing.T) {
+	tr := &Translator{MountRoot: "/mnt", Distro: "Ubuntu"}
+	for in, want := range map[string]string{
+		"/mnt/c":               `C:\`,
+		"/mnt/c/":              `C:\`,
+		"/mnt/c/Users/me":      `C:\Users\me`,
+		"/mnt/d/src/../app":    `D:\app`,
+		"/home/me/src":         `\\wsl.localhost\Ubuntu\home\me\src`,
+		"/mnt/wsl/shared":      `\\wsl.localhost\Ubuntu\mnt\wsl\shared`,
+		"/":                    `\\wsl.localhost\Ubuntu\`,
+		"relative/path":        "relative/path",
+		"/mnt/c/Program Files": `C:\Program Files`,
+	} {
+		if got := tr.ToWindows(in); got != want {
+			t.Errorf("ToWindows(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	noDistro := &Translator{MountRoot: "/"}
+	if got := noDistro.ToWindows("/c/src"); got != `C:\src` {
+		t.Errorf("ToWindows with mount root / = %q", got)
+	}
+	if got := noDistro.ToWindows("/home/me"); got != "/home/me" {
+		t.Errorf("expected paths without a Windows form unchanged, got %q", got)
+	}
+}
+
+func TestToLinux(t *testing.T) {
+	tr := &Translator{MountRoot: "/mnt", Distro: "Ubuntu"}
+	for in, want := range map[string]string{
+		`C:\`:                                "/mnt/c",
+		`C:`:                                 "/mnt/c",
+		`C:\Users\me`:                        "/mnt/c/Users/me",
+		`c:/Users/me/`:                       "/mnt/c/Users/me",
+		`D:\src\..\app`:                      "/mnt/d/app",
+		`\\wsl.localhost\Ubuntu\home\me`:     "/home/me",
+		`\\WSL$\ubuntu\home\me`:              "/home/me",
+		`//wsl.localhost/Ubuntu/`:            "/",
+		`\\wsl.localhost\Debian\home\me`:     `\\wsl.localhost\Debian\home\me`,
+		"/home/me":                           "/home/me",
+		"relative":                           "relative",
+		`\\fileserver\share\docs`:            `\\fileserver\share\docs`,
+		`C:\Users\me\AppData\Local\Temp\x y`: "/mnt/c/Users/me/AppData/Local/Temp/x y",
+	} {
+		if got := tr.ToLinux(in); got != want {
+			t.Errorf("ToLinux(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	// Paths survive the round trip
+	for _, p := range []string{"/mnt/c/Users/me", "/home/me/src", "/mnt/e"} {
+		if got := tr.ToLinux(tr.ToWindows(p)); got != p {
+			t.Errorf("round trip of %q gave %q", p, got)
+		}
+	}
+}
+
+func TestIsWindowsPath(t *testing.T) {
+	for p, want := range map[string]bool{
+		`C:\src`:                  true,
+		"c:/src":                  true,
+		"C:":                      true,
+		`\\wsl.localhost\Ubuntu\`: true,
+		`\\wsl$\Ubuntu\home`:      true,
+		"/mnt/c/src":              false,
+		"C:src":                   false,
+		"CC:/src":                 false,
+		`\\fileserver\share`:      false,
+		"relative/C:/not-a-drive": false,
+	} {
+		if got := IsWindowsPath(p); got != want {
+			t.Errorf("IsWindowsPath(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestMountRoot(t *testing.T) {
+	for conf, want := range map[string]string{
+		"":                               "/mnt",
+		"[boot]\nsystemd=true\n":         "/mnt",
+		"[automount]\nroot = /windir/\n": "/windir",
+		"[automount]\nenabled=true\nroot=\"/\"\n": "/",
+		"[network]\nroot = /nope\n":               "/mnt",
+	} {
+		if got := mountRoot(conf); got != want {
+			t.Errorf("mountRoot(%q) = %q, want %q", conf, got, want)
+		}
+	}
+}